@@ -0,0 +1,110 @@
+// Package budget works out where agent spend stands against monthly budgets.
+package budget
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+// What a budget covers
+const (
+	ScopeUser    = "user"
+	ScopeProject = "project"
+)
+
+// Scope is whether the budget covers all of a user's projects or just one
+func Scope(b *db.AgentBudget) string {
+	if b.ProjectID != nil {
+		return ScopeProject
+	}
+	return ScopeUser
+}
+
+// owner is how messages to the user refer to the budget
+func owner(b *db.AgentBudget) string {
+	if b.ProjectID != nil {
+		return "this project's"
+	}
+	return "your"
+}
+
+// Period returns the budget month now falls in: from the latest reset day at
+// or before now to the next one, in UTC
+func Period(resetDay int, now time.Time) (start, end time.Time) {
+	now = now.UTC()
+	start = time.Date(now.Year(), now.Month(), resetDay, 0, 0, 0, 0, time.UTC)
+	if start.After(now) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start, start.AddDate(0, 1, 0)
+}
+
+// Status is a budget's spend so far this period
+type Status struct {
+	Budget      *db.AgentBudget
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	// Since is when spend starts counting: the period's start, or a reset by
+	// hand after it
+	Since    time.Time
+	SpentUSD float64
+}
+
+// NewStatus works out the budget's period at now. SpentUSD is left for the
+// caller to fill in from Since.
+func NewStatus(b *db.AgentBudget, now time.Time) *Status {
+	start, end := Period(b.ResetDay, now)
+	since := start
+	if b.ResetAt != nil && b.ResetAt.After(since) {
+		since = *b.ResetAt
+	}
+	return &Status{Budget: b, PeriodStart: start, PeriodEnd: end, Since: since}
+}
+
+// Exceeded reports whether the budget is used up
+func (s *Status) Exceeded() bool {
+	return s.SpentUSD >= s.Budget.MonthlyLimitUSD
+}
+
+// RemainingUSD is what is left to spend this period
+func (s *Status) RemainingUSD() float64 {
+	return math.Max(0, Round(s.Budget.MonthlyLimitUSD-s.SpentUSD))
+}
+
+// Crossed returns the highest warning threshold that spending the given amount
+// took the budget past, or 0 if it crossed none
+func (s *Status) Crossed(spentUSD float64) int {
+	before := s.SpentUSD - spentUSD
+	crossed := 0
+	for _, t := range s.Budget.WarningThresholds {
+		at := s.Budget.MonthlyLimitUSD * float64(t) / 100
+		if before < at && s.SpentUSD >= at {
+			crossed = t
+		}
+	}
+	return crossed
+}
+
+// Round rounds an amount in USD to the cent
+func Round(usd float64) float64 {
+	return math.Round(usd*100) / 100
+}
+
+// Exceeded is returned when a budget is used up
+type Exceeded struct {
+	*Status
+}
+
+func (e *Exceeded) Error() string {
+	return fmt.Sprintf("agent budget exceeded: $%.2f of %s $%.2f monthly budget spent; it resets on %s",
+		e.SpentUSD, owner(e.Budget), e.Budget.MonthlyLimitUSD, e.PeriodEnd.Format(time.DateOnly))
+}
+
+// Warning is the message for spend passing a warning threshold
+func (s *Status) Warning(threshold int) string {
+	return fmt.Sprintf("agent spend has passed %d%% of %s $%.2f monthly budget ($%.2f spent)",
+		threshold, owner(s.Budget), s.Budget.MonthlyLimitUSD, s.SpentUSD)
+}
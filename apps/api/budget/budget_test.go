@@ -0,0 +1,81 @@
+package budget
+
+import (
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestPeriod(t *testing.T) {
+	tests := []struct {
+		resetDay   int
+		now        string
+		start, end string
+	}{
+		{1, "2026-10-16T12:00:00Z", "2026-10-01T00:00:00Z", "2026-11-01T00:00:00Z"},
+		{1, "2026-10-01T00:00:00Z", "2026-10-01T00:00:00Z", "2026-11-01T00:00:00Z"},
+		{20, "2026-10-16T12:00:00Z", "2026-09-20T00:00:00Z", "2026-10-20T00:00:00Z"},
+		{15, "2026-01-10T00:00:00Z", "2025-12-15T00:00:00Z", "2026-01-15T00:00:00Z"},
+		// Times in other zones fall in the UTC period
+		{1, "2026-10-31T20:00:00-05:00", "2026-11-01T00:00:00Z", "2026-12-01T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		start, end := Period(tt.resetDay, date(tt.now))
+		if !start.Equal(date(tt.start)) || !end.Equal(date(tt.end)) {
+			t.Errorf("reset day %d at %s: expected %s to %s, got %s to %s", tt.resetDay, tt.now, tt.start, tt.end, start, end)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	now := date("2026-10-16T12:00:00Z")
+	b := &db.AgentBudget{MonthlyLimitUSD: 10, WarningThresholds: []int{50, 80}, ResetDay: 1}
+
+	s := NewStatus(b, now)
+	if !s.Since.Equal(date("2026-10-01T00:00:00Z")) {
+		t.Errorf("expected spend to count from the period start, got %s", s.Since)
+	}
+
+	// A reset by hand this period moves the start; one from last period doesn't
+	reset := date("2026-10-10T08:00:00Z")
+	b.ResetAt = &reset
+	if s := NewStatus(b, now); !s.Since.Equal(reset) {
+		t.Errorf("expected spend to count from the reset, got %s", s.Since)
+	}
+	old := date("2026-09-20T00:00:00Z")
+	b.ResetAt = &old
+	if s := NewStatus(b, now); !s.Since.Equal(s.PeriodStart) {
+		t.Errorf("expected an old reset to be ignored, got %s", s.Since)
+	}
+
+	s.SpentUSD = 8.5
+	if got := s.Crossed(4); got != 80 {
+		t.Errorf("expected going from 4.5 to 8.5 to cross 80%%, got %d", got)
+	}
+	if got := s.Crossed(0.6); got != 80 {
+		t.Errorf("expected going from 7.9 to 8.5 to cross 80%%, got %d", got)
+	}
+	if got := s.Crossed(0.4); got != 0 {
+		t.Errorf("expected going from 8.1 to 8.5 to cross nothing, got %d", got)
+	}
+	if s.Exceeded() || s.RemainingUSD() != 1.5 {
+		t.Errorf("expected 1.5 left, got %v", s.RemainingUSD())
+	}
+
+	s.SpentUSD = 10.25
+	if !s.Exceeded() || s.RemainingUSD() != 0 {
+		t.Errorf("expected the budget used up, got %v left", s.RemainingUSD())
+	}
+	if msg := (&Exceeded{s}).Error(); msg != "agent budget exceeded: $10.25 of your $10.00 monthly budget spent; it resets on 2026-11-01" {
+		t.Errorf("unexpected message: %s", msg)
+	}
+}
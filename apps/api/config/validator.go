@@ -98,6 +98,17 @@ func ValidateStartupConfig(logger *logging.Logger) error {
 		}
 	}
 
+	if policy := os.Getenv("RECONCILE_ORPHAN_POLICY"); policy != "" && policy != "report" && policy != "delete" {
+		logger.Warn("RECONCILE_ORPHAN_POLICY must be 'report' or 'delete', using default",
+			"value", policy,
+			"default", "report",
+		)
+	}
+
+	if os.Getenv("ADMIN_USER_IDS") == "" {
+		logger.Warn("ADMIN_USER_IDS not set, admin endpoints will reject every request")
+	}
+
 	if len(errs) > 0 {
 		// Log all errors for visibility
 		for _, e := range errs {
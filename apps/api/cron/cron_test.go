@@ -0,0 +1,125 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+	}
+
+	for _, expr := range tests {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) expected error", expr)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("failed to load timezone: %v", err)
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatalf("failed to load timezone: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{
+			name:  "later today",
+			expr:  "0 9 * * *",
+			after: time.Date(2026, 3, 2, 8, 30, 0, 0, newYork),
+			want:  time.Date(2026, 3, 2, 9, 0, 0, 0, newYork),
+		},
+		{
+			name:  "exactly on a run moves to the next",
+			expr:  "0 9 * * *",
+			after: time.Date(2026, 3, 2, 9, 0, 0, 0, newYork),
+			want:  time.Date(2026, 3, 3, 9, 0, 0, 0, newYork),
+		},
+		{
+			name:  "weekdays skip the weekend",
+			expr:  "0 19 * * mon-fri",
+			after: time.Date(2026, 3, 6, 20, 0, 0, 0, newYork), // Friday
+			want:  time.Date(2026, 3, 9, 19, 0, 0, 0, newYork),
+		},
+		{
+			name:  "steps",
+			expr:  "*/15 * * * *",
+			after: time.Date(2026, 3, 2, 8, 31, 0, 0, time.UTC),
+			want:  time.Date(2026, 3, 2, 8, 45, 0, 0, time.UTC),
+		},
+		{
+			name:  "sunday as 7",
+			expr:  "0 0 * * 7",
+			after: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), // Monday
+			want:  time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "day of month or day of week when both are set",
+			expr:  "0 0 15 * fri",
+			after: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), // Monday
+			want:  time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "descriptor",
+			expr:  "@monthly",
+			after: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "half hour offset",
+			expr:  "0 9 * * *",
+			after: time.Date(2026, 3, 2, 8, 45, 0, 0, kolkata),
+			want:  time.Date(2026, 3, 2, 9, 0, 0, 0, kolkata),
+		},
+		{
+			name:  "wall clock kept across daylight saving",
+			expr:  "0 9 * * *",
+			after: time.Date(2026, 3, 7, 10, 0, 0, 0, newYork),
+			want:  time.Date(2026, 3, 8, 9, 0, 0, 0, newYork),
+		},
+		{
+			name:  "time skipped by daylight saving doesn't fire",
+			expr:  "30 2 * * *",
+			after: time.Date(2026, 3, 7, 3, 0, 0, 0, newYork),
+			want:  time.Date(2026, 3, 9, 2, 30, 0, 0, newYork),
+		},
+		{
+			name:  "never fires",
+			expr:  "0 0 30 2 *",
+			after: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+			want:  time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+			if got := s.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.after, got, tt.want)
+			}
+		})
+	}
+}
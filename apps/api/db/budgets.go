@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrBudgetExists is returned when the user or project already has a budget
+var ErrBudgetExists = errors.New("budget already exists")
+
+// AgentBudget caps what agents may spend each month. Without a project it
+// covers all of the user's agent spend; with one it covers that project's.
+type AgentBudget struct {
+	ID                string  `json:"id"`
+	UserID            string  `json:"-"`
+	ProjectID         *string `json:"project_id"`
+	MonthlyLimitUSD   float64 `json:"monthly_limit_usd"`
+	WarningThresholds []int   `json:"warning_thresholds"`
+	// ResetDay is the day of the month (UTC) spend starts over
+	ResetDay int `json:"reset_day"`
+	// ResetAt is when the budget was last reset by hand
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+const agentBudgetColumns = `id, user_id, project_id, monthly_limit_usd::float8, warning_thresholds,
+		       reset_day, reset_at, created_at, updated_at`
+
+func scanAgentBudget(row pgx.Row) (*AgentBudget, error) {
+	var b AgentBudget
+	err := row.Scan(&b.ID, &b.UserID, &b.ProjectID, &b.MonthlyLimitUSD, &b.WarningThresholds,
+		&b.ResetDay, &b.ResetAt, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func scanAgentBudgets(rows pgx.Rows) ([]AgentBudget, error) {
+	defer rows.Close()
+
+	var budgets []AgentBudget
+	for rows.Next() {
+		b, err := scanAgentBudget(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan agent budget: %w", err)
+		}
+		budgets = append(budgets, *b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating agent budgets: %w", err)
+	}
+
+	return budgets, nil
+}
+
+// CreateAgentBudget adds a budget. It returns ErrBudgetExists if the user or
+// project already has one, and ErrNotFound if the project doesn't exist.
+func (c *Client) CreateAgentBudget(ctx context.Context, budget *AgentBudget) (*AgentBudget, error) {
+	b, err := scanAgentBudget(c.pool.QueryRow(ctx, `
+		INSERT INTO agent_budgets (user_id, project_id, monthly_limit_usd, warning_thresholds, reset_day)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+agentBudgetColumns,
+		budget.UserID, budget.ProjectID, budget.MonthlyLimitUSD, budget.WarningThresholds, budget.ResetDay))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return nil, ErrBudgetExists
+			case "23503":
+				return nil, ErrNotFound
+			}
+		}
+		return nil, fmt.Errorf("failed to create agent budget: %w", err)
+	}
+	return b, nil
+}
+
+// ListAgentBudgets returns a user's budgets, the user-wide one first
+func (c *Client) ListAgentBudgets(ctx context.Context, userID string) ([]AgentBudget, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+agentBudgetColumns+`
+		FROM agent_budgets
+		WHERE user_id = $1
+		ORDER BY project_id IS NOT NULL, created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agent budgets: %w", err)
+	}
+	return scanAgentBudgets(rows)
+}
+
+// GetAgentBudgetsFor returns the budgets that apply to agents running in a
+// project: its owner's user-wide budget and the project's own
+func (c *Client) GetAgentBudgetsFor(ctx context.Context, userID, projectID string) ([]AgentBudget, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+agentBudgetColumns+`
+		FROM agent_budgets
+		WHERE (user_id = $1 AND project_id IS NULL) OR project_id = $2
+		ORDER BY project_id IS NOT NULL
+	`, userID, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent budgets: %w", err)
+	}
+	return scanAgentBudgets(rows)
+}
+
+func (c *Client) GetAgentBudget(ctx context.Context, budgetID, userID string) (*AgentBudget, error) {
+	b, err := scanAgentBudget(c.pool.QueryRow(ctx, `
+		SELECT `+agentBudgetColumns+`
+		FROM agent_budgets
+		WHERE id = $1 AND user_id = $2
+	`, budgetID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get agent budget: %w", err)
+	}
+	return b, nil
+}
+
+// UpdateAgentBudget replaces a budget's limit, warnings and reset day
+func (c *Client) UpdateAgentBudget(ctx context.Context, budget *AgentBudget) (*AgentBudget, error) {
+	b, err := scanAgentBudget(c.pool.QueryRow(ctx, `
+		UPDATE agent_budgets
+		SET monthly_limit_usd = $3, warning_thresholds = $4, reset_day = $5
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+agentBudgetColumns,
+		budget.ID, budget.UserID, budget.MonthlyLimitUSD, budget.WarningThresholds, budget.ResetDay))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to update agent budget: %w", err)
+	}
+	return b, nil
+}
+
+// ResetAgentBudget starts the budget's spend over from the given time
+func (c *Client) ResetAgentBudget(ctx context.Context, budgetID, userID string, at time.Time) (*AgentBudget, error) {
+	b, err := scanAgentBudget(c.pool.QueryRow(ctx, `
+		UPDATE agent_budgets
+		SET reset_at = $3
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+agentBudgetColumns,
+		budgetID, userID, at))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to reset agent budget: %w", err)
+	}
+	return b, nil
+}
+
+func (c *Client) DeleteAgentBudget(ctx context.Context, budgetID, userID string) error {
+	result, err := c.pool.Exec(ctx, `
+		DELETE FROM agent_budgets
+		WHERE id = $1 AND user_id = $2
+	`, budgetID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete agent budget: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetAgentSpend totals what agents spent since the given time, in USD. A
+// budget's project, or else its user, scopes what counts.
+func (c *Client) GetAgentSpend(ctx context.Context, budget *AgentBudget, since time.Time) (float64, error) {
+	var spent float64
+	err := c.pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(cost_usd), 0)::float8
+		FROM llm_usage
+		WHERE CASE WHEN $2::uuid IS NULL THEN user_id = $1 ELSE project_id = $2 END
+		  AND created_at >= $3
+		  AND status = 'completed'
+	`, budget.UserID, budget.ProjectID, since).Scan(&spent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get agent spend: %w", err)
+	}
+	return spent, nil
+}
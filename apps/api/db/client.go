@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -12,6 +14,10 @@ import (
 
 var ErrNotFound = errors.New("not found")
 
+// ErrVersionMismatch is returned when a conditional update's expected version is
+// no longer the row's current version
+var ErrVersionMismatch = errors.New("version mismatch")
+
 type Client struct {
 	pool *pgxpool.Pool
 }
@@ -25,26 +31,69 @@ type Profile struct {
 }
 
 type Project struct {
-	ID                 string     `json:"id"`
-	UserID             string     `json:"user_id"`
-	Name               string     `json:"name"`
-	Description        *string    `json:"description,omitempty"`
-	FlyMachineID       *string    `json:"fly_machine_id,omitempty"`
-	FlyVolumeID        *string    `json:"fly_volume_id,omitempty"`
-	Status             string     `json:"status"`
-	ErrorMessage       *string    `json:"error_message,omitempty"`
-	BaseImage          string     `json:"base_image"`
-	EnvVars            any        `json:"env_vars"`
-	CPUKind            string     `json:"cpu_kind"`
-	CPUs               int        `json:"cpus"`
-	MemoryMB           int        `json:"memory_mb"`
-	VolumeSizeGB       int        `json:"volume_size_gb"`
-	GPUKind            *string    `json:"gpu_kind,omitempty"`
-	IdleTimeoutMinutes *int       `json:"idle_timeout_minutes,omitempty"`
-	PreviewToken       *string    `json:"preview_token,omitempty"`
-	LastAccessedAt     *time.Time `json:"last_accessed_at,omitempty"`
-	CreatedAt          time.Time  `json:"created_at"`
-	UpdatedAt          time.Time  `json:"updated_at"`
+	ID                 string            `json:"id"`
+	UserID             string            `json:"user_id"`
+	OrganizationID     *string           `json:"organization_id,omitempty"`
+	Name               string            `json:"name"`
+	Description        *string           `json:"description,omitempty"`
+	FlyMachineID       *string           `json:"fly_machine_id,omitempty"`
+	FlyVolumeID        *string           `json:"fly_volume_id,omitempty"`
+	Status             string            `json:"status"`
+	ErrorMessage       *string           `json:"error_message,omitempty"`
+	BaseImage          string            `json:"base_image"`
+	MachineImage       *string           `json:"machine_image,omitempty"`
+	EnvVarsEncrypted   *string           `json:"-"`
+	EnvPending         bool              `json:"env_pending"`
+	CPUKind            string            `json:"cpu_kind"`
+	CPUs               int               `json:"cpus"`
+	MemoryMB           int               `json:"memory_mb"`
+	VolumeSizeGB       int               `json:"volume_size_gb"`
+	GPUKind            *string           `json:"gpu_kind,omitempty"`
+	Region             *string           `json:"region,omitempty"`
+	IdleTimeoutMinutes *int              `json:"idle_timeout_minutes,omitempty"`
+	PreviewToken       *string           `json:"preview_token,omitempty"`
+	ParentProjectID    *string           `json:"parent_project_id,omitempty"`
+	HardwarePending    bool              `json:"hardware_pending"`
+	TemplateID         *string           `json:"template_id,omitempty"`
+	TemplateImage      *string           `json:"template_image,omitempty"`
+	ExposedPorts       []int             `json:"exposed_ports"`
+	TemplateSetup      *TemplateSetup    `json:"template_setup,omitempty"`
+	LastAccessedAt     *time.Time        `json:"last_accessed_at,omitempty"`
+	IdleWarnedAt       *time.Time        `json:"idle_warned_at,omitempty"`
+	IdleSnoozedUntil   *time.Time        `json:"idle_snoozed_until,omitempty"`
+	WakeOnRequest      bool              `json:"wake_on_request"`
+	ArchiveKey         *string           `json:"-"`
+	ArchiveSizeBytes   *int64            `json:"archive_size_bytes,omitempty"`
+	HibernatedAt       *time.Time        `json:"hibernated_at,omitempty"`
+	DeletedAt          *time.Time        `json:"deleted_at,omitempty"`
+	Tags               []string          `json:"tags"`
+	Labels             map[string]string `json:"labels"`
+	LabelsPending      bool              `json:"labels_pending"`
+	Version            int64             `json:"version"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
+	// Role is the reading user's role on the project: owner of their personal
+	// projects, their organization role on its projects. Only reads made on a
+	// user's behalf fill it in.
+	Role string `json:"-"`
+}
+
+// Image returns the image the project's machine should run: its template's image
+// if it has one, otherwise its platform base image
+func (p *Project) Image() string {
+	if p.TemplateImage != nil && *p.TemplateImage != "" {
+		return *p.TemplateImage
+	}
+	return p.BaseImage
+}
+
+// ImagePending reports whether the project's machine was created from an image
+// other than the one it should run, and must be recreated on the next start
+func (p *Project) ImagePending() bool {
+	if p.FlyMachineID == nil || *p.FlyMachineID == "" || p.MachineImage == nil {
+		return false
+	}
+	return *p.MachineImage != p.Image()
 }
 
 // HardwareConfig represents VM hardware configuration
@@ -65,10 +114,51 @@ type UserSettings struct {
 	DefaultVolumeSizeGB       int       `json:"default_volume_size_gb"`
 	DefaultGPUKind            *string   `json:"default_gpu_kind,omitempty"`
 	DefaultIdleTimeoutMinutes *int      `json:"default_idle_timeout_minutes,omitempty"`
+	Version                   int64     `json:"version"`
 	CreatedAt                 time.Time `json:"created_at"`
 	UpdatedAt                 time.Time `json:"updated_at"`
 }
 
+// projectColumns lists the columns read by scanProject, in order
+const projectColumns = `id, user_id, organization_id, name, description, fly_machine_id, fly_volume_id,
+		       status, error_message, base_image, machine_image, env_vars_encrypted, env_pending,
+		       cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind, region,
+		       idle_timeout_minutes, preview_token, parent_project_id, hardware_pending,
+		       template_id, template_image, exposed_ports, template_setup,
+		       last_accessed_at, idle_warned_at, idle_snoozed_until, wake_on_request,
+		       archive_key, archive_size_bytes, hibernated_at, deleted_at, tags, labels, labels_pending, version, created_at, updated_at`
+
+func scanProject(row pgx.Row, extra ...any) (*Project, error) {
+	var p Project
+	dest := []any{
+		&p.ID, &p.UserID, &p.OrganizationID, &p.Name, &p.Description,
+		&p.FlyMachineID, &p.FlyVolumeID, &p.Status, &p.ErrorMessage,
+		&p.BaseImage, &p.MachineImage, &p.EnvVarsEncrypted, &p.EnvPending,
+		&p.CPUKind, &p.CPUs, &p.MemoryMB, &p.VolumeSizeGB, &p.GPUKind, &p.Region,
+		&p.IdleTimeoutMinutes, &p.PreviewToken, &p.ParentProjectID, &p.HardwarePending,
+		&p.TemplateID, &p.TemplateImage, &p.ExposedPorts, &p.TemplateSetup,
+		&p.LastAccessedAt, &p.IdleWarnedAt, &p.IdleSnoozedUntil, &p.WakeOnRequest,
+		&p.ArchiveKey, &p.ArchiveSizeBytes, &p.HibernatedAt, &p.DeletedAt, &p.Tags, &p.Labels, &p.LabelsPending, &p.Version, &p.CreatedAt, &p.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// scanUserProject scans a project followed by the reading user's role on it
+func scanUserProject(row pgx.Row) (*Project, error) {
+	var role *string
+	p, err := scanProject(row, &role)
+	if err != nil {
+		return nil, err
+	}
+	if role != nil {
+		p.Role = *role
+	}
+	return p, nil
+}
+
 func NewClient(databaseURL string) (*Client, error) {
 	config, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
@@ -137,16 +227,144 @@ func (c *Client) UpdateProfile(ctx context.Context, userID string, displayName s
 // Project Methods
 // ============================================
 
-func (c *Client) ListProjects(ctx context.Context, userID string) ([]Project, error) {
-	rows, err := c.pool.Query(ctx, `
-		SELECT id, user_id, name, description, fly_machine_id, fly_volume_id,
-		       status, error_message, base_image, env_vars,
-		       cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind,
-		       idle_timeout_minutes, preview_token, last_accessed_at, created_at, updated_at
+// Sort keys for ListProjects
+const (
+	ProjectSortUpdatedAt      = "updated_at"
+	ProjectSortCreatedAt      = "created_at"
+	ProjectSortLastAccessedAt = "last_accessed_at"
+	ProjectSortName           = "name"
+)
+
+var projectSortExprs = map[string]string{
+	ProjectSortUpdatedAt: "updated_at",
+	ProjectSortCreatedAt: "created_at",
+	// Projects that were never opened sort by when they were created
+	ProjectSortLastAccessedAt: "COALESCE(last_accessed_at, created_at)",
+	ProjectSortName:           "name",
+}
+
+// ProjectListOptions filters, sorts and pages ListProjects. Zero values match everything.
+type ProjectListOptions struct {
+	Statuses []string
+	CPUKind  string
+	HasGPU   *bool
+	GPUKind  string
+	// Tags matches projects that have every tag
+	Tags []string
+	// Labels matches projects with every key set to the given value, and
+	// LabelKeys projects with every key set to anything
+	Labels    map[string]string
+	LabelKeys []string
+	// Search matches a substring of the name or description, ignoring case
+	Search string
+	// OrganizationID limits the listing to one organization's projects, or to
+	// personal projects when it points at ""
+	OrganizationID *string
+
+	Sort       string
+	Descending bool
+	// AfterValue and AfterID continue a listing after the project with this sort
+	// value and ID. AfterValue is a time.Time for time sorts and a string for name.
+	AfterValue any
+	AfterID    string
+	Limit      int
+}
+
+// ProjectSortValue returns the value a project is sorted by for the given key
+func ProjectSortValue(p *Project, sort string) any {
+	switch sort {
+	case ProjectSortCreatedAt:
+		return p.CreatedAt
+	case ProjectSortLastAccessedAt:
+		if p.LastAccessedAt != nil {
+			return *p.LastAccessedAt
+		}
+		return p.CreatedAt
+	case ProjectSortName:
+		return p.Name
+	default:
+		return p.UpdatedAt
+	}
+}
+
+// buildListProjectsQuery builds the keyset-paginated query behind ListProjects
+func buildListProjectsQuery(userID string, opts ProjectListOptions) (string, []any) {
+	args := []any{userID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	conditions := []string{projectAccess("$1"), "status != 'trashed'"}
+	if opts.OrganizationID != nil {
+		if *opts.OrganizationID == "" {
+			conditions = append(conditions, "organization_id IS NULL")
+		} else {
+			conditions = append(conditions, "organization_id = "+arg(*opts.OrganizationID))
+		}
+	}
+	if len(opts.Statuses) > 0 {
+		conditions = append(conditions, "status = ANY("+arg(opts.Statuses)+")")
+	}
+	if opts.CPUKind != "" {
+		conditions = append(conditions, "cpu_kind = "+arg(opts.CPUKind))
+	}
+	if opts.HasGPU != nil {
+		if *opts.HasGPU {
+			conditions = append(conditions, "gpu_kind IS NOT NULL")
+		} else {
+			conditions = append(conditions, "gpu_kind IS NULL")
+		}
+	}
+	if opts.GPUKind != "" {
+		conditions = append(conditions, "gpu_kind = "+arg(opts.GPUKind))
+	}
+	if len(opts.Tags) > 0 {
+		conditions = append(conditions, "tags @> "+arg(opts.Tags))
+	}
+	if len(opts.Labels) > 0 {
+		conditions = append(conditions, "labels @> "+arg(opts.Labels))
+	}
+	if len(opts.LabelKeys) > 0 {
+		conditions = append(conditions, "labels ?& "+arg(opts.LabelKeys))
+	}
+	if opts.Search != "" {
+		pattern := arg("%" + likeEscaper.Replace(opts.Search) + "%")
+		conditions = append(conditions, "(name ILIKE "+pattern+" OR description ILIKE "+pattern+")")
+	}
+
+	sortExpr, ok := projectSortExprs[opts.Sort]
+	if !ok {
+		sortExpr = projectSortExprs[ProjectSortUpdatedAt]
+	}
+	direction, cmp := "ASC", ">"
+	if opts.Descending {
+		direction, cmp = "DESC", "<"
+	}
+	if opts.AfterID != "" {
+		conditions = append(conditions, "("+sortExpr+", id) "+cmp+" ("+arg(opts.AfterValue)+", "+arg(opts.AfterID)+")")
+	}
+
+	query := `
+		SELECT ` + projectColumns + `, ` + projectRole("$1") + `
 		FROM projects
-		WHERE user_id = $1
-		ORDER BY updated_at DESC
-	`, userID)
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + sortExpr + ` ` + direction + `, id ` + direction
+	if opts.Limit > 0 {
+		query += `
+		LIMIT ` + arg(opts.Limit)
+	}
+	return query, args
+}
+
+// likeEscaper escapes LIKE wildcards so search text matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListProjects returns a page of the projects a user can reach, leaving out any
+// in the trash
+func (c *Client) ListProjects(ctx context.Context, userID string, opts ProjectListOptions) ([]Project, error) {
+	query, args := buildListProjectsQuery(userID, opts)
+	rows, err := c.pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list projects: %w", err)
 	}
@@ -154,18 +372,11 @@ func (c *Client) ListProjects(ctx context.Context, userID string) ([]Project, er
 
 	var projects []Project
 	for rows.Next() {
-		var p Project
-		err := rows.Scan(
-			&p.ID, &p.UserID, &p.Name, &p.Description,
-			&p.FlyMachineID, &p.FlyVolumeID, &p.Status, &p.ErrorMessage,
-			&p.BaseImage, &p.EnvVars,
-			&p.CPUKind, &p.CPUs, &p.MemoryMB, &p.VolumeSizeGB, &p.GPUKind,
-			&p.IdleTimeoutMinutes, &p.PreviewToken, &p.LastAccessedAt, &p.CreatedAt, &p.UpdatedAt,
-		)
+		p, err := scanUserProject(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan project: %w", err)
 		}
-		projects = append(projects, p)
+		projects = append(projects, *p)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -177,22 +388,12 @@ func (c *Client) ListProjects(ctx context.Context, userID string) ([]Project, er
 
 func (c *Client) GetProject(ctx context.Context, projectID string) (*Project, error) {
 	row := c.pool.QueryRow(ctx, `
-		SELECT id, user_id, name, description, fly_machine_id, fly_volume_id,
-		       status, error_message, base_image, env_vars,
-		       cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind,
-		       idle_timeout_minutes, preview_token, last_accessed_at, created_at, updated_at
+		SELECT `+projectColumns+`
 		FROM projects
 		WHERE id = $1
 	`, projectID)
 
-	var p Project
-	err := row.Scan(
-		&p.ID, &p.UserID, &p.Name, &p.Description,
-		&p.FlyMachineID, &p.FlyVolumeID, &p.Status, &p.ErrorMessage,
-		&p.BaseImage, &p.EnvVars,
-		&p.CPUKind, &p.CPUs, &p.MemoryMB, &p.VolumeSizeGB, &p.GPUKind,
-		&p.IdleTimeoutMinutes, &p.PreviewToken, &p.LastAccessedAt, &p.CreatedAt, &p.UpdatedAt,
-	)
+	p, err := scanProject(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -200,27 +401,19 @@ func (c *Client) GetProject(ctx context.Context, projectID string) (*Project, er
 		return nil, fmt.Errorf("failed to get project: %w", err)
 	}
 
-	return &p, nil
+	return p, nil
 }
 
+// GetProjectByUser returns a project the user can reach, with their role on it.
+// It returns ErrNotFound for projects the user can't reach.
 func (c *Client) GetProjectByUser(ctx context.Context, projectID, userID string) (*Project, error) {
 	row := c.pool.QueryRow(ctx, `
-		SELECT id, user_id, name, description, fly_machine_id, fly_volume_id,
-		       status, error_message, base_image, env_vars,
-		       cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind,
-		       idle_timeout_minutes, preview_token, last_accessed_at, created_at, updated_at
+		SELECT `+projectColumns+`, `+projectRole("$2")+`
 		FROM projects
-		WHERE id = $1 AND user_id = $2
+		WHERE id = $1 AND `+projectAccess("$2")+`
 	`, projectID, userID)
 
-	var p Project
-	err := row.Scan(
-		&p.ID, &p.UserID, &p.Name, &p.Description,
-		&p.FlyMachineID, &p.FlyVolumeID, &p.Status, &p.ErrorMessage,
-		&p.BaseImage, &p.EnvVars,
-		&p.CPUKind, &p.CPUs, &p.MemoryMB, &p.VolumeSizeGB, &p.GPUKind,
-		&p.IdleTimeoutMinutes, &p.PreviewToken, &p.LastAccessedAt, &p.CreatedAt, &p.UpdatedAt,
-	)
+	p, err := scanUserProject(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -228,10 +421,14 @@ func (c *Client) GetProjectByUser(ctx context.Context, projectID, userID string)
 		return nil, fmt.Errorf("failed to get project: %w", err)
 	}
 
-	return &p, nil
+	return p, nil
 }
 
-func (c *Client) CreateProject(ctx context.Context, userID, name string, description *string, baseImage string, hw *HardwareConfig, idleTimeoutMinutes *int) (*Project, error) {
+// CreateProject creates a stopped project. If tmpl is set, the project takes the
+// template's image and ports, and its setup is queued for first boot. The caller
+// encrypts the initial env vars. With an organization the project belongs to
+// it, and userID records who created it.
+func (c *Client) CreateProject(ctx context.Context, userID, name string, description *string, baseImage string, hw *HardwareConfig, idleTimeoutMinutes *int, tmpl *Template, envVarsEncrypted *string, tags []string, labels map[string]string, region string, organizationID *string) (*Project, error) {
 	// Use defaults if hardware config is nil
 	cpuKind := "shared"
 	cpus := 1
@@ -247,55 +444,217 @@ func (c *Client) CreateProject(ctx context.Context, userID, name string, descrip
 		gpuKind = hw.GPUKind
 	}
 
-	var p Project
-	err := c.pool.QueryRow(ctx, `
+	var templateID, templateImage *string
+	var setup *TemplateSetup
+	ports := []int{}
+	if tags == nil {
+		tags = []string{}
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if tmpl != nil {
+		templateID = &tmpl.ID
+		templateImage = tmpl.BaseImage
+		setup = tmpl.Setup()
+		if tmpl.Ports != nil {
+			ports = tmpl.Ports
+		}
+	}
+
+	p, err := scanUserProject(c.pool.QueryRow(ctx, `
 		INSERT INTO projects (user_id, name, description, base_image, status,
-		                      cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind, idle_timeout_minutes)
-		VALUES ($1, $2, $3, $4, 'stopped', $5, $6, $7, $8, $9, $10)
-		RETURNING id, user_id, name, description, fly_machine_id, fly_volume_id,
-		          status, error_message, base_image, env_vars,
-		          cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind,
-		          idle_timeout_minutes, preview_token, last_accessed_at, created_at, updated_at
-	`, userID, name, description, baseImage, cpuKind, cpus, memoryMB, volumeSizeGB, gpuKind, idleTimeoutMinutes).Scan(
-		&p.ID, &p.UserID, &p.Name, &p.Description,
-		&p.FlyMachineID, &p.FlyVolumeID, &p.Status, &p.ErrorMessage,
-		&p.BaseImage, &p.EnvVars,
-		&p.CPUKind, &p.CPUs, &p.MemoryMB, &p.VolumeSizeGB, &p.GPUKind,
-		&p.IdleTimeoutMinutes, &p.PreviewToken, &p.LastAccessedAt, &p.CreatedAt, &p.UpdatedAt,
-	)
+		                      cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind, idle_timeout_minutes,
+		                      template_id, template_image, env_vars_encrypted, exposed_ports, template_setup, tags, labels, region,
+		                      organization_id)
+		VALUES ($1, $2, $3, $4, 'stopped', $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		RETURNING `+projectColumns+`, `+projectRole("$1"),
+		userID, name, description, baseImage, cpuKind, cpus, memoryMB, volumeSizeGB, gpuKind, idleTimeoutMinutes,
+		templateID, templateImage, envVarsEncrypted, ports, setup, tags, labels, region, organizationID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create project: %w", err)
 	}
 
-	return &p, nil
+	return p, nil
 }
 
-func (c *Client) UpdateProject(ctx context.Context, projectID, userID string, name, description *string) (*Project, error) {
-	var p Project
-	err := c.pool.QueryRow(ctx, `
+// ForkProject creates a stopped copy of a project's configuration, made by the
+// given user and in the source's organization, if it has one. Env vars are
+// encrypted for the source's creator, so only their own forks keep them. The
+// fork starts without a machine or volume; the caller attaches a forked volume.
+// Template setup still pending on the source hasn't reached its volume, so it carries over.
+func (c *Client) ForkProject(ctx context.Context, sourceID, userID, name string, description *string) (*Project, error) {
+	p, err := scanUserProject(c.pool.QueryRow(ctx, `
+		INSERT INTO projects (user_id, organization_id, name, description, base_image, env_vars_encrypted, status,
+		                      cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind, idle_timeout_minutes,
+		                      parent_project_id, template_id, template_image, exposed_ports, template_setup, tags, labels, region)
+		SELECT $2, organization_id, $3, COALESCE($4, description), base_image,
+		       CASE WHEN user_id = $2 THEN env_vars_encrypted END, 'stopped',
+		       cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind, idle_timeout_minutes,
+		       id, template_id, template_image, exposed_ports, template_setup, tags, labels, region
+		FROM projects
+		WHERE id = $1 AND `+projectAccess("$2")+`
+		RETURNING `+projectColumns+`, `+projectRole("$2"),
+		sourceID, userID, name, description))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to fork project: %w", err)
+	}
+
+	return p, nil
+}
+
+// UpdateProject changes the fields that are set. A nil tags slice leaves the
+// tags alone; an empty one clears them. If ifVersion is set, the update only
+// applies while the project is still at that version.
+func (c *Client) UpdateProject(ctx context.Context, projectID, userID string, name, description *string, wakeOnRequest *bool, tags []string, ifVersion *int64) (*Project, error) {
+	p, err := scanUserProject(c.pool.QueryRow(ctx, `
 		UPDATE projects
 		SET name = COALESCE($3, name),
-		    description = COALESCE($4, description)
-		WHERE id = $1 AND user_id = $2
-		RETURNING id, user_id, name, description, fly_machine_id, fly_volume_id,
-		          status, error_message, base_image, env_vars,
-		          cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind,
-		          idle_timeout_minutes, preview_token, last_accessed_at, created_at, updated_at
-	`, projectID, userID, name, description).Scan(
-		&p.ID, &p.UserID, &p.Name, &p.Description,
-		&p.FlyMachineID, &p.FlyVolumeID, &p.Status, &p.ErrorMessage,
-		&p.BaseImage, &p.EnvVars,
-		&p.CPUKind, &p.CPUs, &p.MemoryMB, &p.VolumeSizeGB, &p.GPUKind,
-		&p.IdleTimeoutMinutes, &p.PreviewToken, &p.LastAccessedAt, &p.CreatedAt, &p.UpdatedAt,
-	)
+		    description = COALESCE($4, description),
+		    wake_on_request = COALESCE($5, wake_on_request),
+		    tags = COALESCE($6, tags)
+		WHERE id = $1 AND `+projectAccess("$2")+` AND ($7::bigint IS NULL OR version = $7)
+		RETURNING `+projectColumns+`, `+projectRole("$2"),
+		projectID, userID, name, description, wakeOnRequest, tags, ifVersion))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrNotFound
+			return nil, c.conditionalUpdateMiss(ctx, projectID, userID, ifVersion)
 		}
 		return nil, fmt.Errorf("failed to update project: %w", err)
 	}
 
-	return &p, nil
+	return p, nil
+}
+
+// SetProjectLabels replaces a project's labels. If they changed and the project
+// already has a machine, they are flagged as pending until the next start
+// writes them to the machine's metadata.
+func (c *Client) SetProjectLabels(ctx context.Context, projectID, userID string, labels map[string]string, ifVersion *int64) (*Project, error) {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	p, err := scanUserProject(c.pool.QueryRow(ctx, `
+		UPDATE projects
+		SET labels = $3,
+		    labels_pending = labels_pending OR (fly_machine_id IS NOT NULL AND labels IS DISTINCT FROM $3)
+		WHERE id = $1 AND `+projectAccess("$2")+` AND ($4::bigint IS NULL OR version = $4)
+		RETURNING `+projectColumns+`, `+projectRole("$2"),
+		projectID, userID, labels, ifVersion))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, c.conditionalUpdateMiss(ctx, projectID, userID, ifVersion)
+		}
+		return nil, fmt.Errorf("failed to set project labels: %w", err)
+	}
+
+	return p, nil
+}
+
+// conditionalUpdateMiss explains why an update limited to ifVersion matched no
+// project: ErrVersionMismatch if the user's project exists, else ErrNotFound
+func (c *Client) conditionalUpdateMiss(ctx context.Context, projectID, userID string, ifVersion *int64) error {
+	if ifVersion == nil {
+		return ErrNotFound
+	}
+	var exists bool
+	err := c.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1 AND `+projectAccess("$2")+`)
+	`, projectID, userID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check project: %w", err)
+	}
+	if exists {
+		return ErrVersionMismatch
+	}
+	return ErrNotFound
+}
+
+// UpdateProjectHardware stores a new hardware config. If the project already has a
+// machine or volume, it is flagged as pending until the next start applies it.
+func (c *Client) UpdateProjectHardware(ctx context.Context, projectID, userID string, hw *HardwareConfig) (*Project, error) {
+	p, err := scanUserProject(c.pool.QueryRow(ctx, `
+		UPDATE projects
+		SET cpu_kind = $3, cpus = $4, memory_mb = $5, volume_size_gb = $6, gpu_kind = $7,
+		    hardware_pending = (fly_machine_id IS NOT NULL OR fly_volume_id IS NOT NULL)
+		WHERE id = $1 AND `+projectAccess("$2")+`
+		RETURNING `+projectColumns+`, `+projectRole("$2"),
+		projectID, userID, hw.CPUKind, hw.CPUs, hw.MemoryMB, hw.VolumeSizeGB, hw.GPUKind))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to update project hardware: %w", err)
+	}
+
+	return p, nil
+}
+
+// ClearProjectHardwarePending marks the applied hardware as live on the project's machine
+// and volume. A config changed again since it was applied stays pending.
+func (c *Client) ClearProjectHardwarePending(ctx context.Context, projectID string, applied *HardwareConfig) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE projects SET hardware_pending = false
+		WHERE id = $1 AND cpu_kind = $2 AND cpus = $3 AND memory_mb = $4
+		  AND volume_size_gb = $5 AND gpu_kind IS NOT DISTINCT FROM $6
+	`, projectID, applied.CPUKind, applied.CPUs, applied.MemoryMB, applied.VolumeSizeGB, applied.GPUKind)
+	if err != nil {
+		return fmt.Errorf("failed to clear hardware pending: %w", err)
+	}
+	return nil
+}
+
+// SetProjectEnvVars stores a project's encrypted env vars. If the project already has
+// a machine, they are flagged as pending until the next start applies them. If
+// ifVersion is set the project must still be at that version, so vars read,
+// changed and written back can't overwrite someone else's change.
+func (c *Client) SetProjectEnvVars(ctx context.Context, projectID, userID string, encrypted *string, ifVersion *int64) (*Project, error) {
+	p, err := scanUserProject(c.pool.QueryRow(ctx, `
+		UPDATE projects
+		SET env_vars_encrypted = $3,
+		    env_pending = (fly_machine_id IS NOT NULL)
+		WHERE id = $1 AND `+projectAccess("$2")+` AND ($4::bigint IS NULL OR version = $4)
+		RETURNING `+projectColumns+`, `+projectRole("$2"),
+		projectID, userID, encrypted, ifVersion))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, c.conditionalUpdateMiss(ctx, projectID, userID, ifVersion)
+		}
+		return nil, fmt.Errorf("failed to set project env vars: %w", err)
+	}
+
+	return p, nil
+}
+
+// ClearProjectEnvPending marks the applied env vars as live on the project's machine.
+// Vars changed again since they were applied stay pending.
+func (c *Client) ClearProjectEnvPending(ctx context.Context, projectID string, applied *string) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE projects SET env_pending = false
+		WHERE id = $1 AND env_vars_encrypted IS NOT DISTINCT FROM $2
+	`, projectID, applied)
+	if err != nil {
+		return fmt.Errorf("failed to clear env pending: %w", err)
+	}
+	return nil
+}
+
+// ClearProjectLabelsPending marks the applied labels as live on the project's
+// machine. Labels changed again since they were applied stay pending.
+func (c *Client) ClearProjectLabelsPending(ctx context.Context, projectID string, applied map[string]string) error {
+	if applied == nil {
+		applied = map[string]string{}
+	}
+	_, err := c.pool.Exec(ctx, `
+		UPDATE projects SET labels_pending = false
+		WHERE id = $1 AND labels = $2
+	`, projectID, applied)
+	if err != nil {
+		return fmt.Errorf("failed to clear labels pending: %w", err)
+	}
+	return nil
 }
 
 func (c *Client) DeleteProject(ctx context.Context, projectID, userID string) error {
@@ -313,29 +672,63 @@ func (c *Client) DeleteProject(ctx context.Context, projectID, userID string) er
 	return nil
 }
 
-func (c *Client) UpdateProjectStatus(ctx context.Context, projectID, status string, errorMsg *string) error {
+// UpdateProjectMachine records a newly created machine and the image it runs
+func (c *Client) UpdateProjectMachine(ctx context.Context, projectID, machineID, image string) error {
 	_, err := c.pool.Exec(ctx, `
-		UPDATE projects SET status = $1, error_message = $2 WHERE id = $3
-	`, status, errorMsg, projectID)
+		WITH updated AS (
+			UPDATE projects SET fly_machine_id = $1, machine_image = $3 WHERE id = $2
+			RETURNING id, user_id, organization_id, status
+		)
+		INSERT INTO project_events (project_id, user_id, organization_id, type, status, data)
+		SELECT id, user_id, organization_id, 'machine_assigned', status, jsonb_build_object('machine_id', $1::text, 'image', $3::text)
+		FROM updated
+	`, machineID, projectID, image)
 	if err != nil {
-		return fmt.Errorf("failed to update project status: %w", err)
+		return fmt.Errorf("failed to update project machine: %w", err)
 	}
 	return nil
 }
 
-func (c *Client) UpdateProjectMachine(ctx context.Context, projectID, machineID string) error {
+// DetachProjectMachine forgets a machine that the caller has deleted. Unlike
+// ClearProjectMachine it is meant to be called from inside a lifecycle operation.
+func (c *Client) DetachProjectMachine(ctx context.Context, projectID, machineID string) error {
 	_, err := c.pool.Exec(ctx, `
-		UPDATE projects SET fly_machine_id = $1 WHERE id = $2
-	`, machineID, projectID)
+		UPDATE projects SET fly_machine_id = NULL, machine_image = NULL
+		WHERE id = $1 AND fly_machine_id = $2
+	`, projectID, machineID)
 	if err != nil {
-		return fmt.Errorf("failed to update project machine: %w", err)
+		return fmt.Errorf("failed to detach project machine: %w", err)
 	}
 	return nil
 }
 
+// SetProjectImage sets the base image a project should run. A machine created from
+// another image is recreated on the project's next start.
+func (c *Client) SetProjectImage(ctx context.Context, projectID, image string) (*Project, error) {
+	p, err := scanProject(c.pool.QueryRow(ctx, `
+		UPDATE projects SET base_image = $2
+		WHERE id = $1
+		RETURNING `+projectColumns,
+		projectID, image))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to set project image: %w", err)
+	}
+
+	return p, nil
+}
+
 func (c *Client) UpdateProjectVolume(ctx context.Context, projectID, volumeID string) error {
 	_, err := c.pool.Exec(ctx, `
-		UPDATE projects SET fly_volume_id = $1 WHERE id = $2
+		WITH updated AS (
+			UPDATE projects SET fly_volume_id = $1 WHERE id = $2
+			RETURNING id, user_id, organization_id, status
+		)
+		INSERT INTO project_events (project_id, user_id, organization_id, type, status, data)
+		SELECT id, user_id, organization_id, 'volume_assigned', status, jsonb_build_object('volume_id', $1::text)
+		FROM updated
 	`, volumeID, projectID)
 	if err != nil {
 		return fmt.Errorf("failed to update project volume: %w", err)
@@ -343,6 +736,20 @@ func (c *Client) UpdateProjectVolume(ctx context.Context, projectID, volumeID st
 	return nil
 }
 
+// MoveProjectRegion places a project in region, on volumeID if it has one there
+func (c *Client) MoveProjectRegion(ctx context.Context, projectID, region string, volumeID *string) error {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE projects SET region = $1, fly_volume_id = $2 WHERE id = $3
+	`, region, volumeID, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to move project region: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (c *Client) UpdateProjectLastAccessed(ctx context.Context, projectID string) error {
 	_, err := c.pool.Exec(ctx, `
 		UPDATE projects SET last_accessed_at = now() WHERE id = $1
@@ -353,14 +760,37 @@ func (c *Client) UpdateProjectLastAccessed(ctx context.Context, projectID string
 	return nil
 }
 
+// ClaimProjectIdleWarning records that clients are being warned of an upcoming idle
+// stop. It only succeeds if the project hasn't been warned since it went idle, so
+// each warning is sent by one replica.
+func (c *Client) ClaimProjectIdleWarning(ctx context.Context, projectID string, idleSince time.Time) (bool, error) {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE projects
+		SET idle_warned_at = now()
+		WHERE id = $1 AND status = 'running' AND (idle_warned_at IS NULL OR idle_warned_at < $2)
+	`, projectID, idleSince)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim idle warning: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// SnoozeProjectIdle holds off idle stops until the given time
+func (c *Client) SnoozeProjectIdle(ctx context.Context, projectID string, until time.Time) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE projects SET idle_snoozed_until = $2 WHERE id = $1
+	`, projectID, until)
+	if err != nil {
+		return fmt.Errorf("failed to snooze idle stop: %w", err)
+	}
+	return nil
+}
+
 // GetRunningProjects returns all running projects for idle checking
 // The caller handles per-project timeout logic
 func (c *Client) GetRunningProjects(ctx context.Context) ([]Project, error) {
 	rows, err := c.pool.Query(ctx, `
-		SELECT id, user_id, name, description, fly_machine_id, fly_volume_id,
-		       status, error_message, base_image, env_vars,
-		       cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind,
-		       idle_timeout_minutes, preview_token, last_accessed_at, created_at, updated_at
+		SELECT `+projectColumns+`
 		FROM projects
 		WHERE status = 'running'
 		  AND last_accessed_at IS NOT NULL
@@ -372,45 +802,177 @@ func (c *Client) GetRunningProjects(ctx context.Context) ([]Project, error) {
 
 	var projects []Project
 	for rows.Next() {
-		var p Project
-		err := rows.Scan(
-			&p.ID, &p.UserID, &p.Name, &p.Description,
-			&p.FlyMachineID, &p.FlyVolumeID, &p.Status, &p.ErrorMessage,
-			&p.BaseImage, &p.EnvVars,
-			&p.CPUKind, &p.CPUs, &p.MemoryMB, &p.VolumeSizeGB, &p.GPUKind,
-			&p.IdleTimeoutMinutes, &p.PreviewToken, &p.LastAccessedAt, &p.CreatedAt, &p.UpdatedAt,
-		)
+		p, err := scanProject(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan project: %w", err)
 		}
-		projects = append(projects, p)
+		projects = append(projects, *p)
 	}
 
 	return projects, nil
 }
 
-// GetProjectByIDPrefix finds a running project by ID prefix (first 8 chars)
-// Used by the gateway proxy to resolve subdomain to full project
+// ListHibernationCandidates returns stopped projects with a machine and volume that
+// nobody has opened since the given time, least recently used first
+func (c *Client) ListHibernationCandidates(ctx context.Context, inactiveSince time.Time, limit int) ([]Project, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+projectColumns+`
+		FROM projects
+		WHERE status = 'stopped'
+		  AND fly_machine_id IS NOT NULL
+		  AND fly_volume_id IS NOT NULL
+		  AND COALESCE(last_accessed_at, created_at) < $1
+		ORDER BY COALESCE(last_accessed_at, created_at)
+		LIMIT $2
+	`, inactiveSince, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hibernation candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var projects []Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan project: %w", err)
+		}
+		projects = append(projects, *p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating projects: %w", err)
+	}
+
+	return projects, nil
+}
+
+// SetProjectArchive records the archive holding a project's volume contents
+func (c *Client) SetProjectArchive(ctx context.Context, projectID, key string, sizeBytes int64) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE projects
+		SET archive_key = $2, archive_size_bytes = $3, hibernated_at = now()
+		WHERE id = $1
+	`, projectID, key, sizeBytes)
+	if err != nil {
+		return fmt.Errorf("failed to set project archive: %w", err)
+	}
+	return nil
+}
+
+// ClearProjectArchive forgets a project's archive once it has been restored.
+// An archive replaced in the meantime is kept.
+func (c *Client) ClearProjectArchive(ctx context.Context, projectID, key string) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE projects
+		SET archive_key = NULL, archive_size_bytes = NULL, hibernated_at = NULL
+		WHERE id = $1 AND archive_key = $2
+	`, projectID, key)
+	if err != nil {
+		return fmt.Errorf("failed to clear project archive: %w", err)
+	}
+	return nil
+}
+
+// GetAllProjects returns every project, used by the reconciler to compare
+// database state against the compute backend
+func (c *Client) GetAllProjects(ctx context.Context) ([]Project, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+projectColumns+`
+		FROM projects
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all projects: %w", err)
+	}
+	defer rows.Close()
+
+	var projects []Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan project: %w", err)
+		}
+		projects = append(projects, *p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating projects: %w", err)
+	}
+
+	return projects, nil
+}
+
+// ReconcileProjectStatus sets a project's status only if it still has the expected
+// status and no lifecycle operation is in flight. Returns false if nothing changed.
+// Corrections reflect observed backend state, so they bypass the transition table.
+func (c *Client) ReconcileProjectStatus(ctx context.Context, projectID, expected, status string) (bool, error) {
+	result, err := c.pool.Exec(ctx, `
+		WITH updated AS (
+			UPDATE projects SET status = $3, error_message = NULL
+			WHERE id = $1 AND status = $2
+			  AND NOT EXISTS (
+			      SELECT 1 FROM project_operations
+			      WHERE project_id = $1 AND status IN ('pending', 'running')
+			  )
+			RETURNING id, user_id, organization_id, status
+		)
+		INSERT INTO project_events (project_id, user_id, organization_id, type, status, data)
+		SELECT id, user_id, organization_id, 'status', status, jsonb_build_object('from', $2::text, 'reconciled', true)
+		FROM updated
+	`, projectID, expected, status)
+	if err != nil {
+		return false, fmt.Errorf("failed to reconcile project status: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// ClearProjectMachine removes a stale machine reference if it still points at machineID
+// and no lifecycle operation is in flight. Returns false if nothing changed.
+func (c *Client) ClearProjectMachine(ctx context.Context, projectID, machineID string) (bool, error) {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE projects SET fly_machine_id = NULL, machine_image = NULL
+		WHERE id = $1 AND fly_machine_id = $2
+		  AND NOT EXISTS (
+		      SELECT 1 FROM project_operations
+		      WHERE project_id = $1 AND status IN ('pending', 'running')
+		  )
+	`, projectID, machineID)
+	if err != nil {
+		return false, fmt.Errorf("failed to clear project machine: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// ClearProjectVolume removes a stale volume reference if it still points at volumeID
+// and no lifecycle operation is in flight. Returns false if nothing changed.
+func (c *Client) ClearProjectVolume(ctx context.Context, projectID, volumeID string) (bool, error) {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE projects SET fly_volume_id = NULL
+		WHERE id = $1 AND fly_volume_id = $2
+		  AND NOT EXISTS (
+		      SELECT 1 FROM project_operations
+		      WHERE project_id = $1 AND status IN ('pending', 'running')
+		  )
+	`, projectID, volumeID)
+	if err != nil {
+		return false, fmt.Errorf("failed to clear project volume: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// GetProjectByIDPrefix finds a project by ID prefix (first 8 chars), preferring a
+// running one if the prefix is shared. Used by the gateway proxy to resolve
+// subdomain to full project, including stopped projects it may wake.
 func (c *Client) GetProjectByIDPrefix(ctx context.Context, prefix string) (*Project, error) {
 	row := c.pool.QueryRow(ctx, `
-		SELECT id, user_id, name, description, fly_machine_id, fly_volume_id,
-		       status, error_message, base_image, env_vars,
-		       cpu_kind, cpus, memory_mb, volume_size_gb, gpu_kind,
-		       idle_timeout_minutes, preview_token, last_accessed_at, created_at, updated_at
+		SELECT `+projectColumns+`
 		FROM projects
 		WHERE id::text LIKE $1 || '%'
-		  AND status = 'running'
+		  AND status NOT IN ('deleting', 'trashed')
+		ORDER BY (status = 'running') DESC, created_at
 		LIMIT 1
 	`, prefix)
 
-	var p Project
-	err := row.Scan(
-		&p.ID, &p.UserID, &p.Name, &p.Description,
-		&p.FlyMachineID, &p.FlyVolumeID, &p.Status, &p.ErrorMessage,
-		&p.BaseImage, &p.EnvVars,
-		&p.CPUKind, &p.CPUs, &p.MemoryMB, &p.VolumeSizeGB, &p.GPUKind,
-		&p.IdleTimeoutMinutes, &p.PreviewToken, &p.LastAccessedAt, &p.CreatedAt, &p.UpdatedAt,
-	)
+	p, err := scanProject(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -418,7 +980,7 @@ func (c *Client) GetProjectByIDPrefix(ctx context.Context, prefix string) (*Proj
 		return nil, fmt.Errorf("failed to get project by prefix: %w", err)
 	}
 
-	return &p, nil
+	return p, nil
 }
 
 // ============================================
@@ -467,7 +1029,7 @@ func (c *Client) GetUserSettings(ctx context.Context, userID string) (*UserSetti
 	row := c.pool.QueryRow(ctx, `
 		SELECT user_id, default_cpu_kind, default_cpus, default_memory_mb,
 		       default_volume_size_gb, default_gpu_kind, default_idle_timeout_minutes,
-		       created_at, updated_at
+		       version, created_at, updated_at
 		FROM user_settings
 		WHERE user_id = $1
 	`, userID)
@@ -476,7 +1038,7 @@ func (c *Client) GetUserSettings(ctx context.Context, userID string) (*UserSetti
 	err := row.Scan(
 		&s.UserID, &s.DefaultCPUKind, &s.DefaultCPUs, &s.DefaultMemoryMB,
 		&s.DefaultVolumeSizeGB, &s.DefaultGPUKind, &s.DefaultIdleTimeoutMinutes,
-		&s.CreatedAt, &s.UpdatedAt,
+		&s.Version, &s.CreatedAt, &s.UpdatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -488,8 +1050,9 @@ func (c *Client) GetUserSettings(ctx context.Context, userID string) (*UserSetti
 	return &s, nil
 }
 
-// UpdateUserSettings updates user default settings
-func (c *Client) UpdateUserSettings(ctx context.Context, userID string, settings *UserSettings) (*UserSettings, error) {
+// UpdateUserSettings updates user default settings. If ifVersion is set, the update
+// only applies while the settings are still at that version.
+func (c *Client) UpdateUserSettings(ctx context.Context, userID string, settings *UserSettings, ifVersion *int64) (*UserSettings, error) {
 	var s UserSettings
 	err := c.pool.QueryRow(ctx, `
 		UPDATE user_settings
@@ -499,18 +1062,24 @@ func (c *Client) UpdateUserSettings(ctx context.Context, userID string, settings
 		    default_volume_size_gb = $5,
 		    default_gpu_kind = $6,
 		    default_idle_timeout_minutes = $7
-		WHERE user_id = $1
+		WHERE user_id = $1 AND ($8::bigint IS NULL OR version = $8)
 		RETURNING user_id, default_cpu_kind, default_cpus, default_memory_mb,
 		          default_volume_size_gb, default_gpu_kind, default_idle_timeout_minutes,
-		          created_at, updated_at
+		          version, created_at, updated_at
 	`, userID, settings.DefaultCPUKind, settings.DefaultCPUs, settings.DefaultMemoryMB,
-		settings.DefaultVolumeSizeGB, settings.DefaultGPUKind, settings.DefaultIdleTimeoutMinutes).Scan(
+		settings.DefaultVolumeSizeGB, settings.DefaultGPUKind, settings.DefaultIdleTimeoutMinutes, ifVersion).Scan(
 		&s.UserID, &s.DefaultCPUKind, &s.DefaultCPUs, &s.DefaultMemoryMB,
 		&s.DefaultVolumeSizeGB, &s.DefaultGPUKind, &s.DefaultIdleTimeoutMinutes,
-		&s.CreatedAt, &s.UpdatedAt,
+		&s.Version, &s.CreatedAt, &s.UpdatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
+			if ifVersion != nil {
+				var exists bool
+				if err := c.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM user_settings WHERE user_id = $1)`, userID).Scan(&exists); err == nil && exists {
+					return nil, ErrVersionMismatch
+				}
+			}
 			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("failed to update user settings: %w", err)
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildListProjectsQuery(t *testing.T) {
+	after := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	hasGPU := false
+	query, args := buildListProjectsQuery("user-1", ProjectListOptions{
+		Statuses:   []string{StatusRunning},
+		HasGPU:     &hasGPU,
+		Tags:       []string{"ml"},
+		Labels:     map[string]string{"client": "acme"},
+		LabelKeys:  []string{"team"},
+		Search:     "50%_off",
+		Sort:       ProjectSortLastAccessedAt,
+		Descending: true,
+		AfterValue: after,
+		AfterID:    "p-1",
+		Limit:      11,
+	})
+
+	for _, want := range []string{
+		"user_id = $1",
+		"status != 'trashed'",
+		"status = ANY($2)",
+		"gpu_kind IS NULL",
+		"tags @> $3",
+		"labels @> $4",
+		"labels ?& $5",
+		"(name ILIKE $6 OR description ILIKE $6)",
+		"(COALESCE(last_accessed_at, created_at), id) < ($7, $8)",
+		"ORDER BY COALESCE(last_accessed_at, created_at) DESC, id DESC",
+		"LIMIT $9",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("expected query to contain %q:\n%s", want, query)
+		}
+	}
+
+	wantArgs := []any{"user-1", []string{StatusRunning}, []string{"ml"}, map[string]string{"client": "acme"}, []string{"team"}, `%50\%\_off%`, after, "p-1", 11}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", args, wantArgs)
+	}
+}
+
+func TestBuildListProjectsQuery_Defaults(t *testing.T) {
+	query, args := buildListProjectsQuery("user-1", ProjectListOptions{})
+	if !strings.Contains(query, "ORDER BY updated_at ASC, id ASC") || strings.Contains(query, "LIMIT") {
+		t.Errorf("unexpected default query:\n%s", query)
+	}
+	if len(args) != 1 {
+		t.Errorf("expected only the user ID as an argument, got %v", args)
+	}
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Project event types
+const (
+	EventStatus              = "status"
+	EventMachineAssigned     = "machine_assigned"
+	EventVolumeAssigned      = "volume_assigned"
+	EventIdleStop            = "idle_stop"
+	EventIdleWarning         = "idle_warning"
+	EventIdleSnoozed         = "idle_snoozed"
+	EventSnapshotCreated     = "snapshot_created"
+	EventSnapshotRestored    = "snapshot_restored"
+	EventTemplateApplied     = "template_applied"
+	EventTemplateFailed      = "template_failed"
+	EventImageUpgraded       = "image_upgraded"
+	EventScheduleRun         = "schedule_run"
+	EventPreviewWake         = "preview_wake"
+	EventHibernated          = "hibernated"
+	EventArchiveRestored     = "archive_restored"
+	EventRegionMoved         = "region_moved"
+	EventOrganizationChanged = "organization_changed"
+)
+
+// ProjectEvent is an entry in the project lifecycle event log
+type ProjectEvent struct {
+	ID        int64  `json:"id"`
+	ProjectID string `json:"project_id"`
+	UserID    string `json:"-"`
+	// OrganizationID is the project's organization when the event was
+	// recorded; its members receive the event instead of just the owner
+	OrganizationID *string        `json:"-"`
+	Type           string         `json:"type"`
+	Status         *string        `json:"status,omitempty"`
+	Message        *string        `json:"message,omitempty"`
+	Data           map[string]any `json:"data,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+const projectEventColumns = `id, project_id, user_id, organization_id, type, status, message, data, created_at`
+
+func scanProjectEvents(rows pgx.Rows) ([]ProjectEvent, error) {
+	defer rows.Close()
+
+	var events []ProjectEvent
+	for rows.Next() {
+		var e ProjectEvent
+		if err := rows.Scan(&e.ID, &e.ProjectID, &e.UserID, &e.OrganizationID, &e.Type, &e.Status, &e.Message, &e.Data, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan project event: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating project events: %w", err)
+	}
+
+	return events, nil
+}
+
+// ============================================
+// Project Event Methods
+// ============================================
+
+// RecordProjectEvent appends an event for a project, stamped with its owner,
+// organization and current status
+func (c *Client) RecordProjectEvent(ctx context.Context, projectID, eventType string, message *string, data map[string]any) error {
+	if data == nil {
+		data = map[string]any{}
+	}
+	_, err := c.pool.Exec(ctx, `
+		INSERT INTO project_events (project_id, user_id, organization_id, type, status, message, data)
+		SELECT id, user_id, organization_id, $2, status, $3, $4
+		FROM projects WHERE id = $1
+	`, projectID, eventType, message, data)
+	if err != nil {
+		return fmt.Errorf("failed to record project event: %w", err)
+	}
+	return nil
+}
+
+// ListProjectEventsSince returns events across all users with IDs greater than afterID, oldest first
+func (c *Client) ListProjectEventsSince(ctx context.Context, afterID int64, limit int) ([]ProjectEvent, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+projectEventColumns+`
+		FROM project_events
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project events: %w", err)
+	}
+	return scanProjectEvents(rows)
+}
+
+// ListUserProjectEventsSince returns the events a user receives, from their
+// personal projects and their organizations', with IDs greater than afterID, oldest first
+func (c *Client) ListUserProjectEventsSince(ctx context.Context, userID string, afterID int64, limit int) ([]ProjectEvent, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+projectEventColumns+`
+		FROM project_events
+		WHERE `+projectAccess("$1")+` AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user project events: %w", err)
+	}
+	return scanProjectEvents(rows)
+}
+
+// CountProjectEventsSince counts a project's events of one type created after since
+func (c *Client) CountProjectEventsSince(ctx context.Context, projectID, eventType string, since time.Time) (int, error) {
+	var count int
+	err := c.pool.QueryRow(ctx, `
+		SELECT count(*) FROM project_events
+		WHERE project_id = $1 AND type = $2 AND created_at > $3
+	`, projectID, eventType, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count project events: %w", err)
+	}
+	return count, nil
+}
+
+// GetLatestProjectEventID returns the highest event ID, or 0 if there are no events
+func (c *Client) GetLatestProjectEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := c.pool.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM project_events`).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest project event: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteProjectEventsBefore prunes events older than the given time
+func (c *Client) DeleteProjectEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := c.pool.Exec(ctx, `DELETE FROM project_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune project events: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// IdempotencyKey records a mutating request made with an Idempotency-Key header,
+// and its response once it has finished
+type IdempotencyKey struct {
+	UserID              string
+	Key                 string
+	RequestHash         string
+	ResponseStatus      *int
+	ResponseContentType *string
+	ResponseETag        *string
+	ResponseBody        []byte
+	LockedAt            time.Time
+	CreatedAt           time.Time
+	ExpiresAt           time.Time
+}
+
+// Completed reports whether the key's first request has finished
+func (k *IdempotencyKey) Completed() bool {
+	return k.ResponseStatus != nil
+}
+
+const idempotencyKeyColumns = `user_id, key, request_hash, response_status, response_content_type,
+		       response_etag, response_body, locked_at, created_at, expires_at`
+
+func scanIdempotencyKey(row pgx.Row) (*IdempotencyKey, error) {
+	var k IdempotencyKey
+	err := row.Scan(&k.UserID, &k.Key, &k.RequestHash, &k.ResponseStatus, &k.ResponseContentType,
+		&k.ResponseETag, &k.ResponseBody, &k.LockedAt, &k.CreatedAt, &k.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// ClaimIdempotencyKey records that a request with this key is running. It returns
+// the new record and true, or the existing record and false if the key is already
+// taken. Expired keys, and claims still unfinished since before staleBefore, are
+// taken over.
+func (c *Client) ClaimIdempotencyKey(ctx context.Context, userID, key, requestHash string, expiresAt, staleBefore time.Time) (*IdempotencyKey, bool, error) {
+	k, err := scanIdempotencyKey(c.pool.QueryRow(ctx, `
+		INSERT INTO idempotency_keys (user_id, key, request_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+		    response_status = NULL,
+		    response_content_type = NULL,
+		    response_etag = NULL,
+		    response_body = NULL,
+		    locked_at = now(),
+		    created_at = now(),
+		    expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < now()
+		   OR (idempotency_keys.response_status IS NULL AND idempotency_keys.locked_at < $5)
+		RETURNING `+idempotencyKeyColumns,
+		userID, key, requestHash, expiresAt, staleBefore))
+	if err == nil {
+		return k, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	// Taken and still live
+	k, err = scanIdempotencyKey(c.pool.QueryRow(ctx, `
+		SELECT `+idempotencyKeyColumns+`
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`, userID, key))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Deleted between the two queries; the caller can retry
+			return nil, false, ErrNotFound
+		}
+		return nil, false, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	return k, false, nil
+}
+
+// CompleteIdempotencyKey stores the response to a claimed key's request
+func (c *Client) CompleteIdempotencyKey(ctx context.Context, userID, key string, status int, contentType, etag string, body []byte) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE idempotency_keys
+		SET response_status = $3, response_content_type = $4, response_etag = NULLIF($5, ''), response_body = $6
+		WHERE user_id = $1 AND key = $2
+	`, userID, key, status, contentType, etag, body)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey deletes an unfinished claim so the request can be retried
+func (c *Client) ReleaseIdempotencyKey(ctx context.Context, userID, key string) error {
+	_, err := c.pool.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND response_status IS NULL
+	`, userID, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys removes keys past their expiry and returns how many
+func (c *Client) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	result, err := c.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < now()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
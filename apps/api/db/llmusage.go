@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LLMUsage is the tokens one agent turn used, as the agent reported them
+type LLMUsage struct {
+	ID           string  `json:"id"`
+	UserID       string  `json:"user_id"`
+	ProjectID    *string `json:"project_id"`
+	ProjectName  string  `json:"project_name"`
+	Agent        string  `json:"agent"`
+	Model        string  `json:"model"`
+	SessionID    *string `json:"session_id,omitempty"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+	// CostEstimated is set when the agent reported no cost and it was worked
+	// out from the tokens
+	CostEstimated bool      `json:"cost_estimated"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// What LLM usage can be summarized by
+const (
+	LLMUsageByProject = "project"
+	LLMUsageByAgent   = "agent"
+	LLMUsageByModel   = "model"
+	LLMUsageBySession = "session"
+	LLMUsageByDay     = "day"
+	LLMUsageByMonth   = "month"
+)
+
+// llmUsageGroups are the key and name expressions for each grouping. Names are
+// the project's name for projects and sessions.
+var llmUsageGroups = map[string]struct{ key, name string }{
+	LLMUsageByProject: {`COALESCE(project_id::text, '')`, `MAX(project_name)`},
+	LLMUsageByAgent:   {`provider`, `NULL::text`},
+	LLMUsageByModel:   {`model`, `NULL::text`},
+	LLMUsageBySession: {`COALESCE(session_id, '')`, `MAX(project_name)`},
+	LLMUsageByDay:     {`to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')`, `NULL::text`},
+	LLMUsageByMonth:   {`to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM')`, `NULL::text`},
+}
+
+// ValidLLMUsageGroup reports whether usage can be summarized by group
+func ValidLLMUsageGroup(group string) bool {
+	_, ok := llmUsageGroups[group]
+	return ok
+}
+
+// LLMUsageQuery selects a user's usage within [From, To), or one project's usage
+// by all its members. Callers must check the user can see the project.
+type LLMUsageQuery struct {
+	UserID    string
+	ProjectID *string
+	From      time.Time
+	To        time.Time
+}
+
+// LLMUsageSummary is the usage for one group. Key is empty for usage whose
+// project was deleted, or that came without a session.
+type LLMUsageSummary struct {
+	Key          string  `json:"key"`
+	Name         *string `json:"name,omitempty"`
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// RecordLLMUsage stores one report of agent usage
+func (c *Client) RecordLLMUsage(ctx context.Context, usage *LLMUsage) error {
+	metadata := map[string]any{}
+	if usage.CostEstimated {
+		metadata["cost_estimated"] = true
+	}
+	err := c.pool.QueryRow(ctx, `
+		INSERT INTO llm_usage (user_id, project_id, project_name, provider, model, session_id,
+		                       input_tokens, output_tokens, cost_usd, completed_at, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now(), $10)
+		RETURNING id, created_at
+	`, usage.UserID, usage.ProjectID, usage.ProjectName, usage.Agent, usage.Model, usage.SessionID,
+		usage.InputTokens, usage.OutputTokens, usage.CostUSD, metadata).Scan(&usage.ID, &usage.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record llm usage: %w", err)
+	}
+	return nil
+}
+
+// SummarizeLLMUsage totals completed usage by the given group. Periods come
+// back in order and everything else most expensive first.
+func (c *Client) SummarizeLLMUsage(ctx context.Context, q LLMUsageQuery, group string) ([]LLMUsageSummary, error) {
+	expr, ok := llmUsageGroups[group]
+	if !ok {
+		return nil, fmt.Errorf("unknown llm usage group %q", group)
+	}
+	order := `cost_usd DESC, key`
+	if group == LLMUsageByDay || group == LLMUsageByMonth {
+		order = `key`
+	}
+
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+expr.key+` AS key, `+expr.name+` AS name,
+		       COUNT(*), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		       COALESCE(SUM(cost_usd), 0)::float8 AS cost_usd
+		FROM llm_usage
+		WHERE CASE WHEN $2::uuid IS NULL THEN user_id = $1 ELSE project_id = $2 END
+		  AND created_at >= $3 AND created_at < $4
+		  AND status = 'completed'
+		GROUP BY 1
+		ORDER BY `+order, q.UserID, q.ProjectID, q.From, q.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize llm usage: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []LLMUsageSummary
+	for rows.Next() {
+		var s LLMUsageSummary
+		if err := rows.Scan(&s.Key, &s.Name, &s.Requests, &s.InputTokens, &s.OutputTokens, &s.CostUSD); err != nil {
+			return nil, fmt.Errorf("failed to scan llm usage summary: %w", err)
+		}
+		summaries = append(summaries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to summarize llm usage: %w", err)
+	}
+	return summaries, nil
+}
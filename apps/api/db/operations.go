@@ -0,0 +1,277 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Operation types
+const (
+	OperationStart     = "start"
+	OperationStop      = "stop"
+	OperationRestart   = "restart"
+	OperationUpgrade   = "upgrade"
+	OperationHibernate = "hibernate"
+	OperationMove      = "move"
+	OperationRestore   = "restore"
+)
+
+// Operation statuses
+const (
+	OperationPending   = "pending"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+var (
+	// ErrOperationInProgress is returned when a project already has an unfinished operation
+	ErrOperationInProgress = errors.New("operation already in progress")
+	// ErrOperationNotClaimed is returned when a worker updates an operation it no longer owns
+	ErrOperationNotClaimed = errors.New("operation is not claimed by this worker")
+)
+
+// Operation is a durable record of a project lifecycle action
+type Operation struct {
+	ID        string  `json:"id"`
+	ProjectID string  `json:"project_id"`
+	UserID    string  `json:"user_id"`
+	Type      string  `json:"type"`
+	Status    string  `json:"status"`
+	Step      string  `json:"step"`
+	Attempts  int     `json:"attempts"`
+	LastError *string `json:"last_error,omitempty"`
+	// Params carries what the operation needs beyond the project row, and what
+	// it has created so far
+	Params      map[string]string `json:"params,omitempty"`
+	ClaimedBy   *string           `json:"claimed_by,omitempty"`
+	HeartbeatAt *time.Time        `json:"heartbeat_at,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+}
+
+const operationColumns = `id, project_id, user_id, type, status, step, attempts, last_error, params,
+		       claimed_by, heartbeat_at, created_at, updated_at, completed_at`
+
+func scanOperation(row pgx.Row) (*Operation, error) {
+	var op Operation
+	err := row.Scan(
+		&op.ID, &op.ProjectID, &op.UserID, &op.Type, &op.Status, &op.Step, &op.Attempts, &op.LastError, &op.Params,
+		&op.ClaimedBy, &op.HeartbeatAt, &op.CreatedAt, &op.UpdatedAt, &op.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// ============================================
+// Operation Methods
+// ============================================
+
+// BeginOperation moves a project from one of the given statuses to `to` and records
+// a pending operation for it, in one transaction. Returns a *TransitionError if the
+// project is not in an expected status, or ErrOperationInProgress if it already
+// has an unfinished operation.
+func (c *Client) BeginOperation(ctx context.Context, projectID, userID, opType string, from []string, to string) (*Operation, error) {
+	return c.BeginOperationWithParams(ctx, projectID, userID, opType, nil, from, to)
+}
+
+// BeginOperationWithParams is BeginOperation for operations that need params
+func (c *Client) BeginOperationWithParams(ctx context.Context, projectID, userID, opType string, params map[string]string, from []string, to string) (*Operation, error) {
+	if params == nil {
+		params = map[string]string{}
+	}
+	if err := checkTransitions(from, to); err != nil {
+		return nil, err
+	}
+
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := transitionProjectStatus(ctx, tx, projectID, from, to, nil); err != nil {
+		return nil, err
+	}
+
+	op, err := scanOperation(tx.QueryRow(ctx, `
+		INSERT INTO project_operations (project_id, user_id, type, params)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+operationColumns,
+		projectID, userID, opType, params))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrOperationInProgress
+		}
+		return nil, fmt.Errorf("failed to create operation: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit operation: %w", err)
+	}
+	return op, nil
+}
+
+// GetOperation returns an operation belonging to the given project
+func (c *Client) GetOperation(ctx context.Context, operationID, projectID string) (*Operation, error) {
+	op, err := scanOperation(c.pool.QueryRow(ctx, `
+		SELECT `+operationColumns+`
+		FROM project_operations
+		WHERE id = $1 AND project_id = $2
+	`, operationID, projectID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get operation: %w", err)
+	}
+	return op, nil
+}
+
+// ClaimOperation marks a pending operation as running on the given worker.
+// Returns ErrNotFound if the operation was already claimed elsewhere.
+func (c *Client) ClaimOperation(ctx context.Context, operationID, workerID string) (*Operation, error) {
+	op, err := scanOperation(c.pool.QueryRow(ctx, `
+		UPDATE project_operations
+		SET status = 'running', claimed_by = $2, heartbeat_at = now(), attempts = attempts + 1
+		WHERE id = $1 AND status = 'pending'
+		RETURNING `+operationColumns,
+		operationID, workerID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to claim operation: %w", err)
+	}
+	return op, nil
+}
+
+// ClaimNextOperation claims the oldest operation that is either pending or
+// running with a heartbeat older than staleAfter (its worker is presumed dead).
+// Returns ErrNotFound when there is nothing to resume.
+func (c *Client) ClaimNextOperation(ctx context.Context, workerID string, staleAfter time.Duration) (*Operation, error) {
+	op, err := scanOperation(c.pool.QueryRow(ctx, `
+		UPDATE project_operations
+		SET status = 'running', claimed_by = $1, heartbeat_at = now(), attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM project_operations
+			WHERE status = 'pending'
+			   OR (status = 'running' AND heartbeat_at < now() - $2 * interval '1 second')
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+operationColumns,
+		workerID, staleAfter.Seconds()))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to claim next operation: %w", err)
+	}
+	return op, nil
+}
+
+// UpdateOperationStep records progress and refreshes the heartbeat.
+// Returns ErrOperationNotClaimed if another worker has taken over the operation.
+func (c *Client) UpdateOperationStep(ctx context.Context, operationID, workerID, step string) error {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE project_operations
+		SET step = $3, heartbeat_at = now()
+		WHERE id = $1 AND claimed_by = $2 AND status = 'running'
+	`, operationID, workerID, step)
+	if err != nil {
+		return fmt.Errorf("failed to update operation step: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrOperationNotClaimed
+	}
+	return nil
+}
+
+// SetOperationParams merges params into an operation's, so a resumed attempt
+// finds what earlier ones created. Returns ErrOperationNotClaimed if another
+// worker has taken over the operation.
+func (c *Client) SetOperationParams(ctx context.Context, operationID, workerID string, params map[string]string) error {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE project_operations
+		SET params = params || $3, heartbeat_at = now()
+		WHERE id = $1 AND claimed_by = $2 AND status = 'running'
+	`, operationID, workerID, params)
+	if err != nil {
+		return fmt.Errorf("failed to set operation params: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrOperationNotClaimed
+	}
+	return nil
+}
+
+// CompleteOperation marks an operation as succeeded
+func (c *Client) CompleteOperation(ctx context.Context, operationID, workerID string) error {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE project_operations
+		SET status = 'succeeded', step = 'done', last_error = NULL, completed_at = now()
+		WHERE id = $1 AND claimed_by = $2 AND status = 'running'
+	`, operationID, workerID)
+	if err != nil {
+		return fmt.Errorf("failed to complete operation: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrOperationNotClaimed
+	}
+	return nil
+}
+
+// FailOperation marks an operation as failed with the given error
+func (c *Client) FailOperation(ctx context.Context, operationID, workerID, errorMsg string) error {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE project_operations
+		SET status = 'failed', last_error = $3, completed_at = now()
+		WHERE id = $1 AND claimed_by = $2 AND status = 'running'
+	`, operationID, workerID, errorMsg)
+	if err != nil {
+		return fmt.Errorf("failed to fail operation: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrOperationNotClaimed
+	}
+	return nil
+}
+
+// GetProjectIDsWithActiveOperations returns the set of projects that have a
+// pending or running operation
+func (c *Client) GetProjectIDsWithActiveOperations(ctx context.Context) (map[string]bool, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT project_id FROM project_operations
+		WHERE status IN ('pending', 'running')
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active operations: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan operation project id: %w", err)
+		}
+		ids[id] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating active operations: %w", err)
+	}
+
+	return ids, nil
+}
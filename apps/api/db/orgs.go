@@ -0,0 +1,663 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Organization roles, from most to least access
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleViewer = "viewer"
+)
+
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleMember: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// ValidRole reports whether role is an organization role
+func ValidRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+// RoleAtLeast reports whether role grants everything min does
+func RoleAtLeast(role, min string) bool {
+	return ValidRole(role) && roleRanks[role] >= roleRanks[min]
+}
+
+var (
+	// ErrMemberExists is returned when inviting someone already in the organization
+	ErrMemberExists = errors.New("already a member")
+	// ErrInvitationExists is returned when the address already has an open invitation
+	ErrInvitationExists = errors.New("invitation already exists")
+	// ErrLastOwner is returned when a change would leave an organization without an owner
+	ErrLastOwner = errors.New("organization needs an owner")
+	// ErrOrganizationHasProjects is returned when deleting an organization that still has projects
+	ErrOrganizationHasProjects = errors.New("organization has projects")
+)
+
+// Organization is a group of users that share projects
+type Organization struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	CreatedBy *string `json:"created_by,omitempty"`
+	// DefaultHardware and DefaultIdleTimeoutMinutes, when set, override each
+	// member's own settings for projects in the organization
+	DefaultHardware           *HardwareConfig `json:"-"`
+	DefaultIdleTimeoutMinutes *int            `json:"default_idle_timeout_minutes,omitempty"`
+	// Role is the reading user's role, for organizations read on their behalf
+	Role      string    `json:"role,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ApplyDefaults returns a copy of a member's settings with the organization's
+// defaults laid over them
+func (o *Organization) ApplyDefaults(s *UserSettings) *UserSettings {
+	merged := *s
+	if hw := o.DefaultHardware; hw != nil {
+		merged.DefaultCPUKind = hw.CPUKind
+		merged.DefaultCPUs = hw.CPUs
+		merged.DefaultMemoryMB = hw.MemoryMB
+		merged.DefaultVolumeSizeGB = hw.VolumeSizeGB
+		merged.DefaultGPUKind = hw.GPUKind
+	}
+	if o.DefaultIdleTimeoutMinutes != nil {
+		merged.DefaultIdleTimeoutMinutes = o.DefaultIdleTimeoutMinutes
+	}
+	return &merged
+}
+
+// OrganizationMember is a user's membership of an organization
+type OrganizationMember struct {
+	OrganizationID string    `json:"-"`
+	UserID         string    `json:"user_id"`
+	Email          string    `json:"email"`
+	DisplayName    *string   `json:"display_name,omitempty"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"joined_at"`
+}
+
+// OrganizationInvitation invites an email address to join an organization
+type OrganizationInvitation struct {
+	ID               string    `json:"id"`
+	OrganizationID   string    `json:"organization_id"`
+	OrganizationName string    `json:"organization_name"`
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	InvitedBy        *string   `json:"invited_by,omitempty"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// projectAccess is the condition for projects the user whose ID is in the
+// given placeholder can reach: their personal projects, and every project in
+// an organization they belong to. Role checks are left to the caller.
+func projectAccess(userParam string) string {
+	return `(CASE WHEN organization_id IS NULL THEN user_id = ` + userParam + `
+		ELSE organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = ` + userParam + `) END)`
+}
+
+// projectRole is the column for the user's role on the project: owner of
+// their personal projects, their organization role otherwise, NULL without access
+func projectRole(userParam string) string {
+	return `(CASE WHEN organization_id IS NULL THEN CASE WHEN user_id = ` + userParam + ` THEN '` + RoleOwner + `' END
+		ELSE (SELECT role FROM organization_members m WHERE m.organization_id = projects.organization_id AND m.user_id = ` + userParam + `) END)`
+}
+
+// ============================================
+// Organization Methods
+// ============================================
+
+const organizationColumns = `o.id, o.name, o.created_by,
+		       o.default_cpu_kind, o.default_cpus, o.default_memory_mb, o.default_volume_size_gb, o.default_gpu_kind,
+		       o.default_idle_timeout_minutes, o.created_at, o.updated_at`
+
+func scanOrganization(row pgx.Row, extra ...any) (*Organization, error) {
+	var o Organization
+	var cpuKind, gpuKind *string
+	var cpus, memoryMB, volumeSizeGB *int
+	dest := []any{&o.ID, &o.Name, &o.CreatedBy,
+		&cpuKind, &cpus, &memoryMB, &volumeSizeGB, &gpuKind,
+		&o.DefaultIdleTimeoutMinutes, &o.CreatedAt, &o.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if cpuKind != nil && cpus != nil && memoryMB != nil && volumeSizeGB != nil {
+		o.DefaultHardware = &HardwareConfig{
+			CPUKind:      *cpuKind,
+			CPUs:         *cpus,
+			MemoryMB:     *memoryMB,
+			VolumeSizeGB: *volumeSizeGB,
+			GPUKind:      gpuKind,
+		}
+	}
+	return &o, nil
+}
+
+// scanMemberOrganization scans an organization followed by the reader's role
+func scanMemberOrganization(row pgx.Row) (*Organization, error) {
+	var role string
+	o, err := scanOrganization(row, &role)
+	if err != nil {
+		return nil, err
+	}
+	o.Role = role
+	return o, nil
+}
+
+// CreateOrganization creates an organization with the given user as its owner
+func (c *Client) CreateOrganization(ctx context.Context, name, ownerID string) (*Organization, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	o, err := scanOrganization(tx.QueryRow(ctx, `
+		INSERT INTO organizations AS o (name, created_by)
+		VALUES ($1, $2)
+		RETURNING `+organizationColumns,
+		name, ownerID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)
+	`, o.ID, ownerID, RoleOwner); err != nil {
+		return nil, fmt.Errorf("failed to add organization owner: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit organization: %w", err)
+	}
+	o.Role = RoleOwner
+	return o, nil
+}
+
+// ListOrganizations returns the organizations a user belongs to, with their role in each
+func (c *Client) ListOrganizations(ctx context.Context, userID string) ([]Organization, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+organizationColumns+`, m.role
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY o.name, o.id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	var orgs []Organization
+	for rows.Next() {
+		o, err := scanMemberOrganization(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, *o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organizations: %w", err)
+	}
+
+	return orgs, nil
+}
+
+// ListOrganizationIDs returns the IDs of the organizations a user belongs to
+func (c *Client) ListOrganizationIDs(ctx context.Context, userID string) ([]string, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT organization_id FROM organization_members WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization IDs: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan organization IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// GetOrganization returns an organization with the user's role in it. It
+// returns ErrNotFound if the user isn't a member.
+func (c *Client) GetOrganization(ctx context.Context, orgID, userID string) (*Organization, error) {
+	o, err := scanMemberOrganization(c.pool.QueryRow(ctx, `
+		SELECT `+organizationColumns+`, m.role
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE o.id = $1 AND m.user_id = $2
+	`, orgID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return o, nil
+}
+
+// UpdateOrganization replaces an organization's name and default settings
+func (c *Client) UpdateOrganization(ctx context.Context, org *Organization) (*Organization, error) {
+	var cpuKind, gpuKind *string
+	var cpus, memoryMB, volumeSizeGB *int
+	if hw := org.DefaultHardware; hw != nil {
+		cpuKind, cpus, memoryMB, volumeSizeGB, gpuKind = &hw.CPUKind, &hw.CPUs, &hw.MemoryMB, &hw.VolumeSizeGB, hw.GPUKind
+	}
+	o, err := scanOrganization(c.pool.QueryRow(ctx, `
+		UPDATE organizations AS o
+		SET name = $2, default_cpu_kind = $3, default_cpus = $4, default_memory_mb = $5,
+		    default_volume_size_gb = $6, default_gpu_kind = $7, default_idle_timeout_minutes = $8
+		WHERE id = $1
+		RETURNING `+organizationColumns,
+		org.ID, org.Name, cpuKind, cpus, memoryMB, volumeSizeGB, gpuKind, org.DefaultIdleTimeoutMinutes))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to update organization: %w", err)
+	}
+	o.Role = org.Role
+	return o, nil
+}
+
+// DeleteOrganization deletes an organization with its members and invitations.
+// It returns ErrOrganizationHasProjects while any project, trashed or not, is in it.
+func (c *Client) DeleteOrganization(ctx context.Context, orgID string) error {
+	result, err := c.pool.Exec(ctx, `DELETE FROM organizations WHERE id = $1`, orgID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrOrganizationHasProjects
+		}
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ============================================
+// Organization Member Methods
+// ============================================
+
+const organizationMemberColumns = `m.organization_id, m.user_id, p.email, p.display_name, m.role, m.created_at`
+
+func scanOrganizationMember(row pgx.Row) (*OrganizationMember, error) {
+	var m OrganizationMember
+	if err := row.Scan(&m.OrganizationID, &m.UserID, &m.Email, &m.DisplayName, &m.Role, &m.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ListOrganizationMembers returns an organization's members, owners first
+func (c *Client) ListOrganizationMembers(ctx context.Context, orgID string) ([]OrganizationMember, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+organizationMemberColumns+`
+		FROM organization_members m
+		JOIN profiles p ON p.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY array_position(ARRAY['owner', 'admin', 'member', 'viewer'], m.role), m.created_at
+	`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []OrganizationMember
+	for rows.Next() {
+		m, err := scanOrganizationMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization member: %w", err)
+		}
+		members = append(members, *m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization members: %w", err)
+	}
+
+	return members, nil
+}
+
+func (c *Client) GetOrganizationMember(ctx context.Context, orgID, userID string) (*OrganizationMember, error) {
+	m, err := scanOrganizationMember(c.pool.QueryRow(ctx, `
+		SELECT `+organizationMemberColumns+`
+		FROM organization_members m
+		JOIN profiles p ON p.id = m.user_id
+		WHERE m.organization_id = $1 AND m.user_id = $2
+	`, orgID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization member: %w", err)
+	}
+	return m, nil
+}
+
+// lockLastOwner locks the organization's owners and returns ErrLastOwner if the
+// given member is the only one. Changes to owners serialize on the lock.
+func lockLastOwner(ctx context.Context, tx pgx.Tx, orgID, userID string) error {
+	rows, err := tx.Query(ctx, `
+		SELECT user_id FROM organization_members
+		WHERE organization_id = $1 AND role = $2
+		FOR UPDATE
+	`, orgID, RoleOwner)
+	if err != nil {
+		return fmt.Errorf("failed to lock organization owners: %w", err)
+	}
+	owners, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to scan organization owners: %w", err)
+	}
+	if len(owners) == 1 && owners[0] == userID {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// SetOrganizationMemberRole changes a member's role. It returns ErrLastOwner
+// if that would leave the organization without an owner.
+func (c *Client) SetOrganizationMemberRole(ctx context.Context, orgID, userID, role string) (*OrganizationMember, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if role != RoleOwner {
+		if err := lockLastOwner(ctx, tx, orgID, userID); err != nil {
+			return nil, err
+		}
+	}
+	m, err := scanOrganizationMember(tx.QueryRow(ctx, `
+		UPDATE organization_members m
+		SET role = $3
+		FROM profiles p
+		WHERE p.id = m.user_id AND m.organization_id = $1 AND m.user_id = $2
+		RETURNING `+organizationMemberColumns,
+		orgID, userID, role))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to set organization member role: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit member role: %w", err)
+	}
+	return m, nil
+}
+
+// RemoveOrganizationMember takes a user out of an organization. It returns
+// ErrLastOwner if they are its only owner.
+func (c *Client) RemoveOrganizationMember(ctx context.Context, orgID, userID string) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := lockLastOwner(ctx, tx, orgID, userID); err != nil {
+		return err
+	}
+	result, err := tx.Exec(ctx, `
+		DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2
+	`, orgID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove organization member: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit member removal: %w", err)
+	}
+	return nil
+}
+
+// ============================================
+// Organization Invitation Methods
+// ============================================
+
+const organizationInvitationColumns = `i.id, i.organization_id, o.name, i.email, i.role, i.invited_by, i.expires_at, i.created_at`
+
+func scanOrganizationInvitation(row pgx.Row) (*OrganizationInvitation, error) {
+	var inv OrganizationInvitation
+	err := row.Scan(&inv.ID, &inv.OrganizationID, &inv.OrganizationName, &inv.Email, &inv.Role,
+		&inv.InvitedBy, &inv.ExpiresAt, &inv.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func scanOrganizationInvitations(rows pgx.Rows) ([]OrganizationInvitation, error) {
+	defer rows.Close()
+
+	var invitations []OrganizationInvitation
+	for rows.Next() {
+		inv, err := scanOrganizationInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization invitation: %w", err)
+		}
+		invitations = append(invitations, *inv)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization invitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+// CreateOrganizationInvitation invites an email address to an organization,
+// replacing any expired invitation for it. It returns ErrMemberExists if a
+// member already has the address, and ErrInvitationExists if it has an open invitation.
+func (c *Client) CreateOrganizationInvitation(ctx context.Context, invitation *OrganizationInvitation) (*OrganizationInvitation, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var member bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM organization_members m
+			JOIN profiles p ON p.id = m.user_id
+			WHERE m.organization_id = $1 AND lower(p.email) = lower($2)
+		)
+	`, invitation.OrganizationID, invitation.Email).Scan(&member)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check organization members: %w", err)
+	}
+	if member {
+		return nil, ErrMemberExists
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM organization_invitations
+		WHERE organization_id = $1 AND lower(email) = lower($2) AND expires_at <= now()
+	`, invitation.OrganizationID, invitation.Email); err != nil {
+		return nil, fmt.Errorf("failed to clear expired invitation: %w", err)
+	}
+
+	inv, err := scanOrganizationInvitation(tx.QueryRow(ctx, `
+		WITH i AS (
+			INSERT INTO organization_invitations (organization_id, email, role, invited_by, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING *
+		)
+		SELECT `+organizationInvitationColumns+`
+		FROM i JOIN organizations o ON o.id = i.organization_id
+	`, invitation.OrganizationID, invitation.Email, invitation.Role, invitation.InvitedBy, invitation.ExpiresAt))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return nil, ErrInvitationExists
+			case "23503":
+				return nil, ErrNotFound
+			}
+		}
+		return nil, fmt.Errorf("failed to create organization invitation: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit invitation: %w", err)
+	}
+	return inv, nil
+}
+
+// ListOrganizationInvitations returns an organization's open invitations, newest first
+func (c *Client) ListOrganizationInvitations(ctx context.Context, orgID string) ([]OrganizationInvitation, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+organizationInvitationColumns+`
+		FROM organization_invitations i
+		JOIN organizations o ON o.id = i.organization_id
+		WHERE i.organization_id = $1 AND i.expires_at > now()
+		ORDER BY i.created_at DESC
+	`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization invitations: %w", err)
+	}
+	return scanOrganizationInvitations(rows)
+}
+
+func (c *Client) DeleteOrganizationInvitation(ctx context.Context, invitationID, orgID string) error {
+	result, err := c.pool.Exec(ctx, `
+		DELETE FROM organization_invitations WHERE id = $1 AND organization_id = $2
+	`, invitationID, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete organization invitation: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// userInvitation is the condition for open invitations to the email address
+// of the user whose ID is in the given placeholder
+func userInvitation(userParam string) string {
+	return `lower(i.email) = (SELECT lower(email) FROM profiles WHERE id = ` + userParam + `) AND i.expires_at > now()`
+}
+
+// ListUserInvitations returns the open invitations to a user's email address
+func (c *Client) ListUserInvitations(ctx context.Context, userID string) ([]OrganizationInvitation, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+organizationInvitationColumns+`
+		FROM organization_invitations i
+		JOIN organizations o ON o.id = i.organization_id
+		WHERE `+userInvitation("$1")+`
+		ORDER BY i.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user invitations: %w", err)
+	}
+	return scanOrganizationInvitations(rows)
+}
+
+// AcceptOrganizationInvitation adds the user to the organization with the
+// invited role and uses up the invitation. Someone already in the
+// organization keeps their role. It returns ErrNotFound unless the invitation
+// is open and to the user's address.
+func (c *Client) AcceptOrganizationInvitation(ctx context.Context, invitationID, userID string) (*Organization, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var orgID, role string
+	err = tx.QueryRow(ctx, `
+		DELETE FROM organization_invitations i
+		WHERE i.id = $1 AND `+userInvitation("$2")+`
+		RETURNING i.organization_id, i.role
+	`, invitationID, userID).Scan(&orgID, &role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to accept organization invitation: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id, user_id) DO NOTHING
+	`, orgID, userID, role); err != nil {
+		return nil, fmt.Errorf("failed to add organization member: %w", err)
+	}
+	o, err := scanMemberOrganization(tx.QueryRow(ctx, `
+		SELECT `+organizationColumns+`, m.role
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE o.id = $1 AND m.user_id = $2
+	`, orgID, userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit invitation: %w", err)
+	}
+	return o, nil
+}
+
+// DeclineOrganizationInvitation deletes an open invitation to the user's address
+func (c *Client) DeclineOrganizationInvitation(ctx context.Context, invitationID, userID string) error {
+	result, err := c.pool.Exec(ctx, `
+		DELETE FROM organization_invitations i
+		WHERE i.id = $1 AND `+userInvitation("$2")+`
+	`, invitationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to decline organization invitation: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetProjectOrganization moves a project into an organization, or out of one
+// when orgID is nil. The creator keeps the project's environment variables
+// readable, so a project leaving an organization goes back to them. The
+// project is returned with the given user's role on it.
+func (c *Client) SetProjectOrganization(ctx context.Context, projectID string, orgID *string, userID string) (*Project, error) {
+	p, err := scanUserProject(c.pool.QueryRow(ctx, `
+		UPDATE projects
+		SET organization_id = $2
+		WHERE id = $1
+		RETURNING `+projectColumns+`, `+projectRole("$3"),
+		projectID, orgID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to set project organization: %w", err)
+	}
+	return p, nil
+}
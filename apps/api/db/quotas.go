@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Plan caps what its users and organizations can create and run. A nil limit
+// is unlimited.
+type Plan struct {
+	ID                 string    `json:"id"`
+	Name               string    `json:"name"`
+	MaxProjects        *int      `json:"max_projects"`
+	MaxVolumeGB        *int      `json:"max_volume_gb"`
+	AllowGPU           bool      `json:"allow_gpu"`
+	MaxRunningMachines *int      `json:"max_running_machines"`
+	MaxVCPUs           *int      `json:"max_vcpus"`
+	MaxMemoryMB        *int      `json:"max_memory_mb"`
+	IsDefault          bool      `json:"is_default"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// QuotaUsage is what a user's or organization's projects hold right now. Running CPUs and memory
+// only cover CPU machines; GPU machines run at a fixed size the caller knows.
+type QuotaUsage struct {
+	Projects           int
+	VolumeGB           int
+	RunningMachines    int
+	RunningGPUMachines int
+	RunningCPUs        int
+	RunningMemoryMB    int
+}
+
+// MachineStatuses are the statuses in which a project holds a machine's compute
+var MachineStatuses = []string{StatusStarting, StatusRunning, StatusStopping}
+
+const planColumns = `id, name, max_projects, max_volume_gb, allow_gpu, max_running_machines,
+	max_vcpus, max_memory_mb, is_default, created_at, updated_at`
+
+func scanPlan(row pgx.Row) (*Plan, error) {
+	var p Plan
+	err := row.Scan(&p.ID, &p.Name, &p.MaxProjects, &p.MaxVolumeGB, &p.AllowGPU, &p.MaxRunningMachines,
+		&p.MaxVCPUs, &p.MaxMemoryMB, &p.IsDefault, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPlans returns every plan, default first
+func (c *Client) ListPlans(ctx context.Context) ([]Plan, error) {
+	rows, err := c.pool.Query(ctx, `SELECT `+planColumns+` FROM plans ORDER BY is_default DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plans: %w", err)
+	}
+	defer rows.Close()
+
+	var plans []Plan
+	for rows.Next() {
+		p, err := scanPlan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan plan: %w", err)
+		}
+		plans = append(plans, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list plans: %w", err)
+	}
+	return plans, nil
+}
+
+// GetUserPlan returns the plan assigned to a user, or the default plan if none
+// is. It returns ErrNotFound when the user has neither.
+func (c *Client) GetUserPlan(ctx context.Context, userID string) (*Plan, error) {
+	p, err := scanPlan(c.pool.QueryRow(ctx, `
+		SELECT `+planColumns+`
+		FROM plans
+		WHERE id = (SELECT plan_id FROM user_plans WHERE user_id = $1)
+		   OR (is_default AND NOT EXISTS (SELECT 1 FROM user_plans WHERE user_id = $1))
+		LIMIT 1
+	`, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user plan: %w", err)
+	}
+	return p, nil
+}
+
+// SetUserPlan assigns a plan to a user. It returns ErrNotFound if the user or
+// the plan doesn't exist.
+func (c *Client) SetUserPlan(ctx context.Context, userID, planID string) error {
+	result, err := c.pool.Exec(ctx, `
+		INSERT INTO user_plans (user_id, plan_id)
+		SELECT $1, id FROM plans WHERE id = $2
+		ON CONFLICT (user_id) DO UPDATE SET plan_id = EXCLUDED.plan_id
+	`, userID, planID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to set user plan: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetOrganizationPlan returns the plan assigned to an organization, or the
+// default plan if none is. It returns ErrNotFound when the organization has neither.
+func (c *Client) GetOrganizationPlan(ctx context.Context, orgID string) (*Plan, error) {
+	p, err := scanPlan(c.pool.QueryRow(ctx, `
+		SELECT `+planColumns+`
+		FROM plans
+		WHERE id = (SELECT plan_id FROM organization_plans WHERE organization_id = $1)
+		   OR (is_default AND NOT EXISTS (SELECT 1 FROM organization_plans WHERE organization_id = $1))
+		LIMIT 1
+	`, orgID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization plan: %w", err)
+	}
+	return p, nil
+}
+
+// SetOrganizationPlan assigns a plan to an organization. It returns ErrNotFound
+// if the organization or the plan doesn't exist.
+func (c *Client) SetOrganizationPlan(ctx context.Context, orgID, planID string) error {
+	result, err := c.pool.Exec(ctx, `
+		INSERT INTO organization_plans (organization_id, plan_id)
+		SELECT $1, id FROM plans WHERE id = $2
+		ON CONFLICT (organization_id) DO UPDATE SET plan_id = EXCLUDED.plan_id
+	`, orgID, planID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to set organization plan: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetQuotaUsage totals a user's personal projects against the limits plans set.
+// Organization projects count against their organization instead. Trashed
+// projects count until they are purged; hibernated projects keep no volume.
+func (c *Client) GetQuotaUsage(ctx context.Context, userID string) (*QuotaUsage, error) {
+	return c.quotaUsage(ctx, `user_id = $1 AND organization_id IS NULL`, userID)
+}
+
+// GetOrganizationQuotaUsage totals an organization's projects, whoever created them
+func (c *Client) GetOrganizationQuotaUsage(ctx context.Context, orgID string) (*QuotaUsage, error) {
+	return c.quotaUsage(ctx, `organization_id = $1`, orgID)
+}
+
+func (c *Client) quotaUsage(ctx context.Context, where string, owner string) (*QuotaUsage, error) {
+	var u QuotaUsage
+	err := c.pool.QueryRow(ctx, `
+		SELECT count(*),
+		       coalesce(sum(volume_size_gb) FILTER (WHERE status <> $2), 0),
+		       count(*) FILTER (WHERE status = ANY($3)),
+		       count(*) FILTER (WHERE status = ANY($3) AND gpu_kind IS NOT NULL),
+		       coalesce(sum(cpus) FILTER (WHERE status = ANY($3) AND gpu_kind IS NULL), 0),
+		       coalesce(sum(memory_mb) FILTER (WHERE status = ANY($3) AND gpu_kind IS NULL), 0)
+		FROM projects
+		WHERE `+where+`
+	`, owner, StatusHibernated, MachineStatuses).Scan(
+		&u.Projects, &u.VolumeGB, &u.RunningMachines, &u.RunningGPUMachines, &u.RunningCPUs, &u.RunningMemoryMB,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+	return &u, nil
+}
+
+// LockQuota holds an account's quota until the returned release is called: the
+// organization's if orgID is set, otherwise the user's. Callers take it before
+// reading usage and release it once what they checked for is recorded, so two
+// requests can't both be let into the same room. The lock is a session-level
+// advisory lock on a connection kept out of the pool until release, which
+// Postgres drops by itself if the connection dies.
+func (c *Client) LockQuota(ctx context.Context, userID string, orgID *string) (func(), error) {
+	key := "quota:user:" + userID
+	if orgID != nil {
+		key = "quota:org:" + *orgID
+	}
+
+	conn, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection for quota lock: %w", err)
+	}
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock(hashtextextended($1, 0))`, key); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to lock quota: %w", err)
+	}
+
+	return func() {
+		ctx := context.WithoutCancel(ctx)
+		if _, err := conn.Exec(ctx, `SELECT pg_advisory_unlock(hashtextextended($1, 0))`, key); err != nil {
+			// Closing the connection is the only other way to drop the lock
+			_ = conn.Conn().Close(ctx)
+		}
+		conn.Release()
+	}, nil
+}
@@ -0,0 +1,280 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Rollout statuses. A rollout upgrades its canary projects, pauses for the
+// operator to check them, then rolls out to everything else.
+const (
+	RolloutCanary      = "canary"
+	RolloutPaused      = "paused"
+	RolloutRolling     = "rolling"
+	RolloutCompleted   = "completed"
+	RolloutRollingBack = "rolling_back"
+	RolloutRolledBack  = "rolled_back"
+)
+
+// Rollout project statuses
+const (
+	RolloutProjectPending      = "pending"
+	RolloutProjectUpgrading    = "upgrading"
+	RolloutProjectUpgraded     = "upgraded"
+	RolloutProjectFailed       = "failed"
+	RolloutProjectReverting    = "reverting"
+	RolloutProjectReverted     = "reverted"
+	RolloutProjectRevertFailed = "revert_failed"
+	RolloutProjectSkipped      = "skipped"
+)
+
+// ErrRolloutInProgress is returned when creating a rollout while another is unfinished
+var ErrRolloutInProgress = errors.New("rollout already in progress")
+
+// Rollout moves existing projects onto a new base image
+type Rollout struct {
+	ID            string     `json:"id"`
+	Image         string     `json:"image"`
+	CanaryPercent int        `json:"canary_percent"`
+	BatchSize     int        `json:"batch_size"`
+	Status        string     `json:"status"`
+	PauseReason   *string    `json:"pause_reason,omitempty"`
+	CreatedBy     *string    `json:"created_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+}
+
+// RolloutProject is one project's progress through a rollout
+type RolloutProject struct {
+	RolloutID     string    `json:"rollout_id"`
+	ProjectID     string    `json:"project_id"`
+	PreviousImage string    `json:"previous_image"`
+	Canary        bool      `json:"canary"`
+	Status        string    `json:"status"`
+	OperationID   *string   `json:"operation_id,omitempty"`
+	Error         *string   `json:"error,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+const rolloutColumns = `id, image, canary_percent, batch_size, status, pause_reason, created_by,
+		       created_at, updated_at, completed_at`
+
+func scanRollout(row pgx.Row) (*Rollout, error) {
+	var r Rollout
+	err := row.Scan(&r.ID, &r.Image, &r.CanaryPercent, &r.BatchSize, &r.Status, &r.PauseReason, &r.CreatedBy,
+		&r.CreatedAt, &r.UpdatedAt, &r.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func scanRollouts(rows pgx.Rows) ([]Rollout, error) {
+	defer rows.Close()
+
+	var rollouts []Rollout
+	for rows.Next() {
+		r, err := scanRollout(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rollout: %w", err)
+		}
+		rollouts = append(rollouts, *r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rollouts: %w", err)
+	}
+
+	return rollouts, nil
+}
+
+// ============================================
+// Image Rollout Methods
+// ============================================
+
+// CreateRollout starts a rollout of image to every project on a different platform
+// base image. canaryPercent of them, chosen at random, are upgraded first.
+// Returns ErrRolloutInProgress if another rollout is unfinished.
+func (c *Client) CreateRollout(ctx context.Context, image string, canaryPercent, batchSize int, createdBy string) (*Rollout, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	status := RolloutCanary
+	if canaryPercent == 0 {
+		status = RolloutRolling
+	}
+
+	rollout, err := scanRollout(tx.QueryRow(ctx, `
+		INSERT INTO image_rollouts (image, canary_percent, batch_size, status, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+rolloutColumns,
+		image, canaryPercent, batchSize, status, createdBy))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrRolloutInProgress
+		}
+		return nil, fmt.Errorf("failed to create rollout: %w", err)
+	}
+
+	// Projects on a template's image manage their own image and are left out
+	_, err = tx.Exec(ctx, `
+		INSERT INTO image_rollout_projects (rollout_id, project_id, previous_image, canary)
+		SELECT $1, id, base_image,
+		       row_number() OVER (ORDER BY random()) <= ceil(count(*) OVER () * $3 / 100.0)
+		FROM projects
+		WHERE template_image IS NULL AND base_image <> $2 AND status <> 'deleting'
+	`, rollout.ID, image, canaryPercent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enroll rollout projects: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit rollout: %w", err)
+	}
+	return rollout, nil
+}
+
+// ListRollouts returns every rollout, newest first
+func (c *Client) ListRollouts(ctx context.Context) ([]Rollout, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+rolloutColumns+`
+		FROM image_rollouts
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rollouts: %w", err)
+	}
+	return scanRollouts(rows)
+}
+
+// ListActiveRollouts returns rollouts that have work to do: upgrading or rolling back
+func (c *Client) ListActiveRollouts(ctx context.Context) ([]Rollout, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+rolloutColumns+`
+		FROM image_rollouts
+		WHERE status IN ('canary', 'rolling', 'rolling_back')
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active rollouts: %w", err)
+	}
+	return scanRollouts(rows)
+}
+
+func (c *Client) GetRollout(ctx context.Context, rolloutID string) (*Rollout, error) {
+	r, err := scanRollout(c.pool.QueryRow(ctx, `
+		SELECT `+rolloutColumns+`
+		FROM image_rollouts
+		WHERE id = $1
+	`, rolloutID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get rollout: %w", err)
+	}
+	return r, nil
+}
+
+// UpdateRolloutStatus moves a rollout to status if it is still in one of the from
+// statuses. Returns ErrNotFound if it isn't, or ErrRolloutInProgress if reopening
+// a finished rollout while another is unfinished.
+func (c *Client) UpdateRolloutStatus(ctx context.Context, rolloutID string, from []string, status string, pauseReason *string) (*Rollout, error) {
+	r, err := scanRollout(c.pool.QueryRow(ctx, `
+		UPDATE image_rollouts
+		SET status = $3, pause_reason = $4,
+		    completed_at = CASE WHEN $3 IN ('completed', 'rolled_back') THEN now() END
+		WHERE id = $1 AND status = ANY($2)
+		RETURNING `+rolloutColumns,
+		rolloutID, from, status, pauseReason))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrRolloutInProgress
+		}
+		return nil, fmt.Errorf("failed to update rollout status: %w", err)
+	}
+	return r, nil
+}
+
+// LeaseRollout lets one worker advance a rollout at a time. Returns false if
+// another worker holds an unexpired lease.
+func (c *Client) LeaseRollout(ctx context.Context, rolloutID, workerID string, leaseFor time.Duration) (bool, error) {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE image_rollouts
+		SET leased_by = $2, lease_expires_at = now() + $3 * interval '1 second'
+		WHERE id = $1 AND (leased_by = $2 OR lease_expires_at IS NULL OR lease_expires_at < now())
+	`, rolloutID, workerID, leaseFor.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to lease rollout: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// ListRolloutProjects returns a rollout's projects, canaries first
+func (c *Client) ListRolloutProjects(ctx context.Context, rolloutID string) ([]RolloutProject, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT rollout_id, project_id, previous_image, canary, status, operation_id, error, updated_at
+		FROM image_rollout_projects
+		WHERE rollout_id = $1
+		ORDER BY canary DESC, project_id
+	`, rolloutID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rollout projects: %w", err)
+	}
+	defer rows.Close()
+
+	var projects []RolloutProject
+	for rows.Next() {
+		var p RolloutProject
+		if err := rows.Scan(&p.RolloutID, &p.ProjectID, &p.PreviousImage, &p.Canary, &p.Status, &p.OperationID, &p.Error, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rollout project: %w", err)
+		}
+		projects = append(projects, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rollout projects: %w", err)
+	}
+
+	return projects, nil
+}
+
+// UpdateRolloutProject records a project's progress through a rollout
+func (c *Client) UpdateRolloutProject(ctx context.Context, rolloutID, projectID, status string, operationID, errorMsg *string) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE image_rollout_projects
+		SET status = $3, operation_id = $4, error = $5
+		WHERE rollout_id = $1 AND project_id = $2
+	`, rolloutID, projectID, status, operationID, errorMsg)
+	if err != nil {
+		return fmt.Errorf("failed to update rollout project: %w", err)
+	}
+	return nil
+}
+
+// SetRolloutProjectsStatus moves every project of a rollout in one of the from statuses to status
+func (c *Client) SetRolloutProjectsStatus(ctx context.Context, rolloutID string, from []string, status string) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE image_rollout_projects
+		SET status = $3
+		WHERE rollout_id = $1 AND status = ANY($2)
+	`, rolloutID, from, status)
+	if err != nil {
+		return fmt.Errorf("failed to update rollout projects: %w", err)
+	}
+	return nil
+}
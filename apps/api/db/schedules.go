@@ -0,0 +1,206 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Schedule actions
+const (
+	ScheduleActionStart = "start"
+	ScheduleActionStop  = "stop"
+)
+
+// Schedule run results
+const (
+	ScheduleResultTriggered = "triggered"
+	ScheduleResultSkipped   = "skipped"
+	ScheduleResultMissed    = "missed"
+	ScheduleResultFailed    = "failed"
+)
+
+// Schedule starts or stops a project whenever its cron expression fires
+type Schedule struct {
+	ID              string     `json:"id"`
+	ProjectID       string     `json:"project_id"`
+	UserID          string     `json:"-"`
+	Action          string     `json:"action"`
+	Cron            string     `json:"cron"`
+	Timezone        string     `json:"timezone"`
+	Enabled         bool       `json:"enabled"`
+	NextRunAt       *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	LastResult      *string    `json:"last_result,omitempty"`
+	LastError       *string    `json:"last_error,omitempty"`
+	LastOperationID *string    `json:"last_operation_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+const scheduleColumns = `id, project_id, user_id, action, cron, timezone, enabled, next_run_at,
+		       last_run_at, last_result, last_error, last_operation_id, created_at, updated_at`
+
+func scanSchedule(row pgx.Row) (*Schedule, error) {
+	var s Schedule
+	err := row.Scan(&s.ID, &s.ProjectID, &s.UserID, &s.Action, &s.Cron, &s.Timezone, &s.Enabled, &s.NextRunAt,
+		&s.LastRunAt, &s.LastResult, &s.LastError, &s.LastOperationID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func scanSchedules(rows pgx.Rows) ([]Schedule, error) {
+	defer rows.Close()
+
+	var schedules []Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// ============================================
+// Project Schedule Methods
+// ============================================
+
+func (c *Client) CreateSchedule(ctx context.Context, projectID, userID, action, cron, timezone string, enabled bool, nextRunAt *time.Time) (*Schedule, error) {
+	s, err := scanSchedule(c.pool.QueryRow(ctx, `
+		INSERT INTO project_schedules (project_id, user_id, action, cron, timezone, enabled, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+scheduleColumns,
+		projectID, userID, action, cron, timezone, enabled, nextRunAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return s, nil
+}
+
+// ListSchedules returns a project's schedules, oldest first
+func (c *Client) ListSchedules(ctx context.Context, projectID string) ([]Schedule, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM project_schedules
+		WHERE project_id = $1
+		ORDER BY created_at
+	`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	return scanSchedules(rows)
+}
+
+// ListUserSchedules returns the schedules of all the projects a user can reach, oldest first
+func (c *Client) ListUserSchedules(ctx context.Context, userID string) ([]Schedule, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM project_schedules
+		WHERE project_id IN (SELECT id FROM projects WHERE `+projectAccess("$1")+`)
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user schedules: %w", err)
+	}
+	return scanSchedules(rows)
+}
+
+func (c *Client) GetSchedule(ctx context.Context, scheduleID, projectID string) (*Schedule, error) {
+	s, err := scanSchedule(c.pool.QueryRow(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM project_schedules
+		WHERE id = $1 AND project_id = $2
+	`, scheduleID, projectID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	return s, nil
+}
+
+// UpdateSchedule replaces a schedule's settings and its next run
+func (c *Client) UpdateSchedule(ctx context.Context, scheduleID, projectID, action, cron, timezone string, enabled bool, nextRunAt *time.Time) (*Schedule, error) {
+	s, err := scanSchedule(c.pool.QueryRow(ctx, `
+		UPDATE project_schedules
+		SET action = $3, cron = $4, timezone = $5, enabled = $6, next_run_at = $7
+		WHERE id = $1 AND project_id = $2
+		RETURNING `+scheduleColumns,
+		scheduleID, projectID, action, cron, timezone, enabled, nextRunAt))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to update schedule: %w", err)
+	}
+	return s, nil
+}
+
+func (c *Client) DeleteSchedule(ctx context.Context, scheduleID, projectID string) error {
+	result, err := c.pool.Exec(ctx, `
+		DELETE FROM project_schedules
+		WHERE id = $1 AND project_id = $2
+	`, scheduleID, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListDueSchedules returns enabled schedules whose next run is at or before now
+func (c *Client) ListDueSchedules(ctx context.Context, now time.Time) ([]Schedule, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM project_schedules
+		WHERE enabled AND next_run_at <= $1
+		ORDER BY next_run_at
+		LIMIT 100
+	`, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due schedules: %w", err)
+	}
+	return scanSchedules(rows)
+}
+
+// ClaimScheduleRun moves a due schedule on to its next run. It only succeeds if the
+// schedule is still due at dueAt, so each run is claimed by one replica.
+func (c *Client) ClaimScheduleRun(ctx context.Context, scheduleID string, dueAt time.Time, nextRunAt *time.Time) (bool, error) {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE project_schedules
+		SET next_run_at = $3, last_run_at = now()
+		WHERE id = $1 AND enabled AND next_run_at = $2
+	`, scheduleID, dueAt, nextRunAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim schedule run: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// RecordScheduleResult records the outcome of a claimed run
+func (c *Client) RecordScheduleResult(ctx context.Context, scheduleID, result string, errorMsg, operationID *string) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE project_schedules
+		SET last_result = $2, last_error = $3, last_operation_id = $4
+		WHERE id = $1
+	`, scheduleID, result, errorMsg, operationID)
+	if err != nil {
+		return fmt.Errorf("failed to record schedule result: %w", err)
+	}
+	return nil
+}
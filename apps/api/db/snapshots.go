@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Snapshot creators
+const (
+	SnapshotCreatedByUser      = "user"
+	SnapshotCreatedByAutomatic = "automatic"
+)
+
+// Snapshot is a point-in-time copy of a project's volume
+type Snapshot struct {
+	ID                 string     `json:"id"`
+	ProjectID          string     `json:"project_id"`
+	UserID             string     `json:"-"`
+	Label              *string    `json:"label,omitempty"`
+	VolumeID           string     `json:"-"`
+	ProviderSnapshotID string     `json:"-"`
+	SizeBytes          int64      `json:"size_bytes"`
+	CreatedBy          string     `json:"created_by"`
+	CreatedAt          time.Time  `json:"created_at"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+}
+
+const snapshotColumns = `id, project_id, user_id, label, volume_id, provider_snapshot_id, size_bytes, created_by, created_at, expires_at`
+
+func scanSnapshot(row pgx.Row) (*Snapshot, error) {
+	var s Snapshot
+	err := row.Scan(&s.ID, &s.ProjectID, &s.UserID, &s.Label, &s.VolumeID, &s.ProviderSnapshotID,
+		&s.SizeBytes, &s.CreatedBy, &s.CreatedAt, &s.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ============================================
+// Project Snapshot Methods
+// ============================================
+
+// CreateSnapshot records a snapshot taken by the compute backend and logs a
+// snapshot_created event. expiresAt is when the backend discards the snapshot,
+// or nil if it keeps it until deleted.
+func (c *Client) CreateSnapshot(ctx context.Context, projectID, userID, volumeID, providerSnapshotID string, label *string, sizeBytes int64, createdBy string, expiresAt *time.Time) (*Snapshot, error) {
+	s, err := scanSnapshot(c.pool.QueryRow(ctx, `
+		WITH inserted AS (
+			INSERT INTO project_snapshots (project_id, user_id, label, volume_id, provider_snapshot_id, size_bytes, created_by, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING `+snapshotColumns+`
+		), event AS (
+			INSERT INTO project_events (project_id, user_id, organization_id, type, status, data)
+			SELECT i.project_id, i.user_id, p.organization_id, 'snapshot_created', p.status,
+			       jsonb_build_object('snapshot_id', i.id, 'created_by', i.created_by)
+			FROM inserted i JOIN projects p ON p.id = i.project_id
+		)
+		SELECT `+snapshotColumns+` FROM inserted
+	`, projectID, userID, label, volumeID, providerSnapshotID, sizeBytes, createdBy, expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	return s, nil
+}
+
+// ListSnapshots returns a project's unexpired snapshots, newest first
+func (c *Client) ListSnapshots(ctx context.Context, projectID string) ([]Snapshot, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+snapshotColumns+`
+		FROM project_snapshots
+		WHERE project_id = $1 AND (expires_at IS NULL OR expires_at > now())
+		ORDER BY created_at DESC
+	`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		snapshots = append(snapshots, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating snapshots: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// GetSnapshot returns an unexpired snapshot belonging to the given project
+func (c *Client) GetSnapshot(ctx context.Context, snapshotID, projectID string) (*Snapshot, error) {
+	s, err := scanSnapshot(c.pool.QueryRow(ctx, `
+		SELECT `+snapshotColumns+`
+		FROM project_snapshots
+		WHERE id = $1 AND project_id = $2 AND (expires_at IS NULL OR expires_at > now())
+	`, snapshotID, projectID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+	return s, nil
+}
+
+// DeleteSnapshot removes a snapshot's metadata
+func (c *Client) DeleteSnapshot(ctx context.Context, snapshotID, projectID string) error {
+	result, err := c.pool.Exec(ctx, `
+		DELETE FROM project_snapshots
+		WHERE id = $1 AND project_id = $2
+	`, snapshotID, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteExpiredSnapshots removes snapshots the backend has discarded and returns how many
+func (c *Client) DeleteExpiredSnapshots(ctx context.Context) (int64, error) {
+	result, err := c.pool.Exec(ctx, `DELETE FROM project_snapshots WHERE expires_at < now()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired snapshots: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Project statuses
+const (
+	StatusStopped     = "stopped"
+	StatusStarting    = "starting"
+	StatusRunning     = "running"
+	StatusStopping    = "stopping"
+	StatusError       = "error"
+	StatusDeleting    = "deleting"
+	StatusRestoring   = "restoring"
+	StatusHibernating = "hibernating"
+	StatusHibernated  = "hibernated"
+	StatusTrashed     = "trashed"
+	StatusMoving      = "moving"
+)
+
+var projectStatuses = []string{StatusStopped, StatusStarting, StatusRunning, StatusStopping, StatusError, StatusDeleting, StatusRestoring, StatusHibernating, StatusHibernated, StatusTrashed, StatusMoving}
+
+// IsProjectStatus reports whether s is a known project status
+func IsProjectStatus(s string) bool {
+	for _, status := range projectStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// projectTransitions lists the statuses each status may move to.
+// The normal lifecycle is stopped → starting → running → stopping → stopped;
+// any in-flight status can fail into error, and error can be retried.
+// Snapshot restores run only on stopped projects and return them to stopped.
+// Region moves also run on hibernated projects, returning them to where they started.
+// Hibernation also starts from stopped, falling back to it if archiving fails.
+// Deleting a project trashes it; only trashed projects are deleted for real, and
+// a failed purge leaves them in the trash. A project whose machine can't be
+// stopped after it was trashed goes back to running.
+var projectTransitions = map[string][]string{
+	StatusStopped:     {StatusStarting, StatusRestoring, StatusHibernating, StatusTrashed, StatusMoving},
+	StatusStarting:    {StatusRunning, StatusError},
+	StatusRunning:     {StatusStopping, StatusError, StatusTrashed},
+	StatusStopping:    {StatusStopped, StatusError},
+	StatusError:       {StatusStarting, StatusStopping, StatusStopped, StatusTrashed},
+	StatusDeleting:    {StatusTrashed},
+	StatusRestoring:   {StatusStopped, StatusError},
+	StatusHibernating: {StatusHibernated, StatusStopped, StatusError},
+	StatusHibernated:  {StatusStarting, StatusTrashed, StatusMoving},
+	StatusTrashed:     {StatusStopped, StatusHibernated, StatusDeleting, StatusRunning},
+	StatusMoving:      {StatusStopped, StatusHibernated, StatusError},
+}
+
+// ErrInvalidTransition is returned when a project is not in a status that
+// allows the requested transition
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// TransitionError reports the project's actual status when a transition is rejected
+type TransitionError struct {
+	Current string
+	Target  string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot move project from %s to %s", e.Current, e.Target)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// CanTransition reports whether the state machine allows from → to
+func CanTransition(from, to string) bool {
+	for _, s := range projectTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionSources returns every status that may move to the given status
+func TransitionSources(to string) []string {
+	var sources []string
+	for _, from := range projectStatuses {
+		if CanTransition(from, to) {
+			sources = append(sources, from)
+		}
+	}
+	return sources
+}
+
+func checkTransitions(from []string, to string) error {
+	if len(from) == 0 {
+		return fmt.Errorf("no source statuses given for transition to %s", to)
+	}
+	for _, f := range from {
+		if !CanTransition(f, to) {
+			return fmt.Errorf("transition %s → %s is not allowed", f, to)
+		}
+	}
+	return nil
+}
+
+// querier is satisfied by both the pool and a transaction
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// transitionProjectStatus moves a project to `to` only if its current status is one of `from`.
+// Returns a *TransitionError carrying the current status if the update didn't apply.
+func transitionProjectStatus(ctx context.Context, q querier, projectID string, from []string, to string, errorMsg *string) error {
+	// Every applied transition is written to the event log in the same statement
+	var status string
+	err := q.QueryRow(ctx, `
+		WITH updated AS (
+			UPDATE projects p SET status = $3, error_message = $4
+			FROM (SELECT id, status FROM projects WHERE id = $1 FOR UPDATE) prev
+			WHERE p.id = prev.id AND prev.status = ANY($2)
+			RETURNING p.id, p.user_id, p.organization_id, p.status, p.error_message, prev.status AS prev_status
+		)
+		INSERT INTO project_events (project_id, user_id, organization_id, type, status, message, data)
+		SELECT id, user_id, organization_id, 'status', status, error_message, jsonb_build_object('from', prev_status)
+		FROM updated
+		RETURNING status
+	`, projectID, from, to, errorMsg).Scan(&status)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to transition project status: %w", err)
+	}
+
+	// Nothing matched: either the project is gone or it is in another status
+	err = q.QueryRow(ctx, `SELECT status FROM projects WHERE id = $1`, projectID).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get project status: %w", err)
+	}
+	return &TransitionError{Current: status, Target: to}
+}
+
+// TransitionProjectStatus atomically moves a project from one of the given statuses to `to`.
+// Returns a *TransitionError (matching ErrInvalidTransition) with the current status
+// if the project was not in any of the expected statuses.
+func (c *Client) TransitionProjectStatus(ctx context.Context, projectID string, from []string, to string, errorMsg *string) error {
+	if err := checkTransitions(from, to); err != nil {
+		return err
+	}
+	return transitionProjectStatus(ctx, c.pool, projectID, from, to, errorMsg)
+}
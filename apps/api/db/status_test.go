@@ -0,0 +1,72 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusStopped, StatusStarting, true},
+		{StatusStarting, StatusRunning, true},
+		{StatusRunning, StatusStopping, true},
+		{StatusStopping, StatusStopped, true},
+		{StatusStarting, StatusError, true},
+		{StatusError, StatusStarting, true},
+		{StatusStopped, StatusRunning, false},
+		{StatusStarting, StatusStopping, false},
+		{StatusStopping, StatusStarting, false},
+		{StatusStarting, StatusDeleting, false},
+		{StatusDeleting, StatusStarting, false},
+		{StatusStopped, StatusRestoring, true},
+		{StatusRunning, StatusRestoring, false},
+		{StatusRestoring, StatusStarting, false},
+		{StatusStopped, StatusHibernating, true},
+		{StatusRunning, StatusHibernating, false},
+		{StatusHibernating, StatusStopped, true},
+		{StatusHibernated, StatusStarting, true},
+		{StatusHibernated, StatusRunning, false},
+		{StatusRunning, StatusTrashed, true},
+		{StatusStopped, StatusDeleting, false},
+		{StatusTrashed, StatusDeleting, true},
+		{StatusTrashed, StatusStarting, false},
+		{StatusTrashed, StatusRunning, true},
+		{StatusDeleting, StatusTrashed, true},
+		{StatusStopped, StatusMoving, true},
+		{StatusMoving, StatusStarting, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestTransitionSources(t *testing.T) {
+	got := TransitionSources(StatusStopped)
+	want := []string{StatusStopping, StatusError, StatusRestoring, StatusHibernating, StatusTrashed, StatusMoving}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TransitionSources(stopped) = %v, want %v", got, want)
+	}
+
+	got = TransitionSources(StatusTrashed)
+	want = []string{StatusStopped, StatusRunning, StatusError, StatusDeleting, StatusHibernated}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TransitionSources(trashed) = %v, want %v", got, want)
+	}
+}
+
+func TestTransitionError(t *testing.T) {
+	var err error = &TransitionError{Current: StatusStarting, Target: StatusDeleting}
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Error("expected TransitionError to match ErrInvalidTransition")
+	}
+	if err.Error() != "cannot move project from starting to deleting" {
+		t.Errorf("unexpected message: %s", err.Error())
+	}
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Template is a starting point for new projects. Built-in templates are defined
+// in the API and never stored; user-defined ones live in project_templates.
+type Template struct {
+	ID               string            `json:"id"`
+	UserID           string            `json:"-"`
+	Name             string            `json:"name"`
+	Description      *string           `json:"description,omitempty"`
+	BaseImage        *string           `json:"base_image,omitempty"`
+	HardwarePreset   *string           `json:"hardware_preset,omitempty"`
+	EnvVars          map[string]string `json:"env_vars"`
+	Ports            []int             `json:"ports"`
+	Files            map[string]string `json:"files"`
+	GitURL           *string           `json:"git_url,omitempty"`
+	PostCreateScript *string           `json:"post_create_script,omitempty"`
+	BuiltIn          bool              `json:"built_in"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
+
+// TemplateSetup is the part of a template applied inside the workspace on first boot
+type TemplateSetup struct {
+	Files            map[string]string `json:"files,omitempty"`
+	GitURL           *string           `json:"git_url,omitempty"`
+	PostCreateScript *string           `json:"post_create_script,omitempty"`
+}
+
+// Setup returns the template's first-boot work, or nil if there is none
+func (t *Template) Setup() *TemplateSetup {
+	if len(t.Files) == 0 && t.GitURL == nil && t.PostCreateScript == nil {
+		return nil
+	}
+	return &TemplateSetup{Files: t.Files, GitURL: t.GitURL, PostCreateScript: t.PostCreateScript}
+}
+
+const templateColumns = `id, user_id, name, description, base_image, hardware_preset, env_vars, ports,
+		       files, git_url, post_create_script, created_at, updated_at`
+
+func scanTemplate(row pgx.Row) (*Template, error) {
+	var t Template
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Description, &t.BaseImage, &t.HardwarePreset,
+		&t.EnvVars, &t.Ports, &t.Files, &t.GitURL, &t.PostCreateScript, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ============================================
+// Project Template Methods
+// ============================================
+
+// ListTemplates returns a user's own templates, newest first
+func (c *Client) ListTemplates(ctx context.Context, userID string) ([]Template, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+templateColumns+`
+		FROM project_templates
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []Template
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan template: %w", err)
+		}
+		templates = append(templates, *t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating templates: %w", err)
+	}
+
+	return templates, nil
+}
+
+func (c *Client) GetTemplate(ctx context.Context, templateID, userID string) (*Template, error) {
+	t, err := scanTemplate(c.pool.QueryRow(ctx, `
+		SELECT `+templateColumns+`
+		FROM project_templates
+		WHERE id = $1 AND user_id = $2
+	`, templateID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+	return t, nil
+}
+
+// CreateTemplate stores a user-defined template. ID, UserID and timestamps are
+// ignored and filled in from the inserted row.
+func (c *Client) CreateTemplate(ctx context.Context, userID string, t *Template) (*Template, error) {
+	envVars := t.EnvVars
+	if envVars == nil {
+		envVars = map[string]string{}
+	}
+	ports := t.Ports
+	if ports == nil {
+		ports = []int{}
+	}
+	files := t.Files
+	if files == nil {
+		files = map[string]string{}
+	}
+
+	created, err := scanTemplate(c.pool.QueryRow(ctx, `
+		INSERT INTO project_templates (user_id, name, description, base_image, hardware_preset,
+		                               env_vars, ports, files, git_url, post_create_script)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+templateColumns,
+		userID, t.Name, t.Description, t.BaseImage, t.HardwarePreset,
+		envVars, ports, files, t.GitURL, t.PostCreateScript))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create template: %w", err)
+	}
+	return created, nil
+}
+
+func (c *Client) DeleteTemplate(ctx context.Context, templateID, userID string) error {
+	result, err := c.pool.Exec(ctx, `
+		DELETE FROM project_templates WHERE id = $1 AND user_id = $2
+	`, templateID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClearProjectTemplateSetup marks a project's template as applied
+func (c *Client) ClearProjectTemplateSetup(ctx context.Context, projectID string) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE projects SET template_setup = NULL WHERE id = $1
+	`, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to clear template setup: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TrashProject moves a project from one of the given statuses to trashed and
+// stamps when, which starts its retention period
+func (c *Client) TrashProject(ctx context.Context, projectID string, from []string) error {
+	if err := checkTransitions(from, StatusTrashed); err != nil {
+		return err
+	}
+
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := transitionProjectStatus(ctx, tx, projectID, from, StatusTrashed, nil); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE projects SET deleted_at = now() WHERE id = $1`, projectID); err != nil {
+		return fmt.Errorf("failed to trash project: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit trash: %w", err)
+	}
+	return nil
+}
+
+// RestoreTrashedProject moves a trashed project back to the given status
+func (c *Client) RestoreTrashedProject(ctx context.Context, projectID, to string) error {
+	if err := checkTransitions([]string{StatusTrashed}, to); err != nil {
+		return err
+	}
+
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := transitionProjectStatus(ctx, tx, projectID, []string{StatusTrashed}, to, nil); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE projects SET deleted_at = NULL WHERE id = $1`, projectID); err != nil {
+		return fmt.Errorf("failed to restore project: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return nil
+}
+
+// ListTrashedProjects returns the trashed projects a user can reach, most
+// recently deleted first
+func (c *Client) ListTrashedProjects(ctx context.Context, userID string) ([]Project, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+projectColumns+`, `+projectRole("$1")+`
+		FROM projects
+		WHERE `+projectAccess("$1")+` AND status = 'trashed'
+		ORDER BY deleted_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trashed projects: %w", err)
+	}
+	defer rows.Close()
+
+	var projects []Project
+	for rows.Next() {
+		p, err := scanUserProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan project: %w", err)
+		}
+		projects = append(projects, *p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating projects: %w", err)
+	}
+
+	return projects, nil
+}
+
+// ListExpiredTrash returns projects trashed before the given time, oldest first
+func (c *Client) ListExpiredTrash(ctx context.Context, deletedBefore time.Time, limit int) ([]Project, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+projectColumns+`
+		FROM projects
+		WHERE status = 'trashed' AND deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+	`, deletedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired trash: %w", err)
+	}
+	return collectProjects(rows)
+}
+
+// ListStaleDeletions returns projects left deleting since before the given
+// time, such as by a purge interrupted by a restart, oldest first
+func (c *Client) ListStaleDeletions(ctx context.Context, updatedBefore time.Time, limit int) ([]Project, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+projectColumns+`
+		FROM projects
+		WHERE status = 'deleting' AND updated_at < $1
+		ORDER BY updated_at
+		LIMIT $2
+	`, updatedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale deletions: %w", err)
+	}
+	return collectProjects(rows)
+}
+
+// ReclaimDeletion takes over the purge of a project left deleting since before
+// the given time, restarting its staleness clock. It returns ErrNotFound if the
+// project has since been purged, sent back to the trash or reclaimed by someone else.
+func (c *Client) ReclaimDeletion(ctx context.Context, projectID string, updatedBefore time.Time) error {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE projects SET updated_at = now()
+		WHERE id = $1 AND status = 'deleting' AND updated_at < $2
+	`, projectID, updatedBefore)
+	if err != nil {
+		return fmt.Errorf("failed to reclaim deletion: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// collectProjects scans and closes rows of projectColumns
+func collectProjects(rows pgx.Rows) ([]Project, error) {
+	defer rows.Close()
+
+	var projects []Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan project: %w", err)
+		}
+		projects = append(projects, *p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating projects: %w", err)
+	}
+
+	return projects, nil
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UsageInterval is one run of a project's machine. StoppedAt is nil while it runs.
+type UsageInterval struct {
+	ID          string     `json:"id"`
+	ProjectID   *string    `json:"project_id"`
+	ProjectName string     `json:"project_name"`
+	UserID      string     `json:"user_id"`
+	MachineID   *string    `json:"machine_id,omitempty"`
+	Region      *string    `json:"region,omitempty"`
+	CPUKind     string     `json:"cpu_kind"`
+	CPUs        int        `json:"cpus"`
+	MemoryMB    int        `json:"memory_mb"`
+	GPUKind     *string    `json:"gpu_kind,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	StoppedAt   *time.Time `json:"stopped_at,omitempty"`
+}
+
+// UsageQuery selects the intervals that overlap [From, To) for a user, or for
+// one project whoever ran it. Callers must check the user can see the project.
+type UsageQuery struct {
+	UserID    string
+	ProjectID *string
+	From      time.Time
+	To        time.Time
+}
+
+const usageIntervalColumns = `id, project_id, project_name, user_id, machine_id, region,
+	cpu_kind, cpus, memory_mb, gpu_kind, started_at, stopped_at`
+
+func scanUsageInterval(row pgx.Row) (*UsageInterval, error) {
+	var u UsageInterval
+	err := row.Scan(&u.ID, &u.ProjectID, &u.ProjectName, &u.UserID, &u.MachineID, &u.Region,
+		&u.CPUKind, &u.CPUs, &u.MemoryMB, &u.GPUKind, &u.StartedAt, &u.StoppedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// OpenUsageInterval records that a project's machine started. It does nothing if
+// the project already has an open interval, so resumed operations can repeat it.
+func (c *Client) OpenUsageInterval(ctx context.Context, interval *UsageInterval) error {
+	_, err := c.pool.Exec(ctx, `
+		INSERT INTO usage_intervals (project_id, project_name, user_id, machine_id, region,
+		                             cpu_kind, cpus, memory_mb, gpu_kind, started_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (project_id) WHERE stopped_at IS NULL DO NOTHING
+	`, interval.ProjectID, interval.ProjectName, interval.UserID, interval.MachineID, interval.Region,
+		interval.CPUKind, interval.CPUs, interval.MemoryMB, interval.GPUKind, interval.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to open usage interval: %w", err)
+	}
+	return nil
+}
+
+// CloseUsageInterval records that a project's machine stopped at the given time.
+// A project without an open interval is left alone.
+func (c *Client) CloseUsageInterval(ctx context.Context, projectID string, at time.Time) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE usage_intervals
+		SET stopped_at = GREATEST(started_at, $2)
+		WHERE project_id = $1 AND stopped_at IS NULL
+	`, projectID, at)
+	if err != nil {
+		return fmt.Errorf("failed to close usage interval: %w", err)
+	}
+	return nil
+}
+
+// CloseStaleUsageIntervals closes open intervals whose project no longer holds a
+// machine, such as one that failed or was reconciled without a stop operation
+func (c *Client) CloseStaleUsageIntervals(ctx context.Context, at time.Time) (int64, error) {
+	result, err := c.pool.Exec(ctx, `
+		UPDATE usage_intervals u
+		SET stopped_at = GREATEST(u.started_at, $1)
+		WHERE u.stopped_at IS NULL
+		  AND NOT EXISTS (
+		      SELECT 1 FROM projects p
+		      WHERE p.id = u.project_id AND p.status = ANY($2)
+		  )
+	`, at, MachineStatuses)
+	if err != nil {
+		return 0, fmt.Errorf("failed to close stale usage intervals: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// GetProjectIDsWithOpenUsage returns the set of projects with an open usage interval
+func (c *Client) GetProjectIDsWithOpenUsage(ctx context.Context) (map[string]bool, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT project_id FROM usage_intervals
+		WHERE stopped_at IS NULL AND project_id IS NOT NULL
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open usage intervals: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan usage interval project id: %w", err)
+		}
+		ids[id] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating open usage intervals: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ListUsageIntervals returns the intervals that overlap the query's range, oldest first
+func (c *Client) ListUsageIntervals(ctx context.Context, q UsageQuery) ([]UsageInterval, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT `+usageIntervalColumns+`
+		FROM usage_intervals
+		WHERE CASE WHEN $2::uuid IS NULL THEN user_id = $1 ELSE project_id = $2 END
+		  AND started_at < $4
+		  AND (stopped_at IS NULL OR stopped_at > $3)
+		ORDER BY started_at
+	`, q.UserID, q.ProjectID, q.From, q.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage intervals: %w", err)
+	}
+	defer rows.Close()
+
+	var intervals []UsageInterval
+	for rows.Next() {
+		u, err := scanUsageInterval(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan usage interval: %w", err)
+		}
+		intervals = append(intervals, *u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list usage intervals: %w", err)
+	}
+	return intervals, nil
+}
@@ -40,6 +40,7 @@ type MachineConfig struct {
 	Env      map[string]string `json:"env,omitempty"`
 	Services []Service         `json:"services,omitempty"`
 	Mounts   []Mount           `json:"mounts,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type Mount struct {
@@ -83,12 +84,33 @@ type CreateMachineRequest struct {
 	Config MachineConfig `json:"config"`
 }
 
+type UpdateMachineRequest struct {
+	Config     MachineConfig `json:"config"`
+	SkipLaunch bool          `json:"skip_launch,omitempty"`
+}
+
+type ExecRequest struct {
+	Command []string `json:"command"`
+	Timeout int      `json:"timeout,omitempty"`
+}
+
+type ExecResponse struct {
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+}
+
 type APIError struct {
 	Error   string `json:"error"`
 	Message string `json:"message,omitempty"`
 }
 
 func (c *Client) doRequest(method, path string, body interface{}) ([]byte, error) {
+	return c.doRequestWithTimeout(method, path, body, 0)
+}
+
+// doRequestWithTimeout is doRequest for calls that can outlast the client's default timeout
+func (c *Client) doRequestWithTimeout(method, path string, body interface{}, timeout time.Duration) ([]byte, error) {
 	url := fmt.Sprintf("%s/apps/%s%s", baseURL, c.appName, path)
 
 	var reqBody io.Reader
@@ -110,7 +132,12 @@ func (c *Client) doRequest(method, path string, body interface{}) ([]byte, error
 	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.http.Do(req)
+	client := c.http
+	if timeout > 0 {
+		client = &http.Client{Transport: c.http.Transport, Timeout: timeout}
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -138,13 +165,32 @@ func (c *Client) doRequest(method, path string, body interface{}) ([]byte, error
 }
 
 func (c *Client) CreateMachine(name string, config handlers.MachineConfig) (*handlers.Machine, error) {
-	region := c.region
-	// GPU machines must be created in ord region
-	if config.Guest.GPUKind != "" {
-		region = "ord"
+	region := config.Region
+	if region == "" {
+		region = c.region
+	}
+
+	req := CreateMachineRequest{
+		Name:   name,
+		Region: region,
+		Config: toFlyConfig(config),
+	}
+
+	respBody, err := c.doRequest("POST", "/machines", req)
+	if err != nil {
+		return nil, fmt.Errorf("create machine %s (region=%s): %w", name, region, err)
 	}
 
-	// Convert handlers config to Fly API config
+	var machine Machine
+	if err := json.Unmarshal(respBody, &machine); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return machineToHandler(&machine), nil
+}
+
+// toFlyConfig converts a handlers config to a Fly API config
+func toFlyConfig(config handlers.MachineConfig) MachineConfig {
 	flyConfig := MachineConfig{
 		Image: config.Image,
 		Guest: GuestConfig{
@@ -153,7 +199,8 @@ func (c *Client) CreateMachine(name string, config handlers.MachineConfig) (*han
 			MemoryMB: config.Guest.MemoryMB,
 			GPUKind:  config.Guest.GPUKind,
 		},
-		Env: config.Env,
+		Env:      config.Env,
+		Metadata: config.Labels,
 	}
 	for _, m := range config.Mounts {
 		flyConfig.Mounts = append(flyConfig.Mounts, Mount{
@@ -161,24 +208,46 @@ func (c *Client) CreateMachine(name string, config handlers.MachineConfig) (*han
 			Path:   m.Path,
 		})
 	}
+	return flyConfig
+}
 
-	req := CreateMachineRequest{
-		Name:   name,
-		Region: region,
-		Config: flyConfig,
+// UpdateMachine replaces a machine's config without launching it. The machine keeps
+// its region, so changes that need a different region require a new machine.
+func (c *Client) UpdateMachine(machineID string, config handlers.MachineConfig) error {
+	req := UpdateMachineRequest{
+		Config:     toFlyConfig(config),
+		SkipLaunch: true,
 	}
 
-	respBody, err := c.doRequest("POST", "/machines", req)
+	if _, err := c.doRequest("POST", "/machines/"+machineID, req); err != nil {
+		return fmt.Errorf("update machine %s: %w", machineID, err)
+	}
+	return nil
+}
+
+// Exec runs a command on a started machine and waits for it to exit
+func (c *Client) Exec(machineID string, command []string, timeout time.Duration) (*handlers.ExecResult, error) {
+	req := ExecRequest{
+		Command: command,
+		Timeout: int(timeout.Seconds()),
+	}
+
+	// Leave the API time to report a command that hit its timeout
+	respBody, err := c.doRequestWithTimeout("POST", "/machines/"+machineID+"/exec", req, timeout+10*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("create machine %s (region=%s): %w", name, region, err)
+		return nil, fmt.Errorf("exec on machine %s: %w", machineID, err)
 	}
 
-	var machine Machine
-	if err := json.Unmarshal(respBody, &machine); err != nil {
+	var resp ExecResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	return machineToHandler(&machine), nil
+	return &handlers.ExecResult{
+		ExitCode: resp.ExitCode,
+		Stdout:   resp.Stdout,
+		Stderr:   resp.Stderr,
+	}, nil
 }
 
 func (c *Client) GetMachine(machineID string) (*handlers.Machine, error) {
@@ -317,6 +386,8 @@ type CreateVolumeRequest struct {
 	Encrypted         bool   `json:"encrypted,omitempty"`
 	RequireUniqueZone bool   `json:"require_unique_zone,omitempty"`
 	FSType            string `json:"fstype,omitempty"`
+	SourceVolumeID    string `json:"source_volume_id,omitempty"`
+	SnapshotID        string `json:"snapshot_id,omitempty"`
 }
 
 func (c *Client) CreateVolume(name string, sizeGB int, region string) (*handlers.Volume, error) {
@@ -341,6 +412,55 @@ func (c *Client) CreateVolume(name string, sizeGB int, region string) (*handlers
 	return volumeToHandler(&volume), nil
 }
 
+// ExtendVolume grows a volume. Fly only allows extending, never shrinking.
+func (c *Client) ExtendVolume(volumeID string, sizeGB int) error {
+	req := map[string]int{"size_gb": sizeGB}
+	if _, err := c.doRequest("PUT", "/volumes/"+volumeID+"/extend", req); err != nil {
+		return fmt.Errorf("extend volume %s to %dGB: %w", volumeID, sizeGB, err)
+	}
+	return nil
+}
+
+// ForkVolume creates a copy of an existing volume in the source volume's region.
+// The fork must be at least as large as the source.
+func (c *Client) ForkVolume(sourceVolumeID, name string) (*handlers.Volume, error) {
+	return c.CopyVolume(sourceVolumeID, name, "")
+}
+
+// CopyVolume forks a volume into region, or the source's region if empty. Fly
+// hydrates a fork in another region in the background; it can be attached
+// straight away.
+func (c *Client) CopyVolume(sourceVolumeID, name, region string) (*handlers.Volume, error) {
+	source, err := c.GetVolume(sourceVolumeID)
+	if err != nil {
+		return nil, err
+	}
+	if region == "" {
+		region = source.Region
+	}
+
+	req := CreateVolumeRequest{
+		Name:           name,
+		Region:         region,
+		SizeGB:         source.SizeGB,
+		Encrypted:      true,
+		FSType:         "ext4",
+		SourceVolumeID: sourceVolumeID,
+	}
+
+	respBody, err := c.doRequest("POST", "/volumes", req)
+	if err != nil {
+		return nil, fmt.Errorf("copy volume %s as %s (region=%s): %w", sourceVolumeID, name, region, err)
+	}
+
+	var volume Volume
+	if err := json.Unmarshal(respBody, &volume); err != nil {
+		return nil, fmt.Errorf("failed to parse volume response: %w", err)
+	}
+
+	return volumeToHandler(&volume), nil
+}
+
 func (c *Client) GetVolume(volumeID string) (*handlers.Volume, error) {
 	respBody, err := c.doRequest("GET", "/volumes/"+volumeID, nil)
 	if err != nil {
@@ -375,6 +495,111 @@ func (c *Client) DeleteVolume(volumeID string) error {
 	return nil
 }
 
+// Snapshot types and operations
+
+type VolumeSnapshot struct {
+	ID            string `json:"id"`
+	CreatedAt     string `json:"created_at"`
+	Digest        string `json:"digest"`
+	RetentionDays int    `json:"retention_days"`
+	Size          int64  `json:"size"`
+	Status        string `json:"status"`
+}
+
+// CreateSnapshot snapshots a volume. Its size and retention are read back from
+// the volume's list, matched on the ID the create call returns.
+func (c *Client) CreateSnapshot(volumeID string) (*handlers.Snapshot, error) {
+	respBody, err := c.doRequest("POST", "/volumes/"+volumeID+"/snapshots", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot of volume %s: %w", volumeID, err)
+	}
+
+	var created VolumeSnapshot
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot response: %w", err)
+	}
+	if created.ID == "" {
+		return nil, fmt.Errorf("create snapshot of volume %s: response has no snapshot ID", volumeID)
+	}
+
+	snapshots, err := c.ListSnapshots(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range snapshots {
+		if snapshots[i].ID == created.ID {
+			return snapshotToHandler(volumeID, &snapshots[i]), nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot %s of volume %s not found after creation", created.ID, volumeID)
+}
+
+// snapshotToHandler converts a Fly API VolumeSnapshot to a handlers.Snapshot.
+// Fly discards snapshots retention_days after they were taken.
+func snapshotToHandler(volumeID string, s *VolumeSnapshot) *handlers.Snapshot {
+	snapshot := &handlers.Snapshot{
+		ID:        s.ID,
+		VolumeID:  volumeID,
+		SizeBytes: s.Size,
+		CreatedAt: s.CreatedAt,
+	}
+	if createdAt, err := time.Parse(time.RFC3339, s.CreatedAt); err == nil && s.RetentionDays > 0 {
+		expiresAt := createdAt.AddDate(0, 0, s.RetentionDays)
+		snapshot.ExpiresAt = &expiresAt
+	}
+	return snapshot
+}
+
+func (c *Client) ListSnapshots(volumeID string) ([]VolumeSnapshot, error) {
+	respBody, err := c.doRequest("GET", "/volumes/"+volumeID+"/snapshots", nil)
+	if err != nil {
+		return nil, fmt.Errorf("list snapshots of volume %s: %w", volumeID, err)
+	}
+
+	var snapshots []VolumeSnapshot
+	if err := json.Unmarshal(respBody, &snapshots); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshots response: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// DeleteSnapshot always fails: the Machines API has no endpoint for deleting
+// snapshots, which expire after the volume's snapshot retention period
+func (c *Client) DeleteSnapshot(snapshotID string) error {
+	return handlers.ErrSnapshotDeleteUnsupported
+}
+
+// RestoreSnapshot creates a new volume from a snapshot, since Fly volumes can't be
+// restored in place. The new volume matches the current volume's region and size.
+func (c *Client) RestoreSnapshot(volumeID, snapshotID, name string) (*handlers.Volume, error) {
+	current, err := c.GetVolume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	req := CreateVolumeRequest{
+		Name:       name,
+		Region:     current.Region,
+		SizeGB:     current.SizeGB,
+		Encrypted:  true,
+		FSType:     "ext4",
+		SnapshotID: snapshotID,
+	}
+
+	respBody, err := c.doRequest("POST", "/volumes", req)
+	if err != nil {
+		return nil, fmt.Errorf("restore snapshot %s as %s (region=%s): %w", snapshotID, name, current.Region, err)
+	}
+
+	var volume Volume
+	if err := json.Unmarshal(respBody, &volume); err != nil {
+		return nil, fmt.Errorf("failed to parse volume response: %w", err)
+	}
+
+	return volumeToHandler(&volume), nil
+}
+
 func (c *Client) ListVolumes() ([]Volume, error) {
 	respBody, err := c.doRequest("GET", "/volumes", nil)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"aether/apps/api/handlers"
 )
@@ -71,6 +72,12 @@ func TestCreateMachine(t *testing.T) {
 		if req.Name != "test-machine" {
 			t.Errorf("expected name 'test-machine', got %s", req.Name)
 		}
+		if req.Config.Metadata["client"] != "acme" {
+			t.Errorf("expected labels in metadata, got %v", req.Config.Metadata)
+		}
+		if req.Region != "ams" {
+			t.Errorf("expected the config's region over the client's, got %s", req.Region)
+		}
 
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(Machine{
@@ -96,8 +103,10 @@ func TestCreateMachine(t *testing.T) {
 	defer func() { baseURL = originalBaseURL }()
 
 	machine, err := client.CreateMachine("test-machine", handlers.MachineConfig{
-		Image: "test-image",
-		Guest: handlers.GuestConfig{CPUKind: "shared", CPUs: 1, MemoryMB: 256},
+		Region: "ams",
+		Image:  "test-image",
+		Guest:  handlers.GuestConfig{CPUKind: "shared", CPUs: 1, MemoryMB: 256},
+		Labels: map[string]string{"client": "acme"},
 	})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -133,3 +142,91 @@ func TestAPIError(t *testing.T) {
 		t.Fatal("expected error, got nil")
 	}
 }
+
+func TestCreateSnapshot(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/test-app/volumes/vol-1/snapshots" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Method == "POST" {
+			if err := json.NewEncoder(w).Encode(VolumeSnapshot{ID: "snap-new"}); err != nil {
+				t.Fatalf("failed to encode response: %v", err)
+			}
+			return
+		}
+		// A daily snapshot taken later than ours must not be mistaken for it
+		snapshots := []VolumeSnapshot{
+			{ID: "snap-new", CreatedAt: createdAt.Format(time.RFC3339), RetentionDays: 5, Size: 200},
+			{ID: "snap-daily", CreatedAt: createdAt.Add(time.Second).Format(time.RFC3339), RetentionDays: 5, Size: 100},
+		}
+		if err := json.NewEncoder(w).Encode(snapshots); err != nil {
+			t.Fatalf("failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{
+		token:   "test-token",
+		appName: "test-app",
+		region:  "sjc",
+		http:    server.Client(),
+	}
+
+	originalBaseURL := baseURL
+	baseURL = server.URL + "/v1"
+	defer func() { baseURL = originalBaseURL }()
+
+	snapshot, err := client.CreateSnapshot("vol-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if snapshot.ID != "snap-new" || snapshot.SizeBytes != 200 {
+		t.Errorf("expected the new snapshot, got %+v", snapshot)
+	}
+	if want := createdAt.AddDate(0, 0, 5); snapshot.ExpiresAt == nil || !snapshot.ExpiresAt.Equal(want) {
+		t.Errorf("expected expiry %v, got %v", want, snapshot.ExpiresAt)
+	}
+}
+
+func TestExec(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/apps/test-app/machines/m1/exec" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		var req ExecRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if len(req.Command) != 2 || req.Command[0] != "ls" || req.Timeout != 30 {
+			t.Errorf("unexpected exec request %+v", req)
+		}
+
+		if err := json.NewEncoder(w).Encode(ExecResponse{ExitCode: 2, Stderr: "ls: cannot access"}); err != nil {
+			t.Fatalf("failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{
+		token:   "test-token",
+		appName: "test-app",
+		region:  "sjc",
+		http:    server.Client(),
+	}
+
+	originalBaseURL := baseURL
+	baseURL = server.URL + "/v1"
+	defer func() { baseURL = originalBaseURL }()
+
+	result, err := client.Exec("m1", []string{"ls", "/missing"}, 30*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 2 || result.Stderr != "ls: cannot access" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
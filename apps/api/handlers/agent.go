@@ -22,14 +22,16 @@ type AgentHandler struct {
 	db             *db.Client
 	authMiddleware *authmw.AuthMiddleware
 	apiKeys        APIKeysGetter
+	projectEnv     ProjectEnvProvider
 }
 
-func NewAgentHandler(resolver ConnectionResolver, db *db.Client, authMiddleware *authmw.AuthMiddleware, apiKeys APIKeysGetter) *AgentHandler {
+func NewAgentHandler(resolver ConnectionResolver, db *db.Client, authMiddleware *authmw.AuthMiddleware, apiKeys APIKeysGetter, projectEnv ProjectEnvProvider) *AgentHandler {
 	return &AgentHandler{
 		resolver:       resolver,
 		db:             db,
 		authMiddleware: authMiddleware,
 		apiKeys:        apiKeys,
+		projectEnv:     projectEnv,
 	}
 }
 
@@ -108,6 +110,7 @@ type UsageInfo struct {
 	InputTokens  int     `json:"inputTokens"`
 	OutputTokens int     `json:"outputTokens"`
 	Cost         float64 `json:"cost"`
+	Model        string  `json:"model,omitempty"`
 }
 
 // HandleAgent handles WebSocket connections for the agent
@@ -120,7 +123,7 @@ func (h *AgentHandler) HandleAgent(w http.ResponseWriter, r *http.Request) {
 	ctx = logging.WithProjectID(ctx, projectID)
 
 	// Validate agent type
-	if agentType != "claude" && agentType != "codex" && agentType != "codebuff" && agentType != "opencode" {
+	if !agentTypes[agentType] {
 		http.Error(w, "Invalid agent type", http.StatusBadRequest)
 		return
 	}
@@ -159,6 +162,10 @@ func (h *AgentHandler) HandleAgent(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to get project", http.StatusInternalServerError)
 		return
 	}
+	if !db.RoleAtLeast(project.Role, roleOperate) {
+		http.Error(w, "Your role on this project doesn't allow this", http.StatusForbidden)
+		return
+	}
 
 	if project.Status != "running" {
 		http.Error(w, "Project is not running", http.StatusBadRequest)
@@ -193,7 +200,7 @@ func (h *AgentHandler) HandleAgent(w http.ResponseWriter, r *http.Request) {
 	log.Info("agent websocket connected", "agent", agentType)
 
 	// Build fresh env vars for the agent (includes user's latest API keys)
-	agentEnv := NewEnvBuilder(h.apiKeys).BuildAgentEnv(ctx, projectID, userID)
+	agentEnv := NewEnvBuilder(h.apiKeys, h.projectEnv).BuildAgentEnv(ctx, projectID, userID)
 
 	// Add correlation IDs for workspace-service logging
 	if requestID := logging.GetRequestID(ctx); requestID != "" {
@@ -226,18 +233,22 @@ func (h *AgentHandler) HandleAgent(w http.ResponseWriter, r *http.Request) {
 	log.Info("agent connector established")
 
 	// Bridge the frontend WebSocket with the VM connector
-	h.bridgeConnection(ctx, wsConn, connector)
+	h.bridgeConnection(ctx, wsConn, connector,
+		newUsageMeter(h.db, project, userID, agentType),
+		newBudgetGuard(h.db, userID, projectID, "", agentType))
 
 	log.Info("agent session ended")
 }
 
-// bridgeConnection bridges the frontend WebSocket with the VM ProxyConnector
-func (h *AgentHandler) bridgeConnection(ctx context.Context, wsConn *websocket.Conn, connector proxy.ProxyConnector) {
+// bridgeConnection bridges the frontend WebSocket with the VM ProxyConnector,
+// metering the token usage the agent reports on the way through and holding
+// back prompts once an agent budget is used up
+func (h *AgentHandler) bridgeConnection(ctx context.Context, wsConn *websocket.Conn, connector proxy.ProxyConnector, meter *usageMeter, budgets *budgetGuard) {
 	log := logging.FromContext(ctx)
 	var wg sync.WaitGroup
 	var wsMu sync.Mutex
 
-	// Connector -> WebSocket (forward raw messages from VM to frontend)
+	// Connector -> WebSocket (forward raw messages from VM to frontend, then meter them)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -259,6 +270,14 @@ func (h *AgentHandler) bridgeConnection(ctx context.Context, wsConn *websocket.C
 					log.Debug("websocket write error", "error", err)
 					return
 				}
+				if usage := meter.observe(ctx, data); usage != nil {
+					for _, notice := range budgets.afterUsage(ctx, usage) {
+						if err := writeWebSocketJSON(wsConn, &wsMu, notice); err != nil {
+							log.Debug("websocket write error", "error", err)
+							return
+						}
+					}
+				}
 			case <-connector.Done():
 				return
 			}
@@ -294,6 +313,15 @@ func (h *AgentHandler) bridgeConnection(ctx context.Context, wsConn *websocket.C
 				return
 			}
 
+			// Prompts over an agent budget go no further
+			if refusal := budgets.checkPrompt(ctx, data); refusal != nil {
+				if err := writeWebSocketJSON(wsConn, &wsMu, refusal); err != nil {
+					log.Debug("websocket write error", "error", err)
+					return
+				}
+				continue
+			}
+
 			if err := connector.Send(ctx, data); err != nil {
 				log.Debug("connector send error", "error", err)
 				return
@@ -0,0 +1,580 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aether/apps/api/budget"
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Agent messages about budgets. Prompts a budget refuses get an "error" message.
+const (
+	budgetWarningMessage  = "budget_warning"
+	budgetExceededMessage = "budget_exceeded"
+)
+
+var defaultBudgetThresholds = []int{50, 80}
+
+type CreateAgentBudgetRequest struct {
+	// ProjectID limits the budget to one project; without it the budget covers
+	// all of the user's agent spend
+	ProjectID       *string `json:"project_id,omitempty"`
+	MonthlyLimitUSD float64 `json:"monthly_limit_usd"`
+	// WarningThresholds are percentages of the limit; defaults to 50 and 80
+	WarningThresholds []int `json:"warning_thresholds,omitempty"`
+	// ResetDay is the day of the month spend starts over; defaults to 1
+	ResetDay *int `json:"reset_day,omitempty"`
+}
+
+// UpdateAgentBudgetRequest changes the fields that are set and leaves the rest
+// alone. An empty warning_thresholds turns warnings off.
+type UpdateAgentBudgetRequest struct {
+	MonthlyLimitUSD   *float64 `json:"monthly_limit_usd,omitempty"`
+	WarningThresholds []int    `json:"warning_thresholds,omitempty"`
+	ResetDay          *int     `json:"reset_day,omitempty"`
+}
+
+// AgentBudgetResponse is a budget with its spend this period
+type AgentBudgetResponse struct {
+	ID                string     `json:"id"`
+	Scope             string     `json:"scope"`
+	ProjectID         *string    `json:"project_id"`
+	MonthlyLimitUSD   float64    `json:"monthly_limit_usd"`
+	WarningThresholds []int      `json:"warning_thresholds"`
+	ResetDay          int        `json:"reset_day"`
+	ResetAt           *time.Time `json:"reset_at,omitempty"`
+	PeriodStart       time.Time  `json:"period_start"`
+	PeriodEnd         time.Time  `json:"period_end"`
+	SpentUSD          float64    `json:"spent_usd"`
+	RemainingUSD      float64    `json:"remaining_usd"`
+	Exceeded          bool       `json:"exceeded"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+type AgentBudgetListResponse struct {
+	Budgets []AgentBudgetResponse `json:"budgets"`
+}
+
+func budgetToResponse(s *budget.Status) AgentBudgetResponse {
+	b := s.Budget
+	return AgentBudgetResponse{
+		ID:                b.ID,
+		Scope:             budget.Scope(b),
+		ProjectID:         b.ProjectID,
+		MonthlyLimitUSD:   b.MonthlyLimitUSD,
+		WarningThresholds: b.WarningThresholds,
+		ResetDay:          b.ResetDay,
+		ResetAt:           b.ResetAt,
+		PeriodStart:       s.PeriodStart,
+		PeriodEnd:         s.PeriodEnd,
+		SpentUSD:          budget.Round(s.SpentUSD),
+		RemainingUSD:      s.RemainingUSD(),
+		Exceeded:          s.Exceeded(),
+		CreatedAt:         b.CreatedAt,
+	}
+}
+
+// budgetStatus reads what the budget has spent so far this period
+func budgetStatus(ctx context.Context, store AgentSpendGetter, b *db.AgentBudget, now time.Time) (*budget.Status, error) {
+	s := budget.NewStatus(b, now)
+	spent, err := store.GetAgentSpend(ctx, b, s.Since)
+	if err != nil {
+		return nil, err
+	}
+	s.SpentUSD = spent
+	return s, nil
+}
+
+// AgentBudgetHandler manages the monthly caps on what a user's agents spend
+type AgentBudgetHandler struct {
+	store AgentBudgetStore
+}
+
+func NewAgentBudgetHandler(store AgentBudgetStore) *AgentBudgetHandler {
+	return &AgentBudgetHandler{store: store}
+}
+
+func (h *AgentBudgetHandler) writeBudget(w http.ResponseWriter, r *http.Request, status int, b *db.AgentBudget) {
+	ctx := r.Context()
+	s, err := budgetStatus(ctx, h.store, b, time.Now())
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to get agent spend", "budget_id", b.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get agent budget")
+		return
+	}
+	WriteJSON(w, status, budgetToResponse(s))
+}
+
+// budgetID validates the route's budget ID. It writes the error response and
+// returns "" if it isn't valid.
+func budgetID(w http.ResponseWriter, r *http.Request) string {
+	id := chi.URLParam(r, "budgetId")
+	if err := validation.ValidateUUID(id, "budgetId"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return ""
+	}
+	return id
+}
+
+// List returns the user's budgets with what each has spent this period
+func (h *AgentBudgetHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	budgets, err := h.store.ListAgentBudgets(ctx, userID)
+	if err != nil {
+		log.Error("failed to list agent budgets", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list agent budgets")
+		return
+	}
+
+	now := time.Now()
+	response := AgentBudgetListResponse{Budgets: make([]AgentBudgetResponse, 0, len(budgets))}
+	for i := range budgets {
+		s, err := budgetStatus(ctx, h.store, &budgets[i], now)
+		if err != nil {
+			log.Error("failed to get agent spend", "budget_id", budgets[i].ID, "error", err)
+			WriteError(w, http.StatusInternalServerError, "Failed to list agent budgets")
+			return
+		}
+		response.Budgets = append(response.Budgets, budgetToResponse(s))
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// Create adds a budget for the user, or for one of their projects
+func (h *AgentBudgetHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	var req CreateAgentBudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	b := &db.AgentBudget{
+		UserID:            userID,
+		ProjectID:         req.ProjectID,
+		MonthlyLimitUSD:   req.MonthlyLimitUSD,
+		WarningThresholds: req.WarningThresholds,
+		ResetDay:          1,
+	}
+	if b.WarningThresholds == nil {
+		b.WarningThresholds = defaultBudgetThresholds
+	}
+	if req.ResetDay != nil {
+		b.ResetDay = *req.ResetDay
+	}
+
+	errs := validation.ValidateAgentBudget(b.MonthlyLimitUSD, b.WarningThresholds, b.ResetDay)
+	if b.ProjectID != nil {
+		if err := validation.ValidateUUID(*b.ProjectID, "project_id"); err != nil {
+			errs = append(errs, *err)
+		}
+	}
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+
+	// A project's budget caps everyone using the project
+	if b.ProjectID != nil {
+		project, err := h.store.GetProjectByUser(ctx, *b.ProjectID, userID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				WriteError(w, http.StatusNotFound, "Project not found")
+				return
+			}
+			log.Error("failed to get project for agent budget", "project_id", *b.ProjectID, "error", err)
+			WriteError(w, http.StatusInternalServerError, "Failed to create agent budget")
+			return
+		}
+		if !allowProject(w, project, roleManage) {
+			return
+		}
+	}
+
+	created, err := h.store.CreateAgentBudget(ctx, b)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrBudgetExists):
+			WriteError(w, http.StatusConflict, "A budget already exists for this "+budget.Scope(b))
+		case errors.Is(err, db.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "Project not found")
+		default:
+			log.Error("failed to create agent budget", "error", err)
+			WriteError(w, http.StatusInternalServerError, "Failed to create agent budget")
+		}
+		return
+	}
+
+	log.Info("agent budget created", "budget_id", created.ID, "scope", budget.Scope(created), "limit_usd", created.MonthlyLimitUSD)
+	h.writeBudget(w, r, http.StatusCreated, created)
+}
+
+func (h *AgentBudgetHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	id := budgetID(w, r)
+	if id == "" {
+		return
+	}
+
+	b, err := h.store.GetAgentBudget(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Budget not found")
+			return
+		}
+		log.Error("failed to get agent budget", "budget_id", id, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get agent budget")
+		return
+	}
+	h.writeBudget(w, r, http.StatusOK, b)
+}
+
+// Update changes a budget's limit, warnings or reset day. What it has spent
+// this period is kept.
+func (h *AgentBudgetHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	id := budgetID(w, r)
+	if id == "" {
+		return
+	}
+
+	var req UpdateAgentBudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	b, err := h.store.GetAgentBudget(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Budget not found")
+			return
+		}
+		log.Error("failed to get agent budget", "budget_id", id, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update agent budget")
+		return
+	}
+
+	if req.MonthlyLimitUSD != nil {
+		b.MonthlyLimitUSD = *req.MonthlyLimitUSD
+	}
+	if req.WarningThresholds != nil {
+		b.WarningThresholds = req.WarningThresholds
+	}
+	if req.ResetDay != nil {
+		b.ResetDay = *req.ResetDay
+	}
+
+	if errs := validation.ValidateAgentBudget(b.MonthlyLimitUSD, b.WarningThresholds, b.ResetDay); errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+
+	updated, err := h.store.UpdateAgentBudget(ctx, b)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Budget not found")
+			return
+		}
+		log.Error("failed to update agent budget", "budget_id", id, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update agent budget")
+		return
+	}
+	h.writeBudget(w, r, http.StatusOK, updated)
+}
+
+// Reset starts the budget's spend over from now, ahead of its reset day
+func (h *AgentBudgetHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	id := budgetID(w, r)
+	if id == "" {
+		return
+	}
+
+	b, err := h.store.ResetAgentBudget(ctx, id, userID, time.Now())
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Budget not found")
+			return
+		}
+		log.Error("failed to reset agent budget", "budget_id", id, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to reset agent budget")
+		return
+	}
+
+	log.Info("agent budget reset", "budget_id", id)
+	h.writeBudget(w, r, http.StatusOK, b)
+}
+
+func (h *AgentBudgetHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	id := budgetID(w, r)
+	if id == "" {
+		return
+	}
+
+	if err := h.store.DeleteAgentBudget(ctx, id, userID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Budget not found")
+			return
+		}
+		log.Error("failed to delete agent budget", "budget_id", id, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to delete agent budget")
+		return
+	}
+
+	log.Info("agent budget deleted", "budget_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BudgetNotice tells an agent client where spend stands against a budget: a
+// warning as it passes a threshold, a notice when it runs out, or an error
+// when a prompt is refused
+type BudgetNotice struct {
+	Channel string            `json:"channel,omitempty"`
+	Type    string            `json:"type"`
+	Agent   string            `json:"agent,omitempty"`
+	Content string            `json:"content,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Budget  *BudgetNoticeInfo `json:"budget,omitempty"`
+}
+
+type BudgetNoticeInfo struct {
+	ID        string    `json:"id"`
+	Scope     string    `json:"scope"`
+	ProjectID *string   `json:"project_id,omitempty"`
+	LimitUSD  float64   `json:"limit_usd"`
+	SpentUSD  float64   `json:"spent_usd"`
+	Threshold int       `json:"threshold,omitempty"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+func budgetNoticeInfo(s *budget.Status) *BudgetNoticeInfo {
+	return &BudgetNoticeInfo{
+		ID:        s.Budget.ID,
+		Scope:     budget.Scope(s.Budget),
+		ProjectID: s.Budget.ProjectID,
+		LimitUSD:  s.Budget.MonthlyLimitUSD,
+		SpentUSD:  budget.Round(s.SpentUSD),
+		ResetsAt:  s.PeriodEnd,
+	}
+}
+
+// agentClientMessage is the part of a client message the budget guard reads
+type agentClientMessage struct {
+	Channel string
+	Type    string
+	Agent   string
+}
+
+// nonAgentChannels are the workspace channels that never run prompts. The
+// workspace service hands a prompt on any other channel, or none, to an agent.
+var nonAgentChannels = map[string]bool{
+	"terminal": true,
+	"files":    true,
+	"ports":    true,
+}
+
+// parseAgentClientMessage reads a client message the way the workspace service
+// will: top-level keys are matched exactly, and a key given twice is rejected
+// rather than guessing which copy the other side keeps. Non-string channels
+// are kept as raw JSON so they never match a known channel.
+func parseAgentClientMessage(data []byte) (*agentClientMessage, error) {
+	if !json.Valid(data) {
+		return nil, errors.New("message is not valid JSON")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	msg := &agentClientMessage{}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		// Anything but an object has no channel or type to route on
+		return msg, nil
+	}
+
+	seen := make(map[string]bool)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, errors.New("message has a non-string key")
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("message has duplicate key %q", key)
+		}
+		seen[key] = true
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		var str string
+		isString := json.Unmarshal(value, &str) == nil
+		switch key {
+		case "channel":
+			if isString {
+				msg.Channel = str
+			} else {
+				msg.Channel = string(value)
+			}
+		case "type":
+			if isString {
+				msg.Type = str
+			}
+		case "agent":
+			if isString {
+				msg.Agent = str
+			}
+		}
+	}
+	return msg, nil
+}
+
+// budgetGuard enforces the agent budgets that cover one bridge connection: the
+// user's own and the project's. Budgets are read on every check, so changes
+// and resets apply at once.
+type budgetGuard struct {
+	store     AgentBudgetChecker
+	userID    string
+	projectID string
+	// channel is what the bridge's agent messages are tagged with, if anything
+	channel string
+	// agent is who a message is for when it doesn't say
+	agent string
+}
+
+func newBudgetGuard(store AgentBudgetChecker, userID, projectID, channel, agent string) *budgetGuard {
+	return &budgetGuard{store: store, userID: userID, projectID: projectID, channel: channel, agent: agent}
+}
+
+func (g *budgetGuard) statuses(ctx context.Context, now time.Time) ([]*budget.Status, error) {
+	budgets, err := g.store.GetAgentBudgetsFor(ctx, g.userID, g.projectID)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]*budget.Status, 0, len(budgets))
+	for i := range budgets {
+		s, err := budgetStatus(ctx, g.store, &budgets[i], now)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// checkPrompt returns the error to send back instead of forwarding a message
+// from the client, or nil to let it through. Only new prompts are refused, so
+// a running turn can still be approved or aborted. If budgets can't be read,
+// prompts are refused rather than let through unchecked, as are messages that
+// can't be parsed unambiguously.
+func (g *budgetGuard) checkPrompt(ctx context.Context, data []byte) *BudgetNotice {
+	msg, err := parseAgentClientMessage(data)
+	if err != nil {
+		// A message we can't read the same way the workspace service does might be
+		// a prompt in disguise
+		logging.FromContext(ctx).Debug("refused unreadable client message", "error", err)
+		return &BudgetNotice{Channel: g.channel, Type: "error", Agent: g.agent, Error: "Invalid message: " + err.Error()}
+	}
+	if msg.Type != "prompt" || nonAgentChannels[msg.Channel] {
+		return nil
+	}
+	agent := msg.Agent
+	if agent == "" {
+		agent = g.agent
+	}
+	notice := &BudgetNotice{Channel: g.channel, Type: "error", Agent: agent}
+
+	statuses, err := g.statuses(ctx, time.Now())
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to check agent budgets", "error", err)
+		notice.Error = "Couldn't check your agent budget; try again shortly"
+		return notice
+	}
+	for _, s := range statuses {
+		if s.Exceeded() {
+			logging.FromContext(ctx).Info("agent prompt refused over budget", "budget_id", s.Budget.ID, "spent_usd", s.SpentUSD)
+			notice.Error = (&budget.Exceeded{Status: s}).Error()
+			notice.Budget = budgetNoticeInfo(s)
+			return notice
+		}
+	}
+	return nil
+}
+
+// afterUsage returns a notice for each budget the usage took past a warning
+// threshold or its limit
+func (g *budgetGuard) afterUsage(ctx context.Context, usage *db.LLMUsage) []BudgetNotice {
+	if usage.CostUSD <= 0 {
+		return nil
+	}
+	statuses, err := g.statuses(ctx, time.Now())
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to check agent budgets", "error", err)
+		return nil
+	}
+
+	var notices []BudgetNotice
+	for _, s := range statuses {
+		info := budgetNoticeInfo(s)
+		notice := BudgetNotice{Channel: g.channel, Agent: usage.Agent, Budget: info}
+		before := s.SpentUSD - usage.CostUSD
+		switch {
+		case s.Exceeded() && before < s.Budget.MonthlyLimitUSD:
+			notice.Type = budgetExceededMessage
+			notice.Content = (&budget.Exceeded{Status: s}).Error() + "; new prompts are refused until then"
+		default:
+			threshold := s.Crossed(usage.CostUSD)
+			if threshold == 0 {
+				continue
+			}
+			info.Threshold = threshold
+			notice.Type = budgetWarningMessage
+			notice.Content = s.Warning(threshold)
+		}
+		notices = append(notices, notice)
+	}
+	return notices
+}
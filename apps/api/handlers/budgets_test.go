@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (m *mockProjectStore) CreateAgentBudget(ctx context.Context, budget *db.AgentBudget) (*db.AgentBudget, error) {
+	for _, b := range m.budgets {
+		if b.UserID == budget.UserID && b.ProjectID == nil && budget.ProjectID == nil {
+			return nil, db.ErrBudgetExists
+		}
+		if b.ProjectID != nil && budget.ProjectID != nil && *b.ProjectID == *budget.ProjectID {
+			return nil, db.ErrBudgetExists
+		}
+	}
+	created := *budget
+	created.ID = fmt.Sprintf("00000000-0000-4000-8000-%012d", len(m.budgets)+1)
+	created.CreatedAt = time.Now()
+	m.budgets[created.ID] = &created
+	return &created, nil
+}
+
+func (m *mockProjectStore) ListAgentBudgets(ctx context.Context, userID string) ([]db.AgentBudget, error) {
+	var result []db.AgentBudget
+	for _, b := range m.budgets {
+		if b.UserID == userID {
+			result = append(result, *b)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProjectStore) GetAgentBudgetsFor(ctx context.Context, userID, projectID string) ([]db.AgentBudget, error) {
+	var result []db.AgentBudget
+	for _, b := range m.budgets {
+		if (b.UserID == userID && b.ProjectID == nil) || (b.ProjectID != nil && *b.ProjectID == projectID) {
+			result = append(result, *b)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProjectStore) GetAgentBudget(ctx context.Context, budgetID, userID string) (*db.AgentBudget, error) {
+	b, ok := m.budgets[budgetID]
+	if !ok || b.UserID != userID {
+		return nil, db.ErrNotFound
+	}
+	copied := *b
+	return &copied, nil
+}
+
+func (m *mockProjectStore) UpdateAgentBudget(ctx context.Context, budget *db.AgentBudget) (*db.AgentBudget, error) {
+	b, ok := m.budgets[budget.ID]
+	if !ok || b.UserID != budget.UserID {
+		return nil, db.ErrNotFound
+	}
+	b.MonthlyLimitUSD = budget.MonthlyLimitUSD
+	b.WarningThresholds = budget.WarningThresholds
+	b.ResetDay = budget.ResetDay
+	copied := *b
+	return &copied, nil
+}
+
+func (m *mockProjectStore) ResetAgentBudget(ctx context.Context, budgetID, userID string, at time.Time) (*db.AgentBudget, error) {
+	b, ok := m.budgets[budgetID]
+	if !ok || b.UserID != userID {
+		return nil, db.ErrNotFound
+	}
+	b.ResetAt = &at
+	copied := *b
+	return &copied, nil
+}
+
+func (m *mockProjectStore) DeleteAgentBudget(ctx context.Context, budgetID, userID string) error {
+	b, ok := m.budgets[budgetID]
+	if !ok || b.UserID != userID {
+		return db.ErrNotFound
+	}
+	delete(m.budgets, budgetID)
+	return nil
+}
+
+func (m *mockProjectStore) GetAgentSpend(ctx context.Context, budget *db.AgentBudget, since time.Time) (float64, error) {
+	var spent float64
+	for _, u := range m.llmUsage {
+		if u.CreatedAt.Before(since) {
+			continue
+		}
+		if (budget.ProjectID == nil && u.UserID == budget.UserID) || (budget.ProjectID != nil && *u.ProjectID == *budget.ProjectID) {
+			spent += u.CostUSD
+		}
+	}
+	return spent, nil
+}
+
+func TestAgentBudgetHandler(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	projectID := testProjectID
+	store.llmUsage = []db.LLMUsage{
+		{UserID: "test-user-id", ProjectID: &projectID, Agent: "claude", CostUSD: 3, CreatedAt: time.Now()},
+	}
+	handler := NewAgentBudgetHandler(store)
+
+	router := chi.NewRouter()
+	router.Get("/user/agent-budgets", handler.List)
+	router.Post("/user/agent-budgets", handler.Create)
+	router.Get("/user/agent-budgets/{budgetId}", handler.Get)
+	router.Patch("/user/agent-budgets/{budgetId}", handler.Update)
+	router.Delete("/user/agent-budgets/{budgetId}", handler.Delete)
+	router.Post("/user/agent-budgets/{budgetId}/reset", handler.Reset)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		var data []byte
+		if body != "" {
+			data = []byte(body)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newAuthenticatedRequest(method, path, data))
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder) AgentBudgetResponse {
+		var b AgentBudgetResponse
+		if err := json.NewDecoder(rr.Body).Decode(&b); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return b
+	}
+
+	rr := serve("POST", "/user/agent-budgets", `{"monthly_limit_usd":10}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	userBudget := decode(rr)
+	if userBudget.Scope != "user" || userBudget.ResetDay != 1 || len(userBudget.WarningThresholds) != 2 || userBudget.SpentUSD != 3 || userBudget.RemainingUSD != 7 {
+		t.Errorf("expected a user budget with defaults and this month's spend, got %+v", userBudget)
+	}
+
+	rr = serve("POST", "/user/agent-budgets", `{"project_id":"`+testProjectID+`","monthly_limit_usd":2,"warning_thresholds":[],"reset_day":15}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	projectBudget := decode(rr)
+	if projectBudget.Scope != "project" || !projectBudget.Exceeded || len(projectBudget.WarningThresholds) != 0 || projectBudget.ResetDay != 15 {
+		t.Errorf("expected an exceeded project budget without warnings, got %+v", projectBudget)
+	}
+
+	rr = serve("PATCH", "/user/agent-budgets/"+projectBudget.ID, `{"monthly_limit_usd":5,"warning_thresholds":[50]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if updated := decode(rr); updated.Exceeded || updated.MonthlyLimitUSD != 5 || updated.ResetDay != 15 || len(updated.WarningThresholds) != 1 {
+		t.Errorf("expected the limit raised and the rest kept, got %+v", updated)
+	}
+
+	rr = serve("POST", "/user/agent-budgets/"+userBudget.ID+"/reset", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if reset := decode(rr); reset.ResetAt == nil || reset.SpentUSD != 0 {
+		t.Errorf("expected spend to start over after a reset, got %+v", reset)
+	}
+
+	rr = serve("GET", "/user/agent-budgets", "")
+	var list AgentBudgetListResponse
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil || len(list.Budgets) != 2 {
+		t.Errorf("expected both budgets listed, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := serve("DELETE", "/user/agent-budgets/"+userBudget.ID, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+
+	rejected := []struct {
+		method, path, body string
+		code               int
+	}{
+		{"POST", "/user/agent-budgets", `{"monthly_limit_usd":0}`, http.StatusBadRequest},
+		{"POST", "/user/agent-budgets", `{"monthly_limit_usd":10,"warning_thresholds":[80,50]}`, http.StatusBadRequest},
+		{"POST", "/user/agent-budgets", `{"monthly_limit_usd":10,"reset_day":31}`, http.StatusBadRequest},
+		{"POST", "/user/agent-budgets", `{"monthly_limit_usd":10,"project_id":"7c9e6679-7425-40de-944b-e07fc1f90ae7"}`, http.StatusNotFound},
+		{"POST", "/user/agent-budgets", `{"monthly_limit_usd":10,"project_id":"` + testProjectID + `"}`, http.StatusConflict},
+		{"PATCH", "/user/agent-budgets/" + projectBudget.ID, `{"warning_thresholds":[100]}`, http.StatusBadRequest},
+		{"GET", "/user/agent-budgets/" + userBudget.ID, "", http.StatusNotFound},
+		{"GET", "/user/agent-budgets/not-a-uuid", "", http.StatusBadRequest},
+	}
+	for _, tt := range rejected {
+		if rr := serve(tt.method, tt.path, tt.body); rr.Code != tt.code {
+			t.Errorf("%s %s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.body, tt.code, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestBudgetGuard(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	projectID := testProjectID
+	store.budgets["budget-1"] = &db.AgentBudget{ID: "budget-1", UserID: "test-user-id", ProjectID: &projectID, MonthlyLimitUSD: 10, WarningThresholds: []int{50, 80}, ResetDay: 1}
+	meter := newUsageMeter(store, store.projects[testProjectID], "test-user-id", defaultAgentType)
+	guard := newBudgetGuard(store, "test-user-id", testProjectID, "agent", defaultAgentType)
+	ctx := context.Background()
+
+	prompt := []byte(`{"channel":"agent","type":"prompt","agent":"claude","prompt":"hello"}`)
+	if refusal := guard.checkPrompt(ctx, prompt); refusal != nil {
+		t.Fatalf("expected a prompt under budget to go through, got %+v", refusal)
+	}
+
+	spend := func(cost float64) []BudgetNotice {
+		msg := fmt.Sprintf(`{"channel":"agent","type":"done","agent":"claude","usage":{"inputTokens":1,"outputTokens":1,"cost":%v}}`, cost)
+		usage := meter.observe(ctx, []byte(msg))
+		if usage == nil {
+			t.Fatalf("expected usage recorded for %s", msg)
+		}
+		return guard.afterUsage(ctx, usage)
+	}
+
+	if notices := spend(4); len(notices) != 0 {
+		t.Errorf("expected no warning at 40%%, got %+v", notices)
+	}
+	notices := spend(4.5)
+	if len(notices) != 1 || notices[0].Type != budgetWarningMessage || notices[0].Budget.Threshold != 80 || notices[0].Channel != "agent" {
+		t.Fatalf("expected one warning for passing 80%%, got %+v", notices)
+	}
+	if notices := spend(0.5); len(notices) != 0 {
+		t.Errorf("expected no repeat warning, got %+v", notices)
+	}
+	notices = spend(2)
+	if len(notices) != 1 || notices[0].Type != budgetExceededMessage || !strings.Contains(notices[0].Content, "this project's $10.00") {
+		t.Fatalf("expected a notice that the budget ran out, got %+v", notices)
+	}
+
+	refusal := guard.checkPrompt(ctx, prompt)
+	if refusal == nil || refusal.Type != "error" || refusal.Channel != "agent" || refusal.Budget == nil || refusal.Budget.ID != "budget-1" {
+		t.Fatalf("expected the prompt refused over budget, got %+v", refusal)
+	}
+	if !strings.HasPrefix(refusal.Error, "agent budget exceeded: $11.00") {
+		t.Errorf("unexpected refusal: %s", refusal.Error)
+	}
+
+	// Only new prompts are held back
+	for _, msg := range []string{
+		`{"channel":"agent","type":"abort","agent":"claude"}`,
+		`{"channel":"terminal","type":"input","data":"echo \"prompt\""}`,
+		`{"channel":"agent","type":"approve","toolId":"t1","prompt":"x"}`,
+	} {
+		if refusal := guard.checkPrompt(ctx, []byte(msg)); refusal != nil {
+			t.Errorf("expected %s to go through, got %+v", msg, refusal)
+		}
+	}
+
+	// Raising the limit lets prompts through again
+	store.budgets["budget-1"].MonthlyLimitUSD = 20
+	if refusal := guard.checkPrompt(ctx, prompt); refusal != nil {
+		t.Errorf("expected a prompt under the raised limit to go through, got %+v", refusal)
+	}
+}
+
+func TestBudgetGuard_RefusesDisguisedPrompts(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	store.budgets["budget-1"] = &db.AgentBudget{ID: "budget-1", UserID: "test-user-id", MonthlyLimitUSD: 1, ResetDay: 1}
+	store.llmUsage = append(store.llmUsage, db.LLMUsage{UserID: "test-user-id", CostUSD: 2, CreatedAt: time.Now()})
+	guard := newBudgetGuard(store, "test-user-id", testProjectID, "", defaultAgentType)
+	ctx := context.Background()
+
+	// The workspace service runs a prompt on any channel it doesn't know
+	for _, msg := range []string{
+		`{"channel":"x","type":"prompt","prompt":"hi"}`,
+		`{"channel":5,"type":"prompt","prompt":"hi"}`,
+		`{"type":"prompt","prompt":"hi"}`,
+		`{"type":"prompt","TYPE":"z","prompt":"hi"}`,
+		`{"CHANNEL":"terminal","type":"prompt","prompt":"hi"}`,
+		`{"type":"\u0070rompt","prompt":"hi"}`,
+		`{"\u0074ype":"prompt","prompt":"hi"}`,
+		`{"type":"abort","type":"prompt","prompt":"hi"}`,
+		`{"channel":"terminal","channel":"agent","type":"prompt","prompt":"hi"}`,
+		`{"type":"prompt"`,
+		`{"type":"input"}{"type":"prompt"}`,
+	} {
+		if refusal := guard.checkPrompt(ctx, []byte(msg)); refusal == nil || refusal.Type != "error" {
+			t.Errorf("expected %s refused, got %+v", msg, refusal)
+		}
+	}
+
+	// Channels that never reach an agent pass whatever their type
+	for _, msg := range []string{
+		`{"channel":"terminal","type":"prompt","data":"x"}`,
+		`{"channel":"files","type":"prompt"}`,
+		`{"channel":"ports","type":"prompt"}`,
+		`{"type":"Prompt"}`,
+		`"prompt"`,
+	} {
+		if refusal := guard.checkPrompt(ctx, []byte(msg)); refusal != nil {
+			t.Errorf("expected %s to go through, got %+v", msg, refusal)
+		}
+	}
+}
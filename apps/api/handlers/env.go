@@ -2,20 +2,22 @@ package handlers
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 )
 
 // EnvBuilder builds environment variables for machines and agents
 type EnvBuilder struct {
-	apiKeys APIKeysGetter
+	apiKeys    APIKeysGetter
+	projectEnv ProjectEnvProvider
 }
 
-// NewEnvBuilder creates a new EnvBuilder
-func NewEnvBuilder(apiKeys APIKeysGetter) *EnvBuilder {
-	return &EnvBuilder{apiKeys: apiKeys}
+// NewEnvBuilder creates a new EnvBuilder. Either source may be nil when
+// encryption isn't configured.
+func NewEnvBuilder(apiKeys APIKeysGetter, projectEnv ProjectEnvProvider) *EnvBuilder {
+	return &EnvBuilder{apiKeys: apiKeys, projectEnv: projectEnv}
 }
 
 // BuildEnv builds environment variables with common settings
@@ -47,6 +49,25 @@ func (b *EnvBuilder) BuildEnv(ctx context.Context, projectID, userID string, ext
 		}
 	}
 
+	// Project env vars are the most specific, so they win over user-level keys
+	for k, v := range b.ProjectEnv(ctx, projectID) {
+		env[k] = v
+	}
+	env["PROJECT_ID"] = projectID
+
+	return env
+}
+
+// ProjectEnv returns the project's own env vars, or nil if there are none
+func (b *EnvBuilder) ProjectEnv(ctx context.Context, projectID string) map[string]string {
+	if b.projectEnv == nil {
+		return nil
+	}
+	env, err := b.projectEnv.GetDecryptedEnv(ctx, projectID)
+	if err != nil {
+		log.Printf("Warning: failed to get env vars for project %s: %v", projectID, err)
+		return nil
+	}
 	return env
 }
 
@@ -68,11 +89,18 @@ func (b *EnvBuilder) BuildAgentEnv(ctx context.Context, projectID, userID string
 	return env
 }
 
-// ToEnvFileContent creates shell export statements from env vars
+// ToEnvFileContent creates shell export statements from env vars, sorted by name.
+// Values are single-quoted so the file can be sourced without expanding them.
 func ToEnvFileContent(env map[string]string) string {
+	names := make([]string, 0, len(env))
+	for k := range env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
 	var sb strings.Builder
-	for k, v := range env {
-		sb.WriteString(fmt.Sprintf("export %s=%q\n", k, v))
+	for _, k := range names {
+		sb.WriteString("export " + k + "=" + shellQuote(env[k]) + "\n")
 	}
 	return sb.String()
 }
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// versionETag formats a row's version counter as a strong entity tag
+func versionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersion reads the If-Match header as the version a conditional update
+// expects. It returns nil when the header is absent or "*", and ok=false when it
+// isn't a single entity tag. A weak or foreign tag returns version 0, which no row
+// has, so the update fails its precondition.
+func ifMatchVersion(r *http.Request) (version *int64, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+	if strings.Contains(header, ",") {
+		return nil, false
+	}
+
+	var v int64
+	if tag, found := strings.CutPrefix(header, `"`); found && strings.HasSuffix(tag, `"`) {
+		if n, err := strconv.ParseInt(strings.TrimSuffix(tag, `"`), 10, 64); err == nil && n > 0 {
+			v = n
+		}
+	} else if !strings.HasPrefix(header, `W/"`) {
+		return nil, false
+	}
+	return &v, true
+}
+
+// readIfMatch is ifMatchVersion for handlers, writing 400 for a bad header
+func readIfMatch(w http.ResponseWriter, r *http.Request) (*int64, bool) {
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		WriteError(w, http.StatusBadRequest, "If-Match must be a single ETag or *")
+	}
+	return version, ok
+}
+
+// checkIfMatch answers a conditional request whose resource is already loaded.
+// It writes 400 or 412 and returns false unless the request may go ahead.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, version int64) bool {
+	expected, ok := readIfMatch(w, r)
+	if !ok {
+		return false
+	}
+	if expected != nil && *expected != version {
+		writePreconditionFailed(w, version)
+		return false
+	}
+	return true
+}
+
+// writePreconditionFailed tells the client the resource changed and what its
+// current ETag is
+func writePreconditionFailed(w http.ResponseWriter, version int64) {
+	if version > 0 {
+		w.Header().Set("ETag", versionETag(version))
+	}
+	WriteError(w, http.StatusPreconditionFailed, "The resource was modified; reload it and try again")
+}
+
+// notModified handles If-None-Match on a GET, writing 304 and returning true if
+// the client's copy is current. The ETag is set on the response either way.
+func notModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	etag := versionETag(version)
+	w.Header().Set("ETag", etag)
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		// If-None-Match uses weak comparison
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type mockUserSettingsStore struct {
+	settings db.UserSettings
+}
+
+func (m *mockUserSettingsStore) GetUserSettings(ctx context.Context, userID string) (*db.UserSettings, error) {
+	s := m.settings
+	return &s, nil
+}
+
+func (m *mockUserSettingsStore) UpdateUserSettings(ctx context.Context, userID string, settings *db.UserSettings, ifVersion *int64) (*db.UserSettings, error) {
+	if ifVersion != nil && *ifVersion != m.settings.Version {
+		return nil, db.ErrVersionMismatch
+	}
+	version := m.settings.Version + 1
+	m.settings = *settings
+	m.settings.Version = version
+	s := m.settings
+	return &s, nil
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header string
+		want   *int64
+		ok     bool
+	}{
+		{"", nil, true},
+		{"*", nil, true},
+		{`"3"`, int64Ptr(3), true},
+		{`W/"3"`, int64Ptr(0), true},
+		{`"abc"`, int64Ptr(0), true},
+		{`"3", "4"`, nil, false},
+		{"3", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			req := httptest.NewRequest("PATCH", "/", nil)
+			req.Header.Set("If-Match", tt.header)
+			got, ok := ifMatchVersion(req)
+			if ok != tt.ok || (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("ifMatchVersion(%q) = %v, %v; want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func serveProject(handler *ProjectHandler, method, body string, headers map[string]string) *httptest.ResponseRecorder {
+	router := chi.NewRouter()
+	router.Get("/projects/{id}", handler.Get)
+	router.Patch("/projects/{id}", handler.Update)
+	router.Delete("/projects/{id}", handler.Delete)
+
+	var reqBody []byte
+	if body != "" {
+		reqBody = []byte(body)
+	}
+	req := newAuthenticatedRequest(method, "/projects/"+testProjectID, reqBody)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestProjectHandler_Get_NotModified(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	store.projects[testProjectID].Version = 4
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveProject(handler, "GET", "", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"4"` {
+		t.Fatalf("expected 200 with ETag \"4\", got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+
+	rr = serveProject(handler, "GET", "", map[string]string{"If-None-Match": `"4"`})
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("expected empty 304, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The project changed since the client's copy
+	store.projects[testProjectID].Version = 5
+	rr = serveProject(handler, "GET", "", map[string]string{"If-None-Match": `W/"4"`})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"5"` {
+		t.Errorf("expected 200 with ETag \"5\", got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+}
+
+func TestProjectHandler_Update_IfMatch(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	store.projects[testProjectID].Version = 2
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveProject(handler, "PATCH", `{"name":"first-tab"}`, map[string]string{"If-Match": `"2"`})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"3"` {
+		t.Fatalf("expected 200 with ETag \"3\", got %d %q: %s", rr.Code, rr.Header().Get("ETag"), rr.Body.String())
+	}
+
+	// A second tab still holding version 2 doesn't overwrite the first
+	rr = serveProject(handler, "PATCH", `{"name":"second-tab"}`, map[string]string{"If-Match": `"2"`})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d, got %d", http.StatusPreconditionFailed, rr.Code)
+	}
+	if name := store.projects[testProjectID].Name; name != "first-tab" {
+		t.Errorf("expected first tab's name kept, got %q", name)
+	}
+
+	// Unconditional updates still apply
+	rr = serveProject(handler, "PATCH", `{"name":"no-precondition"}`, nil)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	rr = serveProject(handler, "PATCH", `{"name":"x"}`, map[string]string{"If-Match": `"1", "2"`})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an ETag list, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestProjectHandler_Update_IfMatchAfterStart(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	store.projects[testProjectID].Version = 2
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveProject(handler, "GET", "", nil)
+	etag := rr.Header().Get("ETag")
+
+	// Starting changes the status and touches last access, which aren't edits
+	runTestOperation(t, store, handler, db.OperationStart, []string{db.StatusStopped}, db.StatusStarting)
+	if err := store.UpdateProjectLastAccessed(context.Background(), testProjectID); err != nil {
+		t.Fatal(err)
+	}
+
+	rr = serveProject(handler, "PATCH", `{"name":"renamed"}`, map[string]string{"If-Match": etag})
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected the edit to apply after bookkeeping updates, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestProjectHandler_Delete_IfMatch(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	store.projects[testProjectID].Version = 7
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveProject(handler, "DELETE", "", map[string]string{"If-Match": `"6"`})
+	if rr.Code != http.StatusPreconditionFailed || rr.Header().Get("ETag") != `"7"` {
+		t.Fatalf("expected 412 with current ETag, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	if status := store.projects[testProjectID].Status; status != db.StatusStopped {
+		t.Errorf("expected project left alone, got %s", status)
+	}
+
+	rr = serveProject(handler, "DELETE", "", map[string]string{"If-Match": `"7"`})
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func TestUserSettingsHandler_ETags(t *testing.T) {
+	store := &mockUserSettingsStore{settings: db.UserSettings{
+		UserID:              "test-user-id",
+		DefaultCPUKind:      "shared",
+		DefaultCPUs:         1,
+		DefaultMemoryMB:     1024,
+		DefaultVolumeSizeGB: 5,
+		Version:             1,
+	}}
+	handler := NewUserSettingsHandler(store)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := newAuthenticatedRequest("GET", "/user/settings", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		handler.Get(rr, req)
+		return rr
+	}
+	update := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req := newAuthenticatedRequest("PUT", "/user/settings", []byte(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		handler.Update(rr, req)
+		return rr
+	}
+
+	if rr := get(""); rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"1"` {
+		t.Fatalf("expected 200 with ETag \"1\", got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	if rr := get(`"1"`); rr.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, rr.Code)
+	}
+
+	if rr := update(`"1"`, `{"default_idle_timeout_minutes":30}`); rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 200 with ETag \"2\", got %d %q: %s", rr.Code, rr.Header().Get("ETag"), rr.Body.String())
+	}
+	if rr := update(`"1"`, `{"default_idle_timeout_minutes":60}`); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d for a stale ETag, got %d", http.StatusPreconditionFailed, rr.Code)
+	}
+	if got := store.settings.DefaultIdleTimeoutMinutes; got == nil || *got != 30 {
+		t.Errorf("expected the first update kept, got %v", got)
+	}
+	if rr := get(`"1"`); rr.Code != http.StatusOK {
+		t.Errorf("expected status %d after the settings changed, got %d", http.StatusOK, rr.Code)
+	}
+}
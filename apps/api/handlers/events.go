@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/libs/go/logging"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// eventBatchSize caps how many events are read from the log per query
+	eventBatchSize = 500
+	// eventBufferSize is how many undelivered events a subscriber may queue
+	// before it is dropped and has to reconnect with Last-Event-ID
+	eventBufferSize = 64
+	// eventRetention is how long events stay resumable
+	eventRetention = 7 * 24 * time.Hour
+	// eventGapTimeout is how long the broker waits for a missing event ID to
+	// commit before giving up on it. IDs are taken when events are inserted, not
+	// when they commit, so a slow transaction can commit an ID below ones already
+	// delivered; a rolled back one leaves a gap that never fills.
+	eventGapTimeout = time.Minute
+	// sseKeepAlive keeps proxies from closing idle event streams
+	sseKeepAlive = 25 * time.Second
+)
+
+// EventBroker tails the project event log and fans events out to subscribers.
+// The log lives in the database, so events written by any replica reach
+// subscribers on every replica.
+type EventBroker struct {
+	store EventStore
+	mu    sync.Mutex
+	// subscribers holds the channels listening to each audience: a user, for
+	// their personal projects, or an organization
+	subscribers map[string]map[chan db.ProjectEvent]struct{}
+	audiences   map[chan db.ProjectEvent][]string
+
+	// Every event up to settledID has been delivered or given up on. Above it,
+	// delivered holds what has already gone out and missing when each gap in
+	// the IDs was first seen. Only the polling goroutine uses these.
+	settledID int64
+	delivered map[int64]struct{}
+	missing   map[int64]time.Time
+}
+
+func NewEventBroker(store EventStore) *EventBroker {
+	return &EventBroker{
+		store:       store,
+		subscribers: make(map[string]map[chan db.ProjectEvent]struct{}),
+		audiences:   make(map[chan db.ProjectEvent][]string),
+		delivered:   make(map[int64]struct{}),
+		missing:     make(map[int64]time.Time),
+	}
+}
+
+// orgAudience keys an organization's subscribers apart from users'
+func orgAudience(orgID string) string {
+	return "org:" + orgID
+}
+
+// eventAudience is who receives an event: its project's organization if it
+// had one, otherwise the project's owner
+func eventAudience(e db.ProjectEvent) string {
+	if e.OrganizationID != nil {
+		return orgAudience(*e.OrganizationID)
+	}
+	return e.UserID
+}
+
+// Start begins polling the event log. Only events written after Start are broadcast;
+// older ones are available to clients through resume.
+func (b *EventBroker) Start(interval time.Duration) {
+	log := logging.Default()
+	ctx := context.Background()
+
+	lastID, err := b.store.GetLatestProjectEventID(ctx)
+	if err != nil {
+		log.Error("failed to get latest project event", "error", err)
+	}
+	b.settledID = lastID
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		lastPrune := time.Now()
+		for range ticker.C {
+			b.poll(ctx)
+
+			if time.Since(lastPrune) > time.Hour {
+				lastPrune = time.Now()
+				if n, err := b.store.DeleteProjectEventsBefore(ctx, time.Now().Add(-eventRetention)); err != nil {
+					log.Error("failed to prune project events", "error", err)
+				} else if n > 0 {
+					log.Info("pruned project events", "count", n)
+				}
+			}
+		}
+	}()
+}
+
+// poll publishes the events committed since the last poll. It reads from the
+// settled ID rather than the highest one delivered, so an event that commits
+// after a higher ID was already published is still picked up, and skips what
+// it has already delivered.
+func (b *EventBroker) poll(ctx context.Context) {
+	now := time.Now()
+	afterID := b.settledID
+	for {
+		events, err := b.store.ListProjectEventsSince(ctx, afterID, eventBatchSize)
+		if err != nil {
+			logging.Default().Error("failed to poll project events", "error", err)
+			return
+		}
+		for _, e := range events {
+			// Note the IDs skipped over in case they are still to commit
+			for id := afterID + 1; id < e.ID; id++ {
+				if _, ok := b.delivered[id]; !ok {
+					if _, ok := b.missing[id]; !ok {
+						b.missing[id] = now
+					}
+				}
+			}
+			afterID = e.ID
+
+			if _, ok := b.delivered[e.ID]; ok {
+				continue
+			}
+			delete(b.missing, e.ID)
+			b.delivered[e.ID] = struct{}{}
+			b.publish(e)
+		}
+		if len(events) < eventBatchSize {
+			break
+		}
+	}
+	b.settle(now)
+}
+
+// settle advances the settled ID past delivered events and past gaps that
+// have waited longer than eventGapTimeout. An event committing later than that
+// is still in the log for clients that resume, but isn't delivered live.
+func (b *EventBroker) settle(now time.Time) {
+	for {
+		next := b.settledID + 1
+		if _, ok := b.delivered[next]; ok {
+			delete(b.delivered, next)
+		} else if seen, ok := b.missing[next]; ok && now.Sub(seen) > eventGapTimeout {
+			delete(b.missing, next)
+		} else {
+			return
+		}
+		b.settledID = next
+	}
+}
+
+// publish delivers an event to its audience's subscribers. Subscribers that
+// can't keep up are closed so they reconnect and resume from the log.
+func (b *EventBroker) publish(e db.ProjectEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[eventAudience(e)] {
+		select {
+		case ch <- e:
+		default:
+			b.remove(ch)
+		}
+	}
+}
+
+// Subscribe returns a channel that receives the events for the user's personal
+// projects and the given organizations' projects until Unsubscribe is called or
+// the subscriber falls too far behind, in which case it is closed
+func (b *EventBroker) Subscribe(userID string, orgIDs ...string) chan db.ProjectEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan db.ProjectEvent, eventBufferSize)
+	audiences := []string{userID}
+	for _, id := range orgIDs {
+		audiences = append(audiences, orgAudience(id))
+	}
+	for _, a := range audiences {
+		if b.subscribers[a] == nil {
+			b.subscribers[a] = make(map[chan db.ProjectEvent]struct{})
+		}
+		b.subscribers[a][ch] = struct{}{}
+	}
+	b.audiences[ch] = audiences
+	return ch
+}
+
+func (b *EventBroker) Unsubscribe(ch chan db.ProjectEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(ch)
+}
+
+// remove drops a subscriber from all its audiences and closes it. It must be
+// called with the lock held; removing a channel twice does nothing.
+func (b *EventBroker) remove(ch chan db.ProjectEvent) {
+	audiences, ok := b.audiences[ch]
+	if !ok {
+		return
+	}
+	for _, a := range audiences {
+		delete(b.subscribers[a], ch)
+		if len(b.subscribers[a]) == 0 {
+			delete(b.subscribers, a)
+		}
+	}
+	delete(b.audiences, ch)
+	close(ch)
+}
+
+// EventsHandler streams a user's project lifecycle events over SSE or WebSocket
+type EventsHandler struct {
+	broker         *EventBroker
+	store          EventStore
+	authMiddleware *authmw.AuthMiddleware
+}
+
+func NewEventsHandler(broker *EventBroker, store EventStore, authMiddleware *authmw.AuthMiddleware) *EventsHandler {
+	return &EventsHandler{
+		broker:         broker,
+		store:          store,
+		authMiddleware: authMiddleware,
+	}
+}
+
+// authenticate resolves the user from the context, a bearer token, or ?token=
+// (browsers can't set headers on EventSource or WebSocket connections)
+func (h *EventsHandler) authenticate(r *http.Request) (string, error) {
+	if userID := authmw.GetUserID(r.Context()); userID != "" {
+		return userID, nil
+	}
+	token := ExtractTokenFromRequest(r)
+	if token == "" {
+		return "", fmt.Errorf("missing token")
+	}
+	return h.authMiddleware.ValidateToken(token)
+}
+
+// lastEventID reads the resume cursor from the Last-Event-ID header or ?last_event_id=
+func lastEventID(r *http.Request) (int64, bool) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// subscribe registers for live events, then replays anything after the resume
+// cursor. Subscribing first means no event can fall between replay and live delivery.
+func (h *EventsHandler) subscribe(ctx context.Context, r *http.Request, userID string) (chan db.ProjectEvent, []db.ProjectEvent, error) {
+	orgIDs, err := h.store.ListOrganizationIDs(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch := h.broker.Subscribe(userID, orgIDs...)
+
+	afterID, ok := lastEventID(r)
+	if !ok {
+		return ch, nil, nil
+	}
+
+	var backlog []db.ProjectEvent
+	for {
+		events, err := h.store.ListUserProjectEventsSince(ctx, userID, afterID, eventBatchSize)
+		if err != nil {
+			h.broker.Unsubscribe(ch)
+			return nil, nil, err
+		}
+		backlog = append(backlog, events...)
+		if len(events) < eventBatchSize {
+			return ch, backlog, nil
+		}
+		afterID = events[len(events)-1].ID
+	}
+}
+
+// replay sends the backlog and returns the IDs it sent, which may arrive again
+// live since the subscription started first. It returns nil if a send fails.
+func replay(backlog []db.ProjectEvent, send func(db.ProjectEvent) error) map[int64]bool {
+	replayed := make(map[int64]bool, len(backlog))
+	for _, e := range backlog {
+		if err := send(e); err != nil {
+			return nil
+		}
+		replayed[e.ID] = true
+	}
+	return replayed
+}
+
+// Stream serves the event stream as Server-Sent Events
+func (h *EventsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	userID, err := h.authenticate(r)
+	if err != nil {
+		WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	ch, backlog, err := h.subscribe(ctx, r, userID)
+	if err != nil {
+		log.Error("failed to load project events", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to load events")
+		return
+	}
+	defer h.broker.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(e db.ProjectEvent) error {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, payload); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	replayed := replay(backlog, send)
+	if replayed == nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				// Fell behind; the client reconnects and resumes from the last event it got
+				return
+			}
+			if replayed[e.ID] {
+				continue
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// HandleWebSocket serves the event stream over a WebSocket. Each message is a
+// JSON-encoded event; resume with ?last_event_id=.
+func (h *EventsHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	userID, err := h.authenticate(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ch, backlog, err := h.subscribe(ctx, r, userID)
+	if err != nil {
+		log.Error("failed to load project events", "error", err)
+		http.Error(w, "Failed to load events", http.StatusInternalServerError)
+		return
+	}
+	defer h.broker.Unsubscribe(ch)
+
+	responseHeader := http.Header{}
+	if websocket.Subprotocols(r) != nil {
+		responseHeader.Set("Sec-WebSocket-Protocol", "bearer")
+	}
+
+	conn, err := upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		log.Error("websocket upgrade failed", "error", err)
+		return
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Debug("failed to close websocket", "error", err)
+		}
+	}()
+
+	// The reader only handles control frames and notices the client leaving
+	done := make(chan struct{})
+	conn.SetReadLimit(maxMessageSize)
+	if err := conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+		return
+	}
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(e db.ProjectEvent) error {
+		if err := conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+			return err
+		}
+		return conn.WriteJSON(e)
+	}
+
+	replayed := replay(backlog, send)
+	if replayed == nil {
+		return
+	}
+
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case e, ok := <-ch:
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber fell behind"),
+					time.Now().Add(writeWait))
+				return
+			}
+			if replayed[e.ID] {
+				continue
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+)
+
+type mockEventStore struct {
+	events []db.ProjectEvent
+	// orgs lists the organizations each user belongs to
+	orgs map[string][]string
+}
+
+func (m *mockEventStore) ListProjectEventsSince(ctx context.Context, afterID int64, limit int) ([]db.ProjectEvent, error) {
+	events := slices.Clone(m.events)
+	slices.SortFunc(events, func(a, b db.ProjectEvent) int { return cmp.Compare(a.ID, b.ID) })
+	var result []db.ProjectEvent
+	for _, e := range events {
+		if e.ID > afterID && len(result) < limit {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockEventStore) ListUserProjectEventsSince(ctx context.Context, userID string, afterID int64, limit int) ([]db.ProjectEvent, error) {
+	var result []db.ProjectEvent
+	for _, e := range m.events {
+		if e.ID > afterID && len(result) < limit && m.receives(userID, e) {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockEventStore) receives(userID string, e db.ProjectEvent) bool {
+	if e.OrganizationID == nil {
+		return e.UserID == userID
+	}
+	return slices.Contains(m.orgs[userID], *e.OrganizationID)
+}
+
+// record appends an event stamped with the project's owner, organization and status
+func (m *mockEventStore) record(p *db.Project, eventType string) {
+	status := p.Status
+	m.events = append(m.events, db.ProjectEvent{
+		ID:             int64(len(m.events) + 1),
+		ProjectID:      p.ID,
+		UserID:         p.UserID,
+		OrganizationID: p.OrganizationID,
+		Type:           eventType,
+		Status:         &status,
+	})
+}
+
+func (m *mockEventStore) ListOrganizationIDs(ctx context.Context, userID string) ([]string, error) {
+	return m.orgs[userID], nil
+}
+
+func (m *mockEventStore) GetLatestProjectEventID(ctx context.Context) (int64, error) {
+	if len(m.events) == 0 {
+		return 0, nil
+	}
+	return m.events[len(m.events)-1].ID, nil
+}
+
+func (m *mockEventStore) DeleteProjectEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestEventBroker_DeliversOnlyToOwner(t *testing.T) {
+	store := &mockEventStore{}
+	broker := NewEventBroker(store)
+
+	mine := broker.Subscribe("user-1")
+	theirs := broker.Subscribe("user-2")
+	defer broker.Unsubscribe(mine)
+	defer broker.Unsubscribe(theirs)
+
+	store.events = []db.ProjectEvent{{ID: 1, UserID: "user-1", ProjectID: "p1", Type: db.EventStatus}}
+	broker.poll(context.Background())
+
+	select {
+	case e := <-mine:
+		if e.ID != 1 {
+			t.Errorf("expected event 1, got %d", e.ID)
+		}
+	default:
+		t.Fatal("expected event for user-1")
+	}
+
+	select {
+	case e := <-theirs:
+		t.Errorf("user-2 received event %d", e.ID)
+	default:
+	}
+}
+
+func TestEventBroker_DeliversOrganizationEventsToMembers(t *testing.T) {
+	store := &mockEventStore{}
+	broker := NewEventBroker(store)
+
+	creator := broker.Subscribe("user-1", "org-1")
+	teammate := broker.Subscribe("user-2", "org-1")
+	outsider := broker.Subscribe("user-3")
+	defer broker.Unsubscribe(creator)
+	defer broker.Unsubscribe(teammate)
+	defer broker.Unsubscribe(outsider)
+
+	org := "org-1"
+	store.events = []db.ProjectEvent{
+		{ID: 1, UserID: "user-1", OrganizationID: &org, ProjectID: "p1", Type: db.EventStatus},
+		{ID: 2, UserID: "user-2", ProjectID: "p2", Type: db.EventStatus},
+	}
+	broker.poll(context.Background())
+
+	for name, ch := range map[string]chan db.ProjectEvent{"creator": creator, "teammate": teammate} {
+		select {
+		case e := <-ch:
+			if e.ID != 1 {
+				t.Errorf("expected %s to get event 1, got %d", name, e.ID)
+			}
+		default:
+			t.Errorf("expected the organization event for %s", name)
+		}
+	}
+
+	// The teammate's personal project event reaches only the teammate
+	select {
+	case e := <-teammate:
+		if e.ID != 2 {
+			t.Errorf("expected event 2, got %d", e.ID)
+		}
+	default:
+		t.Error("expected the teammate's own event")
+	}
+	select {
+	case e := <-creator:
+		t.Errorf("creator received event %d", e.ID)
+	case e := <-outsider:
+		t.Errorf("outsider received event %d", e.ID)
+	default:
+	}
+}
+
+func TestEventBroker_DropsSlowSubscriber(t *testing.T) {
+	store := &mockEventStore{}
+	broker := NewEventBroker(store)
+	ch := broker.Subscribe("user-1")
+
+	for i := int64(1); i <= eventBufferSize+1; i++ {
+		store.events = append(store.events, db.ProjectEvent{ID: i, UserID: "user-1", Type: db.EventStatus})
+	}
+	broker.poll(context.Background())
+
+	received := 0
+	for range ch {
+		received++
+	}
+	if received != eventBufferSize {
+		t.Errorf("expected %d buffered events before close, got %d", eventBufferSize, received)
+	}
+
+	// Unsubscribing after the broker dropped the channel must not panic
+	broker.Unsubscribe(ch)
+}
+
+func TestEventBroker_DeliversEventsCommittedOutOfOrder(t *testing.T) {
+	store := &mockEventStore{}
+	broker := NewEventBroker(store)
+	ch := broker.Subscribe("user-1")
+	defer broker.Unsubscribe(ch)
+
+	received := func() []int64 {
+		var ids []int64
+		for {
+			select {
+			case e := <-ch:
+				ids = append(ids, e.ID)
+			default:
+				return ids
+			}
+		}
+	}
+
+	// Event 2's transaction commits after event 3's
+	store.events = []db.ProjectEvent{
+		{ID: 1, UserID: "user-1", Type: db.EventStatus},
+		{ID: 3, UserID: "user-1", Type: db.EventStatus},
+	}
+	broker.poll(context.Background())
+	if ids := received(); !slices.Equal(ids, []int64{1, 3}) {
+		t.Fatalf("expected events 1 and 3, got %v", ids)
+	}
+
+	store.events = append(store.events, db.ProjectEvent{ID: 2, UserID: "user-1", Type: db.EventStatus})
+	broker.poll(context.Background())
+	if ids := received(); !slices.Equal(ids, []int64{2}) {
+		t.Fatalf("expected only the late event 2, got %v", ids)
+	}
+	if broker.settledID != 3 || len(broker.delivered) != 0 || len(broker.missing) != 0 {
+		t.Errorf("expected everything settled through 3, got %d %v %v", broker.settledID, broker.delivered, broker.missing)
+	}
+
+	broker.poll(context.Background())
+	if ids := received(); len(ids) != 0 {
+		t.Errorf("expected nothing redelivered, got %v", ids)
+	}
+}
+
+func TestEventBroker_GivesUpOnGapsThatNeverFill(t *testing.T) {
+	store := &mockEventStore{events: []db.ProjectEvent{
+		{ID: 1, UserID: "user-1", Type: db.EventStatus},
+		{ID: 3, UserID: "user-1", Type: db.EventStatus},
+	}}
+	broker := NewEventBroker(store)
+	broker.poll(context.Background())
+	if broker.settledID != 1 {
+		t.Fatalf("expected to wait on event 2, settled through %d", broker.settledID)
+	}
+
+	// Event 2 was rolled back
+	broker.settle(time.Now().Add(eventGapTimeout + time.Second))
+	if broker.settledID != 3 || len(broker.delivered) != 0 || len(broker.missing) != 0 {
+		t.Errorf("expected the gap given up on and everything settled through 3, got %d %v %v", broker.settledID, broker.delivered, broker.missing)
+	}
+}
+
+func TestEventsHandler_StreamResumesFromLastEventID(t *testing.T) {
+	running := "running"
+	store := &mockEventStore{events: []db.ProjectEvent{
+		{ID: 1, UserID: "test-user-id", ProjectID: "p1", Type: db.EventStatus},
+		{ID: 2, UserID: "other-user", ProjectID: "p2", Type: db.EventStatus},
+		{ID: 3, UserID: "test-user-id", ProjectID: "p1", Type: db.EventStatus, Status: &running},
+	}}
+	handler := NewEventsHandler(NewEventBroker(store), store, nil)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), authmw.UserIDKey, "test-user-id"))
+	req := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "1")
+	rr := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		handler.Stream(rr, req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	body := rr.Body.String()
+	if rr.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %s", rr.Header().Get("Content-Type"))
+	}
+	if strings.Contains(body, "id: 1\n") || strings.Contains(body, "id: 2\n") {
+		t.Errorf("stream replayed events it should have skipped:\n%s", body)
+	}
+	if !strings.Contains(body, "id: 3\nevent: status\n") || !strings.Contains(body, `"status":"running"`) {
+		t.Errorf("stream missing resumed event:\n%s", body)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/quota"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ForkProjectRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// Fork creates a new stopped project with the source's configuration and a copy of its volume.
+// Running sources are forked crash-consistently; stop the project first for a clean copy.
+func (h *ProjectHandler) Fork(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	// The body is optional; an empty one forks with default name and description
+	var req ForkProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	input, errs := validation.ValidateUpdateProject(req.Name, req.Description)
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+
+	source, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for fork", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to fork project")
+		return
+	}
+	if !allowProject(w, source, roleOperate) {
+		return
+	}
+
+	// Mid-transition volumes may be detached or half torn down
+	if source.Status != db.StatusStopped && source.Status != db.StatusRunning {
+		WriteJSON(w, http.StatusConflict, map[string]any{
+			"error":          "Project can only be forked while stopped or running",
+			"current_status": source.Status,
+		})
+		return
+	}
+
+	releaseQuota, err := quota.Reserve(ctx, h.store, userID, source.OrganizationID, quota.ForNewProject(source.VolumeSizeGB, source.GPUKind))
+	if err != nil {
+		h.writeTransitionError(w, log, err, "fork")
+		return
+	}
+
+	// Default to "<name>-fork", trimmed so it stays a valid project name
+	name := source.Name
+	if len(name) > 95 {
+		name = name[:95]
+	}
+	name += "-fork"
+	if input.Name != nil {
+		name = *input.Name
+	}
+
+	fork, err := h.store.ForkProject(ctx, projectID, userID, name, input.Description)
+	releaseQuota()
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to fork project", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to fork project")
+		return
+	}
+
+	// A source that was never started has no volume; the fork gets a fresh one on first start
+	if source.FlyVolumeID != nil && *source.FlyVolumeID != "" {
+		volume, err := h.volumes.ForkVolume(*source.FlyVolumeID, "vol_"+fork.ID[:8])
+		if err != nil {
+			log.Error("failed to fork volume", "project_id", projectID, "volume_id", *source.FlyVolumeID, "error", err)
+			if err := h.store.DeleteProject(ctx, fork.ID, userID); err != nil {
+				log.Error("failed to remove fork after volume failure", "fork_id", fork.ID, "error", err)
+			}
+			WriteError(w, http.StatusInternalServerError, "Failed to copy project volume")
+			return
+		}
+
+		if err := h.store.UpdateProjectVolume(ctx, fork.ID, volume.ID); err != nil {
+			log.Error("failed to update fork volume ID", "fork_id", fork.ID, "volume_id", volume.ID, "error", err)
+			if err := h.volumes.DeleteVolume(volume.ID); err != nil {
+				log.Error("failed to delete forked volume", "volume_id", volume.ID, "error", err)
+			}
+			if err := h.store.DeleteProject(ctx, fork.ID, userID); err != nil {
+				log.Error("failed to remove fork after volume failure", "fork_id", fork.ID, "error", err)
+			}
+			WriteError(w, http.StatusInternalServerError, "Failed to fork project")
+			return
+		}
+		fork.FlyVolumeID = &volume.ID
+	}
+
+	log.Info("project forked", "project_id", projectID, "fork_id", fork.ID)
+	WriteJSON(w, http.StatusCreated, projectToResponse(fork))
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+func (m *mockProjectStore) ForkProject(ctx context.Context, sourceID, userID, name string, description *string) (*db.Project, error) {
+	source, ok := m.projects[sourceID]
+	if !ok || m.roleOn(source, userID) == "" {
+		return nil, db.ErrNotFound
+	}
+	if description == nil {
+		description = source.Description
+	}
+	fork := &db.Project{
+		ID:              "660e8400-e29b-41d4-a716-446655440000",
+		UserID:          userID,
+		OrganizationID:  source.OrganizationID,
+		Name:            name,
+		Description:     description,
+		Status:          db.StatusStopped,
+		CPUKind:         source.CPUKind,
+		CPUs:            source.CPUs,
+		MemoryMB:        source.MemoryMB,
+		VolumeSizeGB:    source.VolumeSizeGB,
+		ParentProjectID: &source.ID,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	fork.Role = m.roleOn(fork, userID)
+	m.projects[fork.ID] = fork
+	return fork, nil
+}
+
+func TestProjectHandler_Fork(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	volumes := newMockVolumeManager()
+	var forkedFrom, forkName string
+	volumes.forkFn = func(sourceVolumeID, name string) (*Volume, error) {
+		forkedFrom, forkName = sourceVolumeID, name
+		return &Volume{ID: "vol-fork", Name: name}, nil
+	}
+	handler := NewProjectHandler(store, newMockMachineManager(), volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.Fork, "POST", "/projects/{id}/fork", "/projects/"+testProjectID+"/fork", nil)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var response ProjectResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Name != "my-project-fork" {
+		t.Errorf("expected default fork name, got %q", response.Name)
+	}
+	if response.Status != db.StatusStopped {
+		t.Errorf("expected fork to be stopped, got %s", response.Status)
+	}
+	if response.ParentProjectID == nil || *response.ParentProjectID != testProjectID {
+		t.Errorf("expected parent_project_id %s, got %v", testProjectID, response.ParentProjectID)
+	}
+	if response.Hardware.CPUs != 1 || response.Hardware.MemoryMB != 1024 {
+		t.Errorf("expected hardware copied from source, got %+v", response.Hardware)
+	}
+
+	if forkedFrom != "vol-123" || forkName != "vol_"+response.ID[:8] {
+		t.Errorf("expected vol-123 forked as vol_%s, got %s as %s", response.ID[:8], forkedFrom, forkName)
+	}
+	if v := store.projects[response.ID].FlyVolumeID; v == nil || *v != "vol-fork" {
+		t.Errorf("expected fork volume recorded, got %v", v)
+	}
+}
+
+func TestProjectHandler_Fork_VolumeFailureRemovesFork(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	volumes := newMockVolumeManager()
+	volumes.forkFn = func(sourceVolumeID, name string) (*Volume, error) {
+		return nil, errors.New("fork failed")
+	}
+	handler := NewProjectHandler(store, newMockMachineManager(), volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.Fork, "POST", "/projects/{id}/fork", "/projects/"+testProjectID+"/fork", []byte(`{"name":"copy"}`))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusInternalServerError, rr.Code, rr.Body.String())
+	}
+	if len(store.projects) != 1 {
+		t.Errorf("expected fork row removed, have %d projects", len(store.projects))
+	}
+}
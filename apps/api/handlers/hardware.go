@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/quota"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type UpdateHardwareRequest struct {
+	HardwareConfigRequest
+	// Restart must be set to change a running project; it is restarted onto the new hardware
+	Restart bool `json:"restart,omitempty"`
+}
+
+type UpdateHardwareResponse struct {
+	Project     ProjectResponse `json:"project"`
+	OperationID string          `json:"operation_id,omitempty"`
+}
+
+// UpdateHardware changes a project's hardware. Stopped projects pick it up on their
+// next start; running projects need restart=true and are restarted onto it.
+func (h *ProjectHandler) UpdateHardware(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	var req UpdateHardwareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	requested := &req.HardwareConfigRequest
+	if requested.Preset != "" {
+		preset := validation.GetPresetConfig(requested.Preset)
+		requested = &HardwareConfigRequest{
+			CPUKind:      preset.CPUKind,
+			CPUs:         preset.CPUs,
+			MemoryMB:     preset.MemoryMB,
+			VolumeSizeGB: preset.VolumeSizeGB,
+			GPUKind:      preset.GPUKind,
+		}
+	}
+	hw, errs := validation.ValidateHardwareConfig(requested.CPUKind, requested.CPUs, requested.MemoryMB, requested.VolumeSizeGB, requested.GPUKind)
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+
+	project, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for hardware update", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update hardware")
+		return
+	}
+	if !allowProject(w, project, roleManage) {
+		return
+	}
+
+	if !checkIfMatch(w, r, project.Version) {
+		return
+	}
+
+	verr := h.checkVolumeCompatible(project, hw)
+	if verr == nil {
+		// The project stays in its region, which must offer the new hardware
+		verr = h.regions.Validate(h.projectRegion(project), hw)
+	}
+	if verr != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*verr},
+		})
+		return
+	}
+
+	switch project.Status {
+	case db.StatusStopped, db.StatusError:
+	case db.StatusRunning:
+		if !req.Restart {
+			WriteJSON(w, http.StatusConflict, map[string]any{
+				"error":          "Project is running; set restart to apply the new hardware",
+				"current_status": project.Status,
+			})
+			return
+		}
+	default:
+		WriteJSON(w, http.StatusConflict, map[string]any{
+			"error":          "Cannot change hardware while project is " + project.Status,
+			"current_status": project.Status,
+		})
+		return
+	}
+
+	dbHw := &db.HardwareConfig{
+		CPUKind:      hw.CPUKind,
+		CPUs:         hw.CPUs,
+		MemoryMB:     hw.MemoryMB,
+		VolumeSizeGB: hw.VolumeSizeGB,
+		GPUKind:      hw.GPUKind,
+	}
+	releaseQuota, err := quota.Reserve(ctx, h.store, project.UserID, project.OrganizationID, quota.ForHardwareChange(project, dbHw, project.Status == db.StatusRunning))
+	if err != nil {
+		h.writeTransitionError(w, log, err, "update hardware for")
+		return
+	}
+
+	updated, err := h.store.UpdateProjectHardware(ctx, projectID, userID, dbHw)
+	releaseQuota()
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to update project hardware", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update hardware")
+		return
+	}
+
+	log.Info("project hardware updated", "project_id", projectID, "cpu_kind", hw.CPUKind, "cpus", hw.CPUs, "memory_mb", hw.MemoryMB, "volume_size_gb", hw.VolumeSizeGB, "pending", updated.HardwarePending)
+
+	if project.Status != db.StatusRunning {
+		w.Header().Set("ETag", versionETag(updated.Version))
+		WriteJSON(w, http.StatusOK, UpdateHardwareResponse{Project: projectToResponse(updated)})
+		return
+	}
+
+	// The new config is saved, so even if the restart can't begin it applies on the next start
+	op, err := h.store.BeginOperation(ctx, projectID, project.UserID, db.OperationRestart, []string{db.StatusRunning}, db.StatusStopping)
+	if err != nil {
+		h.writeTransitionError(w, log, err, "restart")
+		return
+	}
+
+	log.Info("restarting project for hardware change", "project_id", projectID, "operation_id", op.ID)
+	h.dispatchOperation(op)
+
+	updated.Status = db.StatusStopping
+	WriteJSON(w, http.StatusAccepted, UpdateHardwareResponse{Project: projectToResponse(updated), OperationID: op.ID})
+}
+
+// checkVolumeCompatible rejects changes the existing volume can't follow: volumes only grow
+func (h *ProjectHandler) checkVolumeCompatible(project *db.Project, hw *validation.HardwareConfig) *validation.ValidationError {
+	if project.FlyVolumeID == nil || *project.FlyVolumeID == "" {
+		return nil
+	}
+
+	if hw.VolumeSizeGB < project.VolumeSizeGB {
+		return &validation.ValidationError{
+			Field:   "volume_size_gb",
+			Message: fmt.Sprintf("volumes can't shrink; must be at least %dGB", project.VolumeSizeGB),
+		}
+	}
+
+	return nil
+}
+
+// applyHardware brings an existing volume and stopped machine in line with the
+// project's hardware. Both steps are idempotent so a resumed start can repeat them.
+func (h *ProjectHandler) applyHardware(ctx context.Context, op *db.Operation, project *db.Project) error {
+	if project.FlyVolumeID != nil && *project.FlyVolumeID != "" {
+		volume, err := h.volumes.GetVolume(*project.FlyVolumeID)
+		if err == nil && volume.SizeGB < project.VolumeSizeGB {
+			if err := h.volumes.ExtendVolume(*project.FlyVolumeID, project.VolumeSizeGB); err != nil {
+				return err
+			}
+		}
+	}
+
+	if project.FlyMachineID != nil && *project.FlyMachineID != "" {
+		if err := h.machines.UpdateMachine(*project.FlyMachineID, h.machineConfig(ctx, project, op.UserID)); err != nil {
+			return err
+		}
+	}
+
+	return h.store.ClearProjectHardwarePending(ctx, project.ID, &db.HardwareConfig{
+		CPUKind:      project.CPUKind,
+		CPUs:         project.CPUs,
+		MemoryMB:     project.MemoryMB,
+		VolumeSizeGB: project.VolumeSizeGB,
+		GPUKind:      project.GPUKind,
+	})
+}
+
+// restartMachineAsync stops a project and starts it again, applying any pending
+// hardware. A resumed restart picks up from whichever half it had reached.
+func (h *ProjectHandler) restartMachineAsync(ctx context.Context, op *db.Operation, project *db.Project) error {
+	log := logging.Default().With("project_id", project.ID, "operation_id", op.ID)
+
+	if project.Status == db.StatusStopping {
+		if err := h.stopMachineAsync(ctx, op, project); err != nil {
+			return err
+		}
+	}
+
+	if err := h.completeTransition(ctx, project.ID, db.StatusStopped, db.StatusStarting); err != nil {
+		log.Error("failed to update project status", "error", err)
+		return err
+	}
+
+	// Stopping may have changed the project; start from its latest state
+	project, err := h.store.GetProject(ctx, project.ID)
+	if err != nil {
+		return err
+	}
+	return h.startMachineAsync(ctx, op, project)
+}
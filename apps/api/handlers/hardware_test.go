@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+func (m *mockProjectStore) UpdateProjectHardware(ctx context.Context, projectID, userID string, hw *db.HardwareConfig) (*db.Project, error) {
+	p, ok := m.projects[projectID]
+	if !ok || m.roleOn(p, userID) == "" {
+		return nil, db.ErrNotFound
+	}
+	p.CPUKind, p.CPUs, p.MemoryMB, p.VolumeSizeGB, p.GPUKind = hw.CPUKind, hw.CPUs, hw.MemoryMB, hw.VolumeSizeGB, hw.GPUKind
+	p.HardwarePending = p.FlyMachineID != nil || p.FlyVolumeID != nil
+	return p, nil
+}
+
+func (m *mockProjectStore) ClearProjectHardwarePending(ctx context.Context, projectID string, applied *db.HardwareConfig) error {
+	if p, ok := m.projects[projectID]; ok && p.CPUs == applied.CPUs && p.MemoryMB == applied.MemoryMB {
+		p.HardwarePending = false
+	}
+	return nil
+}
+
+func TestProjectHandler_UpdateHardware_StoppedIsPending(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	machines := newMockMachineManager()
+	machines.updateFn = func(machineID string, config MachineConfig) error {
+		t.Error("machine updated before next start")
+		return nil
+	}
+	handler := NewProjectHandler(store, machines, newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.UpdateHardware, "PATCH", "/projects/{id}/hardware", "/projects/"+testProjectID+"/hardware", []byte(`{"preset":"performance"}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response UpdateHardwareResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Project.Hardware.CPUKind != "performance" || response.Project.Hardware.VolumeSizeGB != 20 {
+		t.Errorf("expected performance preset, got %+v", response.Project.Hardware)
+	}
+	if !store.projects[testProjectID].HardwarePending {
+		t.Error("expected hardware to be pending until next start")
+	}
+}
+
+func TestProjectHandler_UpdateHardware_Rejections(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		body   string
+		code   int
+	}{
+		{name: "running without restart", status: db.StatusRunning, body: `{"preset":"medium"}`, code: http.StatusConflict},
+		{name: "while starting", status: db.StatusStarting, body: `{"preset":"medium"}`, code: http.StatusConflict},
+		{name: "shrinking volume", status: db.StatusStopped, body: `{"cpu_kind":"shared","cpus":1,"memory_mb":1024,"volume_size_gb":1}`, code: http.StatusBadRequest},
+		{name: "invalid memory", status: db.StatusStopped, body: `{"cpu_kind":"shared","cpus":1,"memory_mb":4096,"volume_size_gb":5}`, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newProjectFixture(tt.status)
+			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+			rr := serveRoute(handler.UpdateHardware, "PATCH", "/projects/{id}/hardware", "/projects/"+testProjectID+"/hardware", []byte(tt.body))
+			if rr.Code != tt.code {
+				t.Fatalf("expected status %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if p := store.projects[testProjectID]; p.CPUs != 1 || p.VolumeSizeGB != 5 || p.HardwarePending {
+				t.Errorf("hardware changed despite rejection: %+v", p)
+			}
+		})
+	}
+}
+
+func TestProjectHandler_RestartAppliesHardware(t *testing.T) {
+	ctx := context.Background()
+	store := newProjectFixture(db.StatusRunning)
+	store.projects[testProjectID].CPUs = 2
+	store.projects[testProjectID].MemoryMB = 2048
+	store.projects[testProjectID].VolumeSizeGB = 10
+	store.projects[testProjectID].HardwarePending = true
+
+	machines := newMockMachineManager()
+	state := "started"
+	machines.getFn = func(machineID string) (*Machine, error) {
+		return &Machine{ID: machineID, State: state}, nil
+	}
+	machines.stopFn = func(machineID string) error {
+		state = "stopped"
+		return nil
+	}
+	machines.startFn = func(machineID string) error {
+		state = "started"
+		return nil
+	}
+	var applied GuestConfig
+	machines.updateFn = func(machineID string, config MachineConfig) error {
+		if state != "stopped" {
+			t.Errorf("machine updated while %s", state)
+		}
+		applied = config.Guest
+		return nil
+	}
+	volumes := newMockVolumeManager()
+	volumes.getFn = func(volumeID string) (*Volume, error) {
+		return &Volume{ID: volumeID, SizeGB: 5}, nil
+	}
+	var extendedTo int
+	volumes.extendFn = func(volumeID string, sizeGB int) error {
+		extendedTo = sizeGB
+		return nil
+	}
+	handler := NewProjectHandler(store, machines, volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	op, err := store.BeginOperation(ctx, testProjectID, "test-user-id", db.OperationRestart, []string{db.StatusRunning}, db.StatusStopping)
+	if err != nil {
+		t.Fatalf("failed to begin restart: %v", err)
+	}
+	claimed, err := store.ClaimOperation(ctx, op.ID, handler.workerID)
+	if err != nil {
+		t.Fatalf("failed to claim restart: %v", err)
+	}
+	handler.runOperation(ctx, claimed)
+
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusRunning {
+		t.Errorf("expected running after restart, got %s", p.Status)
+	}
+	if applied.CPUs != 2 || applied.MemoryMB != 2048 {
+		t.Errorf("expected new guest config applied, got %+v", applied)
+	}
+	if extendedTo != 10 {
+		t.Errorf("expected volume extended to 10GB, got %d", extendedTo)
+	}
+	if p.HardwarePending {
+		t.Error("expected hardware pending cleared")
+	}
+	if store.operations[op.ID].Status != db.OperationSucceeded {
+		t.Errorf("expected operation succeeded, got %s", store.operations[op.ID].Status)
+	}
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"aether/apps/api/db"
+	"aether/libs/go/logging"
+)
+
+// hibernateBatchSize caps how many projects one hibernator pass starts archiving
+const hibernateBatchSize = 5
+
+// StartHibernator starts a background goroutine that hibernates stopped projects
+// nobody has opened for inactiveFor
+func (h *ProjectHandler) StartHibernator(interval, inactiveFor time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			h.hibernateInactiveProjects(context.Background(), time.Now(), inactiveFor)
+		}
+	}()
+}
+
+// hibernateInactiveProjects begins hibernate operations for the least recently
+// used projects that have been inactive for longer than inactiveFor
+func (h *ProjectHandler) hibernateInactiveProjects(ctx context.Context, now time.Time, inactiveFor time.Duration) {
+	log := logging.Default()
+
+	projects, err := h.store.ListHibernationCandidates(ctx, now.Add(-inactiveFor), hibernateBatchSize)
+	if err != nil {
+		log.Error("failed to list projects to hibernate", "error", err)
+		return
+	}
+
+	for i := range projects {
+		p := &projects[i]
+		projectLog := log.With("project_id", p.ID)
+
+		// Go through an operation so hibernation can't race a user's start
+		op, err := h.store.BeginOperation(ctx, p.ID, p.UserID, db.OperationHibernate, []string{db.StatusStopped}, db.StatusHibernating)
+		if err != nil {
+			if errors.Is(err, db.ErrInvalidTransition) || errors.Is(err, db.ErrOperationInProgress) {
+				projectLog.Debug("skipping hibernation, project is busy", "error", err)
+				continue
+			}
+			projectLog.Error("failed to begin hibernation", "error", err)
+			continue
+		}
+
+		projectLog.Info("hibernating inactive project", "last_accessed_at", p.LastAccessedAt, "operation_id", op.ID)
+		h.dispatchOperation(op)
+	}
+}
+
+// hibernateAsync archives a stopped project's volume, then deletes its machine and
+// volume. Until the archive is recorded any failure returns the project to stopped
+// with its volume intact; after that the archive is the project's only copy.
+func (h *ProjectHandler) hibernateAsync(ctx context.Context, op *db.Operation, project *db.Project) error {
+	projectID := project.ID
+	log := logging.Default().With("project_id", projectID, "operation_id", op.ID)
+
+	// Archive unless a previous attempt already did
+	if project.ArchiveKey == nil {
+		if h.archives == nil {
+			return h.abortHibernate(ctx, log, project, "no archive store is configured")
+		}
+		if project.FlyMachineID == nil || *project.FlyMachineID == "" || project.FlyVolumeID == nil || *project.FlyVolumeID == "" {
+			return h.abortHibernate(ctx, log, project, "project has no machine and volume to archive")
+		}
+		machineID := *project.FlyMachineID
+
+		// The volume can only be read from the machine it is mounted on
+		if err := h.setOperationStep(ctx, op, "start_machine"); err != nil {
+			return err
+		}
+		machine, err := h.machines.GetMachine(machineID)
+		if err != nil || machine.State != "started" {
+			if err := h.machines.StartMachine(machineID); err != nil {
+				log.Error("failed to start machine for archive", "error", err)
+				return h.abortHibernate(ctx, log, project, "failed to start machine: "+err.Error())
+			}
+		}
+		if err := h.machines.WaitForState(machineID, "started", 60*time.Second); err != nil {
+			log.Error("failed waiting for machine to start", "error", err)
+			return h.abortHibernate(ctx, log, project, "failed to start machine: "+err.Error())
+		}
+
+		if err := h.setOperationStep(ctx, op, "archive_volume"); err != nil {
+			return err
+		}
+		// Keyed by operation so a resumed attempt overwrites its own partial upload
+		key := "projects/" + projectID + "/" + op.ID + ".tar.gz"
+		stop := h.keepOperationAlive(ctx, op)
+		size, err := h.archives.ArchiveVolume(ctx, project, key)
+		stop()
+		if err != nil {
+			log.Error("failed to archive volume", "error", err)
+			return h.abortHibernate(ctx, log, project, "failed to archive volume: "+err.Error())
+		}
+
+		if err := h.store.SetProjectArchive(ctx, projectID, key, size); err != nil {
+			log.Error("failed to record archive", "error", err)
+			if err := h.archives.DeleteArchive(ctx, key); err != nil {
+				log.Error("failed to delete unrecorded archive", "key", key, "error", err)
+			}
+			return h.abortHibernate(ctx, log, project, "failed to record archive: "+err.Error())
+		}
+		project.ArchiveKey = &key
+		project.ArchiveSizeBytes = &size
+		log.Info("archived volume", "key", key, "size_bytes", size)
+	}
+
+	if project.FlyMachineID != nil && *project.FlyMachineID != "" {
+		if err := h.setOperationStep(ctx, op, "delete_machine"); err != nil {
+			return err
+		}
+		if err := h.stopAndDeleteMachine(*project.FlyMachineID); err != nil {
+			log.Error("failed to delete machine", "error", err)
+			return h.failProject(ctx, log, projectID, "Failed to delete machine while hibernating: "+err.Error())
+		}
+		if _, err := h.store.ClearProjectMachine(ctx, projectID, *project.FlyMachineID); err != nil {
+			log.Error("failed to clear project machine", "error", err)
+		}
+	}
+
+	if project.FlyVolumeID != nil && *project.FlyVolumeID != "" {
+		if err := h.setOperationStep(ctx, op, "delete_volume"); err != nil {
+			return err
+		}
+		if err := h.volumes.DeleteVolume(*project.FlyVolumeID); err != nil {
+			log.Error("failed to delete volume", "error", err)
+			return h.failProject(ctx, log, projectID, "Failed to delete volume while hibernating: "+err.Error())
+		}
+		if _, err := h.store.ClearProjectVolume(ctx, projectID, *project.FlyVolumeID); err != nil {
+			log.Error("failed to clear project volume", "error", err)
+		}
+	}
+
+	if err := h.completeTransition(ctx, projectID, db.StatusHibernating, db.StatusHibernated); err != nil {
+		log.Error("failed to update project status", "error", err)
+		return err
+	}
+
+	data := map[string]any{}
+	if project.ArchiveSizeBytes != nil {
+		data["archive_size_bytes"] = *project.ArchiveSizeBytes
+	}
+	if err := h.store.RecordProjectEvent(ctx, projectID, db.EventHibernated, nil, data); err != nil {
+		log.Error("failed to record hibernation event", "error", err)
+	}
+
+	log.Info("project hibernated")
+	return nil
+}
+
+// stopAndDeleteMachine stops and deletes a machine unless it is already gone
+func (h *ProjectHandler) stopAndDeleteMachine(machineID string) error {
+	machine, err := h.machines.GetMachine(machineID)
+	if err != nil || machineGone(machine.State) {
+		return nil
+	}
+	if machine.State != "stopped" {
+		if err := h.machines.StopMachine(machineID); err != nil {
+			return err
+		}
+		if err := h.machines.WaitForState(machineID, "stopped", 30*time.Second); err != nil {
+			return err
+		}
+	}
+	return h.machines.DeleteMachine(machineID)
+}
+
+// abortHibernate returns a project whose volume is still intact to stopped,
+// stopping the machine if archiving started it, and returns the reason as an error
+func (h *ProjectHandler) abortHibernate(ctx context.Context, log *logging.Logger, project *db.Project, reason string) error {
+	if project.FlyMachineID != nil && *project.FlyMachineID != "" {
+		if machine, err := h.machines.GetMachine(*project.FlyMachineID); err == nil && machine.State == "started" {
+			if err := h.machines.StopMachine(*project.FlyMachineID); err != nil {
+				log.Error("failed to stop machine after archive failure", "error", err)
+			}
+		}
+	}
+	if err := h.completeTransition(ctx, project.ID, db.StatusHibernating, db.StatusStopped); err != nil {
+		log.Error("failed to update project status", "error", err)
+	}
+	return fmt.Errorf("hibernation aborted: %s", reason)
+}
+
+// restoreArchive unpacks a hibernated project's archive onto its new volume and
+// deletes the archive. The machine must be started.
+func (h *ProjectHandler) restoreArchive(ctx context.Context, op *db.Operation, project *db.Project, log *logging.Logger) error {
+	if h.archives == nil {
+		return errors.New("no archive store is configured")
+	}
+	key := *project.ArchiveKey
+
+	stop := h.keepOperationAlive(ctx, op)
+	err := h.archives.RestoreVolume(ctx, project, key)
+	stop()
+	if err != nil {
+		return err
+	}
+
+	if err := h.store.ClearProjectArchive(ctx, project.ID, key); err != nil {
+		return err
+	}
+	project.ArchiveKey = nil
+
+	if err := h.archives.DeleteArchive(ctx, key); err != nil {
+		log.Error("failed to delete restored archive", "key", key, "error", err)
+	}
+	if err := h.store.RecordProjectEvent(ctx, project.ID, db.EventArchiveRestored, nil, nil); err != nil {
+		log.Error("failed to record archive restore event", "error", err)
+	}
+	log.Info("restored archive", "key", key)
+	return nil
+}
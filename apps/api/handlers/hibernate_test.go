@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+func (m *mockProjectStore) ListHibernationCandidates(ctx context.Context, inactiveSince time.Time, limit int) ([]db.Project, error) {
+	var result []db.Project
+	for _, p := range m.projects {
+		if p.Status != db.StatusStopped || p.FlyMachineID == nil || p.FlyVolumeID == nil {
+			continue
+		}
+		lastUsed := p.CreatedAt
+		if p.LastAccessedAt != nil {
+			lastUsed = *p.LastAccessedAt
+		}
+		if lastUsed.Before(inactiveSince) && len(result) < limit {
+			result = append(result, *p)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProjectStore) SetProjectArchive(ctx context.Context, projectID, key string, sizeBytes int64) error {
+	p, ok := m.projects[projectID]
+	if !ok {
+		return db.ErrNotFound
+	}
+	now := time.Now()
+	p.ArchiveKey = &key
+	p.ArchiveSizeBytes = &sizeBytes
+	p.HibernatedAt = &now
+	return nil
+}
+
+func (m *mockProjectStore) ClearProjectArchive(ctx context.Context, projectID, key string) error {
+	if p, ok := m.projects[projectID]; ok && p.ArchiveKey != nil && *p.ArchiveKey == key {
+		p.ArchiveKey = nil
+		p.ArchiveSizeBytes = nil
+		p.HibernatedAt = nil
+	}
+	return nil
+}
+
+type mockVolumeArchiver struct {
+	archiveErr error
+	restoreErr error
+
+	archived []string
+	restored []string
+	deleted  []string
+}
+
+func (m *mockVolumeArchiver) ArchiveVolume(ctx context.Context, project *db.Project, key string) (int64, error) {
+	if m.archiveErr != nil {
+		return 0, m.archiveErr
+	}
+	m.archived = append(m.archived, key)
+	return 1024, nil
+}
+
+func (m *mockVolumeArchiver) RestoreVolume(ctx context.Context, project *db.Project, key string) error {
+	if m.restoreErr != nil {
+		return m.restoreErr
+	}
+	m.restored = append(m.restored, key)
+	return nil
+}
+
+func (m *mockVolumeArchiver) DeleteArchive(ctx context.Context, key string) error {
+	m.deleted = append(m.deleted, key)
+	return nil
+}
+
+// runHibernate begins and runs a hibernate operation on the fixture project
+func runHibernate(t *testing.T, handler *ProjectHandler, store *mockProjectStore) *db.Operation {
+	t.Helper()
+	ctx := context.Background()
+
+	op, err := store.BeginOperation(ctx, testProjectID, "test-user-id", db.OperationHibernate, []string{db.StatusStopped}, db.StatusHibernating)
+	if err != nil {
+		t.Fatalf("failed to begin hibernate: %v", err)
+	}
+	claimed, err := store.ClaimOperation(ctx, op.ID, handler.workerID)
+	if err != nil {
+		t.Fatalf("failed to claim hibernate: %v", err)
+	}
+	handler.runOperation(ctx, claimed)
+	return store.operations[op.ID]
+}
+
+func TestProjectHandler_Hibernate(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	machines := newMockMachineManager()
+	var deletedMachine string
+	machines.deleteFn = func(machineID string) error {
+		deletedMachine = machineID
+		return nil
+	}
+	volumes := newMockVolumeManager()
+	var deletedVolume string
+	volumes.deleteFn = func(volumeID string) error {
+		deletedVolume = volumeID
+		return nil
+	}
+	archives := &mockVolumeArchiver{}
+	handler := NewProjectHandler(store, machines, volumes, archives, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	op := runHibernate(t, handler, store)
+
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusHibernated {
+		t.Fatalf("expected hibernated, got %s", p.Status)
+	}
+	if len(archives.archived) != 1 || p.ArchiveKey == nil || *p.ArchiveKey != archives.archived[0] {
+		t.Errorf("expected archive recorded, got %v (archived %v)", p.ArchiveKey, archives.archived)
+	}
+	if p.ArchiveSizeBytes == nil || *p.ArchiveSizeBytes != 1024 {
+		t.Errorf("expected archive size recorded, got %v", p.ArchiveSizeBytes)
+	}
+	if deletedMachine != "machine-123" || p.FlyMachineID != nil {
+		t.Errorf("expected machine deleted and cleared, got deleted=%q id=%v", deletedMachine, p.FlyMachineID)
+	}
+	if deletedVolume != "vol-123" || p.FlyVolumeID != nil {
+		t.Errorf("expected volume deleted and cleared, got deleted=%q id=%v", deletedVolume, p.FlyVolumeID)
+	}
+	if op.Status != db.OperationSucceeded {
+		t.Errorf("expected operation succeeded, got %s", op.Status)
+	}
+}
+
+func TestProjectHandler_Hibernate_ArchiveFailureKeepsVolume(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	machines := newMockMachineManager()
+	machines.deleteFn = func(machineID string) error {
+		t.Error("machine deleted after a failed archive")
+		return nil
+	}
+	volumes := newMockVolumeManager()
+	volumes.deleteFn = func(volumeID string) error {
+		t.Error("volume deleted after a failed archive")
+		return nil
+	}
+	archives := &mockVolumeArchiver{archiveErr: errors.New("upload failed")}
+	handler := NewProjectHandler(store, machines, volumes, archives, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	runHibernate(t, handler, store)
+
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusStopped {
+		t.Errorf("expected back to stopped, got %s", p.Status)
+	}
+	if p.FlyVolumeID == nil || p.ArchiveKey != nil {
+		t.Errorf("expected volume kept and no archive, got volume=%v archive=%v", p.FlyVolumeID, p.ArchiveKey)
+	}
+}
+
+func TestProjectHandler_HibernateInactiveProjects(t *testing.T) {
+	now := time.Now()
+	store := newMockStore()
+	addProject := func(id, status string, lastAccessed time.Time) {
+		store.projects[id] = &db.Project{
+			ID:             id,
+			UserID:         "test-user-id",
+			Status:         status,
+			FlyMachineID:   strPtr("machine-" + id),
+			FlyVolumeID:    strPtr("vol-" + id),
+			LastAccessedAt: &lastAccessed,
+			CreatedAt:      lastAccessed,
+		}
+	}
+	addProject("p-old", db.StatusStopped, now.Add(-100*24*time.Hour))
+	addProject("p-recent", db.StatusStopped, now.Add(-10*24*time.Hour))
+	addProject("p-running", db.StatusRunning, now.Add(-100*24*time.Hour))
+
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), &mockVolumeArchiver{}, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+	handler.hibernateInactiveProjects(context.Background(), now, 90*24*time.Hour)
+
+	store.opsMu.Lock()
+	var hibernating []string
+	for _, op := range store.operations {
+		if op.Type == db.OperationHibernate {
+			hibernating = append(hibernating, op.ProjectID)
+		}
+	}
+	store.opsMu.Unlock()
+
+	if len(hibernating) != 1 || hibernating[0] != "p-old" {
+		t.Errorf("expected only p-old hibernated, got %v", hibernating)
+	}
+}
+
+func TestProjectHandler_StartHibernatedRestoresArchive(t *testing.T) {
+	ctx := context.Background()
+	store := newProjectFixture(db.StatusHibernated)
+	p := store.projects[testProjectID]
+	p.FlyMachineID = nil
+	p.FlyVolumeID = nil
+	key := "projects/" + testProjectID + "/op.tar.gz"
+	p.ArchiveKey = &key
+
+	machines := newMockMachineManager()
+	archives := &mockVolumeArchiver{}
+	handler := NewProjectHandler(store, machines, newMockVolumeManager(), archives, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	op, err := store.BeginOperation(ctx, testProjectID, "test-user-id", db.OperationStart, []string{db.StatusHibernated}, db.StatusStarting)
+	if err != nil {
+		t.Fatalf("failed to begin start: %v", err)
+	}
+	claimed, err := store.ClaimOperation(ctx, op.ID, handler.workerID)
+	if err != nil {
+		t.Fatalf("failed to claim start: %v", err)
+	}
+	handler.runOperation(ctx, claimed)
+
+	if p.Status != db.StatusRunning {
+		t.Fatalf("expected running, got %s (%v)", p.Status, p.ErrorMessage)
+	}
+	if len(archives.restored) != 1 || archives.restored[0] != key {
+		t.Errorf("expected archive restored, got %v", archives.restored)
+	}
+	if len(archives.deleted) != 1 || p.ArchiveKey != nil {
+		t.Errorf("expected archive deleted and cleared, got deleted=%v key=%v", archives.deleted, p.ArchiveKey)
+	}
+	if p.FlyVolumeID == nil {
+		t.Error("expected a new volume")
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+)
+
+const (
+	// IdempotencyKeyHeader names the request header clients set to make retries safe
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from an earlier request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotentBodyBytes caps the request bodies that are buffered for hashing
+	maxIdempotentBodyBytes = 8 << 20
+	// idempotencyLockTimeout is how long a claim can go unfinished before it is
+	// assumed abandoned. It outlasts the request timeout.
+	idempotencyLockTimeout = 2 * time.Minute
+)
+
+// IdempotencyHandler makes mutating requests that carry an Idempotency-Key safe to
+// retry: the first request's response is stored and replayed for the same key
+// until the TTL ends, and reusing the key for a different request is rejected
+type IdempotencyHandler struct {
+	store IdempotencyStore
+	ttl   time.Duration
+}
+
+func NewIdempotencyHandler(store IdempotencyStore, ttl time.Duration) *IdempotencyHandler {
+	return &IdempotencyHandler{
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+// Middleware applies idempotency keys to POST, PUT, PATCH and DELETE requests.
+// Keys are scoped to the user, so it must be mounted after Authenticate.
+func (h *IdempotencyHandler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || !isMutatingMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		userID := authmw.GetUserID(ctx)
+		log := logging.FromContext(ctx)
+
+		if err := validation.ValidateIdempotencyKey(key); err != nil {
+			WriteJSON(w, http.StatusBadRequest, map[string]any{
+				"error":  "Validation failed",
+				"errors": []validation.ValidationError{*err},
+			})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodyBytes+1))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		if len(body) > maxIdempotentBodyBytes {
+			WriteError(w, http.StatusRequestEntityTooLarge, "Request body is too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := idempotencyRequestHash(r, body)
+
+		now := time.Now()
+		record, claimed, err := h.store.ClaimIdempotencyKey(ctx, userID, key, hash, now.Add(h.ttl), now.Add(-idempotencyLockTimeout))
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				WriteError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+				return
+			}
+			log.Error("failed to claim idempotency key", "error", err)
+			WriteError(w, http.StatusInternalServerError, "Failed to check Idempotency-Key")
+			return
+		}
+
+		if !claimed {
+			switch {
+			case record.RequestHash != hash:
+				WriteError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+			case !record.Completed():
+				WriteError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+			default:
+				log.Info("replaying idempotent response", "status", *record.ResponseStatus)
+				replayIdempotentResponse(w, record)
+			}
+			return
+		}
+
+		// Store the response even if the client has gone away, since that's when it retries
+		storeCtx := context.WithoutCancel(ctx)
+		recorder := &idempotentResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			if !completed {
+				if err := h.store.ReleaseIdempotencyKey(storeCtx, userID, key); err != nil {
+					log.Error("failed to release idempotency key", "error", err)
+				}
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+
+		if !storableIdempotentStatus(recorder.status) {
+			return
+		}
+		header := recorder.Header()
+		if err := h.store.CompleteIdempotencyKey(storeCtx, userID, key, recorder.status, header.Get("Content-Type"), header.Get("ETag"), recorder.body.Bytes()); err != nil {
+			log.Error("failed to store idempotent response", "error", err)
+			return
+		}
+		completed = true
+	})
+}
+
+// StartCleanup starts a background goroutine that deletes expired keys
+func (h *IdempotencyHandler) StartCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			deleted, err := h.store.DeleteExpiredIdempotencyKeys(context.Background())
+			if err != nil {
+				logging.Default().Error("failed to delete expired idempotency keys", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				logging.Default().Debug("deleted expired idempotency keys", "count", deleted)
+			}
+		}
+	}()
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// storableIdempotentStatus reports whether a response with this status is kept
+// for replay. Server errors, and conflicts that clear once another change or
+// operation finishes, aren't stored, so the request can be retried.
+func storableIdempotentStatus(status int) bool {
+	switch status {
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+// idempotencyRequestHash identifies a request by its method, URL and body
+func idempotencyRequestHash(r *http.Request, body []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, record *db.IdempotencyKey) {
+	if record.ResponseContentType != nil && *record.ResponseContentType != "" {
+		w.Header().Set("Content-Type", *record.ResponseContentType)
+	}
+	if record.ResponseETag != nil {
+		w.Header().Set("ETag", *record.ResponseETag)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(*record.ResponseStatus)
+	if _, err := w.Write(record.ResponseBody); err != nil {
+		return
+	}
+}
+
+// idempotentResponseWriter passes a response through while keeping a copy of it
+type idempotentResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *idempotentResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *idempotentResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+type mockIdempotencyStore struct {
+	mu   sync.Mutex
+	keys map[string]*db.IdempotencyKey
+}
+
+func newMockIdempotencyStore() *mockIdempotencyStore {
+	return &mockIdempotencyStore{keys: make(map[string]*db.IdempotencyKey)}
+}
+
+func (m *mockIdempotencyStore) ClaimIdempotencyKey(ctx context.Context, userID, key, requestHash string, expiresAt, staleBefore time.Time) (*db.IdempotencyKey, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if k, ok := m.keys[userID+"/"+key]; ok {
+		abandoned := !k.Completed() && k.LockedAt.Before(staleBefore)
+		if k.ExpiresAt.After(now) && !abandoned {
+			copied := *k
+			return &copied, false, nil
+		}
+	}
+	k := &db.IdempotencyKey{UserID: userID, Key: key, RequestHash: requestHash, LockedAt: now, CreatedAt: now, ExpiresAt: expiresAt}
+	m.keys[userID+"/"+key] = k
+	copied := *k
+	return &copied, true, nil
+}
+
+func (m *mockIdempotencyStore) CompleteIdempotencyKey(ctx context.Context, userID, key string, status int, contentType, etag string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.keys[userID+"/"+key]; ok {
+		k.ResponseStatus = &status
+		k.ResponseContentType = &contentType
+		if etag != "" {
+			k.ResponseETag = &etag
+		}
+		k.ResponseBody = append([]byte(nil), body...)
+	}
+	return nil
+}
+
+func (m *mockIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, userID, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.keys[userID+"/"+key]; ok && !k.Completed() {
+		delete(m.keys, userID+"/"+key)
+	}
+	return nil
+}
+
+func (m *mockIdempotencyStore) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted int64
+	for id, k := range m.keys {
+		if k.ExpiresAt.Before(time.Now()) {
+			delete(m.keys, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// countingHandler responds with how many times it has run, or with status if
+// set. The count is also its ETag, as a resource's version would be.
+type countingHandler struct {
+	calls  int
+	status int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	status := h.status
+	if status == 0 {
+		status = http.StatusCreated
+	}
+	w.Header().Set("ETag", versionETag(int64(h.calls)))
+	WriteJSON(w, status, map[string]int{"call": h.calls})
+}
+
+func serveIdempotent(handler http.Handler, method, key, body string) *httptest.ResponseRecorder {
+	req := newAuthenticatedRequest(method, "/projects", []byte(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestIdempotencyHandler_ReplaysResponse(t *testing.T) {
+	next := &countingHandler{}
+	handler := NewIdempotencyHandler(newMockIdempotencyStore(), time.Hour).Middleware(next)
+
+	first := serveIdempotent(handler, "POST", "key-1", `{"name":"my-project"}`)
+	second := serveIdempotent(handler, "POST", "key-1", `{"name":"my-project"}`)
+
+	if next.calls != 1 {
+		t.Fatalf("expected the handler to run once, ran %d times", next.calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("expected replay of %d %s, got %d %s", first.Code, first.Body.String(), second.Code, second.Body.String())
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" || first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("expected only the replay marked, got first=%q second=%q",
+			first.Header().Get(IdempotentReplayedHeader), second.Header().Get(IdempotentReplayedHeader))
+	}
+	if second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected stored content type, got %q", second.Header().Get("Content-Type"))
+	}
+	if second.Header().Get("ETag") != `"1"` {
+		t.Errorf("expected stored ETag \"1\", got %q", second.Header().Get("ETag"))
+	}
+
+	// Another key runs the request again
+	serveIdempotent(handler, "POST", "key-2", `{"name":"my-project"}`)
+	if next.calls != 2 {
+		t.Errorf("expected a new key to run the handler, ran %d times", next.calls)
+	}
+}
+
+func TestIdempotencyHandler_RejectsDifferentRequest(t *testing.T) {
+	next := &countingHandler{}
+	handler := NewIdempotencyHandler(newMockIdempotencyStore(), time.Hour).Middleware(next)
+
+	serveIdempotent(handler, "POST", "key-1", `{"name":"one"}`)
+	rr := serveIdempotent(handler, "POST", "key-1", `{"name":"two"}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d for a different payload, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+
+	rr = serveIdempotent(handler, "PATCH", "key-1", `{"name":"one"}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d for a different method, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	if next.calls != 1 {
+		t.Errorf("expected the handler to run once, ran %d times", next.calls)
+	}
+}
+
+func TestIdempotencyHandler_InProgress(t *testing.T) {
+	store := newMockIdempotencyStore()
+	next := &countingHandler{}
+	handler := NewIdempotencyHandler(store, time.Hour).Middleware(next)
+
+	// Claimed by a request that hasn't finished
+	if _, _, err := store.ClaimIdempotencyKey(context.Background(), "test-user-id", "key-1", "other", time.Now().Add(time.Hour), time.Now()); err != nil {
+		t.Fatalf("failed to seed key: %v", err)
+	}
+	store.keys["test-user-id/key-1"].RequestHash = idempotencyRequestHash(newAuthenticatedRequest("POST", "/projects", nil), []byte(`{}`))
+
+	rr := serveIdempotent(handler, "POST", "key-1", `{}`)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+
+	// An abandoned claim is taken over
+	store.keys["test-user-id/key-1"].LockedAt = time.Now().Add(-idempotencyLockTimeout - time.Minute)
+	rr = serveIdempotent(handler, "POST", "key-1", `{}`)
+	if rr.Code != http.StatusCreated || next.calls != 1 {
+		t.Errorf("expected abandoned claim retaken, got status %d after %d calls", rr.Code, next.calls)
+	}
+}
+
+func TestIdempotencyHandler_ServerErrorsAreRetried(t *testing.T) {
+	store := newMockIdempotencyStore()
+	next := &countingHandler{status: http.StatusInternalServerError}
+	handler := NewIdempotencyHandler(store, time.Hour).Middleware(next)
+
+	serveIdempotent(handler, "POST", "key-1", `{}`)
+	next.status = 0
+	rr := serveIdempotent(handler, "POST", "key-1", `{}`)
+	if rr.Code != http.StatusCreated || next.calls != 2 {
+		t.Errorf("expected the retry to run, got status %d after %d calls", rr.Code, next.calls)
+	}
+
+	// Client errors are stored like successes
+	next.status = http.StatusBadRequest
+	serveIdempotent(handler, "POST", "key-2", `{}`)
+	next.status = 0
+	if rr := serveIdempotent(handler, "POST", "key-2", `{}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected stored %d replayed, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestIdempotencyHandler_ConflictsAreRetried(t *testing.T) {
+	for _, status := range []int{http.StatusConflict, http.StatusPreconditionFailed} {
+		next := &countingHandler{status: status}
+		handler := NewIdempotencyHandler(newMockIdempotencyStore(), time.Hour).Middleware(next)
+
+		if rr := serveIdempotent(handler, "POST", "key-1", `{}`); rr.Code != status {
+			t.Fatalf("expected status %d, got %d", status, rr.Code)
+		}
+
+		// The operation in the way finished, so the retry goes through
+		next.status = 0
+		rr := serveIdempotent(handler, "POST", "key-1", `{}`)
+		if rr.Code != http.StatusCreated || next.calls != 2 || rr.Header().Get(IdempotentReplayedHeader) != "" {
+			t.Errorf("expected the retry after %d to run, got status %d after %d calls", status, rr.Code, next.calls)
+		}
+	}
+}
+
+func TestIdempotencyHandler_Expiry(t *testing.T) {
+	store := newMockIdempotencyStore()
+	next := &countingHandler{}
+	handler := NewIdempotencyHandler(store, time.Hour).Middleware(next)
+
+	serveIdempotent(handler, "POST", "key-1", `{}`)
+	store.keys["test-user-id/key-1"].ExpiresAt = time.Now().Add(-time.Minute)
+
+	// An expired key can be reused, even for a different request
+	if rr := serveIdempotent(handler, "POST", "key-1", `{"name":"other"}`); rr.Code != http.StatusCreated || next.calls != 2 {
+		t.Errorf("expected expired key reused, got status %d after %d calls", rr.Code, next.calls)
+	}
+}
+
+func TestIdempotencyHandler_Passthrough(t *testing.T) {
+	next := &countingHandler{}
+	handler := NewIdempotencyHandler(newMockIdempotencyStore(), time.Hour).Middleware(next)
+
+	for i := 0; i < 2; i++ {
+		serveIdempotent(handler, "POST", "", `{}`)
+		serveIdempotent(handler, "GET", "key-1", "")
+	}
+	if next.calls != 4 {
+		t.Errorf("expected requests without a key and GETs to always run, ran %d of 4", next.calls)
+	}
+}
+
+func TestIdempotencyHandler_InvalidKey(t *testing.T) {
+	next := &countingHandler{}
+	handler := NewIdempotencyHandler(newMockIdempotencyStore(), time.Hour).Middleware(next)
+
+	rr := serveIdempotent(handler, "POST", fmt.Sprintf("%0300d", 0), `{}`)
+	if rr.Code != http.StatusBadRequest || next.calls != 0 {
+		t.Errorf("expected status %d without running, got %d after %d calls", http.StatusBadRequest, rr.Code, next.calls)
+	}
+}
+
+func TestIdempotencyHandler_CreateProjectOnce(t *testing.T) {
+	store := newMockStore()
+	created := 0
+	store.createFn = func(ctx context.Context, userID, name string, description *string, baseImage string, hw *db.HardwareConfig, idleTimeoutMinutes *int, tmpl *db.Template, envVarsEncrypted *string, tags []string, labels map[string]string, region string, organizationID *string) (*db.Project, error) {
+		created++
+		return &db.Project{ID: fmt.Sprintf("project-%d", created), UserID: userID, Name: name, Status: db.StatusStopped, CPUKind: "shared"}, nil
+	}
+	projects := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+	handler := NewIdempotencyHandler(newMockIdempotencyStore(), time.Hour).Middleware(http.HandlerFunc(projects.Create))
+
+	first := serveIdempotent(handler, "POST", "ci-build-42", `{"name":"my-project"}`)
+	second := serveIdempotent(handler, "POST", "ci-build-42", `{"name":"my-project"}`)
+
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, first.Code, first.Body.String())
+	}
+	if created != 1 || second.Body.String() != first.Body.String() {
+		t.Errorf("expected one project and the same response, created %d: %s vs %s", created, first.Body.String(), second.Body.String())
+	}
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// idleWarningLead is how long before an idle stop connected clients are warned,
+	// capped at half the project's idle timeout
+	idleWarningLead = 2 * time.Minute
+	// idleBusyCPULoad is the per-CPU load average at which a workspace counts as
+	// busy, e.g. running a build, even with nobody connected
+	idleBusyCPULoad = 0.5
+)
+
+type SnoozeIdleRequest struct {
+	Minutes int `json:"minutes"`
+}
+
+type SnoozeIdleResponse struct {
+	SnoozedUntil time.Time `json:"snoozed_until"`
+}
+
+// SnoozeIdle keeps a running project from being stopped as idle for the next N minutes
+func (h *ProjectHandler) SnoozeIdle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	var req SnoozeIdleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validation.ValidateIdleSnooze(req.Minutes); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	project, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for snooze", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get project")
+		return
+	}
+	if !allowProject(w, project, roleOperate) {
+		return
+	}
+
+	if project.Status != db.StatusRunning {
+		WriteJSON(w, http.StatusConflict, map[string]any{
+			"error":          "Only running projects can be kept alive",
+			"current_status": project.Status,
+		})
+		return
+	}
+
+	until := time.Now().Add(time.Duration(req.Minutes) * time.Minute).UTC()
+	if err := h.store.SnoozeProjectIdle(ctx, project.ID, until); err != nil {
+		log.Error("failed to snooze idle stop", "project_id", project.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to keep project alive")
+		return
+	}
+
+	log.Info("idle stop snoozed", "project_id", project.ID, "until", until)
+	notice := fmt.Sprintf("Kept alive for %d minutes", req.Minutes)
+	if err := h.store.RecordProjectEvent(ctx, project.ID, db.EventIdleSnoozed, &notice, map[string]any{
+		"minutes":       req.Minutes,
+		"snoozed_until": until,
+	}); err != nil {
+		log.Error("failed to record idle snooze event", "error", err)
+	}
+
+	// Let other open tabs dismiss their warning
+	h.notifyIdle(ctx, project, IdleNotice{Channel: "idle", Type: "snoozed", SnoozedUntil: &until})
+
+	WriteJSON(w, http.StatusOK, SnoozeIdleResponse{SnoozedUntil: until})
+}
+
+// StartIdleChecker starts background goroutine to stop idle projects
+func (h *ProjectHandler) StartIdleChecker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			h.checkIdleProjects(context.Background(), time.Now())
+		}
+	}()
+}
+
+// checkIdleProjects stops running projects that have been idle past their timeout.
+// A project near its timeout is first asked whether agents, builds or previews are
+// still busy in it, and connected clients are warned before it is stopped. Each
+// pass also brings the usage ledger up to date with what is running.
+func (h *ProjectHandler) checkIdleProjects(ctx context.Context, now time.Time) {
+	log := logging.Default()
+
+	projects, err := h.store.GetRunningProjects(ctx)
+	if err != nil {
+		log.Error("failed to check idle projects", "error", err)
+		return
+	}
+	h.syncUsage(ctx, projects, now)
+
+	for i := range projects {
+		p := &projects[i]
+
+		// Only check projects that have an explicit idle timeout set
+		if p.IdleTimeoutMinutes == nil {
+			// No idle timeout set - never auto-stop
+			continue
+		}
+
+		if *p.IdleTimeoutMinutes == 0 {
+			// 0 means never auto-stop
+			continue
+		}
+
+		timeout := time.Duration(*p.IdleTimeoutMinutes) * time.Minute
+
+		if p.LastAccessedAt == nil {
+			continue
+		}
+
+		if p.IdleSnoozedUntil != nil && now.Before(*p.IdleSnoozedUntil) {
+			continue
+		}
+
+		idleSince := projectIdleSince(p)
+		idleFor := now.Sub(idleSince)
+		lead := min(idleWarningLead, timeout/2)
+		projectLog := log.With("project_id", p.ID)
+		projectLog.Debug("idle check", "idle_for", idleFor.Round(time.Second), "timeout", timeout)
+
+		if idleFor <= timeout-lead {
+			continue
+		}
+
+		if h.workspaceBusy(ctx, p, projectLog) {
+			// Restart the idle clock, which also makes any earlier warning stale
+			if err := h.store.UpdateProjectLastAccessed(ctx, p.ID); err != nil {
+				projectLog.Error("failed to update last accessed", "error", err)
+			}
+			continue
+		}
+
+		// A warning only counts if it was sent since the project went idle. Clients
+		// always get the full lead time, even if the checker falls behind.
+		warned := p.IdleWarnedAt != nil && p.IdleWarnedAt.After(idleSince)
+		if !warned {
+			h.warnIdle(ctx, p, idleSince, now.Add(lead), projectLog)
+			continue
+		}
+		if now.Sub(*p.IdleWarnedAt) < lead {
+			continue
+		}
+
+		// Go through a stop operation so the idle checker can't race a user's start or stop
+		op, err := h.store.BeginOperation(ctx, p.ID, p.UserID, db.OperationStop, []string{db.StatusRunning}, db.StatusStopping)
+		if err != nil {
+			if errors.Is(err, db.ErrInvalidTransition) || errors.Is(err, db.ErrOperationInProgress) {
+				projectLog.Debug("skipping idle stop, project is busy", "error", err)
+				continue
+			}
+			projectLog.Error("failed to begin idle stop", "error", err)
+			continue
+		}
+
+		projectLog.Info("stopping idle project", "idle_for", idleFor, "timeout", timeout, "operation_id", op.ID)
+		notice := "Stopping after " + idleFor.Round(time.Minute).String() + " of inactivity"
+		if err := h.store.RecordProjectEvent(ctx, p.ID, db.EventIdleStop, &notice, map[string]any{
+			"idle_timeout_minutes": *p.IdleTimeoutMinutes,
+			"operation_id":         op.ID,
+		}); err != nil {
+			projectLog.Error("failed to record idle stop event", "error", err)
+		}
+		h.dispatchOperation(op)
+	}
+}
+
+// projectIdleSince returns when idle time started counting: the last access or the
+// end of a snooze, whichever is later. LastAccessedAt must be set.
+func projectIdleSince(p *db.Project) time.Time {
+	if p.IdleSnoozedUntil != nil && p.IdleSnoozedUntil.After(*p.LastAccessedAt) {
+		return *p.IdleSnoozedUntil
+	}
+	return *p.LastAccessedAt
+}
+
+// workspaceBusy asks the project's workspace service whether anything is still
+// going on in it. A workspace that can't be reached counts as idle.
+func (h *ProjectHandler) workspaceBusy(ctx context.Context, p *db.Project, log *logging.Logger) bool {
+	if h.workspaces == nil {
+		return false
+	}
+
+	activity, err := h.workspaces.GetActivity(ctx, p)
+	if err != nil {
+		log.Warn("failed to get workspace activity", "error", err)
+		return false
+	}
+
+	busy := activity.AgentSessions > 0 || activity.PreviewConnections > 0 || activity.CPULoad >= idleBusyCPULoad
+	if busy {
+		log.Debug("workspace busy, keeping project running",
+			"agent_sessions", activity.AgentSessions,
+			"cpu_load", activity.CPULoad,
+			"preview_connections", activity.PreviewConnections)
+	}
+	return busy
+}
+
+// warnIdle tells connected clients the project will be stopped at stopsAt unless
+// it is used or snoozed before then
+func (h *ProjectHandler) warnIdle(ctx context.Context, p *db.Project, idleSince, stopsAt time.Time, log *logging.Logger) {
+	claimed, err := h.store.ClaimProjectIdleWarning(ctx, p.ID, idleSince)
+	if err != nil {
+		log.Error("failed to claim idle warning", "error", err)
+		return
+	}
+	if !claimed {
+		// Another replica warned, or the project stopped meanwhile
+		return
+	}
+
+	stopsAt = stopsAt.UTC()
+	log.Info("warning of idle stop", "stops_at", stopsAt)
+	notice := "Stopping at " + stopsAt.Format(time.RFC3339) + " unless kept alive"
+	if err := h.store.RecordProjectEvent(ctx, p.ID, db.EventIdleWarning, &notice, map[string]any{
+		"idle_timeout_minutes": *p.IdleTimeoutMinutes,
+		"stops_at":             stopsAt,
+	}); err != nil {
+		log.Error("failed to record idle warning event", "error", err)
+	}
+
+	h.notifyIdle(ctx, p, IdleNotice{
+		Channel:          "idle",
+		Type:             "warning",
+		StopsAt:          &stopsAt,
+		MaxSnoozeMinutes: validation.MaxIdleSnoozeMinutes,
+	})
+}
+
+// notifyIdle relays an idle notice to the project's connected clients, if any
+func (h *ProjectHandler) notifyIdle(ctx context.Context, p *db.Project, notice IdleNotice) {
+	if h.workspaces == nil {
+		return
+	}
+	if err := h.workspaces.NotifyIdle(ctx, p, notice); err != nil {
+		logging.FromContext(ctx).Warn("failed to notify workspace clients", "project_id", p.ID, "type", notice.Type, "error", err)
+	}
+}
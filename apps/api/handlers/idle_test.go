@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+func (m *mockProjectStore) ClaimProjectIdleWarning(ctx context.Context, projectID string, idleSince time.Time) (bool, error) {
+	p, ok := m.projects[projectID]
+	if !ok || p.Status != db.StatusRunning || (p.IdleWarnedAt != nil && !p.IdleWarnedAt.Before(idleSince)) {
+		return false, nil
+	}
+	now := time.Now()
+	p.IdleWarnedAt = &now
+	return true, nil
+}
+
+func (m *mockProjectStore) SnoozeProjectIdle(ctx context.Context, projectID string, until time.Time) error {
+	if p, ok := m.projects[projectID]; ok {
+		p.IdleSnoozedUntil = &until
+	}
+	return nil
+}
+
+type mockWorkspaceProbe struct {
+	activity WorkspaceActivity
+	err      error
+	notices  []IdleNotice
+}
+
+func (m *mockWorkspaceProbe) GetActivity(ctx context.Context, project *db.Project) (*WorkspaceActivity, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	activity := m.activity
+	return &activity, nil
+}
+
+func (m *mockWorkspaceProbe) NotifyIdle(ctx context.Context, project *db.Project, notice IdleNotice) error {
+	m.notices = append(m.notices, notice)
+	return nil
+}
+
+func TestProjectHandler_CheckIdleProjects(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+
+	tests := []struct {
+		name         string
+		lastAccessed *time.Time
+		warnedAt     *time.Time
+		snoozedUntil *time.Time
+		activity     WorkspaceActivity
+		probeErr     error
+		wantWarning  bool
+		wantStopsAt  time.Time
+		wantStop     bool
+		wantTouched  bool
+	}{
+		{name: "recently used", lastAccessed: ago(5 * time.Minute)},
+		{name: "near timeout warns", lastAccessed: ago(9 * time.Minute), wantWarning: true, wantStopsAt: now.Add(idleWarningLead)},
+		{name: "past timeout warns before stopping", lastAccessed: ago(time.Hour), wantWarning: true, wantStopsAt: now.Add(idleWarningLead)},
+		{name: "warned and past timeout stops", lastAccessed: ago(11 * time.Minute), warnedAt: ago(3 * time.Minute), wantStop: true},
+		{name: "warned too recently", lastAccessed: ago(11 * time.Minute), warnedAt: ago(time.Minute)},
+		{name: "warning before last access is stale", lastAccessed: ago(11 * time.Minute), warnedAt: ago(12 * time.Minute), wantWarning: true, wantStopsAt: now.Add(idleWarningLead)},
+		{name: "running agent keeps project", lastAccessed: ago(time.Hour), warnedAt: ago(3 * time.Minute), activity: WorkspaceActivity{AgentSessions: 1}, wantTouched: true},
+		{name: "busy cpu keeps project", lastAccessed: ago(time.Hour), warnedAt: ago(3 * time.Minute), activity: WorkspaceActivity{CPULoad: 0.9}, wantTouched: true},
+		{name: "open preview keeps project", lastAccessed: ago(time.Hour), warnedAt: ago(3 * time.Minute), activity: WorkspaceActivity{PreviewConnections: 2}, wantTouched: true},
+		{name: "low cpu is idle", lastAccessed: ago(time.Hour), warnedAt: ago(3 * time.Minute), activity: WorkspaceActivity{CPULoad: 0.1}, wantStop: true},
+		{name: "unreachable workspace is idle", lastAccessed: ago(9 * time.Minute), probeErr: errors.New("connection refused"), wantWarning: true, wantStopsAt: now.Add(idleWarningLead)},
+		{name: "snoozed", lastAccessed: ago(time.Hour), warnedAt: ago(3 * time.Minute), snoozedUntil: ago(-10 * time.Minute)},
+		{name: "idle time counts from end of snooze", lastAccessed: ago(time.Hour), warnedAt: ago(30 * time.Minute), snoozedUntil: ago(5 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newProjectFixture(db.StatusRunning)
+			project := store.projects[testProjectID]
+			timeout := 10
+			project.IdleTimeoutMinutes = &timeout
+			project.LastAccessedAt = tt.lastAccessed
+			project.IdleWarnedAt = tt.warnedAt
+			project.IdleSnoozedUntil = tt.snoozedUntil
+
+			probe := &mockWorkspaceProbe{activity: tt.activity, err: tt.probeErr}
+			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, probe, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+			handler.checkIdleProjects(context.Background(), now)
+
+			if tt.wantWarning {
+				if len(probe.notices) != 1 || probe.notices[0].Type != "warning" {
+					t.Fatalf("expected one warning notice, got %+v", probe.notices)
+				}
+				if stopsAt := probe.notices[0].StopsAt; stopsAt == nil || !stopsAt.Equal(tt.wantStopsAt.UTC()) {
+					t.Errorf("expected stop at %v, got %v", tt.wantStopsAt.UTC(), stopsAt)
+				}
+			} else if len(probe.notices) != 0 {
+				t.Errorf("expected no notices, got %+v", probe.notices)
+			}
+
+			if stopped := len(store.operations) > 0; stopped != tt.wantStop {
+				t.Errorf("expected stop %v, got operations %+v", tt.wantStop, store.operations)
+			}
+			for _, op := range store.operations {
+				if op.Type != db.OperationStop {
+					t.Errorf("expected stop operation, got %s", op.Type)
+				}
+			}
+
+			if touched := project.LastAccessedAt.After(now); touched != tt.wantTouched {
+				t.Errorf("expected last accessed bumped %v, got %v", tt.wantTouched, project.LastAccessedAt)
+			}
+		})
+	}
+}
+
+func TestProjectHandler_CheckIdleProjects_WarnsOnce(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	project := store.projects[testProjectID]
+	timeout := 10
+	project.IdleTimeoutMinutes = &timeout
+	lastAccessed := time.Now().Add(-9 * time.Minute)
+	project.LastAccessedAt = &lastAccessed
+
+	probe := &mockWorkspaceProbe{}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, probe, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	handler.checkIdleProjects(context.Background(), time.Now())
+	handler.checkIdleProjects(context.Background(), time.Now())
+
+	if len(probe.notices) != 1 {
+		t.Errorf("expected a single warning, got %+v", probe.notices)
+	}
+}
+
+func TestProjectHandler_SnoozeIdle(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	probe := &mockWorkspaceProbe{}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, probe, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	before := time.Now()
+	rr := serveRoute(handler.SnoozeIdle, "POST", "/projects/{id}/idle/snooze", "/projects/"+testProjectID+"/idle/snooze", []byte(`{"minutes":30}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response SnoozeIdleResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if want := before.Add(30 * time.Minute); response.SnoozedUntil.Before(want) || response.SnoozedUntil.After(want.Add(time.Minute)) {
+		t.Errorf("expected snooze until about %v, got %v", want, response.SnoozedUntil)
+	}
+
+	snoozedUntil := store.projects[testProjectID].IdleSnoozedUntil
+	if snoozedUntil == nil || !snoozedUntil.Equal(response.SnoozedUntil) {
+		t.Errorf("expected project snoozed until %v, got %v", response.SnoozedUntil, snoozedUntil)
+	}
+	if len(probe.notices) != 1 || probe.notices[0].Type != "snoozed" {
+		t.Errorf("expected a snoozed notice, got %+v", probe.notices)
+	}
+}
+
+func TestProjectHandler_SnoozeIdle_Rejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		body       string
+		wantStatus int
+	}{
+		{name: "zero minutes", status: db.StatusRunning, body: `{"minutes":0}`, wantStatus: http.StatusBadRequest},
+		{name: "too long", status: db.StatusRunning, body: `{"minutes":1000}`, wantStatus: http.StatusBadRequest},
+		{name: "stopped project", status: db.StatusStopped, body: `{"minutes":30}`, wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newProjectFixture(tt.status)
+			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+			rr := serveRoute(handler.SnoozeIdle, "POST", "/projects/{id}/idle/snooze", "/projects/"+testProjectID+"/idle/snooze", []byte(tt.body))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if store.projects[testProjectID].IdleSnoozedUntil != nil {
+				t.Error("expected project not to be snoozed")
+			}
+		})
+	}
+}
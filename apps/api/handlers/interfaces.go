@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"aether/apps/api/db"
@@ -9,18 +10,92 @@ import (
 
 // ProjectStore defines the database operations needed by ProjectHandler
 type ProjectStore interface {
-	ListProjects(ctx context.Context, userID string) ([]db.Project, error)
+	ListProjects(ctx context.Context, userID string, opts db.ProjectListOptions) ([]db.Project, error)
 	GetProject(ctx context.Context, projectID string) (*db.Project, error)
 	GetProjectByUser(ctx context.Context, projectID, userID string) (*db.Project, error)
-	CreateProject(ctx context.Context, userID, name string, description *string, baseImage string, hw *db.HardwareConfig, idleTimeoutMinutes *int) (*db.Project, error)
-	UpdateProject(ctx context.Context, projectID, userID string, name, description *string) (*db.Project, error)
+	CreateProject(ctx context.Context, userID, name string, description *string, baseImage string, hw *db.HardwareConfig, idleTimeoutMinutes *int, tmpl *db.Template, envVarsEncrypted *string, tags []string, labels map[string]string, region string, organizationID *string) (*db.Project, error)
+	UpdateProject(ctx context.Context, projectID, userID string, name, description *string, wakeOnRequest *bool, tags []string, ifVersion *int64) (*db.Project, error)
+	SetProjectLabels(ctx context.Context, projectID, userID string, labels map[string]string, ifVersion *int64) (*db.Project, error)
+	UpdateProjectHardware(ctx context.Context, projectID, userID string, hw *db.HardwareConfig) (*db.Project, error)
+	ClearProjectHardwarePending(ctx context.Context, projectID string, applied *db.HardwareConfig) error
+	ClearProjectEnvPending(ctx context.Context, projectID string, applied *string) error
+	ClearProjectLabelsPending(ctx context.Context, projectID string, applied map[string]string) error
+	ForkProject(ctx context.Context, sourceID, userID, name string, description *string) (*db.Project, error)
 	DeleteProject(ctx context.Context, projectID, userID string) error
-	UpdateProjectStatus(ctx context.Context, projectID, status string, errorMsg *string) error
-	UpdateProjectMachine(ctx context.Context, projectID, machineID string) error
+	TransitionProjectStatus(ctx context.Context, projectID string, from []string, to string, errorMsg *string) error
+	UpdateProjectMachine(ctx context.Context, projectID, machineID, image string) error
+	DetachProjectMachine(ctx context.Context, projectID, machineID string) error
+	SetProjectImage(ctx context.Context, projectID, image string) (*db.Project, error)
 	UpdateProjectVolume(ctx context.Context, projectID, volumeID string) error
+	MoveProjectRegion(ctx context.Context, projectID, region string, volumeID *string) error
 	UpdateProjectLastAccessed(ctx context.Context, projectID string) error
 	GetRunningProjects(ctx context.Context) ([]db.Project, error)
+	ClaimProjectIdleWarning(ctx context.Context, projectID string, idleSince time.Time) (bool, error)
+	SnoozeProjectIdle(ctx context.Context, projectID string, until time.Time) error
 	GetUserSettings(ctx context.Context, userID string) (*db.UserSettings, error)
+	RecordProjectEvent(ctx context.Context, projectID, eventType string, message *string, data map[string]any) error
+	ClearProjectMachine(ctx context.Context, projectID, machineID string) (bool, error)
+	ClearProjectVolume(ctx context.Context, projectID, volumeID string) (bool, error)
+
+	// Hibernation
+	ListHibernationCandidates(ctx context.Context, inactiveSince time.Time, limit int) ([]db.Project, error)
+	SetProjectArchive(ctx context.Context, projectID, key string, sizeBytes int64) error
+	ClearProjectArchive(ctx context.Context, projectID, key string) error
+
+	// Trash
+	TrashProject(ctx context.Context, projectID string, from []string) error
+	RestoreTrashedProject(ctx context.Context, projectID, to string) error
+
+	// Organizations
+	GetOrganization(ctx context.Context, orgID, userID string) (*db.Organization, error)
+	SetProjectOrganization(ctx context.Context, projectID string, orgID *string, userID string) (*db.Project, error)
+
+	// Project templates
+	GetTemplate(ctx context.Context, templateID, userID string) (*db.Template, error)
+	ClearProjectTemplateSetup(ctx context.Context, projectID string) error
+
+	// Volume snapshots
+	CreateSnapshot(ctx context.Context, projectID, userID, volumeID, providerSnapshotID string, label *string, sizeBytes int64, createdBy string, expiresAt *time.Time) (*db.Snapshot, error)
+	ListSnapshots(ctx context.Context, projectID string) ([]db.Snapshot, error)
+	GetSnapshot(ctx context.Context, snapshotID, projectID string) (*db.Snapshot, error)
+	DeleteSnapshot(ctx context.Context, snapshotID, projectID string) error
+	DeleteExpiredSnapshots(ctx context.Context) (int64, error)
+	ReclaimDeletion(ctx context.Context, projectID string, updatedBefore time.Time) error
+
+	// Start and stop schedules
+	CreateSchedule(ctx context.Context, projectID, userID, action, cron, timezone string, enabled bool, nextRunAt *time.Time) (*db.Schedule, error)
+	ListSchedules(ctx context.Context, projectID string) ([]db.Schedule, error)
+	ListUserSchedules(ctx context.Context, userID string) ([]db.Schedule, error)
+	GetSchedule(ctx context.Context, scheduleID, projectID string) (*db.Schedule, error)
+	UpdateSchedule(ctx context.Context, scheduleID, projectID, action, cron, timezone string, enabled bool, nextRunAt *time.Time) (*db.Schedule, error)
+	DeleteSchedule(ctx context.Context, scheduleID, projectID string) error
+	ListDueSchedules(ctx context.Context, now time.Time) ([]db.Schedule, error)
+	ClaimScheduleRun(ctx context.Context, scheduleID string, dueAt time.Time, nextRunAt *time.Time) (bool, error)
+	RecordScheduleResult(ctx context.Context, scheduleID, result string, errorMsg, operationID *string) error
+
+	// Plan quotas
+	GetUserPlan(ctx context.Context, userID string) (*db.Plan, error)
+	GetQuotaUsage(ctx context.Context, userID string) (*db.QuotaUsage, error)
+	GetOrganizationPlan(ctx context.Context, orgID string) (*db.Plan, error)
+	GetOrganizationQuotaUsage(ctx context.Context, orgID string) (*db.QuotaUsage, error)
+	LockQuota(ctx context.Context, userID string, orgID *string) (func(), error)
+
+	// Usage ledger
+	OpenUsageInterval(ctx context.Context, interval *db.UsageInterval) error
+	CloseUsageInterval(ctx context.Context, projectID string, at time.Time) error
+	CloseStaleUsageIntervals(ctx context.Context, at time.Time) (int64, error)
+	GetProjectIDsWithOpenUsage(ctx context.Context) (map[string]bool, error)
+
+	// Lifecycle operations
+	BeginOperation(ctx context.Context, projectID, userID, opType string, from []string, to string) (*db.Operation, error)
+	BeginOperationWithParams(ctx context.Context, projectID, userID, opType string, params map[string]string, from []string, to string) (*db.Operation, error)
+	GetOperation(ctx context.Context, operationID, projectID string) (*db.Operation, error)
+	ClaimOperation(ctx context.Context, operationID, workerID string) (*db.Operation, error)
+	ClaimNextOperation(ctx context.Context, workerID string, staleAfter time.Duration) (*db.Operation, error)
+	UpdateOperationStep(ctx context.Context, operationID, workerID, step string) error
+	SetOperationParams(ctx context.Context, operationID, workerID string, params map[string]string) error
+	CompleteOperation(ctx context.Context, operationID, workerID string) error
+	FailOperation(ctx context.Context, operationID, workerID, errorMsg string) error
 }
 
 // MachineManager defines operations for managing compute instances.
@@ -28,18 +103,184 @@ type ProjectStore interface {
 type MachineManager interface {
 	CreateMachine(name string, config MachineConfig) (*Machine, error)
 	GetMachine(machineID string) (*Machine, error)
+	// UpdateMachine replaces a stopped machine's config; it stays stopped
+	UpdateMachine(machineID string, config MachineConfig) error
 	StartMachine(machineID string) error
 	StopMachine(machineID string) error
 	DeleteMachine(machineID string) error
 	WaitForState(machineID string, state string, timeout time.Duration) error
+	// Exec runs a command on a started machine and waits up to timeout for it to exit
+	Exec(machineID string, command []string, timeout time.Duration) (*ExecResult, error)
 }
 
+// ErrSnapshotDeleteUnsupported is returned by volume backends whose snapshots
+// can't be deleted, only left to expire
+var ErrSnapshotDeleteUnsupported = errors.New("backend does not support deleting snapshots")
+
 // VolumeManager defines operations for managing persistent storage.
 // Implementations include Fly.io volumes and local directories.
 type VolumeManager interface {
 	CreateVolume(name string, sizeGB int, region string) (*Volume, error)
 	GetVolume(volumeID string) (*Volume, error)
 	DeleteVolume(volumeID string) error
+	// ExtendVolume grows a volume to sizeGB; volumes can't shrink
+	ExtendVolume(volumeID string, sizeGB int) error
+	// ForkVolume creates a new volume holding a copy of the source volume's data
+	ForkVolume(sourceVolumeID, name string) (*Volume, error)
+	// CopyVolume is ForkVolume into another region
+	CopyVolume(sourceVolumeID, name, region string) (*Volume, error)
+
+	// CreateSnapshot takes a point-in-time snapshot of a volume
+	CreateSnapshot(volumeID string) (*Snapshot, error)
+	// DeleteSnapshot removes a snapshot from the backend. Backends whose snapshots
+	// only expire return ErrSnapshotDeleteUnsupported.
+	DeleteSnapshot(snapshotID string) error
+	// RestoreSnapshot puts a snapshot's data on the project's volume. Backends that
+	// can't restore in place return a new volume named `name` instead, and the caller
+	// must move the project onto it.
+	RestoreSnapshot(volumeID, snapshotID, name string) (*Volume, error)
+}
+
+// VolumeArchiver moves a project's volume contents to and from cold storage, so
+// the volume can be deleted while the project hibernates. Archiving and restoring
+// need the project's machine started with the volume mounted.
+type VolumeArchiver interface {
+	// ArchiveVolume uploads the volume's contents under key and returns the archive's size
+	ArchiveVolume(ctx context.Context, project *db.Project, key string) (int64, error)
+	// RestoreVolume unpacks the archive at key onto the project's volume
+	RestoreVolume(ctx context.Context, project *db.Project, key string) error
+	// DeleteArchive removes the archive at key; a missing archive is not an error
+	DeleteArchive(ctx context.Context, key string) error
+}
+
+// EventStore defines the database operations needed by the project event stream
+type EventStore interface {
+	ListProjectEventsSince(ctx context.Context, afterID int64, limit int) ([]db.ProjectEvent, error)
+	ListUserProjectEventsSince(ctx context.Context, userID string, afterID int64, limit int) ([]db.ProjectEvent, error)
+	ListOrganizationIDs(ctx context.Context, userID string) ([]string, error)
+	GetLatestProjectEventID(ctx context.Context) (int64, error)
+	DeleteProjectEventsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ResourceLister enumerates every machine and volume in the compute backend.
+// The reconciler uses it to find resources that no project references.
+type ResourceLister interface {
+	ListMachines() ([]Machine, error)
+	ListVolumes() ([]Volume, error)
+}
+
+// ReconcileStore defines the database operations needed by the reconciler
+type ReconcileStore interface {
+	GetAllProjects(ctx context.Context) ([]db.Project, error)
+	GetProjectIDsWithActiveOperations(ctx context.Context) (map[string]bool, error)
+	ReconcileProjectStatus(ctx context.Context, projectID, expected, status string) (bool, error)
+	ClearProjectMachine(ctx context.Context, projectID, machineID string) (bool, error)
+	ClearProjectVolume(ctx context.Context, projectID, volumeID string) (bool, error)
+}
+
+// RolloutStore defines the database operations needed by RolloutHandler
+type RolloutStore interface {
+	CreateRollout(ctx context.Context, image string, canaryPercent, batchSize int, createdBy string) (*db.Rollout, error)
+	ListRollouts(ctx context.Context) ([]db.Rollout, error)
+	ListActiveRollouts(ctx context.Context) ([]db.Rollout, error)
+	GetRollout(ctx context.Context, rolloutID string) (*db.Rollout, error)
+	UpdateRolloutStatus(ctx context.Context, rolloutID string, from []string, status string, pauseReason *string) (*db.Rollout, error)
+	LeaseRollout(ctx context.Context, rolloutID, workerID string, leaseFor time.Duration) (bool, error)
+	ListRolloutProjects(ctx context.Context, rolloutID string) ([]db.RolloutProject, error)
+	UpdateRolloutProject(ctx context.Context, rolloutID, projectID, status string, operationID, errorMsg *string) error
+	SetRolloutProjectsStatus(ctx context.Context, rolloutID string, from []string, status string) error
+	GetOperation(ctx context.Context, operationID, projectID string) (*db.Operation, error)
+}
+
+// TrashStore defines the database operations needed by TrashHandler
+type TrashStore interface {
+	GetProjectByUser(ctx context.Context, projectID, userID string) (*db.Project, error)
+	ListTrashedProjects(ctx context.Context, userID string) ([]db.Project, error)
+	ListExpiredTrash(ctx context.Context, deletedBefore time.Time, limit int) ([]db.Project, error)
+	ListStaleDeletions(ctx context.Context, updatedBefore time.Time, limit int) ([]db.Project, error)
+	RestoreTrashedProject(ctx context.Context, projectID, to string) error
+}
+
+// IdempotencyStore defines the database operations needed by IdempotencyHandler
+type IdempotencyStore interface {
+	ClaimIdempotencyKey(ctx context.Context, userID, key, requestHash string, expiresAt, staleBefore time.Time) (*db.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, userID, key string, status int, contentType, etag string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, userID, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+}
+
+// QuotaStore defines the database operations needed by QuotaHandler
+type QuotaStore interface {
+	ListPlans(ctx context.Context) ([]db.Plan, error)
+	GetUserPlan(ctx context.Context, userID string) (*db.Plan, error)
+	SetUserPlan(ctx context.Context, userID, planID string) error
+	GetQuotaUsage(ctx context.Context, userID string) (*db.QuotaUsage, error)
+	GetOrganization(ctx context.Context, orgID, userID string) (*db.Organization, error)
+	GetOrganizationPlan(ctx context.Context, orgID string) (*db.Plan, error)
+	SetOrganizationPlan(ctx context.Context, orgID, planID string) error
+	GetOrganizationQuotaUsage(ctx context.Context, orgID string) (*db.QuotaUsage, error)
+}
+
+// UsageStore defines the database operations needed by UsageHandler
+type UsageStore interface {
+	GetProjectByUser(ctx context.Context, projectID, userID string) (*db.Project, error)
+	ListUsageIntervals(ctx context.Context, q db.UsageQuery) ([]db.UsageInterval, error)
+}
+
+// LLMUsageRecorder stores the token usage agents report through the bridge
+type LLMUsageRecorder interface {
+	RecordLLMUsage(ctx context.Context, usage *db.LLMUsage) error
+}
+
+// AgentSpendGetter totals what agents spent against a budget
+type AgentSpendGetter interface {
+	GetAgentSpend(ctx context.Context, budget *db.AgentBudget, since time.Time) (float64, error)
+}
+
+// AgentBudgetChecker is what the agent bridges need to enforce agent budgets
+type AgentBudgetChecker interface {
+	GetAgentBudgetsFor(ctx context.Context, userID, projectID string) ([]db.AgentBudget, error)
+	GetAgentSpend(ctx context.Context, budget *db.AgentBudget, since time.Time) (float64, error)
+}
+
+// AgentBudgetStore defines the database operations needed by AgentBudgetHandler
+type AgentBudgetStore interface {
+	GetProjectByUser(ctx context.Context, projectID, userID string) (*db.Project, error)
+	CreateAgentBudget(ctx context.Context, budget *db.AgentBudget) (*db.AgentBudget, error)
+	ListAgentBudgets(ctx context.Context, userID string) ([]db.AgentBudget, error)
+	GetAgentBudget(ctx context.Context, budgetID, userID string) (*db.AgentBudget, error)
+	UpdateAgentBudget(ctx context.Context, budget *db.AgentBudget) (*db.AgentBudget, error)
+	ResetAgentBudget(ctx context.Context, budgetID, userID string, at time.Time) (*db.AgentBudget, error)
+	DeleteAgentBudget(ctx context.Context, budgetID, userID string) error
+	GetAgentSpend(ctx context.Context, budget *db.AgentBudget, since time.Time) (float64, error)
+}
+
+// LLMUsageStore defines the database operations needed by LLMUsageHandler
+type LLMUsageStore interface {
+	GetProjectByUser(ctx context.Context, projectID, userID string) (*db.Project, error)
+	SummarizeLLMUsage(ctx context.Context, q db.LLMUsageQuery, group string) ([]db.LLMUsageSummary, error)
+}
+
+// OrganizationStore defines the database operations needed by OrganizationHandler
+type OrganizationStore interface {
+	CreateOrganization(ctx context.Context, name, ownerID string) (*db.Organization, error)
+	ListOrganizations(ctx context.Context, userID string) ([]db.Organization, error)
+	GetOrganization(ctx context.Context, orgID, userID string) (*db.Organization, error)
+	UpdateOrganization(ctx context.Context, org *db.Organization) (*db.Organization, error)
+	DeleteOrganization(ctx context.Context, orgID string) error
+	GetUserSettings(ctx context.Context, userID string) (*db.UserSettings, error)
+
+	ListOrganizationMembers(ctx context.Context, orgID string) ([]db.OrganizationMember, error)
+	GetOrganizationMember(ctx context.Context, orgID, userID string) (*db.OrganizationMember, error)
+	SetOrganizationMemberRole(ctx context.Context, orgID, userID, role string) (*db.OrganizationMember, error)
+	RemoveOrganizationMember(ctx context.Context, orgID, userID string) error
+
+	CreateOrganizationInvitation(ctx context.Context, invitation *db.OrganizationInvitation) (*db.OrganizationInvitation, error)
+	ListOrganizationInvitations(ctx context.Context, orgID string) ([]db.OrganizationInvitation, error)
+	DeleteOrganizationInvitation(ctx context.Context, invitationID, orgID string) error
+	ListUserInvitations(ctx context.Context, userID string) ([]db.OrganizationInvitation, error)
+	AcceptOrganizationInvitation(ctx context.Context, invitationID, userID string) (*db.Organization, error)
+	DeclineOrganizationInvitation(ctx context.Context, invitationID, userID string) error
 }
 
 // ConnectionInfo contains connection details for a project's VM
@@ -54,3 +295,27 @@ type ConnectionInfo struct {
 type ConnectionResolver interface {
 	GetConnectionInfo(project *db.Project) (*ConnectionInfo, error)
 }
+
+// WorkspaceActivity is what the workspace service inside a project's VM reports
+// is going on, whether or not anyone has the workspace open
+type WorkspaceActivity struct {
+	AgentSessions      int     `json:"agent_sessions"`
+	CPULoad            float64 `json:"cpu_load"` // One-minute load average per CPU
+	PreviewConnections int     `json:"preview_connections"`
+}
+
+// IdleNotice is relayed to every client connected to a project's workspace on the
+// "idle" channel
+type IdleNotice struct {
+	Channel          string     `json:"channel"`
+	Type             string     `json:"type"` // warning or snoozed
+	StopsAt          *time.Time `json:"stops_at,omitempty"`
+	MaxSnoozeMinutes int        `json:"max_snooze_minutes,omitempty"`
+	SnoozedUntil     *time.Time `json:"snoozed_until,omitempty"`
+}
+
+// WorkspaceProbe talks to the workspace service inside a running project's VM
+type WorkspaceProbe interface {
+	GetActivity(ctx context.Context, project *db.Project) (*WorkspaceActivity, error)
+	NotifyIdle(ctx context.Context, project *db.Project, notice IdleNotice) error
+}
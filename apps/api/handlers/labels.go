@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type SetLabelsRequest struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// SetLabels replaces a project's labels. An existing machine's metadata is
+// marked pending and brought up to date on the project's next (re)start.
+func (h *ProjectHandler) SetLabels(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	ifVersion, ok := readIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req SetLabelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := validation.ValidateLabels(req.Labels); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	current, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for labels", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update labels")
+		return
+	}
+	if !allowProject(w, current, roleOperate) {
+		return
+	}
+
+	project, err := h.store.SetProjectLabels(ctx, projectID, userID, req.Labels, ifVersion)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		if errors.Is(err, db.ErrVersionMismatch) {
+			writePreconditionFailed(w, 0)
+			return
+		}
+		log.Error("failed to set project labels", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update labels")
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(project.Version))
+	WriteJSON(w, http.StatusOK, projectToResponse(project))
+}
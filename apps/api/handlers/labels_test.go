@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+func (m *mockProjectStore) SetProjectLabels(ctx context.Context, projectID, userID string, labels map[string]string, ifVersion *int64) (*db.Project, error) {
+	p, ok := m.projects[projectID]
+	if !ok || m.roleOn(p, userID) == "" {
+		return nil, db.ErrNotFound
+	}
+	if ifVersion != nil && *ifVersion != p.Version {
+		return nil, db.ErrVersionMismatch
+	}
+	p.Version++
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	p.LabelsPending = p.LabelsPending || (p.FlyMachineID != nil && !maps.Equal(p.Labels, labels))
+	p.Labels = labels
+	return p, nil
+}
+
+func (m *mockProjectStore) ClearProjectLabelsPending(ctx context.Context, projectID string, applied map[string]string) error {
+	if p, ok := m.projects[projectID]; ok && maps.Equal(p.Labels, applied) {
+		p.LabelsPending = false
+	}
+	return nil
+}
+
+func TestProjectHandler_SetLabels(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	store.projects[testProjectID].Labels = map[string]string{"team": "web"}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.SetLabels, "PUT", "/projects/{id}/labels", "/projects/"+testProjectID+"/labels", []byte(`{"labels":{"client":"acme","cost-center":"42"}}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response ProjectResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	// Labels are replaced, not merged
+	if len(response.Labels) != 2 || response.Labels["client"] != "acme" || response.Labels["cost-center"] != "42" {
+		t.Errorf("expected labels replaced, got %v", response.Labels)
+	}
+}
+
+func TestProjectHandler_SetLabels_Invalid(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	tests := []struct {
+		name      string
+		projectID string
+		body      string
+		want      int
+	}{
+		{"bad key", testProjectID, `{"labels":{"Client":"acme"}}`, http.StatusBadRequest},
+		{"reserved key", testProjectID, `{"labels":{"fly_process_group":"app"}}`, http.StatusBadRequest},
+		{"not an object", testProjectID, `{"labels":["acme"]}`, http.StatusBadRequest},
+		{"unknown project", "00000000-0000-0000-0000-000000000000", `{"labels":{}}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := serveRoute(handler.SetLabels, "PUT", "/projects/{id}/labels", "/projects/"+tt.projectID+"/labels", []byte(tt.body)); rr.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestProjectHandler_LabelsOnMachine(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	store.projects[testProjectID].Labels = map[string]string{"client": "acme"}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	config := handler.machineConfig(context.Background(), store.projects[testProjectID], "test-user-id")
+	if config.Labels["client"] != "acme" {
+		t.Errorf("expected project labels on machine config, got %v", config.Labels)
+	}
+}
+
+func TestProjectHandler_StartAppliesLabels(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	machineID := "machine-1"
+	store.projects[testProjectID].FlyMachineID = &machineID
+	machines := newMockMachineManager()
+	var applied map[string]string
+	machines.updateFn = func(machineID string, config MachineConfig) error {
+		applied = config.Labels
+		return nil
+	}
+	handler := NewProjectHandler(store, machines, newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.SetLabels, "PUT", "/projects/{id}/labels", "/projects/"+testProjectID+"/labels", []byte(`{"labels":{"client":"acme"}}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response ProjectResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !response.LabelsPending || applied != nil {
+		t.Fatalf("expected the machine's labels pending until start, got pending=%v applied=%v", response.LabelsPending, applied)
+	}
+
+	runTestOperation(t, store, handler, db.OperationStart, []string{db.StatusStopped}, db.StatusStarting)
+
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusRunning {
+		t.Errorf("expected running, got %s", p.Status)
+	}
+	if applied["client"] != "acme" {
+		t.Errorf("expected the new labels on the machine, got %v", applied)
+	}
+	if p.LabelsPending {
+		t.Error("expected labels pending cleared")
+	}
+}
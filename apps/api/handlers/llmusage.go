@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"aether/apps/api/db"
+	"aether/apps/api/metering"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// agentTypes are the agents a project can run
+var agentTypes = map[string]bool{"claude": true, "codex": true, "codebuff": true, "opencode": true}
+
+// defaultAgentType is the agent the workspace service runs for messages that
+// don't name one
+const defaultAgentType = "claude"
+
+// agentUsageMessage is the part of an agent message the bridge meters
+type agentUsageMessage struct {
+	Channel   string     `json:"channel"`
+	Agent     string     `json:"agent"`
+	SessionID string     `json:"sessionId"`
+	Usage     *UsageInfo `json:"usage"`
+}
+
+var (
+	sessionIDField = []byte(`"sessionId"`)
+	usageField     = []byte(`"usage"`)
+)
+
+// usageMeter watches the messages an agent sends through the bridge and records
+// the token usage they report against the user, project, agent and session
+type usageMeter struct {
+	store   LLMUsageRecorder
+	userID  string
+	project *db.Project
+	// agent is who a message is from when it doesn't say
+	agent string
+	// sessions is each agent's current session, from its init message
+	sessions map[string]string
+}
+
+func newUsageMeter(store LLMUsageRecorder, project *db.Project, userID, agent string) *usageMeter {
+	return &usageMeter{
+		store:    store,
+		userID:   userID,
+		project:  project,
+		agent:    agent,
+		sessions: make(map[string]string),
+	}
+}
+
+// observe records the usage one message from the VM reports, and returns it.
+// Messages without usage return nil. Only the goroutine forwarding the VM's
+// messages calls it.
+func (m *usageMeter) observe(ctx context.Context, data []byte) *db.LLMUsage {
+	// Most traffic is terminal output and file events; skip decoding it
+	if !bytes.Contains(data, usageField) && !bytes.Contains(data, sessionIDField) {
+		return nil
+	}
+	var msg agentUsageMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil
+	}
+	if msg.Channel != "" && msg.Channel != "agent" {
+		return nil
+	}
+	agent := msg.Agent
+	if agent == "" {
+		agent = m.agent
+	}
+	if !agentTypes[agent] {
+		return nil
+	}
+	if msg.SessionID != "" {
+		m.sessions[agent] = msg.SessionID
+	}
+
+	u := msg.Usage
+	if u == nil || u.InputTokens < 0 || u.OutputTokens < 0 || u.Cost < 0 {
+		return nil
+	}
+	if u.InputTokens == 0 && u.OutputTokens == 0 && u.Cost == 0 {
+		return nil
+	}
+
+	usage := &db.LLMUsage{
+		UserID:       m.userID,
+		ProjectID:    &m.project.ID,
+		ProjectName:  m.project.Name,
+		Agent:        agent,
+		Model:        u.Model,
+		InputTokens:  u.InputTokens,
+		OutputTokens: u.OutputTokens,
+		CostUSD:      u.Cost,
+	}
+	if usage.Model == "" {
+		usage.Model = "unknown"
+	}
+	if session := m.sessions[agent]; session != "" {
+		usage.SessionID = &session
+	}
+	if usage.CostUSD == 0 {
+		usage.CostUSD = metering.EstimateTokenCost(agent, u.InputTokens, u.OutputTokens)
+		usage.CostEstimated = true
+	}
+
+	if err := m.store.RecordLLMUsage(ctx, usage); err != nil {
+		logging.FromContext(ctx).Error("failed to record agent usage", "agent", agent, "error", err)
+	}
+	return usage
+}
+
+const maxLLMUsageDays = 366
+
+// LLMUsageTotals is the usage across every group of a report
+type LLMUsageTotals struct {
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// LLMUsageReport is agent token usage and its cost over a range, grouped by
+// project, agent, model, session, day or month
+type LLMUsageReport struct {
+	GroupBy string               `json:"group_by"`
+	From    time.Time            `json:"from"`
+	To      time.Time            `json:"to"`
+	Groups  []db.LLMUsageSummary `json:"groups"`
+	Total   LLMUsageTotals       `json:"total"`
+}
+
+// LLMUsageHandler reports the tokens agents used and what they cost
+type LLMUsageHandler struct {
+	store LLMUsageStore
+}
+
+func NewLLMUsageHandler(store LLMUsageStore) *LLMUsageHandler {
+	return &LLMUsageHandler{store: store}
+}
+
+// parseLLMUsageQuery reads group_by, and from and to (dates, to exclusive). The
+// range defaults to the current month so far.
+func parseLLMUsageQuery(query url.Values, now time.Time, defaultGroup string) (string, db.LLMUsageQuery, validation.ValidationErrors) {
+	var errs validation.ValidationErrors
+	var q db.LLMUsageQuery
+
+	group := query.Get("group_by")
+	if group == "" {
+		group = defaultGroup
+	}
+	if !db.ValidLLMUsageGroup(group) {
+		errs = append(errs, validation.ValidationError{Field: "group_by", Message: "must be project, agent, model, session, day or month"})
+	}
+
+	var err *validation.ValidationError
+	if q.To, err = parseDate(query, "to", metering.Truncate(now, metering.PeriodDay).AddDate(0, 0, 1)); err != nil {
+		errs = append(errs, *err)
+	}
+	if q.From, err = parseDate(query, "from", metering.Truncate(now, metering.PeriodMonth)); err != nil {
+		errs = append(errs, *err)
+	}
+
+	switch {
+	case errs.HasErrors():
+		// A date that didn't parse leaves nothing to compare
+	case !q.From.Before(q.To):
+		errs = append(errs, validation.ValidationError{Field: "from", Message: "must be before to"})
+	case q.To.After(q.From.AddDate(0, 0, maxLLMUsageDays)):
+		errs = append(errs, validation.ValidationError{Field: "from", Message: "reports cover at most 366 days"})
+	}
+	return group, q, errs
+}
+
+// GetUserLLMUsage reports the user's agent usage across all their projects,
+// by project unless asked otherwise
+func (h *LLMUsageHandler) GetUserLLMUsage(w http.ResponseWriter, r *http.Request) {
+	h.serveLLMUsage(w, r, nil, db.LLMUsageByProject)
+}
+
+// GetProjectLLMUsage reports one project's agent usage, by agent unless asked
+// otherwise
+func (h *LLMUsageHandler) GetProjectLLMUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	if _, err := h.store.GetProjectByUser(ctx, projectID, userID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for agent usage", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get agent usage")
+		return
+	}
+
+	h.serveLLMUsage(w, r, &projectID, db.LLMUsageByAgent)
+}
+
+func (h *LLMUsageHandler) serveLLMUsage(w http.ResponseWriter, r *http.Request, projectID *string, defaultGroup string) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	group, q, errs := parseLLMUsageQuery(r.URL.Query(), time.Now().UTC(), defaultGroup)
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+	q.UserID = authmw.GetUserID(ctx)
+	q.ProjectID = projectID
+
+	groups, err := h.store.SummarizeLLMUsage(ctx, q, group)
+	if err != nil {
+		log.Error("failed to summarize agent usage", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get agent usage")
+		return
+	}
+	if groups == nil {
+		groups = []db.LLMUsageSummary{}
+	}
+
+	report := LLMUsageReport{GroupBy: group, From: q.From, To: q.To, Groups: groups}
+	for _, g := range groups {
+		report.Total.Requests += g.Requests
+		report.Total.InputTokens += g.InputTokens
+		report.Total.OutputTokens += g.OutputTokens
+		report.Total.CostUSD += g.CostUSD
+	}
+	report.Total.CostUSD = math.Round(report.Total.CostUSD*1e6) / 1e6
+	WriteJSON(w, http.StatusOK, report)
+}
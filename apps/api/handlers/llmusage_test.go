@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (m *mockProjectStore) RecordLLMUsage(ctx context.Context, usage *db.LLMUsage) error {
+	usage.CreatedAt = time.Now()
+	m.llmUsage = append(m.llmUsage, *usage)
+	return nil
+}
+
+func (m *mockProjectStore) SummarizeLLMUsage(ctx context.Context, q db.LLMUsageQuery, group string) ([]db.LLMUsageSummary, error) {
+	byKey := make(map[string]*db.LLMUsageSummary)
+	var keys []string
+	for _, u := range m.llmUsage {
+		if q.ProjectID == nil && u.UserID != q.UserID {
+			continue
+		}
+		if q.ProjectID != nil && (u.ProjectID == nil || *u.ProjectID != *q.ProjectID) {
+			continue
+		}
+		if u.CreatedAt.Before(q.From) || !u.CreatedAt.Before(q.To) {
+			continue
+		}
+		key := u.Agent
+		if group == db.LLMUsageByProject {
+			key = *u.ProjectID
+		}
+		s, ok := byKey[key]
+		if !ok {
+			s = &db.LLMUsageSummary{Key: key}
+			byKey[key] = s
+			keys = append(keys, key)
+		}
+		s.Requests++
+		s.InputTokens += int64(u.InputTokens)
+		s.OutputTokens += int64(u.OutputTokens)
+		s.CostUSD += u.CostUSD
+	}
+	sort.Strings(keys)
+	var result []db.LLMUsageSummary
+	for _, key := range keys {
+		result = append(result, *byKey[key])
+	}
+	return result, nil
+}
+
+func TestUsageMeter(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	meter := newUsageMeter(store, store.projects[testProjectID], "test-user-id", defaultAgentType)
+	ctx := context.Background()
+
+	messages := []string{
+		`{"channel":"agent","type":"init","sessionId":"session-1","agent":"claude"}`,
+		`{"channel":"terminal","type":"output","data":"\"usage\": 100"}`,
+		`{"channel":"agent","type":"text","content":"hello","agent":"claude"}`,
+		`{"channel":"agent","type":"done","agent":"claude","usage":{"inputTokens":1000,"outputTokens":500,"cost":0.25,"model":"claude-sonnet-4-5"}}`,
+		`{"channel":"agent","type":"done","agent":"codex","usage":{"inputTokens":1000000,"outputTokens":0}}`,
+		`{"channel":"agent","type":"done","agent":"claude","usage":{"inputTokens":0,"outputTokens":0}}`,
+		`{"channel":"agent","type":"done","agent":"gpt-9","usage":{"inputTokens":10,"outputTokens":10}}`,
+		`not json with "usage"`,
+	}
+	var recorded int
+	for _, msg := range messages {
+		if meter.observe(ctx, []byte(msg)) != nil {
+			recorded++
+		}
+	}
+
+	if recorded != 2 || len(store.llmUsage) != 2 {
+		t.Fatalf("expected 2 usage records, got %d returned and %d stored", recorded, len(store.llmUsage))
+	}
+	claude, codex := store.llmUsage[0], store.llmUsage[1]
+	if claude.Agent != "claude" || claude.SessionID == nil || *claude.SessionID != "session-1" || claude.Model != "claude-sonnet-4-5" {
+		t.Errorf("expected claude usage in session-1, got %+v", claude)
+	}
+	if claude.CostUSD != 0.25 || claude.CostEstimated || *claude.ProjectID != testProjectID || claude.UserID != "test-user-id" {
+		t.Errorf("expected the reported cost against the project, got %+v", claude)
+	}
+	if codex.Agent != "codex" || codex.SessionID != nil || codex.Model != "unknown" {
+		t.Errorf("expected codex usage without a session, got %+v", codex)
+	}
+	if !codex.CostEstimated || codex.CostUSD != 1.25 {
+		t.Errorf("expected a million codex input tokens estimated at 1.25, got %+v", codex)
+	}
+
+	// The agent endpoint's messages have no channel and name no agent
+	direct := newUsageMeter(store, store.projects[testProjectID], "test-user-id", "opencode")
+	if u := direct.observe(ctx, []byte(`{"type":"done","usage":{"inputTokens":10,"outputTokens":20,"cost":0.01}}`)); u == nil || u.Agent != "opencode" {
+		t.Errorf("expected usage recorded for the endpoint's agent, got %+v", u)
+	}
+}
+
+func TestLLMUsageHandler(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	projectID := testProjectID
+	at := time.Date(2026, 9, 2, 10, 0, 0, 0, time.UTC)
+	store.llmUsage = []db.LLMUsage{
+		{UserID: "test-user-id", ProjectID: &projectID, Agent: "claude", InputTokens: 100, OutputTokens: 50, CostUSD: 0.5, CreatedAt: at},
+		{UserID: "test-user-id", ProjectID: &projectID, Agent: "claude", InputTokens: 200, OutputTokens: 10, CostUSD: 0.25, CreatedAt: at},
+		{UserID: "test-user-id", ProjectID: &projectID, Agent: "codex", InputTokens: 300, OutputTokens: 0, CostUSD: 1, CreatedAt: at},
+		{UserID: "test-user-id", ProjectID: &projectID, Agent: "codex", InputTokens: 999, CostUSD: 9, CreatedAt: at.AddDate(0, 1, 0)},
+	}
+	handler := NewLLMUsageHandler(store)
+
+	router := chi.NewRouter()
+	router.Get("/user/llm-usage", handler.GetUserLLMUsage)
+	router.Get("/projects/{id}/llm-usage", handler.GetProjectLLMUsage)
+	serve := func(path string) (int, LLMUsageReport) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newAuthenticatedRequest("GET", path, nil))
+		var report LLMUsageReport
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return rr.Code, report
+	}
+
+	code, report := serve("/projects/" + testProjectID + "/llm-usage?from=2026-09-01&to=2026-10-01")
+	if code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if report.GroupBy != db.LLMUsageByAgent || len(report.Groups) != 2 || report.Groups[0].Key != "claude" || report.Groups[0].Requests != 2 {
+		t.Errorf("expected the project's usage by agent, got %+v", report)
+	}
+	if report.Total.Requests != 3 || report.Total.InputTokens != 600 || report.Total.CostUSD != 1.75 {
+		t.Errorf("unexpected totals: %+v", report.Total)
+	}
+
+	code, report = serve("/user/llm-usage?from=2026-09-01&to=2026-11-01")
+	if code != http.StatusOK || report.GroupBy != db.LLMUsageByProject || len(report.Groups) != 1 || report.Total.CostUSD != 10.75 {
+		t.Errorf("expected the user's usage by project, got %d %+v", code, report)
+	}
+
+	rejected := []struct {
+		path string
+		code int
+	}{
+		{"/user/llm-usage?group_by=week", http.StatusBadRequest},
+		{"/user/llm-usage?from=2026-09-03&to=2026-09-01", http.StatusBadRequest},
+		{"/user/llm-usage?from=2024-01-01&to=2026-01-01", http.StatusBadRequest},
+		{"/user/llm-usage?to=tomorrow", http.StatusBadRequest},
+		{"/projects/not-a-uuid/llm-usage", http.StatusBadRequest},
+		{"/projects/7c9e6679-7425-40de-944b-e07fc1f90ae7/llm-usage", http.StatusNotFound},
+	}
+	for _, tt := range rejected {
+		if code, _ := serve(tt.path); code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.code, code)
+		}
+	}
+}
+
+func TestLLMUsageHandler_ProjectUsageCoversAllMembers(t *testing.T) {
+	store := newOrgFixture(db.RoleViewer)
+	projectID := testProjectID
+	at := time.Date(2026, 9, 2, 10, 0, 0, 0, time.UTC)
+	store.llmUsage = []db.LLMUsage{
+		{UserID: "owner-id", ProjectID: &projectID, Agent: "claude", InputTokens: 100, CostUSD: 0.5, CreatedAt: at},
+		{UserID: "test-user-id", ProjectID: &projectID, Agent: "claude", InputTokens: 50, CostUSD: 0.25, CreatedAt: at},
+	}
+	handler := NewLLMUsageHandler(store)
+
+	router := chi.NewRouter()
+	router.Get("/projects/{id}/llm-usage", handler.GetProjectLLMUsage)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, newAuthenticatedRequest("GET", "/projects/"+testProjectID+"/llm-usage?from=2026-09-01&to=2026-10-01", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var report LLMUsageReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.Total.Requests != 2 || report.Total.CostUSD != 0.75 {
+		t.Errorf("expected every member's usage of the project, got %+v", report.Total)
+	}
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/quota"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// maxOperationAttempts caps how many times an operation is claimed before it is abandoned
+	maxOperationAttempts = 3
+	// operationStaleAfter is how long a running operation may go without a heartbeat
+	// before another replica assumes its worker died. Must exceed the longest single
+	// step (WaitForState timeouts).
+	operationStaleAfter = 2 * time.Minute
+)
+
+type OperationResponse struct {
+	ID          string     `json:"id"`
+	ProjectID   string     `json:"project_id"`
+	Type        string     `json:"type"`
+	Status      string     `json:"status"`
+	Step        string     `json:"step"`
+	Attempts    int        `json:"attempts"`
+	LastError   *string    `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+func operationToResponse(op *db.Operation) OperationResponse {
+	return OperationResponse{
+		ID:          op.ID,
+		ProjectID:   op.ProjectID,
+		Type:        op.Type,
+		Status:      op.Status,
+		Step:        op.Step,
+		Attempts:    op.Attempts,
+		LastError:   op.LastError,
+		CreatedAt:   op.CreatedAt,
+		UpdatedAt:   op.UpdatedAt,
+		CompletedAt: op.CompletedAt,
+	}
+}
+
+// GetOperation returns a single lifecycle operation for polling
+func (h *ProjectHandler) GetOperation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	operationID := chi.URLParam(r, "opId")
+	log := logging.FromContext(ctx)
+
+	var errs validation.ValidationErrors
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		errs = append(errs, *err)
+	}
+	if err := validation.ValidateUUID(operationID, "opId"); err != nil {
+		errs = append(errs, *err)
+	}
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+
+	// Verify project ownership before exposing its operations
+	if _, err := h.store.GetProjectByUser(ctx, projectID, userID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for operation", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get operation")
+		return
+	}
+
+	op, err := h.store.GetOperation(ctx, operationID, projectID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Operation not found")
+			return
+		}
+		log.Error("failed to get operation", "operation_id", operationID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get operation")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, operationToResponse(op))
+}
+
+// dispatchOperation claims a freshly created operation and runs it in the background.
+// If another worker claims it first, that worker runs it instead.
+func (h *ProjectHandler) dispatchOperation(op *db.Operation) {
+	go func() {
+		ctx := context.Background()
+		log := logging.Default().With("operation_id", op.ID, "project_id", op.ProjectID)
+
+		claimed, err := h.store.ClaimOperation(ctx, op.ID, h.workerID)
+		if err != nil {
+			if !errors.Is(err, db.ErrNotFound) {
+				log.Error("failed to claim operation", "error", err)
+			}
+			return
+		}
+		h.runOperation(ctx, claimed)
+	}()
+}
+
+// runOperation executes a claimed operation to completion
+func (h *ProjectHandler) runOperation(ctx context.Context, op *db.Operation) {
+	log := logging.Default().With("operation_id", op.ID, "project_id", op.ProjectID, "type", op.Type, "attempt", op.Attempts)
+
+	if op.Attempts > maxOperationAttempts {
+		errMsg := fmt.Sprintf("operation abandoned after %d attempts", maxOperationAttempts)
+		log.Error("giving up on operation", "last_step", op.Step)
+		if err := h.store.FailOperation(ctx, op.ID, h.workerID, errMsg); err != nil {
+			log.Error("failed to mark operation failed", "error", err)
+		}
+		if err := h.store.TransitionProjectStatus(ctx, op.ProjectID, db.TransitionSources(db.StatusError), db.StatusError, &errMsg); err != nil {
+			log.Error("failed to update project status", "error", err)
+		}
+		return
+	}
+
+	// Always work from the latest project state so resumed steps are idempotent
+	project, err := h.store.GetProject(ctx, op.ProjectID)
+	if err != nil {
+		log.Error("failed to load project for operation", "error", err)
+		if err := h.store.FailOperation(ctx, op.ID, h.workerID, "failed to load project: "+err.Error()); err != nil {
+			log.Error("failed to mark operation failed", "error", err)
+		}
+		return
+	}
+
+	var runErr error
+	switch op.Type {
+	case db.OperationStart:
+		runErr = h.startMachineAsync(ctx, op, project)
+	case db.OperationStop:
+		runErr = h.stopMachineAsync(ctx, op, project)
+	case db.OperationRestart, db.OperationUpgrade:
+		// An upgrade is a restart; starting recreates the machine on the new image
+		runErr = h.restartMachineAsync(ctx, op, project)
+	case db.OperationHibernate:
+		runErr = h.hibernateAsync(ctx, op, project)
+	case db.OperationMove:
+		runErr = h.moveAsync(ctx, op, project)
+	case db.OperationRestore:
+		runErr = h.restoreSnapshotAsync(ctx, op, project)
+	default:
+		runErr = fmt.Errorf("unknown operation type %q", op.Type)
+	}
+
+	if runErr != nil {
+		if errors.Is(runErr, db.ErrOperationNotClaimed) {
+			log.Warn("operation was taken over by another worker")
+			return
+		}
+		if err := h.store.FailOperation(ctx, op.ID, h.workerID, runErr.Error()); err != nil {
+			log.Error("failed to mark operation failed", "error", err)
+		}
+		return
+	}
+
+	if err := h.store.CompleteOperation(ctx, op.ID, h.workerID); err != nil {
+		log.Error("failed to mark operation succeeded", "error", err)
+		return
+	}
+	log.Info("operation succeeded")
+}
+
+// setOperationStep records the step an operation is about to run and refreshes its heartbeat
+func (h *ProjectHandler) setOperationStep(ctx context.Context, op *db.Operation, step string) error {
+	if err := h.store.UpdateOperationStep(ctx, op.ID, h.workerID, step); err != nil {
+		return err
+	}
+	op.Step = step
+	return nil
+}
+
+// keepOperationAlive refreshes an operation's heartbeat while a long step runs, so
+// other workers don't take it over as abandoned. Call the returned function when
+// the step ends.
+func (h *ProjectHandler) keepOperationAlive(ctx context.Context, op *db.Operation) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(operationStaleAfter / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := h.store.UpdateOperationStep(ctx, op.ID, h.workerID, op.Step); err != nil {
+					logging.Default().Warn("failed to refresh operation heartbeat", "operation_id", op.ID, "error", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// failProject moves a project to the error state and returns the message as an error
+func (h *ProjectHandler) failProject(ctx context.Context, log *logging.Logger, projectID, errMsg string) error {
+	if err := h.store.TransitionProjectStatus(ctx, projectID, db.TransitionSources(db.StatusError), db.StatusError, &errMsg); err != nil {
+		log.Error("failed to update project status", "error", err)
+	}
+	return errors.New(errMsg)
+}
+
+// completeTransition finishes an operation's status change. A resumed operation
+// may find the transition already applied, which counts as success.
+func (h *ProjectHandler) completeTransition(ctx context.Context, projectID, from, to string) error {
+	err := h.store.TransitionProjectStatus(ctx, projectID, []string{from}, to, nil)
+	var transitionErr *db.TransitionError
+	if errors.As(err, &transitionErr) && transitionErr.Current == to {
+		return nil
+	}
+	return err
+}
+
+// writeTransitionError maps lifecycle errors to responses. Conflicts carry the
+// project's current status so callers can decide whether to retry, and quota
+// rejections the limit that was hit.
+func (h *ProjectHandler) writeTransitionError(w http.ResponseWriter, log *logging.Logger, err error, action string) {
+	var transitionErr *db.TransitionError
+	var exceeded *quota.Exceeded
+	switch {
+	case errors.As(err, &exceeded):
+		writeQuotaError(w, exceeded)
+	case errors.As(err, &transitionErr):
+		WriteJSON(w, http.StatusConflict, map[string]string{
+			"error":          "Cannot " + action + " project while it is " + transitionErr.Current,
+			"current_status": transitionErr.Current,
+		})
+	case errors.Is(err, db.ErrOperationInProgress):
+		WriteError(w, http.StatusConflict, "Another operation is already in progress for this project")
+	case errors.Is(err, db.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "Project not found")
+	default:
+		log.Error("failed to "+action+" project", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to "+action+" project")
+	}
+}
+
+// StartOperationWorker starts a background goroutine that claims and resumes
+// operations left pending or abandoned by a crashed replica
+func (h *ProjectHandler) StartOperationWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			h.resumeOperations()
+		}
+	}()
+}
+
+func (h *ProjectHandler) resumeOperations() {
+	ctx := context.Background()
+	log := logging.Default()
+
+	for {
+		op, err := h.store.ClaimNextOperation(ctx, h.workerID, operationStaleAfter)
+		if err != nil {
+			if !errors.Is(err, db.ErrNotFound) {
+				log.Error("failed to claim operation", "error", err)
+			}
+			return
+		}
+
+		log.Info("resuming operation", "operation_id", op.ID, "project_id", op.ProjectID, "type", op.Type, "step", op.Step, "attempt", op.Attempts)
+		go h.runOperation(ctx, op)
+	}
+}
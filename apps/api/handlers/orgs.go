@@ -0,0 +1,772 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/quota"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Roles a project action needs. Anyone who can see a project can read it;
+// personal projects give their owner every role.
+const (
+	// roleOperate uses a project: agents, terminals, start, stop and edits
+	roleOperate = db.RoleMember
+	// roleManage changes what a project is or where it lives: hardware, env
+	// vars, snapshot restores, moves and deletion
+	roleManage = db.RoleAdmin
+)
+
+// invitationTTL is how long an invitation stays open
+const invitationTTL = 7 * 24 * time.Hour
+
+// allowProject writes a 403 and returns false unless the caller's role on the
+// project grants min
+func allowProject(w http.ResponseWriter, project *db.Project, min string) bool {
+	if db.RoleAtLeast(project.Role, min) {
+		return true
+	}
+	WriteError(w, http.StatusForbidden, "Your role on this project doesn't allow this")
+	return false
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name *string `json:"name,omitempty"`
+}
+
+// UpdateOrganizationSettingsRequest replaces an organization's defaults. Leaving
+// one out, or null, lets members' own settings apply.
+type UpdateOrganizationSettingsRequest struct {
+	DefaultHardware           *HardwareSettingsRequest `json:"default_hardware"`
+	DefaultIdleTimeoutMinutes *int                     `json:"default_idle_timeout_minutes"`
+}
+
+type UpdateOrganizationMemberRequest struct {
+	Role string `json:"role"`
+}
+
+type CreateOrganizationInvitationRequest struct {
+	Email string `json:"email"`
+	// Role defaults to member
+	Role string `json:"role,omitempty"`
+}
+
+type SetProjectOrganizationRequest struct {
+	// OrganizationID is the organization to move the project into; null makes
+	// it a personal project of its creator again
+	OrganizationID *string `json:"organization_id"`
+}
+
+type OrganizationResponse struct {
+	ID                        string          `json:"id"`
+	Name                      string          `json:"name"`
+	Role                      string          `json:"role"`
+	DefaultHardware           *HardwareConfig `json:"default_hardware"`
+	DefaultIdleTimeoutMinutes *int            `json:"default_idle_timeout_minutes"`
+	CreatedAt                 time.Time       `json:"created_at"`
+	UpdatedAt                 time.Time       `json:"updated_at"`
+}
+
+type OrganizationListResponse struct {
+	Organizations []OrganizationResponse `json:"organizations"`
+}
+
+type OrganizationMemberListResponse struct {
+	Members []db.OrganizationMember `json:"members"`
+}
+
+type OrganizationInvitationListResponse struct {
+	Invitations []db.OrganizationInvitation `json:"invitations"`
+}
+
+func organizationToResponse(o *db.Organization) OrganizationResponse {
+	resp := OrganizationResponse{
+		ID:                        o.ID,
+		Name:                      o.Name,
+		Role:                      o.Role,
+		DefaultIdleTimeoutMinutes: o.DefaultIdleTimeoutMinutes,
+		CreatedAt:                 o.CreatedAt,
+		UpdatedAt:                 o.UpdatedAt,
+	}
+	if hw := o.DefaultHardware; hw != nil {
+		resp.DefaultHardware = &HardwareConfig{
+			CPUKind:      hw.CPUKind,
+			CPUs:         hw.CPUs,
+			MemoryMB:     hw.MemoryMB,
+			VolumeSizeGB: hw.VolumeSizeGB,
+			GPUKind:      hw.GPUKind,
+		}
+	}
+	return resp
+}
+
+// OrganizationHandler manages organizations, their members and invitations
+type OrganizationHandler struct {
+	store OrganizationStore
+}
+
+func NewOrganizationHandler(store OrganizationStore) *OrganizationHandler {
+	return &OrganizationHandler{store: store}
+}
+
+// routeUUID validates a UUID route parameter. It writes the error response and
+// returns "" if it isn't valid.
+func routeUUID(w http.ResponseWriter, r *http.Request, param string) string {
+	id := chi.URLParam(r, param)
+	if err := validation.ValidateUUID(id, param); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return ""
+	}
+	return id
+}
+
+// routeMemberID is the route's member ID. Callers may always name themselves;
+// anyone else must be named by a valid ID. It writes the error response and
+// returns "" otherwise.
+func routeMemberID(w http.ResponseWriter, r *http.Request) string {
+	if id := chi.URLParam(r, "userId"); id == authmw.GetUserID(r.Context()) {
+		return id
+	}
+	return routeUUID(w, r, "userId")
+}
+
+// organization gets the route's organization with the caller's role on it,
+// which must grant min. It writes the error response and returns nil otherwise.
+func (h *OrganizationHandler) organization(w http.ResponseWriter, r *http.Request, min string) *db.Organization {
+	ctx := r.Context()
+	orgID := routeUUID(w, r, "orgId")
+	if orgID == "" {
+		return nil
+	}
+
+	org, err := h.store.GetOrganization(ctx, orgID, authmw.GetUserID(ctx))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Organization not found")
+			return nil
+		}
+		logging.FromContext(ctx).Error("failed to get organization", "organization_id", orgID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get organization")
+		return nil
+	}
+	if !db.RoleAtLeast(org.Role, min) {
+		WriteError(w, http.StatusForbidden, "Your role in this organization doesn't allow this")
+		return nil
+	}
+	return org
+}
+
+// List returns the organizations the user belongs to
+func (h *OrganizationHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+
+	orgs, err := h.store.ListOrganizations(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to list organizations", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list organizations")
+		return
+	}
+
+	response := OrganizationListResponse{Organizations: make([]OrganizationResponse, len(orgs))}
+	for i := range orgs {
+		response.Organizations[i] = organizationToResponse(&orgs[i])
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// Create creates an organization owned by the user
+func (h *OrganizationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	var req CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validation.ValidateOrganizationName(req.Name); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	org, err := h.store.CreateOrganization(ctx, strings.TrimSpace(req.Name), userID)
+	if err != nil {
+		log.Error("failed to create organization", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to create organization")
+		return
+	}
+
+	log.Info("organization created", "organization_id", org.ID)
+	WriteJSON(w, http.StatusCreated, organizationToResponse(org))
+}
+
+func (h *OrganizationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	org := h.organization(w, r, db.RoleViewer)
+	if org == nil {
+		return
+	}
+	WriteJSON(w, http.StatusOK, organizationToResponse(org))
+}
+
+// Update renames an organization
+func (h *OrganizationHandler) Update(w http.ResponseWriter, r *http.Request) {
+	org := h.organization(w, r, db.RoleAdmin)
+	if org == nil {
+		return
+	}
+
+	var req UpdateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Name != nil {
+		if err := validation.ValidateOrganizationName(*req.Name); err != nil {
+			WriteJSON(w, http.StatusBadRequest, map[string]any{
+				"error":  "Validation failed",
+				"errors": []validation.ValidationError{*err},
+			})
+			return
+		}
+		org.Name = strings.TrimSpace(*req.Name)
+	}
+
+	h.writeUpdated(w, r, org)
+}
+
+// writeUpdated saves an organization and writes it back
+func (h *OrganizationHandler) writeUpdated(w http.ResponseWriter, r *http.Request, org *db.Organization) {
+	ctx := r.Context()
+	updated, err := h.store.UpdateOrganization(ctx, org)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Organization not found")
+			return
+		}
+		logging.FromContext(ctx).Error("failed to update organization", "organization_id", org.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update organization")
+		return
+	}
+	WriteJSON(w, http.StatusOK, organizationToResponse(updated))
+}
+
+// Delete deletes an organization once it has no projects
+func (h *OrganizationHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+	org := h.organization(w, r, db.RoleOwner)
+	if org == nil {
+		return
+	}
+
+	if err := h.store.DeleteOrganization(ctx, org.ID); err != nil {
+		if errors.Is(err, db.ErrOrganizationHasProjects) {
+			WriteError(w, http.StatusConflict, "Move or delete the organization's projects, including trashed ones, first")
+			return
+		}
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Organization not found")
+			return
+		}
+		log.Error("failed to delete organization", "organization_id", org.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to delete organization")
+		return
+	}
+
+	log.Info("organization deleted", "organization_id", org.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSettings returns the settings the caller's projects in the organization
+// start from: their own settings with the organization's defaults laid over
+func (h *OrganizationHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org := h.organization(w, r, db.RoleViewer)
+	if org == nil {
+		return
+	}
+
+	settings, err := h.store.GetUserSettings(ctx, authmw.GetUserID(ctx))
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to get user settings", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get settings")
+		return
+	}
+	WriteJSON(w, http.StatusOK, userSettingsToResponse(org.ApplyDefaults(settings)))
+}
+
+// UpdateSettings replaces the organization's default settings
+func (h *OrganizationHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	org := h.organization(w, r, db.RoleAdmin)
+	if org == nil {
+		return
+	}
+
+	var req UpdateOrganizationSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var errs validation.ValidationErrors
+	org.DefaultHardware = nil
+	if hw := req.DefaultHardware; hw != nil {
+		_, hwErrors := validation.ValidateHardwareConfig(hw.CPUKind, hw.CPUs, hw.MemoryMB, hw.VolumeSizeGB, hw.GPUKind)
+		errs = append(errs, hwErrors...)
+		org.DefaultHardware = &db.HardwareConfig{
+			CPUKind:      hw.CPUKind,
+			CPUs:         hw.CPUs,
+			MemoryMB:     hw.MemoryMB,
+			VolumeSizeGB: hw.VolumeSizeGB,
+			GPUKind:      hw.GPUKind,
+		}
+	}
+	if err := validation.ValidateIdleTimeout(req.DefaultIdleTimeoutMinutes); err != nil {
+		errs = append(errs, *err)
+	}
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+	org.DefaultIdleTimeoutMinutes = req.DefaultIdleTimeoutMinutes
+
+	h.writeUpdated(w, r, org)
+}
+
+func (h *OrganizationHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org := h.organization(w, r, db.RoleViewer)
+	if org == nil {
+		return
+	}
+
+	members, err := h.store.ListOrganizationMembers(ctx, org.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to list organization members", "organization_id", org.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list members")
+		return
+	}
+	if members == nil {
+		members = []db.OrganizationMember{}
+	}
+	WriteJSON(w, http.StatusOK, OrganizationMemberListResponse{Members: members})
+}
+
+// targetMember gets the member a request acts on. Only owners may act on
+// owners. It writes the error response and returns nil otherwise.
+func (h *OrganizationHandler) targetMember(w http.ResponseWriter, r *http.Request, org *db.Organization, memberID string) *db.OrganizationMember {
+	ctx := r.Context()
+	member, err := h.store.GetOrganizationMember(ctx, org.ID, memberID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Member not found")
+			return nil
+		}
+		logging.FromContext(ctx).Error("failed to get organization member", "organization_id", org.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get member")
+		return nil
+	}
+	if member.Role == db.RoleOwner && org.Role != db.RoleOwner {
+		WriteError(w, http.StatusForbidden, "Only owners can change other owners")
+		return nil
+	}
+	return member
+}
+
+// UpdateMember changes a member's role. Only owners grant or take away ownership,
+// and the last owner can't step down.
+func (h *OrganizationHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+	org := h.organization(w, r, db.RoleAdmin)
+	if org == nil {
+		return
+	}
+	memberID := routeMemberID(w, r)
+	if memberID == "" {
+		return
+	}
+
+	var req UpdateOrganizationMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !db.ValidRole(req.Role) {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{{Field: "role", Message: "must be owner, admin, member or viewer"}},
+		})
+		return
+	}
+	if req.Role == db.RoleOwner && org.Role != db.RoleOwner {
+		WriteError(w, http.StatusForbidden, "Only owners can make someone an owner")
+		return
+	}
+	if h.targetMember(w, r, org, memberID) == nil {
+		return
+	}
+
+	member, err := h.store.SetOrganizationMemberRole(ctx, org.ID, memberID, req.Role)
+	if err != nil {
+		if errors.Is(err, db.ErrLastOwner) {
+			WriteError(w, http.StatusConflict, "Make someone else an owner first")
+			return
+		}
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Member not found")
+			return
+		}
+		log.Error("failed to set organization member role", "organization_id", org.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update member")
+		return
+	}
+
+	log.Info("organization member role changed", "organization_id", org.ID, "member_id", memberID, "role", req.Role)
+	WriteJSON(w, http.StatusOK, member)
+}
+
+// RemoveMember takes someone out of an organization. Any member can remove
+// themselves to leave it.
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+	memberID := routeMemberID(w, r)
+	if memberID == "" {
+		return
+	}
+
+	min := db.RoleAdmin
+	if memberID == userID {
+		min = db.RoleViewer
+	}
+	org := h.organization(w, r, min)
+	if org == nil {
+		return
+	}
+	if memberID != userID && h.targetMember(w, r, org, memberID) == nil {
+		return
+	}
+
+	if err := h.store.RemoveOrganizationMember(ctx, org.ID, memberID); err != nil {
+		if errors.Is(err, db.ErrLastOwner) {
+			WriteError(w, http.StatusConflict, "Make someone else an owner first")
+			return
+		}
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Member not found")
+			return
+		}
+		log.Error("failed to remove organization member", "organization_id", org.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to remove member")
+		return
+	}
+
+	log.Info("organization member removed", "organization_id", org.ID, "member_id", memberID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListInvitations returns an organization's open invitations
+func (h *OrganizationHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org := h.organization(w, r, db.RoleAdmin)
+	if org == nil {
+		return
+	}
+
+	invitations, err := h.store.ListOrganizationInvitations(ctx, org.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to list organization invitations", "organization_id", org.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list invitations")
+		return
+	}
+	if invitations == nil {
+		invitations = []db.OrganizationInvitation{}
+	}
+	WriteJSON(w, http.StatusOK, OrganizationInvitationListResponse{Invitations: invitations})
+}
+
+// CreateInvitation invites an email address to join with a role. Only owners
+// invite owners.
+func (h *OrganizationHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+	org := h.organization(w, r, db.RoleAdmin)
+	if org == nil {
+		return
+	}
+
+	var req CreateOrganizationInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Role == "" {
+		req.Role = db.RoleMember
+	}
+
+	var errs validation.ValidationErrors
+	if err := validation.ValidateEmail(req.Email); err != nil {
+		errs = append(errs, *err)
+	}
+	if !db.ValidRole(req.Role) {
+		errs = append(errs, validation.ValidationError{Field: "role", Message: "must be owner, admin, member or viewer"})
+	}
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+	if req.Role == db.RoleOwner && org.Role != db.RoleOwner {
+		WriteError(w, http.StatusForbidden, "Only owners can invite owners")
+		return
+	}
+
+	invitation, err := h.store.CreateOrganizationInvitation(ctx, &db.OrganizationInvitation{
+		OrganizationID: org.ID,
+		Email:          req.Email,
+		Role:           req.Role,
+		InvitedBy:      &userID,
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrMemberExists) {
+			WriteError(w, http.StatusConflict, "Someone with that email is already a member")
+			return
+		}
+		if errors.Is(err, db.ErrInvitationExists) {
+			WriteError(w, http.StatusConflict, "That email already has an open invitation")
+			return
+		}
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Organization not found")
+			return
+		}
+		log.Error("failed to create organization invitation", "organization_id", org.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to create invitation")
+		return
+	}
+
+	log.Info("organization invitation created", "organization_id", org.ID, "invitation_id", invitation.ID, "role", invitation.Role)
+	WriteJSON(w, http.StatusCreated, invitation)
+}
+
+// DeleteInvitation withdraws an open invitation
+func (h *OrganizationHandler) DeleteInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org := h.organization(w, r, db.RoleAdmin)
+	if org == nil {
+		return
+	}
+	invitationID := routeUUID(w, r, "invitationId")
+	if invitationID == "" {
+		return
+	}
+
+	if err := h.store.DeleteOrganizationInvitation(ctx, invitationID, org.ID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Invitation not found")
+			return
+		}
+		logging.FromContext(ctx).Error("failed to delete organization invitation", "invitation_id", invitationID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to delete invitation")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListUserInvitations returns the open invitations to the user's email address
+func (h *OrganizationHandler) ListUserInvitations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+
+	invitations, err := h.store.ListUserInvitations(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to list user invitations", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list invitations")
+		return
+	}
+	if invitations == nil {
+		invitations = []db.OrganizationInvitation{}
+	}
+	WriteJSON(w, http.StatusOK, OrganizationInvitationListResponse{Invitations: invitations})
+}
+
+// AcceptInvitation joins the organization the user was invited to
+func (h *OrganizationHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+	invitationID := routeUUID(w, r, "invitationId")
+	if invitationID == "" {
+		return
+	}
+
+	org, err := h.store.AcceptOrganizationInvitation(ctx, invitationID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Invitation not found")
+			return
+		}
+		log.Error("failed to accept organization invitation", "invitation_id", invitationID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to accept invitation")
+		return
+	}
+
+	log.Info("organization invitation accepted", "organization_id", org.ID, "role", org.Role)
+	WriteJSON(w, http.StatusOK, organizationToResponse(org))
+}
+
+// DeclineInvitation turns down an invitation to the user's email address
+func (h *OrganizationHandler) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	invitationID := routeUUID(w, r, "invitationId")
+	if invitationID == "" {
+		return
+	}
+
+	if err := h.store.DeclineOrganizationInvitation(ctx, invitationID, userID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Invitation not found")
+			return
+		}
+		logging.FromContext(ctx).Error("failed to decline organization invitation", "invitation_id", invitationID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to decline invitation")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// targetOrganization gets an organization a project is being created or moved in.
+// The caller must be able to operate projects there. It writes the error
+// response and returns false otherwise.
+func (h *ProjectHandler) targetOrganization(w http.ResponseWriter, r *http.Request, orgID string) (*db.Organization, bool) {
+	ctx := r.Context()
+	if err := validation.ValidateUUID(orgID, "organization_id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return nil, false
+	}
+
+	org, err := h.store.GetOrganization(ctx, orgID, authmw.GetUserID(ctx))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteJSON(w, http.StatusBadRequest, map[string]any{
+				"error":  "Validation failed",
+				"errors": []validation.ValidationError{{Field: "organization_id", Message: "organization not found"}},
+			})
+			return nil, false
+		}
+		logging.FromContext(ctx).Error("failed to get organization", "organization_id", orgID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get organization")
+		return nil, false
+	}
+	if !db.RoleAtLeast(org.Role, roleOperate) {
+		WriteError(w, http.StatusForbidden, "Your role in this organization doesn't allow this")
+		return nil, false
+	}
+	return org, true
+}
+
+// Transfer moves a project into one of the caller's organizations, or out of
+// its organization back to the user who created it. The project keeps its
+// creator, whose key encrypts its env vars, but counts against the plan of the
+// account it moves to, which must have room for it.
+func (h *ProjectHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	var req SetProjectOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	project, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for transfer", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to move project")
+		return
+	}
+	if !allowProject(w, project, roleManage) {
+		return
+	}
+	if req.OrganizationID != nil {
+		if _, ok := h.targetOrganization(w, r, *req.OrganizationID); !ok {
+			return
+		}
+	}
+	if from, to := project.OrganizationID, req.OrganizationID; (from == nil) != (to == nil) || (from != nil && *from != *to) {
+		releaseQuota, err := quota.Reserve(ctx, h.store, project.UserID, req.OrganizationID, quota.ForTransfer(project))
+		if err != nil {
+			h.writeTransitionError(w, log, err, "move")
+			return
+		}
+		defer releaseQuota()
+	}
+
+	moved, err := h.store.SetProjectOrganization(ctx, projectID, req.OrganizationID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to set project organization", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to move project")
+		return
+	}
+
+	data := map[string]any{"from": project.OrganizationID, "to": req.OrganizationID}
+	if err := h.store.RecordProjectEvent(ctx, projectID, db.EventOrganizationChanged, nil, data); err != nil {
+		log.Error("failed to record organization change event", "error", err)
+	}
+	log.Info("project organization changed", "project_id", projectID, "organization_id", req.OrganizationID)
+
+	w.Header().Set("ETag", versionETag(moved.Version))
+	WriteJSON(w, http.StatusOK, projectToResponse(moved))
+}
@@ -0,0 +1,547 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Users' emails in the mock are their IDs at example.com
+func mockEmail(userID string) string {
+	return userID + "@example.com"
+}
+
+func (m *mockProjectStore) memberOrganization(orgID, userID string) (*db.Organization, error) {
+	org, ok := m.orgs[orgID]
+	role := m.members[orgID][userID]
+	if !ok || role == "" {
+		return nil, db.ErrNotFound
+	}
+	o := *org
+	o.Role = role
+	return &o, nil
+}
+
+func (m *mockProjectStore) CreateOrganization(ctx context.Context, name, ownerID string) (*db.Organization, error) {
+	org := &db.Organization{
+		ID:        fmt.Sprintf("00000000-0000-4000-9000-%012d", len(m.orgs)+1),
+		Name:      name,
+		CreatedBy: &ownerID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	m.orgs[org.ID] = org
+	m.members[org.ID] = map[string]string{ownerID: db.RoleOwner}
+	return m.memberOrganization(org.ID, ownerID)
+}
+
+func (m *mockProjectStore) ListOrganizations(ctx context.Context, userID string) ([]db.Organization, error) {
+	var result []db.Organization
+	for id := range m.orgs {
+		if o, err := m.memberOrganization(id, userID); err == nil {
+			result = append(result, *o)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProjectStore) GetOrganization(ctx context.Context, orgID, userID string) (*db.Organization, error) {
+	return m.memberOrganization(orgID, userID)
+}
+
+func (m *mockProjectStore) UpdateOrganization(ctx context.Context, org *db.Organization) (*db.Organization, error) {
+	if _, ok := m.orgs[org.ID]; !ok {
+		return nil, db.ErrNotFound
+	}
+	updated := *org
+	updated.Role = ""
+	m.orgs[org.ID] = &updated
+	updated.Role = org.Role
+	return &updated, nil
+}
+
+func (m *mockProjectStore) DeleteOrganization(ctx context.Context, orgID string) error {
+	if _, ok := m.orgs[orgID]; !ok {
+		return db.ErrNotFound
+	}
+	for _, p := range m.projects {
+		if p.OrganizationID != nil && *p.OrganizationID == orgID {
+			return db.ErrOrganizationHasProjects
+		}
+	}
+	delete(m.orgs, orgID)
+	delete(m.members, orgID)
+	return nil
+}
+
+func (m *mockProjectStore) mockMember(orgID, userID string) *db.OrganizationMember {
+	return &db.OrganizationMember{OrganizationID: orgID, UserID: userID, Email: mockEmail(userID), Role: m.members[orgID][userID]}
+}
+
+func (m *mockProjectStore) ListOrganizationMembers(ctx context.Context, orgID string) ([]db.OrganizationMember, error) {
+	var result []db.OrganizationMember
+	for userID := range m.members[orgID] {
+		result = append(result, *m.mockMember(orgID, userID))
+	}
+	return result, nil
+}
+
+func (m *mockProjectStore) GetOrganizationMember(ctx context.Context, orgID, userID string) (*db.OrganizationMember, error) {
+	if m.members[orgID][userID] == "" {
+		return nil, db.ErrNotFound
+	}
+	return m.mockMember(orgID, userID), nil
+}
+
+// lastOwner reports whether the user is the organization's only owner
+func (m *mockProjectStore) lastOwner(orgID, userID string) bool {
+	owners := 0
+	for _, role := range m.members[orgID] {
+		if role == db.RoleOwner {
+			owners++
+		}
+	}
+	return owners == 1 && m.members[orgID][userID] == db.RoleOwner
+}
+
+func (m *mockProjectStore) SetOrganizationMemberRole(ctx context.Context, orgID, userID, role string) (*db.OrganizationMember, error) {
+	if m.members[orgID][userID] == "" {
+		return nil, db.ErrNotFound
+	}
+	if role != db.RoleOwner && m.lastOwner(orgID, userID) {
+		return nil, db.ErrLastOwner
+	}
+	m.members[orgID][userID] = role
+	return m.mockMember(orgID, userID), nil
+}
+
+func (m *mockProjectStore) RemoveOrganizationMember(ctx context.Context, orgID, userID string) error {
+	if m.members[orgID][userID] == "" {
+		return db.ErrNotFound
+	}
+	if m.lastOwner(orgID, userID) {
+		return db.ErrLastOwner
+	}
+	delete(m.members[orgID], userID)
+	return nil
+}
+
+func (m *mockProjectStore) CreateOrganizationInvitation(ctx context.Context, invitation *db.OrganizationInvitation) (*db.OrganizationInvitation, error) {
+	for userID := range m.members[invitation.OrganizationID] {
+		if strings.EqualFold(mockEmail(userID), invitation.Email) {
+			return nil, db.ErrMemberExists
+		}
+	}
+	for _, inv := range m.invites {
+		if inv.OrganizationID == invitation.OrganizationID && strings.EqualFold(inv.Email, invitation.Email) {
+			return nil, db.ErrInvitationExists
+		}
+	}
+	created := *invitation
+	created.ID = fmt.Sprintf("00000000-0000-4000-a000-%012d", len(m.invites)+1)
+	created.OrganizationName = m.orgs[invitation.OrganizationID].Name
+	created.CreatedAt = time.Now()
+	m.invites[created.ID] = &created
+	return &created, nil
+}
+
+func (m *mockProjectStore) ListOrganizationInvitations(ctx context.Context, orgID string) ([]db.OrganizationInvitation, error) {
+	var result []db.OrganizationInvitation
+	for _, inv := range m.invites {
+		if inv.OrganizationID == orgID {
+			result = append(result, *inv)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProjectStore) DeleteOrganizationInvitation(ctx context.Context, invitationID, orgID string) error {
+	if inv, ok := m.invites[invitationID]; ok && inv.OrganizationID == orgID {
+		delete(m.invites, invitationID)
+		return nil
+	}
+	return db.ErrNotFound
+}
+
+func (m *mockProjectStore) ListUserInvitations(ctx context.Context, userID string) ([]db.OrganizationInvitation, error) {
+	var result []db.OrganizationInvitation
+	for _, inv := range m.invites {
+		if strings.EqualFold(inv.Email, mockEmail(userID)) {
+			result = append(result, *inv)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProjectStore) AcceptOrganizationInvitation(ctx context.Context, invitationID, userID string) (*db.Organization, error) {
+	inv, ok := m.invites[invitationID]
+	if !ok || !strings.EqualFold(inv.Email, mockEmail(userID)) {
+		return nil, db.ErrNotFound
+	}
+	delete(m.invites, invitationID)
+	if m.members[inv.OrganizationID][userID] == "" {
+		m.members[inv.OrganizationID][userID] = inv.Role
+	}
+	return m.memberOrganization(inv.OrganizationID, userID)
+}
+
+func (m *mockProjectStore) DeclineOrganizationInvitation(ctx context.Context, invitationID, userID string) error {
+	inv, ok := m.invites[invitationID]
+	if !ok || !strings.EqualFold(inv.Email, mockEmail(userID)) {
+		return db.ErrNotFound
+	}
+	delete(m.invites, invitationID)
+	return nil
+}
+
+func (m *mockProjectStore) SetProjectOrganization(ctx context.Context, projectID string, orgID *string, userID string) (*db.Project, error) {
+	p, ok := m.projects[projectID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	p.OrganizationID = orgID
+	p.Role = m.roleOn(p, userID)
+	return p, nil
+}
+
+const (
+	testOrgID = "00000000-0000-4000-9000-000000000100"
+	devUserID = "00000000-0000-4000-b000-000000000001"
+)
+
+// newOrgFixture puts the fixture project in an organization where
+// the test user has the given role
+func newOrgFixture(role string) *mockProjectStore {
+	store := newProjectFixture(db.StatusStopped)
+	store.orgs[testOrgID] = &db.Organization{ID: testOrgID, Name: "Acme"}
+	store.members[testOrgID] = map[string]string{"owner-id": db.RoleOwner, "test-user-id": role}
+	orgID := testOrgID
+	p := store.projects[testProjectID]
+	p.UserID = "owner-id"
+	p.OrganizationID = &orgID
+	return store
+}
+
+func TestOrganizationHandler(t *testing.T) {
+	store := newMockStore()
+	handler := NewOrganizationHandler(store)
+
+	router := chi.NewRouter()
+	router.Get("/organizations", handler.List)
+	router.Post("/organizations", handler.Create)
+	router.Get("/organizations/{orgId}", handler.Get)
+	router.Patch("/organizations/{orgId}", handler.Update)
+	router.Delete("/organizations/{orgId}", handler.Delete)
+	router.Put("/organizations/{orgId}/settings", handler.UpdateSettings)
+	router.Get("/organizations/{orgId}/members", handler.ListMembers)
+	router.Patch("/organizations/{orgId}/members/{userId}", handler.UpdateMember)
+	router.Delete("/organizations/{orgId}/members/{userId}", handler.RemoveMember)
+	router.Get("/organizations/{orgId}/invitations", handler.ListInvitations)
+	router.Post("/organizations/{orgId}/invitations", handler.CreateInvitation)
+	router.Delete("/organizations/{orgId}/invitations/{invitationId}", handler.DeleteInvitation)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		var data []byte
+		if body != "" {
+			data = []byte(body)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newAuthenticatedRequest(method, path, data))
+		return rr
+	}
+
+	rr := serve("POST", "/organizations", `{"name":"  Acme  "}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var org OrganizationResponse
+	if err := json.NewDecoder(rr.Body).Decode(&org); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if org.Name != "Acme" || org.Role != db.RoleOwner || org.DefaultHardware != nil {
+		t.Errorf("expected the creator to own Acme, got %+v", org)
+	}
+	base := "/organizations/" + org.ID
+
+	rr = serve("PUT", base+"/settings", `{"default_hardware":{"cpu_kind":"performance","cpus":2,"memory_mb":4096,"volume_size_gb":20},"default_idle_timeout_minutes":30}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&org); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if org.DefaultHardware == nil || org.DefaultHardware.CPUs != 2 || org.DefaultIdleTimeoutMinutes == nil || *org.DefaultIdleTimeoutMinutes != 30 {
+		t.Errorf("expected the defaults saved, got %+v", org)
+	}
+
+	rr = serve("POST", base+"/invitations", `{"email":"`+mockEmail(devUserID)+`","role":"admin"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var invitation db.OrganizationInvitation
+	if err := json.NewDecoder(rr.Body).Decode(&invitation); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if invitation.Role != db.RoleAdmin || invitation.OrganizationName != "Acme" || !invitation.ExpiresAt.After(time.Now()) {
+		t.Errorf("expected an open admin invitation, got %+v", invitation)
+	}
+
+	if _, err := store.AcceptOrganizationInvitation(context.Background(), invitation.ID, devUserID); err != nil {
+		t.Fatalf("failed to accept invitation: %v", err)
+	}
+	rr = serve("GET", base+"/members", "")
+	var members OrganizationMemberListResponse
+	if err := json.NewDecoder(rr.Body).Decode(&members); err != nil || len(members.Members) != 2 {
+		t.Errorf("expected two members, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rejected := []struct {
+		method, path, body string
+		code               int
+	}{
+		{"POST", "/organizations", `{"name":"   "}`, http.StatusBadRequest},
+		{"POST", base + "/invitations", `{"email":"Dev <dev@example.com>"}`, http.StatusBadRequest},
+		{"POST", base + "/invitations", `{"email":"new@example.com","role":"boss"}`, http.StatusBadRequest},
+		{"POST", base + "/invitations", `{"email":"TEST-USER-ID@example.com"}`, http.StatusConflict},
+		{"PUT", base + "/settings", `{"default_hardware":{"cpu_kind":"shared","cpus":64,"memory_mb":1024,"volume_size_gb":5}}`, http.StatusBadRequest},
+		// The only owner can't step down or leave
+		{"PATCH", base + "/members/test-user-id", `{"role":"admin"}`, http.StatusConflict},
+		{"DELETE", base + "/members/test-user-id", "", http.StatusConflict},
+		{"PATCH", base + "/members/7c9e6679-7425-40de-944b-e07fc1f90ae7", `{"role":"viewer"}`, http.StatusNotFound},
+		{"GET", "/organizations/7c9e6679-7425-40de-944b-e07fc1f90ae7", "", http.StatusNotFound},
+		{"GET", "/organizations/not-a-uuid", "", http.StatusBadRequest},
+	}
+	for _, tt := range rejected {
+		if rr := serve(tt.method, tt.path, tt.body); rr.Code != tt.code {
+			t.Errorf("%s %s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.body, tt.code, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Organizations with projects can't be deleted
+	orgID := org.ID
+	store.projects[testProjectID] = &db.Project{ID: testProjectID, UserID: devUserID, OrganizationID: &orgID}
+	if rr := serve("DELETE", base, ""); rr.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+	delete(store.projects, testProjectID)
+
+	// Once someone else owns it, the creator can hand over and step down
+	store.members[org.ID][devUserID] = db.RoleOwner
+	if rr := serve("PATCH", base+"/members/test-user-id", `{"role":"viewer"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	viewerRejected := []struct {
+		method, path, body string
+	}{
+		{"PATCH", base, `{"name":"Mine"}`},
+		{"PUT", base + "/settings", `{}`},
+		{"GET", base + "/invitations", ""},
+		{"POST", base + "/invitations", `{"email":"new@example.com"}`},
+		{"DELETE", base, ""},
+	}
+	for _, tt := range viewerRejected {
+		if rr := serve(tt.method, tt.path, tt.body); rr.Code != http.StatusForbidden {
+			t.Errorf("%s %s as viewer: expected status %d, got %d", tt.method, tt.path, http.StatusForbidden, rr.Code)
+		}
+	}
+
+	if rr := serve("DELETE", base+"/members/test-user-id", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected a viewer to be able to leave, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve("GET", base, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected the organization hidden after leaving, got %d", rr.Code)
+	}
+}
+
+func TestOrganizationHandler_AcceptInvitation(t *testing.T) {
+	store := newMockStore()
+	handler := NewOrganizationHandler(store)
+	store.orgs[testOrgID] = &db.Organization{ID: testOrgID, Name: "Acme"}
+	store.members[testOrgID] = map[string]string{"owner-id": db.RoleOwner}
+	store.invites["00000000-0000-4000-a000-000000000001"] = &db.OrganizationInvitation{
+		ID: "00000000-0000-4000-a000-000000000001", OrganizationID: testOrgID, OrganizationName: "Acme",
+		Email: "Test-User-ID@example.com", Role: db.RoleViewer, ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	router := chi.NewRouter()
+	router.Get("/user/invitations", handler.ListUserInvitations)
+	router.Post("/user/invitations/{invitationId}/accept", handler.AcceptInvitation)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, newAuthenticatedRequest("GET", "/user/invitations", nil))
+	var list OrganizationInvitationListResponse
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil || len(list.Invitations) != 1 {
+		t.Fatalf("expected the invitation listed, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, newAuthenticatedRequest("POST", "/user/invitations/"+list.Invitations[0].ID+"/accept", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var org OrganizationResponse
+	if err := json.NewDecoder(rr.Body).Decode(&org); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if org.ID != testOrgID || org.Role != db.RoleViewer {
+		t.Errorf("expected to join Acme as a viewer, got %+v", org)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, newAuthenticatedRequest("POST", "/user/invitations/"+list.Invitations[0].ID+"/accept", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected an accepted invitation to be used up, got %d", rr.Code)
+	}
+}
+
+func TestProjectHandler_OrganizationRoles(t *testing.T) {
+	tests := []struct {
+		role         string
+		start, trash int
+	}{
+		{db.RoleViewer, http.StatusForbidden, http.StatusForbidden},
+		{db.RoleMember, http.StatusAccepted, http.StatusForbidden},
+		{db.RoleAdmin, http.StatusAccepted, http.StatusOK},
+	}
+	for _, tt := range tests {
+		store := newOrgFixture(tt.role)
+		handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+		router := chi.NewRouter()
+		router.Get("/projects/{id}", handler.Get)
+		router.Post("/projects/{id}/start", handler.Start)
+		router.Delete("/projects/{id}", handler.Delete)
+		serve := func(method, path string) *httptest.ResponseRecorder {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, newAuthenticatedRequest(method, path, nil))
+			return rr
+		}
+
+		rr := serve("GET", "/projects/"+testProjectID)
+		var project ProjectResponse
+		if err := json.NewDecoder(rr.Body).Decode(&project); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected the project readable, got %d", tt.role, rr.Code)
+		}
+		if project.Role != tt.role || project.OrganizationID == nil || *project.OrganizationID != testOrgID {
+			t.Errorf("%s: expected the organization and role returned, got %v %q", tt.role, project.OrganizationID, project.Role)
+		}
+
+		if rr := serve("POST", "/projects/"+testProjectID+"/start"); rr.Code != tt.start {
+			t.Errorf("%s: expected start status %d, got %d: %s", tt.role, tt.start, rr.Code, rr.Body.String())
+		}
+		store.projects[testProjectID].Status = db.StatusStopped
+		if rr := serve("DELETE", "/projects/"+testProjectID); rr.Code != tt.trash {
+			t.Errorf("%s: expected delete status %d, got %d: %s", tt.role, tt.trash, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Outsiders can't see the project at all
+	store := newOrgFixture(db.RoleViewer)
+	delete(store.members[testOrgID], "test-user-id")
+	if _, err := store.GetProjectByUser(context.Background(), testProjectID, "test-user-id"); err != db.ErrNotFound {
+		t.Errorf("expected the project hidden from non-members, got %v", err)
+	}
+}
+
+func TestProjectHandler_StatusEventsReachOrganizationMembers(t *testing.T) {
+	store := newOrgFixture(db.RoleMember)
+	store.events = &mockEventStore{orgs: map[string][]string{"owner-id": {testOrgID}}}
+	broker := NewEventBroker(store.events)
+	owner := broker.Subscribe("owner-id", testOrgID)
+	defer broker.Unsubscribe(owner)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	// A member starts the project the owner created
+	rr := serveRoute(handler.Start, "POST", "/projects/{id}/start", "/projects/"+testProjectID+"/start", nil)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+	store.opsMu.Lock()
+	broker.poll(context.Background())
+	store.opsMu.Unlock()
+
+	select {
+	case e := <-owner:
+		if e.Type != db.EventStatus || e.Status == nil || *e.Status != db.StatusStarting {
+			t.Errorf("expected the starting status event, got %+v", e)
+		}
+	default:
+		t.Fatal("expected the other member to receive the status change")
+	}
+}
+
+func TestProjectHandler_Create_InOrganization(t *testing.T) {
+	store := newOrgFixture(db.RoleMember)
+	timeout := 30
+	store.orgs[testOrgID].DefaultHardware = &db.HardwareConfig{CPUKind: "performance", CPUs: 2, MemoryMB: 4096, VolumeSizeGB: 20}
+	store.orgs[testOrgID].DefaultIdleTimeoutMinutes = &timeout
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := httptest.NewRecorder()
+	handler.Create(rr, newAuthenticatedRequest("POST", "/projects", []byte(`{"name":"team-project","organization_id":"`+testOrgID+`"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var project ProjectResponse
+	if err := json.NewDecoder(rr.Body).Decode(&project); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if project.OrganizationID == nil || *project.OrganizationID != testOrgID || project.Role != db.RoleMember {
+		t.Errorf("expected the project in the organization, got %v %q", project.OrganizationID, project.Role)
+	}
+	if project.Hardware.CPUKind != "performance" || project.Hardware.CPUs != 2 || project.Hardware.VolumeSizeGB != 20 {
+		t.Errorf("expected the organization's default hardware, got %+v", project.Hardware)
+	}
+
+	store.members[testOrgID]["test-user-id"] = db.RoleViewer
+	rr = httptest.NewRecorder()
+	handler.Create(rr, newAuthenticatedRequest("POST", "/projects", []byte(`{"name":"team-project","organization_id":"`+testOrgID+`"}`)))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected viewers unable to create projects, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.Create(rr, newAuthenticatedRequest("POST", "/projects", []byte(`{"name":"team-project","organization_id":"7c9e6679-7425-40de-944b-e07fc1f90ae7"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown organization rejected, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestProjectHandler_Transfer(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	store.orgs[testOrgID] = &db.Organization{ID: testOrgID, Name: "Acme"}
+	store.members[testOrgID] = map[string]string{"test-user-id": db.RoleMember}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+	router := chi.NewRouter()
+	router.Put("/projects/{id}/organization", handler.Transfer)
+	serve := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newAuthenticatedRequest("PUT", "/projects/"+testProjectID+"/organization", []byte(body)))
+		return rr
+	}
+
+	rr := serve(`{"organization_id":"` + testOrgID + `"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	p := store.projects[testProjectID]
+	if p.OrganizationID == nil || *p.OrganizationID != testOrgID || p.UserID != "test-user-id" {
+		t.Errorf("expected the project moved with its creator kept, got %v %s", p.OrganizationID, p.UserID)
+	}
+
+	// As a plain member the project can no longer be moved back out
+	if rr := serve(`{"organization_id":null}`); rr.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+	store.members[testOrgID]["test-user-id"] = db.RoleAdmin
+	if rr := serve(`{"organization_id":null}`); rr.Code != http.StatusOK || p.OrganizationID != nil {
+		t.Errorf("expected the project made personal again, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
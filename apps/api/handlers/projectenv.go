@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"aether/apps/api/crypto"
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maskedValue replaces secret values in responses
+const maskedValue = "********"
+
+// ProjectEnvProvider reads and seeds projects' encrypted env vars
+type ProjectEnvProvider interface {
+	GetDecryptedEnv(ctx context.Context, projectID string) (map[string]string, error)
+	// EncryptNewEnv encrypts plain (non-secret) vars for a project userID is creating
+	EncryptNewEnv(userID string, vars map[string]string) (*string, error)
+}
+
+// ProjectEnvStore defines the database operations needed by ProjectEnvHandler
+type ProjectEnvStore interface {
+	GetProject(ctx context.Context, projectID string) (*db.Project, error)
+	GetProjectByUser(ctx context.Context, projectID, userID string) (*db.Project, error)
+	SetProjectEnvVars(ctx context.Context, projectID, userID string, encrypted *string, ifVersion *int64) (*db.Project, error)
+}
+
+// ProjectEnvHandler manages per-project env vars. All values are encrypted at
+// rest with the owner's key; secrets are additionally masked in responses.
+type ProjectEnvHandler struct {
+	store     ProjectEnvStore
+	encryptor *crypto.Encryptor
+}
+
+func NewProjectEnvHandler(store ProjectEnvStore, encryptor *crypto.Encryptor) *ProjectEnvHandler {
+	return &ProjectEnvHandler{store: store, encryptor: encryptor}
+}
+
+// StoredEnvVars is the internal structure for storing encrypted env vars
+type StoredEnvVars struct {
+	Vars map[string]StoredEnvVar `json:"vars"`
+}
+
+// StoredEnvVar represents a single stored env var
+type StoredEnvVar struct {
+	Value     string    `json:"value"`
+	Secret    bool      `json:"secret"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EnvVarInput sets one variable. A nil value keeps the current value, so a
+// secret's flag can be changed without resending it.
+type EnvVarInput struct {
+	Value  *string `json:"value,omitempty"`
+	Secret bool    `json:"secret,omitempty"`
+}
+
+// SetEnvVarsRequest is the request body for PUT /projects/{id}/env.
+// Listed variables are created or replaced; others are left alone.
+type SetEnvVarsRequest struct {
+	Vars map[string]EnvVarInput `json:"vars"`
+}
+
+type EnvVarResponse struct {
+	Name      string    `json:"name"`
+	Value     string    `json:"value"`
+	Secret    bool      `json:"secret"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type EnvVarListResponse struct {
+	Vars []EnvVarResponse `json:"vars"`
+	// Pending is set when the project's machine hasn't picked up the latest vars yet
+	Pending bool `json:"pending"`
+}
+
+func envVarsToResponse(stored *StoredEnvVars, pending bool) EnvVarListResponse {
+	names := make([]string, 0, len(stored.Vars))
+	for name := range stored.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	response := EnvVarListResponse{Vars: make([]EnvVarResponse, len(names)), Pending: pending}
+	for i, name := range names {
+		v := stored.Vars[name]
+		value := v.Value
+		if v.Secret {
+			value = maskedValue
+		}
+		response.Vars[i] = EnvVarResponse{Name: name, Value: value, Secret: v.Secret, UpdatedAt: v.UpdatedAt}
+	}
+	return response
+}
+
+// decrypt reads a project's stored env vars
+func (h *ProjectEnvHandler) decrypt(project *db.Project) (*StoredEnvVars, error) {
+	stored := &StoredEnvVars{Vars: make(map[string]StoredEnvVar)}
+	if project.EnvVarsEncrypted == nil || *project.EnvVarsEncrypted == "" {
+		return stored, nil
+	}
+	decrypted, err := h.encryptor.Decrypt(*project.EnvVarsEncrypted, project.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(decrypted), stored); err != nil {
+		return nil, err
+	}
+	if stored.Vars == nil {
+		stored.Vars = make(map[string]StoredEnvVar)
+	}
+	return stored, nil
+}
+
+// Encrypt serializes and encrypts env vars for a project owned by userID.
+// An empty set is stored as NULL.
+func (h *ProjectEnvHandler) Encrypt(stored *StoredEnvVars, userID string) (*string, error) {
+	if len(stored.Vars) == 0 {
+		return nil, nil
+	}
+	varsJSON, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := h.encryptor.Encrypt(string(varsJSON), userID)
+	if err != nil {
+		return nil, err
+	}
+	return &encrypted, nil
+}
+
+// EncryptNewEnv encrypts a new project's initial vars, all stored as plain values
+func (h *ProjectEnvHandler) EncryptNewEnv(userID string, vars map[string]string) (*string, error) {
+	stored := &StoredEnvVars{Vars: make(map[string]StoredEnvVar, len(vars))}
+	now := time.Now().UTC()
+	for name, value := range vars {
+		stored.Vars[name] = StoredEnvVar{Value: value, UpdatedAt: now}
+	}
+	return h.Encrypt(stored, userID)
+}
+
+// GetDecryptedEnv returns a project's env vars with secrets in the clear.
+// This is used internally when configuring machines and agents.
+func (h *ProjectEnvHandler) GetDecryptedEnv(ctx context.Context, projectID string) (map[string]string, error) {
+	project, err := h.store.GetProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := h.decrypt(project)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string, len(stored.Vars))
+	for name, v := range stored.Vars {
+		env[name] = v.Value
+	}
+	return env, nil
+}
+
+// getProject validates the route ID and loads the caller's project and, if withVars
+// is set, its vars. The caller's role on the project must grant min. It writes
+// the error response and returns nil if anything fails.
+func (h *ProjectEnvHandler) getProject(w http.ResponseWriter, r *http.Request, withVars bool, min string) (*db.Project, *StoredEnvVars) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return nil, nil
+	}
+
+	project, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return nil, nil
+		}
+		log.Error("failed to get project for env", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get project")
+		return nil, nil
+	}
+	if !allowProject(w, project, min) {
+		return nil, nil
+	}
+	if !withVars {
+		return project, nil
+	}
+
+	stored, err := h.decrypt(project)
+	if err != nil {
+		log.Error("failed to decrypt project env vars", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to read env vars")
+		return nil, nil
+	}
+	return project, stored
+}
+
+// save encrypts and stores a project's vars and writes the updated list. The
+// vars were read from project, so the write only lands if nobody has changed
+// the project since.
+func (h *ProjectEnvHandler) save(w http.ResponseWriter, r *http.Request, project *db.Project, stored *StoredEnvVars) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	encrypted, err := h.Encrypt(stored, project.UserID)
+	if err != nil {
+		log.Error("failed to encrypt project env vars", "project_id", project.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to save env vars")
+		return
+	}
+
+	updated, err := h.store.SetProjectEnvVars(ctx, project.ID, authmw.GetUserID(ctx), encrypted, &project.Version)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		if errors.Is(err, db.ErrVersionMismatch) {
+			// A client that sent If-Match asked for 412; otherwise the change
+			// raced another one and can simply be retried
+			if r.Header.Get("If-Match") != "" {
+				writePreconditionFailed(w, 0)
+				return
+			}
+			WriteError(w, http.StatusConflict, "Env vars were changed by another request; try again")
+			return
+		}
+		log.Error("failed to save project env vars", "project_id", project.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to save env vars")
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(updated.Version))
+	WriteJSON(w, http.StatusOK, envVarsToResponse(stored, updated.EnvPending))
+}
+
+// List returns the project's env vars with secret values masked
+func (h *ProjectEnvHandler) List(w http.ResponseWriter, r *http.Request) {
+	project, stored := h.getProject(w, r, true, roleOperate)
+	if project == nil {
+		return
+	}
+	w.Header().Set("ETag", versionETag(project.Version))
+	WriteJSON(w, http.StatusOK, envVarsToResponse(stored, project.EnvPending))
+}
+
+// Set creates or replaces the variables in the request. Agents see new values
+// when they next connect; the machine env and .env file on the next start.
+func (h *ProjectEnvHandler) Set(w http.ResponseWriter, r *http.Request) {
+	project, stored := h.getProject(w, r, true, roleManage)
+	if project == nil {
+		return
+	}
+	if !checkIfMatch(w, r, project.Version) {
+		return
+	}
+
+	var req SetEnvVarsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var errs validation.ValidationErrors
+	now := time.Now().UTC()
+	for name, input := range req.Vars {
+		current, exists := stored.Vars[name]
+		if input.Value == nil {
+			if !exists {
+				errs = append(errs, validation.ValidationError{Field: "env_vars", Message: name + ": value is required for a new variable"})
+				continue
+			}
+			current.Secret = input.Secret
+		} else {
+			if err := validation.ValidateProjectEnvVar(name, *input.Value); err != nil {
+				errs = append(errs, *err)
+				continue
+			}
+			current = StoredEnvVar{Value: *input.Value, Secret: input.Secret}
+		}
+		current.UpdatedAt = now
+		stored.Vars[name] = current
+	}
+	if err := validation.ValidateProjectEnvVarCount(len(stored.Vars)); err != nil {
+		errs = append(errs, *err)
+	}
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+
+	h.save(w, r, project, stored)
+}
+
+// Delete removes one variable
+func (h *ProjectEnvHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	project, stored := h.getProject(w, r, true, roleManage)
+	if project == nil {
+		return
+	}
+	if !checkIfMatch(w, r, project.Version) {
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if _, ok := stored.Vars[name]; !ok {
+		WriteError(w, http.StatusNotFound, "Variable not found")
+		return
+	}
+	delete(stored.Vars, name)
+
+	h.save(w, r, project, stored)
+}
+
+// Clear removes all of the project's variables. It doesn't read them first, so it
+// also recovers a project whose vars can no longer be decrypted.
+func (h *ProjectEnvHandler) Clear(w http.ResponseWriter, r *http.Request) {
+	project, _ := h.getProject(w, r, false, roleManage)
+	if project == nil {
+		return
+	}
+	if !checkIfMatch(w, r, project.Version) {
+		return
+	}
+	h.save(w, r, project, &StoredEnvVars{Vars: make(map[string]StoredEnvVar)})
+}
+
+// envFile is sourced by workspace shells so terminals see the project's vars
+const envFile = "/home/coder/workspace/.env"
+
+// writeEnvFile writes the project's env vars to the workspace .env file on a
+// started machine. Failure is logged; the machine env already has the vars.
+func (h *ProjectHandler) writeEnvFile(ctx context.Context, log *logging.Logger, project *db.Project) {
+	env := NewEnvBuilder(nil, h.projectEnv).ProjectEnv(ctx, project.ID)
+	content := base64.StdEncoding.EncodeToString([]byte(ToEnvFileContent(env)))
+	script := "umask 077 && echo " + content + " | base64 -d > " + shellQuote(envFile)
+	command := []string{"sudo", "-u", "coder", "bash", "-c", script}
+
+	result, err := h.machines.Exec(*project.FlyMachineID, command, templateExecTimeout)
+	switch {
+	case err != nil:
+		log.Warn("failed to write env file", "error", err)
+	case result.ExitCode != 0:
+		log.Warn("writing env file exited with error", "exit_code", result.ExitCode, "stderr", result.Stderr)
+	}
+}
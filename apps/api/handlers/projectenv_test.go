@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aether/apps/api/crypto"
+	"aether/apps/api/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (m *mockProjectStore) SetProjectEnvVars(ctx context.Context, projectID, userID string, encrypted *string, ifVersion *int64) (*db.Project, error) {
+	p, ok := m.projects[projectID]
+	if !ok || m.roleOn(p, userID) == "" {
+		return nil, db.ErrNotFound
+	}
+	if ifVersion != nil && *ifVersion != p.Version {
+		return nil, db.ErrVersionMismatch
+	}
+	p.EnvVarsEncrypted = encrypted
+	p.EnvPending = p.FlyMachineID != nil
+	p.Version++
+	return p, nil
+}
+
+func (m *mockProjectStore) ClearProjectEnvPending(ctx context.Context, projectID string, applied *string) error {
+	p, ok := m.projects[projectID]
+	if !ok {
+		return nil
+	}
+	if (p.EnvVarsEncrypted == nil && applied == nil) ||
+		(p.EnvVarsEncrypted != nil && applied != nil && *p.EnvVarsEncrypted == *applied) {
+		p.EnvPending = false
+	}
+	return nil
+}
+
+func newTestProjectEnvHandler(t *testing.T, store ProjectEnvStore) *ProjectEnvHandler {
+	t.Helper()
+	encryptor, err := crypto.NewEncryptorWithKey(make([]byte, 32))
+	if err != nil {
+		t.Fatalf("failed to create encryptor: %v", err)
+	}
+	return NewProjectEnvHandler(store, encryptor)
+}
+
+func serveEnv(handler *ProjectEnvHandler, method, path, body string) *httptest.ResponseRecorder {
+	return serveEnvWithHeaders(handler, method, path, body, nil)
+}
+
+func serveEnvWithHeaders(handler *ProjectEnvHandler, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	router := chi.NewRouter()
+	router.Get("/projects/{id}/env", handler.List)
+	router.Put("/projects/{id}/env", handler.Set)
+	router.Delete("/projects/{id}/env", handler.Clear)
+	router.Delete("/projects/{id}/env/{name}", handler.Delete)
+
+	var payload []byte
+	if body != "" {
+		payload = []byte(body)
+	}
+	req := newAuthenticatedRequest(method, path, payload)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func decodeEnvList(t *testing.T, rr *httptest.ResponseRecorder) map[string]EnvVarResponse {
+	t.Helper()
+	var resp EnvVarListResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	vars := make(map[string]EnvVarResponse, len(resp.Vars))
+	for _, v := range resp.Vars {
+		vars[v.Name] = v
+	}
+	return vars
+}
+
+func TestProjectEnvHandler_SetMasksSecrets(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	handler := newTestProjectEnvHandler(t, store)
+	path := "/projects/" + testProjectID + "/env"
+
+	rr := serveEnv(handler, "PUT", path, `{"vars":{"DATABASE_URL":{"value":"postgres://u:pw@db/app","secret":true},"LOG_LEVEL":{"value":"debug"}}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	vars := decodeEnvList(t, rr)
+	if vars["DATABASE_URL"].Value != maskedValue || !vars["DATABASE_URL"].Secret {
+		t.Errorf("expected secret masked, got %+v", vars["DATABASE_URL"])
+	}
+	if vars["LOG_LEVEL"].Value != "debug" {
+		t.Errorf("expected plain value shown, got %+v", vars["LOG_LEVEL"])
+	}
+
+	p := store.projects[testProjectID]
+	if p.EnvVarsEncrypted == nil || strings.Contains(*p.EnvVarsEncrypted, "postgres://") {
+		t.Error("expected vars stored encrypted")
+	}
+	if !p.EnvPending {
+		t.Error("expected env pending for an existing machine")
+	}
+
+	// Omitting the value changes only the flag
+	rr = serveEnv(handler, "PUT", path, `{"vars":{"DATABASE_URL":{"secret":false}}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := decodeEnvList(t, rr)["DATABASE_URL"].Value; got != "postgres://u:pw@db/app" {
+		t.Errorf("expected value kept when unmasking, got %q", got)
+	}
+
+	rr = serveEnv(handler, "DELETE", path+"/LOG_LEVEL", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	env, err := handler.GetDecryptedEnv(context.Background(), testProjectID)
+	if err != nil {
+		t.Fatalf("failed to decrypt env: %v", err)
+	}
+	if len(env) != 1 || env["DATABASE_URL"] != "postgres://u:pw@db/app" {
+		t.Errorf("expected only DATABASE_URL left, got %v", env)
+	}
+}
+
+func TestProjectEnvHandler_Rejections(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"invalid name", "PUT", "/env", `{"vars":{"1BAD":{"value":"x"}}}`, http.StatusBadRequest},
+		{"reserved name", "PUT", "/env", `{"vars":{"PROJECT_ID":{"value":"x"}}}`, http.StatusBadRequest},
+		{"new var without value", "PUT", "/env", `{"vars":{"NEW":{"secret":true}}}`, http.StatusBadRequest},
+		{"unknown var", "DELETE", "/env/MISSING", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newProjectFixture(db.StatusStopped)
+			handler := newTestProjectEnvHandler(t, store)
+
+			rr := serveEnv(handler, tt.method, "/projects/"+testProjectID+tt.path, tt.body)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if store.projects[testProjectID].EnvVarsEncrypted != nil {
+				t.Error("expected nothing stored")
+			}
+		})
+	}
+}
+
+func TestProjectEnvHandler_ConcurrentChangeConflicts(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	handler := newTestProjectEnvHandler(t, store)
+	path := "/projects/" + testProjectID + "/env"
+
+	rr := serveEnv(handler, "PUT", path, `{"vars":{"A":{"value":"1"}}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	saved := *store.projects[testProjectID].EnvVarsEncrypted
+
+	// Another request writes the vars between this one reading and saving them
+	store.getFn = func(ctx context.Context, projectID, userID string) (*db.Project, error) {
+		p := store.projects[projectID]
+		read := *p
+		read.Role = db.RoleOwner
+		p.Version++
+		return &read, nil
+	}
+
+	rr = serveEnv(handler, "PUT", path, `{"vars":{"B":{"value":"2"}}}`)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = serveEnv(handler, "DELETE", path+"/A", "")
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := *store.projects[testProjectID].EnvVarsEncrypted; got != saved {
+		t.Error("expected the stale writes discarded")
+	}
+}
+
+func TestProjectEnvHandler_IfMatch(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	store.projects[testProjectID].Version = 2
+	handler := newTestProjectEnvHandler(t, store)
+	path := "/projects/" + testProjectID + "/env"
+
+	rr := serveEnvWithHeaders(handler, "PUT", path, `{"vars":{"A":{"value":"1"}}}`, map[string]string{"If-Match": `"2"`})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"3"` {
+		t.Fatalf("expected 200 with ETag \"3\", got %d %q: %s", rr.Code, rr.Header().Get("ETag"), rr.Body.String())
+	}
+
+	// Requests made against the old version are refused
+	for _, tt := range []struct{ method, path, body string }{
+		{"PUT", path, `{"vars":{"B":{"value":"2"}}}`},
+		{"DELETE", path + "/A", ""},
+		{"DELETE", path, ""},
+	} {
+		rr = serveEnvWithHeaders(handler, tt.method, tt.path, tt.body, map[string]string{"If-Match": `"2"`})
+		if rr.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s %s: expected 412, got %d: %s", tt.method, tt.path, rr.Code, rr.Body.String())
+		}
+	}
+	if p := store.projects[testProjectID]; p.Version != 3 {
+		t.Errorf("expected no further writes, got version %d", p.Version)
+	}
+}
+
+func TestProjectHandler_StartAppliesEnv(t *testing.T) {
+	ctx := context.Background()
+	store := newProjectFixture(db.StatusStopped)
+	envHandler := newTestProjectEnvHandler(t, store)
+	if rr := serveEnv(envHandler, "PUT", "/projects/"+testProjectID+"/env", `{"vars":{"API_TOKEN":{"value":"it's secret","secret":true}}}`); rr.Code != http.StatusOK {
+		t.Fatalf("failed to set env: %d %s", rr.Code, rr.Body.String())
+	}
+
+	machines := newMockMachineManager()
+	var applied map[string]string
+	machines.updateFn = func(machineID string, config MachineConfig) error {
+		applied = config.Env
+		return nil
+	}
+	var execs [][]string
+	machines.execFn = func(machineID string, command []string, timeout time.Duration) (*ExecResult, error) {
+		execs = append(execs, command)
+		return &ExecResult{}, nil
+	}
+	handler := NewProjectHandler(store, machines, newMockVolumeManager(), nil, nil, nil, envHandler, "test-image", testRegions, 10*time.Minute)
+
+	op, err := store.BeginOperation(ctx, testProjectID, "test-user-id", db.OperationStart, []string{db.StatusStopped}, db.StatusStarting)
+	if err != nil {
+		t.Fatalf("failed to begin start: %v", err)
+	}
+	claimed, err := store.ClaimOperation(ctx, op.ID, handler.workerID)
+	if err != nil {
+		t.Fatalf("failed to claim start: %v", err)
+	}
+	handler.runOperation(ctx, claimed)
+
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusRunning {
+		t.Errorf("expected running, got %s", p.Status)
+	}
+	if applied["API_TOKEN"] != "it's secret" || applied["PROJECT_ID"] != testProjectID {
+		t.Errorf("expected project env on machine, got %v", applied)
+	}
+	if p.EnvPending {
+		t.Error("expected env pending cleared")
+	}
+
+	if len(execs) != 1 {
+		t.Fatalf("expected one exec to write .env, got %d", len(execs))
+	}
+	content := base64.StdEncoding.EncodeToString([]byte(ToEnvFileContent(map[string]string{"API_TOKEN": "it's secret"})))
+	if script := execs[0][len(execs[0])-1]; !strings.Contains(script, content) || !strings.Contains(script, envFile) {
+		t.Errorf("expected .env written with project vars:\n%s", script)
+	}
+}
+
+func TestToEnvFileContent(t *testing.T) {
+	got := ToEnvFileContent(map[string]string{"B": "it's $HOME", "A": "1"})
+	want := "export A='1'\nexport B='it'\\''s $HOME'\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
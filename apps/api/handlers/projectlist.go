@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"aether/apps/api/db"
+	"aether/apps/api/validation"
+)
+
+const (
+	defaultProjectPageSize = 50
+	maxProjectPageSize     = 200
+	maxProjectSearchLen    = 100
+	// personalProjects filters the listing to projects outside any organization
+	personalProjects = "personal"
+)
+
+// projectCursor marks the last project of a page. It records the sort it was
+// made for, so it can't be replayed against a different order.
+type projectCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d"`
+	Value      string `json:"v"`
+	ID         string `json:"id"`
+}
+
+func encodeProjectCursor(p *db.Project, sort string, descending bool) string {
+	c := projectCursor{Sort: sort, Descending: descending, ID: p.ID}
+	switch v := db.ProjectSortValue(p, sort).(type) {
+	case time.Time:
+		c.Value = v.UTC().Format(time.RFC3339Nano)
+	case string:
+		c.Value = v
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeProjectCursor returns the sort value and ID to continue after
+func decodeProjectCursor(s, sort string, descending bool) (any, string, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, "", false
+	}
+	var c projectCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, "", false
+	}
+	if c.Sort != sort || c.Descending != descending || validation.ValidateUUID(c.ID, "cursor") != nil {
+		return nil, "", false
+	}
+
+	if sort == db.ProjectSortName {
+		return c.Value, c.ID, true
+	}
+	t, err := time.Parse(time.RFC3339Nano, c.Value)
+	if err != nil {
+		return nil, "", false
+	}
+	return t, c.ID, true
+}
+
+// parseProjectListQuery reads the GET /projects query: status (repeatable or
+// comma-separated), cpu_kind, gpu (true or false), gpu_kind, tag (repeatable,
+// all must match), label (key:value or a bare key, repeatable, all must match),
+// q, organization_id (an organization's ID, or "personal"), sort, order (asc or
+// desc), limit and cursor
+func parseProjectListQuery(query url.Values) (db.ProjectListOptions, validation.ValidationErrors) {
+	var errs validation.ValidationErrors
+	opts := db.ProjectListOptions{Limit: defaultProjectPageSize}
+
+	for _, value := range query["status"] {
+		for _, status := range strings.Split(value, ",") {
+			if !db.IsProjectStatus(status) || status == db.StatusTrashed {
+				errs = append(errs, validation.ValidationError{Field: "status", Message: "unknown status " + strconv.Quote(status)})
+				continue
+			}
+			opts.Statuses = append(opts.Statuses, status)
+		}
+	}
+
+	if kind := query.Get("cpu_kind"); kind != "" {
+		if err := validation.ValidateCPUKind(kind); err != nil {
+			errs = append(errs, *err)
+		}
+		opts.CPUKind = kind
+	}
+	if gpu := query.Get("gpu"); gpu != "" {
+		hasGPU, err := strconv.ParseBool(gpu)
+		if err != nil {
+			errs = append(errs, validation.ValidationError{Field: "gpu", Message: "must be true or false"})
+		}
+		opts.HasGPU = &hasGPU
+	}
+	if kind := query.Get("gpu_kind"); kind != "" {
+		if err := validation.ValidateGPUKind(kind); err != nil {
+			errs = append(errs, *err)
+		}
+		opts.GPUKind = kind
+	}
+
+	if tags := query["tag"]; len(tags) > 0 {
+		if err := validation.ValidateTags(tags); err != nil {
+			err.Field = "tag"
+			errs = append(errs, *err)
+		}
+		opts.Tags = tags
+	}
+
+	for _, label := range query["label"] {
+		key, value, hasValue := strings.Cut(label, ":")
+		if err := validation.ValidateLabelKey(key); err != nil {
+			err.Field = "label"
+			errs = append(errs, *err)
+			continue
+		}
+		if !hasValue {
+			opts.LabelKeys = append(opts.LabelKeys, key)
+			continue
+		}
+		if opts.Labels == nil {
+			opts.Labels = map[string]string{}
+		}
+		if existing, ok := opts.Labels[key]; ok && existing != value {
+			errs = append(errs, validation.ValidationError{Field: "label", Message: "conflicting values for " + strconv.Quote(key)})
+			continue
+		}
+		opts.Labels[key] = value
+	}
+
+	opts.Search = strings.TrimSpace(query.Get("q"))
+	if len(opts.Search) > maxProjectSearchLen {
+		errs = append(errs, validation.ValidationError{Field: "q", Message: "must be 100 characters or less"})
+	}
+
+	if org := query.Get("organization_id"); org != "" {
+		if org == personalProjects {
+			org = ""
+		} else if err := validation.ValidateUUID(org, "organization_id"); err != nil {
+			err.Message = "must be an organization ID or 'personal'"
+			errs = append(errs, *err)
+		}
+		opts.OrganizationID = &org
+	}
+
+	opts.Sort = query.Get("sort")
+	switch opts.Sort {
+	case "":
+		opts.Sort = db.ProjectSortUpdatedAt
+	case db.ProjectSortUpdatedAt, db.ProjectSortCreatedAt, db.ProjectSortLastAccessedAt, db.ProjectSortName:
+	default:
+		errs = append(errs, validation.ValidationError{Field: "sort", Message: "must be one of: updated_at, created_at, last_accessed_at, name"})
+	}
+
+	// Newest first for times, alphabetical for names
+	switch query.Get("order") {
+	case "":
+		opts.Descending = opts.Sort != db.ProjectSortName
+	case "asc":
+		opts.Descending = false
+	case "desc":
+		opts.Descending = true
+	default:
+		errs = append(errs, validation.ValidationError{Field: "order", Message: "must be 'asc' or 'desc'"})
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxProjectPageSize {
+			errs = append(errs, validation.ValidationError{Field: "limit", Message: "must be between 1 and " + strconv.Itoa(maxProjectPageSize)})
+		}
+		opts.Limit = n
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" && !errs.HasErrors() {
+		value, id, ok := decodeProjectCursor(cursor, opts.Sort, opts.Descending)
+		if !ok {
+			errs = append(errs, validation.ValidationError{Field: "cursor", Message: "is invalid or was made for a different sort"})
+		}
+		opts.AfterValue = value
+		opts.AfterID = id
+	}
+
+	return opts, errs
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+// mockProjectMatches applies ListProjects filters the way the query does
+func mockProjectMatches(p *db.Project, opts db.ProjectListOptions) bool {
+	if len(opts.Statuses) > 0 && !containsString(opts.Statuses, p.Status) {
+		return false
+	}
+	if opts.CPUKind != "" && p.CPUKind != opts.CPUKind {
+		return false
+	}
+	if opts.HasGPU != nil && (p.GPUKind != nil) != *opts.HasGPU {
+		return false
+	}
+	if opts.GPUKind != "" && (p.GPUKind == nil || *p.GPUKind != opts.GPUKind) {
+		return false
+	}
+	for _, tag := range opts.Tags {
+		if !containsString(p.Tags, tag) {
+			return false
+		}
+	}
+	for key, value := range opts.Labels {
+		if v, ok := p.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	for _, key := range opts.LabelKeys {
+		if _, ok := p.Labels[key]; !ok {
+			return false
+		}
+	}
+	if opts.Search != "" {
+		search := strings.ToLower(opts.Search)
+		inDescription := p.Description != nil && strings.Contains(strings.ToLower(*p.Description), search)
+		if !strings.Contains(strings.ToLower(p.Name), search) && !inDescription {
+			return false
+		}
+	}
+	return true
+}
+
+// pageMockProjects sorts, seeks and limits projects the way the query does
+func pageMockProjects(projects []db.Project, opts db.ProjectListOptions) []db.Project {
+	compare := func(a, b any) int {
+		switch a := a.(type) {
+		case time.Time:
+			return a.Compare(b.(time.Time))
+		default:
+			return strings.Compare(a.(string), b.(string))
+		}
+	}
+	compareKeys := func(valueA any, idA string, valueB any, idB string) int {
+		if c := compare(valueA, valueB); c != 0 {
+			return c
+		}
+		return strings.Compare(idA, idB)
+	}
+	sortKey := func(p *db.Project) any {
+		return db.ProjectSortValue(p, opts.Sort)
+	}
+
+	sort.Slice(projects, func(i, j int) bool {
+		c := compareKeys(sortKey(&projects[i]), projects[i].ID, sortKey(&projects[j]), projects[j].ID)
+		if opts.Descending {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	var result []db.Project
+	for i := range projects {
+		if opts.AfterID != "" {
+			c := compareKeys(sortKey(&projects[i]), projects[i].ID, opts.AfterValue, opts.AfterID)
+			if (opts.Descending && c >= 0) || (!opts.Descending && c <= 0) {
+				continue
+			}
+		}
+		if opts.Limit > 0 && len(result) == opts.Limit {
+			break
+		}
+		result = append(result, projects[i])
+	}
+	return result
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func newListFixture(count int) *mockProjectStore {
+	store := newMockStore()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+		store.projects[id] = &db.Project{
+			ID:        id,
+			UserID:    "test-user-id",
+			Name:      fmt.Sprintf("project-%02d", i),
+			Status:    db.StatusStopped,
+			CPUKind:   "shared",
+			Tags:      []string{},
+			CreatedAt: base.Add(time.Duration(i) * time.Hour),
+			UpdatedAt: base.Add(time.Duration(i) * time.Hour),
+		}
+	}
+	return store
+}
+
+func listProjects(t *testing.T, handler *ProjectHandler, query string) (ProjectListResponse, *httptest.ResponseRecorder) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handler.List(rr, newAuthenticatedRequest("GET", "/projects?"+query, nil))
+
+	var response ProjectListResponse
+	if rr.Code == http.StatusOK {
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return response, rr
+}
+
+func TestProjectHandler_List_Paginates(t *testing.T) {
+	store := newListFixture(5)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	var names []string
+	query := "limit=2"
+	for page := 0; ; page++ {
+		if page > 5 {
+			t.Fatal("pagination did not terminate")
+		}
+		response, rr := listProjects(t, handler, query)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		for _, p := range response.Projects {
+			names = append(names, p.Name)
+		}
+		if response.NextCursor == "" {
+			break
+		}
+		query = "limit=2&cursor=" + response.NextCursor
+	}
+
+	// Most recently updated first
+	want := []string{"project-04", "project-03", "project-02", "project-01", "project-00"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+}
+
+func TestProjectHandler_List_SortByName(t *testing.T) {
+	store := newListFixture(3)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	response, rr := listProjects(t, handler, "sort=name&limit=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if len(response.Projects) != 2 || response.Projects[0].Name != "project-00" {
+		t.Fatalf("expected alphabetical first page, got %+v", response.Projects)
+	}
+
+	// A cursor can't be replayed against another sort
+	_, rr = listProjects(t, handler, "sort=created_at&cursor="+response.NextCursor)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for mismatched cursor, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestProjectHandler_List_Filters(t *testing.T) {
+	store := newListFixture(4)
+	ids := make([]string, 0, 4)
+	for id := range store.projects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	store.projects[ids[0]].Status = db.StatusRunning
+	store.projects[ids[0]].Tags = []string{"client-acme", "ml"}
+	store.projects[ids[1]].Tags = []string{"client-acme"}
+	store.projects[ids[0]].Labels = map[string]string{"client": "globex", "team": "ml"}
+	store.projects[ids[1]].Labels = map[string]string{"client": "acme"}
+	store.projects[ids[2]].GPUKind = strPtr("a10")
+	store.projects[ids[3]].Description = strPtr("Landing page for ACME")
+
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"status=running", []string{"project-00"}},
+		{"status=stopped,running&tag=client-acme", []string{"project-01", "project-00"}},
+		{"tag=client-acme&tag=ml", []string{"project-00"}},
+		{"gpu=true", []string{"project-02"}},
+		{"gpu_kind=a10", []string{"project-02"}},
+		{"label=client:acme", []string{"project-01"}},
+		{"label=client", []string{"project-01", "project-00"}},
+		{"label=client&label=team:ml", []string{"project-00"}},
+		{"q=acme", []string{"project-03"}},
+		{"q=PROJECT-01", []string{"project-01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			response, rr := listProjects(t, handler, tt.query)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			var names []string
+			for _, p := range response.Projects {
+				names = append(names, p.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, names)
+			}
+		})
+	}
+}
+
+func TestProjectHandler_List_InvalidQuery(t *testing.T) {
+	handler := NewProjectHandler(newMockStore(), newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	for _, query := range []string{
+		"status=sleeping",
+		"status=trashed",
+		"cpu_kind=quantum",
+		"gpu=maybe",
+		"sort=size",
+		"order=sideways",
+		"limit=0",
+		"limit=1000",
+		"cursor=not-a-cursor",
+		"tag=Not%20A%20Tag",
+		"label=Client:acme",
+		"label=client:acme&label=client:globex",
+	} {
+		if _, rr := listProjects(t, handler, query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
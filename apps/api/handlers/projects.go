@@ -5,14 +5,18 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"os"
 	"time"
 
 	"aether/apps/api/db"
 	authmw "aether/apps/api/middleware"
+	"aether/apps/api/quota"
+	"aether/apps/api/regions"
 	"aether/apps/api/validation"
 	"aether/libs/go/logging"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 )
 
 // APIKeysGetter interface for fetching decrypted API keys
@@ -21,27 +25,44 @@ type APIKeysGetter interface {
 }
 
 type ProjectHandler struct {
-	store         ProjectStore
-	machines      MachineManager
-	volumes       VolumeManager
-	apiKeys       APIKeysGetter
-	baseImage     string
-	defaultRegion string
-	idleTimeout   time.Duration
+	store       ProjectStore
+	machines    MachineManager
+	volumes     VolumeManager
+	archives    VolumeArchiver
+	workspaces  WorkspaceProbe
+	apiKeys     APIKeysGetter
+	projectEnv  ProjectEnvProvider
+	baseImage   string
+	regions     *regions.Catalog
+	idleTimeout time.Duration
+	workerID    string
 }
 
-func NewProjectHandler(store ProjectStore, machines MachineManager, volumes VolumeManager, apiKeys APIKeysGetter, baseImage string, defaultRegion string, idleTimeout time.Duration) *ProjectHandler {
+func NewProjectHandler(store ProjectStore, machines MachineManager, volumes VolumeManager, archives VolumeArchiver, workspaces WorkspaceProbe, apiKeys APIKeysGetter, projectEnv ProjectEnvProvider, baseImage string, regions *regions.Catalog, idleTimeout time.Duration) *ProjectHandler {
 	return &ProjectHandler{
-		store:         store,
-		machines:      machines,
-		volumes:       volumes,
-		apiKeys:       apiKeys,
-		baseImage:     baseImage,
-		defaultRegion: defaultRegion,
-		idleTimeout:   idleTimeout,
+		store:       store,
+		machines:    machines,
+		volumes:     volumes,
+		archives:    archives,
+		workspaces:  workspaces,
+		apiKeys:     apiKeys,
+		projectEnv:  projectEnv,
+		baseImage:   baseImage,
+		regions:     regions,
+		idleTimeout: idleTimeout,
+		workerID:    newWorkerID(),
 	}
 }
 
+// newWorkerID identifies this replica when claiming operations
+func newWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "api"
+	}
+	return hostname + "-" + uuid.NewString()[:8]
+}
+
 // Request/Response types
 
 type HardwareConfigRequest struct {
@@ -61,48 +82,86 @@ type CreateProjectRequest struct {
 	Description        string                 `json:"description,omitempty"`
 	Hardware           *HardwareConfigRequest `json:"hardware,omitempty"`
 	IdleTimeoutMinutes *int                   `json:"idle_timeout_minutes,omitempty"`
+	// TemplateID is a built-in template name or the ID of one of the user's templates
+	TemplateID string            `json:"template_id,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	// Region defaults to the default region, or the first region offering the hardware
+	Region string `json:"region,omitempty"`
+	// OrganizationID creates the project in one of the user's organizations,
+	// whose default settings fill in hardware and idle timeout left unset
+	OrganizationID *string `json:"organization_id,omitempty"`
 }
 
 type UpdateProjectRequest struct {
-	Name        *string `json:"name,omitempty"`
-	Description *string `json:"description,omitempty"`
+	Name          *string `json:"name,omitempty"`
+	Description   *string `json:"description,omitempty"`
+	WakeOnRequest *bool   `json:"wake_on_request,omitempty"`
+	// Tags replaces the project's tags when set; an empty list clears them
+	Tags []string `json:"tags,omitempty"`
 }
 
 type ProjectResponse struct {
 	ID                 string                 `json:"id"`
+	OrganizationID     *string                `json:"organization_id,omitempty"`
+	Role               string                 `json:"role,omitempty"` // The caller's role on the project
 	Name               string                 `json:"name"`
 	Description        *string                `json:"description,omitempty"`
 	Status             string                 `json:"status"`
 	Hardware           HardwareConfigResponse `json:"hardware"`
+	HardwarePending    bool                   `json:"hardware_pending,omitempty"`
+	Region             *string                `json:"region,omitempty"`
+	EnvPending         bool                   `json:"env_pending,omitempty"`
+	LabelsPending      bool                   `json:"labels_pending,omitempty"`
+	Image              string                 `json:"image"`
+	ImagePending       bool                   `json:"image_pending,omitempty"`
 	IdleTimeoutMinutes *int                   `json:"idle_timeout_minutes,omitempty"`
 	FlyMachineID       *string                `json:"fly_machine_id,omitempty"`
+	ParentProjectID    *string                `json:"parent_project_id,omitempty"`
+	TemplateID         *string                `json:"template_id,omitempty"`
+	ExposedPorts       []int                  `json:"exposed_ports"`
+	Schedules          []ScheduleResponse     `json:"schedules,omitempty"`
 	PrivateIP          *string                `json:"private_ip,omitempty"`
 	LastAccessedAt     *time.Time             `json:"last_accessed_at,omitempty"`
+	IdleSnoozedUntil   *time.Time             `json:"idle_snoozed_until,omitempty"`
+	WakeOnRequest      bool                   `json:"wake_on_request"`
+	Tags               []string               `json:"tags"`
+	Labels             map[string]string      `json:"labels"`
+	Version            int64                  `json:"version"`
+	HibernatedAt       *time.Time             `json:"hibernated_at,omitempty"`
+	ArchiveSizeBytes   *int64                 `json:"archive_size_bytes,omitempty"`
+	DeletedAt          *time.Time             `json:"deleted_at,omitempty"`
 	CreatedAt          time.Time              `json:"created_at"`
 	UpdatedAt          time.Time              `json:"updated_at"`
 }
 
 type ProjectListResponse struct {
 	Projects []ProjectResponse `json:"projects"`
+	// NextCursor fetches the next page when passed as ?cursor=; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type StartResponse struct {
 	Status      string `json:"status"`
 	TerminalURL string `json:"terminal_url"`
+	OperationID string `json:"operation_id,omitempty"`
 }
 
 type StopResponse struct {
-	Status string `json:"status"`
+	Status      string `json:"status"`
+	OperationID string `json:"operation_id,omitempty"`
 }
 
 // Helpers
 
 func projectToResponse(p *db.Project) ProjectResponse {
 	return ProjectResponse{
-		ID:          p.ID,
-		Name:        p.Name,
-		Description: p.Description,
-		Status:      p.Status,
+		ID:             p.ID,
+		OrganizationID: p.OrganizationID,
+		Role:           p.Role,
+		Name:           p.Name,
+		Description:    p.Description,
+		Status:         p.Status,
 		Hardware: HardwareConfigResponse{
 			CPUKind:      p.CPUKind,
 			CPUs:         p.CPUs,
@@ -110,9 +169,26 @@ func projectToResponse(p *db.Project) ProjectResponse {
 			VolumeSizeGB: p.VolumeSizeGB,
 			GPUKind:      p.GPUKind,
 		},
+		HardwarePending:    p.HardwarePending,
+		Region:             p.Region,
+		EnvPending:         p.EnvPending,
+		LabelsPending:      p.LabelsPending,
+		Image:              p.Image(),
+		ImagePending:       p.ImagePending(),
 		IdleTimeoutMinutes: p.IdleTimeoutMinutes,
 		FlyMachineID:       p.FlyMachineID,
+		ParentProjectID:    p.ParentProjectID,
+		TemplateID:         p.TemplateID,
+		ExposedPorts:       p.ExposedPorts,
 		LastAccessedAt:     p.LastAccessedAt,
+		IdleSnoozedUntil:   p.IdleSnoozedUntil,
+		WakeOnRequest:      p.WakeOnRequest,
+		Tags:               p.Tags,
+		Labels:             p.Labels,
+		Version:            p.Version,
+		HibernatedAt:       p.HibernatedAt,
+		ArchiveSizeBytes:   p.ArchiveSizeBytes,
+		DeletedAt:          p.DeletedAt,
 		CreatedAt:          p.CreatedAt,
 		UpdatedAt:          p.UpdatedAt,
 	}
@@ -120,21 +196,54 @@ func projectToResponse(p *db.Project) ProjectResponse {
 
 // Handlers
 
+// List returns a page of the user's projects. See parseProjectListQuery for the
+// filters, sorts and paging it accepts.
 func (h *ProjectHandler) List(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID := authmw.GetUserID(ctx)
 	log := logging.FromContext(ctx)
 
-	projects, err := h.store.ListProjects(ctx, userID)
+	opts, errs := parseProjectListQuery(r.URL.Query())
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+
+	// Fetch one extra to tell whether there is another page
+	pageSize := opts.Limit
+	opts.Limit++
+	projects, err := h.store.ListProjects(ctx, userID, opts)
 	if err != nil {
 		log.Error("failed to list projects", "error", err)
 		WriteError(w, http.StatusInternalServerError, "Failed to list projects")
 		return
 	}
 
-	response := ProjectListResponse{Projects: make([]ProjectResponse, len(projects))}
+	schedules, err := h.store.ListUserSchedules(ctx, userID)
+	if err != nil {
+		log.Error("failed to list schedules", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list projects")
+		return
+	}
+	byProject := make(map[string][]db.Schedule)
+	for _, s := range schedules {
+		byProject[s.ProjectID] = append(byProject[s.ProjectID], s)
+	}
+
+	response := ProjectListResponse{}
+	if len(projects) > pageSize {
+		projects = projects[:pageSize]
+		response.NextCursor = encodeProjectCursor(&projects[pageSize-1], opts.Sort, opts.Descending)
+	}
+	response.Projects = make([]ProjectResponse, len(projects))
 	for i, p := range projects {
 		response.Projects[i] = projectToResponse(&p)
+		if projectSchedules := byProject[p.ID]; len(projectSchedules) > 0 {
+			response.Projects[i].Schedules = schedulesToResponse(projectSchedules)
+		}
 	}
 
 	WriteJSON(w, http.StatusOK, response)
@@ -152,11 +261,49 @@ func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Info("create project request", "name", req.Name, "hardware", req.Hardware, "idle_timeout_minutes", req.IdleTimeoutMinutes)
+	log.Info("create project request", "name", req.Name, "hardware", req.Hardware, "idle_timeout_minutes", req.IdleTimeoutMinutes, "template_id", req.TemplateID, "organization_id", req.OrganizationID)
+
+	var org *db.Organization
+	if req.OrganizationID != nil {
+		var ok bool
+		if org, ok = h.targetOrganization(w, r, *req.OrganizationID); !ok {
+			return
+		}
+	}
+
+	var template *db.Template
+	if req.TemplateID != "" {
+		var err error
+		template, err = findTemplate(ctx, h.store, req.TemplateID, userID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				WriteJSON(w, http.StatusBadRequest, map[string]any{
+					"error":  "Validation failed",
+					"errors": []validation.ValidationError{{Field: "template_id", Message: "template not found"}},
+				})
+				return
+			}
+			log.Error("failed to get template", "template_id", req.TemplateID, "error", err)
+			WriteError(w, http.StatusInternalServerError, "Failed to create project")
+			return
+		}
+	}
 
 	// Handle hardware config - frontend always sends the actual values
 	var hwConfig *validation.HardwareConfig
-	if req.Hardware != nil {
+	if req.Hardware == nil && template != nil && template.HardwarePreset != nil {
+		// The template's preset applies unless the request picks hardware
+		hwConfig = validation.GetPresetConfig(*template.HardwarePreset)
+	} else if req.Hardware == nil && org != nil && org.DefaultHardware != nil {
+		hw := org.DefaultHardware
+		hwConfig = &validation.HardwareConfig{
+			CPUKind:      hw.CPUKind,
+			CPUs:         hw.CPUs,
+			MemoryMB:     hw.MemoryMB,
+			VolumeSizeGB: hw.VolumeSizeGB,
+			GPUKind:      hw.GPUKind,
+		}
+	} else if req.Hardware != nil {
 		if req.Hardware.Preset != "" {
 			hwConfig = validation.GetPresetConfig(req.Hardware.Preset)
 		} else {
@@ -172,6 +319,9 @@ func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	// Idle timeout - frontend always sends the actual value
 	idleTimeoutMinutes := req.IdleTimeoutMinutes
+	if idleTimeoutMinutes == nil && org != nil {
+		idleTimeoutMinutes = org.DefaultIdleTimeoutMinutes
+	}
 
 	// Validate idle timeout
 	if err := validation.ValidateIdleTimeout(idleTimeoutMinutes); err != nil {
@@ -183,6 +333,24 @@ func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	input, errs := validation.ValidateCreateProject(req.Name, req.Description, hwConfig)
+	if err := validation.ValidateTags(req.Tags); err != nil {
+		errs = append(errs, *err)
+	}
+	if err := validation.ValidateLabels(req.Labels); err != nil {
+		errs = append(errs, *err)
+	}
+	var region string
+	if input != nil {
+		var verr *validation.ValidationError
+		if req.Region != "" {
+			region, verr = req.Region, h.regions.Validate(req.Region, input.Hardware)
+		} else {
+			region, verr = h.regions.Place(input.Hardware)
+		}
+		if verr != nil {
+			errs = append(errs, *verr)
+		}
+	}
 	if errs.HasErrors() {
 		log.Warn("validation failed for create project", "errors", errs)
 		WriteJSON(w, http.StatusBadRequest, map[string]any{
@@ -192,6 +360,13 @@ func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	releaseQuota, err := quota.Reserve(ctx, h.store, userID, req.OrganizationID, quota.ForNewProject(input.Hardware.VolumeSizeGB, input.Hardware.GPUKind))
+	if err != nil {
+		h.writeTransitionError(w, log, err, "create")
+		return
+	}
+	defer releaseQuota()
+
 	// Convert validation.HardwareConfig to db.HardwareConfig
 	dbHwConfig := &db.HardwareConfig{
 		CPUKind:      input.Hardware.CPUKind,
@@ -201,7 +376,23 @@ func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
 		GPUKind:      input.Hardware.GPUKind,
 	}
 
-	project, err := h.store.CreateProject(ctx, userID, input.Name, input.Description, h.baseImage, dbHwConfig, idleTimeoutMinutes)
+	// The template's env vars seed the project's own, as plain values
+	var envVarsEncrypted *string
+	if template != nil && len(template.EnvVars) > 0 {
+		if h.projectEnv == nil {
+			log.Warn("env vars not configured, skipping template env vars", "template_id", template.ID)
+		} else {
+			var err error
+			envVarsEncrypted, err = h.projectEnv.EncryptNewEnv(userID, template.EnvVars)
+			if err != nil {
+				log.Error("failed to encrypt template env vars", "template_id", template.ID, "error", err)
+				WriteError(w, http.StatusInternalServerError, "Failed to create project")
+				return
+			}
+		}
+	}
+
+	project, err := h.store.CreateProject(ctx, userID, input.Name, input.Description, h.baseImage, dbHwConfig, idleTimeoutMinutes, template, envVarsEncrypted, req.Tags, req.Labels, region, req.OrganizationID)
 	if err != nil {
 		log.Error("failed to create project", "name", input.Name, "error", err)
 		WriteError(w, http.StatusInternalServerError, "Failed to create project")
@@ -237,7 +428,21 @@ func (h *ProjectHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if notModified(w, r, project.Version) {
+		return
+	}
+
+	schedules, err := h.store.ListSchedules(ctx, projectID)
+	if err != nil {
+		log.Error("failed to list schedules", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get project")
+		return
+	}
+
 	response := projectToResponse(project)
+	if len(schedules) > 0 {
+		response.Schedules = schedulesToResponse(schedules)
+	}
 
 	// Fetch private IP from Fly if machine exists
 	if project.FlyMachineID != nil && *project.FlyMachineID != "" {
@@ -264,6 +469,11 @@ func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ifVersion, ok := readIfMatch(w, r)
+	if !ok {
+		return
+	}
+
 	var req UpdateProjectRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		WriteError(w, http.StatusBadRequest, "Invalid request body")
@@ -271,6 +481,9 @@ func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	input, errs := validation.ValidateUpdateProject(req.Name, req.Description)
+	if err := validation.ValidateTags(req.Tags); err != nil {
+		errs = append(errs, *err)
+	}
 	if errs.HasErrors() {
 		WriteJSON(w, http.StatusBadRequest, map[string]any{
 			"error":  "Validation failed",
@@ -279,20 +492,52 @@ func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	project, err := h.store.UpdateProject(ctx, projectID, userID, input.Name, input.Description)
+	current, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for update", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update project")
+		return
+	}
+	if !allowProject(w, current, roleOperate) {
+		return
+	}
+
+	// Waking needs an idle timeout to stop the project again, which is fixed at creation
+	if req.WakeOnRequest != nil && *req.WakeOnRequest {
+		if err := validation.ValidateWakeOnRequest(true, current.IdleTimeoutMinutes); err != nil {
+			WriteJSON(w, http.StatusBadRequest, map[string]any{
+				"error":  "Validation failed",
+				"errors": []validation.ValidationError{*err},
+			})
+			return
+		}
+	}
+
+	project, err := h.store.UpdateProject(ctx, projectID, userID, input.Name, input.Description, req.WakeOnRequest, req.Tags, ifVersion)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound) {
 			WriteError(w, http.StatusNotFound, "Project not found")
 			return
 		}
+		if errors.Is(err, db.ErrVersionMismatch) {
+			writePreconditionFailed(w, 0)
+			return
+		}
 		log.Error("failed to update project", "project_id", projectID, "error", err)
 		WriteError(w, http.StatusInternalServerError, "Failed to update project")
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(project.Version))
 	WriteJSON(w, http.StatusOK, projectToResponse(project))
 }
 
+// Delete moves a project to the trash, stopping its machine but keeping it and
+// the volume until the retention period ends. Deleting a trashed project purges it.
 func (h *ProjectHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID := authmw.GetUserID(ctx)
@@ -307,7 +552,6 @@ func (h *ProjectHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get project first to check for machine
 	project, err := h.store.GetProjectByUser(ctx, projectID, userID)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound) {
@@ -318,34 +562,142 @@ func (h *ProjectHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusInternalServerError, "Failed to delete project")
 		return
 	}
+	if !allowProject(w, project, roleManage) {
+		return
+	}
 
-	// Destroy Fly machine if exists
-	if project.FlyMachineID != nil && *project.FlyMachineID != "" {
-		if err := h.machines.DeleteMachine(*project.FlyMachineID); err != nil {
-			log.Error("failed to delete Fly machine", "machine_id", *project.FlyMachineID, "error", err)
-			WriteError(w, http.StatusInternalServerError, "Failed to delete VM from Fly.io")
+	if !checkIfMatch(w, r, project.Version) {
+		return
+	}
+
+	if project.Status == db.StatusTrashed {
+		// The purge outlives the request: once the project is deleting, giving up
+		// halfway would leave it there until the purger reclaims it
+		if err := h.PurgeProject(context.WithoutCancel(ctx), project); err != nil {
+			if errors.Is(err, db.ErrInvalidTransition) {
+				h.writeTransitionError(w, log, err, "delete")
+				return
+			}
+			log.Error("failed to purge project", "project_id", projectID, "error", err)
+			WriteError(w, http.StatusInternalServerError, "Failed to delete project")
 			return
 		}
+		w.WriteHeader(http.StatusNoContent)
+		return
 	}
 
-	// Destroy Fly volume if exists
-	if project.FlyVolumeID != nil && *project.FlyVolumeID != "" {
-		if err := h.volumes.DeleteVolume(*project.FlyVolumeID); err != nil {
-			log.Error("failed to delete Fly volume", "volume_id", *project.FlyVolumeID, "error", err)
-			WriteError(w, http.StatusInternalServerError, "Failed to delete volume from Fly.io")
-			return
+	// Claim the project so nothing can start it while it sits in the trash
+	err = h.store.TrashProject(ctx, projectID, []string{db.StatusStopped, db.StatusRunning, db.StatusError, db.StatusHibernated})
+	if err != nil {
+		h.writeTransitionError(w, log, err, "delete")
+		return
+	}
+
+	// The machine is kept for a restore but shouldn't keep running. If it
+	// can't be stopped the project goes back to running, so it is never left
+	// running unbilled and its usage keeps being recorded.
+	if project.FlyMachineID != nil && *project.FlyMachineID != "" {
+		if machine, err := h.machines.GetMachine(*project.FlyMachineID); err == nil && machine.State == "started" {
+			if err := h.machines.StopMachine(*project.FlyMachineID); err != nil {
+				log.Error("failed to stop machine for delete", "machine_id", *project.FlyMachineID, "error", err)
+				if err := h.store.RestoreTrashedProject(ctx, projectID, db.StatusRunning); err != nil {
+					log.Error("failed to restore project after stop failure", "project_id", projectID, "error", err)
+				}
+				WriteError(w, http.StatusInternalServerError, "Failed to stop project")
+				return
+			}
 		}
 	}
+	h.closeUsage(ctx, log, projectID, time.Now())
 
-	// Delete from database
-	if err := h.store.DeleteProject(ctx, projectID, userID); err != nil {
-		log.Error("failed to delete project", "project_id", projectID, "error", err)
+	project, err = h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		log.Error("failed to get trashed project", "project_id", projectID, "error", err)
 		WriteError(w, http.StatusInternalServerError, "Failed to delete project")
 		return
 	}
 
-	log.Info("project deleted", "project_id", projectID)
-	w.WriteHeader(http.StatusNoContent)
+	log.Info("project trashed", "project_id", projectID)
+	WriteJSON(w, http.StatusOK, projectToResponse(project))
+}
+
+// purgeStaleAfter is how long a project may sit in deleting before the purger
+// assumes whoever was deleting it died and takes over
+const purgeStaleAfter = 15 * time.Minute
+
+// PurgeProject deletes a trashed project along with its machine, volume,
+// snapshots and archive. If a resource can't be deleted the project goes back
+// to the trash with the error, so a later purge can retry. A project left
+// deleting by an interrupted purge is reclaimed once it is stale; every step
+// tolerates resources an earlier attempt already removed.
+func (h *ProjectHandler) PurgeProject(ctx context.Context, project *db.Project) error {
+	projectID := project.ID
+	log := logging.Default().With("project_id", projectID)
+
+	if project.Status == db.StatusDeleting {
+		if err := h.store.ReclaimDeletion(ctx, projectID, time.Now().Add(-purgeStaleAfter)); err != nil {
+			return err
+		}
+	} else if err := h.store.TransitionProjectStatus(ctx, projectID, []string{db.StatusTrashed}, db.StatusDeleting, nil); err != nil {
+		return err
+	}
+
+	if project.FlyMachineID != nil && *project.FlyMachineID != "" {
+		if err := h.stopAndDeleteMachine(*project.FlyMachineID); err != nil {
+			log.Error("failed to delete machine", "machine_id", *project.FlyMachineID, "error", err)
+			return h.abortPurge(ctx, log, projectID, "Failed to delete VM: "+err.Error())
+		}
+	}
+
+	if project.FlyVolumeID != nil && *project.FlyVolumeID != "" {
+		if err := h.volumes.DeleteVolume(*project.FlyVolumeID); err != nil && !h.volumeGone(*project.FlyVolumeID) {
+			log.Error("failed to delete volume", "volume_id", *project.FlyVolumeID, "error", err)
+			return h.abortPurge(ctx, log, projectID, "Failed to delete volume: "+err.Error())
+		}
+	}
+
+	// Snapshot rows go with the project, but the backend copies have to be removed here
+	snapshots, err := h.store.ListSnapshots(ctx, projectID)
+	if err != nil {
+		log.Error("failed to list snapshots for delete", "error", err)
+	}
+	for _, s := range snapshots {
+		if err := h.volumes.DeleteSnapshot(s.ProviderSnapshotID); err != nil {
+			log.Error("failed to delete snapshot", "snapshot_id", s.ID, "error", err)
+		}
+	}
+
+	// So does a hibernated project's archive
+	if project.ArchiveKey != nil {
+		if h.archives == nil {
+			log.Warn("no archive store configured, leaving archive behind", "key", *project.ArchiveKey)
+		} else if err := h.archives.DeleteArchive(ctx, *project.ArchiveKey); err != nil {
+			log.Error("failed to delete archive", "key", *project.ArchiveKey, "error", err)
+		}
+	}
+
+	if err := h.store.DeleteProject(ctx, projectID, project.UserID); err != nil {
+		return err
+	}
+
+	log.Info("project deleted")
+	return nil
+}
+
+// volumeGone reports whether a volume that failed to delete no longer exists,
+// as when an interrupted purge already deleted it
+func (h *ProjectHandler) volumeGone(volumeID string) bool {
+	volume, err := h.volumes.GetVolume(volumeID)
+	return err != nil || volume.State == "destroyed" || volume.State == "pending_destroy"
+}
+
+// abortPurge returns a project to the trash with the reason it couldn't be
+// deleted, and returns the reason as an error
+func (h *ProjectHandler) abortPurge(ctx context.Context, log *logging.Logger, projectID, errMsg string) error {
+	if err := h.store.TransitionProjectStatus(ctx, projectID, []string{db.StatusDeleting}, db.StatusTrashed, &errMsg); err != nil {
+		log.Error("failed to update project status", "error", err)
+	}
+	return errors.New(errMsg)
 }
 
 func (h *ProjectHandler) Start(w http.ResponseWriter, r *http.Request) {
@@ -372,45 +724,65 @@ func (h *ProjectHandler) Start(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusInternalServerError, "Failed to start project")
 		return
 	}
-
-	// Update status to starting
-	if err := h.store.UpdateProjectStatus(ctx, projectID, "starting", nil); err != nil {
-		log.Error("failed to update project status", "project_id", projectID, "status", "starting", "error", err)
+	if !allowProject(w, project, roleOperate) {
+		return
 	}
 
-	log.Info("starting project", "project_id", projectID)
+	op, err := h.beginStart(ctx, project)
+	if err != nil {
+		h.writeTransitionError(w, log, err, "start")
+		return
+	}
 
-	// Start machine in background goroutine
-	go h.startMachineAsync(projectID, project, userID)
+	log.Info("starting project", "project_id", projectID, "operation_id", op.ID)
 
 	// Return immediately
 	WriteJSON(w, http.StatusAccepted, StartResponse{
 		Status:      "starting",
 		TerminalURL: "/projects/" + projectID + "/terminal",
+		OperationID: op.ID,
 	})
 }
 
-// startMachineAsync handles machine creation/startup in the background
-func (h *ProjectHandler) startMachineAsync(projectID string, project *db.Project, userID string) {
-	ctx := context.Background()
-	log := logging.Default().With("project_id", projectID, "user_id", userID)
+// beginStart moves a stopped project to starting and runs the start operation in the
+// background. The state change and the operation are recorded together so a crash
+// after this point can be resumed. The plan the project counts against must have
+// room for the machine, whoever is starting it.
+func (h *ProjectHandler) beginStart(ctx context.Context, project *db.Project) (*db.Operation, error) {
+	// A project that can't start gets the transition error rather than a quota one
+	if db.CanTransition(project.Status, db.StatusStarting) {
+		releaseQuota, err := quota.Reserve(ctx, h.store, project.UserID, project.OrganizationID, quota.ForStart(project))
+		if err != nil {
+			return nil, err
+		}
+		defer releaseQuota()
+	}
+
+	op, err := h.store.BeginOperation(ctx, project.ID, project.UserID, db.OperationStart, []string{db.StatusStopped, db.StatusError, db.StatusHibernated}, db.StatusStarting)
+	if err != nil {
+		return nil, err
+	}
+	h.dispatchOperation(op)
+	return op, nil
+}
+
+// startMachineAsync handles machine creation/startup for a start operation.
+// Every step checks current state first so a resumed operation can re-run it safely.
+func (h *ProjectHandler) startMachineAsync(ctx context.Context, op *db.Operation, project *db.Project) error {
+	projectID := project.ID
+	log := logging.Default().With("project_id", projectID, "user_id", op.UserID, "operation_id", op.ID)
 
 	// Create volume if it doesn't exist
 	if project.FlyVolumeID == nil || *project.FlyVolumeID == "" {
-		volumeName := "vol_" + projectID[:8]
-		// GPU machines must be in ord region, so volumes must match
-		region := h.defaultRegion
-		if project.GPUKind != nil && *project.GPUKind != "" {
-			region = "ord"
+		if err := h.setOperationStep(ctx, op, "create_volume"); err != nil {
+			return err
 		}
-		volume, err := h.volumes.CreateVolume(volumeName, project.VolumeSizeGB, region)
+
+		volumeName := "vol_" + projectID[:8]
+		volume, err := h.volumes.CreateVolume(volumeName, project.VolumeSizeGB, h.projectRegion(project))
 		if err != nil {
 			log.Error("failed to create volume", "error", err)
-			errMsg := "Failed to create storage volume: " + err.Error()
-			if err := h.store.UpdateProjectStatus(ctx, projectID, "error", &errMsg); err != nil {
-				log.Error("failed to update project status", "error", err)
-			}
-			return
+			return h.failProject(ctx, log, projectID, "Failed to create storage volume: "+err.Error())
 		}
 
 		if err := h.store.UpdateProjectVolume(ctx, projectID, volume.ID); err != nil {
@@ -421,48 +793,144 @@ func (h *ProjectHandler) startMachineAsync(projectID string, project *db.Project
 		log.Info("created volume", "volume_id", volume.ID)
 	}
 
+	// The machine runs an older image; recreate it on the current one, keeping the volume
+	var replacedImage *string
+	if project.ImagePending() {
+		if err := h.setOperationStep(ctx, op, "replace_machine"); err != nil {
+			return err
+		}
+		replacedImage = project.MachineImage
+		if err := h.replaceMachine(ctx, project); err != nil {
+			log.Error("failed to replace machine", "error", err)
+			return h.failProject(ctx, log, projectID, "Failed to replace machine for image upgrade: "+err.Error())
+		}
+		log.Info("deleted machine for image upgrade", "from", *replacedImage, "to", project.Image())
+	}
+
+	// Hardware changed since the machine and volume were created
+	if project.HardwarePending {
+		if err := h.setOperationStep(ctx, op, "apply_hardware"); err != nil {
+			return err
+		}
+		if err := h.applyHardware(ctx, op, project); err != nil {
+			log.Error("failed to apply hardware", "error", err)
+			return h.failProject(ctx, log, projectID, "Failed to apply hardware change: "+err.Error())
+		}
+		log.Info("applied hardware change", "cpu_kind", project.CPUKind, "cpus", project.CPUs, "memory_mb", project.MemoryMB, "volume_size_gb", project.VolumeSizeGB)
+	}
+
+	// Env vars changed since the machine was configured
+	if project.EnvPending && project.FlyMachineID != nil && *project.FlyMachineID != "" {
+		if err := h.setOperationStep(ctx, op, "apply_env"); err != nil {
+			return err
+		}
+		if err := h.machines.UpdateMachine(*project.FlyMachineID, h.machineConfig(ctx, project, op.UserID)); err != nil {
+			log.Error("failed to apply env vars", "error", err)
+			return h.failProject(ctx, log, projectID, "Failed to apply env vars: "+err.Error())
+		}
+		// Only clears if the vars weren't changed again while applying
+		if err := h.store.ClearProjectEnvPending(ctx, projectID, project.EnvVarsEncrypted); err != nil {
+			log.Error("failed to clear env pending", "error", err)
+		}
+		log.Info("applied env vars")
+	}
+
+	// Labels changed since the machine was configured
+	if project.LabelsPending && project.FlyMachineID != nil && *project.FlyMachineID != "" {
+		if err := h.setOperationStep(ctx, op, "apply_labels"); err != nil {
+			return err
+		}
+		if err := h.machines.UpdateMachine(*project.FlyMachineID, h.machineConfig(ctx, project, op.UserID)); err != nil {
+			log.Error("failed to apply labels", "error", err)
+			return h.failProject(ctx, log, projectID, "Failed to apply labels: "+err.Error())
+		}
+		// Only clears if the labels weren't changed again while applying
+		if err := h.store.ClearProjectLabelsPending(ctx, projectID, project.Labels); err != nil {
+			log.Error("failed to clear labels pending", "error", err)
+		}
+		log.Info("applied labels")
+	}
+
 	// If no machine exists, create one
 	if project.FlyMachineID == nil || *project.FlyMachineID == "" {
-		machine, err := h.createMachine(ctx, project, userID)
+		if err := h.setOperationStep(ctx, op, "create_machine"); err != nil {
+			return err
+		}
+
+		machine, err := h.createMachine(ctx, project, op.UserID)
 		if err != nil {
 			log.Error("failed to create machine", "error", err)
-			errMsg := err.Error()
-			if err := h.store.UpdateProjectStatus(ctx, projectID, "error", &errMsg); err != nil {
-				log.Error("failed to update project status", "error", err)
-			}
-			return
+			return h.failProject(ctx, log, projectID, err.Error())
 		}
 
-		if err := h.store.UpdateProjectMachine(ctx, projectID, machine.ID); err != nil {
+		image := project.Image()
+		if err := h.store.UpdateProjectMachine(ctx, projectID, machine.ID, image); err != nil {
 			log.Error("failed to update project machine ID", "error", err)
 		}
 
 		project.FlyMachineID = &machine.ID
+		project.MachineImage = &image
+		if replacedImage != nil {
+			if err := h.store.RecordProjectEvent(ctx, projectID, db.EventImageUpgraded, nil, map[string]any{"from": *replacedImage, "to": image}); err != nil {
+				log.Error("failed to record image upgrade event", "error", err)
+			}
+		}
 	} else {
-		// Machine exists, start it
-		if err := h.machines.StartMachine(*project.FlyMachineID); err != nil {
-			log.Error("failed to start machine", "error", err)
-			errMsg := err.Error()
-			if err := h.store.UpdateProjectStatus(ctx, projectID, "error", &errMsg); err != nil {
-				log.Error("failed to update project status", "error", err)
+		if err := h.setOperationStep(ctx, op, "start_machine"); err != nil {
+			return err
+		}
+
+		// Machine exists, start it unless a previous attempt already did
+		machine, err := h.machines.GetMachine(*project.FlyMachineID)
+		if err != nil || machine.State != "started" {
+			if err := h.machines.StartMachine(*project.FlyMachineID); err != nil {
+				log.Error("failed to start machine", "error", err)
+				return h.failProject(ctx, log, projectID, err.Error())
 			}
-			return
 		}
 	}
 
 	// Wait for machine to be running
+	if err := h.setOperationStep(ctx, op, "wait_started"); err != nil {
+		return err
+	}
 	if err := h.machines.WaitForState(*project.FlyMachineID, "started", 60*time.Second); err != nil {
 		log.Error("failed waiting for machine to start", "error", err)
-		errMsg := err.Error()
-		if err := h.store.UpdateProjectStatus(ctx, projectID, "error", &errMsg); err != nil {
-			log.Error("failed to update project status", "error", err)
+		return h.failProject(ctx, log, projectID, err.Error())
+	}
+	h.openUsage(ctx, log, project, time.Now())
+
+	// A hibernated project gets its files back before anything else runs in it
+	if project.ArchiveKey != nil {
+		if err := h.setOperationStep(ctx, op, "restore_archive"); err != nil {
+			return err
 		}
-		return
+		if err := h.restoreArchive(ctx, op, project, log); err != nil {
+			log.Error("failed to restore archive", "error", err)
+			return h.failProject(ctx, log, projectID, "Failed to restore hibernated project: "+err.Error())
+		}
+	}
+
+	// Refresh the workspace .env file before any template script can read it
+	if h.projectEnv != nil {
+		if err := h.setOperationStep(ctx, op, "write_env"); err != nil {
+			return err
+		}
+		h.writeEnvFile(ctx, log, project)
+	}
+
+	// First boot of a project created from a template
+	if project.TemplateSetup != nil {
+		if err := h.setOperationStep(ctx, op, "apply_template"); err != nil {
+			return err
+		}
+		h.applyTemplate(ctx, log, project)
 	}
 
 	// Update status to running
-	if err := h.store.UpdateProjectStatus(ctx, projectID, "running", nil); err != nil {
+	if err := h.completeTransition(ctx, projectID, db.StatusStarting, db.StatusRunning); err != nil {
 		log.Error("failed to update project status", "error", err)
+		return err
 	}
 
 	// Update last accessed
@@ -471,6 +939,7 @@ func (h *ProjectHandler) startMachineAsync(projectID string, project *db.Project
 	}
 
 	log.Info("project started successfully")
+	return nil
 }
 
 func (h *ProjectHandler) Stop(w http.ResponseWriter, r *http.Request) {
@@ -497,88 +966,128 @@ func (h *ProjectHandler) Stop(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusInternalServerError, "Failed to stop project")
 		return
 	}
-
-	if project.FlyMachineID == nil || *project.FlyMachineID == "" {
-		WriteError(w, http.StatusBadRequest, "Project has no VM to stop")
+	if !allowProject(w, project, roleOperate) {
 		return
 	}
 
-	// Update status to stopping
-	if err := h.store.UpdateProjectStatus(ctx, projectID, "stopping", nil); err != nil {
-		log.Error("failed to update project status", "project_id", projectID, "status", "stopping", "error", err)
+	op, err := h.beginStop(ctx, project)
+	if err != nil {
+		if errors.Is(err, errNoMachine) {
+			WriteError(w, http.StatusBadRequest, "Project has no VM to stop")
+			return
+		}
+		h.writeTransitionError(w, log, err, "stop")
+		return
 	}
 
-	log.Info("stopping project", "project_id", projectID)
-
-	// Stop machine in background goroutine
-	go h.stopMachineAsync(projectID, *project.FlyMachineID)
+	log.Info("stopping project", "project_id", projectID, "operation_id", op.ID)
 
 	// Return immediately
-	WriteJSON(w, http.StatusAccepted, StopResponse{Status: "stopping"})
+	WriteJSON(w, http.StatusAccepted, StopResponse{Status: "stopping", OperationID: op.ID})
 }
 
-// stopMachineAsync handles machine shutdown in the background
-func (h *ProjectHandler) stopMachineAsync(projectID string, machineID string) {
-	ctx := context.Background()
-	log := logging.Default().With("project_id", projectID, "machine_id", machineID)
+// errNoMachine is returned when stopping a project that has never been started
+var errNoMachine = errors.New("project has no machine")
 
-	// Stop the machine
-	if err := h.machines.StopMachine(machineID); err != nil {
-		log.Error("failed to stop machine", "error", err)
-		errMsg := err.Error()
-		if err := h.store.UpdateProjectStatus(ctx, projectID, "error", &errMsg); err != nil {
-			log.Error("failed to update project status", "error", err)
-		}
-		return
+// beginStop moves a running project to stopping and runs the stop operation in the background
+func (h *ProjectHandler) beginStop(ctx context.Context, project *db.Project) (*db.Operation, error) {
+	if project.FlyMachineID == nil || *project.FlyMachineID == "" {
+		return nil, errNoMachine
 	}
+	op, err := h.store.BeginOperation(ctx, project.ID, project.UserID, db.OperationStop, []string{db.StatusRunning, db.StatusError}, db.StatusStopping)
+	if err != nil {
+		return nil, err
+	}
+	h.dispatchOperation(op)
+	return op, nil
+}
+
+// stopMachineAsync handles machine shutdown for a stop operation
+func (h *ProjectHandler) stopMachineAsync(ctx context.Context, op *db.Operation, project *db.Project) error {
+	projectID := project.ID
+	log := logging.Default().With("project_id", projectID, "operation_id", op.ID)
 
-	// Wait for machine to be stopped
-	if err := h.machines.WaitForState(machineID, "stopped", 30*time.Second); err != nil {
-		log.Error("failed waiting for machine to stop", "error", err)
-		errMsg := err.Error()
-		if err := h.store.UpdateProjectStatus(ctx, projectID, "error", &errMsg); err != nil {
-			log.Error("failed to update project status", "error", err)
+	if project.FlyMachineID != nil && *project.FlyMachineID != "" {
+		machineID := *project.FlyMachineID
+		log = log.With("machine_id", machineID)
+
+		if err := h.setOperationStep(ctx, op, "stop_machine"); err != nil {
+			return err
 		}
-		return
+
+		// Stop the machine unless a previous attempt already did
+		machine, err := h.machines.GetMachine(machineID)
+		if err != nil || machine.State != "stopped" {
+			if err := h.machines.StopMachine(machineID); err != nil {
+				log.Error("failed to stop machine", "error", err)
+				return h.failProject(ctx, log, projectID, err.Error())
+			}
+		}
+
+		// Wait for machine to be stopped
+		if err := h.setOperationStep(ctx, op, "wait_stopped"); err != nil {
+			return err
+		}
+		if err := h.machines.WaitForState(machineID, "stopped", 30*time.Second); err != nil {
+			log.Error("failed waiting for machine to stop", "error", err)
+			return h.failProject(ctx, log, projectID, err.Error())
+		}
+		h.closeUsage(ctx, log, projectID, time.Now())
 	}
 
 	// Update status to stopped
-	if err := h.store.UpdateProjectStatus(ctx, projectID, "stopped", nil); err != nil {
+	if err := h.completeTransition(ctx, projectID, db.StatusStopping, db.StatusStopped); err != nil {
 		log.Error("failed to update project status", "error", err)
+		return err
 	}
 
 	log.Info("project stopped successfully")
+	return nil
 }
 
 func (h *ProjectHandler) createMachine(ctx context.Context, project *db.Project, userID string) (*Machine, error) {
+	machineName := "aether-" + project.ID[:8]
+	return h.machines.CreateMachine(machineName, h.machineConfig(ctx, project, userID))
+}
+
+// machineConfig builds the machine config for a project's current hardware and volume
+func (h *ProjectHandler) machineConfig(ctx context.Context, project *db.Project, userID string) MachineConfig {
 	log := logging.Default().With("project_id", project.ID, "user_id", userID)
 	var guestConfig GuestConfig
 
 	// GPU machines require cpu_kind and cpus, but Fly.io determines actual compute from gpu_kind
 	if project.GPUKind != nil && *project.GPUKind != "" {
 		guestConfig = GuestConfig{
-			CPUKind:  "performance",
-			CPUs:     8,
-			MemoryMB: 16384,
+			CPUKind:  validation.GPUMachineCPUKind,
+			CPUs:     validation.GPUMachineCPUs,
+			MemoryMB: validation.GPUMachineMemoryMB,
 			GPUKind:  *project.GPUKind,
 		}
-		log.Info("creating GPU machine", "gpu_kind", *project.GPUKind)
+		log.Info("configuring GPU machine", "gpu_kind", *project.GPUKind)
 	} else {
 		guestConfig = GuestConfig{
 			CPUKind:  project.CPUKind,
 			CPUs:     project.CPUs,
 			MemoryMB: project.MemoryMB,
 		}
-		log.Info("creating CPU machine", "cpu_kind", project.CPUKind, "cpus", project.CPUs, "memory_mb", project.MemoryMB)
+		log.Info("configuring CPU machine", "cpu_kind", project.CPUKind, "cpus", project.CPUs, "memory_mb", project.MemoryMB)
 	}
 
 	// Build environment variables
-	machineEnv := NewEnvBuilder(h.apiKeys).BuildEnv(ctx, project.ID, userID, nil)
-	log.Debug("creating machine", "env_count", len(machineEnv))
+	machineEnv := NewEnvBuilder(h.apiKeys, h.projectEnv).BuildEnv(ctx, project.ID, userID, nil)
+	log.Debug("building machine config", "env_count", len(machineEnv))
+
+	image := project.Image()
+	if image == "" {
+		image = h.baseImage
+	}
+
 	config := MachineConfig{
-		Image: h.baseImage,
-		Guest: guestConfig,
-		Env:   machineEnv,
+		Region: h.projectRegion(project),
+		Image:  image,
+		Guest:  guestConfig,
+		Env:    machineEnv,
+		Labels: project.Labels,
 	}
 
 	// Attach volume if exists
@@ -589,65 +1098,5 @@ func (h *ProjectHandler) createMachine(ctx context.Context, project *db.Project,
 		}}
 	}
 
-	machineName := "aether-" + project.ID[:8]
-	return h.machines.CreateMachine(machineName, config)
-}
-
-// StartIdleChecker starts background goroutine to stop idle projects
-func (h *ProjectHandler) StartIdleChecker(interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	go func() {
-		for range ticker.C {
-			h.checkIdleProjects()
-		}
-	}()
-}
-
-func (h *ProjectHandler) checkIdleProjects() {
-	ctx := context.Background()
-	log := logging.Default()
-
-	projects, err := h.store.GetRunningProjects(ctx)
-	if err != nil {
-		log.Error("failed to check idle projects", "error", err)
-		return
-	}
-
-	for _, p := range projects {
-		// Only check projects that have an explicit idle timeout set
-		if p.IdleTimeoutMinutes == nil {
-			// No idle timeout set - never auto-stop
-			continue
-		}
-
-		if *p.IdleTimeoutMinutes == 0 {
-			// 0 means never auto-stop
-			continue
-		}
-
-		timeout := time.Duration(*p.IdleTimeoutMinutes) * time.Minute
-
-		if p.LastAccessedAt == nil {
-			continue
-		}
-
-		idleFor := time.Since(*p.LastAccessedAt)
-		projectLog := log.With("project_id", p.ID)
-		projectLog.Debug("idle check", "idle_for", idleFor.Round(time.Second), "timeout", timeout)
-
-		if idleFor <= timeout {
-			continue
-		}
-
-		projectLog.Info("stopping idle project", "idle_for", idleFor, "timeout", timeout)
-		if p.FlyMachineID != nil && *p.FlyMachineID != "" {
-			if err := h.machines.StopMachine(*p.FlyMachineID); err != nil {
-				projectLog.Error("failed to stop idle machine", "error", err)
-				continue
-			}
-		}
-		if err := h.store.UpdateProjectStatus(ctx, p.ID, "stopped", nil); err != nil {
-			projectLog.Error("failed to update idle project status", "error", err)
-		}
-	}
+	return config
 }
@@ -6,43 +6,93 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"aether/apps/api/db"
 	authmw "aether/apps/api/middleware"
+	"aether/apps/api/regions"
 
 	"github.com/go-chi/chi/v5"
 )
 
+// testRegions places CPU projects in sjc and GPU projects in ord
+var testRegions = regions.Default("sjc")
+
 // Mock implementations
 
 type mockProjectStore struct {
 	projects       map[string]*db.Project
-	listProjectsFn func(ctx context.Context, userID string) ([]db.Project, error)
-	createFn       func(ctx context.Context, userID, name string, description *string, baseImage string, hw *db.HardwareConfig, idleTimeoutMinutes *int) (*db.Project, error)
+	listProjectsFn func(ctx context.Context, userID string, opts db.ProjectListOptions) ([]db.Project, error)
+	createFn       func(ctx context.Context, userID, name string, description *string, baseImage string, hw *db.HardwareConfig, idleTimeoutMinutes *int, tmpl *db.Template, envVarsEncrypted *string, tags []string, labels map[string]string, region string, organizationID *string) (*db.Project, error)
 	getFn          func(ctx context.Context, projectID, userID string) (*db.Project, error)
-	updateFn       func(ctx context.Context, projectID, userID string, name, description *string) (*db.Project, error)
+	updateFn       func(ctx context.Context, projectID, userID string, name, description *string, wakeOnRequest *bool, tags []string, ifVersion *int64) (*db.Project, error)
 	deleteFn       func(ctx context.Context, projectID, userID string) error
+
+	opsMu      sync.Mutex
+	operations map[string]*db.Operation
+
+	snapshots map[string]*db.Snapshot
+	templates map[string]*db.Template
+	schedules map[string]*db.Schedule
+
+	// Plans by ID, and the plan each user and organization is on
+	plans     map[string]*db.Plan
+	userPlans map[string]string
+	orgPlans  map[string]string
+	// Accounts whose quota is locked between a check and what it's checked for
+	quotaLocked map[string]bool
+
+	usage    []db.UsageInterval
+	llmUsage []db.LLMUsage
+	budgets  map[string]*db.AgentBudget
+
+	// Organizations by ID, and each one's members' roles by user ID
+	orgs    map[string]*db.Organization
+	members map[string]map[string]string
+	invites map[string]*db.OrganizationInvitation
+
+	// Event log that applied transitions are written to, as the db does
+	events *mockEventStore
 }
 
 func newMockStore() *mockProjectStore {
 	return &mockProjectStore{
-		projects: make(map[string]*db.Project),
+		projects:   make(map[string]*db.Project),
+		operations: make(map[string]*db.Operation),
+		snapshots:  make(map[string]*db.Snapshot),
+		templates:  make(map[string]*db.Template),
+		schedules:  make(map[string]*db.Schedule),
+		budgets:    make(map[string]*db.AgentBudget),
+		orgs:       make(map[string]*db.Organization),
+		members:    make(map[string]map[string]string),
+		invites:    make(map[string]*db.OrganizationInvitation),
 	}
 }
 
-func (m *mockProjectStore) ListProjects(ctx context.Context, userID string) ([]db.Project, error) {
+// roleOn is the user's role on a project, or "" if they can't reach it
+func (m *mockProjectStore) roleOn(p *db.Project, userID string) string {
+	if p.OrganizationID == nil {
+		if p.UserID == userID {
+			return db.RoleOwner
+		}
+		return ""
+	}
+	return m.members[*p.OrganizationID][userID]
+}
+
+func (m *mockProjectStore) ListProjects(ctx context.Context, userID string, opts db.ProjectListOptions) ([]db.Project, error) {
 	if m.listProjectsFn != nil {
-		return m.listProjectsFn(ctx, userID)
+		return m.listProjectsFn(ctx, userID, opts)
 	}
 	var result []db.Project
 	for _, p := range m.projects {
-		if p.UserID == userID {
+		if m.roleOn(p, userID) != "" && p.Status != db.StatusTrashed && mockProjectMatches(p, opts) {
 			result = append(result, *p)
 		}
 	}
-	return result, nil
+	return pageMockProjects(result, opts), nil
 }
 
 func (m *mockProjectStore) GetProject(ctx context.Context, projectID string) (*db.Project, error) {
@@ -56,15 +106,16 @@ func (m *mockProjectStore) GetProjectByUser(ctx context.Context, projectID, user
 	if m.getFn != nil {
 		return m.getFn(ctx, projectID, userID)
 	}
-	if p, ok := m.projects[projectID]; ok && p.UserID == userID {
+	if p, ok := m.projects[projectID]; ok && m.roleOn(p, userID) != "" {
+		p.Role = m.roleOn(p, userID)
 		return p, nil
 	}
 	return nil, db.ErrNotFound
 }
 
-func (m *mockProjectStore) CreateProject(ctx context.Context, userID, name string, description *string, baseImage string, hw *db.HardwareConfig, idleTimeoutMinutes *int) (*db.Project, error) {
+func (m *mockProjectStore) CreateProject(ctx context.Context, userID, name string, description *string, baseImage string, hw *db.HardwareConfig, idleTimeoutMinutes *int, tmpl *db.Template, envVarsEncrypted *string, tags []string, labels map[string]string, region string, organizationID *string) (*db.Project, error) {
 	if m.createFn != nil {
-		return m.createFn(ctx, userID, name, description, baseImage, hw, idleTimeoutMinutes)
+		return m.createFn(ctx, userID, name, description, baseImage, hw, idleTimeoutMinutes, tmpl, envVarsEncrypted, tags, labels, region, organizationID)
 	}
 	// Default hardware config
 	cpuKind := "shared"
@@ -78,37 +129,59 @@ func (m *mockProjectStore) CreateProject(ctx context.Context, userID, name strin
 		volumeSizeGB = hw.VolumeSizeGB
 	}
 	p := &db.Project{
-		ID:           "test-project-id",
-		UserID:       userID,
-		Name:         name,
-		Description:  description,
-		Status:       "stopped",
-		BaseImage:    baseImage,
-		CPUKind:      cpuKind,
-		CPUs:         cpus,
-		MemoryMB:     memoryMB,
-		VolumeSizeGB: volumeSizeGB,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+		ID:               "test-project-id",
+		UserID:           userID,
+		OrganizationID:   organizationID,
+		Name:             name,
+		Description:      description,
+		Status:           "stopped",
+		BaseImage:        baseImage,
+		CPUKind:          cpuKind,
+		CPUs:             cpus,
+		MemoryMB:         memoryMB,
+		VolumeSizeGB:     volumeSizeGB,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+		EnvVarsEncrypted: envVarsEncrypted,
+		Tags:             tags,
+		Labels:           labels,
+		Region:           &region,
+	}
+	if tmpl != nil {
+		p.TemplateID = &tmpl.ID
+		p.TemplateImage = tmpl.BaseImage
+		p.ExposedPorts = tmpl.Ports
+		p.TemplateSetup = tmpl.Setup()
 	}
+	p.Role = m.roleOn(p, userID)
 	m.projects[p.ID] = p
 	return p, nil
 }
 
-func (m *mockProjectStore) UpdateProject(ctx context.Context, projectID, userID string, name, description *string) (*db.Project, error) {
+func (m *mockProjectStore) UpdateProject(ctx context.Context, projectID, userID string, name, description *string, wakeOnRequest *bool, tags []string, ifVersion *int64) (*db.Project, error) {
 	if m.updateFn != nil {
-		return m.updateFn(ctx, projectID, userID, name, description)
+		return m.updateFn(ctx, projectID, userID, name, description, wakeOnRequest, tags, ifVersion)
 	}
 	p, ok := m.projects[projectID]
-	if !ok || p.UserID != userID {
+	if !ok || m.roleOn(p, userID) == "" {
 		return nil, db.ErrNotFound
 	}
+	if ifVersion != nil && *ifVersion != p.Version {
+		return nil, db.ErrVersionMismatch
+	}
 	if name != nil {
 		p.Name = *name
 	}
 	if description != nil {
 		p.Description = description
 	}
+	if wakeOnRequest != nil {
+		p.WakeOnRequest = *wakeOnRequest
+	}
+	if tags != nil {
+		p.Tags = tags
+	}
+	p.Version++
 	p.UpdatedAt = time.Now()
 	return p, nil
 }
@@ -124,18 +197,34 @@ func (m *mockProjectStore) DeleteProject(ctx context.Context, projectID, userID
 	return db.ErrNotFound
 }
 
-func (m *mockProjectStore) UpdateProjectStatus(ctx context.Context, projectID, status string, errorMsg *string) error {
-	if p, ok := m.projects[projectID]; ok {
-		p.Status = status
-		p.ErrorMessage = errorMsg
-		return nil
+func (m *mockProjectStore) TransitionProjectStatus(ctx context.Context, projectID string, from []string, to string, errorMsg *string) error {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	return m.transition(projectID, from, to, errorMsg)
+}
+
+func (m *mockProjectStore) transition(projectID string, from []string, to string, errorMsg *string) error {
+	p, ok := m.projects[projectID]
+	if !ok {
+		return db.ErrNotFound
 	}
-	return nil
+	for _, f := range from {
+		if p.Status == f {
+			p.Status = to
+			p.ErrorMessage = errorMsg
+			if m.events != nil {
+				m.events.record(p, db.EventStatus)
+			}
+			return nil
+		}
+	}
+	return &db.TransitionError{Current: p.Status, Target: to}
 }
 
-func (m *mockProjectStore) UpdateProjectMachine(ctx context.Context, projectID, machineID string) error {
+func (m *mockProjectStore) UpdateProjectMachine(ctx context.Context, projectID, machineID, image string) error {
 	if p, ok := m.projects[projectID]; ok {
 		p.FlyMachineID = &machineID
+		p.MachineImage = &image
 		return nil
 	}
 	return nil
@@ -151,7 +240,13 @@ func (m *mockProjectStore) UpdateProjectLastAccessed(ctx context.Context, projec
 }
 
 func (m *mockProjectStore) GetRunningProjects(ctx context.Context) ([]db.Project, error) {
-	return nil, nil
+	var result []db.Project
+	for _, p := range m.projects {
+		if p.Status == db.StatusRunning {
+			result = append(result, *p)
+		}
+	}
+	return result, nil
 }
 
 func (m *mockProjectStore) GetUserSettings(ctx context.Context, userID string) (*db.UserSettings, error) {
@@ -166,10 +261,144 @@ func (m *mockProjectStore) UpdateProjectVolume(ctx context.Context, projectID, v
 	return nil
 }
 
+func (m *mockProjectStore) RecordProjectEvent(ctx context.Context, projectID, eventType string, message *string, data map[string]any) error {
+	return nil
+}
+
+func (m *mockProjectStore) BeginOperation(ctx context.Context, projectID, userID, opType string, from []string, to string) (*db.Operation, error) {
+	return m.BeginOperationWithParams(ctx, projectID, userID, opType, nil, from, to)
+}
+
+func (m *mockProjectStore) BeginOperationWithParams(ctx context.Context, projectID, userID, opType string, params map[string]string, from []string, to string) (*db.Operation, error) {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	for _, op := range m.operations {
+		if op.ProjectID == projectID && (op.Status == db.OperationPending || op.Status == db.OperationRunning) {
+			return nil, db.ErrOperationInProgress
+		}
+	}
+	if err := m.transition(projectID, from, to, nil); err != nil {
+		return nil, err
+	}
+	op := m.addOperation(projectID, userID, opType)
+	for k, v := range params {
+		m.operations[op.ID].Params[k] = v
+		op.Params[k] = v
+	}
+	return op, nil
+}
+
+// addOperation records a pending operation without touching project status.
+// Callers must hold opsMu.
+func (m *mockProjectStore) addOperation(projectID, userID, opType string) *db.Operation {
+	op := &db.Operation{
+		ID:        "660e8400-e29b-41d4-a716-44665544000" + string(rune('0'+len(m.operations))),
+		ProjectID: projectID,
+		UserID:    userID,
+		Type:      opType,
+		Status:    db.OperationPending,
+		Step:      "queued",
+		Params:    map[string]string{},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	m.operations[op.ID] = op
+	return copyOperation(op)
+}
+
+// copyOperation copies an operation so callers can't reach into the mock's
+func copyOperation(op *db.Operation) *db.Operation {
+	copied := *op
+	copied.Params = make(map[string]string, len(op.Params))
+	for k, v := range op.Params {
+		copied.Params[k] = v
+	}
+	return &copied
+}
+
+// seedOperation adds a pending operation for tests
+func (m *mockProjectStore) seedOperation(projectID, opType string) *db.Operation {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	return m.addOperation(projectID, "test-user-id", opType)
+}
+
+func (m *mockProjectStore) GetOperation(ctx context.Context, operationID, projectID string) (*db.Operation, error) {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	if op, ok := m.operations[operationID]; ok && op.ProjectID == projectID {
+		return copyOperation(op), nil
+	}
+	return nil, db.ErrNotFound
+}
+
+func (m *mockProjectStore) ClaimOperation(ctx context.Context, operationID, workerID string) (*db.Operation, error) {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	op, ok := m.operations[operationID]
+	if !ok || op.Status != db.OperationPending {
+		return nil, db.ErrNotFound
+	}
+	op.Status = db.OperationRunning
+	op.ClaimedBy = &workerID
+	op.Attempts++
+	return copyOperation(op), nil
+}
+
+func (m *mockProjectStore) ClaimNextOperation(ctx context.Context, workerID string, staleAfter time.Duration) (*db.Operation, error) {
+	return nil, db.ErrNotFound
+}
+
+func (m *mockProjectStore) UpdateOperationStep(ctx context.Context, operationID, workerID, step string) error {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	if op, ok := m.operations[operationID]; ok {
+		op.Step = step
+	}
+	return nil
+}
+
+func (m *mockProjectStore) SetOperationParams(ctx context.Context, operationID, workerID string, params map[string]string) error {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	if op, ok := m.operations[operationID]; ok {
+		for k, v := range params {
+			op.Params[k] = v
+		}
+	}
+	return nil
+}
+
+func (m *mockProjectStore) CompleteOperation(ctx context.Context, operationID, workerID string) error {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	if op, ok := m.operations[operationID]; ok {
+		op.Status = db.OperationSucceeded
+	}
+	return nil
+}
+
+func (m *mockProjectStore) FailOperation(ctx context.Context, operationID, workerID, errorMsg string) error {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	if op, ok := m.operations[operationID]; ok {
+		op.Status = db.OperationFailed
+		op.LastError = &errorMsg
+	}
+	return nil
+}
+
 type mockVolumeManager struct {
 	createFn func(name string, sizeGB int, region string) (*Volume, error)
 	getFn    func(volumeID string) (*Volume, error)
 	deleteFn func(volumeID string) error
+	forkFn   func(sourceVolumeID, name string) (*Volume, error)
+	copyFn   func(sourceVolumeID, name, region string) (*Volume, error)
+	extendFn func(volumeID string, sizeGB int) error
+
+	createSnapshotFn  func(volumeID string) (*Snapshot, error)
+	deleteSnapshotFn  func(snapshotID string) error
+	restoreSnapshotFn func(volumeID, snapshotID, name string) (*Volume, error)
 }
 
 func newMockVolumeManager() *mockVolumeManager {
@@ -197,6 +426,41 @@ func (m *mockVolumeManager) DeleteVolume(volumeID string) error {
 	return nil
 }
 
+func (m *mockVolumeManager) ExtendVolume(volumeID string, sizeGB int) error {
+	if m.extendFn != nil {
+		return m.extendFn(volumeID, sizeGB)
+	}
+	return nil
+}
+
+func (m *mockVolumeManager) ForkVolume(sourceVolumeID, name string) (*Volume, error) {
+	if m.forkFn != nil {
+		return m.forkFn(sourceVolumeID, name)
+	}
+	return &Volume{ID: "vol-fork", Name: name, State: "created"}, nil
+}
+
+func (m *mockVolumeManager) CreateSnapshot(volumeID string) (*Snapshot, error) {
+	if m.createSnapshotFn != nil {
+		return m.createSnapshotFn(volumeID)
+	}
+	return &Snapshot{ID: "snap-123", VolumeID: volumeID, SizeBytes: 1024}, nil
+}
+
+func (m *mockVolumeManager) DeleteSnapshot(snapshotID string) error {
+	if m.deleteSnapshotFn != nil {
+		return m.deleteSnapshotFn(snapshotID)
+	}
+	return nil
+}
+
+func (m *mockVolumeManager) RestoreSnapshot(volumeID, snapshotID, name string) (*Volume, error) {
+	if m.restoreSnapshotFn != nil {
+		return m.restoreSnapshotFn(volumeID, snapshotID, name)
+	}
+	return &Volume{ID: volumeID, Name: name, State: "created"}, nil
+}
+
 type mockMachineManager struct {
 	createFn    func(name string, config MachineConfig) (*Machine, error)
 	getFn       func(machineID string) (*Machine, error)
@@ -204,6 +468,8 @@ type mockMachineManager struct {
 	stopFn      func(machineID string) error
 	deleteFn    func(machineID string) error
 	waitStateFn func(machineID string, state string, timeout time.Duration) error
+	updateFn    func(machineID string, config MachineConfig) error
+	execFn      func(machineID string, command []string, timeout time.Duration) (*ExecResult, error)
 }
 
 func newMockMachineManager() *mockMachineManager {
@@ -224,6 +490,13 @@ func (m *mockMachineManager) GetMachine(machineID string) (*Machine, error) {
 	return &Machine{ID: machineID, State: "stopped"}, nil
 }
 
+func (m *mockMachineManager) UpdateMachine(machineID string, config MachineConfig) error {
+	if m.updateFn != nil {
+		return m.updateFn(machineID, config)
+	}
+	return nil
+}
+
 func (m *mockMachineManager) StartMachine(machineID string) error {
 	if m.startFn != nil {
 		return m.startFn(machineID)
@@ -252,6 +525,13 @@ func (m *mockMachineManager) WaitForState(machineID string, state string, timeou
 	return nil
 }
 
+func (m *mockMachineManager) Exec(machineID string, command []string, timeout time.Duration) (*ExecResult, error) {
+	if m.execFn != nil {
+		return m.execFn(machineID, command, timeout)
+	}
+	return &ExecResult{}, nil
+}
+
 // Helper to create request with user context
 func newAuthenticatedRequest(method, path string, body []byte) *http.Request {
 	var req *http.Request
@@ -266,6 +546,40 @@ func newAuthenticatedRequest(method, path string, body []byte) *http.Request {
 	return req.WithContext(ctx)
 }
 
+// testProjectID is the project seeded by newProjectFixture
+const testProjectID = "550e8400-e29b-41d4-a716-446655440000"
+
+// newProjectFixture returns a store holding one shared-CPU project owned by the
+// test user, in the given status, with a machine and volume attached
+func newProjectFixture(status string) *mockProjectStore {
+	store := newMockStore()
+	store.projects[testProjectID] = &db.Project{
+		ID:           testProjectID,
+		UserID:       "test-user-id",
+		Name:         "my-project",
+		Status:       status,
+		CPUKind:      "shared",
+		CPUs:         1,
+		MemoryMB:     1024,
+		VolumeSizeGB: 5,
+		FlyMachineID: strPtr("machine-123"),
+		FlyVolumeID:  strPtr("vol-123"),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	return store
+}
+
+// serveRoute mounts handler at pattern and sends it an authenticated request for path
+func serveRoute(handler http.HandlerFunc, method, pattern, path string, body []byte) *httptest.ResponseRecorder {
+	router := chi.NewRouter()
+	router.Method(method, pattern, handler)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, newAuthenticatedRequest(method, path, body))
+	return rr
+}
+
 // Tests
 
 func TestProjectHandler_List(t *testing.T) {
@@ -295,7 +609,7 @@ func TestProjectHandler_List(t *testing.T) {
 		UpdatedAt:    time.Now(),
 	}
 
-	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, "test-image", "sjc", 10*time.Minute)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
 
 	req := newAuthenticatedRequest("GET", "/projects", nil)
 	rr := httptest.NewRecorder()
@@ -318,7 +632,7 @@ func TestProjectHandler_List(t *testing.T) {
 
 func TestProjectHandler_Create_Valid(t *testing.T) {
 	store := newMockStore()
-	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, "test-image", "sjc", 10*time.Minute)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
 
 	body := []byte(`{"name": "my-project", "description": "A test project"}`)
 	req := newAuthenticatedRequest("POST", "/projects", body)
@@ -358,7 +672,7 @@ func TestProjectHandler_Create_InvalidName(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			store := newMockStore()
-			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, "test-image", "sjc", 10*time.Minute)
+			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
 
 			req := newAuthenticatedRequest("POST", "/projects", []byte(tt.body))
 			rr := httptest.NewRecorder()
@@ -388,7 +702,7 @@ func TestProjectHandler_Get_Found(t *testing.T) {
 		UpdatedAt:    time.Now(),
 	}
 
-	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, "test-image", "sjc", 10*time.Minute)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
 
 	req := newAuthenticatedRequest("GET", "/projects/"+projectID, nil)
 	rr := httptest.NewRecorder()
@@ -405,7 +719,7 @@ func TestProjectHandler_Get_Found(t *testing.T) {
 
 func TestProjectHandler_Get_NotFound(t *testing.T) {
 	store := newMockStore()
-	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, "test-image", "sjc", 10*time.Minute)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
 
 	req := newAuthenticatedRequest("GET", "/projects/550e8400-e29b-41d4-a716-446655440000", nil)
 	rr := httptest.NewRecorder()
@@ -421,7 +735,7 @@ func TestProjectHandler_Get_NotFound(t *testing.T) {
 
 func TestProjectHandler_Get_InvalidUUID(t *testing.T) {
 	store := newMockStore()
-	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, "test-image", "sjc", 10*time.Minute)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
 
 	req := newAuthenticatedRequest("GET", "/projects/not-a-uuid", nil)
 	rr := httptest.NewRecorder()
@@ -451,7 +765,7 @@ func TestProjectHandler_Update(t *testing.T) {
 		UpdatedAt:    time.Now(),
 	}
 
-	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, "test-image", "sjc", 10*time.Minute)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
 
 	body := []byte(`{"name": "new-name"}`)
 	req := newAuthenticatedRequest("PATCH", "/projects/"+projectID, body)
@@ -475,6 +789,42 @@ func TestProjectHandler_Update(t *testing.T) {
 	}
 }
 
+func TestProjectHandler_Update_WakeOnRequest(t *testing.T) {
+	tenMinutes, never := 10, 0
+	tests := []struct {
+		name        string
+		idleTimeout *int
+		body        string
+		wantStatus  int
+		wantWake    bool
+	}{
+		{name: "enable with idle timeout", idleTimeout: &tenMinutes, body: `{"wake_on_request": true}`, wantStatus: http.StatusOK, wantWake: true},
+		{name: "enable without idle timeout", body: `{"wake_on_request": true}`, wantStatus: http.StatusBadRequest},
+		{name: "enable with auto-stop off", idleTimeout: &never, body: `{"wake_on_request": true}`, wantStatus: http.StatusBadRequest},
+		{name: "disable without idle timeout", body: `{"wake_on_request": false}`, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newProjectFixture(db.StatusStopped)
+			store.projects[testProjectID].IdleTimeoutMinutes = tt.idleTimeout
+			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+			router := chi.NewRouter()
+			router.Patch("/projects/{id}", handler.Update)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, newAuthenticatedRequest("PATCH", "/projects/"+testProjectID, []byte(tt.body)))
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if wake := store.projects[testProjectID].WakeOnRequest; wake != tt.wantWake {
+				t.Errorf("expected wake on request %v, got %v", tt.wantWake, wake)
+			}
+		})
+	}
+}
+
 func TestProjectHandler_Delete(t *testing.T) {
 	store := newMockStore()
 	projectID := "550e8400-e29b-41d4-a716-446655440000"
@@ -491,7 +841,7 @@ func TestProjectHandler_Delete(t *testing.T) {
 		UpdatedAt:    time.Now(),
 	}
 
-	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, "test-image", "sjc", 10*time.Minute)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
 
 	req := newAuthenticatedRequest("DELETE", "/projects/"+projectID, nil)
 	rr := httptest.NewRecorder()
@@ -500,12 +850,16 @@ func TestProjectHandler_Delete(t *testing.T) {
 	router.Delete("/projects/{id}", handler.Delete)
 	router.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusNoContent {
-		t.Errorf("expected status %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
 	}
 
-	if _, ok := store.projects[projectID]; ok {
-		t.Error("project should have been deleted")
+	p, ok := store.projects[projectID]
+	if !ok {
+		t.Fatal("project should have been kept in the trash")
+	}
+	if p.Status != db.StatusTrashed || p.DeletedAt == nil {
+		t.Errorf("expected project trashed, got %s (deleted_at %v)", p.Status, p.DeletedAt)
 	}
 }
 
@@ -527,7 +881,7 @@ func TestProjectHandler_Start(t *testing.T) {
 
 	machines := newMockMachineManager()
 	volumes := newMockVolumeManager()
-	handler := NewProjectHandler(store, machines, volumes, nil, "test-image", "sjc", 10*time.Minute)
+	handler := NewProjectHandler(store, machines, volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
 
 	req := newAuthenticatedRequest("POST", "/projects/"+projectID+"/start", nil)
 	rr := httptest.NewRecorder()
@@ -536,8 +890,8 @@ func TestProjectHandler_Start(t *testing.T) {
 	router.Post("/projects/{id}/start", handler.Start)
 	router.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusOK {
-		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
 	}
 
 	var response StartResponse
@@ -545,8 +899,12 @@ func TestProjectHandler_Start(t *testing.T) {
 		t.Fatalf("failed to decode response: %v", err)
 	}
 
-	if response.Status != "running" {
-		t.Errorf("expected status 'running', got %s", response.Status)
+	if response.Status != "starting" {
+		t.Errorf("expected status 'starting', got %s", response.Status)
+	}
+
+	if response.OperationID == "" {
+		t.Error("expected operation_id in response")
 	}
 }
 
@@ -570,7 +928,7 @@ func TestProjectHandler_Stop(t *testing.T) {
 
 	machines := newMockMachineManager()
 	volumes := newMockVolumeManager()
-	handler := NewProjectHandler(store, machines, volumes, nil, "test-image", "sjc", 10*time.Minute)
+	handler := NewProjectHandler(store, machines, volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
 
 	req := newAuthenticatedRequest("POST", "/projects/"+projectID+"/stop", nil)
 	rr := httptest.NewRecorder()
@@ -579,8 +937,8 @@ func TestProjectHandler_Stop(t *testing.T) {
 	router.Post("/projects/{id}/stop", handler.Stop)
 	router.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusOK {
-		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
 	}
 
 	var response StopResponse
@@ -588,8 +946,12 @@ func TestProjectHandler_Stop(t *testing.T) {
 		t.Fatalf("failed to decode response: %v", err)
 	}
 
-	if response.Status != "stopped" {
-		t.Errorf("expected status 'stopped', got %s", response.Status)
+	if response.Status != "stopping" {
+		t.Errorf("expected status 'stopping', got %s", response.Status)
+	}
+
+	if response.OperationID == "" {
+		t.Error("expected operation_id in response")
 	}
 }
 
@@ -609,7 +971,7 @@ func TestProjectHandler_Stop_NoMachine(t *testing.T) {
 		UpdatedAt:    time.Now(),
 	}
 
-	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, "test-image", "sjc", 10*time.Minute)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
 
 	req := newAuthenticatedRequest("POST", "/projects/"+projectID+"/stop", nil)
 	rr := httptest.NewRecorder()
@@ -622,3 +984,143 @@ func TestProjectHandler_Stop_NoMachine(t *testing.T) {
 		t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
 	}
 }
+
+func TestProjectHandler_Start_OperationInProgress(t *testing.T) {
+	store := newMockStore()
+	projectID := "550e8400-e29b-41d4-a716-446655440000"
+	store.projects[projectID] = &db.Project{
+		ID:           projectID,
+		UserID:       "test-user-id",
+		Name:         "My Project",
+		Status:       "stopping",
+		CPUKind:      "shared",
+		CPUs:         1,
+		MemoryMB:     1024,
+		VolumeSizeGB: 5,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	store.seedOperation(projectID, db.OperationStop)
+
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	req := newAuthenticatedRequest("POST", "/projects/"+projectID+"/start", nil)
+	rr := httptest.NewRecorder()
+
+	router := chi.NewRouter()
+	router.Post("/projects/{id}/start", handler.Start)
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+}
+
+func TestProjectHandler_GetOperation(t *testing.T) {
+	store := newMockStore()
+	projectID := "550e8400-e29b-41d4-a716-446655440000"
+	store.projects[projectID] = &db.Project{
+		ID:           projectID,
+		UserID:       "test-user-id",
+		Name:         "My Project",
+		Status:       "starting",
+		CPUKind:      "shared",
+		CPUs:         1,
+		MemoryMB:     1024,
+		VolumeSizeGB: 5,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	op := store.seedOperation(projectID, db.OperationStart)
+
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	router := chi.NewRouter()
+	router.Get("/projects/{id}/operations/{opId}", handler.GetOperation)
+
+	req := newAuthenticatedRequest("GET", "/projects/"+projectID+"/operations/"+op.ID, nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response OperationResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.ID != op.ID || response.Type != db.OperationStart {
+		t.Errorf("unexpected operation: %+v", response)
+	}
+
+	// Unknown operation IDs are not found
+	req = newAuthenticatedRequest("GET", "/projects/"+projectID+"/operations/770e8400-e29b-41d4-a716-446655440000", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d: %s", http.StatusNotFound, rr.Code, rr.Body.String())
+	}
+}
+
+func TestProjectHandler_LifecycleConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		method  string
+		path    string
+		handler func(h *ProjectHandler) http.HandlerFunc
+	}{
+		{name: "start while running", status: "running", method: "POST", path: "/start", handler: func(h *ProjectHandler) http.HandlerFunc { return h.Start }},
+		{name: "stop while starting", status: "starting", method: "POST", path: "/stop", handler: func(h *ProjectHandler) http.HandlerFunc { return h.Stop }},
+		{name: "delete while starting", status: "starting", method: "DELETE", path: "", handler: func(h *ProjectHandler) http.HandlerFunc { return h.Delete }},
+		{name: "fork while stopping", status: "stopping", method: "POST", path: "/fork", handler: func(h *ProjectHandler) http.HandlerFunc { return h.Fork }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMockStore()
+			projectID := "550e8400-e29b-41d4-a716-446655440000"
+			machineID := "machine-123"
+			store.projects[projectID] = &db.Project{
+				ID:           projectID,
+				UserID:       "test-user-id",
+				Name:         "My Project",
+				Status:       tt.status,
+				FlyMachineID: &machineID,
+				CreatedAt:    time.Now(),
+				UpdatedAt:    time.Now(),
+			}
+
+			machines := newMockMachineManager()
+			machines.deleteFn = func(machineID string) error {
+				t.Error("machine deleted despite conflict")
+				return nil
+			}
+			handler := NewProjectHandler(store, machines, newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+			router := chi.NewRouter()
+			router.MethodFunc(tt.method, "/projects/{id}"+tt.path, tt.handler(handler))
+
+			req := newAuthenticatedRequest(tt.method, "/projects/"+projectID+tt.path, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusConflict {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+			}
+
+			var response map[string]string
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if response["current_status"] != tt.status {
+				t.Errorf("expected current_status %q, got %q", tt.status, response["current_status"])
+			}
+			if store.projects[projectID].Status != tt.status {
+				t.Errorf("status changed to %s", store.projects[projectID].Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/quota"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// QuotaErrorResponse is returned with 403 when a request needs a bigger plan,
+// and with 429 when it needs the user to stop a running machine first
+type QuotaErrorResponse struct {
+	Error     string `json:"error"`
+	Quota     string `json:"quota"`
+	Plan      string `json:"plan"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Requested int    `json:"requested"`
+}
+
+// QuotaLimits are a plan's limits; nil is unlimited
+type QuotaLimits struct {
+	Projects        *int `json:"projects"`
+	VolumeGB        *int `json:"volume_gb"`
+	GPU             bool `json:"gpu"`
+	RunningMachines *int `json:"running_machines"`
+	VCPUs           *int `json:"vcpus"`
+	MemoryMB        *int `json:"memory_mb"`
+}
+
+type QuotaPlan struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// QuotaResponse is a user's or organization's usage against its plan's limits. Plan is nil when
+// no plan applies and nothing is limited.
+type QuotaResponse struct {
+	Plan   *QuotaPlan  `json:"plan"`
+	Limits QuotaLimits `json:"limits"`
+	Usage  quota.Usage `json:"usage"`
+}
+
+type PlanListResponse struct {
+	Plans []db.Plan `json:"plans"`
+}
+
+type SetPlanRequest struct {
+	PlanID string `json:"plan_id"`
+}
+
+func writeQuotaError(w http.ResponseWriter, exceeded *quota.Exceeded) {
+	status := http.StatusForbidden
+	if exceeded.Temporary() {
+		status = http.StatusTooManyRequests
+	}
+	WriteJSON(w, status, QuotaErrorResponse{
+		Error:     exceeded.Error(),
+		Quota:     exceeded.Quota,
+		Plan:      exceeded.Plan,
+		Limit:     exceeded.Limit,
+		Used:      exceeded.Used,
+		Requested: exceeded.Requested,
+	})
+}
+
+// QuotaHandler shows users and organizations their plan usage and lets admins
+// assign plans
+type QuotaHandler struct {
+	store QuotaStore
+}
+
+func NewQuotaHandler(store QuotaStore) *QuotaHandler {
+	return &QuotaHandler{store: store}
+}
+
+// Get returns the user's usage of their personal projects against their plan
+func (h *QuotaHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	response, err := h.quotaResponse(ctx, userID, nil)
+	if err != nil {
+		log.Error("failed to get quota", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get quota")
+		return
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// GetOrganization returns an organization's usage against its plan. Any member
+// can see it.
+func (h *QuotaHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+	orgID := routeUUID(w, r, "orgId")
+	if orgID == "" {
+		return
+	}
+
+	if _, err := h.store.GetOrganization(ctx, orgID, authmw.GetUserID(ctx)); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Organization not found")
+			return
+		}
+		log.Error("failed to get organization", "organization_id", orgID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get quota")
+		return
+	}
+
+	response, err := h.quotaResponse(ctx, "", &orgID)
+	if err != nil {
+		log.Error("failed to get quota", "organization_id", orgID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get quota")
+		return
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *QuotaHandler) quotaResponse(ctx context.Context, userID string, orgID *string) (*QuotaResponse, error) {
+	plan, err := quota.AccountPlan(ctx, h.store, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := quota.AccountUsage(ctx, h.store, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &QuotaResponse{Limits: QuotaLimits{GPU: true}, Usage: quota.UsageOf(usage)}
+	if plan != nil {
+		response.Plan = &QuotaPlan{ID: plan.ID, Name: plan.Name}
+		response.Limits = QuotaLimits{
+			Projects:        plan.MaxProjects,
+			VolumeGB:        plan.MaxVolumeGB,
+			GPU:             plan.AllowGPU,
+			RunningMachines: plan.MaxRunningMachines,
+			VCPUs:           plan.MaxVCPUs,
+			MemoryMB:        plan.MaxMemoryMB,
+		}
+	}
+	return response, nil
+}
+
+// ListPlans returns every plan
+func (h *QuotaHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	plans, err := h.store.ListPlans(ctx)
+	if err != nil {
+		log.Error("failed to list plans", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list plans")
+		return
+	}
+	if plans == nil {
+		plans = []db.Plan{}
+	}
+	WriteJSON(w, http.StatusOK, PlanListResponse{Plans: plans})
+}
+
+// SetUserPlan moves a user onto a plan and returns their usage against it. What
+// they already have is kept even if the new plan is smaller.
+func (h *QuotaHandler) SetUserPlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := chi.URLParam(r, "userId")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(userID, "userId"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+	planID, ok := decodePlanID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.SetUserPlan(ctx, userID, planID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "User or plan not found")
+			return
+		}
+		log.Error("failed to set user plan", "user_id", userID, "plan_id", planID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to set plan")
+		return
+	}
+	log.Info("user plan changed", "user_id", userID, "plan_id", planID, "by", authmw.GetUserID(ctx))
+
+	response, err := h.quotaResponse(ctx, userID, nil)
+	if err != nil {
+		log.Error("failed to get quota", "user_id", userID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get quota")
+		return
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// SetOrganizationPlan moves an organization onto a plan and returns its usage
+// against it. Its members' personal projects stay on their own plans.
+func (h *QuotaHandler) SetOrganizationPlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+	orgID := routeUUID(w, r, "orgId")
+	if orgID == "" {
+		return
+	}
+	planID, ok := decodePlanID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.SetOrganizationPlan(ctx, orgID, planID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Organization or plan not found")
+			return
+		}
+		log.Error("failed to set organization plan", "organization_id", orgID, "plan_id", planID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to set plan")
+		return
+	}
+	log.Info("organization plan changed", "organization_id", orgID, "plan_id", planID, "by", authmw.GetUserID(ctx))
+
+	response, err := h.quotaResponse(ctx, "", &orgID)
+	if err != nil {
+		log.Error("failed to get quota", "organization_id", orgID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get quota")
+		return
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// decodePlanID reads the plan to assign from the request body, writing a 400
+// if there isn't one
+func decodePlanID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req SetPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return "", false
+	}
+	req.PlanID = strings.TrimSpace(req.PlanID)
+	if req.PlanID == "" {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{{Field: "plan_id", Message: "plan_id is required"}},
+		})
+		return "", false
+	}
+	return req.PlanID, true
+}
@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+	"aether/apps/api/quota"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (m *mockProjectStore) ListPlans(ctx context.Context) ([]db.Plan, error) {
+	var plans []db.Plan
+	for _, p := range m.plans {
+		plans = append(plans, *p)
+	}
+	return plans, nil
+}
+
+func (m *mockProjectStore) GetUserPlan(ctx context.Context, userID string) (*db.Plan, error) {
+	if p, ok := m.plans[m.userPlans[userID]]; ok {
+		return p, nil
+	}
+	return nil, db.ErrNotFound
+}
+
+func (m *mockProjectStore) SetUserPlan(ctx context.Context, userID, planID string) error {
+	if _, ok := m.plans[planID]; !ok {
+		return db.ErrNotFound
+	}
+	if m.userPlans == nil {
+		m.userPlans = make(map[string]string)
+	}
+	m.userPlans[userID] = planID
+	return nil
+}
+
+func (m *mockProjectStore) GetQuotaUsage(ctx context.Context, userID string) (*db.QuotaUsage, error) {
+	return m.quotaUsage(func(p *db.Project) bool { return p.UserID == userID && p.OrganizationID == nil }), nil
+}
+
+func (m *mockProjectStore) GetOrganizationPlan(ctx context.Context, orgID string) (*db.Plan, error) {
+	if p, ok := m.plans[m.orgPlans[orgID]]; ok {
+		return p, nil
+	}
+	return nil, db.ErrNotFound
+}
+
+func (m *mockProjectStore) SetOrganizationPlan(ctx context.Context, orgID, planID string) error {
+	if _, ok := m.plans[planID]; !ok || m.orgs[orgID] == nil {
+		return db.ErrNotFound
+	}
+	if m.orgPlans == nil {
+		m.orgPlans = make(map[string]string)
+	}
+	m.orgPlans[orgID] = planID
+	return nil
+}
+
+func (m *mockProjectStore) GetOrganizationQuotaUsage(ctx context.Context, orgID string) (*db.QuotaUsage, error) {
+	return m.quotaUsage(func(p *db.Project) bool { return p.OrganizationID != nil && *p.OrganizationID == orgID }), nil
+}
+
+func (m *mockProjectStore) LockQuota(ctx context.Context, userID string, orgID *string) (func(), error) {
+	key := "user:" + userID
+	if orgID != nil {
+		key = "org:" + *orgID
+	}
+	if m.quotaLocked[key] {
+		return nil, fmt.Errorf("quota for %s locked twice", key)
+	}
+	if m.quotaLocked == nil {
+		m.quotaLocked = make(map[string]bool)
+	}
+	m.quotaLocked[key] = true
+	return func() { delete(m.quotaLocked, key) }, nil
+}
+
+func (m *mockProjectStore) quotaUsage(counts func(p *db.Project) bool) *db.QuotaUsage {
+	var u db.QuotaUsage
+	for _, p := range m.projects {
+		if !counts(p) {
+			continue
+		}
+		u.Projects++
+		if p.Status != db.StatusHibernated {
+			u.VolumeGB += p.VolumeSizeGB
+		}
+		switch p.Status {
+		case db.StatusStarting, db.StatusRunning, db.StatusStopping:
+			u.RunningMachines++
+			if p.GPUKind != nil {
+				u.RunningGPUMachines++
+			} else {
+				u.RunningCPUs += p.CPUs
+				u.RunningMemoryMB += p.MemoryMB
+			}
+		}
+	}
+	return &u
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// withPlan puts test-user-id on a plan like the seeded free one
+func withPlan(store *mockProjectStore) *db.Plan {
+	plan := &db.Plan{
+		ID:                 "free",
+		Name:               "Free",
+		MaxProjects:        intPtr(2),
+		MaxVolumeGB:        intPtr(20),
+		MaxRunningMachines: intPtr(1),
+		MaxVCPUs:           intPtr(2),
+		MaxMemoryMB:        intPtr(4096),
+	}
+	store.plans = map[string]*db.Plan{plan.ID: plan}
+	store.userPlans = map[string]string{"test-user-id": plan.ID}
+	return plan
+}
+
+func decodeQuotaError(t *testing.T, rr *httptest.ResponseRecorder) QuotaErrorResponse {
+	t.Helper()
+	var response QuotaErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return response
+}
+
+func TestProjectHandler_Create_Quota(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		code  int
+		quota string
+	}{
+		{"fits", `{"name":"small","hardware":{"cpu_kind":"shared","cpus":1,"memory_mb":1024,"volume_size_gb":5}}`, http.StatusCreated, ""},
+		{"volume over plan", `{"name":"big","hardware":{"cpu_kind":"shared","cpus":1,"memory_mb":1024,"volume_size_gb":16}}`, http.StatusForbidden, quota.VolumeGB},
+		{"gpu not in plan", `{"name":"gpu","hardware":{"volume_size_gb":5,"gpu_kind":"a10"}}`, http.StatusForbidden, quota.GPU},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newProjectFixture(db.StatusStopped)
+			withPlan(store)
+			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+			req := newAuthenticatedRequest("POST", "/projects", []byte(tt.body))
+			rr := httptest.NewRecorder()
+			handler.Create(rr, req)
+			if rr.Code != tt.code {
+				t.Fatalf("expected status %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.quota == "" {
+				return
+			}
+			if response := decodeQuotaError(t, rr); response.Quota != tt.quota || response.Plan != "free" || response.Error == "" {
+				t.Errorf("expected %s quota error on free, got %+v", tt.quota, response)
+			}
+		})
+	}
+
+	// The fixture project and one more fill the plan
+	store := newProjectFixture(db.StatusStopped)
+	withPlan(store)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+	for i, want := range []int{http.StatusCreated, http.StatusForbidden} {
+		req := newAuthenticatedRequest("POST", "/projects", []byte(`{"name":"p","hardware":{"cpu_kind":"shared","cpus":1,"memory_mb":1024,"volume_size_gb":1}}`))
+		rr := httptest.NewRecorder()
+		handler.Create(rr, req)
+		if rr.Code != want {
+			t.Fatalf("create %d: expected status %d, got %d: %s", i, want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestProjectHandler_Start_Quota(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	withPlan(store)
+	store.projects["other-project"] = &db.Project{ID: "other-project", UserID: "test-user-id", Status: db.StatusRunning, CPUs: 1, MemoryMB: 1024}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	router := chi.NewRouter()
+	router.Post("/projects/{id}/start", handler.Start)
+	start := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newAuthenticatedRequest("POST", "/projects/"+testProjectID+"/start", nil))
+		return rr
+	}
+
+	rr := start()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusTooManyRequests, rr.Code, rr.Body.String())
+	}
+	if response := decodeQuotaError(t, rr); response.Quota != quota.RunningMachines || response.Limit != 1 || response.Used != 1 {
+		t.Errorf("expected running machines quota error, got %+v", response)
+	}
+	if p := store.projects[testProjectID]; p.Status != db.StatusStopped {
+		t.Errorf("expected project left stopped, got %s", p.Status)
+	}
+
+	// Stopping the other machine frees the slot
+	store.projects["other-project"].Status = db.StatusStopped
+	if rr := start(); rr.Code != http.StatusAccepted {
+		t.Errorf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+}
+
+func TestProjectHandler_UpdateHardware_Quota(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	withPlan(store)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	if rr := serveRoute(handler.UpdateHardware, "PATCH", "/projects/{id}/hardware", "/projects/"+testProjectID+"/hardware", []byte(`{"cpu_kind":"shared","cpus":1,"memory_mb":1024,"volume_size_gb":25}`)); rr.Code != http.StatusForbidden {
+		t.Errorf("expected status %d growing past the volume limit, got %d: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	// A stopped project's compute is only checked when it next starts
+	if rr := serveRoute(handler.UpdateHardware, "PATCH", "/projects/{id}/hardware", "/projects/"+testProjectID+"/hardware", []byte(`{"cpu_kind":"shared","cpus":4,"memory_mb":4096,"volume_size_gb":10}`)); rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func TestQuotaHandler(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	withPlan(store)
+	store.plans["pro"] = &db.Plan{ID: "pro", Name: "Pro", AllowGPU: true}
+	handler := NewQuotaHandler(store)
+
+	rr := httptest.NewRecorder()
+	handler.Get(rr, newAuthenticatedRequest("GET", "/user/quota", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response QuotaResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Plan == nil || response.Plan.ID != "free" || *response.Limits.Projects != 2 || response.Limits.GPU {
+		t.Errorf("expected free plan limits, got %+v", response)
+	}
+	p := store.projects[testProjectID]
+	if response.Usage.Projects != 1 || response.Usage.RunningMachines != 1 || response.Usage.VCPUs != p.CPUs || response.Usage.VolumeGB != p.VolumeSizeGB {
+		t.Errorf("expected the running fixture project counted, got %+v", response.Usage)
+	}
+
+	router := chi.NewRouter()
+	router.Put("/admin/users/{userId}/plan", handler.SetUserPlan)
+	userID := "3f2a1b4c-5d6e-4f70-8a9b-0c1d2e3f4a5b"
+	tests := []struct {
+		name string
+		path string
+		body string
+		code int
+	}{
+		{"assigns plan", "/admin/users/" + userID + "/plan", `{"plan_id":"pro"}`, http.StatusOK},
+		{"unknown plan", "/admin/users/" + userID + "/plan", `{"plan_id":"enterprise"}`, http.StatusNotFound},
+		{"missing plan", "/admin/users/" + userID + "/plan", `{}`, http.StatusBadRequest},
+		{"invalid user", "/admin/users/not-a-uuid/plan", `{"plan_id":"pro"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, newAuthenticatedRequest("PUT", tt.path, []byte(tt.body)))
+			if rr.Code != tt.code {
+				t.Errorf("expected status %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+		})
+	}
+	if store.userPlans[userID] != "pro" {
+		t.Errorf("expected user moved to pro, got %q", store.userPlans[userID])
+	}
+}
+
+func TestProjectHandler_Start_OrganizationQuota(t *testing.T) {
+	store := newOrgFixture(db.RoleMember)
+	withPlan(store)
+	store.plans["team"] = &db.Plan{ID: "team", Name: "Team", MaxRunningMachines: intPtr(1)}
+	store.orgPlans = map[string]string{testOrgID: "team"}
+	orgID := testOrgID
+	store.projects["colleague-project"] = &db.Project{ID: "colleague-project", UserID: "owner-id", OrganizationID: &orgID, Status: db.StatusRunning, CPUs: 1, MemoryMB: 1024}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	router := chi.NewRouter()
+	router.Post("/projects/{id}/start", handler.Start)
+	start := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newAuthenticatedRequest("POST", "/projects/"+testProjectID+"/start", nil))
+		return rr
+	}
+
+	// The colleague's machine fills the organization's plan, not the owner's or the member's
+	rr := start()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusTooManyRequests, rr.Code, rr.Body.String())
+	}
+	if response := decodeQuotaError(t, rr); response.Plan != "team" || response.Quota != quota.RunningMachines {
+		t.Errorf("expected the team plan's running machines quota, got %+v", response)
+	}
+
+	// The member's personal machines don't count against the organization
+	store.projects["colleague-project"].Status = db.StatusStopped
+	store.projects["personal-project"] = &db.Project{ID: "personal-project", UserID: "test-user-id", Status: db.StatusRunning, CPUs: 1, MemoryMB: 1024}
+	if rr := start(); rr.Code != http.StatusAccepted {
+		t.Errorf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+}
+
+func TestProjectHandler_Transfer_Quota(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	withPlan(store)
+	store.plans["team"] = &db.Plan{ID: "team", Name: "Team", MaxProjects: intPtr(1)}
+	store.orgs[testOrgID] = &db.Organization{ID: testOrgID, Name: "Acme"}
+	store.members[testOrgID] = map[string]string{"owner-id": db.RoleOwner, "test-user-id": db.RoleAdmin}
+	store.orgPlans = map[string]string{testOrgID: "team"}
+	orgID := testOrgID
+	store.projects["org-project"] = &db.Project{ID: "org-project", UserID: "owner-id", OrganizationID: &orgID, Status: db.StatusStopped}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	router := chi.NewRouter()
+	router.Put("/projects/{id}/organization", handler.Transfer)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, newAuthenticatedRequest("PUT", "/projects/"+testProjectID+"/organization", []byte(`{"organization_id":"`+testOrgID+`"}`)))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+	if response := decodeQuotaError(t, rr); response.Plan != "team" || response.Quota != quota.Projects {
+		t.Errorf("expected the team plan's project quota, got %+v", response)
+	}
+	if p := store.projects[testProjectID]; p.OrganizationID != nil {
+		t.Errorf("expected the project left personal, got %v", *p.OrganizationID)
+	}
+}
+
+func TestQuotaHandler_Organization(t *testing.T) {
+	store := newOrgFixture(db.RoleViewer)
+	withPlan(store)
+	store.plans["team"] = &db.Plan{ID: "team", Name: "Team", MaxProjects: intPtr(10)}
+	handler := NewQuotaHandler(store)
+
+	router := chi.NewRouter()
+	router.Get("/organizations/{orgId}/quota", handler.GetOrganization)
+	router.Put("/admin/organizations/{orgId}/plan", handler.SetOrganizationPlan)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newAuthenticatedRequest(method, path, []byte(body)))
+		return rr
+	}
+
+	if rr := serve("PUT", "/admin/organizations/"+testOrgID+"/plan", `{"plan_id":"team"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := serve("PUT", "/admin/organizations/7c9e6679-7425-40de-944b-e07fc1f90ae7/plan", `{"plan_id":"team"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for an unknown organization, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	rr := serve("GET", "/organizations/"+testOrgID+"/quota", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response QuotaResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Plan == nil || response.Plan.ID != "team" || response.Usage.Projects != 1 {
+		t.Errorf("expected the organization's project on the team plan, got %+v", response)
+	}
+
+	// The organization's project isn't the member's own
+	rr = httptest.NewRecorder()
+	handler.Get(rr, newAuthenticatedRequest("GET", "/user/quota", nil))
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Plan == nil || response.Plan.ID != "free" || response.Usage.Projects != 0 {
+		t.Errorf("expected no personal projects on the free plan, got %+v", response)
+	}
+
+	delete(store.members[testOrgID], "test-user-id")
+	if rr := serve("GET", "/organizations/"+testOrgID+"/quota", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a non-member, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestProjectHandler_Create_HoldsQuotaUntilCreated(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	withPlan(store)
+	locked := false
+	store.createFn = func(ctx context.Context, userID, name string, description *string, baseImage string, hw *db.HardwareConfig, idleTimeoutMinutes *int, tmpl *db.Template, envVarsEncrypted *string, tags []string, labels map[string]string, region string, organizationID *string) (*db.Project, error) {
+		locked = store.quotaLocked["user:"+userID]
+		return &db.Project{ID: "new-project", UserID: userID, Name: name, Status: db.StatusStopped, CPUKind: "shared"}, nil
+	}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := httptest.NewRecorder()
+	handler.Create(rr, newAuthenticatedRequest("POST", "/projects", []byte(`{"name":"p","hardware":{"cpu_kind":"shared","cpus":1,"memory_mb":1024,"volume_size_gb":1}}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if !locked {
+		t.Error("expected the quota locked while the project was created")
+	}
+	if len(store.quotaLocked) != 0 {
+		t.Errorf("expected the quota released, got %v", store.quotaLocked)
+	}
+}
+
+func TestProjectHandler_Start_HibernatedNeedsVolumeQuota(t *testing.T) {
+	store := newProjectFixture(db.StatusHibernated)
+	withPlan(store)
+	store.projects["other-project"] = &db.Project{ID: "other-project", UserID: "test-user-id", Status: db.StatusStopped, VolumeSizeGB: 18}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	router := chi.NewRouter()
+	router.Post("/projects/{id}/start", handler.Start)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, newAuthenticatedRequest("POST", "/projects/"+testProjectID+"/start", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+	if response := decodeQuotaError(t, rr); response.Quota != quota.VolumeGB || response.Used != 18 || response.Requested != 5 {
+		t.Errorf("expected the recreated volume checked, got %+v", response)
+	}
+	if len(store.quotaLocked) != 0 {
+		t.Errorf("expected the quota released, got %v", store.quotaLocked)
+	}
+}
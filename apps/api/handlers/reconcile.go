@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aether/apps/api/db"
+	"aether/libs/go/logging"
+)
+
+// Orphan policies control what the reconciler does with machines and volumes
+// that exist in the compute backend but are not referenced by any project
+const (
+	OrphanPolicyReport = "report"
+	OrphanPolicyDelete = "delete"
+)
+
+// Resource names created by ProjectHandler. Only resources with these prefixes
+// are ever considered orphans, so unrelated machines in the same app are left alone.
+const (
+	machineNamePrefix = "aether-"
+	volumeNamePrefix  = "vol_"
+)
+
+// ReconcileConfig controls how the reconciler treats orphaned resources
+type ReconcileConfig struct {
+	OrphanPolicy string
+	// OrphanGracePeriod protects resources created by an in-flight start
+	// that has not yet recorded the resource ID on its project
+	OrphanGracePeriod time.Duration
+}
+
+type ReconcileHandler struct {
+	store     ReconcileStore
+	machines  MachineManager
+	volumes   VolumeManager
+	resources ResourceLister
+	config    ReconcileConfig
+}
+
+func NewReconcileHandler(store ReconcileStore, machines MachineManager, volumes VolumeManager, resources ResourceLister, config ReconcileConfig) *ReconcileHandler {
+	if config.OrphanPolicy != OrphanPolicyDelete {
+		config.OrphanPolicy = OrphanPolicyReport
+	}
+	return &ReconcileHandler{
+		store:     store,
+		machines:  machines,
+		volumes:   volumes,
+		resources: resources,
+		config:    config,
+	}
+}
+
+// ReconcileAction describes a correction to a project's database row
+type ReconcileAction struct {
+	ProjectID string `json:"project_id"`
+	Kind      string `json:"kind"` // status, clear_machine, clear_volume
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	Reason    string `json:"reason"`
+	Applied   bool   `json:"applied"`
+}
+
+// OrphanResource describes a backend resource that no project references
+type OrphanResource struct {
+	Kind      string `json:"kind"` // machine, volume
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	State     string `json:"state,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+	Action    string `json:"action"` // reported, within_grace_period, stopped, deleted
+	Error     string `json:"error,omitempty"`
+}
+
+type ReconcileReport struct {
+	DryRun       bool              `json:"dry_run"`
+	OrphanPolicy string            `json:"orphan_policy"`
+	StartedAt    time.Time         `json:"started_at"`
+	FinishedAt   time.Time         `json:"finished_at"`
+	Projects     int               `json:"projects_checked"`
+	Skipped      int               `json:"projects_skipped"`
+	Actions      []ReconcileAction `json:"actions"`
+	Orphans      []OrphanResource  `json:"orphans"`
+	Errors       []string          `json:"errors,omitempty"`
+}
+
+// Report runs the reconciler in dry-run mode and returns what it would change
+func (h *ReconcileHandler) Report(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	report, err := h.Reconcile(ctx, true)
+	if err != nil {
+		log.Error("failed to build reconcile report", "error", err)
+		WriteError(w, http.StatusBadGateway, "Failed to inventory compute backend")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, report)
+}
+
+// StartReconciler starts a background goroutine that periodically heals drift
+// between the database and the compute backend
+func (h *ReconcileHandler) StartReconciler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			log := logging.Default()
+			report, err := h.Reconcile(context.Background(), false)
+			if err != nil {
+				log.Error("reconcile failed", "error", err)
+				continue
+			}
+			if len(report.Actions) > 0 || len(report.Orphans) > 0 || len(report.Errors) > 0 {
+				log.Info("reconcile finished",
+					"actions", len(report.Actions),
+					"orphans", len(report.Orphans),
+					"errors", len(report.Errors),
+				)
+			}
+		}
+	}()
+}
+
+// Reconcile compares every project against the compute backend. With dryRun
+// set it only reports what it would do.
+func (h *ReconcileHandler) Reconcile(ctx context.Context, dryRun bool) (*ReconcileReport, error) {
+	report := &ReconcileReport{
+		DryRun:       dryRun,
+		OrphanPolicy: h.config.OrphanPolicy,
+		StartedAt:    time.Now(),
+		Actions:      []ReconcileAction{},
+		Orphans:      []OrphanResource{},
+	}
+
+	// A full inventory is required: without it we can't tell "gone" from "not listed"
+	machines, err := h.resources.ListMachines()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list machines: %w", err)
+	}
+	volumes, err := h.resources.ListVolumes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	projects, err := h.store.GetAllProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	busy, err := h.store.GetProjectIDsWithActiveOperations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	machinesByID := make(map[string]*Machine, len(machines))
+	for i := range machines {
+		machinesByID[machines[i].ID] = &machines[i]
+	}
+	volumesByID := make(map[string]*Volume, len(volumes))
+	for i := range volumes {
+		volumesByID[volumes[i].ID] = &volumes[i]
+	}
+
+	referencedMachines := make(map[string]bool)
+	referencedVolumes := make(map[string]bool)
+	for i := range projects {
+		p := &projects[i]
+		if p.FlyMachineID != nil && *p.FlyMachineID != "" {
+			referencedMachines[*p.FlyMachineID] = true
+		}
+		if p.FlyVolumeID != nil && *p.FlyVolumeID != "" {
+			referencedVolumes[*p.FlyVolumeID] = true
+		}
+
+		// Operations own their project's state until they finish, as does the
+		// delete handler while it tears resources down
+		if busy[p.ID] || p.Status == db.StatusDeleting {
+			report.Skipped++
+			continue
+		}
+		report.Projects++
+		h.reconcileProject(ctx, p, machinesByID, volumesByID, dryRun, report)
+	}
+
+	h.collectOrphans(machines, volumes, referencedMachines, referencedVolumes, dryRun, report)
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+func (h *ReconcileHandler) reconcileProject(ctx context.Context, p *db.Project, machinesByID map[string]*Machine, volumesByID map[string]*Volume, dryRun bool, report *ReconcileReport) {
+	log := logging.Default().With("project_id", p.ID)
+	status := p.Status
+
+	if p.FlyMachineID != nil && *p.FlyMachineID != "" {
+		machineID := *p.FlyMachineID
+		machine := h.findMachine(machineID, machinesByID)
+
+		if machine == nil || machineGone(machine.State) {
+			// The machine is gone; the next start creates a fresh one
+			applied := false
+			if !dryRun {
+				var err error
+				applied, err = h.store.ClearProjectMachine(ctx, p.ID, machineID)
+				if err != nil {
+					log.Error("failed to clear stale machine", "machine_id", machineID, "error", err)
+					report.Errors = append(report.Errors, err.Error())
+				}
+			}
+			report.Actions = append(report.Actions, ReconcileAction{
+				ProjectID: p.ID,
+				Kind:      "clear_machine",
+				From:      machineID,
+				Reason:    "machine no longer exists in compute backend",
+				Applied:   applied,
+			})
+			if status == "running" || status == "starting" || status == "stopping" {
+				h.correctStatus(ctx, p.ID, status, "stopped", "machine no longer exists", dryRun, report)
+			}
+		} else if actual, ok := projectStatusForMachine(machine.State); ok {
+			switch status {
+			case "running", "starting", "stopping", "stopped":
+				if status != actual {
+					h.correctStatus(ctx, p.ID, status, actual, "machine is "+machine.State, dryRun, report)
+				}
+			}
+		}
+	} else if status == "running" || status == "starting" || status == "stopping" {
+		h.correctStatus(ctx, p.ID, status, "stopped", "project has no machine", dryRun, report)
+	}
+
+	if p.FlyVolumeID != nil && *p.FlyVolumeID != "" {
+		volumeID := *p.FlyVolumeID
+		if !h.volumeExists(volumeID, volumesByID) {
+			applied := false
+			if !dryRun {
+				var err error
+				applied, err = h.store.ClearProjectVolume(ctx, p.ID, volumeID)
+				if err != nil {
+					log.Error("failed to clear stale volume", "volume_id", volumeID, "error", err)
+					report.Errors = append(report.Errors, err.Error())
+				}
+			}
+			report.Actions = append(report.Actions, ReconcileAction{
+				ProjectID: p.ID,
+				Kind:      "clear_volume",
+				From:      volumeID,
+				Reason:    "volume no longer exists in compute backend",
+				Applied:   applied,
+			})
+		}
+	}
+}
+
+func (h *ReconcileHandler) correctStatus(ctx context.Context, projectID, from, to, reason string, dryRun bool, report *ReconcileReport) {
+	applied := false
+	if !dryRun {
+		var err error
+		applied, err = h.store.ReconcileProjectStatus(ctx, projectID, from, to)
+		if err != nil {
+			logging.Default().Error("failed to correct project status", "project_id", projectID, "from", from, "to", to, "error", err)
+			report.Errors = append(report.Errors, err.Error())
+		} else if applied {
+			logging.Default().Info("corrected project status", "project_id", projectID, "from", from, "to", to, "reason", reason)
+		}
+	}
+	report.Actions = append(report.Actions, ReconcileAction{
+		ProjectID: projectID,
+		Kind:      "status",
+		From:      from,
+		To:        to,
+		Reason:    reason,
+		Applied:   applied,
+	})
+}
+
+// findMachine looks a machine up in the inventory, confirming with GetMachine
+// before concluding that it is gone
+func (h *ReconcileHandler) findMachine(machineID string, machinesByID map[string]*Machine) *Machine {
+	if m, ok := machinesByID[machineID]; ok {
+		return m
+	}
+	m, err := h.machines.GetMachine(machineID)
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+func (h *ReconcileHandler) volumeExists(volumeID string, volumesByID map[string]*Volume) bool {
+	if _, ok := volumesByID[volumeID]; ok {
+		return true
+	}
+	_, err := h.volumes.GetVolume(volumeID)
+	return err == nil
+}
+
+func (h *ReconcileHandler) collectOrphans(machines []Machine, volumes []Volume, referencedMachines, referencedVolumes map[string]bool, dryRun bool, report *ReconcileReport) {
+	log := logging.Default()
+	deleting := h.config.OrphanPolicy == OrphanPolicyDelete && !dryRun
+
+	for _, m := range machines {
+		if referencedMachines[m.ID] || !strings.HasPrefix(m.Name, machineNamePrefix) || machineGone(m.State) {
+			continue
+		}
+		orphan := OrphanResource{Kind: "machine", ID: m.ID, Name: m.Name, State: m.State, CreatedAt: m.CreatedAt, Action: "reported"}
+
+		if !h.pastGracePeriod(m.CreatedAt) {
+			orphan.Action = "within_grace_period"
+		} else if deleting {
+			// A started machine can't be deleted; stop it now and delete it on a later pass
+			if m.State == "started" {
+				if err := h.machines.StopMachine(m.ID); err != nil {
+					orphan.Error = err.Error()
+				} else {
+					orphan.Action = "stopped"
+				}
+			} else if err := h.machines.DeleteMachine(m.ID); err != nil {
+				orphan.Error = err.Error()
+			} else {
+				orphan.Action = "deleted"
+			}
+			log.Info("orphaned machine", "machine_id", m.ID, "action", orphan.Action, "error", orphan.Error)
+		}
+		report.Orphans = append(report.Orphans, orphan)
+	}
+
+	for _, v := range volumes {
+		if referencedVolumes[v.ID] || !strings.HasPrefix(v.Name, volumeNamePrefix) {
+			continue
+		}
+		orphan := OrphanResource{Kind: "volume", ID: v.ID, Name: v.Name, State: v.State, CreatedAt: v.CreatedAt, Action: "reported"}
+
+		if !h.pastGracePeriod(v.CreatedAt) {
+			orphan.Action = "within_grace_period"
+		} else if deleting {
+			if err := h.volumes.DeleteVolume(v.ID); err != nil {
+				orphan.Error = err.Error()
+			} else {
+				orphan.Action = "deleted"
+			}
+			log.Info("orphaned volume", "volume_id", v.ID, "action", orphan.Action, "error", orphan.Error)
+		}
+		report.Orphans = append(report.Orphans, orphan)
+	}
+}
+
+// pastGracePeriod reports whether a resource is old enough to be treated as orphaned.
+// Resources with an unparseable creation time are never deleted.
+func (h *ReconcileHandler) pastGracePeriod(createdAt string) bool {
+	created, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return false
+	}
+	return time.Since(created) >= h.config.OrphanGracePeriod
+}
+
+// projectStatusForMachine maps a settled machine state to the project status it implies.
+// Returns false for transitional states, which are left alone until they settle.
+func projectStatusForMachine(state string) (string, bool) {
+	switch state {
+	case "started":
+		return "running", true
+	case "stopped", "suspended", "created", "exited":
+		return "stopped", true
+	default:
+		return "", false
+	}
+}
+
+func machineGone(state string) bool {
+	return state == "destroyed" || state == "destroying"
+}
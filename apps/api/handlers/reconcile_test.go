@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+func (m *mockProjectStore) GetAllProjects(ctx context.Context) ([]db.Project, error) {
+	var projects []db.Project
+	for _, p := range m.projects {
+		projects = append(projects, *p)
+	}
+	return projects, nil
+}
+
+func (m *mockProjectStore) GetProjectIDsWithActiveOperations(ctx context.Context) (map[string]bool, error) {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	ids := make(map[string]bool)
+	for _, op := range m.operations {
+		if op.Status == db.OperationPending || op.Status == db.OperationRunning {
+			ids[op.ProjectID] = true
+		}
+	}
+	return ids, nil
+}
+
+func (m *mockProjectStore) ReconcileProjectStatus(ctx context.Context, projectID, expected, status string) (bool, error) {
+	p, ok := m.projects[projectID]
+	if !ok || p.Status != expected {
+		return false, nil
+	}
+	p.Status = status
+	return true, nil
+}
+
+func (m *mockProjectStore) ClearProjectMachine(ctx context.Context, projectID, machineID string) (bool, error) {
+	p, ok := m.projects[projectID]
+	if !ok || p.FlyMachineID == nil || *p.FlyMachineID != machineID {
+		return false, nil
+	}
+	p.FlyMachineID = nil
+	p.MachineImage = nil
+	return true, nil
+}
+
+func (m *mockProjectStore) ClearProjectVolume(ctx context.Context, projectID, volumeID string) (bool, error) {
+	p, ok := m.projects[projectID]
+	if !ok || p.FlyVolumeID == nil || *p.FlyVolumeID != volumeID {
+		return false, nil
+	}
+	p.FlyVolumeID = nil
+	return true, nil
+}
+
+type mockResourceLister struct {
+	machines []Machine
+	volumes  []Volume
+}
+
+func (m *mockResourceLister) ListMachines() ([]Machine, error) {
+	return m.machines, nil
+}
+
+func (m *mockResourceLister) ListVolumes() ([]Volume, error) {
+	return m.volumes, nil
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func newReconcileFixture() (*mockProjectStore, *mockMachineManager, *mockVolumeManager, *mockResourceLister) {
+	store := newMockStore()
+	store.projects["p-dead"] = &db.Project{ID: "p-dead", Status: "running", FlyMachineID: strPtr("m-dead"), FlyVolumeID: strPtr("v-1")}
+	store.projects["p-stopped"] = &db.Project{ID: "p-stopped", Status: "running", FlyMachineID: strPtr("m-stopped"), FlyVolumeID: strPtr("v-2")}
+	store.projects["p-ok"] = &db.Project{ID: "p-ok", Status: "stopped", FlyMachineID: strPtr("m-ok"), FlyVolumeID: strPtr("v-gone")}
+
+	old := time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
+	fresh := time.Now().Format(time.RFC3339)
+	lister := &mockResourceLister{
+		machines: []Machine{
+			{ID: "m-stopped", Name: "aether-stopped", State: "stopped", CreatedAt: old},
+			{ID: "m-ok", Name: "aether-ok", State: "stopped", CreatedAt: old},
+			{ID: "m-orphan", Name: "aether-orphan", State: "stopped", CreatedAt: old},
+			{ID: "m-new", Name: "aether-new", State: "started", CreatedAt: fresh},
+			{ID: "m-other", Name: "unrelated", State: "started", CreatedAt: old},
+		},
+		volumes: []Volume{
+			{ID: "v-1", Name: "vol_1", CreatedAt: old},
+			{ID: "v-2", Name: "vol_2", CreatedAt: old},
+			{ID: "v-orphan", Name: "vol_orphan", CreatedAt: old},
+		},
+	}
+
+	machines := newMockMachineManager()
+	machines.getFn = func(machineID string) (*Machine, error) {
+		return nil, errors.New("not found")
+	}
+	volumes := newMockVolumeManager()
+	volumes.getFn = func(volumeID string) (*Volume, error) {
+		return nil, errors.New("not found")
+	}
+	return store, machines, volumes, lister
+}
+
+func TestReconcile_DryRunChangesNothing(t *testing.T) {
+	store, machines, volumes, lister := newReconcileFixture()
+	machines.deleteFn = func(machineID string) error {
+		t.Errorf("dry run deleted machine %s", machineID)
+		return nil
+	}
+
+	h := NewReconcileHandler(store, machines, volumes, lister, ReconcileConfig{OrphanPolicy: OrphanPolicyDelete, OrphanGracePeriod: time.Hour})
+	report, err := h.Reconcile(context.Background(), true)
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	for _, a := range report.Actions {
+		if a.Applied {
+			t.Errorf("dry run applied action %+v", a)
+		}
+	}
+	if store.projects["p-dead"].Status != "running" || store.projects["p-dead"].FlyMachineID == nil {
+		t.Error("dry run modified project")
+	}
+	if len(report.Actions) != 4 {
+		t.Errorf("expected 4 actions, got %d: %+v", len(report.Actions), report.Actions)
+	}
+}
+
+func TestReconcile_HealsDriftAndDeletesOrphans(t *testing.T) {
+	store, machines, volumes, lister := newReconcileFixture()
+	var deletedMachines, deletedVolumes []string
+	machines.deleteFn = func(machineID string) error {
+		deletedMachines = append(deletedMachines, machineID)
+		return nil
+	}
+	volumes.deleteFn = func(volumeID string) error {
+		deletedVolumes = append(deletedVolumes, volumeID)
+		return nil
+	}
+
+	h := NewReconcileHandler(store, machines, volumes, lister, ReconcileConfig{OrphanPolicy: OrphanPolicyDelete, OrphanGracePeriod: time.Hour})
+	if _, err := h.Reconcile(context.Background(), false); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	if p := store.projects["p-dead"]; p.Status != "stopped" || p.FlyMachineID != nil {
+		t.Errorf("expected dead machine cleared and status stopped, got status=%s machine=%v", p.Status, p.FlyMachineID)
+	}
+	if p := store.projects["p-stopped"]; p.Status != "stopped" {
+		t.Errorf("expected status stopped, got %s", p.Status)
+	}
+	if p := store.projects["p-ok"]; p.FlyVolumeID != nil {
+		t.Errorf("expected missing volume cleared, got %v", *p.FlyVolumeID)
+	}
+
+	// Only the old, correctly-named orphans are deleted
+	if len(deletedMachines) != 1 || deletedMachines[0] != "m-orphan" {
+		t.Errorf("expected only m-orphan deleted, got %v", deletedMachines)
+	}
+	if len(deletedVolumes) != 1 || deletedVolumes[0] != "v-orphan" {
+		t.Errorf("expected only v-orphan deleted, got %v", deletedVolumes)
+	}
+}
+
+func TestReconcile_SkipsProjectsWithActiveOperations(t *testing.T) {
+	store, machines, volumes, lister := newReconcileFixture()
+	store.seedOperation("p-dead", db.OperationStart)
+
+	h := NewReconcileHandler(store, machines, volumes, lister, ReconcileConfig{})
+	report, err := h.Reconcile(context.Background(), false)
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	if report.Skipped != 1 {
+		t.Errorf("expected 1 skipped project, got %d", report.Skipped)
+	}
+	if p := store.projects["p-dead"]; p.Status != "running" || p.FlyMachineID == nil {
+		t.Error("reconciler touched a project with an active operation")
+	}
+	if report.OrphanPolicy != OrphanPolicyReport {
+		t.Errorf("expected default policy report, got %s", report.OrphanPolicy)
+	}
+}
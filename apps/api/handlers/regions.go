@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/regions"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type RegionListResponse struct {
+	Regions []regions.Region `json:"regions"`
+	Default string           `json:"default"`
+}
+
+type MoveProjectRequest struct {
+	Region string `json:"region"`
+}
+
+type MoveProjectResponse struct {
+	Project     ProjectResponse `json:"project"`
+	OperationID string          `json:"operation_id"`
+}
+
+// projectRegion is where a project's volume and machine live. Projects created
+// before regions were configurable have none and are in the default region.
+func (h *ProjectHandler) projectRegion(project *db.Project) string {
+	if project.Region != nil && *project.Region != "" {
+		return *project.Region
+	}
+	return h.regions.DefaultRegion()
+}
+
+// projectHardware is the project's hardware in the form regions check it against
+func projectHardware(project *db.Project) *validation.HardwareConfig {
+	return &validation.HardwareConfig{
+		CPUKind:      project.CPUKind,
+		CPUs:         project.CPUs,
+		MemoryMB:     project.MemoryMB,
+		VolumeSizeGB: project.VolumeSizeGB,
+		GPUKind:      project.GPUKind,
+	}
+}
+
+// ListRegions returns the regions projects can be placed in and their hardware
+func (h *ProjectHandler) ListRegions(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, RegionListResponse{
+		Regions: h.regions.Regions(),
+		Default: h.regions.DefaultRegion(),
+	})
+}
+
+// Move places a stopped or hibernated project in another region. Its volume is
+// copied there and its machine, which can't change region, is deleted; the next
+// start creates one in the new region. The move runs as an operation, so it
+// finishes even if this request doesn't.
+func (h *ProjectHandler) Move(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	var req MoveProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	project, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for move", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to move project")
+		return
+	}
+	if !allowProject(w, project, roleManage) {
+		return
+	}
+
+	if !checkIfMatch(w, r, project.Version) {
+		return
+	}
+
+	from := h.projectRegion(project)
+	verr := h.regions.Validate(req.Region, projectHardware(project))
+	if verr == nil && req.Region == from {
+		verr = &validation.ValidationError{Field: "region", Message: fmt.Sprintf("project is already in %s", from)}
+	}
+	if verr != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*verr},
+		})
+		return
+	}
+
+	// A hibernated project has no volume, so it moves by restoring into the new region
+	params := map[string]string{
+		moveParamRegion:   req.Region,
+		moveParamFrom:     from,
+		moveParamReturnTo: project.Status,
+	}
+	if project.FlyVolumeID != nil && *project.FlyVolumeID != "" {
+		params[moveParamOldVolume] = *project.FlyVolumeID
+	}
+	op, err := h.store.BeginOperationWithParams(ctx, project.ID, project.UserID, db.OperationMove, params, []string{db.StatusStopped, db.StatusHibernated}, db.StatusMoving)
+	if err != nil {
+		h.writeTransitionError(w, log, err, "move")
+		return
+	}
+	log.Info("moving project", "project_id", project.ID, "from", from, "to", req.Region, "operation_id", op.ID)
+
+	// Read the project back before the operation starts changing it
+	moving, err := h.store.GetProjectByUser(ctx, project.ID, userID)
+	if err != nil {
+		h.dispatchOperation(op)
+		log.Error("failed to get moving project", "project_id", project.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get project")
+		return
+	}
+	response := MoveProjectResponse{Project: projectToResponse(moving), OperationID: op.ID}
+	w.Header().Set("ETag", versionETag(moving.Version))
+	h.dispatchOperation(op)
+
+	WriteJSON(w, http.StatusAccepted, response)
+}
+
+// Move operation params
+const (
+	moveParamRegion    = "region"
+	moveParamFrom      = "from"
+	moveParamReturnTo  = "return_to"
+	moveParamOldVolume = "old_volume_id"
+	moveParamNewVolume = "new_volume_id"
+)
+
+// moveAsync runs a move operation. Until the project's region is switched any
+// failure returns it to where it started; the copied volume is recorded on the
+// operation as soon as it exists, so a resumed attempt reuses it.
+func (h *ProjectHandler) moveAsync(ctx context.Context, op *db.Operation, project *db.Project) error {
+	projectID := project.ID
+	log := logging.Default().With("project_id", projectID, "operation_id", op.ID)
+	region := op.Params[moveParamRegion]
+	returnTo := op.Params[moveParamReturnTo]
+	if region == "" || (returnTo != db.StatusStopped && returnTo != db.StatusHibernated) {
+		return h.failProject(ctx, log, projectID, "Move operation is missing its target region")
+	}
+
+	if h.projectRegion(project) != region {
+		oldVolumeID := op.Params[moveParamOldVolume]
+		newVolumeID := op.Params[moveParamNewVolume]
+		if oldVolumeID != "" && newVolumeID == "" {
+			if err := h.setOperationStep(ctx, op, "copy_volume"); err != nil {
+				return err
+			}
+			// A copy made by an attempt that died before recording it is collected
+			// by the reconciler as an orphan
+			stop := h.keepOperationAlive(ctx, op)
+			volume, err := h.volumes.CopyVolume(oldVolumeID, "vol_"+projectID[:8], region)
+			stop()
+			if err != nil {
+				// Nothing was swapped, so the project is as it was
+				log.Error("failed to copy volume", "volume_id", oldVolumeID, "region", region, "error", err)
+				return h.abortMove(ctx, log, projectID, returnTo, "", "failed to copy volume: "+err.Error())
+			}
+			newVolumeID = volume.ID
+			if err := h.store.SetOperationParams(ctx, op.ID, h.workerID, map[string]string{moveParamNewVolume: newVolumeID}); err != nil {
+				return err
+			}
+			op.Params[moveParamNewVolume] = newVolumeID
+		}
+
+		if project.FlyMachineID != nil && *project.FlyMachineID != "" {
+			if err := h.setOperationStep(ctx, op, "delete_machine"); err != nil {
+				return err
+			}
+			if err := h.stopAndDeleteMachine(*project.FlyMachineID); err != nil {
+				log.Error("failed to delete machine for move", "machine_id", *project.FlyMachineID, "error", err)
+				return h.abortMove(ctx, log, projectID, returnTo, newVolumeID, "failed to delete machine: "+err.Error())
+			}
+			if _, err := h.store.ClearProjectMachine(ctx, projectID, *project.FlyMachineID); err != nil {
+				log.Error("failed to clear project machine", "error", err)
+			}
+		}
+
+		if err := h.setOperationStep(ctx, op, "switch_region"); err != nil {
+			return err
+		}
+		var volumeID *string
+		if newVolumeID != "" {
+			volumeID = &newVolumeID
+		}
+		if err := h.store.MoveProjectRegion(ctx, projectID, region, volumeID); err != nil {
+			log.Error("failed to record project region", "region", region, "error", err)
+			return h.failProject(ctx, log, projectID, "Failed to attach volume in "+region+": "+err.Error())
+		}
+	}
+
+	// The reconciler collects the old volume if this fails
+	if oldVolumeID := op.Params[moveParamOldVolume]; oldVolumeID != "" {
+		if err := h.setOperationStep(ctx, op, "delete_old_volume"); err != nil {
+			return err
+		}
+		if err := h.volumes.DeleteVolume(oldVolumeID); err != nil {
+			log.Error("failed to delete moved volume", "volume_id", oldVolumeID, "error", err)
+		}
+	}
+
+	if err := h.completeTransition(ctx, projectID, db.StatusMoving, returnTo); err != nil {
+		log.Error("failed to update project status", "error", err)
+		return err
+	}
+	if err := h.store.RecordProjectEvent(ctx, projectID, db.EventRegionMoved, nil, map[string]any{"from": op.Params[moveParamFrom], "to": region}); err != nil {
+		log.Error("failed to record move event", "error", err)
+	}
+	log.Info("project moved", "from", op.Params[moveParamFrom], "to", region)
+	return nil
+}
+
+// abortMove deletes the volume a move copied, returns the project to where it
+// started, and returns the reason as an error
+func (h *ProjectHandler) abortMove(ctx context.Context, log *logging.Logger, projectID, returnTo, copiedVolumeID, reason string) error {
+	if copiedVolumeID != "" {
+		if err := h.volumes.DeleteVolume(copiedVolumeID); err != nil {
+			log.Error("failed to delete copied volume", "volume_id", copiedVolumeID, "error", err)
+		}
+	}
+	if err := h.completeTransition(ctx, projectID, db.StatusMoving, returnTo); err != nil {
+		log.Error("failed to update project status", "error", err)
+	}
+	return fmt.Errorf("move aborted: %s", reason)
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+func (m *mockProjectStore) MoveProjectRegion(ctx context.Context, projectID, region string, volumeID *string) error {
+	p, ok := m.projects[projectID]
+	if !ok {
+		return db.ErrNotFound
+	}
+	p.Region = &region
+	p.FlyVolumeID = volumeID
+	p.Version++
+	return nil
+}
+
+func (m *mockVolumeManager) CopyVolume(sourceVolumeID, name, region string) (*Volume, error) {
+	if m.copyFn != nil {
+		return m.copyFn(sourceVolumeID, name, region)
+	}
+	return &Volume{ID: "vol-" + region, Name: name, Region: region, State: "created"}, nil
+}
+
+func TestProjectHandler_Create_Region(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		code   int
+		region string
+	}{
+		{"default region", `{"name":"cpu"}`, http.StatusCreated, "sjc"},
+		{"gpu goes where gpus are", `{"name":"gpu","hardware":{"volume_size_gb":10,"gpu_kind":"a10"}}`, http.StatusCreated, "ord"},
+		{"chosen region", `{"name":"cpu","region":"ord"}`, http.StatusCreated, "ord"},
+		{"unknown region", `{"name":"cpu","region":"xyz"}`, http.StatusBadRequest, ""},
+		{"gpu in a region without gpus", `{"name":"gpu","region":"sjc","hardware":{"volume_size_gb":10,"gpu_kind":"a10"}}`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewProjectHandler(newMockStore(), newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+			req := newAuthenticatedRequest("POST", "/projects", []byte(tt.body))
+			rr := httptest.NewRecorder()
+			handler.Create(rr, req)
+			if rr.Code != tt.code {
+				t.Fatalf("expected status %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.region == "" {
+				return
+			}
+
+			var response ProjectResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if response.Region == nil || *response.Region != tt.region {
+				t.Errorf("expected region %s, got %v", tt.region, response.Region)
+			}
+		})
+	}
+}
+
+func TestProjectHandler_StartUsesRegion(t *testing.T) {
+	ctx := context.Background()
+	store := newProjectFixture(db.StatusStopped)
+	p := store.projects[testProjectID]
+	p.FlyMachineID, p.FlyVolumeID, p.Region = nil, nil, strPtr("ord")
+
+	var volumeRegion, machineRegion string
+	volumes := newMockVolumeManager()
+	volumes.createFn = func(name string, sizeGB int, region string) (*Volume, error) {
+		volumeRegion = region
+		return &Volume{ID: "vol-ord", Name: name, SizeGB: sizeGB, Region: region}, nil
+	}
+	machines := newMockMachineManager()
+	machines.createFn = func(name string, config MachineConfig) (*Machine, error) {
+		machineRegion = config.Region
+		return &Machine{ID: "machine-ord", Name: name, State: "created", Region: config.Region}, nil
+	}
+	handler := NewProjectHandler(store, machines, volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	op, err := store.BeginOperation(ctx, testProjectID, "test-user-id", db.OperationStart, []string{db.StatusStopped}, db.StatusStarting)
+	if err != nil {
+		t.Fatalf("failed to begin start: %v", err)
+	}
+	claimed, err := store.ClaimOperation(ctx, op.ID, handler.workerID)
+	if err != nil {
+		t.Fatalf("failed to claim start: %v", err)
+	}
+	handler.runOperation(ctx, claimed)
+
+	if volumeRegion != "ord" || machineRegion != "ord" {
+		t.Errorf("expected volume and machine in ord, got %q and %q", volumeRegion, machineRegion)
+	}
+
+	// Projects from before regions existed stay in the default region
+	p.Region = nil
+	if config := handler.machineConfig(ctx, p, "test-user-id"); config.Region != "sjc" {
+		t.Errorf("expected default region for a project without one, got %q", config.Region)
+	}
+}
+
+func TestProjectHandler_UpdateHardware_RegionMustOfferIt(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	// sjc has no GPUs; the project has to move to ord first
+	rr := serveRoute(handler.UpdateHardware, "PATCH", "/projects/{id}/hardware", "/projects/"+testProjectID+"/hardware", []byte(`{"volume_size_gb":5,"gpu_kind":"a10"}`))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+
+	store.projects[testProjectID].Region = strPtr("ord")
+	if rr := serveRoute(handler.UpdateHardware, "PATCH", "/projects/{id}/hardware", "/projects/"+testProjectID+"/hardware", []byte(`{"volume_size_gb":5,"gpu_kind":"a10"}`)); rr.Code != http.StatusOK {
+		t.Errorf("expected status %d in ord, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func TestProjectHandler_Move(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.Move, "POST", "/projects/{id}/move", "/projects/"+testProjectID+"/move", []byte(`{"region":"ord"}`))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+
+	var response MoveProjectResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.OperationID == "" || response.Project.Status != db.StatusMoving {
+		t.Errorf("expected a move operation with the project moving, got %q and %s", response.OperationID, response.Project.Status)
+	}
+	if rr.Header().Get("ETag") == "" {
+		t.Error("expected an ETag for the moving project")
+	}
+}
+
+func TestProjectHandler_Move_RunsAsOwner(t *testing.T) {
+	store := newOrgFixture(db.RoleAdmin)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.Move, "POST", "/projects/{id}/move", "/projects/"+testProjectID+"/move", []byte(`{"region":"ord"}`))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+	var response MoveProjectResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	store.opsMu.Lock()
+	defer store.opsMu.Unlock()
+	if op := store.operations[response.OperationID]; op == nil || op.UserID != "owner-id" {
+		t.Errorf("expected the move to run as the project's owner, got %+v", op)
+	}
+}
+
+// runMove begins a move of the fixture project to region and runs it to completion
+func runMove(t *testing.T, handler *ProjectHandler, store *mockProjectStore, region string) *db.Operation {
+	t.Helper()
+	ctx := context.Background()
+	p := store.projects[testProjectID]
+	params := map[string]string{moveParamRegion: region, moveParamFrom: handler.projectRegion(p), moveParamReturnTo: p.Status}
+	if p.FlyVolumeID != nil {
+		params[moveParamOldVolume] = *p.FlyVolumeID
+	}
+	op, err := store.BeginOperationWithParams(ctx, testProjectID, "test-user-id", db.OperationMove, params, []string{db.StatusStopped, db.StatusHibernated}, db.StatusMoving)
+	if err != nil {
+		t.Fatalf("failed to begin move: %v", err)
+	}
+	claimed, err := store.ClaimOperation(ctx, op.ID, handler.workerID)
+	if err != nil {
+		t.Fatalf("failed to claim move: %v", err)
+	}
+	handler.runOperation(ctx, claimed)
+	done, _ := store.GetOperation(ctx, op.ID, testProjectID)
+	return done
+}
+
+func TestProjectHandler_MoveOperation(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	var deletedVolumes, deletedMachines []string
+	volumes := newMockVolumeManager()
+	volumes.deleteFn = func(volumeID string) error {
+		deletedVolumes = append(deletedVolumes, volumeID)
+		return nil
+	}
+	machines := newMockMachineManager()
+	machines.deleteFn = func(machineID string) error {
+		deletedMachines = append(deletedMachines, machineID)
+		return nil
+	}
+	handler := NewProjectHandler(store, machines, volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	op := runMove(t, handler, store, "ord")
+	if op.Status != db.OperationSucceeded {
+		t.Fatalf("expected the move to succeed, got %s: %v", op.Status, op.LastError)
+	}
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusStopped || p.Region == nil || *p.Region != "ord" {
+		t.Errorf("expected stopped in ord, got %s in %v", p.Status, p.Region)
+	}
+	if p.FlyVolumeID == nil || *p.FlyVolumeID != "vol-ord" || p.FlyMachineID != nil {
+		t.Errorf("expected the copied volume and no machine, got %v and %v", p.FlyVolumeID, p.FlyMachineID)
+	}
+	if len(deletedVolumes) != 1 || deletedVolumes[0] != "vol-123" || len(deletedMachines) != 1 || deletedMachines[0] != "machine-123" {
+		t.Errorf("expected the old volume and machine deleted, got %v and %v", deletedVolumes, deletedMachines)
+	}
+	if op.Params[moveParamNewVolume] != "vol-ord" {
+		t.Errorf("expected the copied volume recorded on the operation, got %v", op.Params)
+	}
+
+	if rr := serveRoute(handler.Move, "POST", "/projects/{id}/move", "/projects/"+testProjectID+"/move", []byte(`{"region":"ord"}`)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d moving to the same region, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestProjectHandler_MoveOperation_ResumesAfterCopy(t *testing.T) {
+	ctx := context.Background()
+	store := newProjectFixture(db.StatusStopped)
+	volumes := newMockVolumeManager()
+	volumes.copyFn = func(sourceVolumeID, name, region string) (*Volume, error) {
+		t.Error("volume copied again on resume")
+		return nil, errors.New("already copied")
+	}
+	machines := newMockMachineManager()
+	machines.getFn = func(machineID string) (*Machine, error) {
+		return &Machine{ID: machineID, State: "destroyed"}, nil
+	}
+	handler := NewProjectHandler(store, machines, volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	// An earlier attempt copied the volume and deleted the machine, then died
+	params := map[string]string{moveParamRegion: "ord", moveParamFrom: "sjc", moveParamReturnTo: db.StatusStopped, moveParamOldVolume: "vol-123", moveParamNewVolume: "vol-ord"}
+	op, err := store.BeginOperationWithParams(ctx, testProjectID, "test-user-id", db.OperationMove, params, []string{db.StatusStopped}, db.StatusMoving)
+	if err != nil {
+		t.Fatalf("failed to begin move: %v", err)
+	}
+	claimed, err := store.ClaimOperation(ctx, op.ID, handler.workerID)
+	if err != nil {
+		t.Fatalf("failed to claim move: %v", err)
+	}
+	claimed.Attempts = 2
+	handler.runOperation(ctx, claimed)
+
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusStopped || *p.Region != "ord" || *p.FlyVolumeID != "vol-ord" || p.FlyMachineID != nil {
+		t.Errorf("expected the resumed move to finish in ord on vol-ord, got %s in %v on %v", p.Status, p.Region, p.FlyVolumeID)
+	}
+}
+
+func TestProjectHandler_Move_Rejections(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		gpu    bool
+		body   string
+		code   int
+	}{
+		{"running", db.StatusRunning, false, `{"region":"ord"}`, http.StatusConflict},
+		{"unknown region", db.StatusStopped, false, `{"region":"xyz"}`, http.StatusBadRequest},
+		{"gpu to a region without gpus", db.StatusStopped, true, `{"region":"sjc"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newProjectFixture(tt.status)
+			if tt.gpu {
+				store.projects[testProjectID].GPUKind = strPtr("a10")
+				store.projects[testProjectID].Region = strPtr("ord")
+			}
+			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+			if rr := serveRoute(handler.Move, "POST", "/projects/{id}/move", "/projects/"+testProjectID+"/move", []byte(tt.body)); rr.Code != tt.code {
+				t.Fatalf("expected status %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if p := store.projects[testProjectID]; *p.FlyVolumeID != "vol-123" || p.Status != tt.status {
+				t.Errorf("project changed despite rejection: %s on %s", p.Status, *p.FlyVolumeID)
+			}
+		})
+	}
+}
+
+func TestProjectHandler_MoveOperation_CopyFailureKeepsProject(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	volumes := newMockVolumeManager()
+	volumes.copyFn = func(sourceVolumeID, name, region string) (*Volume, error) {
+		return nil, errors.New("no capacity in ord")
+	}
+	machines := newMockMachineManager()
+	machines.deleteFn = func(machineID string) error {
+		t.Errorf("machine %s deleted after a failed copy", machineID)
+		return nil
+	}
+	handler := NewProjectHandler(store, machines, volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	if op := runMove(t, handler, store, "ord"); op.Status != db.OperationFailed {
+		t.Fatalf("expected the move to fail, got %s", op.Status)
+	}
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusStopped || p.Region != nil || *p.FlyVolumeID != "vol-123" {
+		t.Errorf("expected the project left stopped where it was, got %s in %v on %s", p.Status, p.Region, *p.FlyVolumeID)
+	}
+}
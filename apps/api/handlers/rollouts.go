@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultRolloutCanaryPercent = 5
+	defaultRolloutBatchSize     = 10
+	// rolloutLease is how long a worker may hold a rollout; longer than one advance
+	rolloutLease = 2 * time.Minute
+)
+
+// ProjectUpgrader moves projects between images. ProjectHandler implements it.
+type ProjectUpgrader interface {
+	UpgradeProject(ctx context.Context, projectID, from, to string) (*db.Operation, error)
+}
+
+// RolloutHandler lets operators roll a new base image out across existing projects:
+// a random canary slice first, then everything else in batches, with pause and rollback.
+type RolloutHandler struct {
+	store    RolloutStore
+	upgrader ProjectUpgrader
+	workerID string
+}
+
+func NewRolloutHandler(store RolloutStore, upgrader ProjectUpgrader) *RolloutHandler {
+	return &RolloutHandler{store: store, upgrader: upgrader, workerID: newWorkerID()}
+}
+
+type CreateRolloutRequest struct {
+	Image         string `json:"image"`
+	CanaryPercent *int   `json:"canary_percent,omitempty"`
+	BatchSize     *int   `json:"batch_size,omitempty"`
+}
+
+type RolloutResponse struct {
+	db.Rollout
+	// Counts is the number of projects in each rollout project status
+	Counts   map[string]int      `json:"counts"`
+	Projects []db.RolloutProject `json:"projects,omitempty"`
+}
+
+type RolloutListResponse struct {
+	Rollouts []db.Rollout `json:"rollouts"`
+}
+
+func rolloutToResponse(rollout *db.Rollout, projects []db.RolloutProject) RolloutResponse {
+	counts := make(map[string]int)
+	for _, p := range projects {
+		counts[p.Status]++
+	}
+	if projects == nil {
+		projects = []db.RolloutProject{}
+	}
+	return RolloutResponse{Rollout: *rollout, Counts: counts, Projects: projects}
+}
+
+// List returns every rollout, newest first
+func (h *RolloutHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	rollouts, err := h.store.ListRollouts(ctx)
+	if err != nil {
+		log.Error("failed to list rollouts", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list rollouts")
+		return
+	}
+	if rollouts == nil {
+		rollouts = []db.Rollout{}
+	}
+
+	WriteJSON(w, http.StatusOK, RolloutListResponse{Rollouts: rollouts})
+}
+
+// Create starts a rollout. It begins with the canary slice unless canary_percent is 0.
+func (h *RolloutHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	var req CreateRolloutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	canaryPercent := defaultRolloutCanaryPercent
+	if req.CanaryPercent != nil {
+		canaryPercent = *req.CanaryPercent
+	}
+	batchSize := defaultRolloutBatchSize
+	if req.BatchSize != nil {
+		batchSize = *req.BatchSize
+	}
+
+	if errs := validation.ValidateRollout(req.Image, canaryPercent, batchSize); errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+
+	rollout, err := h.store.CreateRollout(ctx, req.Image, canaryPercent, batchSize, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrRolloutInProgress) {
+			WriteError(w, http.StatusConflict, "Another rollout is already in progress")
+			return
+		}
+		log.Error("failed to create rollout", "image", req.Image, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to create rollout")
+		return
+	}
+
+	projects, err := h.store.ListRolloutProjects(ctx, rollout.ID)
+	if err != nil {
+		log.Error("failed to list rollout projects", "rollout_id", rollout.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to create rollout")
+		return
+	}
+
+	log.Info("rollout created", "rollout_id", rollout.ID, "image", rollout.Image, "projects", len(projects), "canary_percent", canaryPercent)
+	WriteJSON(w, http.StatusCreated, rolloutToResponse(rollout, projects))
+}
+
+// Get returns a rollout with the progress of each of its projects
+func (h *RolloutHandler) Get(w http.ResponseWriter, r *http.Request) {
+	rollout := h.getRollout(w, r)
+	if rollout == nil {
+		return
+	}
+	h.writeRollout(w, r, rollout, http.StatusOK)
+}
+
+// Pause stops a rollout from upgrading more projects. Upgrades already under way finish.
+func (h *RolloutHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, "pause", []string{db.RolloutCanary, db.RolloutRolling}, func(*db.Rollout) string {
+		return db.RolloutPaused
+	})
+}
+
+// Resume continues a paused rollout: the rest of the canary slice if any is left,
+// otherwise every remaining project
+func (h *RolloutHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, "resume", []string{db.RolloutPaused}, func(rollout *db.Rollout) string {
+		projects, err := h.store.ListRolloutProjects(r.Context(), rollout.ID)
+		if err != nil {
+			return ""
+		}
+		for _, p := range projects {
+			if p.Canary && (p.Status == db.RolloutProjectPending || p.Status == db.RolloutProjectUpgrading) {
+				return db.RolloutCanary
+			}
+		}
+		return db.RolloutRolling
+	})
+}
+
+// Rollback stops a rollout and moves its upgraded projects back to their previous image
+func (h *RolloutHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, "roll back", []string{db.RolloutCanary, db.RolloutPaused, db.RolloutRolling, db.RolloutCompleted}, func(*db.Rollout) string {
+		return db.RolloutRollingBack
+	})
+}
+
+// transition moves a rollout from one of the given statuses to the one picked by next
+func (h *RolloutHandler) transition(w http.ResponseWriter, r *http.Request, action string, from []string, next func(*db.Rollout) string) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	rollout := h.getRollout(w, r)
+	if rollout == nil {
+		return
+	}
+
+	allowed := false
+	for _, s := range from {
+		allowed = allowed || rollout.Status == s
+	}
+	if !allowed {
+		WriteJSON(w, http.StatusConflict, map[string]string{
+			"error":          "Cannot " + action + " rollout while it is " + rollout.Status,
+			"current_status": rollout.Status,
+		})
+		return
+	}
+
+	status := next(rollout)
+	if status == "" {
+		log.Error("failed to pick rollout status", "rollout_id", rollout.ID, "action", action)
+		WriteError(w, http.StatusInternalServerError, "Failed to update rollout")
+		return
+	}
+
+	updated, err := h.store.UpdateRolloutStatus(ctx, rollout.ID, []string{rollout.Status}, status, nil)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusConflict, "Rollout changed while updating; try again")
+			return
+		}
+		if errors.Is(err, db.ErrRolloutInProgress) {
+			WriteError(w, http.StatusConflict, "Another rollout is already in progress")
+			return
+		}
+		log.Error("failed to update rollout status", "rollout_id", rollout.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update rollout")
+		return
+	}
+
+	// Projects the rollout never reached have nothing to roll back
+	if status == db.RolloutRollingBack {
+		if err := h.store.SetRolloutProjectsStatus(ctx, rollout.ID, []string{db.RolloutProjectPending}, db.RolloutProjectSkipped); err != nil {
+			log.Error("failed to skip pending rollout projects", "rollout_id", rollout.ID, "error", err)
+		}
+	}
+
+	log.Info("rollout updated", "rollout_id", rollout.ID, "action", action, "status", status)
+	h.writeRollout(w, r, updated, http.StatusOK)
+}
+
+// getRollout validates the route ID and loads the rollout. It writes the error
+// response and returns nil if anything fails.
+func (h *RolloutHandler) getRollout(w http.ResponseWriter, r *http.Request) *db.Rollout {
+	ctx := r.Context()
+	rolloutID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(rolloutID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return nil
+	}
+
+	rollout, err := h.store.GetRollout(ctx, rolloutID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Rollout not found")
+			return nil
+		}
+		log.Error("failed to get rollout", "rollout_id", rolloutID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get rollout")
+		return nil
+	}
+	return rollout
+}
+
+func (h *RolloutHandler) writeRollout(w http.ResponseWriter, r *http.Request, rollout *db.Rollout, status int) {
+	projects, err := h.store.ListRolloutProjects(r.Context(), rollout.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to list rollout projects", "rollout_id", rollout.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get rollout")
+		return
+	}
+	WriteJSON(w, status, rolloutToResponse(rollout, projects))
+}
+
+// StartRolloutWorker starts a background goroutine that advances active rollouts
+func (h *RolloutHandler) StartRolloutWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			h.advanceRollouts(context.Background())
+		}
+	}()
+}
+
+func (h *RolloutHandler) advanceRollouts(ctx context.Context) {
+	log := logging.Default()
+
+	rollouts, err := h.store.ListActiveRollouts(ctx)
+	if err != nil {
+		log.Error("failed to list active rollouts", "error", err)
+		return
+	}
+
+	for i := range rollouts {
+		rollout := &rollouts[i]
+		leased, err := h.store.LeaseRollout(ctx, rollout.ID, h.workerID, rolloutLease)
+		if err != nil {
+			log.Error("failed to lease rollout", "rollout_id", rollout.ID, "error", err)
+			continue
+		}
+		if !leased {
+			continue
+		}
+		if err := h.advance(ctx, rollout); err != nil {
+			log.Error("failed to advance rollout", "rollout_id", rollout.ID, "error", err)
+		}
+	}
+}
+
+// advance settles finished upgrades, starts up to a batch of new ones, and moves the
+// rollout on when its current phase is done. A failed upgrade pauses the rollout.
+func (h *RolloutHandler) advance(ctx context.Context, rollout *db.Rollout) error {
+	log := logging.Default().With("rollout_id", rollout.ID, "status", rollout.Status)
+
+	projects, err := h.store.ListRolloutProjects(ctx, rollout.ID)
+	if err != nil {
+		return err
+	}
+
+	rollingBack := rollout.Status == db.RolloutRollingBack
+
+	// Settle projects whose restart onto the new image has finished
+	inFlight, failed := 0, 0
+	for i := range projects {
+		p := &projects[i]
+		if (p.Status != db.RolloutProjectUpgrading && p.Status != db.RolloutProjectReverting) || p.OperationID == nil {
+			continue
+		}
+		op, err := h.store.GetOperation(ctx, *p.OperationID, p.ProjectID)
+		if err != nil {
+			return err
+		}
+
+		done, failure := db.RolloutProjectUpgraded, db.RolloutProjectFailed
+		if p.Status == db.RolloutProjectReverting {
+			done, failure = db.RolloutProjectReverted, db.RolloutProjectRevertFailed
+		}
+		switch op.Status {
+		case db.OperationSucceeded:
+			p.Status = done
+		case db.OperationFailed:
+			p.Status = failure
+			failed++
+		default:
+			inFlight++
+			continue
+		}
+		if err := h.store.UpdateRolloutProject(ctx, rollout.ID, p.ProjectID, p.Status, p.OperationID, op.LastError); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 && !rollingBack {
+		reason := fmt.Sprintf("%d project(s) failed to upgrade", failed)
+		log.Warn("pausing rollout", "reason", reason)
+		_, err := h.store.UpdateRolloutStatus(ctx, rollout.ID, []string{rollout.Status}, db.RolloutPaused, &reason)
+		return err
+	}
+
+	var todo []*db.RolloutProject
+	for i := range projects {
+		p := &projects[i]
+		switch rollout.Status {
+		case db.RolloutCanary:
+			if p.Canary && p.Status == db.RolloutProjectPending {
+				todo = append(todo, p)
+			}
+		case db.RolloutRolling:
+			if p.Status == db.RolloutProjectPending {
+				todo = append(todo, p)
+			}
+		case db.RolloutRollingBack:
+			if p.Status == db.RolloutProjectUpgraded || p.Status == db.RolloutProjectFailed {
+				todo = append(todo, p)
+			}
+		}
+	}
+
+	if len(todo) == 0 && inFlight == 0 {
+		return h.finishPhase(ctx, log, rollout)
+	}
+
+	for _, p := range todo {
+		if inFlight >= rollout.BatchSize {
+			break
+		}
+
+		from, to := p.PreviousImage, rollout.Image
+		started, done := db.RolloutProjectUpgrading, db.RolloutProjectUpgraded
+		if rollingBack {
+			from, to = rollout.Image, p.PreviousImage
+			started, done = db.RolloutProjectReverting, db.RolloutProjectReverted
+		}
+
+		op, err := h.upgrader.UpgradeProject(ctx, p.ProjectID, from, to)
+		var transitionErr *db.TransitionError
+		switch {
+		case err == nil && op == nil:
+			// Not running; the image applies on its next start
+			err = h.store.UpdateRolloutProject(ctx, rollout.ID, p.ProjectID, done, nil, nil)
+		case err == nil:
+			inFlight++
+			err = h.store.UpdateRolloutProject(ctx, rollout.ID, p.ProjectID, started, &op.ID, nil)
+		case errors.As(err, &transitionErr), errors.Is(err, db.ErrOperationInProgress):
+			// Busy; try again on the next pass
+			continue
+		case errors.Is(err, db.ErrNotFound), errors.Is(err, errTemplateImage), errors.Is(err, errImageChanged):
+			msg := err.Error()
+			err = h.store.UpdateRolloutProject(ctx, rollout.ID, p.ProjectID, db.RolloutProjectSkipped, nil, &msg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// finishPhase moves a rollout on once its current phase has no work left. Canaries
+// done pauses it for the operator to check them before rolling out further.
+func (h *RolloutHandler) finishPhase(ctx context.Context, log *logging.Logger, rollout *db.Rollout) error {
+	var next string
+	var reason *string
+	switch rollout.Status {
+	case db.RolloutCanary:
+		next = db.RolloutPaused
+		msg := "canary complete"
+		reason = &msg
+	case db.RolloutRolling:
+		next = db.RolloutCompleted
+	case db.RolloutRollingBack:
+		next = db.RolloutRolledBack
+	default:
+		return nil
+	}
+
+	if _, err := h.store.UpdateRolloutStatus(ctx, rollout.ID, []string{rollout.Status}, next, reason); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			// The operator changed it meanwhile
+			return nil
+		}
+		return err
+	}
+	log.Info("rollout phase finished", "next", next)
+	return nil
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const rolloutID = "660e8400-e29b-41d4-a716-446655440000"
+
+type mockRolloutStore struct {
+	rollouts   map[string]*db.Rollout
+	projects   []db.RolloutProject
+	operations map[string]*db.Operation
+}
+
+func newRolloutFixture(status string, batchSize int, canaries int, projectIDs ...string) *mockRolloutStore {
+	store := &mockRolloutStore{
+		rollouts:   map[string]*db.Rollout{rolloutID: {ID: rolloutID, Image: "new-image", CanaryPercent: 25, BatchSize: batchSize, Status: status}},
+		operations: make(map[string]*db.Operation),
+	}
+	for i, id := range projectIDs {
+		store.projects = append(store.projects, db.RolloutProject{
+			RolloutID:     rolloutID,
+			ProjectID:     id,
+			PreviousImage: "old-image",
+			Canary:        i < canaries,
+			Status:        db.RolloutProjectPending,
+		})
+	}
+	return store
+}
+
+func (m *mockRolloutStore) CreateRollout(ctx context.Context, image string, canaryPercent, batchSize int, createdBy string) (*db.Rollout, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m *mockRolloutStore) ListRollouts(ctx context.Context) ([]db.Rollout, error) {
+	var result []db.Rollout
+	for _, r := range m.rollouts {
+		result = append(result, *r)
+	}
+	return result, nil
+}
+
+func (m *mockRolloutStore) ListActiveRollouts(ctx context.Context) ([]db.Rollout, error) {
+	var result []db.Rollout
+	for _, r := range m.rollouts {
+		switch r.Status {
+		case db.RolloutCanary, db.RolloutRolling, db.RolloutRollingBack:
+			result = append(result, *r)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRolloutStore) GetRollout(ctx context.Context, rolloutID string) (*db.Rollout, error) {
+	r, ok := m.rollouts[rolloutID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	copied := *r
+	return &copied, nil
+}
+
+func (m *mockRolloutStore) UpdateRolloutStatus(ctx context.Context, rolloutID string, from []string, status string, pauseReason *string) (*db.Rollout, error) {
+	r, ok := m.rollouts[rolloutID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	for _, s := range from {
+		if r.Status == s {
+			r.Status, r.PauseReason = status, pauseReason
+			copied := *r
+			return &copied, nil
+		}
+	}
+	return nil, db.ErrNotFound
+}
+
+func (m *mockRolloutStore) LeaseRollout(ctx context.Context, rolloutID, workerID string, leaseFor time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (m *mockRolloutStore) ListRolloutProjects(ctx context.Context, rolloutID string) ([]db.RolloutProject, error) {
+	return append([]db.RolloutProject(nil), m.projects...), nil
+}
+
+func (m *mockRolloutStore) UpdateRolloutProject(ctx context.Context, rolloutID, projectID, status string, operationID, errorMsg *string) error {
+	for i := range m.projects {
+		if m.projects[i].ProjectID == projectID {
+			m.projects[i].Status, m.projects[i].OperationID, m.projects[i].Error = status, operationID, errorMsg
+		}
+	}
+	return nil
+}
+
+func (m *mockRolloutStore) SetRolloutProjectsStatus(ctx context.Context, rolloutID string, from []string, status string) error {
+	for i := range m.projects {
+		for _, s := range from {
+			if m.projects[i].Status == s {
+				m.projects[i].Status = status
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockRolloutStore) GetOperation(ctx context.Context, operationID, projectID string) (*db.Operation, error) {
+	op, ok := m.operations[operationID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	return op, nil
+}
+
+func (m *mockRolloutStore) status(projectID string) string {
+	for _, p := range m.projects {
+		if p.ProjectID == projectID {
+			return p.Status
+		}
+	}
+	return ""
+}
+
+// fakeUpgrader records upgrades. Projects listed in running get an operation
+// in the store; the rest upgrade without one, as stopped projects do.
+type fakeUpgrader struct {
+	store    *mockRolloutStore
+	running  map[string]bool
+	upgrades []string
+}
+
+func (f *fakeUpgrader) UpgradeProject(ctx context.Context, projectID, from, to string) (*db.Operation, error) {
+	f.upgrades = append(f.upgrades, projectID+":"+from+"->"+to)
+	if !f.running[projectID] {
+		return nil, nil
+	}
+	op := &db.Operation{ID: "op-" + projectID, ProjectID: projectID, Type: db.OperationUpgrade, Status: db.OperationRunning}
+	f.store.operations[op.ID] = op
+	return op, nil
+}
+
+func serveRolloutAction(handler *RolloutHandler, action string) *httptest.ResponseRecorder {
+	router := chi.NewRouter()
+	router.Post("/admin/rollouts/{id}/"+action, map[string]http.HandlerFunc{
+		"pause":    handler.Pause,
+		"resume":   handler.Resume,
+		"rollback": handler.Rollback,
+	}[action])
+
+	req := newAuthenticatedRequest("POST", "/admin/rollouts/"+rolloutID+"/"+action, nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRolloutHandler_CanaryThenRollout(t *testing.T) {
+	ctx := context.Background()
+	store := newRolloutFixture(db.RolloutCanary, 2, 1, "p1", "p2", "p3")
+	upgrader := &fakeUpgrader{store: store, running: map[string]bool{"p1": true}}
+	handler := NewRolloutHandler(store, upgrader)
+
+	// Only the canary is upgraded, and the rollout waits on its restart
+	handler.advanceRollouts(ctx)
+	handler.advanceRollouts(ctx)
+	if len(upgrader.upgrades) != 1 || upgrader.upgrades[0] != "p1:old-image->new-image" {
+		t.Fatalf("expected only the canary upgraded, got %v", upgrader.upgrades)
+	}
+	if got := store.status("p1"); got != db.RolloutProjectUpgrading {
+		t.Fatalf("expected canary upgrading, got %s", got)
+	}
+
+	store.operations["op-p1"].Status = db.OperationSucceeded
+	handler.advanceRollouts(ctx)
+	handler.advanceRollouts(ctx)
+	if got := store.status("p1"); got != db.RolloutProjectUpgraded {
+		t.Errorf("expected canary upgraded, got %s", got)
+	}
+	if r := store.rollouts[rolloutID]; r.Status != db.RolloutPaused {
+		t.Fatalf("expected rollout paused after canary, got %s", r.Status)
+	}
+
+	rr := serveRolloutAction(handler, "resume")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if r := store.rollouts[rolloutID]; r.Status != db.RolloutRolling {
+		t.Fatalf("expected rolling after resume, got %s", r.Status)
+	}
+
+	handler.advanceRollouts(ctx)
+	handler.advanceRollouts(ctx)
+	for _, id := range []string{"p2", "p3"} {
+		if got := store.status(id); got != db.RolloutProjectUpgraded {
+			t.Errorf("expected %s upgraded, got %s", id, got)
+		}
+	}
+	if r := store.rollouts[rolloutID]; r.Status != db.RolloutCompleted {
+		t.Errorf("expected rollout completed, got %s", r.Status)
+	}
+}
+
+func TestRolloutHandler_FailurePausesRollout(t *testing.T) {
+	ctx := context.Background()
+	store := newRolloutFixture(db.RolloutRolling, 1, 0, "p1", "p2")
+	upgrader := &fakeUpgrader{store: store, running: map[string]bool{"p1": true, "p2": true}}
+	handler := NewRolloutHandler(store, upgrader)
+
+	handler.advanceRollouts(ctx)
+	if len(upgrader.upgrades) != 1 {
+		t.Fatalf("expected one upgrade per batch, got %v", upgrader.upgrades)
+	}
+
+	store.operations["op-p1"].Status = db.OperationFailed
+	handler.advanceRollouts(ctx)
+
+	r := store.rollouts[rolloutID]
+	if r.Status != db.RolloutPaused || r.PauseReason == nil {
+		t.Fatalf("expected rollout paused with a reason, got %s", r.Status)
+	}
+	if got := store.status("p1"); got != db.RolloutProjectFailed {
+		t.Errorf("expected p1 failed, got %s", got)
+	}
+	if got := store.status("p2"); got != db.RolloutProjectPending {
+		t.Errorf("expected p2 left pending, got %s", got)
+	}
+}
+
+func TestRolloutHandler_Rollback(t *testing.T) {
+	ctx := context.Background()
+	store := newRolloutFixture(db.RolloutPaused, 10, 1, "p1", "p2")
+	store.projects[0].Status = db.RolloutProjectUpgraded
+	upgrader := &fakeUpgrader{store: store}
+	handler := NewRolloutHandler(store, upgrader)
+
+	rr := serveRolloutAction(handler, "rollback")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := store.status("p2"); got != db.RolloutProjectSkipped {
+		t.Errorf("expected pending project skipped, got %s", got)
+	}
+
+	handler.advanceRollouts(ctx)
+	handler.advanceRollouts(ctx)
+	if len(upgrader.upgrades) != 1 || upgrader.upgrades[0] != "p1:new-image->old-image" {
+		t.Errorf("expected p1 moved back to old-image, got %v", upgrader.upgrades)
+	}
+	if got := store.status("p1"); got != db.RolloutProjectReverted {
+		t.Errorf("expected p1 reverted, got %s", got)
+	}
+	if r := store.rollouts[rolloutID]; r.Status != db.RolloutRolledBack {
+		t.Errorf("expected rolled back, got %s", r.Status)
+	}
+
+	rr = serveRolloutAction(handler, "pause")
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected pausing a rolled back rollout to conflict, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aether/apps/api/cron"
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/quota"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// scheduleMissedAfter is how late a run may be picked up, e.g. after an outage,
+// before it is skipped as missed rather than starting or stopping at a surprising time
+const scheduleMissedAfter = 10 * time.Minute
+
+type CreateScheduleRequest struct {
+	Action string `json:"action"`
+	Cron   string `json:"cron"`
+	// Timezone is an IANA name; defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// UpdateScheduleRequest changes the fields that are set and leaves the rest alone
+type UpdateScheduleRequest struct {
+	Action   *string `json:"action,omitempty"`
+	Cron     *string `json:"cron,omitempty"`
+	Timezone *string `json:"timezone,omitempty"`
+	Enabled  *bool   `json:"enabled,omitempty"`
+}
+
+type ScheduleResponse struct {
+	ID              string     `json:"id"`
+	Action          string     `json:"action"`
+	Cron            string     `json:"cron"`
+	Timezone        string     `json:"timezone"`
+	Enabled         bool       `json:"enabled"`
+	NextRunAt       *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	LastResult      *string    `json:"last_result,omitempty"`
+	LastError       *string    `json:"last_error,omitempty"`
+	LastOperationID *string    `json:"last_operation_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type ScheduleListResponse struct {
+	Schedules []ScheduleResponse `json:"schedules"`
+}
+
+func scheduleToResponse(s *db.Schedule) ScheduleResponse {
+	return ScheduleResponse{
+		ID:              s.ID,
+		Action:          s.Action,
+		Cron:            s.Cron,
+		Timezone:        s.Timezone,
+		Enabled:         s.Enabled,
+		NextRunAt:       s.NextRunAt,
+		LastRunAt:       s.LastRunAt,
+		LastResult:      s.LastResult,
+		LastError:       s.LastError,
+		LastOperationID: s.LastOperationID,
+		CreatedAt:       s.CreatedAt,
+	}
+}
+
+func schedulesToResponse(schedules []db.Schedule) []ScheduleResponse {
+	response := make([]ScheduleResponse, len(schedules))
+	for i, s := range schedules {
+		response[i] = scheduleToResponse(&s)
+	}
+	return response
+}
+
+// nextScheduleRun returns when a schedule next fires after t, or nil if it is
+// disabled or never fires. The expression and timezone must already be valid.
+func nextScheduleRun(cronExpr, timezone string, enabled bool, t time.Time) *time.Time {
+	if !enabled {
+		return nil
+	}
+	schedule, err := cron.Parse(cronExpr)
+	if err != nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil
+	}
+	next := schedule.Next(t.In(loc))
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+// getScheduleProject validates the route IDs and loads the caller's project,
+// on which their role must grant min. It writes the error response and returns
+// nil if anything fails.
+func (h *ProjectHandler) getScheduleProject(w http.ResponseWriter, r *http.Request, withSchedule bool, min string) *db.Project {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	var errs validation.ValidationErrors
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		errs = append(errs, *err)
+	}
+	if withSchedule {
+		if err := validation.ValidateUUID(chi.URLParam(r, "scheduleId"), "scheduleId"); err != nil {
+			errs = append(errs, *err)
+		}
+	}
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return nil
+	}
+
+	project, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return nil
+		}
+		log.Error("failed to get project for schedule", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get project")
+		return nil
+	}
+	if !allowProject(w, project, min) {
+		return nil
+	}
+	return project
+}
+
+func (h *ProjectHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	project := h.getScheduleProject(w, r, false, db.RoleViewer)
+	if project == nil {
+		return
+	}
+
+	schedules, err := h.store.ListSchedules(ctx, project.ID)
+	if err != nil {
+		log.Error("failed to list schedules", "project_id", project.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list schedules")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, ScheduleListResponse{Schedules: schedulesToResponse(schedules)})
+}
+
+// CreateSchedule adds a cron schedule that starts or stops the project
+func (h *ProjectHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	project := h.getScheduleProject(w, r, false, roleOperate)
+	if project == nil {
+		return
+	}
+
+	var req CreateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	enabled := req.Enabled == nil || *req.Enabled
+
+	if errs := validation.ValidateSchedule(req.Action, req.Cron, req.Timezone); errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+
+	existing, err := h.store.ListSchedules(ctx, project.ID)
+	if err != nil {
+		log.Error("failed to list schedules", "project_id", project.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to create schedule")
+		return
+	}
+	if len(existing) >= validation.MaxSchedulesPerProject {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{{Field: "schedules", Message: fmt.Sprintf("a project can have at most %d schedules", validation.MaxSchedulesPerProject)}},
+		})
+		return
+	}
+
+	nextRunAt := nextScheduleRun(req.Cron, req.Timezone, enabled, time.Now())
+	schedule, err := h.store.CreateSchedule(ctx, project.ID, userID, req.Action, req.Cron, req.Timezone, enabled, nextRunAt)
+	if err != nil {
+		log.Error("failed to create schedule", "project_id", project.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to create schedule")
+		return
+	}
+
+	log.Info("schedule created", "project_id", project.ID, "schedule_id", schedule.ID, "action", schedule.Action, "cron", schedule.Cron)
+	WriteJSON(w, http.StatusCreated, scheduleToResponse(schedule))
+}
+
+// UpdateSchedule changes a schedule. Its next run is recomputed from now.
+func (h *ProjectHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	project := h.getScheduleProject(w, r, true, roleOperate)
+	if project == nil {
+		return
+	}
+	if !checkIfMatch(w, r, project.Version) {
+		return
+	}
+	scheduleID := chi.URLParam(r, "scheduleId")
+
+	var req UpdateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	schedule, err := h.store.GetSchedule(ctx, scheduleID, project.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Schedule not found")
+			return
+		}
+		log.Error("failed to get schedule", "schedule_id", scheduleID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update schedule")
+		return
+	}
+
+	if req.Action != nil {
+		schedule.Action = *req.Action
+	}
+	if req.Cron != nil {
+		schedule.Cron = *req.Cron
+	}
+	if req.Timezone != nil {
+		schedule.Timezone = *req.Timezone
+	}
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+
+	if errs := validation.ValidateSchedule(schedule.Action, schedule.Cron, schedule.Timezone); errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+
+	nextRunAt := nextScheduleRun(schedule.Cron, schedule.Timezone, schedule.Enabled, time.Now())
+	updated, err := h.store.UpdateSchedule(ctx, schedule.ID, project.ID, schedule.Action, schedule.Cron, schedule.Timezone, schedule.Enabled, nextRunAt)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Schedule not found")
+			return
+		}
+		log.Error("failed to update schedule", "schedule_id", schedule.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update schedule")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, scheduleToResponse(updated))
+}
+
+func (h *ProjectHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	project := h.getScheduleProject(w, r, true, roleOperate)
+	if project == nil {
+		return
+	}
+	if !checkIfMatch(w, r, project.Version) {
+		return
+	}
+	scheduleID := chi.URLParam(r, "scheduleId")
+
+	if err := h.store.DeleteSchedule(ctx, scheduleID, project.ID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Schedule not found")
+			return
+		}
+		log.Error("failed to delete schedule", "schedule_id", scheduleID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to delete schedule")
+		return
+	}
+
+	log.Info("schedule deleted", "project_id", project.ID, "schedule_id", scheduleID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StartScheduler starts a background goroutine that runs due start and stop schedules
+func (h *ProjectHandler) StartScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			h.runDueSchedules(context.Background(), time.Now())
+		}
+	}()
+}
+
+func (h *ProjectHandler) runDueSchedules(ctx context.Context, now time.Time) {
+	log := logging.Default()
+
+	schedules, err := h.store.ListDueSchedules(ctx, now)
+	if err != nil {
+		log.Error("failed to list due schedules", "error", err)
+		return
+	}
+
+	for i := range schedules {
+		h.runSchedule(ctx, &schedules[i], now)
+	}
+}
+
+// runSchedule claims a due run and starts or stops the project through the same
+// operations as the HTTP handlers. Runs picked up too late are skipped as missed.
+func (h *ProjectHandler) runSchedule(ctx context.Context, s *db.Schedule, now time.Time) {
+	log := logging.Default().With("schedule_id", s.ID, "project_id", s.ProjectID, "action", s.Action)
+
+	// Later runs are counted from now so an outage doesn't replay every run it covered
+	dueAt := *s.NextRunAt
+	claimed, err := h.store.ClaimScheduleRun(ctx, s.ID, dueAt, nextScheduleRun(s.Cron, s.Timezone, s.Enabled, now))
+	if err != nil {
+		log.Error("failed to claim schedule run", "error", err)
+		return
+	}
+	if !claimed {
+		// Another replica ran it, or it was edited meanwhile
+		return
+	}
+
+	record := func(result string, errorMsg string, op *db.Operation) {
+		var msg, opID *string
+		if errorMsg != "" {
+			msg = &errorMsg
+		}
+		if op != nil {
+			opID = &op.ID
+		}
+		if err := h.store.RecordScheduleResult(ctx, s.ID, result, msg, opID); err != nil {
+			log.Error("failed to record schedule result", "error", err)
+		}
+	}
+
+	if late := now.Sub(dueAt); late > scheduleMissedAfter {
+		log.Warn("skipping missed schedule run", "due_at", dueAt, "late", late.Round(time.Second))
+		record(db.ScheduleResultMissed, "run was due at "+dueAt.Format(time.RFC3339), nil)
+		return
+	}
+
+	project, err := h.store.GetProject(ctx, s.ProjectID)
+	if err != nil {
+		log.Error("failed to get project for schedule", "error", err)
+		record(db.ScheduleResultFailed, "failed to load project", nil)
+		return
+	}
+
+	var op *db.Operation
+	var notice string
+	switch s.Action {
+	case db.ScheduleActionStart:
+		op, err = h.beginStart(ctx, project)
+		notice = "Starting on schedule"
+	case db.ScheduleActionStop:
+		op, err = h.beginStop(ctx, project)
+		notice = "Stopping on schedule"
+	default:
+		record(db.ScheduleResultFailed, "unknown action "+s.Action, nil)
+		return
+	}
+
+	var transitionErr *db.TransitionError
+	var exceeded *quota.Exceeded
+	switch {
+	case errors.As(err, &transitionErr):
+		log.Debug("skipping schedule run, project is " + transitionErr.Current)
+		record(db.ScheduleResultSkipped, "project was "+transitionErr.Current, nil)
+		return
+	case errors.Is(err, db.ErrOperationInProgress):
+		record(db.ScheduleResultSkipped, "another operation was in progress", nil)
+		return
+	case errors.Is(err, errNoMachine):
+		record(db.ScheduleResultSkipped, "project has never been started", nil)
+		return
+	case errors.As(err, &exceeded):
+		log.Warn("schedule run over quota", "quota", exceeded.Quota, "plan", exceeded.Plan)
+		record(db.ScheduleResultFailed, exceeded.Error(), nil)
+		return
+	case err != nil:
+		log.Error("failed to run schedule", "error", err)
+		record(db.ScheduleResultFailed, err.Error(), nil)
+		return
+	}
+
+	log.Info("ran schedule", "operation_id", op.ID)
+	record(db.ScheduleResultTriggered, "", op)
+	if err := h.store.RecordProjectEvent(ctx, project.ID, db.EventScheduleRun, &notice, map[string]any{
+		"schedule_id":  s.ID,
+		"action":       s.Action,
+		"operation_id": op.ID,
+	}); err != nil {
+		log.Error("failed to record schedule event", "error", err)
+	}
+}
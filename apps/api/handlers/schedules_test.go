@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func (m *mockProjectStore) CreateSchedule(ctx context.Context, projectID, userID, action, cron, timezone string, enabled bool, nextRunAt *time.Time) (*db.Schedule, error) {
+	s := &db.Schedule{
+		ID:        uuid.NewString(),
+		ProjectID: projectID,
+		UserID:    userID,
+		Action:    action,
+		Cron:      cron,
+		Timezone:  timezone,
+		Enabled:   enabled,
+		NextRunAt: nextRunAt,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	m.schedules[s.ID] = s
+	return s, nil
+}
+
+func (m *mockProjectStore) ListSchedules(ctx context.Context, projectID string) ([]db.Schedule, error) {
+	var schedules []db.Schedule
+	for _, s := range m.schedules {
+		if s.ProjectID == projectID {
+			schedules = append(schedules, *s)
+		}
+	}
+	return schedules, nil
+}
+
+func (m *mockProjectStore) ListUserSchedules(ctx context.Context, userID string) ([]db.Schedule, error) {
+	var schedules []db.Schedule
+	for _, s := range m.schedules {
+		if s.UserID == userID {
+			schedules = append(schedules, *s)
+		}
+	}
+	return schedules, nil
+}
+
+func (m *mockProjectStore) GetSchedule(ctx context.Context, scheduleID, projectID string) (*db.Schedule, error) {
+	s, ok := m.schedules[scheduleID]
+	if !ok || s.ProjectID != projectID {
+		return nil, db.ErrNotFound
+	}
+	copied := *s
+	return &copied, nil
+}
+
+func (m *mockProjectStore) UpdateSchedule(ctx context.Context, scheduleID, projectID, action, cron, timezone string, enabled bool, nextRunAt *time.Time) (*db.Schedule, error) {
+	s, ok := m.schedules[scheduleID]
+	if !ok || s.ProjectID != projectID {
+		return nil, db.ErrNotFound
+	}
+	s.Action, s.Cron, s.Timezone, s.Enabled, s.NextRunAt = action, cron, timezone, enabled, nextRunAt
+	return s, nil
+}
+
+func (m *mockProjectStore) DeleteSchedule(ctx context.Context, scheduleID, projectID string) error {
+	if _, err := m.GetSchedule(ctx, scheduleID, projectID); err != nil {
+		return err
+	}
+	delete(m.schedules, scheduleID)
+	return nil
+}
+
+func (m *mockProjectStore) ListDueSchedules(ctx context.Context, now time.Time) ([]db.Schedule, error) {
+	var schedules []db.Schedule
+	for _, s := range m.schedules {
+		if s.Enabled && s.NextRunAt != nil && !s.NextRunAt.After(now) {
+			schedules = append(schedules, *s)
+		}
+	}
+	return schedules, nil
+}
+
+func (m *mockProjectStore) ClaimScheduleRun(ctx context.Context, scheduleID string, dueAt time.Time, nextRunAt *time.Time) (bool, error) {
+	s, ok := m.schedules[scheduleID]
+	if !ok || !s.Enabled || s.NextRunAt == nil || !s.NextRunAt.Equal(dueAt) {
+		return false, nil
+	}
+	now := time.Now()
+	s.NextRunAt, s.LastRunAt = nextRunAt, &now
+	return true, nil
+}
+
+func (m *mockProjectStore) RecordScheduleResult(ctx context.Context, scheduleID, result string, errorMsg, operationID *string) error {
+	if s, ok := m.schedules[scheduleID]; ok {
+		s.LastResult, s.LastError, s.LastOperationID = &result, errorMsg, operationID
+	}
+	return nil
+}
+
+func newScheduleRouter(handler *ProjectHandler) *chi.Mux {
+	router := chi.NewRouter()
+	router.Get("/projects/{id}", handler.Get)
+	router.Post("/projects/{id}/schedules", handler.CreateSchedule)
+	router.Patch("/projects/{id}/schedules/{scheduleId}", handler.UpdateSchedule)
+	router.Delete("/projects/{id}/schedules/{scheduleId}", handler.DeleteSchedule)
+	return router
+}
+
+func TestProjectHandler_CreateSchedule(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+	router := newScheduleRouter(handler)
+
+	req := newAuthenticatedRequest("POST", "/projects/"+testProjectID+"/schedules",
+		[]byte(`{"action":"start","cron":"0 9 * * mon-fri","timezone":"America/New_York"}`))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var created ScheduleResponse
+	if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !created.Enabled || created.NextRunAt == nil {
+		t.Fatalf("expected an enabled schedule with a next run, got %+v", created)
+	}
+	newYork, _ := time.LoadLocation("America/New_York")
+	if next := created.NextRunAt.In(newYork); next.Hour() != 9 || next.Minute() != 0 {
+		t.Errorf("expected next run at 9:00 New York time, got %v", next)
+	}
+
+	// The schedule shows up on the project
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, newAuthenticatedRequest("GET", "/projects/"+testProjectID, nil))
+	var project ProjectResponse
+	if err := json.NewDecoder(rr.Body).Decode(&project); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(project.Schedules) != 1 || project.Schedules[0].ID != created.ID {
+		t.Errorf("expected project to list the schedule, got %+v", project.Schedules)
+	}
+
+	// Disabling clears the next run
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, newAuthenticatedRequest("PATCH", "/projects/"+testProjectID+"/schedules/"+created.ID, []byte(`{"enabled":false}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if s := store.schedules[created.ID]; s.Enabled || s.NextRunAt != nil {
+		t.Errorf("expected disabled schedule without a next run, got %+v", s)
+	}
+}
+
+func TestProjectHandler_Schedule_IfMatch(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	store.projects[testProjectID].Version = 3
+	schedule, _ := store.CreateSchedule(context.Background(), testProjectID, "test-user-id", "start", "0 9 * * *", "UTC", true, nil)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+	router := newScheduleRouter(handler)
+	path := "/projects/" + testProjectID + "/schedules/" + schedule.ID
+
+	for _, method := range []string{"PATCH", "DELETE"} {
+		req := newAuthenticatedRequest(method, path, []byte(`{"enabled":false}`))
+		req.Header.Set("If-Match", `"2"`)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s: expected status %d, got %d: %s", method, http.StatusPreconditionFailed, rr.Code, rr.Body.String())
+		}
+	}
+	if s := store.schedules[schedule.ID]; s == nil || !s.Enabled {
+		t.Errorf("expected schedule untouched, got %+v", s)
+	}
+
+	req := newAuthenticatedRequest("DELETE", path, nil)
+	req.Header.Set("If-Match", `"3"`)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+}
+
+func TestProjectHandler_CreateSchedule_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "bad action", body: `{"action":"restart","cron":"0 9 * * *"}`},
+		{name: "bad cron", body: `{"action":"start","cron":"0 25 * * *"}`},
+		{name: "bad timezone", body: `{"action":"start","cron":"0 9 * * *","timezone":"Mars/Olympus"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newProjectFixture(db.StatusStopped)
+			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+			rr := httptest.NewRecorder()
+			newScheduleRouter(handler).ServeHTTP(rr, newAuthenticatedRequest("POST", "/projects/"+testProjectID+"/schedules", []byte(tt.body)))
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+			}
+			if len(store.schedules) != 0 {
+				t.Error("expected no schedule created")
+			}
+		})
+	}
+}
+
+func TestProjectHandler_RunDueSchedules(t *testing.T) {
+	now := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC) // Monday, 9:00 in New York
+
+	tests := []struct {
+		name       string
+		status     string
+		action     string
+		dueAt      time.Time
+		wantResult string
+		wantOp     string
+	}{
+		{name: "start stopped project", status: db.StatusStopped, action: db.ScheduleActionStart, dueAt: now, wantResult: db.ScheduleResultTriggered, wantOp: db.OperationStart},
+		{name: "stop running project", status: db.StatusRunning, action: db.ScheduleActionStop, dueAt: now, wantResult: db.ScheduleResultTriggered, wantOp: db.OperationStop},
+		{name: "start running project", status: db.StatusRunning, action: db.ScheduleActionStart, dueAt: now, wantResult: db.ScheduleResultSkipped},
+		{name: "missed run", status: db.StatusStopped, action: db.ScheduleActionStart, dueAt: now.Add(-time.Hour), wantResult: db.ScheduleResultMissed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newProjectFixture(tt.status)
+			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+			dueAt := tt.dueAt
+			s, _ := store.CreateSchedule(ctx, testProjectID, "test-user-id", tt.action, "0 9 * * *", "America/New_York", true, &dueAt)
+
+			handler.runDueSchedules(ctx, now)
+
+			if s.LastResult == nil || *s.LastResult != tt.wantResult {
+				t.Fatalf("expected result %s, got %v (error %v)", tt.wantResult, s.LastResult, s.LastError)
+			}
+			if want := time.Date(2026, 3, 3, 14, 0, 0, 0, time.UTC); s.NextRunAt == nil || !s.NextRunAt.Equal(want) {
+				t.Errorf("expected next run %v, got %v", want, s.NextRunAt)
+			}
+			if tt.wantOp == "" {
+				if s.LastOperationID != nil {
+					t.Errorf("expected no operation, got %s", *s.LastOperationID)
+				}
+				return
+			}
+			if s.LastOperationID == nil {
+				t.Fatal("expected an operation")
+			}
+			if op := store.operations[*s.LastOperationID]; op.Type != tt.wantOp {
+				t.Errorf("expected %s operation, got %s", tt.wantOp, op.Type)
+			}
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CreateSnapshotRequest struct {
+	Label *string `json:"label,omitempty"`
+}
+
+type SnapshotResponse struct {
+	ID        string     `json:"id"`
+	ProjectID string     `json:"project_id"`
+	Label     *string    `json:"label,omitempty"`
+	SizeBytes int64      `json:"size_bytes"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type RestoreSnapshotResponse struct {
+	Project     ProjectResponse `json:"project"`
+	OperationID string          `json:"operation_id"`
+}
+
+type SnapshotListResponse struct {
+	Snapshots []SnapshotResponse `json:"snapshots"`
+}
+
+func snapshotToResponse(s *db.Snapshot) SnapshotResponse {
+	return SnapshotResponse{
+		ID:        s.ID,
+		ProjectID: s.ProjectID,
+		Label:     s.Label,
+		SizeBytes: s.SizeBytes,
+		CreatedBy: s.CreatedBy,
+		CreatedAt: s.CreatedAt,
+		ExpiresAt: s.ExpiresAt,
+	}
+}
+
+// getSnapshotProject validates the route IDs and loads the caller's project,
+// on which their role must grant min. It writes the error response and returns
+// nil if anything fails.
+func (h *ProjectHandler) getSnapshotProject(w http.ResponseWriter, r *http.Request, withSnapshot bool, min string) *db.Project {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	var errs validation.ValidationErrors
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		errs = append(errs, *err)
+	}
+	if withSnapshot {
+		if err := validation.ValidateUUID(chi.URLParam(r, "snapshotId"), "snapshotId"); err != nil {
+			errs = append(errs, *err)
+		}
+	}
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return nil
+	}
+
+	project, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return nil
+		}
+		log.Error("failed to get project for snapshot", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get project")
+		return nil
+	}
+	if !allowProject(w, project, min) {
+		return nil
+	}
+	return project
+}
+
+// getSnapshot loads the snapshot named in the route, writing the error response on failure
+func (h *ProjectHandler) getSnapshot(w http.ResponseWriter, r *http.Request, projectID string) *db.Snapshot {
+	ctx := r.Context()
+	snapshotID := chi.URLParam(r, "snapshotId")
+
+	snapshot, err := h.store.GetSnapshot(ctx, snapshotID, projectID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Snapshot not found")
+			return nil
+		}
+		logging.FromContext(ctx).Error("failed to get snapshot", "snapshot_id", snapshotID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get snapshot")
+		return nil
+	}
+	return snapshot
+}
+
+// CreateSnapshot checkpoints the project's volume. Running projects are
+// snapshotted crash-consistently; stop the project first for a clean copy.
+func (h *ProjectHandler) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	project := h.getSnapshotProject(w, r, false, roleOperate)
+	if project == nil {
+		return
+	}
+
+	// The body is optional; snapshots don't need a label
+	var req CreateSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Label != nil {
+		if err := validation.ValidateSnapshotLabel(*req.Label); err != nil {
+			WriteJSON(w, http.StatusBadRequest, map[string]any{
+				"error":  "Validation failed",
+				"errors": []validation.ValidationError{*err},
+			})
+			return
+		}
+	}
+
+	if project.Status != db.StatusStopped && project.Status != db.StatusRunning {
+		WriteJSON(w, http.StatusConflict, map[string]any{
+			"error":          "Project can only be snapshotted while stopped or running",
+			"current_status": project.Status,
+		})
+		return
+	}
+	if project.FlyVolumeID == nil || *project.FlyVolumeID == "" {
+		WriteError(w, http.StatusConflict, "Project has no volume yet; start it once before taking a snapshot")
+		return
+	}
+
+	backendSnapshot, err := h.volumes.CreateSnapshot(*project.FlyVolumeID)
+	if err != nil {
+		log.Error("failed to create volume snapshot", "project_id", project.ID, "volume_id", *project.FlyVolumeID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to create snapshot")
+		return
+	}
+
+	snapshot, err := h.store.CreateSnapshot(ctx, project.ID, userID, *project.FlyVolumeID, backendSnapshot.ID,
+		req.Label, backendSnapshot.SizeBytes, db.SnapshotCreatedByUser, backendSnapshot.ExpiresAt)
+	if err != nil {
+		log.Error("failed to record snapshot", "project_id", project.ID, "error", err)
+		if err := h.volumes.DeleteSnapshot(backendSnapshot.ID); err != nil && !errors.Is(err, ErrSnapshotDeleteUnsupported) {
+			log.Error("failed to delete unrecorded snapshot", "snapshot_id", backendSnapshot.ID, "error", err)
+		}
+		WriteError(w, http.StatusInternalServerError, "Failed to create snapshot")
+		return
+	}
+
+	log.Info("snapshot created", "project_id", project.ID, "snapshot_id", snapshot.ID)
+	WriteJSON(w, http.StatusCreated, snapshotToResponse(snapshot))
+}
+
+func (h *ProjectHandler) ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	project := h.getSnapshotProject(w, r, false, db.RoleViewer)
+	if project == nil {
+		return
+	}
+
+	snapshots, err := h.store.ListSnapshots(ctx, project.ID)
+	if err != nil {
+		log.Error("failed to list snapshots", "project_id", project.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list snapshots")
+		return
+	}
+
+	response := SnapshotListResponse{Snapshots: make([]SnapshotResponse, len(snapshots))}
+	for i, s := range snapshots {
+		response.Snapshots[i] = snapshotToResponse(&s)
+	}
+
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// DeleteSnapshot removes a snapshot. Backends whose snapshots only expire
+// answer 501 with the snapshot's expiry instead.
+func (h *ProjectHandler) DeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	project := h.getSnapshotProject(w, r, true, roleManage)
+	if project == nil {
+		return
+	}
+	snapshot := h.getSnapshot(w, r, project.ID)
+	if snapshot == nil {
+		return
+	}
+
+	if err := h.volumes.DeleteSnapshot(snapshot.ProviderSnapshotID); err != nil {
+		if errors.Is(err, ErrSnapshotDeleteUnsupported) {
+			WriteJSON(w, http.StatusNotImplemented, map[string]any{
+				"error":      "Snapshots can't be deleted on this backend; they expire after the retention period",
+				"expires_at": snapshot.ExpiresAt,
+			})
+			return
+		}
+		log.Error("failed to delete volume snapshot", "snapshot_id", snapshot.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to delete snapshot")
+		return
+	}
+
+	if err := h.store.DeleteSnapshot(ctx, snapshot.ID, project.ID); err != nil && !errors.Is(err, db.ErrNotFound) {
+		log.Error("failed to delete snapshot", "snapshot_id", snapshot.ID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to delete snapshot")
+		return
+	}
+
+	log.Info("snapshot deleted", "project_id", project.ID, "snapshot_id", snapshot.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreSnapshot replaces the project's volume contents with a snapshot.
+// The project must be stopped; it is held in restoring while an operation
+// swaps the volume, so the restore finishes even if this request doesn't.
+func (h *ProjectHandler) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.FromContext(ctx)
+
+	project := h.getSnapshotProject(w, r, true, roleManage)
+	if project == nil {
+		return
+	}
+	snapshot := h.getSnapshot(w, r, project.ID)
+	if snapshot == nil {
+		return
+	}
+
+	if project.FlyVolumeID == nil || *project.FlyVolumeID == "" {
+		WriteError(w, http.StatusConflict, "Project has no volume to restore into")
+		return
+	}
+
+	params := map[string]string{
+		restoreParamSnapshot:         snapshot.ID,
+		restoreParamProviderSnapshot: snapshot.ProviderSnapshotID,
+		restoreParamOldVolume:        *project.FlyVolumeID,
+	}
+	op, err := h.store.BeginOperationWithParams(ctx, project.ID, project.UserID, db.OperationRestore, params, []string{db.StatusStopped}, db.StatusRestoring)
+	if err != nil {
+		h.writeTransitionError(w, log, err, "restore")
+		return
+	}
+	log.Info("restoring snapshot", "project_id", project.ID, "snapshot_id", snapshot.ID, "operation_id", op.ID)
+
+	project.Status = db.StatusRestoring
+	response := RestoreSnapshotResponse{Project: projectToResponse(project), OperationID: op.ID}
+	h.dispatchOperation(op)
+
+	WriteJSON(w, http.StatusAccepted, response)
+}
+
+// Restore operation params
+const (
+	restoreParamSnapshot         = "snapshot_id"
+	restoreParamProviderSnapshot = "provider_snapshot_id"
+	restoreParamOldVolume        = "old_volume_id"
+	restoreParamNewVolume        = "new_volume_id"
+)
+
+// restoreSnapshotAsync runs a restore operation. Backends that restore into a
+// new volume need the project moved onto it; the old machine mounts the old
+// volume, so it is destroyed and recreated on next start. The restored volume
+// is recorded on the operation as soon as it exists, so a resumed attempt
+// finishes the swap instead of restoring again.
+func (h *ProjectHandler) restoreSnapshotAsync(ctx context.Context, op *db.Operation, project *db.Project) error {
+	projectID := project.ID
+	log := logging.Default().With("project_id", projectID, "operation_id", op.ID)
+	oldVolumeID := op.Params[restoreParamOldVolume]
+	newVolumeID := op.Params[restoreParamNewVolume]
+	if oldVolumeID == "" || op.Params[restoreParamProviderSnapshot] == "" {
+		return h.failProject(ctx, log, projectID, "Restore operation is missing its snapshot")
+	}
+
+	if newVolumeID == "" {
+		if err := h.setOperationStep(ctx, op, "restore_volume"); err != nil {
+			return err
+		}
+		stop := h.keepOperationAlive(ctx, op)
+		volume, err := h.volumes.RestoreSnapshot(oldVolumeID, op.Params[restoreParamProviderSnapshot], "vol_"+projectID[:8])
+		stop()
+		if err != nil {
+			// Nothing was swapped, so the project is as it was
+			log.Error("failed to restore snapshot", "snapshot_id", op.Params[restoreParamSnapshot], "error", err)
+			return h.abortRestore(ctx, log, projectID, "", "failed to restore snapshot: "+err.Error())
+		}
+		newVolumeID = volume.ID
+		if err := h.store.SetOperationParams(ctx, op.ID, h.workerID, map[string]string{restoreParamNewVolume: newVolumeID}); err != nil {
+			return err
+		}
+		op.Params[restoreParamNewVolume] = newVolumeID
+	}
+
+	if newVolumeID != oldVolumeID {
+		if project.FlyMachineID != nil && *project.FlyMachineID != "" {
+			if err := h.setOperationStep(ctx, op, "delete_machine"); err != nil {
+				return err
+			}
+			if err := h.stopAndDeleteMachine(*project.FlyMachineID); err != nil {
+				log.Error("failed to delete machine for restore", "machine_id", *project.FlyMachineID, "error", err)
+				return h.abortRestore(ctx, log, projectID, newVolumeID, "failed to delete machine: "+err.Error())
+			}
+			if _, err := h.store.ClearProjectMachine(ctx, projectID, *project.FlyMachineID); err != nil {
+				log.Error("failed to clear project machine", "error", err)
+			}
+		}
+
+		if err := h.setOperationStep(ctx, op, "attach_volume"); err != nil {
+			return err
+		}
+		if err := h.store.UpdateProjectVolume(ctx, projectID, newVolumeID); err != nil {
+			log.Error("failed to update project volume ID", "volume_id", newVolumeID, "error", err)
+			return h.failProject(ctx, log, projectID, "Failed to attach restored volume: "+err.Error())
+		}
+
+		// The reconciler collects the old volume if this fails
+		if err := h.volumes.DeleteVolume(oldVolumeID); err != nil {
+			log.Error("failed to delete replaced volume", "volume_id", oldVolumeID, "error", err)
+		}
+	}
+
+	if err := h.completeTransition(ctx, projectID, db.StatusRestoring, db.StatusStopped); err != nil {
+		log.Error("failed to update project status", "error", err)
+		return err
+	}
+	if err := h.store.RecordProjectEvent(ctx, projectID, db.EventSnapshotRestored, nil, map[string]any{"snapshot_id": op.Params[restoreParamSnapshot]}); err != nil {
+		log.Error("failed to record restore event", "error", err)
+	}
+	log.Info("snapshot restored", "snapshot_id", op.Params[restoreParamSnapshot])
+	return nil
+}
+
+// abortRestore deletes the volume a restore created, returns the project to
+// stopped on its old volume, and returns the reason as an error
+func (h *ProjectHandler) abortRestore(ctx context.Context, log *logging.Logger, projectID, restoredVolumeID, reason string) error {
+	if restoredVolumeID != "" {
+		if err := h.volumes.DeleteVolume(restoredVolumeID); err != nil {
+			log.Error("failed to delete restored volume", "volume_id", restoredVolumeID, "error", err)
+		}
+	}
+	if err := h.completeTransition(ctx, projectID, db.StatusRestoring, db.StatusStopped); err != nil {
+		log.Error("failed to update project status", "error", err)
+	}
+	return fmt.Errorf("restore aborted: %s", reason)
+}
+
+// StartSnapshotCleanup starts a background goroutine that deletes the records
+// of snapshots the backend has discarded
+func (h *ProjectHandler) StartSnapshotCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			deleted, err := h.store.DeleteExpiredSnapshots(context.Background())
+			if err != nil {
+				logging.Default().Error("failed to delete expired snapshots", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				logging.Default().Debug("deleted expired snapshots", "count", deleted)
+			}
+		}
+	}()
+}
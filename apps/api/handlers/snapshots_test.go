@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+
+	"github.com/google/uuid"
+)
+
+func (m *mockProjectStore) CreateSnapshot(ctx context.Context, projectID, userID, volumeID, providerSnapshotID string, label *string, sizeBytes int64, createdBy string, expiresAt *time.Time) (*db.Snapshot, error) {
+	s := &db.Snapshot{
+		ID:                 uuid.NewString(),
+		ProjectID:          projectID,
+		UserID:             userID,
+		Label:              label,
+		VolumeID:           volumeID,
+		ProviderSnapshotID: providerSnapshotID,
+		SizeBytes:          sizeBytes,
+		CreatedBy:          createdBy,
+		CreatedAt:          time.Now(),
+		ExpiresAt:          expiresAt,
+	}
+	m.snapshots[s.ID] = s
+	return s, nil
+}
+
+func (m *mockProjectStore) ListSnapshots(ctx context.Context, projectID string) ([]db.Snapshot, error) {
+	var snapshots []db.Snapshot
+	for _, s := range m.snapshots {
+		if s.ProjectID == projectID && !snapshotExpired(s) {
+			snapshots = append(snapshots, *s)
+		}
+	}
+	return snapshots, nil
+}
+
+func (m *mockProjectStore) GetSnapshot(ctx context.Context, snapshotID, projectID string) (*db.Snapshot, error) {
+	s, ok := m.snapshots[snapshotID]
+	if !ok || s.ProjectID != projectID || snapshotExpired(s) {
+		return nil, db.ErrNotFound
+	}
+	return s, nil
+}
+
+func (m *mockProjectStore) DeleteSnapshot(ctx context.Context, snapshotID, projectID string) error {
+	if _, err := m.GetSnapshot(ctx, snapshotID, projectID); err != nil {
+		return err
+	}
+	delete(m.snapshots, snapshotID)
+	return nil
+}
+
+func (m *mockProjectStore) DeleteExpiredSnapshots(ctx context.Context) (int64, error) {
+	var deleted int64
+	for id, s := range m.snapshots {
+		if snapshotExpired(s) {
+			delete(m.snapshots, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func snapshotExpired(s *db.Snapshot) bool {
+	return s.ExpiresAt != nil && !s.ExpiresAt.After(time.Now())
+}
+
+const snapshotID = "770e8400-e29b-41d4-a716-446655440000"
+
+// newSnapshotFixture returns the project fixture with one snapshot of its volume
+func newSnapshotFixture(status string) *mockProjectStore {
+	store := newProjectFixture(status)
+	store.snapshots[snapshotID] = &db.Snapshot{
+		ID:                 snapshotID,
+		ProjectID:          testProjectID,
+		UserID:             "test-user-id",
+		VolumeID:           "vol-123",
+		ProviderSnapshotID: "snap-provider",
+		CreatedBy:          db.SnapshotCreatedByUser,
+	}
+	return store
+}
+
+func TestProjectHandler_CreateSnapshot(t *testing.T) {
+	store := newSnapshotFixture(db.StatusRunning)
+	expiresAt := time.Now().Add(5 * 24 * time.Hour).Truncate(time.Second)
+	volumes := newMockVolumeManager()
+	volumes.createSnapshotFn = func(volumeID string) (*Snapshot, error) {
+		if volumeID != "vol-123" {
+			t.Errorf("expected snapshot of vol-123, got %s", volumeID)
+		}
+		return &Snapshot{ID: "snap-new", VolumeID: volumeID, SizeBytes: 4096, ExpiresAt: &expiresAt}, nil
+	}
+	handler := NewProjectHandler(store, newMockMachineManager(), volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.CreateSnapshot, "POST", "/projects/{id}/snapshots", "/projects/"+testProjectID+"/snapshots", []byte(`{"label":"before refactor"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var response SnapshotResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Label == nil || *response.Label != "before refactor" {
+		t.Errorf("expected label to round-trip, got %v", response.Label)
+	}
+	if response.SizeBytes != 4096 || response.CreatedBy != db.SnapshotCreatedByUser {
+		t.Errorf("unexpected snapshot metadata: %+v", response)
+	}
+	if response.ExpiresAt == nil || !response.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("expected backend expiry %v, got %v", expiresAt, response.ExpiresAt)
+	}
+	if s := store.snapshots[response.ID]; s == nil || s.ProviderSnapshotID != "snap-new" {
+		t.Errorf("expected provider snapshot recorded, got %+v", s)
+	}
+}
+
+func TestProjectHandler_ListSnapshots_HidesExpired(t *testing.T) {
+	store := newSnapshotFixture(db.StatusStopped)
+	expired := time.Now().Add(-time.Minute)
+	store.snapshots["880e8400-e29b-41d4-a716-446655440000"] = &db.Snapshot{
+		ID:                 "880e8400-e29b-41d4-a716-446655440000",
+		ProjectID:          testProjectID,
+		VolumeID:           "vol-123",
+		ProviderSnapshotID: "snap-expired",
+		CreatedBy:          db.SnapshotCreatedByAutomatic,
+		ExpiresAt:          &expired,
+	}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.ListSnapshots, "GET", "/projects/{id}/snapshots", "/projects/"+testProjectID+"/snapshots", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response SnapshotListResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Snapshots) != 1 || response.Snapshots[0].ID != snapshotID {
+		t.Errorf("expected only the unexpired snapshot, got %+v", response.Snapshots)
+	}
+}
+
+func TestProjectHandler_DeleteSnapshot(t *testing.T) {
+	store := newSnapshotFixture(db.StatusStopped)
+	volumes := newMockVolumeManager()
+	var deleted string
+	volumes.deleteSnapshotFn = func(id string) error {
+		deleted = id
+		return nil
+	}
+	handler := NewProjectHandler(store, newMockMachineManager(), volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.DeleteSnapshot, "DELETE", "/projects/{id}/snapshots/{snapshotId}", "/projects/"+testProjectID+"/snapshots/"+snapshotID, nil)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if deleted != "snap-provider" {
+		t.Errorf("expected backend snapshot deleted, got %q", deleted)
+	}
+	if _, ok := store.snapshots[snapshotID]; ok {
+		t.Error("expected snapshot row deleted")
+	}
+}
+
+func TestProjectHandler_DeleteSnapshot_Unsupported(t *testing.T) {
+	store := newSnapshotFixture(db.StatusStopped)
+	volumes := newMockVolumeManager()
+	volumes.deleteSnapshotFn = func(id string) error {
+		return ErrSnapshotDeleteUnsupported
+	}
+	handler := NewProjectHandler(store, newMockMachineManager(), volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.DeleteSnapshot, "DELETE", "/projects/{id}/snapshots/{snapshotId}", "/projects/"+testProjectID+"/snapshots/"+snapshotID, nil)
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNotImplemented, rr.Code, rr.Body.String())
+	}
+	if _, ok := store.snapshots[snapshotID]; !ok {
+		t.Error("expected snapshot row kept while the backend still holds the snapshot")
+	}
+}
+
+func TestProjectHandler_RestoreSnapshot(t *testing.T) {
+	store := newSnapshotFixture(db.StatusStopped)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.RestoreSnapshot, "POST", "/projects/{id}/snapshots/{snapshotId}/restore", "/projects/"+testProjectID+"/snapshots/"+snapshotID+"/restore", nil)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+
+	var response RestoreSnapshotResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.OperationID == "" || response.Project.Status != db.StatusRestoring {
+		t.Errorf("expected a restore operation with the project restoring, got %q and %s", response.OperationID, response.Project.Status)
+	}
+}
+
+func TestProjectHandler_RestoreSnapshot_RunsAsOwner(t *testing.T) {
+	store := newOrgFixture(db.RoleAdmin)
+	store.snapshots = newSnapshotFixture(db.StatusStopped).snapshots
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.RestoreSnapshot, "POST", "/projects/{id}/snapshots/{snapshotId}/restore", "/projects/"+testProjectID+"/snapshots/"+snapshotID+"/restore", nil)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+	var response RestoreSnapshotResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	store.opsMu.Lock()
+	defer store.opsMu.Unlock()
+	if op := store.operations[response.OperationID]; op == nil || op.UserID != "owner-id" {
+		t.Errorf("expected the restore to run as the project's owner, got %+v", op)
+	}
+}
+
+// runRestore begins a restore of the fixture snapshot and runs it to completion
+func runRestore(t *testing.T, handler *ProjectHandler, store *mockProjectStore, params map[string]string) *db.Operation {
+	t.Helper()
+	ctx := context.Background()
+	if params == nil {
+		params = map[string]string{restoreParamSnapshot: snapshotID, restoreParamProviderSnapshot: "snap-provider", restoreParamOldVolume: "vol-123"}
+	}
+	op, err := store.BeginOperationWithParams(ctx, testProjectID, "test-user-id", db.OperationRestore, params, []string{db.StatusStopped}, db.StatusRestoring)
+	if err != nil {
+		t.Fatalf("failed to begin restore: %v", err)
+	}
+	claimed, err := store.ClaimOperation(ctx, op.ID, handler.workerID)
+	if err != nil {
+		t.Fatalf("failed to claim restore: %v", err)
+	}
+	handler.runOperation(ctx, claimed)
+	done, _ := store.GetOperation(ctx, op.ID, testProjectID)
+	return done
+}
+
+func TestProjectHandler_RestoreOperation_NewVolume(t *testing.T) {
+	store := newSnapshotFixture(db.StatusStopped)
+	machines := newMockMachineManager()
+	var deletedMachine string
+	machines.deleteFn = func(machineID string) error {
+		deletedMachine = machineID
+		return nil
+	}
+	volumes := newMockVolumeManager()
+	volumes.restoreSnapshotFn = func(volumeID, snapshotID, name string) (*Volume, error) {
+		if snapshotID != "snap-provider" {
+			t.Errorf("expected provider snapshot ID, got %s", snapshotID)
+		}
+		return &Volume{ID: "vol-restored", Name: name}, nil
+	}
+	var deletedVolume string
+	volumes.deleteFn = func(volumeID string) error {
+		deletedVolume = volumeID
+		return nil
+	}
+	handler := NewProjectHandler(store, machines, volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	if op := runRestore(t, handler, store, nil); op.Status != db.OperationSucceeded {
+		t.Fatalf("expected the restore to succeed, got %s: %v", op.Status, op.LastError)
+	}
+
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusStopped {
+		t.Errorf("expected project back to stopped, got %s", p.Status)
+	}
+	if p.FlyVolumeID == nil || *p.FlyVolumeID != "vol-restored" {
+		t.Errorf("expected project on restored volume, got %v", p.FlyVolumeID)
+	}
+	if p.FlyMachineID != nil || deletedMachine != "machine-123" {
+		t.Errorf("expected old machine destroyed and cleared, got %v (deleted %q)", p.FlyMachineID, deletedMachine)
+	}
+	if deletedVolume != "vol-123" {
+		t.Errorf("expected old volume deleted, got %q", deletedVolume)
+	}
+}
+
+func TestProjectHandler_RestoreOperation_ResumesAfterRestore(t *testing.T) {
+	store := newSnapshotFixture(db.StatusStopped)
+	volumes := newMockVolumeManager()
+	volumes.restoreSnapshotFn = func(volumeID, snapshotID, name string) (*Volume, error) {
+		t.Error("snapshot restored again on resume")
+		return nil, errors.New("already restored")
+	}
+	handler := NewProjectHandler(store, newMockMachineManager(), volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	// An earlier attempt restored into a new volume, then died before attaching it
+	params := map[string]string{restoreParamSnapshot: snapshotID, restoreParamProviderSnapshot: "snap-provider", restoreParamOldVolume: "vol-123", restoreParamNewVolume: "vol-restored"}
+	if op := runRestore(t, handler, store, params); op.Status != db.OperationSucceeded {
+		t.Fatalf("expected the resumed restore to succeed, got %s: %v", op.Status, op.LastError)
+	}
+	if p := store.projects[testProjectID]; p.Status != db.StatusStopped || *p.FlyVolumeID != "vol-restored" || p.FlyMachineID != nil {
+		t.Errorf("expected stopped on vol-restored without a machine, got %s on %s", p.Status, *p.FlyVolumeID)
+	}
+}
+
+func TestProjectHandler_RestoreOperation_FailureLeavesProjectStopped(t *testing.T) {
+	store := newSnapshotFixture(db.StatusStopped)
+	volumes := newMockVolumeManager()
+	volumes.restoreSnapshotFn = func(volumeID, snapshotID, name string) (*Volume, error) {
+		return nil, errors.New("restore failed")
+	}
+	handler := NewProjectHandler(store, newMockMachineManager(), volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	if op := runRestore(t, handler, store, nil); op.Status != db.OperationFailed {
+		t.Fatalf("expected the restore to fail, got %s", op.Status)
+	}
+	if p := store.projects[testProjectID]; p.Status != db.StatusStopped || *p.FlyVolumeID != "vol-123" {
+		t.Errorf("expected project untouched, got status=%s volume=%s", p.Status, *p.FlyVolumeID)
+	}
+}
+
+func TestProjectHandler_RestoreSnapshot_RequiresStopped(t *testing.T) {
+	store := newSnapshotFixture(db.StatusRunning)
+	volumes := newMockVolumeManager()
+	volumes.restoreSnapshotFn = func(volumeID, snapshotID, name string) (*Volume, error) {
+		t.Error("restored a running project")
+		return nil, nil
+	}
+	handler := NewProjectHandler(store, newMockMachineManager(), volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.RestoreSnapshot, "POST", "/projects/{id}/snapshots/{snapshotId}/restore", "/projects/"+testProjectID+"/snapshots/"+snapshotID+"/restore", nil)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+}
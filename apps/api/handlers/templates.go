@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	projectDir = "/home/coder/workspace/project"
+	// postCreateLog is where the detached post-create script writes its output
+	postCreateLog = "/home/coder/workspace/.aether/post-create.log"
+	// templateExecTimeout bounds the clone and file writes, well inside the
+	// window after which a stalled operation is picked up by another replica
+	templateExecTimeout = 60 * time.Second
+)
+
+func templateText(s string) *string { return &s }
+
+// builtInTemplates are available to every user. They run on the platform base
+// image, which ships Node.js and Python.
+var builtInTemplates = []db.Template{
+	{
+		ID:          "blank",
+		Name:        "Blank",
+		Description: templateText("An empty workspace"),
+		EnvVars:     map[string]string{},
+		Ports:       []int{},
+		Files:       map[string]string{},
+		BuiltIn:     true,
+	},
+	{
+		ID:             "node",
+		Name:           "Node.js",
+		Description:    templateText("A minimal Node.js HTTP server on port 3000"),
+		HardwarePreset: templateText("small"),
+		EnvVars:        map[string]string{"NODE_ENV": "development", "PORT": "3000"},
+		Ports:          []int{3000},
+		Files: map[string]string{
+			"package.json": `{
+  "name": "app",
+  "version": "0.1.0",
+  "private": true,
+  "scripts": {
+    "start": "node index.js"
+  }
+}
+`,
+			"index.js": `const http = require("http");
+
+const port = process.env.PORT || 3000;
+
+http
+  .createServer((req, res) => {
+    res.writeHead(200, { "Content-Type": "text/plain" });
+    res.end("Hello from Aether\n");
+  })
+  .listen(port, () => console.log("Listening on port " + port));
+`,
+			".gitignore": "node_modules/\n",
+		},
+		PostCreateScript: templateText("npm install"),
+		BuiltIn:          true,
+	},
+	{
+		ID:             "python",
+		Name:           "Python",
+		Description:    templateText("A Python virtualenv with a minimal HTTP server on port 8000"),
+		HardwarePreset: templateText("small"),
+		EnvVars:        map[string]string{"PORT": "8000", "PYTHONUNBUFFERED": "1"},
+		Ports:          []int{8000},
+		Files: map[string]string{
+			"requirements.txt": "",
+			"main.py": `import os
+from http.server import BaseHTTPRequestHandler, HTTPServer
+
+
+class Handler(BaseHTTPRequestHandler):
+    def do_GET(self):
+        self.send_response(200)
+        self.send_header("Content-Type", "text/plain")
+        self.end_headers()
+        self.wfile.write(b"Hello from Aether\n")
+
+
+port = int(os.environ.get("PORT", "8000"))
+print(f"Listening on port {port}")
+HTTPServer(("", port), Handler).serve_forever()
+`,
+			".gitignore": ".venv/\n__pycache__/\n",
+		},
+		PostCreateScript: templateText("python3 -m venv .venv && .venv/bin/pip install -r requirements.txt"),
+		BuiltIn:          true,
+	},
+}
+
+// TemplateGetter looks up a user's stored templates
+type TemplateGetter interface {
+	GetTemplate(ctx context.Context, templateID, userID string) (*db.Template, error)
+}
+
+// TemplateStore defines the database operations needed by TemplateHandler
+type TemplateStore interface {
+	TemplateGetter
+	ListTemplates(ctx context.Context, userID string) ([]db.Template, error)
+	CreateTemplate(ctx context.Context, userID string, t *db.Template) (*db.Template, error)
+	DeleteTemplate(ctx context.Context, templateID, userID string) error
+}
+
+// findTemplate resolves a built-in template name or the ID of one of the user's templates
+func findTemplate(ctx context.Context, store TemplateGetter, templateID, userID string) (*db.Template, error) {
+	for i := range builtInTemplates {
+		if builtInTemplates[i].ID == templateID {
+			t := builtInTemplates[i]
+			return &t, nil
+		}
+	}
+	if _, err := uuid.Parse(templateID); err != nil {
+		return nil, db.ErrNotFound
+	}
+	return store.GetTemplate(ctx, templateID, userID)
+}
+
+// TemplateHandler serves the built-in and user-defined project templates
+type TemplateHandler struct {
+	store TemplateStore
+}
+
+func NewTemplateHandler(store TemplateStore) *TemplateHandler {
+	return &TemplateHandler{store: store}
+}
+
+type CreateTemplateRequest struct {
+	Name             string            `json:"name"`
+	Description      string            `json:"description,omitempty"`
+	BaseImage        string            `json:"base_image,omitempty"`
+	HardwarePreset   string            `json:"hardware_preset,omitempty"`
+	EnvVars          map[string]string `json:"env_vars,omitempty"`
+	Ports            []int             `json:"ports,omitempty"`
+	Files            map[string]string `json:"files,omitempty"`
+	GitURL           string            `json:"git_url,omitempty"`
+	PostCreateScript string            `json:"post_create_script,omitempty"`
+}
+
+type TemplateResponse struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Description      *string           `json:"description,omitempty"`
+	BaseImage        *string           `json:"base_image,omitempty"`
+	HardwarePreset   *string           `json:"hardware_preset,omitempty"`
+	EnvVars          map[string]string `json:"env_vars"`
+	Ports            []int             `json:"ports"`
+	Files            map[string]string `json:"files"`
+	GitURL           *string           `json:"git_url,omitempty"`
+	PostCreateScript *string           `json:"post_create_script,omitempty"`
+	BuiltIn          bool              `json:"built_in"`
+	CreatedAt        *time.Time        `json:"created_at,omitempty"`
+}
+
+type TemplateListResponse struct {
+	Templates []TemplateResponse `json:"templates"`
+}
+
+func templateToResponse(t *db.Template) TemplateResponse {
+	response := TemplateResponse{
+		ID:               t.ID,
+		Name:             t.Name,
+		Description:      t.Description,
+		BaseImage:        t.BaseImage,
+		HardwarePreset:   t.HardwarePreset,
+		EnvVars:          t.EnvVars,
+		Ports:            t.Ports,
+		Files:            t.Files,
+		GitURL:           t.GitURL,
+		PostCreateScript: t.PostCreateScript,
+		BuiltIn:          t.BuiltIn,
+	}
+	if !t.BuiltIn {
+		response.CreatedAt = &t.CreatedAt
+	}
+	return response
+}
+
+// List returns the built-in templates followed by the user's own
+func (h *TemplateHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	templates, err := h.store.ListTemplates(ctx, userID)
+	if err != nil {
+		log.Error("failed to list templates", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list templates")
+		return
+	}
+
+	response := TemplateListResponse{Templates: make([]TemplateResponse, 0, len(builtInTemplates)+len(templates))}
+	for i := range builtInTemplates {
+		response.Templates = append(response.Templates, templateToResponse(&builtInTemplates[i]))
+	}
+	for i := range templates {
+		response.Templates = append(response.Templates, templateToResponse(&templates[i]))
+	}
+
+	WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *TemplateHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	templateID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	template, err := findTemplate(ctx, h.store, templateID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Template not found")
+			return
+		}
+		log.Error("failed to get template", "template_id", templateID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get template")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, templateToResponse(template))
+}
+
+func (h *TemplateHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	var req CreateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	input, errs := validation.ValidateCreateTemplate(req.Name, req.Description, req.BaseImage, req.HardwarePreset,
+		req.EnvVars, req.Ports, req.Files, req.GitURL, req.PostCreateScript)
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+
+	template, err := h.store.CreateTemplate(ctx, userID, &db.Template{
+		Name:             input.Name,
+		Description:      input.Description,
+		BaseImage:        input.BaseImage,
+		HardwarePreset:   input.HardwarePreset,
+		EnvVars:          input.EnvVars,
+		Ports:            input.Ports,
+		Files:            input.Files,
+		GitURL:           input.GitURL,
+		PostCreateScript: input.PostCreateScript,
+	})
+	if err != nil {
+		log.Error("failed to create template", "name", input.Name, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to create template")
+		return
+	}
+
+	log.Info("template created", "template_id", template.ID)
+	WriteJSON(w, http.StatusCreated, templateToResponse(template))
+}
+
+// Delete removes a user-defined template. Projects created from it are unaffected.
+func (h *TemplateHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	templateID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(templateID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	if err := h.store.DeleteTemplate(ctx, templateID, userID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Template not found")
+			return
+		}
+		log.Error("failed to delete template", "template_id", templateID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to delete template")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyTemplate runs a template's first-boot setup on the project's started machine.
+// A failed setup is reported as an event but leaves the workspace usable, and is
+// not retried.
+func (h *ProjectHandler) applyTemplate(ctx context.Context, log *logging.Logger, project *db.Project) {
+	script := templateSetupScript(project.TemplateSetup)
+	// -E keeps the machine env (template and user env vars) for the post-create script
+	command := []string{"sudo", "-E", "-u", "coder", "-H", "bash", "-c", script}
+
+	result, err := h.machines.Exec(*project.FlyMachineID, command, templateExecTimeout)
+	switch {
+	case err != nil:
+		log.Error("failed to apply template", "error", err)
+		h.recordTemplateEvent(ctx, log, project, db.EventTemplateFailed, "Template setup failed: "+err.Error())
+	case result.ExitCode != 0:
+		log.Warn("template setup exited with error", "exit_code", result.ExitCode, "stderr", result.Stderr)
+		h.recordTemplateEvent(ctx, log, project, db.EventTemplateFailed, "Template setup failed: "+lastLine(result.Stderr))
+	default:
+		log.Info("applied template", "template_id", project.TemplateID)
+		h.recordTemplateEvent(ctx, log, project, db.EventTemplateApplied, "")
+	}
+
+	if err := h.store.ClearProjectTemplateSetup(ctx, project.ID); err != nil {
+		log.Error("failed to clear template setup", "error", err)
+	}
+	project.TemplateSetup = nil
+}
+
+func (h *ProjectHandler) recordTemplateEvent(ctx context.Context, log *logging.Logger, project *db.Project, eventType, message string) {
+	data := map[string]any{}
+	if project.TemplateID != nil {
+		data["template_id"] = *project.TemplateID
+	}
+	var msg *string
+	if message != "" {
+		msg = &message
+	}
+	if err := h.store.RecordProjectEvent(ctx, project.ID, eventType, msg, data); err != nil {
+		log.Error("failed to record template event", "error", err)
+	}
+}
+
+// lastLine returns the last non-empty line of command output, which usually holds the error
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	line := lines[len(lines)-1]
+	if line == "" {
+		return "setup script exited with an error"
+	}
+	return line
+}
+
+// templateSetupScript builds the bash script that applies a template inside the
+// workspace. Each step is safe to re-run if a resumed operation repeats it.
+func templateSetupScript(setup *db.TemplateSetup) string {
+	var sb strings.Builder
+	sb.WriteString("set -euo pipefail\n")
+	sb.WriteString("mkdir -p " + shellQuote(projectDir) + "\n")
+	sb.WriteString("cd " + shellQuote(projectDir) + "\n")
+
+	// Clone next to the project and copy in, since git won't clone into the
+	// mount point's existing directory. Skipped once the project has content.
+	if setup.GitURL != nil {
+		sb.WriteString("if [ -z \"$(ls -A)\" ]; then\n")
+		sb.WriteString("  tmp=$(mktemp -d)\n")
+		sb.WriteString("  GIT_TERMINAL_PROMPT=0 git clone --quiet " + shellQuote(*setup.GitURL) + " \"$tmp/repo\"\n")
+		sb.WriteString("  cp -a \"$tmp/repo/.\" .\n")
+		sb.WriteString("  rm -rf \"$tmp\"\n")
+		sb.WriteString("fi\n")
+	}
+
+	// Template files are layered over the cloned repository
+	paths := make([]string, 0, len(setup.Files))
+	for path := range setup.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		content := base64.StdEncoding.EncodeToString([]byte(setup.Files[path]))
+		sb.WriteString("mkdir -p \"$(dirname " + shellQuote(path) + ")\"\n")
+		sb.WriteString("echo " + shellQuote(content) + " | base64 -d > " + shellQuote(path) + "\n")
+	}
+
+	// The post-create script can run for minutes (dependency installs), so it is
+	// started detached and its log left in the workspace
+	if setup.PostCreateScript != nil {
+		logDir := postCreateLog[:strings.LastIndex(postCreateLog, "/")]
+		sb.WriteString("if [ ! -e " + shellQuote(postCreateLog) + " ]; then\n")
+		sb.WriteString("  mkdir -p " + shellQuote(logDir) + "\n")
+		sb.WriteString("  setsid nohup bash -lc " + shellQuote(*setup.PostCreateScript) + " > " + shellQuote(postCreateLog) + " 2>&1 < /dev/null &\n")
+		sb.WriteString("fi\n")
+	}
+
+	return sb.String()
+}
+
+// shellQuote quotes s as a single bash word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+func (m *mockProjectStore) GetTemplate(ctx context.Context, templateID, userID string) (*db.Template, error) {
+	if t, ok := m.templates[templateID]; ok && t.UserID == userID {
+		return t, nil
+	}
+	return nil, db.ErrNotFound
+}
+
+func (m *mockProjectStore) ListTemplates(ctx context.Context, userID string) ([]db.Template, error) {
+	var result []db.Template
+	for _, t := range m.templates {
+		if t.UserID == userID {
+			result = append(result, *t)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProjectStore) CreateTemplate(ctx context.Context, userID string, t *db.Template) (*db.Template, error) {
+	created := *t
+	created.ID = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	created.UserID = userID
+	created.CreatedAt = time.Now()
+	m.templates[created.ID] = &created
+	return &created, nil
+}
+
+func (m *mockProjectStore) DeleteTemplate(ctx context.Context, templateID, userID string) error {
+	if t, ok := m.templates[templateID]; ok && t.UserID == userID {
+		delete(m.templates, templateID)
+		return nil
+	}
+	return db.ErrNotFound
+}
+
+func (m *mockProjectStore) ClearProjectTemplateSetup(ctx context.Context, projectID string) error {
+	if p, ok := m.projects[projectID]; ok {
+		p.TemplateSetup = nil
+	}
+	return nil
+}
+
+func TestProjectHandler_CreateFromTemplate(t *testing.T) {
+	userTemplateID := "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	image := "ghcr.io/acme/rust:1"
+	large := "large"
+
+	tests := []struct {
+		name          string
+		body          string
+		wantStatus    int
+		wantTemplate  string
+		wantCPUs      int
+		wantPorts     []int
+		wantImage     *string
+		wantSetup     bool
+		otherTemplate bool
+	}{
+		{"built-in template", `{"name":"app","template_id":"node"}`, http.StatusCreated, "node", 1, []int{3000}, nil, true, false},
+		{"user template preset", `{"name":"app","template_id":"` + userTemplateID + `"}`, http.StatusCreated, userTemplateID, 4, []int{8080}, &image, false, false},
+		{"request hardware wins over preset", `{"name":"app","template_id":"` + userTemplateID + `","hardware":{"preset":"medium"}}`, http.StatusCreated, userTemplateID, 2, []int{8080}, &image, false, false},
+		{"unknown template", `{"name":"app","template_id":"cobol"}`, http.StatusBadRequest, "", 0, nil, nil, false, false},
+		{"another user's template", `{"name":"app","template_id":"` + userTemplateID + `"}`, http.StatusBadRequest, "", 0, nil, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMockStore()
+			owner := "test-user-id"
+			if tt.otherTemplate {
+				owner = "other-user"
+			}
+			store.templates[userTemplateID] = &db.Template{
+				ID:             userTemplateID,
+				UserID:         owner,
+				Name:           "Rust",
+				BaseImage:      &image,
+				HardwarePreset: &large,
+				Ports:          []int{8080},
+			}
+			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+			req := newAuthenticatedRequest("POST", "/projects", []byte(tt.body))
+			rr := httptest.NewRecorder()
+			handler.Create(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			p := store.projects["test-project-id"]
+			if p.TemplateID == nil || *p.TemplateID != tt.wantTemplate {
+				t.Errorf("expected template %s, got %v", tt.wantTemplate, p.TemplateID)
+			}
+			if p.CPUs != tt.wantCPUs {
+				t.Errorf("expected %d cpus, got %d", tt.wantCPUs, p.CPUs)
+			}
+			if len(p.ExposedPorts) != len(tt.wantPorts) || p.ExposedPorts[0] != tt.wantPorts[0] {
+				t.Errorf("expected ports %v, got %v", tt.wantPorts, p.ExposedPorts)
+			}
+			if (p.TemplateImage == nil) != (tt.wantImage == nil) {
+				t.Errorf("expected template image %v, got %v", tt.wantImage, p.TemplateImage)
+			}
+			if (p.TemplateSetup != nil) != tt.wantSetup {
+				t.Errorf("expected pending setup %v, got %+v", tt.wantSetup, p.TemplateSetup)
+			}
+
+			var resp ProjectResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.TemplateID == nil || *resp.TemplateID != tt.wantTemplate {
+				t.Errorf("expected template_id %s in response, got %v", tt.wantTemplate, resp.TemplateID)
+			}
+		})
+	}
+}
+
+// startTemplateProject creates a project from the node template and runs its first start
+func startTemplateProject(t *testing.T, machines *mockMachineManager) *mockProjectStore {
+	t.Helper()
+	ctx := context.Background()
+	store := newMockStore()
+	handler := NewProjectHandler(store, machines, newMockVolumeManager(), nil, nil, nil, newTestProjectEnvHandler(t, store), "test-image", testRegions, 10*time.Minute)
+
+	rr := httptest.NewRecorder()
+	handler.Create(rr, newAuthenticatedRequest("POST", "/projects", []byte(`{"name":"app","template_id":"node"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("failed to create project: %d %s", rr.Code, rr.Body.String())
+	}
+	// The mock store uses a fixed ID; operations need a real UUID prefix
+	p := store.projects["test-project-id"]
+	p.ID = testProjectID
+	store.projects[testProjectID] = p
+	delete(store.projects, "test-project-id")
+
+	op, err := store.BeginOperation(ctx, p.ID, "test-user-id", db.OperationStart, []string{db.StatusStopped}, db.StatusStarting)
+	if err != nil {
+		t.Fatalf("failed to begin start: %v", err)
+	}
+	claimed, err := store.ClaimOperation(ctx, op.ID, handler.workerID)
+	if err != nil {
+		t.Fatalf("failed to claim start: %v", err)
+	}
+	handler.runOperation(ctx, claimed)
+	return store
+}
+
+func TestProjectHandler_FirstStartAppliesTemplate(t *testing.T) {
+	machines := newMockMachineManager()
+	var config MachineConfig
+	machines.createFn = func(name string, c MachineConfig) (*Machine, error) {
+		config = c
+		return &Machine{ID: "machine-123", Name: name, State: "started"}, nil
+	}
+	var execs [][]string
+	machines.execFn = func(machineID string, command []string, timeout time.Duration) (*ExecResult, error) {
+		execs = append(execs, command)
+		return &ExecResult{}, nil
+	}
+
+	store := startTemplateProject(t, machines)
+
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusRunning {
+		t.Errorf("expected running, got %s", p.Status)
+	}
+	if p.TemplateSetup != nil {
+		t.Error("expected template setup cleared after first boot")
+	}
+	if config.Env["NODE_ENV"] != "development" || config.Env["PROJECT_ID"] != testProjectID {
+		t.Errorf("expected template env on machine, got %v", config.Env)
+	}
+	if config.Image != "test-image" {
+		t.Errorf("expected platform image, got %s", config.Image)
+	}
+	// The .env file is written first so the post-create script can use it
+	if len(execs) != 2 {
+		t.Fatalf("expected .env and template execs, got %d", len(execs))
+	}
+	script := execs[1][len(execs[1])-1]
+	node, _ := findTemplate(context.Background(), store, "node", "test-user-id")
+	indexJS := base64.StdEncoding.EncodeToString([]byte(node.Files["index.js"]))
+	if !strings.Contains(script, indexJS) || !strings.Contains(script, "'npm install'") {
+		t.Errorf("setup script missing template files or post-create script:\n%s", script)
+	}
+}
+
+func TestProjectHandler_TemplateFailureKeepsProjectRunning(t *testing.T) {
+	machines := newMockMachineManager()
+	machines.execFn = func(machineID string, command []string, timeout time.Duration) (*ExecResult, error) {
+		return nil, errors.New("exec unavailable")
+	}
+
+	store := startTemplateProject(t, machines)
+
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusRunning {
+		t.Errorf("expected running despite failed setup, got %s", p.Status)
+	}
+	if p.TemplateSetup != nil {
+		t.Error("expected failed template setup not to be retried")
+	}
+}
+
+func TestTemplateSetupScript(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	gitURL := "https://example.com/it's.git"
+	script := templateSetupScript(&db.TemplateSetup{
+		Files:  map[string]string{"src/it's here.txt": "a'b\n$HOME\n"},
+		GitURL: &gitURL,
+	})
+
+	// The script must parse, and paths and URLs must survive quoting
+	cmd := exec.Command("bash", "-n", "-c", script)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("script does not parse: %v\n%s\n%s", err, stderr.String(), script)
+	}
+	if !strings.Contains(script, `'src/it'\''s here.txt'`) || !strings.Contains(script, `'https://example.com/it'\''s.git'`) {
+		t.Errorf("expected quoted path and URL:\n%s", script)
+	}
+}
+
+func TestTemplateHandler_ListAndCreate(t *testing.T) {
+	store := newMockStore()
+	handler := NewTemplateHandler(store)
+
+	rr := httptest.NewRecorder()
+	handler.Create(rr, newAuthenticatedRequest("POST", "/templates", []byte(`{"name":"Go","ports":[70000]}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid port, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.Create(rr, newAuthenticatedRequest("POST", "/templates", []byte(`{"name":"Go","hardware_preset":"medium","ports":[8080],"files":{"main.go":"package main\n"}}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.List(rr, newAuthenticatedRequest("GET", "/templates", nil))
+	var resp TemplateListResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Templates) != len(builtInTemplates)+1 {
+		t.Fatalf("expected built-ins plus one user template, got %d", len(resp.Templates))
+	}
+	last := resp.Templates[len(resp.Templates)-1]
+	if last.Name != "Go" || last.BuiltIn || last.CreatedAt == nil {
+		t.Errorf("expected user template last, got %+v", last)
+	}
+	if !resp.Templates[0].BuiltIn || resp.Templates[0].ID != "blank" {
+		t.Errorf("expected built-ins first, got %+v", resp.Templates[0])
+	}
+}
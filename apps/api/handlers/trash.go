@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// trashPurgeBatchSize caps how many projects one purge pass deletes
+const trashPurgeBatchSize = 10
+
+// ProjectPurger deletes trashed projects for real. ProjectHandler implements it.
+type ProjectPurger interface {
+	PurgeProject(ctx context.Context, project *db.Project) error
+}
+
+// TrashHandler lists and restores trashed projects, and purges them once they
+// have been in the trash for longer than the retention period
+type TrashHandler struct {
+	store     TrashStore
+	purger    ProjectPurger
+	retention time.Duration
+}
+
+func NewTrashHandler(store TrashStore, purger ProjectPurger, retention time.Duration) *TrashHandler {
+	return &TrashHandler{store: store, purger: purger, retention: retention}
+}
+
+type TrashedProjectResponse struct {
+	ProjectResponse
+	ErrorMessage *string   `json:"error_message,omitempty"`
+	PurgeAt      time.Time `json:"purge_at"`
+}
+
+type TrashListResponse struct {
+	Projects []TrashedProjectResponse `json:"projects"`
+}
+
+func (h *TrashHandler) trashedToResponse(p *db.Project) TrashedProjectResponse {
+	deletedAt := p.UpdatedAt
+	if p.DeletedAt != nil {
+		deletedAt = *p.DeletedAt
+	}
+	return TrashedProjectResponse{
+		ProjectResponse: projectToResponse(p),
+		ErrorMessage:    p.ErrorMessage,
+		PurgeAt:         deletedAt.Add(h.retention),
+	}
+}
+
+// List returns the user's trashed projects and when each will be purged
+func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+
+	projects, err := h.store.ListTrashedProjects(ctx, userID)
+	if err != nil {
+		log.Error("failed to list trashed projects", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list trash")
+		return
+	}
+
+	response := TrashListResponse{Projects: make([]TrashedProjectResponse, len(projects))}
+	for i := range projects {
+		response.Projects[i] = h.trashedToResponse(&projects[i])
+	}
+	WriteJSON(w, http.StatusOK, response)
+}
+
+// Restore takes a project out of the trash. It comes back stopped, or
+// hibernated if it was hibernated when it was deleted.
+func (h *TrashHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	project, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for restore", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to restore project")
+		return
+	}
+	if !allowProject(w, project, roleManage) {
+		return
+	}
+
+	to := db.StatusStopped
+	if project.ArchiveKey != nil && project.FlyVolumeID == nil {
+		to = db.StatusHibernated
+	}
+
+	if err := h.store.RestoreTrashedProject(ctx, projectID, to); err != nil {
+		var transitionErr *db.TransitionError
+		switch {
+		case errors.As(err, &transitionErr):
+			WriteJSON(w, http.StatusConflict, map[string]string{
+				"error":          "Project is not in the trash",
+				"current_status": transitionErr.Current,
+			})
+		case errors.Is(err, db.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "Project not found")
+		default:
+			log.Error("failed to restore project", "project_id", projectID, "error", err)
+			WriteError(w, http.StatusInternalServerError, "Failed to restore project")
+		}
+		return
+	}
+
+	project, err = h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		log.Error("failed to get restored project", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to restore project")
+		return
+	}
+
+	log.Info("project restored from trash", "project_id", projectID, "status", to)
+	WriteJSON(w, http.StatusOK, projectToResponse(project))
+}
+
+// StartPurger starts a background goroutine that deletes projects whose
+// retention period has ended
+func (h *TrashHandler) StartPurger(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			h.purgeExpired(context.Background(), time.Now())
+		}
+	}()
+}
+
+// purgeExpired purges projects whose retention period has ended, and finishes
+// purges that were interrupted partway
+func (h *TrashHandler) purgeExpired(ctx context.Context, now time.Time) {
+	log := logging.Default()
+
+	projects, err := h.store.ListExpiredTrash(ctx, now.Add(-h.retention), trashPurgeBatchSize)
+	if err != nil {
+		log.Error("failed to list expired trash", "error", err)
+		return
+	}
+
+	stale, err := h.store.ListStaleDeletions(ctx, now.Add(-purgeStaleAfter), trashPurgeBatchSize)
+	if err != nil {
+		log.Error("failed to list stale deletions", "error", err)
+	}
+	projects = append(projects, stale...)
+
+	for i := range projects {
+		p := &projects[i]
+		if err := h.purger.PurgeProject(ctx, p); err != nil {
+			// Restored, purged or reclaimed by someone else in the meantime
+			if errors.Is(err, db.ErrInvalidTransition) || errors.Is(err, db.ErrNotFound) {
+				continue
+			}
+			log.Error("failed to purge trashed project", "project_id", p.ID, "error", err)
+			continue
+		}
+		log.Info("purged trashed project", "project_id", p.ID, "deleted_at", p.DeletedAt)
+	}
+}
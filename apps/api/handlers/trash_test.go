@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (m *mockProjectStore) TrashProject(ctx context.Context, projectID string, from []string) error {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	if err := m.transition(projectID, from, db.StatusTrashed, nil); err != nil {
+		return err
+	}
+	now := time.Now()
+	m.projects[projectID].DeletedAt = &now
+	return nil
+}
+
+func (m *mockProjectStore) RestoreTrashedProject(ctx context.Context, projectID, to string) error {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	if err := m.transition(projectID, []string{db.StatusTrashed}, to, nil); err != nil {
+		return err
+	}
+	m.projects[projectID].DeletedAt = nil
+	return nil
+}
+
+func (m *mockProjectStore) ListTrashedProjects(ctx context.Context, userID string) ([]db.Project, error) {
+	var result []db.Project
+	for _, p := range m.projects {
+		if m.roleOn(p, userID) != "" && p.Status == db.StatusTrashed {
+			result = append(result, *p)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProjectStore) ListExpiredTrash(ctx context.Context, deletedBefore time.Time, limit int) ([]db.Project, error) {
+	var result []db.Project
+	for _, p := range m.projects {
+		if p.Status == db.StatusTrashed && p.DeletedAt != nil && p.DeletedAt.Before(deletedBefore) && len(result) < limit {
+			result = append(result, *p)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProjectStore) ListStaleDeletions(ctx context.Context, updatedBefore time.Time, limit int) ([]db.Project, error) {
+	var result []db.Project
+	for _, p := range m.projects {
+		if p.Status == db.StatusDeleting && p.UpdatedAt.Before(updatedBefore) && len(result) < limit {
+			result = append(result, *p)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProjectStore) ReclaimDeletion(ctx context.Context, projectID string, updatedBefore time.Time) error {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+	p, ok := m.projects[projectID]
+	if !ok || p.Status != db.StatusDeleting || !p.UpdatedAt.Before(updatedBefore) {
+		return db.ErrNotFound
+	}
+	p.UpdatedAt = time.Now()
+	return nil
+}
+
+// newTrashFixture returns the fixture project, trashed at deletedAt
+func newTrashFixture(deletedAt time.Time) *mockProjectStore {
+	store := newProjectFixture(db.StatusTrashed)
+	store.projects[testProjectID].DeletedAt = &deletedAt
+	return store
+}
+
+func TestProjectHandler_Delete_StopsRunningMachine(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	projectID := testProjectID
+	store.usage = []db.UsageInterval{{ProjectID: &projectID, StartedAt: time.Now().Add(-time.Hour)}}
+	machines := newMockMachineManager()
+	machines.getFn = func(machineID string) (*Machine, error) {
+		return &Machine{ID: machineID, State: "started"}, nil
+	}
+	var stopped string
+	machines.stopFn = func(machineID string) error {
+		stopped = machineID
+		return nil
+	}
+	machines.deleteFn = func(machineID string) error {
+		t.Error("machine deleted when trashing")
+		return nil
+	}
+	volumes := newMockVolumeManager()
+	volumes.deleteFn = func(volumeID string) error {
+		t.Error("volume deleted when trashing")
+		return nil
+	}
+	handler := NewProjectHandler(store, machines, volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	router := chi.NewRouter()
+	router.Delete("/projects/{id}", handler.Delete)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, newAuthenticatedRequest("DELETE", "/projects/"+testProjectID, nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response ProjectResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Status != db.StatusTrashed || response.DeletedAt == nil {
+		t.Errorf("expected trashed response, got %s (deleted_at %v)", response.Status, response.DeletedAt)
+	}
+	if stopped != "machine-123" {
+		t.Errorf("expected machine stopped, got %q", stopped)
+	}
+	if store.usage[0].StoppedAt == nil {
+		t.Error("expected usage interval closed")
+	}
+}
+
+func TestProjectHandler_Delete_StopFailureKeepsProject(t *testing.T) {
+	store := newProjectFixture(db.StatusRunning)
+	projectID := testProjectID
+	store.usage = []db.UsageInterval{{ProjectID: &projectID, StartedAt: time.Now().Add(-time.Hour)}}
+	machines := newMockMachineManager()
+	machines.getFn = func(machineID string) (*Machine, error) {
+		return &Machine{ID: machineID, State: "started"}, nil
+	}
+	machines.stopFn = func(machineID string) error {
+		return errors.New("machine unreachable")
+	}
+	handler := NewProjectHandler(store, machines, newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.Delete, "DELETE", "/projects/{id}", "/projects/"+testProjectID, nil)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusInternalServerError, rr.Code, rr.Body.String())
+	}
+	if p := store.projects[testProjectID]; p.Status != db.StatusRunning || p.DeletedAt != nil {
+		t.Errorf("expected project back to running out of the trash, got %s (deleted_at %v)", p.Status, p.DeletedAt)
+	}
+	if store.usage[0].StoppedAt != nil {
+		t.Error("expected usage to keep accruing while the machine runs")
+	}
+}
+
+func TestProjectHandler_Delete_ClaimsBeforeStopping(t *testing.T) {
+	// A stop already in flight holds the project, so trashing it must not touch the machine
+	store := newProjectFixture(db.StatusStopping)
+	machines := newMockMachineManager()
+	machines.getFn = func(machineID string) (*Machine, error) {
+		return &Machine{ID: machineID, State: "started"}, nil
+	}
+	machines.stopFn = func(machineID string) error {
+		t.Error("machine stopped without the project claimed")
+		return nil
+	}
+	handler := NewProjectHandler(store, machines, newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.Delete, "DELETE", "/projects/{id}", "/projects/"+testProjectID, nil)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	if status := store.projects[testProjectID].Status; status != db.StatusStopping {
+		t.Errorf("expected project left stopping, got %s", status)
+	}
+}
+
+func TestProjectHandler_Delete_TrashedPurges(t *testing.T) {
+	store := newTrashFixture(time.Now())
+	machines := newMockMachineManager()
+	var deletedMachine string
+	machines.deleteFn = func(machineID string) error {
+		deletedMachine = machineID
+		return nil
+	}
+	volumes := newMockVolumeManager()
+	var deletedVolume string
+	volumes.deleteFn = func(volumeID string) error {
+		deletedVolume = volumeID
+		return nil
+	}
+	handler := NewProjectHandler(store, machines, volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	router := chi.NewRouter()
+	router.Delete("/projects/{id}", handler.Delete)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, newAuthenticatedRequest("DELETE", "/projects/"+testProjectID, nil))
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if deletedMachine != "machine-123" || deletedVolume != "vol-123" {
+		t.Errorf("expected machine and volume deleted, got %q and %q", deletedMachine, deletedVolume)
+	}
+	if _, ok := store.projects[testProjectID]; ok {
+		t.Error("project should have been deleted")
+	}
+}
+
+func TestProjectHandler_PurgeProject_FailureReturnsToTrash(t *testing.T) {
+	store := newTrashFixture(time.Now())
+	volumes := newMockVolumeManager()
+	volumes.deleteFn = func(volumeID string) error {
+		return errors.New("volume busy")
+	}
+	handler := NewProjectHandler(store, newMockMachineManager(), volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	if err := handler.PurgeProject(context.Background(), store.projects[testProjectID]); err == nil {
+		t.Fatal("expected purge to fail")
+	}
+
+	p, ok := store.projects[testProjectID]
+	if !ok {
+		t.Fatal("project should have been kept")
+	}
+	if p.Status != db.StatusTrashed || p.ErrorMessage == nil {
+		t.Errorf("expected back in the trash with an error, got %s (%v)", p.Status, p.ErrorMessage)
+	}
+}
+
+func TestTrashHandler_Restore(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		hibernated bool
+		wantCode   int
+		wantStatus string
+	}{
+		{"trashed", db.StatusTrashed, false, http.StatusOK, db.StatusStopped},
+		{"trashed while hibernated", db.StatusTrashed, true, http.StatusOK, db.StatusHibernated},
+		{"not trashed", db.StatusRunning, false, http.StatusConflict, db.StatusRunning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newProjectFixture(tt.status)
+			p := store.projects[testProjectID]
+			if tt.hibernated {
+				key := "projects/" + testProjectID + "/op.tar.gz"
+				p.ArchiveKey = &key
+				p.FlyMachineID = nil
+				p.FlyVolumeID = nil
+			}
+			handler := NewTrashHandler(store, nil, 7*24*time.Hour)
+
+			rr := serveRoute(handler.Restore, "POST", "/projects/{id}/restore", "/projects/"+testProjectID+"/restore", nil)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+			if p.Status != tt.wantStatus {
+				t.Errorf("expected %s, got %s", tt.wantStatus, p.Status)
+			}
+			if tt.wantCode == http.StatusOK && p.DeletedAt != nil {
+				t.Error("expected deleted_at cleared")
+			}
+		})
+	}
+}
+
+func TestTrashHandler_List(t *testing.T) {
+	deletedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := newTrashFixture(deletedAt)
+	handler := NewTrashHandler(store, nil, 7*24*time.Hour)
+
+	rr := httptest.NewRecorder()
+	handler.List(rr, newAuthenticatedRequest("GET", "/projects/trash", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response TrashListResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Projects) != 1 {
+		t.Fatalf("expected 1 trashed project, got %d", len(response.Projects))
+	}
+	if want := deletedAt.Add(7 * 24 * time.Hour); !response.Projects[0].PurgeAt.Equal(want) {
+		t.Errorf("expected purge at %v, got %v", want, response.Projects[0].PurgeAt)
+	}
+}
+
+func TestTrashHandler_PurgeExpired(t *testing.T) {
+	now := time.Now()
+	store := newTrashFixture(now.Add(-8 * 24 * time.Hour))
+	recentID := "660e8400-e29b-41d4-a716-446655440000"
+	recentDeletedAt := now.Add(-24 * time.Hour)
+	store.projects[recentID] = &db.Project{
+		ID:        recentID,
+		UserID:    "test-user-id",
+		Status:    db.StatusTrashed,
+		DeletedAt: &recentDeletedAt,
+	}
+	projects := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+	handler := NewTrashHandler(store, projects, 7*24*time.Hour)
+
+	handler.purgeExpired(context.Background(), now)
+
+	if _, ok := store.projects[testProjectID]; ok {
+		t.Error("expected expired project purged")
+	}
+	if _, ok := store.projects[recentID]; !ok {
+		t.Error("expected recently trashed project kept")
+	}
+}
+
+func TestTrashHandler_PurgeExpired_ReclaimsStaleDeletion(t *testing.T) {
+	now := time.Now()
+	store := newProjectFixture(db.StatusDeleting)
+	store.projects[testProjectID].UpdatedAt = now.Add(-time.Hour)
+	activeID := "660e8400-e29b-41d4-a716-446655440000"
+	store.projects[activeID] = &db.Project{
+		ID:        activeID,
+		UserID:    "test-user-id",
+		Status:    db.StatusDeleting,
+		UpdatedAt: now,
+	}
+
+	// The interrupted purge already deleted the machine and volume
+	machines := newMockMachineManager()
+	machines.getFn = func(machineID string) (*Machine, error) {
+		return nil, errors.New("machine not found")
+	}
+	machines.deleteFn = func(machineID string) error {
+		t.Error("deleted machine again")
+		return nil
+	}
+	volumes := newMockVolumeManager()
+	volumes.getFn = func(volumeID string) (*Volume, error) {
+		return nil, errors.New("volume not found")
+	}
+	volumes.deleteFn = func(volumeID string) error {
+		return errors.New("volume not found")
+	}
+	projects := NewProjectHandler(store, machines, volumes, nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+	handler := NewTrashHandler(store, projects, 7*24*time.Hour)
+
+	handler.purgeExpired(context.Background(), now)
+
+	if _, ok := store.projects[testProjectID]; ok {
+		t.Error("expected stale deletion finished")
+	}
+	if p, ok := store.projects[activeID]; !ok || p.Status != db.StatusDeleting {
+		t.Error("expected deletion in progress left alone")
+	}
+}
@@ -1,5 +1,7 @@
 package handlers
 
+import "time"
+
 // HardwareConfig represents hardware configuration for projects
 // Used in both project responses and user settings
 type HardwareConfig struct {
@@ -32,13 +34,37 @@ type Volume struct {
 	CreatedAt string
 }
 
+// Snapshot is a point-in-time copy of a volume (Fly snapshot, local tarball, etc.)
+// This is a provider-agnostic type used by handlers.
+type Snapshot struct {
+	ID        string
+	VolumeID  string
+	SizeBytes int64
+	CreatedAt string
+	// ExpiresAt is when the backend discards the snapshot, or nil if it keeps
+	// snapshots until they are deleted
+	ExpiresAt *time.Time
+}
+
+// ExecResult is the outcome of a command run inside a machine
+type ExecResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
 // MachineConfig contains the configuration for creating a machine.
 // This is a provider-agnostic type - implementations convert to provider-specific formats.
 type MachineConfig struct {
+	// Region places a new machine; empty uses the backend's default region
+	Region string
 	Image  string
 	Guest  GuestConfig
 	Env    map[string]string
 	Mounts []Mount
+	// Labels are the project's labels, attached to the machine for filtering
+	// in the provider's own tooling
+	Labels map[string]string
 }
 
 // GuestConfig specifies compute resources for a machine.
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"aether/apps/api/db"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	// errTemplateImage is returned when upgrading a project that runs its template's image
+	errTemplateImage = errors.New("project runs its template's image")
+	// errImageChanged is returned when a project is no longer on the image an upgrade expected
+	errImageChanged = errors.New("project image changed")
+)
+
+type UpgradeResponse struct {
+	Project     ProjectResponse `json:"project"`
+	OperationID string          `json:"operation_id,omitempty"`
+}
+
+// Upgrade moves a project onto the platform's current base image. Running projects
+// are restarted onto it; stopped projects get a new machine on their next start.
+// The volume is kept either way.
+func (h *ProjectHandler) Upgrade(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	project, err := h.store.GetProjectByUser(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for upgrade", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to upgrade project")
+		return
+	}
+	if !allowProject(w, project, roleOperate) {
+		return
+	}
+
+	op, err := h.upgradeProject(ctx, project, "", h.baseImage)
+	if err != nil {
+		if errors.Is(err, errTemplateImage) {
+			WriteError(w, http.StatusConflict, "Project runs its template's image and can't be upgraded")
+			return
+		}
+		h.writeTransitionError(w, log, err, "upgrade")
+		return
+	}
+
+	updated, err := h.store.GetProject(ctx, projectID)
+	if err != nil {
+		log.Error("failed to reload project after upgrade", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to upgrade project")
+		return
+	}
+
+	log.Info("project upgraded", "project_id", projectID, "image", h.baseImage, "pending", updated.ImagePending())
+
+	if op == nil {
+		WriteJSON(w, http.StatusOK, UpgradeResponse{Project: projectToResponse(updated)})
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, UpgradeResponse{Project: projectToResponse(updated), OperationID: op.ID})
+}
+
+// UpgradeProject moves a project from image `from` onto image `to`, as upgradeProject
+func (h *ProjectHandler) UpgradeProject(ctx context.Context, projectID, from, to string) (*db.Operation, error) {
+	project, err := h.store.GetProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return h.upgradeProject(ctx, project, from, to)
+}
+
+// upgradeProject sets the image a project runs. If from is set, the project must
+// still be on it, or already on to: an earlier attempt may have saved the image
+// but not begun the restart, and retrying finishes it. Running projects are
+// restarted onto the new image by the returned operation; for other projects it
+// is nil and the image applies on the next start. Projects mid-transition are
+// refused with a *db.TransitionError.
+func (h *ProjectHandler) upgradeProject(ctx context.Context, project *db.Project, from, to string) (*db.Operation, error) {
+	if project.TemplateImage != nil && *project.TemplateImage != "" {
+		return nil, errTemplateImage
+	}
+	if from != "" && project.BaseImage != from && project.BaseImage != to {
+		return nil, errImageChanged
+	}
+
+	switch project.Status {
+	case db.StatusStopped, db.StatusError, db.StatusRunning:
+	default:
+		return nil, &db.TransitionError{Current: project.Status, Target: db.StatusStopping}
+	}
+
+	updated, err := h.store.SetProjectImage(ctx, project.ID, to)
+	if err != nil {
+		return nil, err
+	}
+	if updated.Status != db.StatusRunning || !updated.ImagePending() {
+		return nil, nil
+	}
+
+	// The new image is saved, so even if the restart can't begin it applies on the next start
+	op, err := h.store.BeginOperation(ctx, project.ID, project.UserID, db.OperationUpgrade, []string{db.StatusRunning}, db.StatusStopping)
+	if err != nil {
+		return nil, err
+	}
+	h.dispatchOperation(op)
+	return op, nil
+}
+
+// replaceMachine deletes a stopped project's machine so the start can create one on
+// the project's current image. A resumed start may find the machine already gone.
+func (h *ProjectHandler) replaceMachine(ctx context.Context, project *db.Project) error {
+	machineID := *project.FlyMachineID
+	if err := h.machines.DeleteMachine(machineID); err != nil {
+		if _, getErr := h.machines.GetMachine(machineID); getErr == nil {
+			return err
+		}
+	}
+	if err := h.store.DetachProjectMachine(ctx, project.ID, machineID); err != nil {
+		return err
+	}
+	project.FlyMachineID = nil
+	project.MachineImage = nil
+	return nil
+}
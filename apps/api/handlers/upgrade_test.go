@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+func (m *mockProjectStore) DetachProjectMachine(ctx context.Context, projectID, machineID string) error {
+	if p, ok := m.projects[projectID]; ok && p.FlyMachineID != nil && *p.FlyMachineID == machineID {
+		p.FlyMachineID = nil
+		p.MachineImage = nil
+	}
+	return nil
+}
+
+func (m *mockProjectStore) SetProjectImage(ctx context.Context, projectID, image string) (*db.Project, error) {
+	p, ok := m.projects[projectID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	p.BaseImage = image
+	return p, nil
+}
+
+func newUpgradeFixture(status string) *mockProjectStore {
+	store := newProjectFixture(status)
+	p := store.projects[testProjectID]
+	p.BaseImage = "old-image"
+	p.MachineImage = strPtr("old-image")
+	return store
+}
+
+func TestProjectHandler_Upgrade_StoppedIsPending(t *testing.T) {
+	store := newUpgradeFixture(db.StatusStopped)
+	machines := newMockMachineManager()
+	machines.deleteFn = func(machineID string) error {
+		t.Error("machine replaced before next start")
+		return nil
+	}
+	handler := NewProjectHandler(store, machines, newMockVolumeManager(), nil, nil, nil, nil, "new-image", testRegions, 10*time.Minute)
+
+	rr := serveRoute(handler.Upgrade, "POST", "/projects/{id}/upgrade", "/projects/"+testProjectID+"/upgrade", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response UpgradeResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Project.Image != "new-image" || !response.Project.ImagePending {
+		t.Errorf("expected new-image pending, got %q pending=%v", response.Project.Image, response.Project.ImagePending)
+	}
+	if response.OperationID != "" {
+		t.Errorf("expected no operation for a stopped project, got %s", response.OperationID)
+	}
+}
+
+func TestProjectHandler_Upgrade_Rejections(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		template bool
+		code     int
+	}{
+		{name: "while starting", status: db.StatusStarting, code: http.StatusConflict},
+		{name: "template image", status: db.StatusStopped, template: true, code: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newUpgradeFixture(tt.status)
+			if tt.template {
+				store.projects[testProjectID].TemplateImage = strPtr("template-image")
+			}
+			handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "new-image", testRegions, 10*time.Minute)
+
+			rr := serveRoute(handler.Upgrade, "POST", "/projects/{id}/upgrade", "/projects/"+testProjectID+"/upgrade", nil)
+			if rr.Code != tt.code {
+				t.Fatalf("expected status %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if p := store.projects[testProjectID]; p.BaseImage != "old-image" {
+				t.Errorf("image changed despite rejection: %s", p.BaseImage)
+			}
+		})
+	}
+}
+
+func TestProjectHandler_UpgradeProject_RetriesAfterBusy(t *testing.T) {
+	ctx := context.Background()
+	store := newUpgradeFixture(db.StatusRunning)
+	blocker := store.addOperation(testProjectID, "test-user-id", db.OperationRestart)
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "new-image", testRegions, 10*time.Minute)
+
+	// The image is saved but the restart can't begin while another operation runs
+	if _, err := handler.UpgradeProject(ctx, testProjectID, "old-image", "new-image"); !errors.Is(err, db.ErrOperationInProgress) {
+		t.Fatalf("expected operation in progress, got %v", err)
+	}
+	if p := store.projects[testProjectID]; p.BaseImage != "new-image" {
+		t.Fatalf("expected image saved, got %s", p.BaseImage)
+	}
+
+	// A rollout's retry must finish the upgrade rather than skip the project
+	store.operations[blocker.ID].Status = db.OperationSucceeded
+	op, err := handler.UpgradeProject(ctx, testProjectID, "old-image", "new-image")
+	if err != nil {
+		t.Fatalf("expected retry to upgrade, got %v", err)
+	}
+	if op == nil || op.Type != db.OperationUpgrade {
+		t.Errorf("expected an upgrade operation, got %+v", op)
+	}
+}
+
+func TestProjectHandler_UpgradeReplacesMachine(t *testing.T) {
+	ctx := context.Background()
+	store := newUpgradeFixture(db.StatusRunning)
+
+	machines := newMockMachineManager()
+	state := "started"
+	machines.getFn = func(machineID string) (*Machine, error) {
+		return &Machine{ID: machineID, State: state}, nil
+	}
+	machines.stopFn = func(machineID string) error {
+		state = "stopped"
+		return nil
+	}
+	var deleted string
+	machines.deleteFn = func(machineID string) error {
+		if state != "stopped" {
+			t.Errorf("machine deleted while %s", state)
+		}
+		deleted = machineID
+		return nil
+	}
+	var created MachineConfig
+	machines.createFn = func(name string, config MachineConfig) (*Machine, error) {
+		created = config
+		state = "created"
+		return &Machine{ID: "machine-456", Name: name, State: state}, nil
+	}
+	machines.startFn = func(machineID string) error {
+		state = "started"
+		return nil
+	}
+	handler := NewProjectHandler(store, machines, newMockVolumeManager(), nil, nil, nil, nil, "new-image", testRegions, 10*time.Minute)
+
+	// Set the image and begin the restart as upgradeProject does, without dispatching it
+	if _, err := store.SetProjectImage(ctx, testProjectID, "new-image"); err != nil {
+		t.Fatalf("failed to set image: %v", err)
+	}
+	op, err := store.BeginOperation(ctx, testProjectID, "test-user-id", db.OperationUpgrade, []string{db.StatusRunning}, db.StatusStopping)
+	if err != nil {
+		t.Fatalf("failed to begin upgrade: %v", err)
+	}
+	claimed, err := store.ClaimOperation(ctx, op.ID, handler.workerID)
+	if err != nil {
+		t.Fatalf("failed to claim upgrade: %v", err)
+	}
+	handler.runOperation(ctx, claimed)
+
+	p := store.projects[testProjectID]
+	if p.Status != db.StatusRunning {
+		t.Errorf("expected running after upgrade, got %s", p.Status)
+	}
+	if deleted != "machine-123" {
+		t.Errorf("expected old machine deleted, got %q", deleted)
+	}
+	if created.Image != "new-image" {
+		t.Errorf("expected machine created on new-image, got %q", created.Image)
+	}
+	if len(created.Mounts) != 1 || created.Mounts[0].Volume != "vol-123" {
+		t.Errorf("expected volume kept, got %+v", created.Mounts)
+	}
+	if p.FlyMachineID == nil || *p.FlyMachineID != "machine-456" || p.ImagePending() {
+		t.Errorf("expected project on new machine with image applied, got %+v", p)
+	}
+}
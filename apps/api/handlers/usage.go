@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"aether/apps/api/db"
+	"aether/apps/api/metering"
+	authmw "aether/apps/api/middleware"
+	"aether/apps/api/quota"
+	"aether/apps/api/validation"
+	"aether/libs/go/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxUsageDays   = 366
+	maxUsageMonths = 36
+)
+
+// openUsage records in the usage ledger that the project's machine is running,
+// at the size it runs at
+func (h *ProjectHandler) openUsage(ctx context.Context, log *logging.Logger, project *db.Project, startedAt time.Time) {
+	cpus, memoryMB := quota.Compute(project.CPUs, project.MemoryMB, project.GPUKind)
+	cpuKind := project.CPUKind
+	if project.GPUKind != nil && *project.GPUKind != "" {
+		cpuKind = validation.GPUMachineCPUKind
+	}
+	region := h.projectRegion(project)
+	if err := h.store.OpenUsageInterval(ctx, &db.UsageInterval{
+		ProjectID:   &project.ID,
+		ProjectName: project.Name,
+		UserID:      project.UserID,
+		MachineID:   project.FlyMachineID,
+		Region:      &region,
+		CPUKind:     cpuKind,
+		CPUs:        cpus,
+		MemoryMB:    memoryMB,
+		GPUKind:     project.GPUKind,
+		StartedAt:   startedAt,
+	}); err != nil {
+		log.Error("failed to record usage start", "error", err)
+	}
+}
+
+// closeUsage records in the usage ledger that the project's machine stopped
+func (h *ProjectHandler) closeUsage(ctx context.Context, log *logging.Logger, projectID string, stoppedAt time.Time) {
+	if err := h.store.CloseUsageInterval(ctx, projectID, stoppedAt); err != nil {
+		log.Error("failed to record usage stop", "error", err)
+	}
+}
+
+// syncUsage makes the usage ledger agree with which projects are running. Start
+// and stop operations record the exact times; this catches projects that stopped
+// some other way, such as failing or being reconciled, and ones that were
+// running before metering began.
+func (h *ProjectHandler) syncUsage(ctx context.Context, running []db.Project, now time.Time) {
+	log := logging.Default()
+
+	closed, err := h.store.CloseStaleUsageIntervals(ctx, now)
+	if err != nil {
+		log.Error("failed to close stale usage intervals", "error", err)
+	} else if closed > 0 {
+		log.Info("closed stale usage intervals", "count", closed)
+	}
+
+	open, err := h.store.GetProjectIDsWithOpenUsage(ctx)
+	if err != nil {
+		log.Error("failed to get open usage intervals", "error", err)
+		return
+	}
+	for i := range running {
+		p := &running[i]
+		if !open[p.ID] {
+			log.Info("opening missing usage interval", "project_id", p.ID)
+			h.openUsage(ctx, log.With("project_id", p.ID), p, now)
+		}
+	}
+}
+
+// UsageHandler reports machine usage and its cost from the usage ledger
+type UsageHandler struct {
+	store  UsageStore
+	prices *metering.Prices
+}
+
+func NewUsageHandler(store UsageStore, prices *metering.Prices) *UsageHandler {
+	return &UsageHandler{store: store, prices: prices}
+}
+
+// usageRange is the reporting window asked for
+type usageRange struct {
+	Period string
+	From   time.Time
+	To     time.Time
+	CSV    bool
+}
+
+// parseUsageQuery reads period (day or month), from and to (dates, to exclusive)
+// and format (json or csv). Days default to the current month so far, months to
+// the last twelve.
+func parseUsageQuery(query url.Values, now time.Time) (usageRange, validation.ValidationErrors) {
+	var errs validation.ValidationErrors
+	r := usageRange{Period: metering.PeriodDay}
+
+	switch period := query.Get("period"); period {
+	case "", metering.PeriodDay:
+	case metering.PeriodMonth:
+		r.Period = metering.PeriodMonth
+	default:
+		errs = append(errs, validation.ValidationError{Field: "period", Message: "must be day or month"})
+	}
+
+	var err *validation.ValidationError
+	if r.To, err = parseDate(query, "to", metering.Truncate(now, metering.PeriodDay).AddDate(0, 0, 1)); err != nil {
+		errs = append(errs, *err)
+	}
+	from := metering.Truncate(now, metering.PeriodMonth)
+	if r.Period == metering.PeriodMonth {
+		from = from.AddDate(0, -11, 0)
+	}
+	if r.From, err = parseDate(query, "from", from); err != nil {
+		errs = append(errs, *err)
+	}
+
+	switch {
+	case errs.HasErrors():
+		// A date that didn't parse leaves nothing to compare
+	case !r.From.Before(r.To):
+		errs = append(errs, validation.ValidationError{Field: "from", Message: "must be before to"})
+	case r.Period == metering.PeriodDay && r.To.After(r.From.AddDate(0, 0, maxUsageDays)):
+		errs = append(errs, validation.ValidationError{Field: "from", Message: "daily reports cover at most 366 days"})
+	case r.Period == metering.PeriodMonth && r.To.After(r.From.AddDate(0, maxUsageMonths, 0)):
+		errs = append(errs, validation.ValidationError{Field: "from", Message: "monthly reports cover at most 36 months"})
+	}
+
+	switch format := query.Get("format"); format {
+	case "", "json":
+	case "csv":
+		r.CSV = true
+	default:
+		errs = append(errs, validation.ValidationError{Field: "format", Message: "must be json or csv"})
+	}
+	return r, errs
+}
+
+// parseDate reads a date query parameter, or returns def when it isn't set
+func parseDate(query url.Values, field string, def time.Time) (time.Time, *validation.ValidationError) {
+	value := query.Get(field)
+	if value == "" {
+		return def, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return t, &validation.ValidationError{Field: field, Message: "must be a date like 2006-01-02"}
+	}
+	return t, nil
+}
+
+// GetUserUsage reports the user's machine usage across all their projects,
+// including deleted ones
+func (h *UsageHandler) GetUserUsage(w http.ResponseWriter, r *http.Request) {
+	h.serveUsage(w, r, nil)
+}
+
+// GetProjectUsage reports one project's machine usage
+func (h *UsageHandler) GetProjectUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	projectID := chi.URLParam(r, "id")
+	log := logging.FromContext(ctx)
+
+	if err := validation.ValidateUUID(projectID, "id"); err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": []validation.ValidationError{*err},
+		})
+		return
+	}
+
+	if _, err := h.store.GetProjectByUser(ctx, projectID, userID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			WriteError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		log.Error("failed to get project for usage", "project_id", projectID, "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get usage")
+		return
+	}
+
+	h.serveUsage(w, r, &projectID)
+}
+
+func (h *UsageHandler) serveUsage(w http.ResponseWriter, r *http.Request, projectID *string) {
+	ctx := r.Context()
+	userID := authmw.GetUserID(ctx)
+	log := logging.FromContext(ctx)
+	now := time.Now().UTC()
+
+	rng, errs := parseUsageQuery(r.URL.Query(), now)
+	if errs.HasErrors() {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "Validation failed",
+			"errors": errs,
+		})
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		rng.CSV = true
+	}
+
+	intervals, err := h.store.ListUsageIntervals(ctx, db.UsageQuery{UserID: userID, ProjectID: projectID, From: rng.From, To: rng.To})
+	if err != nil {
+		log.Error("failed to list usage intervals", "error", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get usage")
+		return
+	}
+	report := metering.Aggregate(intervals, rng.Period, rng.From, rng.To, now, h.prices)
+
+	if !rng.CSV {
+		WriteJSON(w, http.StatusOK, report)
+		return
+	}
+
+	name := "usage-" + rng.From.Format(time.DateOnly) + "-" + rng.To.Format(time.DateOnly) + ".csv"
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.WriteHeader(http.StatusOK)
+	if err := metering.WriteCSV(w, report); err != nil {
+		log.Error("failed to write usage CSV", "error", err)
+	}
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+	"aether/apps/api/metering"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (m *mockProjectStore) OpenUsageInterval(ctx context.Context, interval *db.UsageInterval) error {
+	for _, u := range m.usage {
+		if u.StoppedAt == nil && *u.ProjectID == *interval.ProjectID {
+			return nil
+		}
+	}
+	opened := *interval
+	opened.ID = "usage-" + *interval.ProjectID
+	m.usage = append(m.usage, opened)
+	return nil
+}
+
+func (m *mockProjectStore) CloseUsageInterval(ctx context.Context, projectID string, at time.Time) error {
+	for i := range m.usage {
+		if u := &m.usage[i]; u.StoppedAt == nil && *u.ProjectID == projectID {
+			u.StoppedAt = &at
+		}
+	}
+	return nil
+}
+
+func (m *mockProjectStore) CloseStaleUsageIntervals(ctx context.Context, at time.Time) (int64, error) {
+	var closed int64
+	for i := range m.usage {
+		u := &m.usage[i]
+		if u.StoppedAt != nil {
+			continue
+		}
+		if p, ok := m.projects[*u.ProjectID]; ok {
+			switch p.Status {
+			case db.StatusStarting, db.StatusRunning, db.StatusStopping:
+				continue
+			}
+		}
+		u.StoppedAt = &at
+		closed++
+	}
+	return closed, nil
+}
+
+func (m *mockProjectStore) GetProjectIDsWithOpenUsage(ctx context.Context) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	for _, u := range m.usage {
+		if u.StoppedAt == nil {
+			ids[*u.ProjectID] = true
+		}
+	}
+	return ids, nil
+}
+
+func (m *mockProjectStore) ListUsageIntervals(ctx context.Context, q db.UsageQuery) ([]db.UsageInterval, error) {
+	var result []db.UsageInterval
+	for _, u := range m.usage {
+		if q.ProjectID == nil && u.UserID != q.UserID {
+			continue
+		}
+		if q.ProjectID != nil && (u.ProjectID == nil || *u.ProjectID != *q.ProjectID) {
+			continue
+		}
+		if !u.StartedAt.Before(q.To) || (u.StoppedAt != nil && !u.StoppedAt.After(q.From)) {
+			continue
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+func runTestOperation(t *testing.T, store *mockProjectStore, handler *ProjectHandler, opType string, from []string, to string) {
+	t.Helper()
+	ctx := context.Background()
+	op, err := store.BeginOperation(ctx, testProjectID, "test-user-id", opType, from, to)
+	if err != nil {
+		t.Fatalf("failed to begin %s: %v", opType, err)
+	}
+	claimed, err := store.ClaimOperation(ctx, op.ID, handler.workerID)
+	if err != nil {
+		t.Fatalf("failed to claim %s: %v", opType, err)
+	}
+	handler.runOperation(ctx, claimed)
+}
+
+func TestProjectHandler_StartStopRecordsUsage(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	store.projects[testProjectID].GPUKind = strPtr("a10")
+	store.projects[testProjectID].Region = strPtr("ord")
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	runTestOperation(t, store, handler, db.OperationStart, []string{db.StatusStopped}, db.StatusStarting)
+	if len(store.usage) != 1 || store.usage[0].StoppedAt != nil {
+		t.Fatalf("expected one open interval, got %+v", store.usage)
+	}
+	if u := store.usage[0]; u.CPUs != 8 || u.MemoryMB != 16384 || u.GPUKind == nil || *u.Region != "ord" || *u.MachineID != "machine-123" {
+		t.Errorf("expected the GPU machine's size and placement recorded, got %+v", u)
+	}
+
+	runTestOperation(t, store, handler, db.OperationStop, []string{db.StatusRunning}, db.StatusStopping)
+	if len(store.usage) != 1 || store.usage[0].StoppedAt == nil {
+		t.Errorf("expected the interval closed, got %+v", store.usage)
+	}
+}
+
+func TestProjectHandler_SyncUsage(t *testing.T) {
+	ctx := context.Background()
+	store := newProjectFixture(db.StatusRunning)
+	running := *store.projects[testProjectID]
+	store.projects["stopped-project"] = &db.Project{ID: "stopped-project", UserID: "test-user-id", Status: db.StatusError}
+	stoppedID := "stopped-project"
+	store.usage = []db.UsageInterval{{ProjectID: &stoppedID, UserID: "test-user-id", StartedAt: time.Now().Add(-time.Hour)}}
+	handler := NewProjectHandler(store, newMockMachineManager(), newMockVolumeManager(), nil, nil, nil, nil, "test-image", testRegions, 10*time.Minute)
+
+	now := time.Now()
+	handler.syncUsage(ctx, []db.Project{running}, now)
+	if store.usage[0].StoppedAt == nil || !store.usage[0].StoppedAt.Equal(now) {
+		t.Errorf("expected the failed project's interval closed, got %+v", store.usage[0])
+	}
+	if len(store.usage) != 2 || *store.usage[1].ProjectID != testProjectID || store.usage[1].StoppedAt != nil {
+		t.Errorf("expected an interval opened for the running project, got %+v", store.usage)
+	}
+
+	// Running it again changes nothing
+	handler.syncUsage(ctx, []db.Project{running}, now.Add(time.Minute))
+	if len(store.usage) != 2 {
+		t.Errorf("expected no duplicate interval, got %d", len(store.usage))
+	}
+}
+
+func TestUsageHandler(t *testing.T) {
+	store := newProjectFixture(db.StatusStopped)
+	projectID := testProjectID
+	start := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	stop := start.Add(3 * time.Hour)
+	store.usage = []db.UsageInterval{{ProjectID: &projectID, UserID: "test-user-id", CPUKind: "shared", CPUs: 2, MemoryMB: 2048, StartedAt: start, StoppedAt: &stop}}
+	handler := NewUsageHandler(store, metering.DefaultPrices())
+
+	router := chi.NewRouter()
+	router.Get("/user/usage", handler.GetUserUsage)
+	router.Get("/projects/{id}/usage", handler.GetProjectUsage)
+	serve := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newAuthenticatedRequest("GET", path, nil))
+		return rr
+	}
+
+	for _, path := range []string{"/user/usage", "/projects/" + testProjectID + "/usage"} {
+		rr := serve(path + "?from=2026-09-01&to=2026-09-03")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d: %s", path, http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var report metering.Report
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(report.Buckets) != 2 || report.Buckets[0].MachineHours != 3 || report.Total.VCPUHours != 6 || report.Total.Cost <= 0 {
+			t.Errorf("%s: expected 3 machine-hours on the first day, got %+v", path, report)
+		}
+	}
+
+	rr := serve("/user/usage?period=month&from=2026-09-01&to=2026-11-01&format=csv")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected CSV, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n"); len(lines) != 4 || !strings.HasPrefix(lines[1], "2026-09,3,6,") {
+		t.Errorf("unexpected CSV:\n%s", rr.Body.String())
+	}
+
+	rejected := []struct {
+		path string
+		code int
+	}{
+		{"/user/usage?period=week", http.StatusBadRequest},
+		{"/user/usage?from=2026-09-03&to=2026-09-01", http.StatusBadRequest},
+		{"/user/usage?from=2024-01-01&to=2026-01-01", http.StatusBadRequest},
+		{"/user/usage?from=yesterday", http.StatusBadRequest},
+		{"/user/usage?format=xml", http.StatusBadRequest},
+		{"/projects/7c9e6679-7425-40de-944b-e07fc1f90ae7/usage", http.StatusNotFound},
+	}
+	for _, tt := range rejected {
+		if rr := serve(tt.path); rr.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.path, tt.code, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestUsageHandler_ProjectUsageCoversAllMembers(t *testing.T) {
+	store := newOrgFixture(db.RoleViewer)
+	projectID := testProjectID
+	start := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	stop := start.Add(2 * time.Hour)
+	store.usage = []db.UsageInterval{{ProjectID: &projectID, UserID: "owner-id", CPUKind: "shared", CPUs: 1, MemoryMB: 1024, StartedAt: start, StoppedAt: &stop}}
+	handler := NewUsageHandler(store, metering.DefaultPrices())
+
+	router := chi.NewRouter()
+	router.Get("/user/usage", handler.GetUserUsage)
+	router.Get("/projects/{id}/usage", handler.GetProjectUsage)
+
+	hours := func(path string) float64 {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newAuthenticatedRequest("GET", path+"?from=2026-09-01&to=2026-09-02", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d: %s", path, http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var report metering.Report
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return report.Total.MachineHours
+	}
+
+	if got := hours("/projects/" + testProjectID + "/usage"); got != 2 {
+		t.Errorf("expected the project's 2 machine-hours recorded under its owner, got %v", got)
+	}
+	if got := hours("/user/usage"); got != 0 {
+		t.Errorf("expected no usage of the viewer's own, got %v", got)
+	}
+}
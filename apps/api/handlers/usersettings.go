@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"aether/apps/api/db"
@@ -13,7 +14,7 @@ import (
 // UserSettingsStore interface for database operations
 type UserSettingsStore interface {
 	GetUserSettings(ctx context.Context, userID string) (*db.UserSettings, error)
-	UpdateUserSettings(ctx context.Context, userID string, settings *db.UserSettings) (*db.UserSettings, error)
+	UpdateUserSettings(ctx context.Context, userID string, settings *db.UserSettings, ifVersion *int64) (*db.UserSettings, error)
 }
 
 // UserSettingsHandler handles user settings management
@@ -33,6 +34,7 @@ type HardwareSettingsResponse = HardwareConfig
 type UserSettingsResponse struct {
 	DefaultHardware           HardwareSettingsResponse `json:"default_hardware"`
 	DefaultIdleTimeoutMinutes *int                     `json:"default_idle_timeout_minutes,omitempty"`
+	Version                   int64                    `json:"version"`
 }
 
 // UpdateUserSettingsRequest is the request body for PUT /user/settings
@@ -65,16 +67,11 @@ func (h *UserSettingsHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	WriteJSON(w, http.StatusOK, UserSettingsResponse{
-		DefaultHardware: HardwareSettingsResponse{
-			CPUKind:      settings.DefaultCPUKind,
-			CPUs:         settings.DefaultCPUs,
-			MemoryMB:     settings.DefaultMemoryMB,
-			VolumeSizeGB: settings.DefaultVolumeSizeGB,
-			GPUKind:      settings.DefaultGPUKind,
-		},
-		DefaultIdleTimeoutMinutes: settings.DefaultIdleTimeoutMinutes,
-	})
+	if notModified(w, r, settings.Version) {
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, userSettingsToResponse(settings))
 }
 
 // Update updates the user's settings
@@ -86,6 +83,11 @@ func (h *UserSettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ifVersion, ok := readIfMatch(w, r)
+	if !ok {
+		return
+	}
+
 	var req UpdateUserSettingsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
@@ -141,20 +143,30 @@ func (h *UserSettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save updates
-	result, err := h.db.UpdateUserSettings(ctx, userID, updated)
+	result, err := h.db.UpdateUserSettings(ctx, userID, updated, ifVersion)
 	if err != nil {
+		if errors.Is(err, db.ErrVersionMismatch) {
+			writePreconditionFailed(w, 0)
+			return
+		}
 		WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to update user settings"})
 		return
 	}
 
-	WriteJSON(w, http.StatusOK, UserSettingsResponse{
+	w.Header().Set("ETag", versionETag(result.Version))
+	WriteJSON(w, http.StatusOK, userSettingsToResponse(result))
+}
+
+func userSettingsToResponse(s *db.UserSettings) UserSettingsResponse {
+	return UserSettingsResponse{
 		DefaultHardware: HardwareSettingsResponse{
-			CPUKind:      result.DefaultCPUKind,
-			CPUs:         result.DefaultCPUs,
-			MemoryMB:     result.DefaultMemoryMB,
-			VolumeSizeGB: result.DefaultVolumeSizeGB,
-			GPUKind:      result.DefaultGPUKind,
+			CPUKind:      s.DefaultCPUKind,
+			CPUs:         s.DefaultCPUs,
+			MemoryMB:     s.DefaultMemoryMB,
+			VolumeSizeGB: s.DefaultVolumeSizeGB,
+			GPUKind:      s.DefaultGPUKind,
 		},
-		DefaultIdleTimeoutMinutes: result.DefaultIdleTimeoutMinutes,
-	})
+		DefaultIdleTimeoutMinutes: s.DefaultIdleTimeoutMinutes,
+		Version:                   s.Version,
+	}
 }
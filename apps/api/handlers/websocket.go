@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -24,3 +26,18 @@ var upgrader = websocket.Upgrader{
 	},
 	Subprotocols: []string{"bearer"},
 }
+
+// writeWebSocketJSON sends v to the client as a text message, holding mu so it
+// doesn't interleave with a bridge's other writes
+func writeWebSocketJSON(conn *websocket.Conn, mu *sync.Mutex, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if err := conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
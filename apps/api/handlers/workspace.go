@@ -22,16 +22,18 @@ type WorkspaceHandler struct {
 	db               *db.Client
 	authMiddleware   *authmw.AuthMiddleware
 	apiKeys          APIKeysGetter
+	projectEnv       ProjectEnvProvider
 	lastAccessedMu   sync.Mutex
 	lastAccessedTime map[string]time.Time
 }
 
-func NewWorkspaceHandler(resolver ConnectionResolver, db *db.Client, authMiddleware *authmw.AuthMiddleware, apiKeys APIKeysGetter) *WorkspaceHandler {
+func NewWorkspaceHandler(resolver ConnectionResolver, db *db.Client, authMiddleware *authmw.AuthMiddleware, apiKeys APIKeysGetter, projectEnv ProjectEnvProvider) *WorkspaceHandler {
 	return &WorkspaceHandler{
 		resolver:         resolver,
 		db:               db,
 		authMiddleware:   authMiddleware,
 		apiKeys:          apiKeys,
+		projectEnv:       projectEnv,
 		lastAccessedTime: make(map[string]time.Time),
 	}
 }
@@ -103,6 +105,10 @@ func (h *WorkspaceHandler) HandleWorkspace(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Failed to get project", http.StatusInternalServerError)
 		return
 	}
+	if !db.RoleAtLeast(project.Role, roleOperate) {
+		http.Error(w, "Your role on this project doesn't allow this", http.StatusForbidden)
+		return
+	}
 
 	if project.Status != "running" {
 		http.Error(w, "Project is not running", http.StatusBadRequest)
@@ -137,7 +143,7 @@ func (h *WorkspaceHandler) HandleWorkspace(w http.ResponseWriter, r *http.Reques
 	log.Info("workspace websocket connected")
 
 	// Build environment variables for the agent (includes API keys)
-	agentEnv := NewEnvBuilder(h.apiKeys).BuildAgentEnv(ctx, projectID, userID)
+	agentEnv := NewEnvBuilder(h.apiKeys, h.projectEnv).BuildAgentEnv(ctx, projectID, userID)
 
 	// Add correlation IDs for workspace-service logging
 	if requestID := logging.GetRequestID(ctx); requestID != "" {
@@ -170,20 +176,24 @@ func (h *WorkspaceHandler) HandleWorkspace(w http.ResponseWriter, r *http.Reques
 	log.Info("workspace connector established")
 
 	// Bridge the frontend WebSocket with the VM connector
-	h.bridgeConnection(ctx, wsConn, connector, projectID)
+	h.bridgeConnection(ctx, wsConn, connector, projectID,
+		newUsageMeter(h.db, project, userID, defaultAgentType),
+		newBudgetGuard(h.db, userID, projectID, "agent", defaultAgentType))
 
 	log.Info("workspace session ended")
 }
 
-// bridgeConnection bridges the frontend WebSocket with the VM ProxyConnector
-func (h *WorkspaceHandler) bridgeConnection(ctx context.Context, wsConn *websocket.Conn, connector proxy.ProxyConnector, projectID string) {
+// bridgeConnection bridges the frontend WebSocket with the VM ProxyConnector,
+// metering the token usage the agent reports on the way through and holding
+// back prompts once an agent budget is used up
+func (h *WorkspaceHandler) bridgeConnection(ctx context.Context, wsConn *websocket.Conn, connector proxy.ProxyConnector, projectID string, meter *usageMeter, budgets *budgetGuard) {
 	defer h.clearLastAccessed(projectID)
 	log := logging.FromContext(ctx)
 
 	var wg sync.WaitGroup
 	var wsMu sync.Mutex
 
-	// Connector -> WebSocket (forward raw messages from VM to frontend)
+	// Connector -> WebSocket (forward raw messages from VM to frontend, then meter them)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -205,6 +215,14 @@ func (h *WorkspaceHandler) bridgeConnection(ctx context.Context, wsConn *websock
 					log.Debug("websocket write error", "error", err)
 					return
 				}
+				if usage := meter.observe(ctx, data); usage != nil {
+					for _, notice := range budgets.afterUsage(ctx, usage) {
+						if err := writeWebSocketJSON(wsConn, &wsMu, notice); err != nil {
+							log.Debug("websocket write error", "error", err)
+							return
+						}
+					}
+				}
 				// Update last accessed on activity
 				go h.updateLastAccessedDebounced(ctx, projectID)
 			case <-connector.Done():
@@ -245,6 +263,15 @@ func (h *WorkspaceHandler) bridgeConnection(ctx context.Context, wsConn *websock
 			// Update last accessed on activity
 			go h.updateLastAccessedDebounced(ctx, projectID)
 
+			// Prompts over an agent budget go no further
+			if refusal := budgets.checkPrompt(ctx, data); refusal != nil {
+				if err := writeWebSocketJSON(wsConn, &wsMu, refusal); err != nil {
+					log.Debug("websocket write error", "error", err)
+					return
+				}
+				continue
+			}
+
 			if err := connector.Send(ctx, data); err != nil {
 				log.Debug("connector send error", "error", err)
 				return
@@ -0,0 +1,91 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"aether/apps/api/config"
+	"aether/apps/api/db"
+	"aether/apps/api/objectstore"
+)
+
+// VolumeArchiver implements handlers.VolumeArchiver for local development by
+// archiving the volume's directory from the host
+type VolumeArchiver struct {
+	store objectstore.Store
+}
+
+func NewVolumeArchiver(store objectstore.Store) *VolumeArchiver {
+	return &VolumeArchiver{store: store}
+}
+
+// volumeDir returns the host directory backing a project's volume
+func volumeDir(project *db.Project) (string, error) {
+	if project.FlyVolumeID == nil || *project.FlyVolumeID == "" {
+		return "", fmt.Errorf("project %s has no volume", project.ID)
+	}
+	return filepath.Join(config.GetLocalProjectDir(), strings.TrimPrefix(*project.FlyVolumeID, "local-vol-")), nil
+}
+
+// ArchiveVolume tars the volume's directory into a temporary file and uploads it
+func (a *VolumeArchiver) ArchiveVolume(ctx context.Context, project *db.Project, key string) (int64, error) {
+	dir, err := volumeDir(project)
+	if err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp("", "aether-archive-*.tar.gz")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	cmd := exec.CommandContext(ctx, "tar", "-czf", tmp.Name(), "-C", dir, ".")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return 0, fmt.Errorf("failed to archive volume: %w\nOutput: %s", err, string(output))
+	}
+
+	info, err := tmp.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat archive: %w", err)
+	}
+	if err := a.store.Put(ctx, key, tmp, info.Size()); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// RestoreVolume unpacks the archive into the volume's directory
+func (a *VolumeArchiver) RestoreVolume(ctx context.Context, project *db.Project, key string) error {
+	dir, err := volumeDir(project)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	body, err := a.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	cmd := exec.CommandContext(ctx, "tar", "-xzf", "-", "-C", dir)
+	cmd.Stdin = body
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to restore volume: %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
+
+func (a *VolumeArchiver) DeleteArchive(ctx context.Context, key string) error {
+	return a.store.Delete(ctx, key)
+}
@@ -1,6 +1,9 @@
 package local
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os/exec"
@@ -88,6 +91,11 @@ func (m *MachineManager) CreateMachine(name string, cfg handlers.MachineConfig)
 		args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
 	}
 
+	// Add project labels as container labels
+	for k, v := range cfg.Labels {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", k, v))
+	}
+
 	args = append(args, image)
 
 	log.Printf("[LOCAL] Creating Docker container: docker %s", strings.Join(args, " "))
@@ -118,6 +126,15 @@ func (m *MachineManager) CreateMachine(name string, cfg handlers.MachineConfig)
 	}, nil
 }
 
+// UpdateMachine recreates the container with the new config. The volume lives on
+// the host, so the workspace carries over; the container is left stopped.
+func (m *MachineManager) UpdateMachine(machineID string, cfg handlers.MachineConfig) error {
+	if _, err := m.CreateMachine(strings.TrimPrefix(machineID, "local-"), cfg); err != nil {
+		return err
+	}
+	return m.StopMachine(machineID)
+}
+
 // parseContainerID extracts the container ID from docker run output
 // The output may contain warnings before the actual container ID
 func parseContainerID(output string) string {
@@ -246,17 +263,21 @@ func (m *MachineManager) StopMachine(machineID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// Containers from before an API restart aren't tracked; their name is the machine ID
+	containerID := machineID
 	state, ok := m.machines[machineID]
-	if !ok {
-		return fmt.Errorf("machine %s not found", machineID)
+	if ok {
+		containerID = state.ContainerID
 	}
 
-	cmd := exec.Command("docker", "stop", state.ContainerID)
+	cmd := exec.Command("docker", "stop", containerID)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to stop container: %w\nOutput: %s", err, string(output))
 	}
 
-	state.State = "stopped"
+	if ok {
+		state.State = "stopped"
+	}
 	log.Printf("[LOCAL] Stopped container %s", machineID)
 	return nil
 }
@@ -265,12 +286,12 @@ func (m *MachineManager) DeleteMachine(machineID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	state, ok := m.machines[machineID]
-	if !ok {
-		return fmt.Errorf("machine %s not found in local manager", machineID)
+	containerID := machineID
+	if state, ok := m.machines[machineID]; ok {
+		containerID = state.ContainerID
 	}
 
-	cmd := exec.Command("docker", "rm", "-f", state.ContainerID)
+	cmd := exec.Command("docker", "rm", "-f", containerID)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to delete container: %w\nOutput: %s", err, string(output))
 	}
@@ -303,3 +324,36 @@ func (m *MachineManager) WaitForState(machineID string, targetState string, time
 
 	return fmt.Errorf("timeout waiting for machine %s to reach state %s", machineID, targetState)
 }
+
+// Exec runs a command in the container with docker exec
+func (m *MachineManager) Exec(machineID string, command []string, timeout time.Duration) (*handlers.ExecResult, error) {
+	m.mu.RLock()
+	containerID := machineID
+	if state, ok := m.machines[machineID]; ok {
+		containerID = state.ContainerID
+	}
+	m.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", append([]string{"exec", containerID}, command...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("exec in container %s timed out after %s", machineID, timeout)
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to exec in container: %w", err)
+	}
+
+	return &handlers.ExecResult{
+		ExitCode: cmd.ProcessState.ExitCode(),
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+	}, nil
+}
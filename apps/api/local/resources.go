@@ -0,0 +1,94 @@
+package local
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"aether/apps/api/config"
+	"aether/apps/api/handlers"
+)
+
+// dockerTimeLayout is the format of {{.CreatedAt}} in docker ps output
+const dockerTimeLayout = "2006-01-02 15:04:05 -0700 MST"
+
+// ResourceLister implements handlers.ResourceLister by querying Docker and the
+// local project directory, so containers and volumes from earlier runs are included
+type ResourceLister struct{}
+
+func NewResourceLister() *ResourceLister {
+	return &ResourceLister{}
+}
+
+func (l *ResourceLister) ListMachines() ([]handlers.Machine, error) {
+	cmd := exec.Command("docker", "ps", "-a",
+		"--filter", "name=^local-",
+		"--format", "{{.Names}}\t{{.State}}\t{{.CreatedAt}}")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker ps failed: %w", err)
+	}
+
+	var machines []handlers.Machine
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+
+		// Machine IDs are the container names, "local-<machine name>"
+		id := fields[0]
+		state := fields[1]
+		if state == "running" {
+			state = "started"
+		} else if state == "exited" {
+			state = "stopped"
+		}
+
+		createdAt := ""
+		if t, err := time.Parse(dockerTimeLayout, fields[2]); err == nil {
+			createdAt = t.Format(time.RFC3339)
+		}
+
+		machines = append(machines, handlers.Machine{
+			ID:        id,
+			Name:      strings.TrimPrefix(id, "local-"),
+			State:     state,
+			Region:    "local",
+			CreatedAt: createdAt,
+		})
+	}
+	return machines, nil
+}
+
+func (l *ResourceLister) ListVolumes() ([]handlers.Volume, error) {
+	entries, err := os.ReadDir(config.GetLocalProjectDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read local project directory: %w", err)
+	}
+
+	var volumes []handlers.Volume
+	for _, entry := range entries {
+		// Hidden directories hold snapshots and restores in progress, not volumes
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		volumes = append(volumes, handlers.Volume{
+			ID:        "local-vol-" + entry.Name(),
+			Name:      entry.Name(),
+			Region:    "local",
+			State:     "created",
+			CreatedAt: info.ModTime().Format(time.RFC3339),
+		})
+	}
+	return volumes, nil
+}
@@ -3,7 +3,9 @@ package local
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,6 +13,9 @@ import (
 	"aether/apps/api/handlers"
 )
 
+// snapshotDirName is the directory under the local project dir holding snapshot tarballs
+const snapshotDirName = ".snapshots"
+
 // VolumeManager implements handlers.VolumeManager for local development.
 // It creates local directories instead of Fly.io volumes.
 type VolumeManager struct {
@@ -67,3 +72,160 @@ func (v *VolumeManager) DeleteVolume(volumeID string) error {
 	// Note: We don't delete the local directory to preserve data
 	return nil
 }
+
+// ExtendVolume records the new size; local directories aren't size-limited
+func (v *VolumeManager) ExtendVolume(volumeID string, sizeGB int) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if volume, ok := v.volumes[volumeID]; ok && sizeGB > volume.SizeGB {
+		volume.SizeGB = sizeGB
+	}
+	return nil
+}
+
+// ForkVolume copies the source volume's directory into a new volume directory
+func (v *VolumeManager) ForkVolume(sourceVolumeID, name string) (*handlers.Volume, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	projectDir := config.GetLocalProjectDir()
+	sourceDir := filepath.Join(projectDir, strings.TrimPrefix(sourceVolumeID, "local-vol-"))
+	if _, err := os.Stat(sourceDir); err != nil {
+		return nil, fmt.Errorf("source volume %s not found: %w", sourceVolumeID, err)
+	}
+
+	localDir := filepath.Join(projectDir, name)
+	if _, err := os.Stat(localDir); err == nil {
+		return nil, fmt.Errorf("volume directory %s already exists", localDir)
+	}
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		return nil, err
+	}
+
+	// cp -a preserves permissions, ownership and symlinks
+	cmd := exec.Command("cp", "-a", sourceDir+"/.", localDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		_ = os.RemoveAll(localDir)
+		return nil, fmt.Errorf("failed to copy volume: %w\nOutput: %s", err, string(output))
+	}
+
+	volume := &handlers.Volume{
+		ID:        "local-vol-" + name,
+		Name:      name,
+		Region:    "local",
+		State:     "created",
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if source, ok := v.volumes[sourceVolumeID]; ok {
+		volume.SizeGB = source.SizeGB
+	}
+	v.volumes[volume.ID] = volume
+	return volume, nil
+}
+
+// CopyVolume forks the volume; local volumes are all in the one "local" region
+func (v *VolumeManager) CopyVolume(sourceVolumeID, name, region string) (*handlers.Volume, error) {
+	return v.ForkVolume(sourceVolumeID, name)
+}
+
+// snapshotPath returns the tarball path for a local snapshot ID
+func snapshotPath(snapshotID string) string {
+	return filepath.Join(config.GetLocalProjectDir(), snapshotDirName, snapshotID+".tar.gz")
+}
+
+// CreateSnapshot archives the volume's directory into a gzipped tarball
+func (v *VolumeManager) CreateSnapshot(volumeID string) (*handlers.Snapshot, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	name := strings.TrimPrefix(volumeID, "local-vol-")
+	volumeDir := filepath.Join(config.GetLocalProjectDir(), name)
+	if _, err := os.Stat(volumeDir); err != nil {
+		return nil, fmt.Errorf("volume %s not found: %w", volumeID, err)
+	}
+
+	now := time.Now()
+	id := fmt.Sprintf("local-snap-%s-%d", name, now.UnixNano())
+	path := snapshotPath(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("tar", "-czf", path, "-C", volumeDir, ".")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("failed to archive volume: %w\nOutput: %s", err, string(output))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &handlers.Snapshot{
+		ID:        id,
+		VolumeID:  volumeID,
+		SizeBytes: info.Size(),
+		CreatedAt: now.Format(time.RFC3339),
+	}, nil
+}
+
+func (v *VolumeManager) DeleteSnapshot(snapshotID string) error {
+	if err := os.Remove(snapshotPath(snapshotID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete snapshot %s: %w", snapshotID, err)
+	}
+	return nil
+}
+
+// RestoreSnapshot replaces the volume's directory with the snapshot's contents in place.
+// The tarball is unpacked beside the volume first so a failed extract leaves it untouched.
+func (v *VolumeManager) RestoreSnapshot(volumeID, snapshotID, name string) (*handlers.Volume, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	path := snapshotPath(snapshotID)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("snapshot %s not found: %w", snapshotID, err)
+	}
+
+	projectDir := config.GetLocalProjectDir()
+	volumeName := strings.TrimPrefix(volumeID, "local-vol-")
+	volumeDir := filepath.Join(projectDir, volumeName)
+	restoreDir := filepath.Join(projectDir, ".restore-"+volumeName)
+	oldDir := filepath.Join(projectDir, ".old-"+volumeName)
+
+	_ = os.RemoveAll(restoreDir)
+	if err := os.MkdirAll(restoreDir, 0755); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("tar", "-xzf", path, "-C", restoreDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		_ = os.RemoveAll(restoreDir)
+		return nil, fmt.Errorf("failed to extract snapshot: %w\nOutput: %s", err, string(output))
+	}
+
+	_ = os.RemoveAll(oldDir)
+	if err := os.Rename(volumeDir, oldDir); err != nil && !os.IsNotExist(err) {
+		_ = os.RemoveAll(restoreDir)
+		return nil, fmt.Errorf("failed to move volume aside: %w", err)
+	}
+	if err := os.Rename(restoreDir, volumeDir); err != nil {
+		_ = os.Rename(oldDir, volumeDir)
+		return nil, fmt.Errorf("failed to move restored volume into place: %w", err)
+	}
+	_ = os.RemoveAll(oldDir)
+
+	if volume, ok := v.volumes[volumeID]; ok {
+		return volume, nil
+	}
+	volume := &handlers.Volume{
+		ID:        volumeID,
+		Name:      volumeName,
+		Region:    "local",
+		State:     "created",
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	v.volumes[volumeID] = volume
+	return volume, nil
+}
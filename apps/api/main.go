@@ -11,7 +11,10 @@ import (
 	"aether/apps/api/db"
 	"aether/apps/api/fly"
 	"aether/apps/api/handlers"
+	"aether/apps/api/metering"
 	authmw "aether/apps/api/middleware"
+	"aether/apps/api/objectstore"
+	"aether/apps/api/regions"
 	"aether/apps/api/workspace"
 	"aether/libs/go/logging"
 
@@ -77,6 +80,26 @@ func main() {
 	}
 	idleTimeoutMin := getEnvInt("IDLE_TIMEOUT_MINUTES", 10)
 
+	// Regions projects can be placed in (PROJECT_REGIONS), defaulting to FLY_REGION plus GPUs in ord
+	regionCatalog := regions.Default(flyRegion)
+	if spec := os.Getenv("PROJECT_REGIONS"); spec != "" {
+		regionCatalog, err = regions.Parse([]byte(spec), flyRegion)
+		if err != nil {
+			logger.Error("invalid PROJECT_REGIONS", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Hourly prices usage reports charge at (USAGE_PRICES), over the built-in defaults
+	usagePrices := metering.DefaultPrices()
+	if spec := os.Getenv("USAGE_PRICES"); spec != "" {
+		usagePrices, err = metering.ParsePrices([]byte(spec))
+		if err != nil {
+			logger.Error("invalid USAGE_PRICES", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	flyClient := fly.NewClient(flyToken, flyAppName, flyRegion)
 
 	// Initialize database client
@@ -99,9 +122,10 @@ func main() {
 	}
 	logger.Info("auth middleware initialized with JWKS")
 
-	// Initialize encryption service (optional - if key not set, API keys feature is disabled)
+	// Initialize encryption service (optional - if key not set, API keys and project env vars are disabled)
 	var encryptor *crypto.Encryptor
 	var apiKeysHandler *handlers.APIKeysHandler
+	var projectEnvHandler *handlers.ProjectEnvHandler
 	if os.Getenv("ENCRYPTION_MASTER_KEY") != "" {
 		encryptor, err = crypto.NewEncryptor()
 		if err != nil {
@@ -109,27 +133,91 @@ func main() {
 			os.Exit(1)
 		}
 		apiKeysHandler = handlers.NewAPIKeysHandler(dbClient, encryptor)
+		projectEnvHandler = handlers.NewProjectEnvHandler(dbClient, encryptor)
 		logger.Info("encryption service initialized")
 	} else {
-		logger.Warn("ENCRYPTION_MASTER_KEY not set, API keys feature disabled")
+		logger.Warn("ENCRYPTION_MASTER_KEY not set, API keys and project env vars disabled")
 	}
 	// Convert to interface safely (avoids Go's typed-nil interface gotcha)
 	apiKeysGetter := asAPIKeysGetter(apiKeysHandler)
+	projectEnvProvider := asProjectEnvProvider(projectEnvHandler)
 
 	idleTimeout := time.Duration(idleTimeoutMin) * time.Minute
 
 	// Create workspace factory (returns local or Fly implementations based on LOCAL_MODE)
 	wsFactory := workspace.NewFactory(flyClient)
 
+	// Share one machine/volume manager so local mode tracks containers in one place
+	machineManager := wsFactory.MachineManager()
+	volumeManager := wsFactory.VolumeManager()
+
+	// Cold storage for hibernated projects (optional - hibernation is disabled without it)
+	archiveStore, err := newArchiveStore(cfg)
+	if err != nil {
+		logger.Error("failed to initialize archive store", "error", err)
+		os.Exit(1)
+	}
+	volumeArchiver := wsFactory.VolumeArchiver(machineManager, archiveStore)
+
 	// New project-based handlers
-	projectHandler := handlers.NewProjectHandler(dbClient, wsFactory.MachineManager(), wsFactory.VolumeManager(), apiKeysGetter, baseImage, flyRegion, idleTimeout)
-	agentHandler := handlers.NewAgentHandler(wsFactory.ConnectionResolver(), dbClient, authMiddleware, apiKeysGetter)
-	workspaceHandler := handlers.NewWorkspaceHandler(wsFactory.ConnectionResolver(), dbClient, authMiddleware, apiKeysGetter)
+	projectHandler := handlers.NewProjectHandler(dbClient, machineManager, volumeManager, volumeArchiver, wsFactory.WorkspaceProbe(), apiKeysGetter, projectEnvProvider, baseImage, regionCatalog, idleTimeout)
+	agentHandler := handlers.NewAgentHandler(wsFactory.ConnectionResolver(), dbClient, authMiddleware, apiKeysGetter, projectEnvProvider)
+	workspaceHandler := handlers.NewWorkspaceHandler(wsFactory.ConnectionResolver(), dbClient, authMiddleware, apiKeysGetter, projectEnvProvider)
 	healthHandler := handlers.NewHealthHandler(dbClient, getEnv("VERSION", "dev"))
 
 	// Start idle project checker
 	projectHandler.StartIdleChecker(1 * time.Minute)
 
+	// Run project start/stop schedules
+	projectHandler.StartScheduler(30 * time.Second)
+
+	// Archive projects nobody has opened in a long time
+	if hibernateAfterDays := getEnvInt("HIBERNATE_AFTER_DAYS", 90); volumeArchiver != nil && hibernateAfterDays > 0 {
+		projectHandler.StartHibernator(1*time.Hour, time.Duration(hibernateAfterDays)*24*time.Hour)
+	} else {
+		logger.Info("project hibernation disabled")
+	}
+
+	// Forget snapshots the backend has discarded after its retention period
+	projectHandler.StartSnapshotCleanup(1 * time.Hour)
+
+	// Deleted projects stay in the trash for the retention period before they are purged
+	trashHandler := handlers.NewTrashHandler(dbClient, projectHandler, time.Duration(getEnvInt("TRASH_RETENTION_DAYS", 7))*24*time.Hour)
+	trashHandler.StartPurger(15 * time.Minute)
+
+	// Resume lifecycle operations abandoned by crashed replicas
+	projectHandler.StartOperationWorker(15 * time.Second)
+
+	// Reconcile database state against the compute backend
+	reconcileHandler := handlers.NewReconcileHandler(dbClient, machineManager, volumeManager, wsFactory.ResourceLister(), handlers.ReconcileConfig{
+		OrphanPolicy:      getEnv("RECONCILE_ORPHAN_POLICY", handlers.OrphanPolicyReport),
+		OrphanGracePeriod: time.Duration(getEnvInt("RECONCILE_ORPHAN_GRACE_MINUTES", 60)) * time.Minute,
+	})
+	reconcileHandler.StartReconciler(time.Duration(getEnvInt("RECONCILE_INTERVAL_MINUTES", 5)) * time.Minute)
+
+	// Roll new base images out across existing projects
+	rolloutHandler := handlers.NewRolloutHandler(dbClient, projectHandler)
+	rolloutHandler.StartRolloutWorker(30 * time.Second)
+	adminUserIDs := authmw.ParseAdminUserIDs(os.Getenv("ADMIN_USER_IDS"))
+
+	// Plan limits on what each user can create and run
+	quotaHandler := handlers.NewQuotaHandler(dbClient)
+
+	// Machine usage and cost reports from the usage ledger
+	usageHandler := handlers.NewUsageHandler(dbClient, usagePrices)
+
+	// Agent token usage and cost, as metered by the agent and workspace bridges
+	llmUsageHandler := handlers.NewLLMUsageHandler(dbClient)
+
+	// Fan out project lifecycle events to SSE/WebSocket subscribers
+	eventBroker := handlers.NewEventBroker(dbClient)
+	eventBroker.Start(1 * time.Second)
+	eventsHandler := handlers.NewEventsHandler(eventBroker, dbClient, authMiddleware)
+
+	// Replay responses to retried project mutations that carry an Idempotency-Key
+	idempotencyHandler := handlers.NewIdempotencyHandler(dbClient, time.Duration(getEnvInt("IDEMPOTENCY_KEY_TTL_HOURS", 24))*time.Hour)
+	idempotencyHandler.StartCleanup(1 * time.Hour)
+
 	r := chi.NewRouter()
 
 	// Create Sentry HTTP handler for panic recovery and request context
@@ -143,7 +231,7 @@ func main() {
 	// 3. Sentry - attaches hub to context, captures panics
 	// 4. RequestLogger - logs requests and enriches context with request_id
 	// 5. Recoverer - catches panics (after Sentry captures them)
-	// 6. Timeout - limits request duration
+	// Timeout is applied per route group below so streaming endpoints aren't cut off
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(func(next http.Handler) http.Handler {
@@ -151,36 +239,79 @@ func main() {
 	})
 	r.Use(logging.RequestLogger(logger))
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match", "If-None-Match", handlers.IdempotencyKeyHeader},
+		ExposedHeaders:   []string{"ETag", handlers.IdempotentReplayedHeader},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
 	// Health check routes
-	r.Get("/health", healthHandler.Health)
-	r.Get("/healthz", healthHandler.Liveness)
-	r.Get("/ready", healthHandler.Readiness)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(60 * time.Second))
+		r.Get("/health", healthHandler.Health)
+		r.Get("/healthz", healthHandler.Liveness)
+		r.Get("/ready", healthHandler.Readiness)
+	})
 
 	// Protected project routes (require auth)
 	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(60 * time.Second))
 		r.Use(authMiddleware.Authenticate)
 
 		r.Route("/projects", func(r chi.Router) {
+			r.Use(idempotencyHandler.Middleware)
 			r.Get("/", projectHandler.List)
 			r.Post("/", projectHandler.Create)
+			r.Get("/trash", trashHandler.List)
 			r.Get("/{id}", projectHandler.Get)
 			r.Patch("/{id}", projectHandler.Update)
+			r.Put("/{id}/labels", projectHandler.SetLabels)
 			r.Delete("/{id}", projectHandler.Delete)
+			r.Post("/{id}/restore", trashHandler.Restore)
 			r.Post("/{id}/start", projectHandler.Start)
 			r.Post("/{id}/stop", projectHandler.Stop)
+			r.Patch("/{id}/hardware", projectHandler.UpdateHardware)
+			r.Post("/{id}/move", projectHandler.Move)
+			r.Put("/{id}/organization", projectHandler.Transfer)
+			r.Post("/{id}/upgrade", projectHandler.Upgrade)
+			r.Get("/{id}/schedules", projectHandler.ListSchedules)
+			r.Post("/{id}/schedules", projectHandler.CreateSchedule)
+			r.Patch("/{id}/schedules/{scheduleId}", projectHandler.UpdateSchedule)
+			r.Delete("/{id}/schedules/{scheduleId}", projectHandler.DeleteSchedule)
+			r.Post("/{id}/idle/snooze", projectHandler.SnoozeIdle)
+			r.Post("/{id}/fork", projectHandler.Fork)
+			r.Get("/{id}/snapshots", projectHandler.ListSnapshots)
+			r.Post("/{id}/snapshots", projectHandler.CreateSnapshot)
+			r.Delete("/{id}/snapshots/{snapshotId}", projectHandler.DeleteSnapshot)
+			r.Post("/{id}/snapshots/{snapshotId}/restore", projectHandler.RestoreSnapshot)
+			r.Get("/{id}/operations/{opId}", projectHandler.GetOperation)
+			r.Get("/{id}/usage", usageHandler.GetProjectUsage)
+			r.Get("/{id}/llm-usage", llmUsageHandler.GetProjectLLMUsage)
+			// Env vars are encrypted, so they need the encryption service
+			if projectEnvHandler != nil {
+				r.Get("/{id}/env", projectEnvHandler.List)
+				r.Put("/{id}/env", projectEnvHandler.Set)
+				r.Delete("/{id}/env", projectEnvHandler.Clear)
+				r.Delete("/{id}/env/{name}", projectEnvHandler.Delete)
+			}
 			// File and port operations are now handled via WebSocket (workspace endpoint)
 		})
 
+		r.Get("/regions", projectHandler.ListRegions)
+
+		// Project templates (built-in and user-defined)
+		templateHandler := handlers.NewTemplateHandler(dbClient)
+		r.Route("/templates", func(r chi.Router) {
+			r.Get("/", templateHandler.List)
+			r.Post("/", templateHandler.Create)
+			r.Get("/{id}", templateHandler.Get)
+			r.Delete("/{id}", templateHandler.Delete)
+		})
+
 		// User API keys routes
 		if apiKeysHandler != nil {
 			r.Route("/user/api-keys", func(r chi.Router) {
@@ -196,8 +327,67 @@ func main() {
 			r.Get("/", userSettingsHandler.Get)
 			r.Put("/", userSettingsHandler.Update)
 		})
+
+		// Usage against the user's plan, machine usage over time and agent spend
+		r.Get("/user/quota", quotaHandler.Get)
+		r.Get("/user/usage", usageHandler.GetUserUsage)
+		r.Get("/user/llm-usage", llmUsageHandler.GetUserLLMUsage)
+
+		// Monthly caps on agent spend, enforced by the agent and workspace bridges
+		agentBudgetHandler := handlers.NewAgentBudgetHandler(dbClient)
+		r.Route("/user/agent-budgets", func(r chi.Router) {
+			r.Get("/", agentBudgetHandler.List)
+			r.Post("/", agentBudgetHandler.Create)
+			r.Get("/{budgetId}", agentBudgetHandler.Get)
+			r.Patch("/{budgetId}", agentBudgetHandler.Update)
+			r.Delete("/{budgetId}", agentBudgetHandler.Delete)
+			r.Post("/{budgetId}/reset", agentBudgetHandler.Reset)
+		})
+
+		// Organizations share projects among their members by role
+		organizationHandler := handlers.NewOrganizationHandler(dbClient)
+		r.Route("/organizations", func(r chi.Router) {
+			r.Get("/", organizationHandler.List)
+			r.Post("/", organizationHandler.Create)
+			r.Get("/{orgId}", organizationHandler.Get)
+			r.Patch("/{orgId}", organizationHandler.Update)
+			r.Delete("/{orgId}", organizationHandler.Delete)
+			r.Get("/{orgId}/settings", organizationHandler.GetSettings)
+			r.Get("/{orgId}/quota", quotaHandler.GetOrganization)
+			r.Put("/{orgId}/settings", organizationHandler.UpdateSettings)
+			r.Get("/{orgId}/members", organizationHandler.ListMembers)
+			r.Patch("/{orgId}/members/{userId}", organizationHandler.UpdateMember)
+			r.Delete("/{orgId}/members/{userId}", organizationHandler.RemoveMember)
+			r.Get("/{orgId}/invitations", organizationHandler.ListInvitations)
+			r.Post("/{orgId}/invitations", organizationHandler.CreateInvitation)
+			r.Delete("/{orgId}/invitations/{invitationId}", organizationHandler.DeleteInvitation)
+		})
+		r.Route("/user/invitations", func(r chi.Router) {
+			r.Get("/", organizationHandler.ListUserInvitations)
+			r.Post("/{invitationId}/accept", organizationHandler.AcceptInvitation)
+			r.Delete("/{invitationId}", organizationHandler.DeclineInvitation)
+		})
+
+		// Operator routes
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(authmw.RequireAdmin(adminUserIDs))
+			r.Get("/reconcile", reconcileHandler.Report)
+			r.Get("/rollouts", rolloutHandler.List)
+			r.Post("/rollouts", rolloutHandler.Create)
+			r.Get("/rollouts/{id}", rolloutHandler.Get)
+			r.Post("/rollouts/{id}/pause", rolloutHandler.Pause)
+			r.Post("/rollouts/{id}/resume", rolloutHandler.Resume)
+			r.Post("/rollouts/{id}/rollback", rolloutHandler.Rollback)
+			r.Get("/plans", quotaHandler.ListPlans)
+			r.Put("/users/{userId}/plan", quotaHandler.SetUserPlan)
+			r.Put("/organizations/{orgId}/plan", quotaHandler.SetOrganizationPlan)
+		})
 	})
 
+	// Project lifecycle event streams handle their own auth (bearer header, subprotocol or ?token=)
+	r.Get("/events", eventsHandler.Stream)
+	r.Get("/events/ws", eventsHandler.HandleWebSocket)
+
 	// Agent endpoint handles its own auth (WebSocket subprotocol - legacy)
 	r.Get("/projects/{id}/agent/{agent}", agentHandler.HandleAgent)
 
@@ -210,6 +400,7 @@ func main() {
 		"port", port,
 		"fly_app", flyAppName,
 		"fly_region", flyRegion,
+		"default_region", regionCatalog.DefaultRegion(),
 		"base_image", baseImage,
 		"idle_timeout_minutes", idleTimeoutMin,
 	)
@@ -245,6 +436,36 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// newArchiveStore returns the object store for project archives: an S3-compatible
+// bucket if ARCHIVE_S3_BUCKET is set, otherwise in local mode a directory if
+// ARCHIVE_DIR is set, otherwise nil
+func newArchiveStore(cfg *config.Config) (objectstore.Store, error) {
+	if bucket := os.Getenv("ARCHIVE_S3_BUCKET"); bucket != "" {
+		store, err := objectstore.NewS3Store(objectstore.S3Config{
+			Endpoint:        getEnv("ARCHIVE_S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:          os.Getenv("ARCHIVE_S3_REGION"),
+			Bucket:          bucket,
+			AccessKeyID:     os.Getenv("ARCHIVE_S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("ARCHIVE_S3_SECRET_ACCESS_KEY"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+
+	// Machines can't reach a directory on the API's host, so only local mode can use one
+	if dir := os.Getenv("ARCHIVE_DIR"); dir != "" && cfg.LocalMode {
+		store, err := objectstore.NewFileStore(dir)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+
+	return nil, nil
+}
+
 // asAPIKeysGetter safely converts *APIKeysHandler to APIKeysGetter interface.
 // This avoids Go's typed-nil interface gotcha where a nil concrete pointer
 // assigned to an interface makes the interface non-nil.
@@ -254,3 +475,11 @@ func asAPIKeysGetter(h *handlers.APIKeysHandler) handlers.APIKeysGetter {
 	}
 	return h
 }
+
+// asProjectEnvProvider safely converts *ProjectEnvHandler to ProjectEnvProvider interface
+func asProjectEnvProvider(h *handlers.ProjectEnvHandler) handlers.ProjectEnvProvider {
+	if h == nil {
+		return nil
+	}
+	return h
+}
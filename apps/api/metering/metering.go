@@ -0,0 +1,251 @@
+// Package metering turns the usage ledger into machine-hours and cost, by day
+// or by month, and prices the tokens agents use.
+package metering
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"aether/apps/api/db"
+	"aether/apps/api/validation"
+)
+
+// Report periods
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// Prices are hourly list prices. A GPU machine is charged its GPU price alone,
+// which includes the compute that comes with it.
+type Prices struct {
+	Currency string `json:"currency"`
+	// CPUHour is the price of one vCPU for an hour, by CPU kind
+	CPUHour map[string]float64 `json:"cpu_hour"`
+	// MemoryGBHour is the price of one GB of memory for an hour
+	MemoryGBHour float64 `json:"memory_gb_hour"`
+	// GPUHour is the price of a GPU machine for an hour, by GPU kind
+	GPUHour map[string]float64 `json:"gpu_hour"`
+}
+
+// DefaultPrices returns prices close to Fly.io's on-demand rates
+func DefaultPrices() *Prices {
+	return &Prices{
+		Currency:     "USD",
+		CPUHour:      map[string]float64{"shared": 0.0028, "performance": 0.0431},
+		MemoryGBHour: 0.0069,
+		GPUHour:      map[string]float64{"a10": 1.50, "l40s": 1.25, "a100-40gb": 2.50, "a100-80gb": 3.50},
+	}
+}
+
+// ParsePrices reads a JSON price table. Anything it leaves out keeps its
+// default price.
+func ParsePrices(data []byte) (*Prices, error) {
+	var override Prices
+	if err := json.Unmarshal(data, &override); err != nil {
+		return nil, fmt.Errorf("invalid price table: %w", err)
+	}
+
+	prices := DefaultPrices()
+	if override.Currency != "" {
+		prices.Currency = override.Currency
+	}
+	for kind, price := range override.CPUHour {
+		if err := validation.ValidateCPUKind(kind); err != nil {
+			return nil, fmt.Errorf("cpu_hour: %s", err.Message)
+		}
+		if price < 0 {
+			return nil, fmt.Errorf("cpu_hour: %s price can't be negative", kind)
+		}
+		prices.CPUHour[kind] = price
+	}
+	if override.MemoryGBHour < 0 {
+		return nil, fmt.Errorf("memory_gb_hour can't be negative")
+	}
+	if override.MemoryGBHour > 0 {
+		prices.MemoryGBHour = override.MemoryGBHour
+	}
+	for kind, price := range override.GPUHour {
+		if err := validation.ValidateGPUKind(kind); err != nil {
+			return nil, fmt.Errorf("gpu_hour: %s", err.Message)
+		}
+		if price < 0 {
+			return nil, fmt.Errorf("gpu_hour: %s price can't be negative", kind)
+		}
+		prices.GPUHour[kind] = price
+	}
+	return prices, nil
+}
+
+// HourlyRate is what an hour of the interval's machine costs
+func (p *Prices) HourlyRate(interval *db.UsageInterval) float64 {
+	if interval.GPUKind != nil && *interval.GPUKind != "" {
+		return p.GPUHour[*interval.GPUKind]
+	}
+	return float64(interval.CPUs)*p.CPUHour[interval.CPUKind] + float64(interval.MemoryMB)/1024*p.MemoryGBHour
+}
+
+// Usage is how much machine time was used, and what it cost
+type Usage struct {
+	MachineHours  float64 `json:"machine_hours"`
+	VCPUHours     float64 `json:"vcpu_hours"`
+	MemoryGBHours float64 `json:"memory_gb_hours"`
+	GPUHours      float64 `json:"gpu_hours"`
+	Cost          float64 `json:"cost"`
+}
+
+func (u *Usage) add(interval *db.UsageInterval, hours, rate float64) {
+	u.MachineHours += hours
+	u.VCPUHours += hours * float64(interval.CPUs)
+	u.MemoryGBHours += hours * float64(interval.MemoryMB) / 1024
+	if interval.GPUKind != nil && *interval.GPUKind != "" {
+		u.GPUHours += hours
+	}
+	u.Cost += hours * rate
+}
+
+func (u Usage) rounded() Usage {
+	return Usage{
+		MachineHours:  round(u.MachineHours),
+		VCPUHours:     round(u.VCPUHours),
+		MemoryGBHours: round(u.MemoryGBHours),
+		GPUHours:      round(u.GPUHours),
+		Cost:          round(u.Cost),
+	}
+}
+
+func round(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// Bucket is the usage within one day or month
+type Bucket struct {
+	// Period is the day (2006-01-02) or month (2006-01) in UTC
+	Period string    `json:"period"`
+	Start  time.Time `json:"start"`
+	Usage
+}
+
+// Report is usage over a range, split by period
+type Report struct {
+	Period   string    `json:"period"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Currency string    `json:"currency"`
+	Buckets  []Bucket  `json:"buckets"`
+	Total    Usage     `json:"total"`
+}
+
+// Truncate returns the start of the UTC day or month t falls in
+func Truncate(t time.Time, period string) time.Time {
+	t = t.UTC()
+	if period == PeriodMonth {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func next(t time.Time, period string) time.Time {
+	if period == PeriodMonth {
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+func label(t time.Time, period string) string {
+	if period == PeriodMonth {
+		return t.Format("2006-01")
+	}
+	return t.Format(time.DateOnly)
+}
+
+// Aggregate splits the intervals' time within [from, to) into periods and prices
+// it. Intervals still running count up to now. Every period in the range gets a
+// bucket, including empty ones.
+func Aggregate(intervals []db.UsageInterval, period string, from, to, now time.Time, prices *Prices) *Report {
+	report := &Report{Period: period, From: from, To: to, Currency: prices.Currency}
+
+	index := make(map[time.Time]int)
+	for start := Truncate(from, period); start.Before(to); start = next(start, period) {
+		index[start] = len(report.Buckets)
+		report.Buckets = append(report.Buckets, Bucket{Period: label(start, period), Start: start})
+	}
+
+	for i := range intervals {
+		interval := &intervals[i]
+		end := now
+		if interval.StoppedAt != nil {
+			end = *interval.StoppedAt
+		}
+		start := maxTime(interval.StartedAt, from)
+		end = minTime(end, to)
+		rate := prices.HourlyRate(interval)
+
+		// Walk the interval across period boundaries
+		for start.Before(end) {
+			bucketStart := Truncate(start, period)
+			split := minTime(next(bucketStart, period), end)
+			hours := split.Sub(start).Hours()
+			if b, ok := index[bucketStart]; ok {
+				report.Buckets[b].add(interval, hours, rate)
+			}
+			report.Total.add(interval, hours, rate)
+			start = split
+		}
+	}
+
+	for i := range report.Buckets {
+		report.Buckets[i].Usage = report.Buckets[i].rounded()
+	}
+	report.Total = report.Total.rounded()
+	return report
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// WriteCSV writes one row per period followed by a total row
+func WriteCSV(w io.Writer, report *Report) error {
+	cw := csv.NewWriter(w)
+	row := func(period string, u Usage) []string {
+		return []string{
+			period,
+			strconv.FormatFloat(u.MachineHours, 'f', -1, 64),
+			strconv.FormatFloat(u.VCPUHours, 'f', -1, 64),
+			strconv.FormatFloat(u.MemoryGBHours, 'f', -1, 64),
+			strconv.FormatFloat(u.GPUHours, 'f', -1, 64),
+			strconv.FormatFloat(u.Cost, 'f', -1, 64),
+			report.Currency,
+		}
+	}
+
+	if err := cw.Write([]string{"period", "machine_hours", "vcpu_hours", "memory_gb_hours", "gpu_hours", "cost", "currency"}); err != nil {
+		return err
+	}
+	for _, b := range report.Buckets {
+		if err := cw.Write(row(b.Period, b.Usage)); err != nil {
+			return err
+		}
+	}
+	if err := cw.Write(row("total", report.Total)); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
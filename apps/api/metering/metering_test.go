@@ -0,0 +1,110 @@
+package metering
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"aether/apps/api/db"
+)
+
+func at(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func interval(start, stop string, cpus, memoryMB int, gpuKind *string) db.UsageInterval {
+	iv := db.UsageInterval{CPUKind: "shared", CPUs: cpus, MemoryMB: memoryMB, GPUKind: gpuKind, StartedAt: at(start)}
+	if stop != "" {
+		stopped := at(stop)
+		iv.StoppedAt = &stopped
+	}
+	return iv
+}
+
+func TestAggregate(t *testing.T) {
+	a10 := "a10"
+	prices := &Prices{
+		Currency:     "USD",
+		CPUHour:      map[string]float64{"shared": 1},
+		MemoryGBHour: 0.5,
+		GPUHour:      map[string]float64{"a10": 10},
+	}
+	intervals := []db.UsageInterval{
+		// Crosses midnight: 2h on the 1st, 1h on the 2nd
+		interval("2026-10-01T22:00:00Z", "2026-10-02T01:00:00Z", 2, 2048, nil),
+		// Still running at now: 2h on the 3rd
+		interval("2026-10-03T10:00:00Z", "", 1, 1024, nil),
+		// Started before the range: only the hour inside counts
+		interval("2026-09-30T23:00:00Z", "2026-10-01T01:00:00Z", 1, 1024, &a10),
+	}
+	from, to, now := at("2026-10-01T00:00:00Z"), at("2026-10-04T00:00:00Z"), at("2026-10-03T12:00:00Z")
+
+	report := Aggregate(intervals, PeriodDay, from, to, now, prices)
+	if len(report.Buckets) != 3 {
+		t.Fatalf("expected a bucket per day, got %d", len(report.Buckets))
+	}
+
+	want := []struct {
+		period string
+		hours  float64
+		cost   float64
+	}{
+		// 2h at 2 vCPU + 2GB = 3/h, plus an hour of GPU at 10/h
+		{"2026-10-01", 3, 16},
+		{"2026-10-02", 1, 3},
+		{"2026-10-03", 2, 3},
+	}
+	for i, w := range want {
+		b := report.Buckets[i]
+		if b.Period != w.period || b.MachineHours != w.hours || b.Cost != w.cost {
+			t.Errorf("bucket %d: expected %s with %vh costing %v, got %s with %vh costing %v", i, w.period, w.hours, w.cost, b.Period, b.MachineHours, b.Cost)
+		}
+	}
+	if report.Buckets[0].GPUHours != 1 || report.Total.MachineHours != 6 || report.Total.Cost != 22 {
+		t.Errorf("unexpected totals: %+v, first day %+v", report.Total, report.Buckets[0].Usage)
+	}
+
+	monthly := Aggregate(intervals, PeriodMonth, at("2026-09-01T00:00:00Z"), to, now, prices)
+	if len(monthly.Buckets) != 2 || monthly.Buckets[0].Period != "2026-09" || monthly.Buckets[0].MachineHours != 1 || monthly.Buckets[1].MachineHours != 6 {
+		t.Errorf("expected September and October, got %+v", monthly.Buckets)
+	}
+}
+
+func TestParsePrices(t *testing.T) {
+	prices, err := ParsePrices([]byte(`{"currency":"EUR","cpu_hour":{"shared":0.01},"gpu_hour":{"l40s":2}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defaults := DefaultPrices()
+	if prices.Currency != "EUR" || prices.CPUHour["shared"] != 0.01 || prices.GPUHour["l40s"] != 2 {
+		t.Errorf("expected overrides applied, got %+v", prices)
+	}
+	if prices.CPUHour["performance"] != defaults.CPUHour["performance"] || prices.MemoryGBHour != defaults.MemoryGBHour {
+		t.Errorf("expected unset prices left at their defaults, got %+v", prices)
+	}
+
+	for _, data := range []string{`[]`, `{"cpu_hour":{"quantum":1}}`, `{"gpu_hour":{"h100":1}}`, `{"memory_gb_hour":-1}`} {
+		if _, err := ParsePrices([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	report := Aggregate([]db.UsageInterval{interval("2026-10-01T00:00:00Z", "2026-10-01T01:30:00Z", 1, 1024, nil)},
+		PeriodDay, at("2026-10-01T00:00:00Z"), at("2026-10-02T00:00:00Z"), at("2026-10-02T00:00:00Z"), DefaultPrices())
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "2026-10-01,1.5,1.5,1.5,0,") || !strings.HasPrefix(lines[2], "total,1.5,") {
+		t.Errorf("unexpected CSV:\n%s", buf.String())
+	}
+}
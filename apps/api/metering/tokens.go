@@ -0,0 +1,26 @@
+package metering
+
+import "math"
+
+// TokenPrice is what a million tokens cost in USD
+type TokenPrice struct {
+	InputMTok  float64
+	OutputMTok float64
+}
+
+// AgentTokenPrices are list prices for the default model of each agent. They
+// are only used for agents that report tokens without a cost.
+var AgentTokenPrices = map[string]TokenPrice{
+	"claude":   {InputMTok: 3, OutputMTok: 15},
+	"codex":    {InputMTok: 1.25, OutputMTok: 10},
+	"codebuff": {InputMTok: 3, OutputMTok: 15},
+	"opencode": {InputMTok: 3, OutputMTok: 15},
+}
+
+// EstimateTokenCost works out in USD what the tokens cost at the agent's list
+// price. Agents without a price cost nothing.
+func EstimateTokenCost(agent string, inputTokens, outputTokens int) float64 {
+	price := AgentTokenPrices[agent]
+	cost := float64(inputTokens)*price.InputMTok/1e6 + float64(outputTokens)*price.OutputMTok/1e6
+	return math.Round(cost*1e6) / 1e6
+}
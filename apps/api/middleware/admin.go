@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ParseAdminUserIDs parses a comma-separated list of admin user IDs
+func ParseAdminUserIDs(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// RequireAdmin rejects requests from users that are not in adminUserIDs.
+// Must be mounted after Authenticate so the user ID is in the context.
+func RequireAdmin(adminUserIDs []string) func(http.Handler) http.Handler {
+	admins := make(map[string]bool, len(adminUserIDs))
+	for _, id := range adminUserIDs {
+		admins[id] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !admins[GetUserID(r.Context())] {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				if _, err := w.Write([]byte(`{"error":"admin access required"}`)); err != nil {
+					return
+				}
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseAdminUserIDs(t *testing.T) {
+	got := ParseAdminUserIDs(" a , b,,c ")
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if ids := ParseAdminUserIDs(""); len(ids) != 0 {
+		t.Errorf("expected no IDs, got %v", ids)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	handler := RequireAdmin([]string{"admin-id"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		userID string
+		want   int
+	}{
+		{name: "admin allowed", userID: "admin-id", want: http.StatusOK},
+		{name: "other user forbidden", userID: "user-id", want: http.StatusForbidden},
+		{name: "no user forbidden", userID: "", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/reconcile", nil)
+			if tt.userID != "" {
+				req = req.WithContext(context.WithValue(req.Context(), UserIDKey, tt.userID))
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// Package objectstore stores large blobs, such as archived project volumes, in an
+// S3-compatible bucket or a local directory standing in for one
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when an object doesn't exist
+	ErrNotFound = errors.New("object not found")
+	// ErrPresignUnsupported is returned by stores that can't hand out URLs for
+	// transferring objects directly
+	ErrPresignUnsupported = errors.New("store does not support presigned URLs")
+)
+
+// Store is a bucket of objects addressed by slash-separated keys
+type Store interface {
+	// Put uploads size bytes from body, replacing any object with the same key
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	// Get opens an object for reading; the caller must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes an object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL anyone can download the object from until it expires
+	PresignGet(key string, expires time.Duration) (string, error)
+	// PresignPut returns a URL anyone can upload the object to until it expires
+	PresignPut(key string, expires time.Duration) (string, error)
+}
@@ -0,0 +1,80 @@
+package quota
+
+import (
+	"context"
+	"errors"
+
+	"aether/apps/api/db"
+)
+
+// Reader reads the plans and usage quotas are checked against
+type Reader interface {
+	GetUserPlan(ctx context.Context, userID string) (*db.Plan, error)
+	GetQuotaUsage(ctx context.Context, userID string) (*db.QuotaUsage, error)
+	GetOrganizationPlan(ctx context.Context, orgID string) (*db.Plan, error)
+	GetOrganizationQuotaUsage(ctx context.Context, orgID string) (*db.QuotaUsage, error)
+}
+
+// Store is a Reader that can also hold an account's quota while a request it
+// let through is recorded
+type Store interface {
+	Reader
+	LockQuota(ctx context.Context, userID string, orgID *string) (func(), error)
+}
+
+// AccountPlan returns the plan a project counts against, or nil if none
+// applies. Organization projects count against the organization's plan,
+// whichever member creates, starts or resizes them, so members share one
+// allowance and nobody spends a colleague's. Personal projects count against
+// their owner's plan.
+func AccountPlan(ctx context.Context, store Reader, userID string, orgID *string) (*db.Plan, error) {
+	var plan *db.Plan
+	var err error
+	if orgID != nil {
+		plan, err = store.GetOrganizationPlan(ctx, *orgID)
+	} else {
+		plan, err = store.GetUserPlan(ctx, userID)
+	}
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// AccountUsage returns what the projects counting against the same plan hold
+func AccountUsage(ctx context.Context, store Reader, userID string, orgID *string) (*db.QuotaUsage, error) {
+	if orgID != nil {
+		return store.GetOrganizationQuotaUsage(ctx, *orgID)
+	}
+	return store.GetQuotaUsage(ctx, userID)
+}
+
+// Reserve returns a *Exceeded if the request would take the project's account
+// over its plan: the organization's if orgID is set, otherwise the user's.
+// Accounts without a plan have no limits. When the request fits, the account's
+// quota stays locked until the caller has recorded what it asked for and calls
+// release, so concurrent requests can't each see the same room left.
+func Reserve(ctx context.Context, store Store, userID string, orgID *string, req Request) (release func(), err error) {
+	plan, err := AccountPlan(ctx, store, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return func() {}, nil
+	}
+
+	release, err = store.LockQuota(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := AccountUsage(ctx, store, userID, orgID)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if exceeded := Check(plan, UsageOf(usage), req); exceeded != nil {
+		release()
+		return nil, exceeded
+	}
+	return release, nil
+}
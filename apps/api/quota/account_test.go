@@ -0,0 +1,90 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"aether/apps/api/db"
+)
+
+// fakeStore has one user plan and one organization plan, and tracks which
+// accounts' quotas are locked
+type fakeStore struct {
+	userPlan, orgPlan   *db.Plan
+	userUsage, orgUsage db.QuotaUsage
+	locked              map[string]bool
+}
+
+func (s *fakeStore) GetUserPlan(ctx context.Context, userID string) (*db.Plan, error) {
+	if s.userPlan == nil {
+		return nil, db.ErrNotFound
+	}
+	return s.userPlan, nil
+}
+
+func (s *fakeStore) GetQuotaUsage(ctx context.Context, userID string) (*db.QuotaUsage, error) {
+	return &s.userUsage, nil
+}
+
+func (s *fakeStore) GetOrganizationPlan(ctx context.Context, orgID string) (*db.Plan, error) {
+	if s.orgPlan == nil {
+		return nil, db.ErrNotFound
+	}
+	return s.orgPlan, nil
+}
+
+func (s *fakeStore) GetOrganizationQuotaUsage(ctx context.Context, orgID string) (*db.QuotaUsage, error) {
+	return &s.orgUsage, nil
+}
+
+func (s *fakeStore) LockQuota(ctx context.Context, userID string, orgID *string) (func(), error) {
+	key := "user:" + userID
+	if orgID != nil {
+		key = "org:" + *orgID
+	}
+	s.locked[key] = true
+	return func() { delete(s.locked, key) }, nil
+}
+
+func TestReserve(t *testing.T) {
+	ctx := context.Background()
+	orgID := "org-1"
+	store := &fakeStore{
+		userPlan: &db.Plan{ID: "free", MaxRunningMachines: intPtr(1)},
+		orgPlan:  &db.Plan{ID: "team", MaxRunningMachines: intPtr(5)},
+		// The user's own machine is running; the organization has room
+		userUsage: db.QuotaUsage{RunningMachines: 1},
+		orgUsage:  db.QuotaUsage{RunningMachines: 1},
+		locked:    map[string]bool{},
+	}
+	req := Request{RunningMachines: 1}
+
+	release, err := Reserve(ctx, store, "user-1", &orgID, req)
+	if err != nil {
+		t.Fatalf("expected the organization's plan to have room, got %v", err)
+	}
+	if !store.locked["org:org-1"] || store.locked["user:user-1"] {
+		t.Errorf("expected the organization's quota locked until release, got %v", store.locked)
+	}
+	release()
+	if len(store.locked) != 0 {
+		t.Errorf("expected the lock released, got %v", store.locked)
+	}
+
+	_, err = Reserve(ctx, store, "user-1", nil, req)
+	var exceeded *Exceeded
+	if !errors.As(err, &exceeded) || exceeded.Plan != "free" || exceeded.Quota != RunningMachines {
+		t.Fatalf("expected the user's plan exceeded, got %v", err)
+	}
+	if len(store.locked) != 0 {
+		t.Errorf("expected the lock released when over quota, got %v", store.locked)
+	}
+
+	store.userPlan = nil
+	if release, err := Reserve(ctx, store, "user-1", nil, req); err != nil {
+		t.Errorf("expected no limits without a plan, got %v", err)
+	} else {
+		release()
+	}
+}
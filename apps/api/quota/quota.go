@@ -0,0 +1,173 @@
+// Package quota checks what a user or organization asks for against what its
+// plan allows.
+package quota
+
+import (
+	"fmt"
+
+	"aether/apps/api/db"
+	"aether/apps/api/validation"
+)
+
+// Quotas a plan can set
+const (
+	Projects        = "projects"
+	VolumeGB        = "volume_gb"
+	GPU             = "gpu"
+	RunningMachines = "running_machines"
+	VCPUs           = "vcpus"
+	MemoryMB        = "memory_mb"
+)
+
+// Usage is what a user holds, in the units plans limit
+type Usage struct {
+	Projects        int `json:"projects"`
+	VolumeGB        int `json:"volume_gb"`
+	RunningMachines int `json:"running_machines"`
+	VCPUs           int `json:"vcpus"`
+	MemoryMB        int `json:"memory_mb"`
+}
+
+// Request is what an action adds to a user's usage
+type Request struct {
+	Projects        int
+	VolumeGB        int
+	GPU             bool
+	RunningMachines int
+	VCPUs           int
+	MemoryMB        int
+}
+
+// Exceeded is returned when a request would take a user over their plan
+type Exceeded struct {
+	Quota     string
+	Plan      string
+	Limit     int
+	Used      int
+	Requested int
+}
+
+func (e *Exceeded) Error() string {
+	if e.Quota == GPU {
+		return fmt.Sprintf("the %s plan does not include GPUs", e.Plan)
+	}
+	return fmt.Sprintf("%s quota exceeded: %d used + %d requested exceeds the %s plan's limit of %d",
+		e.Quota, e.Used, e.Requested, e.Plan, e.Limit)
+}
+
+// Temporary reports whether the quota frees up as the user's machines stop.
+// Running machines, vCPUs and memory are; projects, volumes and GPUs need a
+// different plan or fewer projects.
+func (e *Exceeded) Temporary() bool {
+	switch e.Quota {
+	case RunningMachines, VCPUs, MemoryMB:
+		return true
+	}
+	return false
+}
+
+// UsageOf converts stored usage into quota units, counting GPU machines at
+// their fixed size
+func UsageOf(u *db.QuotaUsage) Usage {
+	return Usage{
+		Projects:        u.Projects,
+		VolumeGB:        u.VolumeGB,
+		RunningMachines: u.RunningMachines,
+		VCPUs:           u.RunningCPUs + u.RunningGPUMachines*validation.GPUMachineCPUs,
+		MemoryMB:        u.RunningMemoryMB + u.RunningGPUMachines*validation.GPUMachineMemoryMB,
+	}
+}
+
+// Compute is the vCPUs and memory a machine for this hardware gets
+func Compute(cpus, memoryMB int, gpuKind *string) (int, int) {
+	if gpuKind != nil && *gpuKind != "" {
+		return validation.GPUMachineCPUs, validation.GPUMachineMemoryMB
+	}
+	return cpus, memoryMB
+}
+
+// ForNewProject is the request to create a project with this volume and GPU
+func ForNewProject(volumeSizeGB int, gpuKind *string) Request {
+	return Request{Projects: 1, VolumeGB: volumeSizeGB, GPU: gpuKind != nil && *gpuKind != ""}
+}
+
+// ForStart is the request to run a machine for the project. Starting a
+// hibernated project also recreates the volume it gave up.
+func ForStart(project *db.Project) Request {
+	cpus, memoryMB := Compute(project.CPUs, project.MemoryMB, project.GPUKind)
+	req := Request{
+		RunningMachines: 1,
+		VCPUs:           cpus,
+		MemoryMB:        memoryMB,
+		GPU:             project.GPUKind != nil && *project.GPUKind != "",
+	}
+	if project.Status == db.StatusHibernated {
+		req.VolumeGB = project.VolumeSizeGB
+	}
+	return req
+}
+
+// ForTransfer is what the project adds to the account it moves to: the project,
+// its volume unless hibernated, and its machine if one is running
+func ForTransfer(project *db.Project) Request {
+	req := Request{Projects: 1, GPU: project.GPUKind != nil && *project.GPUKind != ""}
+	if project.Status != db.StatusHibernated {
+		req.VolumeGB = project.VolumeSizeGB
+	}
+	for _, status := range db.MachineStatuses {
+		if project.Status == status {
+			req.RunningMachines = 1
+			req.VCPUs, req.MemoryMB = Compute(project.CPUs, project.MemoryMB, project.GPUKind)
+		}
+	}
+	return req
+}
+
+// ForHardwareChange is what moving the project to new hardware adds. Machine
+// compute only counts if the project is running; otherwise the next start checks it.
+func ForHardwareChange(project *db.Project, hw *db.HardwareConfig, running bool) Request {
+	req := Request{
+		VolumeGB: hw.VolumeSizeGB - project.VolumeSizeGB,
+		GPU:      hw.GPUKind != nil && *hw.GPUKind != "",
+	}
+	if running {
+		oldCPUs, oldMemoryMB := Compute(project.CPUs, project.MemoryMB, project.GPUKind)
+		newCPUs, newMemoryMB := Compute(hw.CPUs, hw.MemoryMB, hw.GPUKind)
+		req.VCPUs, req.MemoryMB = newCPUs-oldCPUs, newMemoryMB-oldMemoryMB
+	}
+	return req
+}
+
+// Check returns the first quota the request would exceed, or nil if it fits.
+// A nil plan has no limits. Only what the request adds is checked, so users over
+// a limit they were given before can keep using what they have.
+func Check(plan *db.Plan, usage Usage, req Request) *Exceeded {
+	if plan == nil {
+		return nil
+	}
+	if req.GPU && !plan.AllowGPU {
+		return &Exceeded{Quota: GPU, Plan: plan.ID}
+	}
+
+	checks := []struct {
+		quota     string
+		limit     *int
+		used      int
+		requested int
+	}{
+		{Projects, plan.MaxProjects, usage.Projects, req.Projects},
+		{VolumeGB, plan.MaxVolumeGB, usage.VolumeGB, req.VolumeGB},
+		{RunningMachines, plan.MaxRunningMachines, usage.RunningMachines, req.RunningMachines},
+		{VCPUs, plan.MaxVCPUs, usage.VCPUs, req.VCPUs},
+		{MemoryMB, plan.MaxMemoryMB, usage.MemoryMB, req.MemoryMB},
+	}
+	for _, c := range checks {
+		if c.limit == nil || c.requested <= 0 {
+			continue
+		}
+		if c.used+c.requested > *c.limit {
+			return &Exceeded{Quota: c.quota, Plan: plan.ID, Limit: *c.limit, Used: c.used, Requested: c.requested}
+		}
+	}
+	return nil
+}
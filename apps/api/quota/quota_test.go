@@ -0,0 +1,119 @@
+package quota
+
+import (
+	"testing"
+
+	"aether/apps/api/db"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestCheck(t *testing.T) {
+	plan := &db.Plan{
+		ID:                 "free",
+		MaxProjects:        intPtr(3),
+		MaxVolumeGB:        intPtr(20),
+		MaxRunningMachines: intPtr(1),
+		MaxVCPUs:           intPtr(2),
+		MaxMemoryMB:        intPtr(4096),
+	}
+	usage := Usage{Projects: 2, VolumeGB: 10, RunningMachines: 0}
+
+	tests := []struct {
+		name      string
+		req       Request
+		quota     string
+		temporary bool
+	}{
+		{"fits", ForNewProject(10, nil), "", false},
+		{"too many projects", Request{Projects: 2}, Projects, false},
+		{"too much volume", ForNewProject(11, nil), VolumeGB, false},
+		{"gpu not allowed", ForNewProject(5, strPtr("a10")), GPU, false},
+		{"start fits", ForStart(&db.Project{CPUs: 2, MemoryMB: 4096}), "", false},
+		{"too many cpus", ForStart(&db.Project{CPUs: 4, MemoryMB: 4096}), VCPUs, true},
+		{"too much memory", ForStart(&db.Project{CPUs: 1, MemoryMB: 8192}), MemoryMB, true},
+		{"nothing added", Request{}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exceeded := Check(plan, usage, tt.req)
+			if tt.quota == "" {
+				if exceeded != nil {
+					t.Fatalf("expected request to fit, got %v", exceeded)
+				}
+				return
+			}
+			if exceeded == nil || exceeded.Quota != tt.quota {
+				t.Fatalf("expected %s exceeded, got %v", tt.quota, exceeded)
+			}
+			if exceeded.Temporary() != tt.temporary {
+				t.Errorf("expected temporary %v for %s", tt.temporary, tt.quota)
+			}
+		})
+	}
+
+	// A running machine fills the plan's one slot
+	usage.RunningMachines = 1
+	if exceeded := Check(plan, usage, ForStart(&db.Project{CPUs: 1, MemoryMB: 1024})); exceeded == nil || exceeded.Quota != RunningMachines {
+		t.Errorf("expected running machines exceeded, got %v", exceeded)
+	}
+
+	if exceeded := Check(nil, usage, Request{Projects: 100, GPU: true}); exceeded != nil {
+		t.Errorf("expected no limits without a plan, got %v", exceeded)
+	}
+}
+
+func TestUsageOf(t *testing.T) {
+	u := UsageOf(&db.QuotaUsage{RunningMachines: 2, RunningGPUMachines: 1, RunningCPUs: 2, RunningMemoryMB: 2048})
+	if u.VCPUs != 10 || u.MemoryMB != 18432 {
+		t.Errorf("expected GPU machine counted at its fixed size, got %d vCPUs and %d MB", u.VCPUs, u.MemoryMB)
+	}
+}
+
+func TestForHardwareChange(t *testing.T) {
+	project := &db.Project{CPUs: 2, MemoryMB: 2048, VolumeSizeGB: 10}
+	hw := &db.HardwareConfig{CPUs: 4, MemoryMB: 1024, VolumeSizeGB: 15}
+
+	if req := ForHardwareChange(project, hw, false); req.VolumeGB != 5 || req.VCPUs != 0 || req.MemoryMB != 0 {
+		t.Errorf("expected only the volume growth for a stopped project, got %+v", req)
+	}
+	if req := ForHardwareChange(project, hw, true); req.VCPUs != 2 || req.MemoryMB != -1024 {
+		t.Errorf("expected the compute difference for a running project, got %+v", req)
+	}
+}
+
+func TestForTransfer(t *testing.T) {
+	project := &db.Project{CPUs: 2, MemoryMB: 2048, VolumeSizeGB: 10, Status: db.StatusStopped}
+	if req := ForTransfer(project); req.Projects != 1 || req.VolumeGB != 10 || req.RunningMachines != 0 || req.VCPUs != 0 {
+		t.Errorf("expected the project and its volume for a stopped project, got %+v", req)
+	}
+
+	project.Status = db.StatusRunning
+	if req := ForTransfer(project); req.RunningMachines != 1 || req.VCPUs != 2 || req.MemoryMB != 2048 {
+		t.Errorf("expected the machine's compute for a running project, got %+v", req)
+	}
+
+	project.Status = db.StatusHibernated
+	if req := ForTransfer(project); req.Projects != 1 || req.VolumeGB != 0 {
+		t.Errorf("expected no volume for a hibernated project, got %+v", req)
+	}
+}
+
+func TestForStart(t *testing.T) {
+	project := &db.Project{CPUs: 2, MemoryMB: 2048, VolumeSizeGB: 10, Status: db.StatusStopped}
+	if req := ForStart(project); req.RunningMachines != 1 || req.VCPUs != 2 || req.VolumeGB != 0 {
+		t.Errorf("expected only the machine for a stopped project, got %+v", req)
+	}
+
+	project.Status = db.StatusHibernated
+	if req := ForStart(project); req.VolumeGB != 10 {
+		t.Errorf("expected the volume recreated for a hibernated project, got %+v", req)
+	}
+}
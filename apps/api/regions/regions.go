@@ -0,0 +1,204 @@
+// Package regions describes where projects can be placed and which hardware each
+// region offers.
+package regions
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"aether/apps/api/validation"
+)
+
+// DefaultGPURegion is where GPU projects were placed before regions were
+// configurable, and where the default catalog offers GPUs
+const DefaultGPURegion = "ord"
+
+var (
+	// Region code: lowercase letters and digits, as Fly names its regions
+	regionCodeRegex = regexp.MustCompile(`^[a-z0-9]{2,16}$`)
+
+	allCPUKinds = []string{"shared", "performance"}
+	allGPUKinds = []string{"a10", "l40s", "a100-40gb", "a100-80gb"}
+)
+
+// Region is a place projects can run, and the hardware available there
+type Region struct {
+	Code string `json:"code"`
+	Name string `json:"name,omitempty"`
+	// CPUKinds lists the CPU kinds offered; empty offers all of them
+	CPUKinds []string `json:"cpu_kinds,omitempty"`
+	// MaxCPUs and MaxMemoryMB cap CPU machines; zero leaves the global limits
+	MaxCPUs     int `json:"max_cpus,omitempty"`
+	MaxMemoryMB int `json:"max_memory_mb,omitempty"`
+	// GPUKinds lists the GPUs offered; empty offers none
+	GPUKinds []string `json:"gpu_kinds,omitempty"`
+}
+
+// Catalog is the set of regions projects may be placed in
+type Catalog struct {
+	regions     []Region
+	defaultCode string
+}
+
+// Default returns the catalog used when none is configured: CPU machines in the
+// default region and GPUs in ord, as projects were placed before regions existed
+func Default(defaultRegion string) *Catalog {
+	c := &Catalog{defaultCode: defaultRegion}
+	c.regions = append(c.regions, Region{Code: defaultRegion, CPUKinds: allCPUKinds})
+	if defaultRegion == DefaultGPURegion {
+		c.regions[0].GPUKinds = allGPUKinds
+	} else {
+		c.regions = append(c.regions, Region{Code: DefaultGPURegion, CPUKinds: allCPUKinds, GPUKinds: allGPUKinds})
+	}
+	return c
+}
+
+// Parse reads a JSON array of regions. New projects go to defaultRegion when it
+// is listed, otherwise to the first region.
+func Parse(data []byte, defaultRegion string) (*Catalog, error) {
+	var regions []Region
+	if err := json.Unmarshal(data, &regions); err != nil {
+		return nil, fmt.Errorf("invalid region list: %w", err)
+	}
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("region list is empty")
+	}
+
+	c := &Catalog{regions: regions, defaultCode: regions[0].Code}
+	seen := make(map[string]bool)
+	for _, r := range regions {
+		if !regionCodeRegex.MatchString(r.Code) {
+			return nil, fmt.Errorf("invalid region code %q", r.Code)
+		}
+		if seen[r.Code] {
+			return nil, fmt.Errorf("region %s is listed twice", r.Code)
+		}
+		seen[r.Code] = true
+		for _, kind := range r.CPUKinds {
+			if err := validation.ValidateCPUKind(kind); err != nil {
+				return nil, fmt.Errorf("region %s: cpu_kinds %s", r.Code, err.Message)
+			}
+		}
+		for _, kind := range r.GPUKinds {
+			if err := validation.ValidateGPUKind(kind); err != nil {
+				return nil, fmt.Errorf("region %s: gpu_kinds %s", r.Code, err.Message)
+			}
+		}
+		if r.MaxCPUs < 0 || r.MaxMemoryMB < 0 {
+			return nil, fmt.Errorf("region %s: limits can't be negative", r.Code)
+		}
+		if r.Code == defaultRegion {
+			c.defaultCode = defaultRegion
+		}
+	}
+	return c, nil
+}
+
+// DefaultRegion is where new projects go when they don't pick a region
+func (c *Catalog) DefaultRegion() string {
+	return c.defaultCode
+}
+
+// Regions lists the catalog's regions in their configured order
+func (c *Catalog) Regions() []Region {
+	return c.regions
+}
+
+// Get looks up a region by code
+func (c *Catalog) Get(code string) (Region, bool) {
+	for _, r := range c.regions {
+		if r.Code == code {
+			return r, true
+		}
+	}
+	return Region{}, false
+}
+
+// Validate checks that code is a known region offering hw
+func (c *Catalog) Validate(code string, hw *validation.HardwareConfig) *validation.ValidationError {
+	r, ok := c.Get(code)
+	if !ok {
+		return &validation.ValidationError{Field: "region", Message: "must be one of: " + strings.Join(c.codes(), ", ")}
+	}
+	return c.check(r, hw)
+}
+
+// Place picks a region for new hardware: the default region if it offers it,
+// otherwise the first region that does
+func (c *Catalog) Place(hw *validation.HardwareConfig) (string, *validation.ValidationError) {
+	if r, ok := c.Get(c.defaultCode); ok && r.Supports(hw) {
+		return r.Code, nil
+	}
+	for _, r := range c.regions {
+		if r.Supports(hw) {
+			return r.Code, nil
+		}
+	}
+	if r, ok := c.Get(c.defaultCode); ok {
+		return "", c.check(r, hw)
+	}
+	return "", &validation.ValidationError{Field: "region", Message: "no region offers this hardware"}
+}
+
+// Supports reports whether hw can run in the region
+func (r Region) Supports(hw *validation.HardwareConfig) bool {
+	return r.unsupported(hw) == nil
+}
+
+// check explains why hw can't run in r, naming the regions where it can
+func (c *Catalog) check(r Region, hw *validation.HardwareConfig) *validation.ValidationError {
+	verr := r.unsupported(hw)
+	if verr == nil {
+		return nil
+	}
+	var elsewhere []string
+	for _, other := range c.regions {
+		if other.Code != r.Code && other.Supports(hw) {
+			elsewhere = append(elsewhere, other.Code)
+		}
+	}
+	if len(elsewhere) > 0 {
+		verr.Message += "; available in: " + strings.Join(elsewhere, ", ")
+	}
+	return verr
+}
+
+func (r Region) unsupported(hw *validation.HardwareConfig) *validation.ValidationError {
+	if hw.GPUKind != nil && *hw.GPUKind != "" {
+		// GPU machines get fixed compute, so only the GPU kind matters
+		if !contains(r.GPUKinds, *hw.GPUKind) {
+			return &validation.ValidationError{Field: "gpu_kind", Message: fmt.Sprintf("%s GPUs aren't available in %s", *hw.GPUKind, r.Code)}
+		}
+		return nil
+	}
+
+	if len(r.CPUKinds) > 0 && !contains(r.CPUKinds, hw.CPUKind) {
+		return &validation.ValidationError{Field: "cpu_kind", Message: fmt.Sprintf("%s CPUs aren't available in %s", hw.CPUKind, r.Code)}
+	}
+	if r.MaxCPUs > 0 && hw.CPUs > r.MaxCPUs {
+		return &validation.ValidationError{Field: "cpus", Message: fmt.Sprintf("%s allows at most %d CPUs", r.Code, r.MaxCPUs)}
+	}
+	if r.MaxMemoryMB > 0 && hw.MemoryMB > r.MaxMemoryMB {
+		return &validation.ValidationError{Field: "memory_mb", Message: fmt.Sprintf("%s allows at most %dMB of memory", r.Code, r.MaxMemoryMB)}
+	}
+	return nil
+}
+
+func (c *Catalog) codes() []string {
+	codes := make([]string, len(c.regions))
+	for i, r := range c.regions {
+		codes[i] = r.Code
+	}
+	return codes
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
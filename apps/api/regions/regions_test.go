@@ -0,0 +1,116 @@
+package regions
+
+import (
+	"strings"
+	"testing"
+
+	"aether/apps/api/validation"
+)
+
+func gpu(kind string) *validation.HardwareConfig {
+	return &validation.HardwareConfig{CPUKind: "performance", CPUs: 8, MemoryMB: 16384, VolumeSizeGB: 10, GPUKind: &kind}
+}
+
+func cpu(kind string, cpus, memoryMB int) *validation.HardwareConfig {
+	return &validation.HardwareConfig{CPUKind: kind, CPUs: cpus, MemoryMB: memoryMB, VolumeSizeGB: 10}
+}
+
+func TestDefault(t *testing.T) {
+	c := Default("sjc")
+	if c.DefaultRegion() != "sjc" || len(c.Regions()) != 2 {
+		t.Fatalf("expected sjc plus ord, got %v", c.Regions())
+	}
+
+	// Placement matches where projects went before regions were configurable
+	if code, err := c.Place(cpu("shared", 1, 1024)); err != nil || code != "sjc" {
+		t.Errorf("expected CPU projects in sjc, got %q %v", code, err)
+	}
+	if code, err := c.Place(gpu("a10")); err != nil || code != "ord" {
+		t.Errorf("expected GPU projects in ord, got %q %v", code, err)
+	}
+
+	if c := Default("ord"); len(c.Regions()) != 1 || !c.Regions()[0].Supports(gpu("l40s")) {
+		t.Errorf("expected a single ord region with GPUs, got %v", c.Regions())
+	}
+}
+
+func TestParse(t *testing.T) {
+	c, err := Parse([]byte(`[
+		{"code":"ams","name":"Amsterdam","cpu_kinds":["shared"],"max_cpus":4},
+		{"code":"iad","gpu_kinds":["a100-80gb"]}
+	]`), "iad")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.DefaultRegion() != "iad" {
+		t.Errorf("expected listed default region kept, got %s", c.DefaultRegion())
+	}
+	if r, ok := c.Get("ams"); !ok || r.Name != "Amsterdam" {
+		t.Errorf("expected ams found, got %v %v", r, ok)
+	}
+
+	// An unlisted default falls back to the first region
+	c, err = Parse([]byte(`[{"code":"ams"}]`), "sjc")
+	if err != nil || c.DefaultRegion() != "ams" {
+		t.Errorf("expected ams as default, got %v %v", c, err)
+	}
+
+	invalid := []string{
+		`{}`,
+		`[]`,
+		`[{"code":"AMS"}]`,
+		`[{"code":"ams"},{"code":"ams"}]`,
+		`[{"code":"ams","cpu_kinds":["quantum"]}]`,
+		`[{"code":"ams","gpu_kinds":["h100"]}]`,
+		`[{"code":"ams","max_cpus":-1}]`,
+	}
+	for _, data := range invalid {
+		if _, err := Parse([]byte(data), "sjc"); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
+
+func TestCatalog_Validate(t *testing.T) {
+	c, err := Parse([]byte(`[
+		{"code":"ams","cpu_kinds":["shared"],"max_cpus":4,"max_memory_mb":4096},
+		{"code":"ord","gpu_kinds":["a10"]}
+	]`), "ams")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		region string
+		hw     *validation.HardwareConfig
+		field  string
+	}{
+		{"fits", "ams", cpu("shared", 2, 2048), ""},
+		{"unknown region", "xyz", cpu("shared", 1, 1024), "region"},
+		{"cpu kind", "ams", cpu("performance", 2, 4096), "cpu_kind"},
+		{"too many cpus", "ams", cpu("shared", 8, 4096), "cpus"},
+		{"too much memory", "ams", cpu("shared", 4, 8192), "memory_mb"},
+		{"no gpus", "ams", gpu("a10"), "gpu_kind"},
+		{"wrong gpu", "ord", gpu("l40s"), "gpu_kind"},
+		{"gpu", "ord", gpu("a10"), ""},
+		{"unlimited region", "ord", cpu("performance", 16, 32768), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verr := c.Validate(tt.region, tt.hw)
+			if (verr == nil) != (tt.field == "") || (verr != nil && verr.Field != tt.field) {
+				t.Errorf("Validate(%s) = %v, want field %q", tt.region, verr, tt.field)
+			}
+		})
+	}
+
+	// Rejections point to the regions that do offer the hardware
+	if verr := c.Validate("ams", gpu("a10")); verr == nil || !strings.Contains(verr.Message, "available in: ord") {
+		t.Errorf("expected ord suggested, got %v", verr)
+	}
+	if _, verr := c.Place(gpu("l40s")); verr == nil {
+		t.Error("expected no region for l40s")
+	}
+}
@@ -2,8 +2,13 @@ package validation
 
 import (
 	"fmt"
+	"net/mail"
 	"regexp"
 	"strings"
+	"time"
+	"unicode"
+
+	"aether/apps/api/cron"
 
 	"github.com/google/uuid"
 )
@@ -11,6 +16,16 @@ import (
 var (
 	// Project name: alphanumeric, dashes, underscores, 1-100 chars
 	projectNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{0,99}$`)
+
+	// Environment variable name: POSIX shell identifier
+	envVarNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// Project tag: lowercase alphanumeric, dashes, underscores, 1-50 chars
+	tagRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+	// Project label key: lowercase alphanumeric, dots, dashes, underscores,
+	// 1-63 chars, starting and ending with a letter or number
+	labelKeyRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9_.-]{0,61}[a-z0-9])?$`)
 )
 
 type ValidationError struct {
@@ -61,6 +76,73 @@ func ValidateProjectDescription(description string) *ValidationError {
 	return nil
 }
 
+// MaxProjectTags caps how many tags a project can have
+const MaxProjectTags = 20
+
+// ValidateTags validates a project's tags
+func ValidateTags(tags []string) *ValidationError {
+	if len(tags) > MaxProjectTags {
+		return &ValidationError{Field: "tags", Message: fmt.Sprintf("at most %d tags are allowed", MaxProjectTags)}
+	}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if !tagRegex.MatchString(tag) {
+			return &ValidationError{Field: "tags", Message: fmt.Sprintf("invalid tag %q; use up to 50 lowercase letters, numbers, dashes, and underscores", tag)}
+		}
+		if seen[tag] {
+			return &ValidationError{Field: "tags", Message: fmt.Sprintf("duplicate tag %q", tag)}
+		}
+		seen[tag] = true
+	}
+	return nil
+}
+
+const (
+	// MaxProjectLabels caps how many labels a project can have
+	MaxProjectLabels    = 32
+	maxLabelValueLen    = 255
+	reservedLabelPrefix = "fly_"
+)
+
+// ValidateLabelKey validates a project label key
+func ValidateLabelKey(key string) *ValidationError {
+	if !labelKeyRegex.MatchString(key) {
+		return &ValidationError{Field: "labels", Message: fmt.Sprintf("invalid label key %q; use up to 63 lowercase letters, numbers, dots, dashes, and underscores", key)}
+	}
+	// Fly keeps its own machine metadata under this prefix
+	if strings.HasPrefix(key, reservedLabelPrefix) {
+		return &ValidationError{Field: "labels", Message: fmt.Sprintf("label key %q uses the reserved prefix %q", key, reservedLabelPrefix)}
+	}
+	return nil
+}
+
+// ValidateLabels validates a project's labels
+func ValidateLabels(labels map[string]string) *ValidationError {
+	if len(labels) > MaxProjectLabels {
+		return &ValidationError{Field: "labels", Message: fmt.Sprintf("at most %d labels are allowed", MaxProjectLabels)}
+	}
+	for key, value := range labels {
+		if err := ValidateLabelKey(key); err != nil {
+			return err
+		}
+		if len(value) > maxLabelValueLen {
+			return &ValidationError{Field: "labels", Message: fmt.Sprintf("%s: value must be %d characters or less", key, maxLabelValueLen)}
+		}
+		if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return &ValidationError{Field: "labels", Message: fmt.Sprintf("%s: value must not contain control characters", key)}
+		}
+	}
+	return nil
+}
+
+// ValidateSnapshotLabel validates a snapshot label
+func ValidateSnapshotLabel(label string) *ValidationError {
+	if len(label) > 100 {
+		return &ValidationError{Field: "label", Message: "must be 100 characters or less"}
+	}
+	return nil
+}
+
 // ValidateUUID validates UUID format
 func ValidateUUID(id string, field string) *ValidationError {
 	if id == "" {
@@ -72,6 +154,20 @@ func ValidateUUID(id string, field string) *ValidationError {
 	return nil
 }
 
+// ValidateIdempotencyKey validates an Idempotency-Key header: 1-255 printable
+// ASCII characters, so clients can use UUIDs or their own retry tokens
+func ValidateIdempotencyKey(key string) *ValidationError {
+	if key == "" || len(key) > 255 {
+		return &ValidationError{Field: "Idempotency-Key", Message: "must be 1-255 characters"}
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return &ValidationError{Field: "Idempotency-Key", Message: "must contain only printable ASCII characters"}
+		}
+	}
+	return nil
+}
+
 // ValidateFilePath validates a file path for safety
 func ValidateFilePath(path string) *ValidationError {
 	if path == "" {
@@ -134,6 +230,14 @@ type HardwareConfig struct {
 	GPUKind      *string
 }
 
+// GPU machines need cpu_kind and cpus set, but their compute comes with the GPU,
+// so every GPU machine gets this guest regardless of the project's hardware
+const (
+	GPUMachineCPUKind  = "performance"
+	GPUMachineCPUs     = 8
+	GPUMachineMemoryMB = 16384
+)
+
 var (
 	validCPUKinds     = map[string]bool{"shared": true, "performance": true}
 	validGPUKinds     = map[string]bool{"a10": true, "l40s": true, "a100-40gb": true, "a100-80gb": true}
@@ -352,6 +456,22 @@ func ValidateUpdateProject(name, description *string) (*UpdateProjectInput, Vali
 	}, nil
 }
 
+// ValidateCPUKind validates a CPU kind filter
+func ValidateCPUKind(kind string) *ValidationError {
+	if !validCPUKinds[kind] {
+		return &ValidationError{Field: "cpu_kind", Message: "must be 'shared' or 'performance'"}
+	}
+	return nil
+}
+
+// ValidateGPUKind validates a GPU kind filter
+func ValidateGPUKind(kind string) *ValidationError {
+	if !validGPUKinds[kind] {
+		return &ValidationError{Field: "gpu_kind", Message: "must be one of: a10, l40s, a100-40gb, a100-80gb"}
+	}
+	return nil
+}
+
 // ValidateIdleTimeout validates idle timeout value
 // Valid values: nil (use default), 0 (never), 5, 10, 30, 60 minutes
 func ValidateIdleTimeout(minutes *int) *ValidationError {
@@ -366,3 +486,331 @@ func ValidateIdleTimeout(minutes *int) *ValidationError {
 	}
 	return nil
 }
+
+// MaxIdleSnoozeMinutes caps how long one snooze can hold off an idle stop
+const MaxIdleSnoozeMinutes = 240
+
+// ValidateIdleSnooze validates how many minutes to keep an idle project running
+func ValidateIdleSnooze(minutes int) *ValidationError {
+	if minutes < 1 || minutes > MaxIdleSnoozeMinutes {
+		return &ValidationError{
+			Field:   "minutes",
+			Message: fmt.Sprintf("must be between 1 and %d", MaxIdleSnoozeMinutes),
+		}
+	}
+	return nil
+}
+
+// ValidateWakeOnRequest checks that a project opting in to wake-on-request will be
+// stopped again once idle, so stray preview traffic can't keep it running for good
+func ValidateWakeOnRequest(enabled bool, idleTimeoutMinutes *int) *ValidationError {
+	if enabled && (idleTimeoutMinutes == nil || *idleTimeoutMinutes == 0) {
+		return &ValidationError{
+			Field:   "wake_on_request",
+			Message: "requires an idle timeout",
+		}
+	}
+	return nil
+}
+
+const (
+	maxTemplateEnvVars     = 50
+	maxTemplatePorts       = 10
+	maxTemplateFiles       = 100
+	maxTemplateFilesBytes  = 64 * 1024 // written through a single exec argument
+	maxPostCreateScriptLen = 10000
+)
+
+var validPresets = map[string]bool{"small": true, "medium": true, "large": true, "performance": true}
+
+// ValidatePreset validates a hardware preset name
+func ValidatePreset(preset string) *ValidationError {
+	if !validPresets[preset] {
+		return &ValidationError{Field: "hardware_preset", Message: "must be one of: small, medium, large, performance"}
+	}
+	return nil
+}
+
+// ValidateEnvVarName validates an environment variable name
+func ValidateEnvVarName(name string) *ValidationError {
+	if !envVarNameRegex.MatchString(name) {
+		return &ValidationError{Field: "env_vars", Message: fmt.Sprintf("invalid variable name %q; use letters, numbers, and underscores, not starting with a number", name)}
+	}
+	if len(name) > 255 {
+		return &ValidationError{Field: "env_vars", Message: "variable names must be 255 characters or less"}
+	}
+	return nil
+}
+
+const (
+	maxProjectEnvVars = 100
+	maxEnvVarValueLen = 32 * 1024
+)
+
+// reservedEnvVars are set by the platform and can't be overridden per project
+var reservedEnvVars = map[string]bool{"PROJECT_ID": true}
+
+// ValidateProjectEnvVar validates a project env var name and value
+func ValidateProjectEnvVar(name, value string) *ValidationError {
+	if err := ValidateEnvVarName(name); err != nil {
+		return err
+	}
+	if reservedEnvVars[name] {
+		return &ValidationError{Field: "env_vars", Message: fmt.Sprintf("%s is set by the platform", name)}
+	}
+	if len(value) > maxEnvVarValueLen {
+		return &ValidationError{Field: "env_vars", Message: fmt.Sprintf("%s: value must be 32KB or less", name)}
+	}
+	return nil
+}
+
+// ValidateProjectEnvVarCount validates the number of env vars a project would have
+func ValidateProjectEnvVarCount(count int) *ValidationError {
+	if count > maxProjectEnvVars {
+		return &ValidationError{Field: "env_vars", Message: fmt.Sprintf("at most %d variables are allowed", maxProjectEnvVars)}
+	}
+	return nil
+}
+
+// ValidatePort validates a TCP port number
+func ValidatePort(port int) *ValidationError {
+	if port < 1 || port > 65535 {
+		return &ValidationError{Field: "ports", Message: fmt.Sprintf("invalid port %d; must be between 1 and 65535", port)}
+	}
+	return nil
+}
+
+// ValidateGitURL validates a repository URL that can be cloned without prompting
+func ValidateGitURL(url string) *ValidationError {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "git@") {
+		return &ValidationError{Field: "git_url", Message: "must be an https:// or git@ URL"}
+	}
+	if strings.ContainsAny(url, " \t\n\r") || strings.ContainsRune(url, 0) {
+		return &ValidationError{Field: "git_url", Message: "must not contain whitespace"}
+	}
+	if len(url) > 1000 {
+		return &ValidationError{Field: "git_url", Message: "must be 1000 characters or less"}
+	}
+	return nil
+}
+
+// ValidateTemplateFilePath validates a template file path, relative to the project directory
+func ValidateTemplateFilePath(path string) *ValidationError {
+	if err := ValidateFilePath(path); err != nil {
+		return &ValidationError{Field: "files", Message: fmt.Sprintf("%q: %s", path, err.Message)}
+	}
+	if strings.HasPrefix(path, "/") || strings.HasSuffix(path, "/") {
+		return &ValidationError{Field: "files", Message: fmt.Sprintf("%q: must be a relative file path", path)}
+	}
+	return nil
+}
+
+// CreateTemplateInput represents validated create template input
+type CreateTemplateInput struct {
+	Name             string
+	Description      *string
+	BaseImage        *string
+	HardwarePreset   *string
+	EnvVars          map[string]string
+	Ports            []int
+	Files            map[string]string
+	GitURL           *string
+	PostCreateScript *string
+}
+
+// ValidateCreateTemplate validates create template request
+func ValidateCreateTemplate(name, description, baseImage, hardwarePreset string, envVars map[string]string, ports []int, files map[string]string, gitURL, postCreateScript string) (*CreateTemplateInput, ValidationErrors) {
+	var errors ValidationErrors
+
+	if name == "" {
+		errors = append(errors, ValidationError{Field: "name", Message: "is required"})
+	} else if len(name) > 100 {
+		errors = append(errors, ValidationError{Field: "name", Message: "must be 100 characters or less"})
+	}
+
+	if err := ValidateProjectDescription(description); err != nil {
+		errors = append(errors, *err)
+	}
+
+	if len(baseImage) > 255 || strings.ContainsAny(baseImage, " \t\n\r") {
+		errors = append(errors, ValidationError{Field: "base_image", Message: "must be an image reference of 255 characters or less"})
+	}
+
+	if hardwarePreset != "" {
+		if err := ValidatePreset(hardwarePreset); err != nil {
+			errors = append(errors, *err)
+		}
+	}
+
+	if len(envVars) > maxTemplateEnvVars {
+		errors = append(errors, ValidationError{Field: "env_vars", Message: fmt.Sprintf("at most %d variables are allowed", maxTemplateEnvVars)})
+	}
+	for k := range envVars {
+		if err := ValidateProjectEnvVar(k, envVars[k]); err != nil {
+			errors = append(errors, *err)
+		}
+	}
+
+	if len(ports) > maxTemplatePorts {
+		errors = append(errors, ValidationError{Field: "ports", Message: fmt.Sprintf("at most %d ports are allowed", maxTemplatePorts)})
+	}
+	for _, port := range ports {
+		if err := ValidatePort(port); err != nil {
+			errors = append(errors, *err)
+		}
+	}
+
+	if len(files) > maxTemplateFiles {
+		errors = append(errors, ValidationError{Field: "files", Message: fmt.Sprintf("at most %d files are allowed", maxTemplateFiles)})
+	}
+	total := 0
+	for path, content := range files {
+		if err := ValidateTemplateFilePath(path); err != nil {
+			errors = append(errors, *err)
+		}
+		total += len(content)
+	}
+	if total > maxTemplateFilesBytes {
+		errors = append(errors, ValidationError{Field: "files", Message: "file contents must total 64KB or less"})
+	}
+
+	if gitURL != "" {
+		if err := ValidateGitURL(gitURL); err != nil {
+			errors = append(errors, *err)
+		}
+	}
+
+	if len(postCreateScript) > maxPostCreateScriptLen {
+		errors = append(errors, ValidationError{Field: "post_create_script", Message: fmt.Sprintf("must be %d characters or less", maxPostCreateScriptLen)})
+	}
+
+	if errors.HasErrors() {
+		return nil, errors
+	}
+
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+
+	return &CreateTemplateInput{
+		Name:             name,
+		Description:      optional(description),
+		BaseImage:        optional(baseImage),
+		HardwarePreset:   optional(hardwarePreset),
+		EnvVars:          envVars,
+		Ports:            ports,
+		Files:            files,
+		GitURL:           optional(gitURL),
+		PostCreateScript: optional(postCreateScript),
+	}, nil
+}
+
+// ValidateImageRef validates a container image reference
+func ValidateImageRef(image, field string) *ValidationError {
+	if image == "" {
+		return &ValidationError{Field: field, Message: "is required"}
+	}
+	if len(image) > 255 || strings.ContainsAny(image, " \t\n\r") {
+		return &ValidationError{Field: field, Message: "must be an image reference of 255 characters or less"}
+	}
+	return nil
+}
+
+// ValidateRollout validates an image rollout request
+func ValidateRollout(image string, canaryPercent, batchSize int) ValidationErrors {
+	var errors ValidationErrors
+
+	if err := ValidateImageRef(image, "image"); err != nil {
+		errors = append(errors, *err)
+	}
+	if canaryPercent < 0 || canaryPercent > 100 {
+		errors = append(errors, ValidationError{Field: "canary_percent", Message: "must be between 0 and 100"})
+	}
+	if batchSize < 1 || batchSize > 100 {
+		errors = append(errors, ValidationError{Field: "batch_size", Message: "must be between 1 and 100"})
+	}
+
+	return errors
+}
+
+// MaxSchedulesPerProject caps how many start/stop schedules a project can have
+const MaxSchedulesPerProject = 10
+
+// ValidateSchedule validates a project start/stop schedule
+func ValidateSchedule(action, cronExpr, timezone string) ValidationErrors {
+	var errors ValidationErrors
+
+	if action != "start" && action != "stop" {
+		errors = append(errors, ValidationError{Field: "action", Message: "must be 'start' or 'stop'"})
+	}
+	if _, err := cron.Parse(cronExpr); err != nil {
+		errors = append(errors, ValidationError{Field: "cron", Message: "must be a valid cron expression: " + err.Error()})
+	}
+	if timezone == "" || timezone == "Local" {
+		errors = append(errors, ValidationError{Field: "timezone", Message: "must be an IANA timezone like America/New_York"})
+	} else if _, err := time.LoadLocation(timezone); err != nil {
+		errors = append(errors, ValidationError{Field: "timezone", Message: "must be an IANA timezone like America/New_York"})
+	}
+
+	return errors
+}
+
+// Agent budget limits
+const (
+	MaxAgentBudgetUSD        = 1000000
+	MaxAgentBudgetThresholds = 5
+)
+
+// ValidateAgentBudget validates a monthly agent budget. Warning thresholds are
+// percentages of the limit, in increasing order.
+func ValidateAgentBudget(limitUSD float64, thresholds []int, resetDay int) ValidationErrors {
+	var errors ValidationErrors
+
+	if limitUSD <= 0 || limitUSD > MaxAgentBudgetUSD {
+		errors = append(errors, ValidationError{Field: "monthly_limit_usd", Message: fmt.Sprintf("must be more than 0 and at most %d", MaxAgentBudgetUSD)})
+	}
+	if len(thresholds) > MaxAgentBudgetThresholds {
+		errors = append(errors, ValidationError{Field: "warning_thresholds", Message: fmt.Sprintf("can have at most %d thresholds", MaxAgentBudgetThresholds)})
+	}
+	for i, t := range thresholds {
+		if t < 1 || t > 99 {
+			errors = append(errors, ValidationError{Field: "warning_thresholds", Message: "must be percentages between 1 and 99"})
+			break
+		}
+		if i > 0 && t <= thresholds[i-1] {
+			errors = append(errors, ValidationError{Field: "warning_thresholds", Message: "must be in increasing order"})
+			break
+		}
+	}
+	if resetDay < 1 || resetDay > 28 {
+		errors = append(errors, ValidationError{Field: "reset_day", Message: "must be between 1 and 28"})
+	}
+
+	return errors
+}
+
+// ValidateOrganizationName validates an organization's display name
+func ValidateOrganizationName(name string) *ValidationError {
+	if strings.TrimSpace(name) == "" {
+		return &ValidationError{Field: "name", Message: "is required"}
+	}
+	if len(name) > 100 {
+		return &ValidationError{Field: "name", Message: "must be 100 characters or less"}
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return &ValidationError{Field: "name", Message: "must not contain control characters"}
+	}
+	return nil
+}
+
+// ValidateEmail checks that an address is a bare email address, without a display name
+func ValidateEmail(email string) *ValidationError {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return &ValidationError{Field: "email", Message: "must be an email address"}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package validation
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -68,6 +69,72 @@ func TestValidateProjectDescription(t *testing.T) {
 	}
 }
 
+func TestValidateSnapshotLabel(t *testing.T) {
+	if err := ValidateSnapshotLabel("before refactor"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateSnapshotLabel(strings.Repeat("a", 101)); err == nil {
+		t.Error("expected error for label over 100 characters")
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		wantErr bool
+	}{
+		{"valid", []string{"client-acme", "ml_training", "2025"}, false},
+		{"empty", nil, false},
+		{"uppercase", []string{"Prod"}, true},
+		{"leading dash", []string{"-prod"}, true},
+		{"too long", []string{strings.Repeat("a", 51)}, true},
+		{"duplicate", []string{"prod", "prod"}, true},
+		{"too many", strings.Split(strings.Repeat("t,", MaxProjectTags)+"x", ","), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTags(tt.tags)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTags(%v) error = %v, wantErr %v", tt.tags, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	tooMany := make(map[string]string, MaxProjectLabels+1)
+	for i := 0; i <= MaxProjectLabels; i++ {
+		tooMany[fmt.Sprintf("key-%d", i)] = "v"
+	}
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{"valid", map[string]string{"client": "Acme Corp", "team.ml": "", "cost-center": "42"}, false},
+		{"empty", nil, false},
+		{"uppercase key", map[string]string{"Client": "acme"}, true},
+		{"trailing dot", map[string]string{"client.": "acme"}, true},
+		{"key too long", map[string]string{strings.Repeat("a", 64): "v"}, true},
+		{"reserved prefix", map[string]string{"fly_process_group": "app"}, true},
+		{"value too long", map[string]string{"client": strings.Repeat("a", 256)}, true},
+		{"control character", map[string]string{"client": "acme\ncorp"}, true},
+		{"too many", tooMany, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLabels(tt.labels)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLabels(%v) error = %v, wantErr %v", tt.labels, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateUUID(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -93,6 +160,30 @@ func TestValidateUUID(t *testing.T) {
 	}
 }
 
+func TestValidateIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{"uuid", "550e8400-e29b-41d4-a716-446655440000", false},
+		{"token with spaces", "ci run 42 / create", false},
+		{"empty", "", true},
+		{"too long", strings.Repeat("k", 256), true},
+		{"non-ascii", "clé", true},
+		{"control character", "key\tvalue", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateIdempotencyKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateIdempotencyKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateCreateProject(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -128,3 +219,109 @@ func TestValidateCreateProject(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateCreateTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		preset   string
+		envVars  map[string]string
+		ports    []int
+		files    map[string]string
+		gitURL   string
+		errCount int
+	}{
+		{"valid", "medium", map[string]string{"NODE_ENV": "development"}, []int{3000}, map[string]string{"src/index.js": ""}, "https://github.com/acme/app.git", 0},
+		{"empty optional fields", "", nil, nil, nil, "", 0},
+		{"unknown preset", "huge", nil, nil, nil, "", 1},
+		{"bad env var name", "", map[string]string{"1BAD": "x", "ALSO-BAD": "y"}, nil, nil, "", 2},
+		{"port out of range", "", nil, []int{0, 65536, 8080}, nil, "", 2},
+		{"file outside project", "", nil, nil, map[string]string{"/etc/passwd": "", "../x": "", "dir/": ""}, "", 3},
+		{"unsupported git scheme", "", nil, nil, nil, "file:///tmp/repo", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, errs := ValidateCreateTemplate("Template", "", "", tt.preset, tt.envVars, tt.ports, tt.files, tt.gitURL, "")
+			if len(errs) != tt.errCount {
+				t.Errorf("expected %d errors, got %d: %v", tt.errCount, len(errs), errs)
+			}
+			if tt.errCount == 0 && input == nil {
+				t.Error("expected input, got nil")
+			}
+		})
+	}
+}
+
+func TestValidateProjectEnvVar(t *testing.T) {
+	tests := []struct {
+		name    string
+		varName string
+		value   string
+		wantErr bool
+	}{
+		{"valid", "DATABASE_URL", "postgres://localhost/db", false},
+		{"leading underscore", "_PRIVATE", "", false},
+		{"leading digit", "1VAR", "x", true},
+		{"dash", "MY-VAR", "x", true},
+		{"reserved", "PROJECT_ID", "x", true},
+		{"value too long", "BIG", strings.Repeat("a", 32*1024+1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProjectEnvVar(tt.varName, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateProjectEnvVar(%q) error = %v, wantErr %v", tt.varName, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRollout(t *testing.T) {
+	tests := []struct {
+		name          string
+		image         string
+		canaryPercent int
+		batchSize     int
+		wantErrors    int
+	}{
+		{"valid", "registry.fly.io/aether-base:v2", 5, 10, 0},
+		{"no canary", "registry.fly.io/aether-base:v2", 0, 1, 0},
+		{"missing image", "", 5, 10, 1},
+		{"image with spaces", "aether base", 5, 10, 1},
+		{"canary over 100", "aether-base:v2", 101, 10, 1},
+		{"zero batch", "aether-base:v2", 5, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateRollout(tt.image, tt.canaryPercent, tt.batchSize)
+			if len(errs) != tt.wantErrors {
+				t.Errorf("ValidateRollout() got %d errors, want %d: %v", len(errs), tt.wantErrors, errs)
+			}
+		})
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	tests := []struct {
+		email   string
+		wantErr bool
+	}{
+		{"dev@example.com", false},
+		{"first.last+team@sub.example.co", false},
+		{"", true},
+		{"not-an-email", true},
+		{"Dev <dev@example.com>", true},
+		{" dev@example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			err := ValidateEmail(tt.email)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateEmail(%q) error = %v, wantErr %v", tt.email, err, tt.wantErr)
+			}
+		})
+	}
+}
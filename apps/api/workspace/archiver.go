@@ -0,0 +1,108 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"aether/apps/api/db"
+	"aether/apps/api/handlers"
+	"aether/apps/api/objectstore"
+)
+
+const (
+	// volumeMountPath is where project volumes are mounted on their machines
+	volumeMountPath = "/home/coder/workspace"
+	// archiveExecTimeout bounds how long packing or unpacking a volume may take
+	archiveExecTimeout = 30 * time.Minute
+	// archiveURLExpiry keeps presigned URLs valid for the whole transfer
+	archiveURLExpiry = archiveExecTimeout + 10*time.Minute
+)
+
+// ExecVolumeArchiver packs and unpacks volumes on the project's own machine, which
+// transfers the archive straight to and from the object store through presigned
+// URLs, so the data never passes through the API
+type ExecVolumeArchiver struct {
+	machines handlers.MachineManager
+	store    objectstore.Store
+}
+
+func NewExecVolumeArchiver(machines handlers.MachineManager, store objectstore.Store) *ExecVolumeArchiver {
+	return &ExecVolumeArchiver{
+		machines: machines,
+		store:    store,
+	}
+}
+
+// ArchiveVolume tars the volume to a temporary file first, since S3 needs the
+// upload's length up front, and prints its size once uploaded
+func (a *ExecVolumeArchiver) ArchiveVolume(ctx context.Context, project *db.Project, key string) (int64, error) {
+	url, err := a.store.PresignPut(key, archiveURLExpiry)
+	if err != nil {
+		return 0, fmt.Errorf("failed to presign archive upload: %w", err)
+	}
+
+	script := strings.Join([]string{
+		"set -euo pipefail",
+		"archive=$(mktemp)",
+		`trap 'rm -f "$archive"' EXIT`,
+		`tar -czf "$archive" -C ` + volumeMountPath + " .",
+		`curl -fsS --retry 3 -T "$archive" ` + shellQuote(url),
+		`stat -c %s "$archive"`,
+	}, "\n")
+
+	stdout, err := a.exec(project, script)
+	if err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(stdout), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected archive size %q", stdout)
+	}
+	return size, nil
+}
+
+// RestoreVolume streams the archive from the object store straight into tar
+func (a *ExecVolumeArchiver) RestoreVolume(ctx context.Context, project *db.Project, key string) error {
+	url, err := a.store.PresignGet(key, archiveURLExpiry)
+	if err != nil {
+		return fmt.Errorf("failed to presign archive download: %w", err)
+	}
+
+	script := strings.Join([]string{
+		"set -euo pipefail",
+		"curl -fsS --retry 3 " + shellQuote(url) + " | tar -xzf - -C " + volumeMountPath,
+	}, "\n")
+
+	_, err = a.exec(project, script)
+	return err
+}
+
+func (a *ExecVolumeArchiver) DeleteArchive(ctx context.Context, key string) error {
+	return a.store.Delete(ctx, key)
+}
+
+// exec runs a script as root on the project's machine, so file ownership survives
+// the round trip, and returns its stdout
+func (a *ExecVolumeArchiver) exec(project *db.Project, script string) (string, error) {
+	if project.FlyMachineID == nil || *project.FlyMachineID == "" {
+		return "", errors.New("project has no machine")
+	}
+
+	result, err := a.machines.Exec(*project.FlyMachineID, []string{"bash", "-c", script}, archiveExecTimeout)
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("archive command exited with %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return result.Stdout, nil
+}
+
+// shellQuote wraps a string in single quotes for bash
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
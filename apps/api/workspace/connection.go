@@ -130,6 +130,11 @@ func (r *FlyConnectionResolver) GetConnectionInfo(project *db.Project) (*handler
 		return nil, fmt.Errorf("VM is not running (state: %s)", machine.State)
 	}
 
+	// A machine left behind by a region move mounts a volume that's been deleted
+	if project.Region != nil && machine.Region != "" && machine.Region != *project.Region {
+		return nil, fmt.Errorf("VM is in %s but the project is in %s", machine.Region, *project.Region)
+	}
+
 	if machine.PrivateIP == "" {
 		return nil, fmt.Errorf("machine has no IP address")
 	}
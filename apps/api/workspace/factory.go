@@ -5,6 +5,7 @@ import (
 	"aether/apps/api/fly"
 	"aether/apps/api/handlers"
 	"aether/apps/api/local"
+	"aether/apps/api/objectstore"
 )
 
 // Factory creates workspace-related managers based on the runtime mode
@@ -41,3 +42,29 @@ func (f *Factory) ConnectionResolver() handlers.ConnectionResolver {
 	}
 	return NewFlyConnectionResolver(f.flyClient)
 }
+
+// ResourceLister returns the appropriate ResourceLister implementation
+func (f *Factory) ResourceLister() handlers.ResourceLister {
+	if config.IsLocalMode() {
+		return local.NewResourceLister()
+	}
+	return NewFlyResourceLister(f.flyClient)
+}
+
+// WorkspaceProbe returns a WorkspaceProbe that reaches the workspace service
+// through this mode's ConnectionResolver
+func (f *Factory) WorkspaceProbe() handlers.WorkspaceProbe {
+	return NewHTTPWorkspaceProbe(f.ConnectionResolver())
+}
+
+// VolumeArchiver returns the VolumeArchiver for this mode backed by the given
+// store, or nil if there is no store and hibernation is disabled
+func (f *Factory) VolumeArchiver(machines handlers.MachineManager, store objectstore.Store) handlers.VolumeArchiver {
+	if store == nil {
+		return nil
+	}
+	if config.IsLocalMode() {
+		return local.NewVolumeArchiver(store)
+	}
+	return NewExecVolumeArchiver(machines, store)
+}
@@ -0,0 +1,87 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"aether/apps/api/db"
+	"aether/apps/api/handlers"
+	"aether/libs/go/logging"
+)
+
+// HTTPWorkspaceProbe calls the workspace service's HTTP endpoints on the same
+// port the workspace WebSocket is served from
+type HTTPWorkspaceProbe struct {
+	resolver handlers.ConnectionResolver
+	client   *http.Client
+}
+
+func NewHTTPWorkspaceProbe(resolver handlers.ConnectionResolver) *HTTPWorkspaceProbe {
+	return &HTTPWorkspaceProbe{
+		resolver: resolver,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (p *HTTPWorkspaceProbe) GetActivity(ctx context.Context, project *db.Project) (*handlers.WorkspaceActivity, error) {
+	var activity handlers.WorkspaceActivity
+	if err := p.do(ctx, project, http.MethodGet, "/activity", nil, http.StatusOK, &activity); err != nil {
+		return nil, fmt.Errorf("failed to get workspace activity: %w", err)
+	}
+	return &activity, nil
+}
+
+func (p *HTTPWorkspaceProbe) NotifyIdle(ctx context.Context, project *db.Project, notice handlers.IdleNotice) error {
+	body, err := json.Marshal(notice)
+	if err != nil {
+		return fmt.Errorf("failed to encode idle notice: %w", err)
+	}
+	if err := p.do(ctx, project, http.MethodPost, "/idle", body, http.StatusNoContent, nil); err != nil {
+		return fmt.Errorf("failed to send idle notice: %w", err)
+	}
+	return nil
+}
+
+// do sends a request to the project's workspace service and decodes the response
+// into out, if given
+func (p *HTTPWorkspaceProbe) do(ctx context.Context, project *db.Project, method, path string, body []byte, wantStatus int, out any) error {
+	connInfo, err := p.resolver.GetConnectionInfo(project)
+	if err != nil {
+		return fmt.Errorf("failed to get connection info: %w", err)
+	}
+
+	url := "http://" + net.JoinHostPort(connInfo.Host, strconv.Itoa(connInfo.WebSocketPort)) + path
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logging.Default().Debug("failed to close workspace response body", "error", err)
+		}
+	}()
+
+	if resp.StatusCode != wantStatus {
+		return fmt.Errorf("workspace service returned %d", resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package workspace
+
+import (
+	"aether/apps/api/fly"
+	"aether/apps/api/handlers"
+)
+
+// FlyResourceLister lists every machine and volume in the Fly VMs app
+type FlyResourceLister struct {
+	flyClient *fly.Client
+}
+
+func NewFlyResourceLister(flyClient *fly.Client) *FlyResourceLister {
+	return &FlyResourceLister{flyClient: flyClient}
+}
+
+func (l *FlyResourceLister) ListMachines() ([]handlers.Machine, error) {
+	machines, err := l.flyClient.ListMachines()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]handlers.Machine, len(machines))
+	for i, m := range machines {
+		result[i] = handlers.Machine{
+			ID:        m.ID,
+			Name:      m.Name,
+			State:     m.State,
+			Region:    m.Region,
+			PrivateIP: m.PrivateIP,
+			CreatedAt: m.CreatedAt,
+		}
+	}
+	return result, nil
+}
+
+func (l *FlyResourceLister) ListVolumes() ([]handlers.Volume, error) {
+	volumes, err := l.flyClient.ListVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]handlers.Volume, 0, len(volumes))
+	for _, v := range volumes {
+		// Destroyed volumes linger in the listing for a while
+		if v.State == "destroyed" || v.State == "pending_destroy" {
+			continue
+		}
+		result = append(result, handlers.Volume{
+			ID:        v.ID,
+			Name:      v.Name,
+			SizeGB:    v.SizeGB,
+			Region:    v.Region,
+			State:     v.State,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+	return result, nil
+}
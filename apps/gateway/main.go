@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -67,6 +68,12 @@ func main() {
 		previewDomain = "localhost" // Default for local dev
 	}
 
+	// Wake-on-request limits
+	wake := proxy.DefaultWakeConfig()
+	wake.ProjectLimit = envInt(logger, "WAKE_PROJECT_LIMIT", wake.ProjectLimit)
+	wake.ClientLimit = envInt(logger, "WAKE_CLIENT_LIMIT", wake.ClientLimit)
+	wake.Window = time.Duration(envInt(logger, "WAKE_WINDOW_MINUTES", int(wake.Window.Minutes()))) * time.Minute
+
 	// Initialize database client
 	logger.Info("connecting to database")
 	dbClient, err := db.NewClient(databaseURL)
@@ -105,7 +112,7 @@ func main() {
 	}
 
 	// Create proxy handler
-	proxyHandler := proxy.NewHandler(dbClient, machines, previewDomain, logger)
+	proxyHandler := proxy.NewHandler(dbClient, machines, previewDomain, wake, logger)
 
 	// Wrap with Sentry HTTP handler for panic recovery and request context
 	sentryHandler := sentryhttp.New(sentryhttp.Options{
@@ -147,3 +154,18 @@ func main() {
 
 	logger.Info("server stopped")
 }
+
+// envInt reads a positive integer from the environment, falling back to the
+// default if it is unset or invalid
+func envInt(logger *logging.Logger, name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		logger.Warn("invalid environment variable, using default", "name", name, "value", value, "default", fallback)
+		return fallback
+	}
+	return n
+}
@@ -20,16 +20,20 @@ type Handler struct {
 	machines      handlers.MachineManager
 	previewDomain string
 	cache         *ProjectCache
+	wake          WakeConfig
+	wakeLimiter   *RateLimiter
 	log           *logging.Logger
 }
 
 // NewHandler creates a new proxy handler
-func NewHandler(dbClient *db.Client, machines handlers.MachineManager, previewDomain string, logger *logging.Logger) *Handler {
+func NewHandler(dbClient *db.Client, machines handlers.MachineManager, previewDomain string, wake WakeConfig, logger *logging.Logger) *Handler {
 	return &Handler{
 		db:            dbClient,
 		machines:      machines,
 		previewDomain: previewDomain,
 		cache:         NewProjectCache(30 * time.Second),
+		wake:          wake,
+		wakeLimiter:   NewRateLimiter(wake.ClientLimit, wake.Window),
 		log:           logger,
 	}
 }
@@ -79,19 +83,20 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if project is running
-	if project.Status != "running" {
-		h.log.Debug("project not running", "project_id", project.ID, "status", project.Status)
-		http.Error(w, "Project is not running", http.StatusServiceUnavailable)
-		return
-	}
-
-	// Validate auth token
+	// Validate auth token, before anything can wake the project
 	if !h.validateAccess(project, info.Token) {
 		http.Error(w, "Unauthorized", http.StatusForbidden)
 		return
 	}
 
+	// Check if project is running, waking it if it opted in
+	if project.Status != db.StatusRunning {
+		project = h.serveNotRunning(w, r, project)
+		if project == nil {
+			return
+		}
+	}
+
 	// Get machine private IP
 	if project.FlyMachineID == nil || *project.FlyMachineID == "" {
 		h.log.Warn("project has no machine", "project_id", project.ID)
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// rateWindow counts events for one key in the current window
+type rateWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// RateLimiter allows up to a fixed number of events per key in each window.
+// Counts are kept in memory, so each gateway instance limits independently.
+type RateLimiter struct {
+	windows map[string]*rateWindow
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+}
+
+// NewRateLimiter creates a limiter allowing limit events per key per window
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	limiter := &RateLimiter{
+		windows: make(map[string]*rateWindow),
+		limit:   limit,
+		window:  window,
+	}
+
+	// Start background cleanup goroutine
+	go limiter.cleanupLoop()
+
+	return limiter
+}
+
+// Allow records an event for key and reports whether it is within the limit
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	w, exists := l.windows[key]
+	if !exists || now.After(w.resetAt) {
+		w = &rateWindow{resetAt: now.Add(l.window)}
+		l.windows[key] = w
+	}
+
+	if w.count >= l.limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// cleanupLoop periodically removes windows that have ended
+func (l *RateLimiter) cleanupLoop() {
+	ticker := time.NewTicker(l.window)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		l.cleanup()
+	}
+}
+
+// cleanup removes all windows that have ended
+func (l *RateLimiter) cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for key, w := range l.windows {
+		if now.After(w.resetAt) {
+			delete(l.windows, key)
+		}
+	}
+}
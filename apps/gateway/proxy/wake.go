@@ -0,0 +1,229 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"aether/apps/api/db"
+	"aether/apps/api/quota"
+)
+
+// wakePollInterval is how often a held request checks whether its project is up
+const wakePollInterval = time.Second
+
+// startingPageRefresh is how often the starting page reloads itself, in seconds
+const startingPageRefresh = 3
+
+var errWakeLimited = errors.New("wake rate limit exceeded")
+
+// WakeConfig controls how preview requests start stopped projects that have
+// opted in to wake-on-request
+type WakeConfig struct {
+	// ProjectLimit is how many times a project can be woken per Window, counted
+	// from its event log so it holds across gateway instances
+	ProjectLimit int
+	// ClientLimit is how many wakes a single client IP can trigger per Window
+	ClientLimit int
+	Window      time.Duration
+	// HoldTimeout is how long a request that can't be shown the starting page,
+	// such as an API call, waits for the project to come up
+	HoldTimeout time.Duration
+}
+
+// DefaultWakeConfig returns the wake limits used when none are configured
+func DefaultWakeConfig() WakeConfig {
+	return WakeConfig{
+		ProjectLimit: 6,
+		ClientLimit:  10,
+		Window:       time.Hour,
+		HoldTimeout:  25 * time.Second,
+	}
+}
+
+// serveNotRunning handles a request for a project that isn't running. Projects
+// that opted in are started; browsers get a page that reloads until the project
+// is up, and other requests are held until it is. Returns the running project to
+// proxy to, or nil if a response has been written.
+func (h *Handler) serveNotRunning(w http.ResponseWriter, r *http.Request, project *db.Project) *db.Project {
+	log := h.log.With("project_id", project.ID, "status", project.Status)
+
+	if !project.WakeOnRequest {
+		log.Debug("project not running")
+		http.Error(w, "Project is not running", http.StatusServiceUnavailable)
+		return nil
+	}
+
+	switch project.Status {
+	case db.StatusStopped:
+		if err := h.wakeProject(r.Context(), project, getClientIP(r)); err != nil {
+			if errors.Is(err, errWakeLimited) {
+				log.Info("preview wake rate limited", "client_ip", getClientIP(r))
+				w.Header().Set("Retry-After", strconv.Itoa(int(h.wake.Window.Seconds())))
+				http.Error(w, "Project is not running and has been woken too often, try again later", http.StatusTooManyRequests)
+				return nil
+			}
+			var exceeded *quota.Exceeded
+			if errors.As(err, &exceeded) {
+				log.Info("preview wake over quota", "quota", exceeded.Quota, "plan", exceeded.Plan)
+				status := http.StatusForbidden
+				if exceeded.Temporary() {
+					status = http.StatusTooManyRequests
+				}
+				http.Error(w, "Project is not running and its plan has no room to start it", status)
+				return nil
+			}
+			log.Error("failed to wake project", "error", err)
+			http.Error(w, "Failed to start project", http.StatusInternalServerError)
+			return nil
+		}
+	case db.StatusStarting, db.StatusStopping:
+		// Already coming up, or will be woken by a later request once it has stopped
+	default:
+		log.Debug("project not running")
+		http.Error(w, "Project is not running", http.StatusServiceUnavailable)
+		return nil
+	}
+
+	if wantsStartingPage(r) {
+		writeStartingPage(w, project)
+		return nil
+	}
+	return h.awaitRunning(w, r, project.ID)
+}
+
+// wakeProject begins a start operation for a stopped project, which the API's
+// operation worker picks up. Returns errWakeLimited if the client or the project
+// has used up its wakes for the current window, or a *quota.Exceeded if the
+// plan the project counts against has no room for the machine.
+func (h *Handler) wakeProject(ctx context.Context, project *db.Project, clientIP string) error {
+	if !h.wakeLimiter.Allow(clientIP) {
+		return errWakeLimited
+	}
+
+	wakes, err := h.db.CountProjectEventsSince(ctx, project.ID, db.EventPreviewWake, time.Now().Add(-h.wake.Window))
+	if err != nil {
+		return err
+	}
+	if wakes >= h.wake.ProjectLimit {
+		return errWakeLimited
+	}
+
+	// The same account is charged, under the same lock, as a start from the API
+	releaseQuota, err := quota.Reserve(ctx, h.db, project.UserID, project.OrganizationID, quota.ForStart(project))
+	if err != nil {
+		return err
+	}
+	defer releaseQuota()
+
+	op, err := h.db.BeginOperation(ctx, project.ID, project.UserID, db.OperationStart, []string{db.StatusStopped}, db.StatusStarting)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidTransition) || errors.Is(err, db.ErrOperationInProgress) {
+			// Another request, or the owner, got there first
+			return nil
+		}
+		return fmt.Errorf("failed to begin start: %w", err)
+	}
+
+	h.log.Info("waking project for preview request", "project_id", project.ID, "operation_id", op.ID)
+	message := "Started by a preview request"
+	if err := h.db.RecordProjectEvent(ctx, project.ID, db.EventPreviewWake, &message, map[string]any{
+		"operation_id": op.ID,
+	}); err != nil {
+		h.log.Error("failed to record preview wake event", "project_id", project.ID, "error", err)
+	}
+	return nil
+}
+
+// awaitRunning holds a request until its project is running, polling the
+// database. Returns the running project, or nil if a response has been written.
+func (h *Handler) awaitRunning(w http.ResponseWriter, r *http.Request, projectID string) *db.Project {
+	ctx, cancel := context.WithTimeout(r.Context(), h.wake.HoldTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(wakePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "Project is starting", http.StatusServiceUnavailable)
+			return nil
+		case <-ticker.C:
+		}
+
+		project, err := h.db.GetProject(ctx, projectID)
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			h.log.Error("failed to poll project status", "project_id", projectID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return nil
+		}
+
+		switch project.Status {
+		case db.StatusRunning:
+			return project
+		case db.StatusStarting, db.StatusStopping:
+			continue
+		case db.StatusStopped:
+			// Finished stopping; a retry will wake it
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Project is not running", http.StatusServiceUnavailable)
+			return nil
+		default:
+			http.Error(w, "Project failed to start", http.StatusServiceUnavailable)
+			return nil
+		}
+	}
+}
+
+// wantsStartingPage reports whether the request is a browser navigation that can
+// be answered with the starting page rather than held
+func wantsStartingPage(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		!isWebSocketRequest(r) &&
+		strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// writeStartingPage serves a page that reloads itself until the project is up
+func writeStartingPage(w http.ResponseWriter, project *db.Project) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", strconv.Itoa(startingPageRefresh))
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	page := fmt.Sprintf(startingPage, startingPageRefresh, html.EscapeString(project.Name))
+	if _, err := w.Write([]byte(page)); err != nil {
+		return
+	}
+}
+
+const startingPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="%d">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Starting your environment</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; background: #0a0a0a; color: #e5e5e5; }
+main { text-align: center; }
+p { color: #a3a3a3; }
+</style>
+</head>
+<body>
+<main>
+<h1>Starting your environment</h1>
+<p>%s is waking up. This page will reload when it's ready.</p>
+</main>
+</body>
+</html>
+`
@@ -29,6 +29,36 @@ export function StatusBadge({ status }: StatusBadgeProps) {
       dot: "bg-red-500",
       label: "Error",
     },
+    deleting: {
+      color: "bg-red-900/50 text-red-300",
+      dot: "bg-red-500 animate-pulse",
+      label: "Deleting",
+    },
+    restoring: {
+      color: "bg-yellow-900/50 text-yellow-300",
+      dot: "bg-yellow-500 animate-pulse",
+      label: "Restoring",
+    },
+    moving: {
+      color: "bg-yellow-900/50 text-yellow-300",
+      dot: "bg-yellow-500 animate-pulse",
+      label: "Moving",
+    },
+    hibernating: {
+      color: "bg-blue-900/50 text-blue-300",
+      dot: "bg-blue-500 animate-pulse",
+      label: "Hibernating",
+    },
+    hibernated: {
+      color: "bg-blue-900/50 text-blue-300",
+      dot: "bg-blue-500",
+      label: "Hibernated",
+    },
+    trashed: {
+      color: "bg-red-900/50 text-red-300",
+      dot: "bg-red-500",
+      label: "Trashed",
+    },
   };
 
   const { color, dot, label } = config[status] || config.stopped;
@@ -64,6 +64,7 @@ export interface AgentChatProps {
 export function AgentChat({ projectId, defaultAgent = "claude" }: AgentChatProps) {
   const [agent, setAgent] = useState<AgentType>(defaultAgent);
   const [error, setError] = useState<string | null>(null);
+  const [budgetNotice, setBudgetNotice] = useState<string | null>(null);
   const [settings, setSettings] = useState<AgentSettings & { extendedThinking: boolean }>(() => ({
     model: agentConfig[defaultAgent].defaultModel,
     permissionMode: "bypassPermissions",
@@ -81,6 +82,11 @@ export function AgentChat({ projectId, defaultAgent = "claude" }: AgentChatProps
 
   const handleMessage = useCallback(
     (message: ServerMessage) => {
+      if (message.type === "budget_warning" || message.type === "budget_exceeded") {
+        // Spend notices sit alongside the conversation rather than replacing it
+        setBudgetNotice(message.content ?? null);
+        return;
+      }
       if (message.type === "error" && message.error) {
         setError(message.error);
       } else {
@@ -203,6 +209,9 @@ export function AgentChat({ projectId, defaultAgent = "claude" }: AgentChatProps
         onFollowupSelect={handleFollowupSelect}
       />
 
+      {budgetNotice && !error && (
+        <div className="shrink-0 bg-amber-900/50 px-4 py-2 text-sm text-amber-200">{budgetNotice}</div>
+      )}
       {error && (
         <div className="shrink-0 bg-red-900/50 px-4 py-2 text-sm text-red-200">{error}</div>
       )}
@@ -19,8 +19,15 @@ export function useProjects(): UseProjectsReturn {
   const refresh = useCallback(async () => {
     try {
       setError(null);
-      const { projects } = await api.listProjects();
-      setProjects(projects);
+      // The dashboard shows every project, so follow the pages to the end
+      const all: Project[] = [];
+      let cursor: string | undefined;
+      do {
+        const page = await api.listProjects({ limit: 200, cursor });
+        all.push(...page.projects);
+        cursor = page.next_cursor;
+      } while (cursor);
+      setProjects(all);
     } catch (err) {
       setError(err instanceof Error ? err.message : "Failed to load projects");
     } finally {
@@ -2,7 +2,7 @@ import { useState, useRef, useCallback, useEffect } from "react";
 import ReconnectingWebSocket from "reconnecting-websocket";
 import { supabase } from "@/lib/supabase";
 import { api } from "@/lib/api";
-import type { FileInfo, FileTree, DirListing } from "@aether/types";
+import type { FileInfo, FileTree, DirListing, IdleNotice } from "@aether/types";
 import type { AgentType, ServerMessage, AgentSettings, PromptContext } from "@/types/agent";
 
 export type ConnectionStatus = "disconnected" | "connecting" | "connected" | "error";
@@ -189,6 +189,7 @@ type IncomingMessage =
   | PortChangeMessage
   | PortKillResponse
   | AgentChannelMessage
+  | IdleNotice
   | ErrorMessage;
 
 // =============================================================================
@@ -215,6 +216,8 @@ export interface UseWorkspaceConnectionOptions {
     isDirectory: boolean
   ) => void;
   onPortChange?: (action: "open" | "close", port: number) => void;
+  /** Idle stop warnings, and snoozes of them from any tab */
+  onIdleNotice?: (notice: IdleNotice) => void;
   onError?: (error: string) => void;
   onStatusChange?: (status: ConnectionStatus) => void;
 }
@@ -271,6 +274,7 @@ export function useWorkspaceConnection({
   onAgentMessage,
   onFileChange,
   onPortChange,
+  onIdleNotice,
   onError,
   onStatusChange,
 }: UseWorkspaceConnectionOptions): UseWorkspaceConnectionReturn {
@@ -289,6 +293,7 @@ export function useWorkspaceConnection({
   const onAgentMessageRef = useRef(onAgentMessage);
   const onFileChangeRef = useRef(onFileChange);
   const onPortChangeRef = useRef(onPortChange);
+  const onIdleNoticeRef = useRef(onIdleNotice);
   const onErrorRef = useRef(onError);
   const onStatusChangeRef = useRef(onStatusChange);
 
@@ -308,6 +313,10 @@ export function useWorkspaceConnection({
     onPortChangeRef.current = onPortChange;
   }, [onPortChange]);
 
+  useEffect(() => {
+    onIdleNoticeRef.current = onIdleNotice;
+  }, [onIdleNotice]);
+
   useEffect(() => {
     onErrorRef.current = onError;
   }, [onError]);
@@ -439,6 +448,10 @@ export function useWorkspaceConnection({
             }
             break;
 
+          case "idle":
+            onIdleNoticeRef.current?.(message);
+            break;
+
           case "error":
             onErrorRef.current?.(message.error);
             break;
@@ -22,10 +22,30 @@ export type { StartProjectResponse as StartResponse } from "@aether/types";
 
 import type {
   Project,
+  TrashedProject,
+  ListProjectsQuery,
+  ProjectListResponse,
   CreateProjectInput,
   UpdateProjectInput,
+  SetLabelsInput,
+  MoveProjectInput,
+  MoveProjectResponse,
+  RegionListResponse,
   UserSettings,
   UpdateUserSettingsInput,
+  QuotaResponse,
+  UsageQuery,
+  UsageReport,
+  LLMUsageQuery,
+  LLMUsageReport,
+  AgentBudget,
+  CreateAgentBudgetInput,
+  UpdateAgentBudgetInput,
+  Organization,
+  OrganizationRole,
+  OrganizationMember,
+  OrganizationInvitation,
+  UpdateOrganizationSettingsInput,
   ConnectedProvider,
   ListProvidersResponse,
   ApiError,
@@ -53,6 +73,22 @@ async function getAuthHeaders(): Promise<HeadersInit> {
   };
 }
 
+/** Makes a write conditional on the resource still being at version */
+function ifMatch(version?: number): HeadersInit {
+  return version === undefined ? {} : { "If-Match": `"${version}"` };
+}
+
+/** Builds the path of a usage report, for one project or the whole account */
+function usagePath(query: UsageQuery, projectId?: string, format?: "csv"): string {
+  const params = new URLSearchParams();
+  for (const [key, value] of Object.entries({ ...query, format })) {
+    if (value !== undefined) params.set(key, value);
+  }
+  const base = projectId ? `/projects/${projectId}/usage` : "/user/usage";
+  const search = params.toString();
+  return search ? `${base}?${search}` : base;
+}
+
 async function apiRequest<T>(path: string, options: RequestInit = {}): Promise<T> {
   const headers = await getAuthHeaders();
 
@@ -79,8 +115,16 @@ async function apiRequest<T>(path: string, options: RequestInit = {}): Promise<T
 }
 
 export const api = {
-  async listProjects(): Promise<{ projects: Project[] }> {
-    return apiRequest("/projects");
+  async listProjects(query: ListProjectsQuery = {}): Promise<ProjectListResponse> {
+    const params = new URLSearchParams();
+    for (const [key, value] of Object.entries(query)) {
+      if (value === undefined) continue;
+      for (const v of Array.isArray(value) ? value : [value]) {
+        params.append(key, String(v));
+      }
+    }
+    const search = params.toString();
+    return apiRequest(search ? `/projects?${search}` : "/projects");
   },
 
   async getProject(id: string): Promise<Project> {
@@ -94,19 +138,59 @@ export const api = {
     });
   },
 
-  async updateProject(id: string, input: UpdateProjectInput): Promise<Project> {
+  /** Pass the version the edit was based on to fail instead of overwriting newer changes */
+  async updateProject(id: string, input: UpdateProjectInput, version?: number): Promise<Project> {
     return apiRequest(`/projects/${id}`, {
       method: "PATCH",
+      headers: ifMatch(version),
       body: JSON.stringify(input),
     });
   },
 
+  async setProjectLabels(id: string, input: SetLabelsInput): Promise<Project> {
+    return apiRequest(`/projects/${id}/labels`, {
+      method: "PUT",
+      body: JSON.stringify(input),
+    });
+  },
+
+  /** Moves a project to the trash, or purges it if it is already there */
   async deleteProject(id: string): Promise<void> {
-    return apiRequest(`/projects/${id}`, {
+    await apiRequest(`/projects/${id}`, {
       method: "DELETE",
     });
   },
 
+  async listTrash(): Promise<{ projects: TrashedProject[] }> {
+    return apiRequest("/projects/trash");
+  },
+
+  async restoreProject(id: string): Promise<Project> {
+    return apiRequest(`/projects/${id}/restore`, {
+      method: "POST",
+    });
+  },
+
+  /** Moves a stopped project to another region, copying its volume there in the background */
+  async moveProject(id: string, input: MoveProjectInput): Promise<MoveProjectResponse> {
+    return apiRequest(`/projects/${id}/move`, {
+      method: "POST",
+      body: JSON.stringify(input),
+    });
+  },
+
+  /** Moves a project into an organization, or with null back to its creator */
+  async setProjectOrganization(id: string, organizationId: string | null): Promise<Project> {
+    return apiRequest(`/projects/${id}/organization`, {
+      method: "PUT",
+      body: JSON.stringify({ organization_id: organizationId }),
+    });
+  },
+
+  async listRegions(): Promise<RegionListResponse> {
+    return apiRequest("/regions");
+  },
+
   async startProject(id: string): Promise<StartResponse> {
     return apiRequest(`/projects/${id}/start`, {
       method: "POST",
@@ -159,10 +243,150 @@ export const api = {
     return apiRequest("/user/settings");
   },
 
-  async updateUserSettings(input: UpdateUserSettingsInput): Promise<UserSettings> {
+  async updateUserSettings(input: UpdateUserSettingsInput, version?: number): Promise<UserSettings> {
     return apiRequest("/user/settings", {
+      method: "PUT",
+      headers: ifMatch(version),
+      body: JSON.stringify(input),
+    });
+  },
+
+  async getQuota(): Promise<QuotaResponse> {
+    return apiRequest("/user/quota");
+  },
+
+  async getOrganizationQuota(id: string): Promise<QuotaResponse> {
+    return apiRequest(`/organizations/${id}/quota`);
+  },
+
+  async getUsage(query: UsageQuery = {}, projectId?: string): Promise<UsageReport> {
+    return apiRequest(usagePath(query, projectId));
+  },
+
+  async downloadUsageCsv(query: UsageQuery = {}, projectId?: string): Promise<Blob> {
+    const response = await fetch(`${API_URL}${usagePath(query, projectId, "csv")}`, {
+      headers: await getAuthHeaders(),
+    });
+    if (!response.ok) {
+      throw new Error(`Request failed with status ${response.status}`);
+    }
+    return response.blob();
+  },
+
+  async getLLMUsage(query: LLMUsageQuery = {}, projectId?: string): Promise<LLMUsageReport> {
+    const params = new URLSearchParams();
+    for (const [key, value] of Object.entries(query)) {
+      if (value !== undefined) params.set(key, value);
+    }
+    const base = projectId ? `/projects/${projectId}/llm-usage` : "/user/llm-usage";
+    const search = params.toString();
+    return apiRequest(search ? `${base}?${search}` : base);
+  },
+
+  async listAgentBudgets(): Promise<{ budgets: AgentBudget[] }> {
+    return apiRequest("/user/agent-budgets");
+  },
+
+  async createAgentBudget(input: CreateAgentBudgetInput): Promise<AgentBudget> {
+    return apiRequest("/user/agent-budgets", {
+      method: "POST",
+      body: JSON.stringify(input),
+    });
+  },
+
+  async updateAgentBudget(id: string, input: UpdateAgentBudgetInput): Promise<AgentBudget> {
+    return apiRequest(`/user/agent-budgets/${id}`, {
+      method: "PATCH",
+      body: JSON.stringify(input),
+    });
+  },
+
+  async resetAgentBudget(id: string): Promise<AgentBudget> {
+    return apiRequest(`/user/agent-budgets/${id}/reset`, { method: "POST" });
+  },
+
+  async deleteAgentBudget(id: string): Promise<void> {
+    return apiRequest(`/user/agent-budgets/${id}`, { method: "DELETE" });
+  },
+
+  async listOrganizations(): Promise<{ organizations: Organization[] }> {
+    return apiRequest("/organizations");
+  },
+
+  async createOrganization(name: string): Promise<Organization> {
+    return apiRequest("/organizations", {
+      method: "POST",
+      body: JSON.stringify({ name }),
+    });
+  },
+
+  async getOrganization(id: string): Promise<Organization> {
+    return apiRequest(`/organizations/${id}`);
+  },
+
+  async renameOrganization(id: string, name: string): Promise<Organization> {
+    return apiRequest(`/organizations/${id}`, {
+      method: "PATCH",
+      body: JSON.stringify({ name }),
+    });
+  },
+
+  async deleteOrganization(id: string): Promise<void> {
+    return apiRequest(`/organizations/${id}`, { method: "DELETE" });
+  },
+
+  /** The caller's settings with the organization's defaults applied */
+  async getOrganizationSettings(id: string): Promise<UserSettings> {
+    return apiRequest(`/organizations/${id}/settings`);
+  },
+
+  async updateOrganizationSettings(id: string, input: UpdateOrganizationSettingsInput): Promise<Organization> {
+    return apiRequest(`/organizations/${id}/settings`, {
       method: "PUT",
       body: JSON.stringify(input),
     });
   },
+
+  async listOrganizationMembers(id: string): Promise<{ members: OrganizationMember[] }> {
+    return apiRequest(`/organizations/${id}/members`);
+  },
+
+  async setOrganizationMemberRole(id: string, userId: string, role: OrganizationRole): Promise<OrganizationMember> {
+    return apiRequest(`/organizations/${id}/members/${userId}`, {
+      method: "PATCH",
+      body: JSON.stringify({ role }),
+    });
+  },
+
+  /** Removing yourself leaves the organization */
+  async removeOrganizationMember(id: string, userId: string): Promise<void> {
+    return apiRequest(`/organizations/${id}/members/${userId}`, { method: "DELETE" });
+  },
+
+  async listOrganizationInvitations(id: string): Promise<{ invitations: OrganizationInvitation[] }> {
+    return apiRequest(`/organizations/${id}/invitations`);
+  },
+
+  async inviteToOrganization(id: string, email: string, role?: OrganizationRole): Promise<OrganizationInvitation> {
+    return apiRequest(`/organizations/${id}/invitations`, {
+      method: "POST",
+      body: JSON.stringify({ email, role }),
+    });
+  },
+
+  async deleteOrganizationInvitation(id: string, invitationId: string): Promise<void> {
+    return apiRequest(`/organizations/${id}/invitations/${invitationId}`, { method: "DELETE" });
+  },
+
+  async listInvitations(): Promise<{ invitations: OrganizationInvitation[] }> {
+    return apiRequest("/user/invitations");
+  },
+
+  async acceptInvitation(invitationId: string): Promise<Organization> {
+    return apiRequest(`/user/invitations/${invitationId}/accept`, { method: "POST" });
+  },
+
+  async declineInvitation(invitationId: string): Promise<void> {
+    return apiRequest(`/user/invitations/${invitationId}`, { method: "DELETE" });
+  },
 };
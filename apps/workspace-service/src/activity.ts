@@ -0,0 +1,70 @@
+import os from "node:os";
+import { readProcNetTcp, isLoopbackAddress, TCP_ESTABLISHED, TCP_LISTEN } from "./utils/procnet";
+
+/** Ports that never carry preview traffic: SSH and the workspace service itself */
+const NON_PREVIEW_PORTS = new Set([22, 2222, 3001]);
+
+/**
+ * Activity signals the API's idle checker uses to keep a project running while
+ * nobody has the workspace open. Field names match the API's JSON.
+ */
+export interface WorkspaceActivity {
+  /** Agent prompts still being worked on, including ones whose client disconnected */
+  agent_sessions: number;
+  /** One-minute load average divided by the number of CPUs */
+  cpu_load: number;
+  /** Open connections to preview ports from outside the VM */
+  preview_connections: number;
+}
+
+let agentSessions = 0;
+
+/**
+ * Count an agent prompt as running until the returned function is called
+ */
+export function trackAgentSession(): () => void {
+  agentSessions++;
+  let done = false;
+  return () => {
+    if (!done) {
+      done = true;
+      agentSessions--;
+    }
+  };
+}
+
+export async function getActivity(): Promise<WorkspaceActivity> {
+  const cpus = os.cpus().length || 1;
+  return {
+    agent_sessions: agentSessions,
+    cpu_load: Math.round((os.loadavg()[0] / cpus) * 100) / 100,
+    preview_connections: await countPreviewConnections(),
+  };
+}
+
+/**
+ * Count established connections to listening ports from non-loopback peers. The
+ * gateway reaches previews through the port forwarders, whose own hop to the dev
+ * server is over loopback and so isn't counted twice.
+ */
+async function countPreviewConnections(): Promise<number> {
+  const [tcp4, tcp6] = await Promise.all([
+    readProcNetTcp("/proc/net/tcp").catch(() => []),
+    readProcNetTcp("/proc/net/tcp6").catch(() => []),
+  ]);
+  const sockets = [...tcp4, ...tcp6];
+
+  const listening = new Set<number>();
+  for (const s of sockets) {
+    if (s.state === TCP_LISTEN && !NON_PREVIEW_PORTS.has(s.localPort)) {
+      listening.add(s.localPort);
+    }
+  }
+
+  return sockets.filter(
+    (s) =>
+      s.state === TCP_ESTABLISHED &&
+      listening.has(s.localPort) &&
+      !isLoopbackAddress(s.remoteAddress)
+  ).length;
+}
@@ -56,6 +56,7 @@ export class ClaudeProvider implements AgentProvider {
         },
       });
 
+      let hasDone = false;
       for await (const msg of q) {
         // Check if aborted before processing
         if (this.abortController?.signal.aborted) {
@@ -67,17 +68,22 @@ export class ClaudeProvider implements AgentProvider {
           this.sessionId = msg.session_id;
         }
 
-        const event = this.mapMessage(msg, options.autoApprove);
-        if (event) yield event;
+        const event = this.mapMessage(msg, options.autoApprove, this.getModelId(options.model));
+        if (event) {
+          if (event.type === "done") hasDone = true;
+          yield event;
+        }
       }
 
-      yield { type: "done" };
+      if (!hasDone) {
+        yield { type: "done" };
+      }
     } catch (err) {
       yield { type: "error", error: err instanceof Error ? err.message : String(err) };
     }
   }
 
-  private mapMessage(msg: SDKMessage, autoApprove: boolean): AgentEvent | null {
+  private mapMessage(msg: SDKMessage, autoApprove: boolean, model: string): AgentEvent | null {
     switch (msg.type) {
       case "system":
         return null;
@@ -132,10 +138,28 @@ export class ClaudeProvider implements AgentProvider {
       }
 
       case "result": {
-        const resultMsg = msg as { type: "result"; subtype: string; errors?: string[] };
+        const resultMsg = msg as {
+          type: "result";
+          subtype: string;
+          errors?: string[];
+          usage?: { input_tokens?: number; output_tokens?: number };
+          total_cost_usd?: number;
+        };
         if (resultMsg.subtype.startsWith("error") && resultMsg.errors) {
           return { type: "error", error: resultMsg.errors.join("; ") };
         }
+        // Report the turn's usage so the API can meter it
+        if (resultMsg.usage) {
+          return {
+            type: "done",
+            usage: {
+              inputTokens: resultMsg.usage.input_tokens || 0,
+              outputTokens: resultMsg.usage.output_tokens || 0,
+              cost: resultMsg.total_cost_usd,
+              model,
+            },
+          };
+        }
         return null;
       }
 
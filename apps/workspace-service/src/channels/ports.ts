@@ -1,5 +1,6 @@
 import { spawn, type Subprocess } from "bun";
 import { logger } from "../logging";
+import { readProcNetTcp, TCP_LISTEN } from "../utils/procnet";
 import type { PortChangeMessage } from "./types";
 
 export interface PortWatcherConfig {
@@ -96,8 +97,8 @@ export class PortWatcher {
   private async getListeningPorts(): Promise<Set<number>> {
     // Read IPv4 and IPv6 in parallel
     const [tcp4, tcp6] = await Promise.all([
-      this.readProcNetTcp("/proc/net/tcp").catch(() => []),
-      this.readProcNetTcp("/proc/net/tcp6").catch(() => []),
+      this.readListeningPorts("/proc/net/tcp").catch(() => []),
+      this.readListeningPorts("/proc/net/tcp6").catch(() => []),
     ]);
 
     const portSet = new Set<number>([...tcp4, ...tcp6]);
@@ -111,45 +112,11 @@ export class PortWatcher {
   }
 
   /**
-   * Parse /proc/net/tcp format to extract listening ports
-   *
-   * Format:
-   *   sl  local_address rem_address   st tx_queue rx_queue ...
-   *    0: 0100007F:1F90 00000000:0000 0A 00000000:00000000 ...
-   *
-   * local_address is hex IP:PORT, st is state (0A = LISTEN)
+   * Extract listening ports from /proc/net/tcp or /proc/net/tcp6
    */
-  private async readProcNetTcp(path: string): Promise<number[]> {
-    const content = await Bun.file(path).text();
-    const lines = content.trim().split("\n");
-    const ports: number[] = [];
-
-    // Skip header line
-    for (let i = 1; i < lines.length; i++) {
-      const line = lines[i].trim();
-      const parts = line.split(/\s+/);
-
-      if (parts.length < 4) continue;
-
-      const localAddress = parts[1];
-      const state = parts[3];
-
-      // Only interested in LISTEN state (0A)
-      if (state !== "0A") continue;
-
-      // Parse port from local_address (format: ADDR:PORT in hex)
-      const colonIndex = localAddress.lastIndexOf(":");
-      if (colonIndex === -1) continue;
-
-      const portHex = localAddress.substring(colonIndex + 1);
-      const port = parseInt(portHex, 16);
-
-      if (!isNaN(port) && port > 0) {
-        ports.push(port);
-      }
-    }
-
-    return ports;
+  private async readListeningPorts(path: string): Promise<number[]> {
+    const sockets = await readProcNetTcp(path);
+    return sockets.filter((s) => s.state === TCP_LISTEN && s.localPort > 0).map((s) => s.localPort);
   }
 
   /**
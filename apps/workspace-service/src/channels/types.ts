@@ -3,7 +3,7 @@
  * All messages have a `channel` field for routing.
  */
 
-export type Channel = "terminal" | "agent" | "files" | "ports";
+export type Channel = "terminal" | "agent" | "files" | "ports" | "idle";
 
 // =============================================================================
 // Base Message
@@ -258,6 +258,27 @@ export interface PortKillResponse extends BaseMessage {
 
 export type PortsMessage = PortChangeMessage | PortKillResponse;
 
+// =============================================================================
+// Idle Channel
+// =============================================================================
+
+// Sent by the API before it stops an idle project (outbound only)
+export interface IdleWarningMessage extends BaseMessage {
+  channel: "idle";
+  type: "warning";
+  stops_at: string;
+  max_snooze_minutes: number;
+}
+
+// Sent by the API when someone snoozes the idle stop (outbound only)
+export interface IdleSnoozedMessage extends BaseMessage {
+  channel: "idle";
+  type: "snoozed";
+  snoozed_until: string;
+}
+
+export type IdleMessage = IdleWarningMessage | IdleSnoozedMessage;
+
 // =============================================================================
 // Union Types
 // =============================================================================
@@ -275,7 +296,8 @@ export type OutgoingMessage =
   | FileChangeMessage
   | FileOperationResponse
   | PortChangeMessage
-  | PortKillResponse;
+  | PortKillResponse
+  | IdleMessage;
 
 // =============================================================================
 // Type Guards
@@ -300,3 +322,9 @@ export function isPortKillRequest(msg: unknown): msg is PortKillRequest {
   const m = msg as Record<string, unknown>;
   return m.channel === "ports" && m.type === "kill" && typeof m.requestId === "string";
 }
+
+export function isIdleMessage(msg: unknown): msg is IdleMessage {
+  if (typeof msg !== "object" || msg === null) return false;
+  const m = msg as Record<string, unknown>;
+  return m.channel === "idle" && (m.type === "warning" || m.type === "snoozed");
+}
@@ -1,6 +1,7 @@
 import { readFile } from "node:fs/promises";
 import path from "node:path";
 import { createProvider, isAgentConfigured } from "./agents";
+import { trackAgentSession } from "./activity";
 import { buildFullPrompt } from "./utils/context";
 import type {
   AgentType,
@@ -112,9 +113,11 @@ export class AgentHandler {
 
       case "prompt":
         // Don't await - process in background so abort can interrupt
-        this.handlePrompt(msg).catch((err) => {
-          this.sender.send({ type: "error", error: String(err) });
-        });
+        this.handlePrompt(msg)
+          .catch((err) => {
+            this.sender.send({ type: "error", error: String(err) });
+          })
+          .finally(trackAgentSession());
         break;
 
       case "abort":
@@ -133,9 +136,11 @@ export class AgentHandler {
           break;
         }
         // Don't await - process in background so abort can interrupt
-        this.handleToolResponse(msg.toolResponse).catch((err) => {
-          this.sender.send({ type: "error", error: String(err) });
-        });
+        this.handleToolResponse(msg.toolResponse)
+          .catch((err) => {
+            this.sender.send({ type: "error", error: String(err) });
+          })
+          .finally(trackAgentSession());
         break;
     }
   }
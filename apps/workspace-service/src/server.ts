@@ -7,7 +7,9 @@ import {
   isTerminalMessage,
   isFileOperationRequest,
   isPortKillRequest,
+  isIdleMessage,
 } from "./channels";
+import { getActivity } from "./activity";
 import { logger, createContextLogger, Logger, CorrelationContext } from "./logging";
 import type { AgentType, ClientMessage, ServerMessage } from "./types";
 import type {
@@ -21,6 +23,8 @@ import type { ServerWebSocket } from "bun";
 const PORT = parseInt(Bun.env.AGENT_PORT || "3001");
 const VALID_AGENTS = ["claude", "codex", "codebuff", "opencode"];
 const PROJECT_CWD = Bun.env.PROJECT_CWD || "/home/coder/workspace/project";
+// Pub/sub topic every unified workspace connection subscribes to
+const WORKSPACE_TOPIC = "workspace";
 
 interface WSData {
   mode: "workspace" | "agent-only";
@@ -104,6 +108,25 @@ const _server = Bun.serve<WSData>({
       return new Response("OK", { status: 200 });
     }
 
+    // Activity signals for the API's idle checker
+    if (url.pathname === "/activity" && req.method === "GET") {
+      return getActivity().then((activity) => Response.json(activity));
+    }
+
+    // Idle stop notices from the API, relayed to every connected workspace client
+    if (url.pathname === "/idle" && req.method === "POST") {
+      return req
+        .json()
+        .then((msg) => {
+          if (!isIdleMessage(msg)) {
+            return new Response("Invalid idle message", { status: 400 });
+          }
+          server.publish(WORKSPACE_TOPIC, JSON.stringify(msg));
+          return new Response(null, { status: 204 });
+        })
+        .catch(() => new Response("Invalid JSON", { status: 400 }));
+    }
+
     return new Response("Not Found", { status: 404 });
   },
 
@@ -161,6 +184,9 @@ const _server = Bun.serve<WSData>({
           ws.data.portWatcher = portWatcher;
           log.info("PortWatcher initialized");
 
+          // Receive idle stop notices broadcast by the API
+          ws.subscribe(WORKSPACE_TOPIC);
+
           // Agent handler will be created on first agent message
           // (since we need to know which agent type)
         } else {
@@ -376,5 +402,6 @@ logger.info("workspace service started", {
     workspace: `ws://localhost:${PORT}/workspace`,
     agents: VALID_AGENTS.map((a) => `ws://localhost:${PORT}/agent/${a}`),
     health: `http://localhost:${PORT}/health`,
+    activity: `http://localhost:${PORT}/activity`,
   },
 });
@@ -0,0 +1,70 @@
+/** TCP socket states from include/net/tcp_states.h, as hex strings in /proc/net/tcp */
+export const TCP_ESTABLISHED = "01";
+export const TCP_LISTEN = "0A";
+
+export interface TcpSocket {
+  localAddress: string;
+  localPort: number;
+  remoteAddress: string;
+  remotePort: number;
+  state: string;
+}
+
+/**
+ * Parse /proc/net/tcp or /proc/net/tcp6
+ *
+ * Format:
+ *   sl  local_address rem_address   st tx_queue rx_queue ...
+ *    0: 0100007F:1F90 00000000:0000 0A 00000000:00000000 ...
+ *
+ * Addresses are hex IP:PORT, st is the socket state
+ */
+export async function readProcNetTcp(path: string): Promise<TcpSocket[]> {
+  const content = await Bun.file(path).text();
+  const lines = content.trim().split("\n");
+  const sockets: TcpSocket[] = [];
+
+  // Skip header line
+  for (let i = 1; i < lines.length; i++) {
+    const parts = lines[i].trim().split(/\s+/);
+    if (parts.length < 4) continue;
+
+    const local = parseAddress(parts[1]);
+    const remote = parseAddress(parts[2]);
+    if (!local || !remote) continue;
+
+    sockets.push({
+      localAddress: local.address,
+      localPort: local.port,
+      remoteAddress: remote.address,
+      remotePort: remote.port,
+      state: parts[3],
+    });
+  }
+
+  return sockets;
+}
+
+function parseAddress(value: string): { address: string; port: number } | null {
+  const colonIndex = value.lastIndexOf(":");
+  if (colonIndex === -1) return null;
+
+  const port = parseInt(value.substring(colonIndex + 1), 16);
+  if (isNaN(port)) return null;
+
+  return { address: value.substring(0, colonIndex), port };
+}
+
+/**
+ * Whether a hex address from /proc/net/tcp{,6} is loopback. IPv4 addresses are
+ * little-endian, so 127.x.x.x ends in 7F; IPv6 covers ::1 and ::ffff:127.x.x.x.
+ */
+export function isLoopbackAddress(address: string): boolean {
+  if (address.length === 8) {
+    return address.endsWith("7F");
+  }
+  return (
+    address === "00000000000000000000000001000000" ||
+    (address.startsWith("0000000000000000FFFF0000") && address.endsWith("7F"))
+  );
+}
@@ -0,0 +1,55 @@
+-- Migration: 007_project_operations.sql
+-- Purpose: Durable, resumable project lifecycle operations (start/stop)
+
+-- ============================================
+-- PROJECT OPERATIONS TABLE
+-- ============================================
+CREATE TABLE public.project_operations (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id uuid NOT NULL REFERENCES public.projects(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES public.profiles(id) ON DELETE CASCADE,
+
+    -- What the operation does and how far it got
+    type text NOT NULL
+        CHECK (type IN ('start', 'stop')),
+    status text DEFAULT 'pending' NOT NULL
+        CHECK (status IN ('pending', 'running', 'succeeded', 'failed')),
+    step text DEFAULT 'queued' NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    last_error text,
+
+    -- Claim bookkeeping: the replica currently executing the operation
+    -- refreshes heartbeat_at after every step. A running operation whose
+    -- heartbeat is stale is considered abandoned and can be re-claimed.
+    claimed_by text,
+    heartbeat_at timestamptz,
+
+    created_at timestamptz DEFAULT now() NOT NULL,
+    updated_at timestamptz DEFAULT now() NOT NULL,
+    completed_at timestamptz
+);
+
+-- Indexes
+CREATE INDEX project_operations_project_id_idx ON public.project_operations(project_id, created_at DESC);
+CREATE INDEX project_operations_unfinished_idx ON public.project_operations(status, heartbeat_at)
+    WHERE status IN ('pending', 'running');
+
+-- At most one unfinished operation per project
+CREATE UNIQUE INDEX project_operations_one_active_idx ON public.project_operations(project_id)
+    WHERE status IN ('pending', 'running');
+
+-- ============================================
+-- ROW LEVEL SECURITY
+-- ============================================
+ALTER TABLE public.project_operations ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own project operations"
+    ON public.project_operations FOR SELECT
+    USING (auth.uid() = user_id);
+
+-- ============================================
+-- TRIGGERS
+-- ============================================
+CREATE TRIGGER project_operations_updated_at
+    BEFORE UPDATE ON public.project_operations
+    FOR EACH ROW EXECUTE FUNCTION public.update_updated_at();
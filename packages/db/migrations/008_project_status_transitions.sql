@@ -0,0 +1,7 @@
+-- Migration: 008_project_status_transitions.sql
+-- Purpose: Add the 'deleting' status used while a project's resources are torn down
+
+ALTER TABLE public.projects DROP CONSTRAINT IF EXISTS projects_status_check;
+
+ALTER TABLE public.projects ADD CONSTRAINT projects_status_check
+    CHECK (status IN ('stopped', 'starting', 'running', 'stopping', 'error', 'deleting'));
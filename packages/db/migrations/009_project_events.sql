@@ -0,0 +1,32 @@
+-- Migration: 009_project_events.sql
+-- Purpose: Durable log of project lifecycle events, streamed to clients over SSE/WebSocket
+
+-- ============================================
+-- PROJECT EVENTS TABLE
+-- ============================================
+CREATE TABLE public.project_events (
+    -- Monotonic ID doubles as the stream cursor (SSE Last-Event-ID)
+    id bigserial PRIMARY KEY,
+    project_id uuid NOT NULL REFERENCES public.projects(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES public.profiles(id) ON DELETE CASCADE,
+
+    type text NOT NULL,          -- status, machine_assigned, volume_assigned, idle_stop
+    status text,                 -- project status at the time of the event
+    message text,                -- error message or human-readable notice
+    data jsonb DEFAULT '{}' NOT NULL,
+
+    created_at timestamptz DEFAULT now() NOT NULL
+);
+
+-- Indexes
+CREATE INDEX project_events_user_id_idx ON public.project_events(user_id, id);
+CREATE INDEX project_events_created_at_idx ON public.project_events(created_at);
+
+-- ============================================
+-- ROW LEVEL SECURITY
+-- ============================================
+ALTER TABLE public.project_events ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own project events"
+    ON public.project_events FOR SELECT
+    USING (auth.uid() = user_id);
@@ -0,0 +1,8 @@
+-- Migration: 010_project_forks.sql
+-- Purpose: Track which project a fork was created from
+
+ALTER TABLE public.projects
+    ADD COLUMN parent_project_id uuid REFERENCES public.projects(id) ON DELETE SET NULL;
+
+CREATE INDEX projects_parent_project_id_idx ON public.projects(parent_project_id)
+    WHERE parent_project_id IS NOT NULL;
@@ -0,0 +1,41 @@
+-- Migration: 011_project_snapshots.sql
+-- Purpose: Volume snapshots for checkpointing and restoring a project's workspace
+
+-- ============================================
+-- PROJECT SNAPSHOTS TABLE
+-- ============================================
+CREATE TABLE public.project_snapshots (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id uuid NOT NULL REFERENCES public.projects(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES public.profiles(id) ON DELETE CASCADE,
+
+    label text,
+    volume_id text NOT NULL,             -- volume the snapshot was taken from
+    provider_snapshot_id text NOT NULL,  -- Fly snapshot ID or local tarball ID
+    size_bytes bigint DEFAULT 0 NOT NULL,
+    created_by text DEFAULT 'user' NOT NULL
+        CHECK (created_by IN ('user', 'automatic')),
+
+    created_at timestamptz DEFAULT now() NOT NULL
+);
+
+-- Indexes
+CREATE INDEX project_snapshots_project_id_idx ON public.project_snapshots(project_id, created_at DESC);
+
+-- ============================================
+-- ROW LEVEL SECURITY
+-- ============================================
+ALTER TABLE public.project_snapshots ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own project snapshots"
+    ON public.project_snapshots FOR SELECT
+    USING (auth.uid() = user_id);
+
+-- ============================================
+-- PROJECT STATUS
+-- ============================================
+-- 'restoring' holds a stopped project while its volume is replaced from a snapshot
+ALTER TABLE public.projects DROP CONSTRAINT IF EXISTS projects_status_check;
+
+ALTER TABLE public.projects ADD CONSTRAINT projects_status_check
+    CHECK (status IN ('stopped', 'starting', 'running', 'stopping', 'error', 'deleting', 'restoring'));
@@ -0,0 +1,13 @@
+-- Migration: 012_project_hardware_updates.sql
+-- Purpose: Change hardware of existing projects and apply it on the next (re)start
+
+-- Set when hardware changes after the machine or volume exists; cleared once the
+-- new guest config and volume size have been applied to them
+ALTER TABLE public.projects
+    ADD COLUMN hardware_pending boolean DEFAULT false NOT NULL;
+
+-- Restart operations stop a running project and start it again with its current config
+ALTER TABLE public.project_operations DROP CONSTRAINT IF EXISTS project_operations_type_check;
+
+ALTER TABLE public.project_operations ADD CONSTRAINT project_operations_type_check
+    CHECK (type IN ('start', 'stop', 'restart'));
@@ -0,0 +1,73 @@
+-- Migration: 013_project_templates.sql
+-- Purpose: User-defined project templates and per-project template state
+
+-- ============================================
+-- PROJECT TEMPLATES TABLE
+-- ============================================
+-- Built-in templates live in the API; this table holds user-defined ones
+CREATE TABLE public.project_templates (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES public.profiles(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    description text,
+
+    base_image text,                       -- NULL uses the platform BASE_IMAGE
+    hardware_preset text,                  -- small, medium, large, performance
+    env_vars jsonb DEFAULT '{}' NOT NULL,
+    ports integer[] DEFAULT '{}' NOT NULL, -- ports the project serves previews on
+
+    -- Applied inside the workspace on first boot
+    files jsonb DEFAULT '{}' NOT NULL,     -- relative path -> file content
+    git_url text,
+    post_create_script text,
+
+    created_at timestamptz DEFAULT now() NOT NULL,
+    updated_at timestamptz DEFAULT now() NOT NULL,
+
+    CONSTRAINT project_templates_name_length CHECK (char_length(name) >= 1 AND char_length(name) <= 100)
+);
+
+-- Indexes
+CREATE INDEX project_templates_user_id_idx ON public.project_templates(user_id);
+
+-- ============================================
+-- ROW LEVEL SECURITY
+-- ============================================
+ALTER TABLE public.project_templates ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own templates"
+    ON public.project_templates FOR SELECT
+    USING (auth.uid() = user_id);
+
+CREATE POLICY "Users can create own templates"
+    ON public.project_templates FOR INSERT
+    WITH CHECK (auth.uid() = user_id);
+
+CREATE POLICY "Users can update own templates"
+    ON public.project_templates FOR UPDATE
+    USING (auth.uid() = user_id)
+    WITH CHECK (auth.uid() = user_id);
+
+CREATE POLICY "Users can delete own templates"
+    ON public.project_templates FOR DELETE
+    USING (auth.uid() = user_id);
+
+-- ============================================
+-- TRIGGERS
+-- ============================================
+CREATE TRIGGER project_templates_updated_at
+    BEFORE UPDATE ON public.project_templates
+    FOR EACH ROW EXECUTE FUNCTION public.update_updated_at();
+
+-- ============================================
+-- PROJECTS
+-- ============================================
+ALTER TABLE public.projects
+    -- Built-in template name or project_templates.id; not a foreign key so
+    -- deleting a template leaves projects created from it intact
+    ADD COLUMN template_id text,
+    -- Image required by the template; NULL follows the platform BASE_IMAGE
+    ADD COLUMN template_image text,
+    ADD COLUMN exposed_ports integer[] DEFAULT '{}' NOT NULL,
+    -- Files, git URL and post-create script still to be applied on first boot
+    ADD COLUMN template_setup jsonb;
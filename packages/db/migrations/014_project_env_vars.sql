@@ -0,0 +1,17 @@
+-- Migration: 014_project_env_vars.sql
+-- Purpose: Per-project environment variables and secrets, encrypted at rest
+
+-- Encrypted JSON of the project's variables (AES-256-GCM, keyed to the owner like
+-- profiles.api_keys_encrypted). Each variable is flagged plain or secret.
+ALTER TABLE public.projects
+    ADD COLUMN env_vars_encrypted text;
+
+-- Set when variables change after the machine exists; cleared once the machine's
+-- config has been updated with them
+ALTER TABLE public.projects
+    ADD COLUMN env_pending boolean DEFAULT false NOT NULL;
+
+-- Replaced by env_vars_encrypted. The API can't encrypt inside a migration, so
+-- template defaults stored here are dropped; they remain in machines created from them.
+ALTER TABLE public.projects
+    DROP COLUMN env_vars;
@@ -0,0 +1,86 @@
+-- Migration: 015_image_rollouts.sql
+-- Purpose: Track the image each project's machine runs and roll new base images out
+
+-- ============================================
+-- PROJECT IMAGES
+-- ============================================
+-- base_image is the image the project should run; machine_image is the one its
+-- current machine was created from. When they differ the machine is recreated
+-- (keeping the volume) on the next start.
+ALTER TABLE public.projects
+    ADD COLUMN machine_image text;
+
+UPDATE public.projects
+    SET machine_image = COALESCE(template_image, base_image)
+    WHERE fly_machine_id IS NOT NULL;
+
+-- Upgrade operations restart a running project onto its new image
+ALTER TABLE public.project_operations DROP CONSTRAINT IF EXISTS project_operations_type_check;
+
+ALTER TABLE public.project_operations ADD CONSTRAINT project_operations_type_check
+    CHECK (type IN ('start', 'stop', 'restart', 'upgrade'));
+
+-- ============================================
+-- IMAGE ROLLOUTS TABLE
+-- ============================================
+-- Operator-driven rollouts of a new base image across existing projects.
+-- Only accessed by the API with the service role.
+CREATE TABLE public.image_rollouts (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    image text NOT NULL,
+    canary_percent integer NOT NULL CHECK (canary_percent BETWEEN 0 AND 100),
+    batch_size integer NOT NULL CHECK (batch_size > 0),
+    status text DEFAULT 'canary' NOT NULL
+        CHECK (status IN ('canary', 'paused', 'rolling', 'completed', 'rolling_back', 'rolled_back')),
+    pause_reason text,
+    created_by uuid REFERENCES public.profiles(id) ON DELETE SET NULL,
+
+    -- Lease held by the API replica currently advancing the rollout
+    leased_by text,
+    lease_expires_at timestamptz,
+
+    created_at timestamptz DEFAULT now() NOT NULL,
+    updated_at timestamptz DEFAULT now() NOT NULL,
+    completed_at timestamptz
+);
+
+-- One unfinished rollout at a time
+CREATE UNIQUE INDEX image_rollouts_one_active_idx ON public.image_rollouts ((true))
+    WHERE status IN ('canary', 'paused', 'rolling', 'rolling_back');
+
+CREATE TRIGGER update_image_rollouts_updated_at
+    BEFORE UPDATE ON public.image_rollouts
+    FOR EACH ROW EXECUTE FUNCTION public.update_updated_at();
+
+-- ============================================
+-- IMAGE ROLLOUT PROJECTS TABLE
+-- ============================================
+-- Per-project progress of a rollout
+CREATE TABLE public.image_rollout_projects (
+    rollout_id uuid NOT NULL REFERENCES public.image_rollouts(id) ON DELETE CASCADE,
+    project_id uuid NOT NULL REFERENCES public.projects(id) ON DELETE CASCADE,
+
+    previous_image text NOT NULL,    -- restored on rollback
+    canary boolean DEFAULT false NOT NULL,
+    status text DEFAULT 'pending' NOT NULL
+        CHECK (status IN ('pending', 'upgrading', 'upgraded', 'failed', 'reverting', 'reverted', 'revert_failed', 'skipped')),
+    operation_id uuid,               -- upgrade operation restarting a running project
+    error text,
+
+    updated_at timestamptz DEFAULT now() NOT NULL,
+
+    PRIMARY KEY (rollout_id, project_id)
+);
+
+CREATE INDEX image_rollout_projects_project_id_idx ON public.image_rollout_projects(project_id);
+
+CREATE TRIGGER update_image_rollout_projects_updated_at
+    BEFORE UPDATE ON public.image_rollout_projects
+    FOR EACH ROW EXECUTE FUNCTION public.update_updated_at();
+
+-- ============================================
+-- ROW LEVEL SECURITY
+-- ============================================
+-- No policies: rollouts are admin-only and never read with a user's token
+ALTER TABLE public.image_rollouts ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.image_rollout_projects ENABLE ROW LEVEL SECURITY;
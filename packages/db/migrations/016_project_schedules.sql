@@ -0,0 +1,45 @@
+-- Migration: 016_project_schedules.sql
+-- Purpose: Cron schedules that start and stop projects at set times
+
+-- ============================================
+-- PROJECT SCHEDULES TABLE
+-- ============================================
+CREATE TABLE public.project_schedules (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id uuid NOT NULL REFERENCES public.projects(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES public.profiles(id) ON DELETE CASCADE,
+
+    action text NOT NULL CHECK (action IN ('start', 'stop')),
+    cron text NOT NULL,                      -- five-field cron expression
+    timezone text DEFAULT 'UTC' NOT NULL,    -- IANA timezone the expression is read in
+    enabled boolean DEFAULT true NOT NULL,
+
+    -- Computed by the API from cron and timezone; NULL when disabled or never firing
+    next_run_at timestamptz,
+
+    last_run_at timestamptz,
+    last_result text CHECK (last_result IN ('triggered', 'skipped', 'missed', 'failed')),
+    last_error text,
+    last_operation_id uuid,
+
+    created_at timestamptz DEFAULT now() NOT NULL,
+    updated_at timestamptz DEFAULT now() NOT NULL
+);
+
+-- Indexes
+CREATE INDEX project_schedules_project_id_idx ON public.project_schedules(project_id, created_at);
+CREATE INDEX project_schedules_next_run_at_idx ON public.project_schedules(next_run_at)
+    WHERE enabled AND next_run_at IS NOT NULL;
+
+CREATE TRIGGER update_project_schedules_updated_at
+    BEFORE UPDATE ON public.project_schedules
+    FOR EACH ROW EXECUTE FUNCTION public.update_updated_at();
+
+-- ============================================
+-- ROW LEVEL SECURITY
+-- ============================================
+ALTER TABLE public.project_schedules ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own project schedules"
+    ON public.project_schedules FOR SELECT
+    USING (auth.uid() = user_id);
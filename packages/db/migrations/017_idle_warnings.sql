@@ -0,0 +1,12 @@
+-- Migration: 017_idle_warnings.sql
+-- Purpose: Warn connected clients before an idle stop and let them snooze it
+
+-- ============================================
+-- IDLE STOP STATE
+-- ============================================
+-- idle_warned_at is when the idle checker last warned that the project would be
+-- stopped; a warning older than the latest access is stale. idle_snoozed_until
+-- holds off idle stops entirely until it passes, and idle time counts from then.
+ALTER TABLE public.projects
+    ADD COLUMN idle_warned_at timestamptz,
+    ADD COLUMN idle_snoozed_until timestamptz;
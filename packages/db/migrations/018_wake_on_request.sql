@@ -0,0 +1,14 @@
+-- Migration: 018_wake_on_request.sql
+-- Purpose: Let preview requests start a stopped project
+
+-- ============================================
+-- WAKE ON REQUEST
+-- ============================================
+-- When set, the preview gateway starts a stopped project on the first request to
+-- one of its preview URLs instead of answering 503. Only projects with an idle
+-- timeout may opt in, so a woken project is stopped again once traffic ends.
+ALTER TABLE public.projects
+    ADD COLUMN wake_on_request boolean DEFAULT false NOT NULL;
+
+-- The gateway rate-limits wakes by counting recent wake events per project
+CREATE INDEX project_events_project_type_idx ON public.project_events(project_id, type, created_at);
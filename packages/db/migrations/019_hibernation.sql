@@ -0,0 +1,30 @@
+-- Migration: 019_hibernation.sql
+-- Purpose: Archive long-inactive projects to object storage and free their machine and volume
+
+-- ============================================
+-- PROJECT ARCHIVE
+-- ============================================
+-- archive_key names the object holding a hibernated project's volume contents.
+-- It stays set until a start has restored the archive onto a fresh volume.
+ALTER TABLE public.projects
+    ADD COLUMN archive_key text,
+    ADD COLUMN archive_size_bytes bigint,
+    ADD COLUMN hibernated_at timestamptz;
+
+-- ============================================
+-- PROJECT STATUS
+-- ============================================
+-- 'hibernating' holds a stopped project while its volume is archived and deleted;
+-- 'hibernated' projects have no machine or volume until they are started again
+ALTER TABLE public.projects DROP CONSTRAINT IF EXISTS projects_status_check;
+
+ALTER TABLE public.projects ADD CONSTRAINT projects_status_check
+    CHECK (status IN ('stopped', 'starting', 'running', 'stopping', 'error', 'deleting', 'restoring', 'hibernating', 'hibernated'));
+
+-- ============================================
+-- OPERATION TYPES
+-- ============================================
+ALTER TABLE public.project_operations DROP CONSTRAINT IF EXISTS project_operations_type_check;
+
+ALTER TABLE public.project_operations ADD CONSTRAINT project_operations_type_check
+    CHECK (type IN ('start', 'stop', 'restart', 'upgrade', 'hibernate'));
@@ -0,0 +1,21 @@
+-- Migration: 020_trash.sql
+-- Purpose: Soft-delete projects into a trash they can be restored from until purged
+
+-- ============================================
+-- PROJECT TRASH
+-- ============================================
+-- deleted_at is set while a project is trashed. Its machine is stopped but kept,
+-- along with its volume, until the retention period ends and the purge job
+-- deletes them for real.
+ALTER TABLE public.projects
+    ADD COLUMN deleted_at timestamptz;
+
+CREATE INDEX projects_trashed_idx ON public.projects(deleted_at) WHERE status = 'trashed';
+
+-- ============================================
+-- PROJECT STATUS
+-- ============================================
+ALTER TABLE public.projects DROP CONSTRAINT IF EXISTS projects_status_check;
+
+ALTER TABLE public.projects ADD CONSTRAINT projects_status_check
+    CHECK (status IN ('stopped', 'starting', 'running', 'stopping', 'error', 'deleting', 'restoring', 'hibernating', 'hibernated', 'trashed'));
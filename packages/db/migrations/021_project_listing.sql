@@ -0,0 +1,19 @@
+-- Migration: 021_project_listing.sql
+-- Purpose: Project tags, and indexes for paging through a user's projects by each sort key
+
+-- ============================================
+-- PROJECT TAGS
+-- ============================================
+ALTER TABLE public.projects
+    ADD COLUMN tags text[] DEFAULT '{}' NOT NULL;
+
+CREATE INDEX projects_tags_idx ON public.projects USING gin (tags);
+
+-- ============================================
+-- LISTING INDEXES
+-- ============================================
+-- Keyset pagination orders by the sort key, then id
+CREATE INDEX projects_user_updated_idx ON public.projects(user_id, updated_at, id);
+CREATE INDEX projects_user_created_idx ON public.projects(user_id, created_at, id);
+CREATE INDEX projects_user_accessed_idx ON public.projects(user_id, (COALESCE(last_accessed_at, created_at)), id);
+CREATE INDEX projects_user_name_idx ON public.projects(user_id, name, id);
@@ -0,0 +1,11 @@
+-- Migration: 022_project_labels.sql
+-- Purpose: Key/value labels for grouping projects, also copied onto their machines
+
+-- ============================================
+-- PROJECT LABELS
+-- ============================================
+-- A flat object of string values, e.g. {"client": "acme", "team": "ml"}
+ALTER TABLE public.projects
+    ADD COLUMN labels jsonb DEFAULT '{}'::jsonb NOT NULL;
+
+CREATE INDEX projects_labels_idx ON public.projects USING gin (labels);
@@ -0,0 +1,32 @@
+-- Migration: 023_idempotency_keys.sql
+-- Purpose: Remember responses to mutating requests so retries with the same
+-- Idempotency-Key replay them instead of running again
+
+-- ============================================
+-- IDEMPOTENCY KEYS TABLE
+-- ============================================
+-- A row is claimed before the request runs and completed with its response.
+-- Rows are only read and written by the API, so there are no RLS policies.
+CREATE TABLE public.idempotency_keys (
+    user_id uuid NOT NULL REFERENCES public.profiles(id) ON DELETE CASCADE,
+    key text NOT NULL,
+
+    -- SHA-256 of the method, path and body, to catch a key reused for another request
+    request_hash text NOT NULL,
+
+    -- NULL while the first request is still running
+    response_status integer,
+    response_content_type text,
+    response_body bytea,
+
+    -- A claim older than this was abandoned by a crashed replica and can be retaken
+    locked_at timestamptz DEFAULT now() NOT NULL,
+    created_at timestamptz DEFAULT now() NOT NULL,
+    expires_at timestamptz NOT NULL,
+
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON public.idempotency_keys(expires_at);
+
+ALTER TABLE public.idempotency_keys ENABLE ROW LEVEL SECURITY;
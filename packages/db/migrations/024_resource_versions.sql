@@ -0,0 +1,48 @@
+-- Migration: 024_resource_versions.sql
+-- Purpose: Version counters for optimistic concurrency, exposed to clients as ETags
+
+-- ============================================
+-- VERSION COUNTERS
+-- ============================================
+-- Bumped on every update, so a client's If-Match can detect that someone else
+-- changed the row since it was read
+ALTER TABLE public.projects
+    ADD COLUMN version bigint DEFAULT 1 NOT NULL;
+
+ALTER TABLE public.user_settings
+    ADD COLUMN version bigint DEFAULT 1 NOT NULL;
+
+CREATE OR REPLACE FUNCTION public.bump_version()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER projects_bump_version
+    BEFORE UPDATE ON public.projects
+    FOR EACH ROW EXECUTE FUNCTION public.bump_version();
+
+CREATE TRIGGER user_settings_bump_version
+    BEFORE UPDATE ON public.user_settings
+    FOR EACH ROW EXECUTE FUNCTION public.bump_version();
+
+-- A project's schedules are part of its representation, so changing them
+-- touches the project and bumps its version too
+CREATE OR REPLACE FUNCTION public.touch_schedule_project()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    UPDATE public.projects SET updated_at = now()
+    WHERE id = COALESCE(NEW.project_id, OLD.project_id);
+    RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER project_schedules_touch_project
+    AFTER INSERT OR UPDATE OR DELETE ON public.project_schedules
+    FOR EACH ROW EXECUTE FUNCTION public.touch_schedule_project();
@@ -0,0 +1,23 @@
+-- Migration: 025_project_regions.sql
+-- Purpose: Per-project region placement, and moving stopped projects between regions
+
+-- ============================================
+-- PROJECT REGION
+-- ============================================
+-- The region a project's volume and machine live in. New projects always get
+-- one. GPU projects were always placed in ord; other existing projects stay
+-- NULL, meaning the deployment's default region (FLY_REGION) they were
+-- created in.
+ALTER TABLE public.projects
+    ADD COLUMN region text;
+
+UPDATE public.projects SET region = 'ord' WHERE gpu_kind IS NOT NULL;
+
+-- ============================================
+-- PROJECT STATUS
+-- ============================================
+-- 'moving' holds a stopped project while its volume is copied to another region
+ALTER TABLE public.projects DROP CONSTRAINT IF EXISTS projects_status_check;
+
+ALTER TABLE public.projects ADD CONSTRAINT projects_status_check
+    CHECK (status IN ('stopped', 'starting', 'running', 'stopping', 'error', 'deleting', 'restoring', 'hibernating', 'hibernated', 'trashed', 'moving'));
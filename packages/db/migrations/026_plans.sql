@@ -0,0 +1,68 @@
+-- Migration: 026_plans.sql
+-- Purpose: Plans that cap what each user can create and run
+
+-- ============================================
+-- PLANS TABLE
+-- ============================================
+-- A NULL limit means unlimited. Users without a row in user_plans are on the
+-- default plan; with no default plan they have no limits at all.
+CREATE TABLE public.plans (
+    id text PRIMARY KEY,
+    name text NOT NULL,
+
+    -- Persistent resources
+    max_projects integer CHECK (max_projects IS NULL OR max_projects >= 0),
+    max_volume_gb integer CHECK (max_volume_gb IS NULL OR max_volume_gb >= 0),
+    allow_gpu boolean DEFAULT false NOT NULL,
+
+    -- Concurrently running machines
+    max_running_machines integer CHECK (max_running_machines IS NULL OR max_running_machines >= 0),
+    max_vcpus integer CHECK (max_vcpus IS NULL OR max_vcpus >= 0),
+    max_memory_mb integer CHECK (max_memory_mb IS NULL OR max_memory_mb >= 0),
+
+    is_default boolean DEFAULT false NOT NULL,
+    created_at timestamptz DEFAULT now() NOT NULL,
+    updated_at timestamptz DEFAULT now() NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_plans_default ON public.plans (is_default) WHERE is_default;
+
+INSERT INTO public.plans (id, name, max_projects, max_volume_gb, allow_gpu, max_running_machines, max_vcpus, max_memory_mb, is_default) VALUES
+    ('free', 'Free', 3, 20, false, 1, 2, 4096, true),
+    ('pro', 'Pro', 25, 250, true, 5, 32, 65536, false),
+    ('unlimited', 'Unlimited', NULL, NULL, true, NULL, NULL, NULL, false);
+
+-- ============================================
+-- USER PLANS TABLE
+-- ============================================
+CREATE TABLE public.user_plans (
+    user_id uuid PRIMARY KEY REFERENCES public.profiles(id) ON DELETE CASCADE,
+    plan_id text NOT NULL REFERENCES public.plans(id),
+    created_at timestamptz DEFAULT now() NOT NULL,
+    updated_at timestamptz DEFAULT now() NOT NULL
+);
+
+CREATE INDEX idx_user_plans_plan ON public.user_plans (plan_id);
+
+CREATE TRIGGER update_plans_updated_at
+    BEFORE UPDATE ON public.plans
+    FOR EACH ROW EXECUTE FUNCTION public.update_updated_at();
+
+CREATE TRIGGER update_user_plans_updated_at
+    BEFORE UPDATE ON public.user_plans
+    FOR EACH ROW EXECUTE FUNCTION public.update_updated_at();
+
+-- ============================================
+-- ROW LEVEL SECURITY
+-- ============================================
+-- Plans are only assigned by admins through the API
+ALTER TABLE public.plans ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.user_plans ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Anyone can view plans"
+    ON public.plans FOR SELECT
+    USING (true);
+
+CREATE POLICY "Users can view own plan"
+    ON public.user_plans FOR SELECT
+    USING (auth.uid() = user_id);
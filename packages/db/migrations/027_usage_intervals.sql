@@ -0,0 +1,45 @@
+-- Migration: 027_usage_intervals.sql
+-- Purpose: Ledger of the intervals each project's machine ran, for usage reports and cost
+
+-- ============================================
+-- USAGE INTERVALS TABLE
+-- ============================================
+-- One row per run of a project's machine, with the machine's size at the time.
+-- stopped_at is NULL while the machine is running. Rows outlive their project
+-- so a user's history stays complete.
+CREATE TABLE public.usage_intervals (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    project_id uuid REFERENCES public.projects(id) ON DELETE SET NULL,
+    project_name text NOT NULL,
+    user_id uuid NOT NULL REFERENCES public.profiles(id) ON DELETE CASCADE,
+    machine_id text,
+    region text,
+
+    -- Machine size; GPU machines record the fixed size they run at
+    cpu_kind text NOT NULL,
+    cpus integer NOT NULL CHECK (cpus > 0),
+    memory_mb integer NOT NULL CHECK (memory_mb > 0),
+    gpu_kind text,
+
+    started_at timestamptz NOT NULL,
+    stopped_at timestamptz,
+
+    CHECK (stopped_at IS NULL OR stopped_at >= started_at)
+);
+
+-- A project has at most one machine running at a time
+CREATE UNIQUE INDEX idx_usage_intervals_open ON public.usage_intervals (project_id)
+    WHERE stopped_at IS NULL;
+
+CREATE INDEX idx_usage_intervals_user ON public.usage_intervals (user_id, started_at);
+CREATE INDEX idx_usage_intervals_project ON public.usage_intervals (project_id, started_at);
+
+-- ============================================
+-- ROW LEVEL SECURITY
+-- ============================================
+-- Rows are only written by the API
+ALTER TABLE public.usage_intervals ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own usage"
+    ON public.usage_intervals FOR SELECT
+    USING (auth.uid() = user_id);
@@ -0,0 +1,19 @@
+-- Migration: 028_llm_usage_sessions.sql
+-- Purpose: Record agent token usage per session from the API's agent bridge
+
+-- ============================================
+-- LLM USAGE COLUMNS
+-- ============================================
+-- session_id is the agent session the usage belongs to. project_name keeps
+-- reports readable after a project is deleted and project_id is cleared.
+ALTER TABLE public.llm_usage
+    ADD COLUMN session_id text,
+    ADD COLUMN project_name text;
+
+-- Agents don't always say which model they ran
+ALTER TABLE public.llm_usage
+    ALTER COLUMN model SET DEFAULT 'unknown';
+
+CREATE INDEX llm_usage_project_created_idx ON public.llm_usage (project_id, created_at DESC);
+CREATE INDEX llm_usage_session_idx ON public.llm_usage (session_id)
+    WHERE session_id IS NOT NULL;
@@ -0,0 +1,47 @@
+-- Migration: 029_agent_budgets.sql
+-- Purpose: Monthly caps on what agents may spend, per user and per project
+
+-- ============================================
+-- AGENT BUDGETS TABLE
+-- ============================================
+-- A budget without a project covers everything the user's agents spend; one
+-- with a project covers that project. Spend counts from the budget's reset day
+-- each month (UTC), or from reset_at if the budget was reset since then.
+CREATE TABLE public.agent_budgets (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    user_id uuid NOT NULL REFERENCES public.profiles(id) ON DELETE CASCADE,
+    project_id uuid REFERENCES public.projects(id) ON DELETE CASCADE,
+
+    monthly_limit_usd numeric(12, 2) NOT NULL CHECK (monthly_limit_usd > 0),
+    -- Percentages of the limit at which the client is warned
+    warning_thresholds integer[] NOT NULL DEFAULT '{50,80}',
+    reset_day integer NOT NULL DEFAULT 1 CHECK (reset_day BETWEEN 1 AND 28),
+    reset_at timestamptz,
+
+    created_at timestamptz DEFAULT now() NOT NULL,
+    updated_at timestamptz DEFAULT now() NOT NULL
+);
+
+-- One budget per user, and one per project
+CREATE UNIQUE INDEX idx_agent_budgets_user ON public.agent_budgets (user_id)
+    WHERE project_id IS NULL;
+CREATE UNIQUE INDEX idx_agent_budgets_project ON public.agent_budgets (project_id)
+    WHERE project_id IS NOT NULL;
+
+CREATE TRIGGER update_agent_budgets_updated_at
+    BEFORE UPDATE ON public.agent_budgets
+    FOR EACH ROW EXECUTE FUNCTION public.update_updated_at();
+
+-- Budgets add up agent spend since a point in time
+CREATE INDEX llm_usage_project_completed_idx ON public.llm_usage (project_id, created_at)
+    WHERE status = 'completed';
+
+-- ============================================
+-- ROW LEVEL SECURITY
+-- ============================================
+-- Budgets are only written by the API
+ALTER TABLE public.agent_budgets ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own agent budgets"
+    ON public.agent_budgets FOR SELECT
+    USING (auth.uid() = user_id);
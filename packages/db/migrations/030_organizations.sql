@@ -0,0 +1,131 @@
+-- Migration: 030_organizations.sql
+-- Purpose: Organizations whose members share projects, with roles and invitations
+
+-- ============================================
+-- ORGANIZATIONS TABLE
+-- ============================================
+-- Default settings are optional; any that are set apply over each member's own
+-- user settings for projects in the organization. Hardware defaults are set or
+-- cleared together.
+CREATE TABLE public.organizations (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    name text NOT NULL CHECK (char_length(name) BETWEEN 1 AND 100),
+    created_by uuid REFERENCES public.profiles(id) ON DELETE SET NULL,
+
+    default_cpu_kind text CHECK (default_cpu_kind IN ('shared', 'performance')),
+    default_cpus integer,
+    default_memory_mb integer,
+    default_volume_size_gb integer,
+    default_gpu_kind text,
+    default_idle_timeout_minutes integer,
+
+    created_at timestamptz DEFAULT now() NOT NULL,
+    updated_at timestamptz DEFAULT now() NOT NULL,
+
+    CONSTRAINT organizations_default_hardware CHECK (
+        (default_cpu_kind IS NULL) = (default_cpus IS NULL)
+        AND (default_cpu_kind IS NULL) = (default_memory_mb IS NULL)
+        AND (default_cpu_kind IS NULL) = (default_volume_size_gb IS NULL)
+        AND (default_cpu_kind IS NOT NULL OR default_gpu_kind IS NULL)
+    )
+);
+
+CREATE TRIGGER update_organizations_updated_at
+    BEFORE UPDATE ON public.organizations
+    FOR EACH ROW EXECUTE FUNCTION public.update_updated_at();
+
+-- ============================================
+-- ORGANIZATION MEMBERS TABLE
+-- ============================================
+-- Roles, from most to least access:
+--   owner  - everything, including deleting the organization and managing owners
+--   admin  - manages members, invitations, settings and every project
+--   member - uses and operates projects: agents, terminals, start and stop
+--   viewer - reads projects and their usage
+CREATE TABLE public.organization_members (
+    organization_id uuid NOT NULL REFERENCES public.organizations(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES public.profiles(id) ON DELETE CASCADE,
+    role text NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+
+    created_at timestamptz DEFAULT now() NOT NULL,
+    updated_at timestamptz DEFAULT now() NOT NULL,
+
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX organization_members_user_id_idx ON public.organization_members(user_id);
+
+CREATE TRIGGER update_organization_members_updated_at
+    BEFORE UPDATE ON public.organization_members
+    FOR EACH ROW EXECUTE FUNCTION public.update_updated_at();
+
+-- ============================================
+-- ORGANIZATION INVITATIONS TABLE
+-- ============================================
+-- An invitation is for an email address; the user whose profile has that
+-- address accepts or declines it. Accepted invitations are deleted.
+CREATE TABLE public.organization_invitations (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    organization_id uuid NOT NULL REFERENCES public.organizations(id) ON DELETE CASCADE,
+    email text NOT NULL,
+    role text NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+    invited_by uuid REFERENCES public.profiles(id) ON DELETE SET NULL,
+    expires_at timestamptz NOT NULL,
+
+    created_at timestamptz DEFAULT now() NOT NULL
+);
+
+-- One open invitation per address per organization
+CREATE UNIQUE INDEX organization_invitations_email_idx
+    ON public.organization_invitations (organization_id, lower(email));
+CREATE INDEX organization_invitations_lower_email_idx
+    ON public.organization_invitations (lower(email));
+
+-- ============================================
+-- ORGANIZATION PROJECTS
+-- ============================================
+-- A project in an organization belongs to it rather than to the user who
+-- created it; user_id stays as the creator. Organizations with projects can't
+-- be deleted.
+ALTER TABLE public.projects
+    ADD COLUMN organization_id uuid REFERENCES public.organizations(id) ON DELETE RESTRICT;
+
+CREATE INDEX projects_organization_id_idx ON public.projects(organization_id)
+    WHERE organization_id IS NOT NULL;
+
+-- Events are stamped with the project's organization so every member receives them
+ALTER TABLE public.project_events
+    ADD COLUMN organization_id uuid REFERENCES public.organizations(id) ON DELETE CASCADE;
+
+CREATE INDEX project_events_organization_id_idx ON public.project_events(organization_id, id)
+    WHERE organization_id IS NOT NULL;
+
+-- ============================================
+-- ROW LEVEL SECURITY
+-- ============================================
+-- Organizations, members and invitations are only written by the API
+ALTER TABLE public.organizations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.organization_members ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.organization_invitations ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Members can view their organizations"
+    ON public.organizations FOR SELECT
+    USING (id IN (SELECT organization_id FROM public.organization_members WHERE user_id = auth.uid()));
+
+-- Policies on organization_members can't query it again, so users see their
+-- own memberships; the API lists the rest
+CREATE POLICY "Users can view own memberships"
+    ON public.organization_members FOR SELECT
+    USING (auth.uid() = user_id);
+
+CREATE POLICY "Users can view invitations to their email"
+    ON public.organization_invitations FOR SELECT
+    USING (lower(email) = (SELECT lower(email) FROM public.profiles WHERE id = auth.uid()));
+
+CREATE POLICY "Members can view organization projects"
+    ON public.projects FOR SELECT
+    USING (organization_id IN (SELECT organization_id FROM public.organization_members WHERE user_id = auth.uid()));
+
+CREATE POLICY "Members can view organization project events"
+    ON public.project_events FOR SELECT
+    USING (organization_id IN (SELECT organization_id FROM public.organization_members WHERE user_id = auth.uid()));
@@ -0,0 +1,18 @@
+-- Migration: 031_move_operations.sql
+-- Purpose: Run region moves as durable operations
+
+-- ============================================
+-- OPERATION PARAMETERS
+-- ============================================
+-- What an operation needs beyond the project's own row, such as a move's target
+-- region, and what it has created so far so a resumed attempt can pick it up
+ALTER TABLE public.project_operations
+    ADD COLUMN params jsonb DEFAULT '{}'::jsonb NOT NULL;
+
+-- ============================================
+-- OPERATION TYPES
+-- ============================================
+ALTER TABLE public.project_operations DROP CONSTRAINT IF EXISTS project_operations_type_check;
+
+ALTER TABLE public.project_operations ADD CONSTRAINT project_operations_type_check
+    CHECK (type IN ('start', 'stop', 'restart', 'upgrade', 'hibernate', 'move'));
@@ -0,0 +1,10 @@
+-- Migration: 032_restore_operations.sql
+-- Purpose: Run snapshot restores as durable operations
+
+-- ============================================
+-- OPERATION TYPES
+-- ============================================
+ALTER TABLE public.project_operations DROP CONSTRAINT IF EXISTS project_operations_type_check;
+
+ALTER TABLE public.project_operations ADD CONSTRAINT project_operations_type_check
+    CHECK (type IN ('start', 'stop', 'restart', 'upgrade', 'hibernate', 'move', 'restore'));
@@ -0,0 +1,11 @@
+-- Migration: 033_snapshot_expiry.sql
+-- Purpose: Track when the compute backend discards a snapshot
+
+-- ============================================
+-- SNAPSHOT EXPIRY
+-- ============================================
+-- NULL for backends that keep snapshots until they are deleted
+ALTER TABLE public.project_snapshots ADD COLUMN expires_at timestamptz;
+
+CREATE INDEX project_snapshots_expires_at_idx ON public.project_snapshots(expires_at)
+    WHERE expires_at IS NOT NULL;
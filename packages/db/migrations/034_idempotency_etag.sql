@@ -0,0 +1,8 @@
+-- Migration: 034_idempotency_etag.sql
+-- Purpose: Replay the ETag of stored idempotent responses, so a retried
+-- conditional update hands the client the version its change produced
+
+-- ============================================
+-- IDEMPOTENCY KEYS
+-- ============================================
+ALTER TABLE public.idempotency_keys ADD COLUMN response_etag text;
@@ -0,0 +1,31 @@
+-- Migration: 035_organization_plans.sql
+-- Purpose: Give organizations their own plan. An organization's projects count
+-- against its plan whichever member creates or starts them; personal projects
+-- keep counting against their owner's plan.
+
+-- ============================================
+-- ORGANIZATION PLANS TABLE
+-- ============================================
+-- Organizations without a row are on the default plan, like users
+CREATE TABLE public.organization_plans (
+    organization_id uuid PRIMARY KEY REFERENCES public.organizations(id) ON DELETE CASCADE,
+    plan_id text NOT NULL REFERENCES public.plans(id),
+    created_at timestamptz DEFAULT now() NOT NULL,
+    updated_at timestamptz DEFAULT now() NOT NULL
+);
+
+CREATE INDEX idx_organization_plans_plan ON public.organization_plans (plan_id);
+
+CREATE TRIGGER update_organization_plans_updated_at
+    BEFORE UPDATE ON public.organization_plans
+    FOR EACH ROW EXECUTE FUNCTION public.update_updated_at();
+
+-- ============================================
+-- ROW LEVEL SECURITY
+-- ============================================
+-- Plans are only assigned by admins through the API
+ALTER TABLE public.organization_plans ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Members can view their organization's plan"
+    ON public.organization_plans FOR SELECT
+    USING (organization_id IN (SELECT organization_id FROM public.organization_members WHERE user_id = auth.uid()));
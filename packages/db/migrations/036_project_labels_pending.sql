@@ -0,0 +1,7 @@
+-- Migration: 036_project_labels_pending.sql
+-- Purpose: Track labels that haven't reached the project's machine metadata yet
+
+-- Set when labels change after the machine exists; cleared once the machine's
+-- config has been updated with them
+ALTER TABLE public.projects
+    ADD COLUMN labels_pending boolean DEFAULT false NOT NULL;
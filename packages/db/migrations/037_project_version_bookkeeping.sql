@@ -0,0 +1,49 @@
+-- Migration: 037_project_version_bookkeeping.sql
+-- Purpose: Bump a project's version only when something a client edits changes
+
+-- ============================================
+-- VERSION COUNTERS
+-- ============================================
+-- Status transitions, access times, machine and archive references and the
+-- pending flags change all the time without anyone editing the project.
+-- Bumping the version for them failed a client's If-Match on its next edit.
+-- An update that sets version itself keeps what it set.
+CREATE OR REPLACE FUNCTION public.bump_project_version()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    bookkeeping text[] := ARRAY[
+        'status', 'error_message', 'last_accessed_at', 'updated_at', 'version',
+        'fly_machine_id', 'fly_volume_id', 'machine_image',
+        'idle_warned_at', 'idle_snoozed_until',
+        'archive_key', 'archive_size_bytes', 'hibernated_at', 'deleted_at',
+        'hardware_pending', 'env_pending', 'labels_pending'
+    ];
+BEGIN
+    IF NEW.version = OLD.version
+       AND to_jsonb(NEW) - bookkeeping IS DISTINCT FROM to_jsonb(OLD) - bookkeeping THEN
+        NEW.version = OLD.version + 1;
+    END IF;
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER projects_bump_version ON public.projects;
+
+CREATE TRIGGER projects_bump_version
+    BEFORE UPDATE ON public.projects
+    FOR EACH ROW EXECUTE FUNCTION public.bump_project_version();
+
+-- Schedules only touched updated_at, which no longer counts, so they bump the
+-- version directly
+CREATE OR REPLACE FUNCTION public.touch_schedule_project()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    UPDATE public.projects SET version = version + 1
+    WHERE id = COALESCE(NEW.project_id, OLD.project_id);
+    RETURN NULL;
+END;
+$$;
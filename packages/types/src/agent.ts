@@ -24,7 +24,9 @@ export type ServerMessageType =
   | "tool_result"
   | "thinking"
   | "done"
-  | "error";
+  | "error"
+  | "budget_warning"
+  | "budget_exceeded";
 
 /** Tool data in messages */
 export interface ToolData {
@@ -41,6 +43,19 @@ export interface UsageStats {
   inputTokens: number;
   outputTokens: number;
   cost?: number;
+  model?: string;
+}
+
+/** Agent budget a budget_warning, budget_exceeded or refused prompt is about */
+export interface BudgetNoticeInfo {
+  id: string;
+  scope: "user" | "project";
+  project_id?: string;
+  limit_usd: number;
+  spent_usd: number;
+  /** Percentage of the limit a budget_warning passed */
+  threshold?: number;
+  resets_at: string;
 }
 
 /** Server -> Client message */
@@ -61,6 +76,7 @@ export interface ServerMessage {
   result?: string;
   usage?: UsageStats;
   error?: string;
+  budget?: BudgetNoticeInfo;
 }
 
 // =============================================================================
@@ -5,25 +5,70 @@
 import type { HardwareConfig, IdleTimeoutMinutes } from "./hardware";
 
 /** Project status */
-export type ProjectStatus = "stopped" | "starting" | "running" | "stopping" | "error";
+export type ProjectStatus = "stopped" | "starting" | "running" | "stopping" | "error" | "deleting" | "restoring" | "hibernating" | "hibernated" | "trashed" | "moving";
 
 /** Project entity */
 export interface Project {
   id: string;
+  /** Set on projects shared through an organization */
+  organization_id?: string;
+  /** The caller's role on the project; owner of their personal projects */
+  role?: OrganizationRole;
   name: string;
   description?: string;
   status: ProjectStatus;
   hardware: HardwareConfig;
+  /** Region the project's volume and machine live in; unset on projects in the default region from before regions existed */
+  region?: string;
   idle_timeout_minutes?: IdleTimeoutMinutes;
   fly_machine_id?: string;
+  parent_project_id?: string;
+  /** Built-in template name or user template ID the project was created from */
+  template_id?: string;
+  /** Ports the project serves previews on */
+  exposed_ports: number[];
+  /** Hardware changed since the machine was created; applied on next (re)start */
+  hardware_pending?: boolean;
+  /** Env vars changed since the machine was configured; applied on next (re)start */
+  env_pending?: boolean;
+  /** Labels changed since the machine was configured; applied on next (re)start */
+  labels_pending?: boolean;
+  /** Image the project runs */
+  image: string;
+  /** Image changed since the machine was created; the machine is replaced on next (re)start */
+  image_pending?: boolean;
+  /** Start and stop schedules, if any */
+  schedules?: ProjectSchedule[];
   private_ip?: string;
   preview_token?: string;
   error_message?: string;
   last_accessed_at?: string;
+  /** Idle stops are held off until this time */
+  idle_snoozed_until?: string;
+  /** Opening a preview URL starts the project when it is stopped */
+  wake_on_request: boolean;
+  tags: string[];
+  /** Key/value labels, also attached to the project's machine */
+  labels: Record<string, string>;
+  /** Bumped on every change; sent back as If-Match to avoid overwriting someone else's edit */
+  version: number;
+  /** Set while the project's files are archived in cold storage */
+  hibernated_at?: string;
+  archive_size_bytes?: number;
+  /** Set while the project is in the trash */
+  deleted_at?: string;
   created_at: string;
   updated_at: string;
 }
 
+/** A project in the trash, from GET /projects/trash */
+export interface TrashedProject extends Project {
+  /** Why the last purge attempt failed, if it did */
+  error_message?: string;
+  /** When the project will be deleted for good */
+  purge_at: string;
+}
+
 /** Input for creating a project */
 export interface CreateProjectInput {
   name: string;
@@ -37,18 +82,229 @@ export interface CreateProjectInput {
     gpu_kind?: string | null;
   };
   idle_timeout_minutes?: IdleTimeoutMinutes;
+  /** Built-in template name (e.g. "node") or user template ID */
+  template_id?: string;
+  tags?: string[];
+  labels?: Record<string, string>;
+  /** Defaults to the default region, or the first region offering the hardware */
+  region?: string;
+  /** Creates the project in an organization; its defaults fill in unset hardware and idle timeout */
+  organization_id?: string;
 }
 
 /** Input for updating a project */
 export interface UpdateProjectInput {
   name?: string;
   description?: string;
+  /** Requires the project to have an idle timeout */
+  wake_on_request?: boolean;
+  /** Replaces the project's tags; an empty list clears them */
+  tags?: string[];
+}
+
+/** Input for PUT /projects/:id/labels, which replaces all of a project's labels */
+export interface SetLabelsInput {
+  labels: Record<string, string>;
+}
+
+/** Sort keys for listing projects */
+export type ProjectSort = "updated_at" | "created_at" | "last_accessed_at" | "name";
+
+/** Query for GET /projects. Filters combine with AND; every tag must match. */
+export interface ListProjectsQuery {
+  status?: ProjectStatus[];
+  cpu_kind?: string;
+  gpu?: boolean;
+  gpu_kind?: string;
+  tag?: string[];
+  /** "key:value" matches that value, a bare "key" any value; every label must match */
+  label?: string[];
+  /** Substring of the name or description, ignoring case */
+  q?: string;
+  sort?: ProjectSort;
+  /** Defaults to desc for times and asc for name */
+  order?: "asc" | "desc";
+  /** 1-200, default 50 */
+  limit?: number;
+  /** next_cursor from the previous page */
+  cursor?: string;
+  /** Only projects in this organization, or "personal" for the caller's own */
+  organization_id?: string;
+}
+
+/** A page of projects from GET /projects */
+export interface ProjectListResponse {
+  projects: Project[];
+  /** Present when there are more projects */
+  next_cursor?: string;
+}
+
+/** Input for changing a project's hardware; running projects need restart */
+export interface UpdateHardwareInput {
+  preset?: string;
+  cpu_kind?: string;
+  cpus?: number;
+  memory_mb?: number;
+  volume_size_gb?: number;
+  gpu_kind?: string | null;
+  restart?: boolean;
+}
+
+/** Response from changing a project's hardware */
+export interface UpdateHardwareResponse {
+  project: Project;
+  operation_id?: string;
+}
+
+/** A region projects can be placed in, and the hardware it offers */
+export interface Region {
+  code: string;
+  name?: string;
+  /** Empty offers every CPU kind */
+  cpu_kinds?: string[];
+  /** Unset leaves the usual limits */
+  max_cpus?: number;
+  max_memory_mb?: number;
+  /** Empty offers no GPUs */
+  gpu_kinds?: string[];
+}
+
+/** Response from GET /regions */
+export interface RegionListResponse {
+  regions: Region[];
+  /** Where projects go when they don't pick a region */
+  default: string;
+}
+
+/** Input for moving a stopped or hibernated project to another region */
+export interface MoveProjectInput {
+  region: string;
+}
+
+/** Response from POST /projects/{id}/move; poll the operation for completion */
+export interface MoveProjectResponse {
+  project: Project;
+  operation_id: string;
+}
+
+/** Input for forking a project; defaults to "<name>-fork" and the source description */
+export interface ForkProjectInput {
+  name?: string;
+  description?: string;
 }
 
 /** Response from starting a project */
 export interface StartProjectResponse {
   status: string;
   terminal_url: string;
+  operation_id?: string;
+}
+
+/** Who took a snapshot */
+export type SnapshotCreator = "user" | "automatic";
+
+/** Point-in-time copy of a project's volume */
+export interface Snapshot {
+  id: string;
+  project_id: string;
+  label?: string;
+  size_bytes: number;
+  created_by: SnapshotCreator;
+  created_at: string;
+  /** When the backend discards the snapshot; absent if it is kept until deleted */
+  expires_at?: string;
+}
+
+/** Input for creating a snapshot */
+export interface CreateSnapshotInput {
+  label?: string;
+}
+
+/** Response from restoring a snapshot; poll the operation for completion */
+export interface RestoreSnapshotResponse {
+  project: Project;
+  operation_id: string;
+}
+
+/** Starting point for new projects; applied on the project's first boot */
+export interface Template {
+  id: string;
+  name: string;
+  description?: string;
+  base_image?: string;
+  hardware_preset?: string;
+  env_vars: Record<string, string>;
+  ports: number[];
+  /** Relative path inside the project -> file content */
+  files: Record<string, string>;
+  git_url?: string;
+  post_create_script?: string;
+  built_in: boolean;
+  created_at?: string;
+}
+
+/** Input for creating a user-defined template */
+export interface CreateTemplateInput {
+  name: string;
+  description?: string;
+  base_image?: string;
+  hardware_preset?: string;
+  env_vars?: Record<string, string>;
+  ports?: number[];
+  files?: Record<string, string>;
+  git_url?: string;
+  post_create_script?: string;
+}
+
+/** Project env var; secret values are masked as "********" */
+export interface EnvVar {
+  name: string;
+  value: string;
+  secret: boolean;
+  updated_at: string;
+}
+
+/** Input for setting project env vars; omit value to change only the secret flag */
+export interface SetEnvVarsInput {
+  vars: Record<string, { value?: string; secret?: boolean }>;
+}
+
+/** Response from the project env endpoints */
+export interface EnvVarListResponse {
+  vars: EnvVar[];
+  /** The machine hasn't picked up the latest vars yet */
+  pending: boolean;
+}
+
+/** Project lifecycle event type */
+export type ProjectEventType =
+  | "status"
+  | "machine_assigned"
+  | "volume_assigned"
+  | "idle_stop"
+  | "idle_warning"
+  | "idle_snoozed"
+  | "snapshot_created"
+  | "snapshot_restored"
+  | "template_applied"
+  | "template_failed"
+  | "image_upgraded"
+  | "schedule_run"
+  | "preview_wake"
+  | "hibernated"
+  | "archive_restored"
+  | "region_moved"
+  | "organization_changed";
+
+/** Event from the /events stream (SSE or WebSocket) */
+export interface ProjectEvent {
+  id: number;
+  project_id: string;
+  type: ProjectEventType;
+  status?: ProjectStatus;
+  message?: string;
+  data?: Record<string, unknown>;
+  created_at: string;
 }
 
 // =============================================================================
@@ -91,6 +347,7 @@ export interface FileTree {
 export interface UserSettings {
   default_hardware: HardwareConfig;
   default_idle_timeout_minutes: IdleTimeoutMinutes;
+  version: number;
 }
 
 /** Input for updating user settings */
@@ -99,6 +356,210 @@ export interface UpdateUserSettingsInput {
   default_idle_timeout_minutes?: IdleTimeoutMinutes;
 }
 
+// =============================================================================
+// Plan Quota Types
+// =============================================================================
+
+/** A quota a plan can limit */
+export type QuotaName = "projects" | "volume_gb" | "gpu" | "running_machines" | "vcpus" | "memory_mb";
+
+/** A plan's limits; null is unlimited */
+export interface QuotaLimits {
+  projects: number | null;
+  volume_gb: number | null;
+  gpu: boolean;
+  running_machines: number | null;
+  vcpus: number | null;
+  memory_mb: number | null;
+}
+
+/** What a user's projects hold right now */
+export interface QuotaUsage {
+  projects: number;
+  volume_gb: number;
+  running_machines: number;
+  vcpus: number;
+  memory_mb: number;
+}
+
+/** A user's usage against their plan; plan is null when nothing is limited */
+export interface QuotaResponse {
+  plan: { id: string; name: string } | null;
+  limits: QuotaLimits;
+  usage: QuotaUsage;
+}
+
+/** Body of a 403 (needs a bigger plan) or 429 (stop a machine first) quota rejection */
+export interface QuotaError {
+  error: string;
+  quota: QuotaName;
+  plan: string;
+  limit: number;
+  used: number;
+  requested: number;
+}
+
+// =============================================================================
+// Usage Report Types
+// =============================================================================
+
+/** How a usage report is split */
+export type UsagePeriod = "day" | "month";
+
+/** Machine time used, and what it cost */
+export interface UsageTotals {
+  machine_hours: number;
+  vcpu_hours: number;
+  memory_gb_hours: number;
+  gpu_hours: number;
+  cost: number;
+}
+
+/** Usage within one UTC day (2006-01-02) or month (2006-01) */
+export interface UsageBucket extends UsageTotals {
+  period: string;
+  start: string;
+}
+
+/** Usage over a range, from GET /user/usage or /projects/{id}/usage */
+export interface UsageReport {
+  period: UsagePeriod;
+  from: string;
+  to: string;
+  currency: string;
+  buckets: UsageBucket[];
+  total: UsageTotals;
+}
+
+/** Query for a usage report; from and to are dates, to exclusive */
+export interface UsageQuery {
+  period?: UsagePeriod;
+  from?: string;
+  to?: string;
+}
+
+// =============================================================================
+// Agent Usage Types
+// =============================================================================
+
+/** How an agent usage report is grouped */
+export type LLMUsageGroupBy = "project" | "agent" | "model" | "session" | "day" | "month";
+
+/** Tokens agents used, and what they cost in USD */
+export interface LLMUsageTotals {
+  requests: number;
+  input_tokens: number;
+  output_tokens: number;
+  cost_usd: number;
+}
+
+/** Usage for one group; name is the project's name for projects and sessions */
+export interface LLMUsageGroup extends LLMUsageTotals {
+  key: string;
+  name?: string;
+}
+
+/** Agent usage over a range, from GET /user/llm-usage or /projects/{id}/llm-usage */
+export interface LLMUsageReport {
+  group_by: LLMUsageGroupBy;
+  from: string;
+  to: string;
+  groups: LLMUsageGroup[];
+  total: LLMUsageTotals;
+}
+
+/** Query for an agent usage report; from and to are dates, to exclusive */
+export interface LLMUsageQuery {
+  group_by?: LLMUsageGroupBy;
+  from?: string;
+  to?: string;
+}
+
+// =============================================================================
+// Agent Budget Types
+// =============================================================================
+
+/** A monthly cap on agent spend, for the whole account or one project */
+export interface AgentBudget {
+  id: string;
+  scope: "user" | "project";
+  project_id: string | null;
+  monthly_limit_usd: number;
+  /** Percentages of the limit at which agent sessions are warned */
+  warning_thresholds: number[];
+  /** Day of the month (UTC) spend starts over */
+  reset_day: number;
+  reset_at?: string;
+  period_start: string;
+  period_end: string;
+  spent_usd: number;
+  remaining_usd: number;
+  /** New prompts are refused while a budget is exceeded */
+  exceeded: boolean;
+  created_at: string;
+}
+
+export interface CreateAgentBudgetInput {
+  project_id?: string;
+  monthly_limit_usd: number;
+  warning_thresholds?: number[];
+  reset_day?: number;
+}
+
+export interface UpdateAgentBudgetInput {
+  monthly_limit_usd?: number;
+  warning_thresholds?: number[];
+  reset_day?: number;
+}
+
+// =============================================================================
+// Organization Types
+// =============================================================================
+
+/**
+ * Roles, from most to least access: owners manage the organization and its
+ * owners; admins manage members, settings and every project; members use and
+ * operate projects; viewers read them
+ */
+export type OrganizationRole = "owner" | "admin" | "member" | "viewer";
+
+export interface Organization {
+  id: string;
+  name: string;
+  /** The caller's role */
+  role: OrganizationRole;
+  /** Defaults over each member's own settings for projects in the organization */
+  default_hardware: HardwareConfig | null;
+  default_idle_timeout_minutes: IdleTimeoutMinutes | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface UpdateOrganizationSettingsInput {
+  /** Leaving a default out or null lets members' own settings apply */
+  default_hardware?: HardwareConfig | null;
+  default_idle_timeout_minutes?: IdleTimeoutMinutes | null;
+}
+
+export interface OrganizationMember {
+  user_id: string;
+  email: string;
+  display_name?: string;
+  role: OrganizationRole;
+  joined_at: string;
+}
+
+export interface OrganizationInvitation {
+  id: string;
+  organization_id: string;
+  organization_name: string;
+  email: string;
+  role: OrganizationRole;
+  invited_by?: string;
+  expires_at: string;
+  created_at: string;
+}
+
 // =============================================================================
 // API Keys Types
 // =============================================================================
@@ -115,6 +576,139 @@ export interface ListProvidersResponse {
   providers: ConnectedProvider[];
 }
 
+// =============================================================================
+// Schedule Types
+// =============================================================================
+
+/** What a schedule does when it fires */
+export type ScheduleAction = "start" | "stop";
+
+/** Outcome of a schedule's last run */
+export type ScheduleResult = "triggered" | "skipped" | "missed" | "failed";
+
+/** Cron schedule that starts or stops a project */
+export interface ProjectSchedule {
+  id: string;
+  action: ScheduleAction;
+  /** Five-field cron expression, e.g. "0 9 * * mon-fri" */
+  cron: string;
+  /** IANA timezone the expression is read in */
+  timezone: string;
+  enabled: boolean;
+  next_run_at?: string;
+  last_run_at?: string;
+  last_result?: ScheduleResult;
+  last_error?: string;
+  last_operation_id?: string;
+  created_at: string;
+}
+
+/** Input for creating a schedule */
+export interface CreateScheduleInput {
+  action: ScheduleAction;
+  cron: string;
+  /** Defaults to UTC */
+  timezone?: string;
+  /** Defaults to true */
+  enabled?: boolean;
+}
+
+/** Input for updating a schedule; omitted fields are unchanged */
+export interface UpdateScheduleInput {
+  action?: ScheduleAction;
+  cron?: string;
+  timezone?: string;
+  enabled?: boolean;
+}
+
+/** Response from GET /projects/{id}/schedules */
+export interface ScheduleListResponse {
+  schedules: ProjectSchedule[];
+}
+
+/** Input for POST /projects/{id}/idle/snooze */
+export interface SnoozeIdleInput {
+  /** Keep the project running for this many minutes, 1-240 */
+  minutes: number;
+}
+
+/** Response from POST /projects/{id}/idle/snooze */
+export interface SnoozeIdleResponse {
+  snoozed_until: string;
+}
+
+/** Sent on the workspace WebSocket's "idle" channel before an idle stop, and when it is snoozed */
+export type IdleNotice =
+  | { channel: "idle"; type: "warning"; stops_at: string; max_snooze_minutes: number }
+  | { channel: "idle"; type: "snoozed"; snoozed_until: string };
+
+// =============================================================================
+// Image Upgrade Types
+// =============================================================================
+
+/** Response from POST /projects/{id}/upgrade */
+export interface UpgradeProjectResponse {
+  project: Project;
+  /** Set when a running project is being restarted onto the new image */
+  operation_id?: string;
+}
+
+/** Image rollout status */
+export type RolloutStatus = "canary" | "paused" | "rolling" | "completed" | "rolling_back" | "rolled_back";
+
+/** A project's progress through a rollout */
+export type RolloutProjectStatus =
+  | "pending"
+  | "upgrading"
+  | "upgraded"
+  | "failed"
+  | "reverting"
+  | "reverted"
+  | "revert_failed"
+  | "skipped";
+
+/** Operator rollout of a new base image across existing projects */
+export interface Rollout {
+  id: string;
+  image: string;
+  canary_percent: number;
+  batch_size: number;
+  status: RolloutStatus;
+  pause_reason?: string;
+  created_by?: string;
+  created_at: string;
+  updated_at: string;
+  completed_at?: string;
+}
+
+/** One project in a rollout */
+export interface RolloutProject {
+  rollout_id: string;
+  project_id: string;
+  /** Image restored on rollback */
+  previous_image: string;
+  canary: boolean;
+  status: RolloutProjectStatus;
+  operation_id?: string;
+  error?: string;
+  updated_at: string;
+}
+
+/** Rollout with per-project progress */
+export interface RolloutDetail extends Rollout {
+  counts: Partial<Record<RolloutProjectStatus, number>>;
+  projects: RolloutProject[];
+}
+
+/** Input for starting a rollout */
+export interface CreateRolloutInput {
+  image: string;
+  /** Percent of projects upgraded first; 0 skips the canary phase (default 5) */
+  canary_percent?: number;
+  /** Projects restarted at a time (default 10) */
+  batch_size?: number;
+}
+
 // =============================================================================
 // API Response Types
 // =============================================================================